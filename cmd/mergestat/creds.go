@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// credentialTypes are the default type of the credentials of a provider, by vendor
+var credentialTypes = map[string]string{
+	"github":    "GITHUB_PAT",
+	"gitlab":    "GITLAB_PAT",
+	"bitbucket": "BITBUCKET_APP_PASSWORD",
+	"azure":     "AZURE_DEVOPS_PAT",
+}
+
+func credsCommand() *cobra.Command {
+	var cmd = &cobra.Command{Use: "creds", Short: "Manage the credentials of providers"}
+	cmd.AddCommand(credsSetCommand())
+	return cmd
+}
+
+func credsSetCommand() *cobra.Command {
+	var credentialType, username string
+
+	var cmd = &cobra.Command{
+		Use:   "set <provider>",
+		Short: "Add a credential to a provider (given by id or name), reading the token from stdin",
+		Long: "Add a credential to a provider (given by id or name), reading the token from stdin so that it doesn't end up in " +
+			"the shell history. The credential is encrypted with ENCRYPTION_SECRET, which must match the one of the workers.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			token, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if token = strings.TrimSpace(token); token == "" {
+				return fmt.Errorf("a token must be given on stdin: %v", err)
+			}
+
+			return withDB(cmd.Context(), func(q *db.Queries) error {
+				p, err := q.FindProvider(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+
+				var typ = credentialType
+				if typ == "" {
+					if typ = credentialTypes[p.Vendor]; typ == "" {
+						return fmt.Errorf("the type of the credential must be set with --type for a %s provider", p.Vendor)
+					}
+				}
+
+				id, err := q.AddCredential(cmd.Context(), p.ID, strings.ToUpper(typ), username, token)
+				if err != nil {
+					return err
+				}
+
+				fmt.Printf("added %s credential %s to provider %s\n", strings.ToUpper(typ), id, p.Name)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&credentialType, "type", "", "type of the credential, e.g. GITHUB_PAT (the default of the provider's vendor if empty)")
+	cmd.Flags().StringVar(&username, "username", "", "username of the credential, if the vendor requires one (e.g. Bitbucket)")
+	return cmd
+}
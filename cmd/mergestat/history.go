@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/spf13/cobra"
+)
+
+func historyCommand() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "history",
+		Short: "Manage the history tables of synced tables",
+		Long: "Manage the history of synced tables: once enabled for a table, every version of its rows written by syncs is " +
+			"appended to a <table>_history table, valid from valid_from until valid_to (NULL for the current version), " +
+			"e.g. to query the branches that existed last month out of git_refs_history.",
+	}
+	cmd.AddCommand(historyEnableCommand(), historyDisableCommand(), historyListCommand())
+	return cmd
+}
+
+func historyEnableCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "enable <table>...",
+		Short: "Enable the history of synced tables",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withDB(cmd.Context(), func(q *db.Queries) error {
+				for _, table := range args {
+					if err := q.EnableHistory(cmd.Context(), table); err != nil {
+						return fmt.Errorf("failed to enable the history of %s: %w", table, err)
+					}
+					fmt.Printf("enabled the history of %s, in %s_history\n", table, table)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func historyDisableCommand() *cobra.Command {
+	var drop bool
+
+	var cmd = &cobra.Command{
+		Use:   "disable <table>...",
+		Short: "Disable the history of synced tables, keeping their history tables unless --drop is set",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withDB(cmd.Context(), func(q *db.Queries) error {
+				for _, table := range args {
+					if err := q.DisableHistory(cmd.Context(), table, drop); err != nil {
+						return fmt.Errorf("failed to disable the history of %s: %w", table, err)
+					}
+					fmt.Printf("disabled the history of %s\n", table)
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().BoolVar(&drop, "drop", false, "drop the history tables as well")
+	return cmd
+}
+
+func historyListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the synced tables whose history is enabled",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withDB(cmd.Context(), func(q *db.Queries) error {
+				tables, err := q.ListHistoryTables(cmd.Context())
+				if err != nil {
+					return err
+				}
+
+				for _, table := range tables {
+					fmt.Println(table)
+				}
+				return nil
+			})
+		},
+	}
+}
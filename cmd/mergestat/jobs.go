@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/logstream"
+	"github.com/spf13/cobra"
+)
+
+func jobsCommand() *cobra.Command {
+	var cmd = &cobra.Command{Use: "jobs", Short: "Follow sync jobs"}
+	cmd.AddCommand(jobsTailCommand())
+	return cmd
+}
+
+func jobsTailCommand() *cobra.Command {
+	var lines int
+	var job int64
+
+	var cmd = &cobra.Command{
+		Use:   "tail [repo]",
+		Short: "Stream the logs of sync jobs as they're logged, optionally only of a repo (given by id or url) or a job",
+		Long: "Stream the logs of sync jobs as they're logged, optionally only of a repo (given by id or url) or a job, " +
+			"until interrupted. The logs of a job are streamed from its start, and until it's done.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var ctx = cmd.Context()
+			return withConn(ctx, func(conn *pgx.Conn) (err error) {
+				var q = db.New(conn)
+				var filter = db.SyncLogFilter{Job: job}
+				if len(args) > 0 {
+					var id uuid.UUID
+					if id, err = q.FindRepo(ctx, args[0]); err != nil {
+						return err
+					}
+					filter.Repo = uuid.NullUUID{UUID: id, Valid: true}
+				}
+
+				var after int64
+				if job == 0 {
+					if after, err = q.SyncLogIDBefore(ctx, lines); err != nil {
+						return err
+					}
+				}
+
+				err = logstream.Follow(ctx, conn, filter, after, func(l *db.SyncLogLine) error {
+					_, err := fmt.Printf("%s  %-7s  job %d  %s  %s  %s\n", l.CreatedAt.Local().Format(time.Stamp), l.Type, l.JobID, l.SyncType, l.Repo, l.Message)
+					return err
+				})
+				if ctx.Err() != nil {
+					return nil // interrupted
+				}
+				return err
+			})
+		},
+	}
+	cmd.Flags().IntVarP(&lines, "lines", "n", 10, "number of the latest log lines (of all repos) to start from, unless following a job")
+	cmd.Flags().Int64Var(&job, "job", 0, "id of the job to stream the logs of")
+	return cmd
+}
@@ -0,0 +1,58 @@
+// Command mergestat manages the repos, syncs and credentials of a MergeStat instance, talking to its Postgres database
+// (set with POSTGRES_CONNECTION, or --db) directly, so that operators don't have to hand-write SQL.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// connection is the connection string of the database, set with --db (POSTGRES_CONNECTION by default)
+var connection string
+
+func main() {
+	var root = &cobra.Command{
+		Use:           "mergestat",
+		Short:         "Manage the repos, syncs and credentials of MergeStat",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+	root.PersistentFlags().StringVar(&connection, "db", os.Getenv("POSTGRES_CONNECTION"), "connection string of the MergeStat database")
+	root.AddCommand(repoCommand(), syncCommand(), credsCommand(), jobsCommand(), workersCommand(), historyCommand())
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := root.ExecuteContext(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// withDB runs fn with the queries of a connection to the database, closing it once fn returns
+func withDB(ctx context.Context, fn func(*db.Queries) error) error {
+	return withConn(ctx, func(conn *pgx.Conn) error { return fn(db.New(conn)) })
+}
+
+// withConn runs fn with a connection to the database, closing it once fn returns
+func withConn(ctx context.Context, fn func(*pgx.Conn) error) error {
+	if connection == "" {
+		return fmt.Errorf("the database to connect to must be set, with --db or POSTGRES_CONNECTION")
+	}
+
+	conn, err := pgx.Connect(ctx, connection)
+	if err != nil {
+		return fmt.Errorf("could not connect to database: %w", err)
+	}
+	defer conn.Close(context.Background())
+
+	return fn(conn)
+}
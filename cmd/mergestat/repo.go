@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/spf13/cobra"
+)
+
+func repoCommand() *cobra.Command {
+	var cmd = &cobra.Command{Use: "repo", Short: "Manage repos"}
+	cmd.AddCommand(repoAddCommand(), repoListCommand())
+	return cmd
+}
+
+func repoAddCommand() *cobra.Command {
+	var provider string
+	var syncTypes []string
+
+	var cmd = &cobra.Command{
+		Use:   "add <url>",
+		Short: "Add a repo, optionally scheduling syncs for it",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withDB(cmd.Context(), func(q *db.Queries) error {
+				p, err := q.FindProvider(cmd.Context(), provider)
+				if err != nil {
+					return err
+				}
+
+				id, err := q.AddRepo(cmd.Context(), args[0], p.ID, syncTypes)
+				if err != nil {
+					return err
+				}
+
+				fmt.Printf("added repo %s (%s) to provider %s\n", args[0], id, p.Name)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&provider, "provider", "", "id or name of the provider of the repo (if there's more than one)")
+	cmd.Flags().StringSliceVar(&syncTypes, "sync", nil, "sync types to schedule for the repo, e.g. GIT_COMMITS (repeatable)")
+	return cmd
+}
+
+func repoListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List repos, along with their scheduled syncs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withDB(cmd.Context(), func(q *db.Queries) error {
+				repos, err := q.ListRepos(cmd.Context())
+				if err != nil {
+					return err
+				}
+
+				var w = tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+				fmt.Fprintln(w, "ID\tREPO\tPROVIDER\tTENANT\tSYNCS\tPAUSED")
+				for _, r := range repos {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%t\n", r.ID, r.Repo, r.Provider, r.Tenant, strings.Join(r.Syncs, ","), r.Paused)
+				}
+				return w.Flush()
+			})
+		},
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/spf13/cobra"
+)
+
+func syncCommand() *cobra.Command {
+	var cmd = &cobra.Command{Use: "sync", Short: "Manage the syncs of repos"}
+	cmd.AddCommand(syncEnableCommand(true), syncEnableCommand(false), syncTriggerCommand())
+	return cmd
+}
+
+// syncEnableCommand returns the command scheduling (if enable is set) or unscheduling the syncs of a repo
+func syncEnableCommand(enable bool) *cobra.Command {
+	var use, short, done = "enable", "Schedule syncs of a repo", "scheduled"
+	if !enable {
+		use, short, done = "disable", "Unschedule syncs of a repo", "unscheduled"
+	}
+
+	return &cobra.Command{
+		Use:   use + " <repo> <sync type>...",
+		Short: short + ", given by id or url",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withDB(cmd.Context(), func(q *db.Queries) error {
+				repo, err := q.FindRepo(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+
+				for _, syncType := range args[1:] {
+					if err = q.SetRepoSyncEnabled(cmd.Context(), repo, strings.ToUpper(syncType), enable); err != nil {
+						return err
+					}
+					fmt.Printf("%s %s of %s\n", done, strings.ToUpper(syncType), args[0])
+				}
+				return nil
+			})
+		},
+	}
+}
+
+func syncTriggerCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "trigger <repo> <sync type>...",
+		Short: "Enqueue syncs of a repo (given by id or url) to run right away",
+		Args:  cobra.MinimumNArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return withDB(cmd.Context(), func(q *db.Queries) error {
+				repo, err := q.FindRepo(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+
+				for _, syncType := range args[1:] {
+					id, err := q.SyncRepoNow(cmd.Context(), repo, strings.ToUpper(syncType))
+					if err != nil {
+						return err
+					}
+					fmt.Printf("enqueued %s of %s as job %d\n", strings.ToUpper(syncType), args[0], id)
+				}
+				return nil
+			})
+		},
+	}
+}
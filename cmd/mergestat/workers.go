@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/spf13/cobra"
+)
+
+func workersCommand() *cobra.Command {
+	var cmd = &cobra.Command{Use: "workers", Short: "Manage the database users of workers"}
+	cmd.AddCommand(workersUserCommand())
+	return cmd
+}
+
+func workersUserCommand() *cobra.Command {
+	var tenant string
+
+	var cmd = &cobra.Command{
+		Use:   "user <username>",
+		Short: "Create (or update) the least-privilege database user of a worker, reading its password from stdin",
+		Long: "Create (or update) the database user of a worker, reading its password from stdin so that it doesn't end up in " +
+			"the shell history. The user can read and write rows, but not alter the schema, so workers connecting as it must " +
+			"be run with SKIP_MIGRATIONS. With --tenant, the user can only read and write the rows of that tenant.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if password = strings.TrimSpace(password); password == "" {
+				return fmt.Errorf("a password must be given on stdin: %v", err)
+			}
+
+			return withDB(cmd.Context(), func(q *db.Queries) error {
+				if err := q.CreateWorkerUser(cmd.Context(), args[0], password, tenant); err != nil {
+					return err
+				}
+
+				if tenant != "" {
+					fmt.Printf("created worker user %s, pinned to tenant %s\n", args[0], tenant)
+				} else {
+					fmt.Printf("created worker user %s\n", args[0])
+				}
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&tenant, "tenant", "", "tenant to pin the user to (of any tenant if empty)")
+	return cmd
+}
@@ -6,7 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	_ "net/http/pprof"
+	"net/http/pprof"
 	"net/url"
 	"os"
 	"os/signal"
@@ -15,13 +15,29 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/mergestat/mergestat/internal/api"
+	"github.com/mergestat/mergestat/internal/clickhouse"
+	"github.com/mergestat/mergestat/internal/config"
 	"github.com/mergestat/mergestat/internal/cron"
 	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/destination"
+	"github.com/mergestat/mergestat/internal/envelope"
+	"github.com/mergestat/mergestat/internal/export"
+	"github.com/mergestat/mergestat/internal/health"
 	"github.com/mergestat/mergestat/internal/helper"
 	"github.com/mergestat/mergestat/internal/jobs/repo"
 	"github.com/mergestat/mergestat/internal/jobs/sync/podman"
+	"github.com/mergestat/mergestat/internal/logstream"
+	"github.com/mergestat/mergestat/internal/manifest"
+	"github.com/mergestat/mergestat/internal/retention"
+	"github.com/mergestat/mergestat/internal/scratch"
+	"github.com/mergestat/mergestat/internal/sealer"
+	"github.com/mergestat/mergestat/internal/sink"
+	"github.com/mergestat/mergestat/internal/standalone"
 	"github.com/mergestat/mergestat/internal/syncer"
 	"github.com/mergestat/mergestat/internal/timeout"
+	"github.com/mergestat/mergestat/internal/tracing"
+	"github.com/mergestat/mergestat/internal/webhook"
 	"github.com/mergestat/mergestat/queries"
 	"github.com/mergestat/sqlq/runtime/embed"
 	"github.com/mergestat/sqlq/schema"
@@ -43,15 +59,7 @@ import (
 	"github.com/mergestat/mergestat/internal/scheduler"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/zerolog"
-	"github.com/shurcooL/githubv4"
 	"go.riyazali.net/sqlite"
-	"golang.org/x/oauth2"
-)
-
-var (
-	postgresConnection = os.Getenv("POSTGRES_CONNECTION")
-	// baseCloneDir       = os.Getenv("BASE_CLONE_DIR")
-	concurrencyEnv = os.Getenv("CONCURRENCY")
 )
 
 func repoLocator() services.RepoLocator {
@@ -74,10 +82,9 @@ func repoLocator() services.RepoLocator {
 // 	return http.DefaultTransport.RoundTrip(r)
 // }
 
-// logLevelFromEnv retrieves the log level from the LOG_LEVEL environment variable
-// and returns the corresponding zerolog level.
-func logLevelFromEnv() zerolog.Level {
-	switch os.Getenv("LOG_LEVEL") {
+// logLevel returns the zerolog level corresponding to the configured log level
+func logLevel(level string) zerolog.Level {
+	switch level {
 	case "debug":
 		return zerolog.DebugLevel
 	case "info":
@@ -92,12 +99,18 @@ func logLevelFromEnv() zerolog.Level {
 }
 
 func main() {
-	logger := zerolog.New(os.Stderr).With().Timestamp().Logger().Level(logLevelFromEnv())
-	prettyLogs := os.Getenv("PRETTY_LOGS") == "1"
+	logger := zerolog.New(os.Stderr).With().Timestamp().Logger()
+
+	// parse the config (from the config file, if any, and the environment) once, before anything else
+	cfg, err := config.Load()
+	if err != nil {
+		logger.Err(err).Msgf("could not load config: %v", err)
+		os.Exit(1)
+	}
+	logger = logger.Level(logLevel(cfg.LogLevel))
 
-	// if stdout is a terminal or if the PRETTY_LOGS environment variable is set
-	// to 1, use a human-friendly log formatter
-	if fileInfo, _ := os.Stdout.Stat(); (fileInfo.Mode()&os.ModeCharDevice) != 0 || prettyLogs {
+	// if stdout is a terminal or if pretty logs are enabled, use a human-friendly log formatter
+	if fileInfo, _ := os.Stdout.Stat(); (fileInfo.Mode()&os.ModeCharDevice) != 0 || cfg.PrettyLogs {
 		logger = logger.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.Stamp})
 	}
 	zerolog.DefaultContextLogger = &logger
@@ -105,69 +118,83 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
-	var err error
-	concurrency := 1
-	if concurrencyEnv != "" {
-		if c, err := strconv.Atoi(concurrencyEnv); err != nil {
-			logger.Err(err).Msgf("could not parse CONCURRENCY env into an int: %s", concurrencyEnv)
-		} else {
-			concurrency = c
+	// in standalone mode, sync a single repo into a local SQLite file and exit, without Postgres or a sync queue
+	if cfg.StandaloneRepo != "" {
+		sqlite.Register(extensions.RegisterFn(
+			options.WithExtraFunctions(),
+			options.WithRepoLocator(repoLocator()),
+		))
+
+		if err = standalone.Sync(ctx, &logger, cfg.StandaloneRepo, cfg.StandaloneOutput, cfg.StandaloneSyncTypesList(), cfg.GitClonePath); err != nil {
+			logger.Err(err).Msgf("could not sync repo in standalone mode: %v", err)
+			os.Exit(1)
 		}
+		return
 	}
 
 	// https://www.alexedwards.net/blog/change-url-query-params-in-go
 	var u *url.URL
-	if u, err = url.Parse(postgresConnection); err != nil {
+	if u, err = url.Parse(cfg.PostgresConnection); err != nil {
 		logger.Err(err).Msgf("could not parse database connection string: %v", err)
 		os.Exit(1)
 	}
 	v := u.Query()
-	v.Add("pool_max_conns", strconv.Itoa(concurrency+5))
+	v.Add("pool_max_conns", strconv.Itoa(cfg.Concurrency+5))
 	u.RawQuery = v.Encode()
 
-	var pool *pgxpool.Pool
-	if pool, err = pgxpool.Connect(ctx, u.String()); err != nil {
-		logger.Err(err).Msgf("could not connect to database: %v", err)
+	// export traces of sync jobs (if an OTLP endpoint is configured)
+	var shutdownTracing func(context.Context) error
+	if shutdownTracing, err = tracing.Setup(ctx, "mergestat-worker"); err != nil {
+		logger.Err(err).Msgf("could not setup tracing: %v", err)
 		os.Exit(1)
 	}
-	defer pool.Close()
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Err(err).Msgf("could not flush traces: %v", err)
+		}
+	}()
 
-	// create a new sqlq worker to process tasks in background
-	var upstream *sql.DB
-	if upstream, err = sql.Open("pgx", postgresConnection); err != nil {
-		logger.Fatal().Err(err).Msg("failed to open connection to upstream")
+	var poolConfig *pgxpool.Config
+	if poolConfig, err = pgxpool.ParseConfig(u.String()); err != nil {
+		logger.Err(err).Msgf("could not parse database connection string: %v", err)
+		os.Exit(1)
 	}
 
-	// this sets the max number of db connections to the same number used by the pgxpool above
-	upstream.SetMaxOpenConns(concurrency + 5)
-
-	// apply sqlq migrations
-	if err := schema.Apply(upstream); err != nil {
-		logger.Fatal().Err(err).Msg("failed to apply sqlq migrations")
+	// record the statements executed during a sync job as spans of its trace, if traces are exported at all (as pgx
+	// otherwise still reports each statement to the logger)
+	if tracing.Enabled() {
+		poolConfig.ConnConfig.Logger, poolConfig.ConnConfig.LogLevel = tracing.PgxLogger{}, pgx.LogLevelInfo
 	}
 
-	var m *migrate.Migrate
-	if m, err = migrate.New("file://migrations", postgresConnection); err != nil {
-		logger.Err(err).Msgf("could not initialize migrations")
+	var pool *pgxpool.Pool
+	if pool, err = pgxpool.ConnectConfig(ctx, poolConfig); err != nil {
+		logger.Err(err).Msgf("could not connect to database: %v", err)
 		os.Exit(1)
 	}
+	defer pool.Close()
 
-	if err := m.Up(); err != nil {
-		if !errors.Is(err, migrate.ErrNoChange) {
-			logger.Err(err).Msgf("could not run migrations: %v", err)
-			os.Exit(1)
-		}
+	// create a new sqlq worker to process tasks in background
+	var upstream *sql.DB
+	if upstream, err = sql.Open("pgx", cfg.PostgresConnection); err != nil {
+		logger.Fatal().Err(err).Msg("failed to open connection to upstream")
 	}
 
-	srcErr, dbErr := m.Close()
-	if srcErr != nil {
-		logger.Err(srcErr).Msgf("could not close migrations with source error: %v", srcErr)
-	}
-	if dbErr != nil {
-		logger.Err(dbErr).Msgf("could not close migrations with db error: %v", dbErr)
+	// this sets the max number of db connections to the same number used by the pgxpool above
+	upstream.SetMaxOpenConns(cfg.Concurrency + 5)
+
+	// migrations (and the schemas of plugin sync types) are applied by the owner of the schema, which a worker
+	// connecting as a least-privilege user isn't
+	if cfg.SkipMigrations {
+		logger.Info().Msg("SKIP_MIGRATIONS is set, skipping migrations")
+	} else {
+		migrateSchema(&logger, upstream, cfg.PostgresConnection)
+		applyPluginSchemas(&logger, upstream)
 	}
 
 	logger.Info().Msg("starting syncer")
+	if cfg.Tenant != "" {
+		logger.Info().Str("tenant", cfg.Tenant).Msg("worker is pinned to a tenant, only running the sync jobs of its repos")
+	}
 
 	l := logger.Level(zerolog.InfoLevel).With().Bool("mergestat-query-exec", true).Logger()
 
@@ -181,6 +208,8 @@ func main() {
 			delayDur = untilResetDur
 		}
 
+		helper.RecordGitHubRateLimit("graphql", rlr.Remaining, rlr.ResetAt.Time)
+
 		if err := helper.WaitForImports(ctx, &l, queries.NewQuerier(db.New(pool))); err != nil {
 			l.Err(err).Msgf("error waiting for imports: %v", err)
 		}
@@ -210,45 +239,19 @@ func main() {
 		githubRequestMutex.Unlock()
 	}
 
-	githubClientGetter := func() *githubv4.Client {
-		encryptionSecret := os.Getenv("ENCRYPTION_SECRET")
-
-		const fetchToken = `
-			SELECT credentials.token
-				FROM (SELECT * FROM mergestat.providers WHERE vendor = 'github') AS provider,
-					  mergestat.fetch_service_auth_credential(provider.id, 'GITHUB_PAT', $1) AS credentials`
-
-		var credentials []byte
-		if err = pool.QueryRow(context.TODO(), fetchToken, encryptionSecret).Scan(&credentials); err != nil && !errors.Is(err, pgx.ErrNoRows) {
-			logger.Err(err).Msgf("error retrieving GitHub PAT from database")
-		}
-
-		// default to GITHUB_TOKEN env var if nothing is in db
-		if credentials == nil {
-			logger.Info().Msg("no GitHub PAT found in DB, using GITHUB_TOKEN env")
-			credentials = []byte(os.Getenv("GITHUB_TOKEN"))
-		}
-
-		httpClient := oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: string(credentials)},
-		))
-		// httpClient.Transport = &mutexRoundTripper{}
-
-		return githubv4.NewClient(httpClient)
-	}
-
 	sqlite.Register(
 		extensions.RegisterFn(
 			options.WithExtraFunctions(),
 			options.WithRepoLocator(locator.CachedLocator(repoLocator())),
 			options.WithGitHub(),
 			// options.WithContextValue("githubToken", os.Getenv("GITHUB_TOKEN")),
-			options.WithContextValue("githubPerPage", os.Getenv("GITHUB_PER_PAGE")),
-			options.WithContextValue("githubRateLimit", os.Getenv("GITHUB_RATE_LIMIT")),
+			options.WithContextValue("githubPerPage", strconv.Itoa(cfg.GitHubPerPage)),
+			options.WithContextValue("githubRateLimit", cfg.GitHubRateLimit),
 			options.WithGitHubRateLimitHandler(ratelimitHandler),
 			options.WithGitHubPreRequestHook(githubPreRequestHook),
 			options.WithGitHubPostRequestHook(githubPostRequestHook),
-			options.WithGitHubClientGetter(githubClientGetter),
+			// the client is the one of the job running the query, for its repo (see syncer.GitHubClient)
+			options.WithGitHubClientGetter(syncer.GitHubClient),
 			options.WithNPM(),
 			options.WithLogger(&l),
 		),
@@ -261,7 +264,7 @@ func main() {
 	}
 
 	var worker, _ = embed.NewWorker(upstream, embed.WorkerConfig{
-		Concurrency: concurrency,
+		Concurrency: cfg.Concurrency,
 	})
 
 	// resets the query params to avoid downstream issues
@@ -269,26 +272,67 @@ func main() {
 
 	// register job handlers for types implemented by this worker
 	_ = worker.Register("repos/auto-import", repo.AutoImport(pool))
-	_ = worker.Register("container/sync", podman.ContainerSync(u.String(), &logger, db.New(pool)))
+	_ = worker.Register("container/sync", podman.ContainerSync(u.String(), &logger, db.New(pool), cfg.GitClonePath))
 
-	// TODO all of the following "params" should be configurable
-	// either via the database/app or possibly with env vars
-	schedulerInterval := 1
-	syncerInterval := 3
+	var sched = scheduler.New(&logger, pool)
+	go sched.Start(ctx, cfg.SchedulerInterval())
+	go timeout.New(&logger, pool).Start(ctx, time.Minute)
+	go retention.New(&logger, pool, cfg.RepoSyncQueueRetentionDays).Start(ctx, time.Hour)
+
+	// reconcile the database against the declarative sync configuration file (if any), on startup and whenever it changes
+	if cfg.ManifestPath != "" {
+		go manifest.New(&logger, pool, cfg.ManifestPath).Start(ctx, 30*time.Second)
+	}
 
-	if schedulerIntervalStr := os.Getenv("SCHEDULER_INTERVAL_MINUTES"); len(schedulerIntervalStr) != 0 {
-		if schedulerInterval, err = strconv.Atoi(schedulerIntervalStr); err != nil {
-			logger.Err(err).Msgf("Incorrect value for SCHEDULER_INTERVAL_MINUTES")
+	// sweep the scratch dirs left behind by jobs of a crashed worker, on startup and every hour
+	go scratch.New(&logger, cfg.ScratchMaxAge(), cfg.ScratchPath(), cfg.GitCloneCachePath).Start(ctx, time.Hour)
+
+	// if a worker-held key is configured, re-encrypt credentials added through the app with it
+	if keyring, err := envelope.FromEnv(); err != nil {
+		logger.Err(err).Msgf("Incorrect value for %s or %s", envelope.KeyEnv, envelope.KMSKeyEnv)
+	} else if keyring != nil {
+		go sealer.New(&logger, pool, keyring).Start(ctx, time.Minute)
+	}
+
+	// the rows written by syncs are also (or only) sent to each of the configured destinations
+	var destinations []destination.Destination
+
+	// if an event sink is configured, the rows written by syncs are published to it
+	var rowSink sink.Sink
+	if cfg.EventSink != "" {
+		if rowSink, err = sink.New(ctx, cfg.EventSink, cfg.EventSinkURL, cfg.EventSinkTopicPrefix); err != nil {
+			logger.Err(err).Msgf("could not connect to event sink: %v", err)
+			os.Exit(1)
+		}
+		destinations = append(destinations, sink.Destination(rowSink))
+	}
+
+	// if an export destination is configured, the rows written by syncs are exported to it
+	if cfg.ExportDestination != "" {
+		exporter, err := export.New(cfg.ExportDestination, cfg.ExportFormat)
+		if err != nil {
+			logger.Err(err).Msgf("could not configure export: %v", err)
+			os.Exit(1)
 		}
+		destinations = append(destinations, exporter)
 	}
-	if syncerIntervalStr := os.Getenv("SYNCER_INTERVAL_SECONDS"); len(syncerIntervalStr) != 0 {
-		if syncerInterval, err = strconv.Atoi(syncerIntervalStr); err != nil {
-			logger.Err(err).Msgf("Incorrect value for SYNCER_INTERVAL_SECONDS")
+
+	// if a ClickHouse server is configured, the rows written by syncs are inserted into it
+	if cfg.ClickHouseURL != "" {
+		ch, err := clickhouse.New(cfg.ClickHouseURL, cfg.ClickHouseDatabase, cfg.ClickHouseUser, os.Getenv("CLICKHOUSE_PASSWORD"))
+		if err != nil {
+			logger.Err(err).Msgf("could not configure ClickHouse: %v", err)
+			os.Exit(1)
 		}
+		destinations = append(destinations, ch)
 	}
-	go scheduler.New(&logger, pool).Start(ctx, time.Duration(schedulerInterval)*time.Minute)
-	go timeout.New(&logger, pool).Start(ctx, time.Minute)
-	go syncer.New(pool, embedded, &logger, concurrency, time.Duration(syncerInterval)*time.Second).Start(ctx)
+
+	// on shutdown, the syncer stops dequeuing and lets in-flight syncs finish (or requeues them) before we exit
+	var syncerDone = make(chan struct{})
+	go func() {
+		defer close(syncerDone)
+		syncer.New(pool, embedded, &logger, cfg, destinations...).Start(ctx, cfg.SyncerDrainTimeout())
+	}()
 
 	// run a basic cron every minute to schedule a repos/auto-import job
 	// these jobs are idempotent, and so, multiple instances can run at same time without conflict
@@ -297,15 +341,65 @@ func main() {
 	// run container sync scheduler every minute
 	go cron.ContainerSync(ctx, 1*time.Minute, upstream)
 
-	if os.Getenv("DEBUG") != "" {
-		go func() {
-			http.Handle("/metrics", promhttp.Handler())
-			if err := http.ListenAndServe(":8080", nil); err != nil {
-				logger.Err(err).Msgf("could not start HTTP handler")
-			}
-		}()
+	// the worker is alive as long as the scheduler keeps running (allowing for a slow run), and
+	// ready as long as it can also reach the database and has room to clone repos into
+	var schedulerAlive = health.Heartbeat(sched.LastRun, 2*cfg.SchedulerInterval()+5*time.Minute)
+
+	var mux = http.NewServeMux()
+	mux.Handle("/healthz", health.Handler(map[string]health.Check{"scheduler": schedulerAlive}))
+	mux.Handle("/readyz", health.Handler(map[string]health.Check{
+		"database":  health.Database(pool),
+		"scratch":   health.ScratchDisk(cfg.ScratchPath(), uint64(cfg.HealthMinScratchFreeMB)<<20),
+		"scheduler": schedulerAlive,
+	}))
+
+	// GitHub webhooks are received (to sync repos as soon as they change) only if a secret to verify them with is set
+	if secret := os.Getenv("GITHUB_WEBHOOK_SECRET"); secret != "" {
+		mux.Handle("/webhooks/github", webhook.GitHub(&logger, pool, []byte(secret)))
+	}
+
+	// logs streamed (by either endpoint below) are bounded together, each over a connection of its own
+	var logStreams = logstream.NewStreams(poolConfig.ConnConfig.Copy(), cfg.LogStreamMaxStreams)
+
+	// the logs of sync jobs are streamed (see package logstream), and the management API (see package api) is served,
+	// only if a token to authorize requests with is set. Both reach the repos of every tenant, so their tokens are
+	// administrators' tokens, which workers pinned to a tenant (e.g. deployed by the tenant) never accept.
+	var adminToken = func(name string) string {
+		var token = os.Getenv(name)
+		if token != "" && cfg.Tenant != "" {
+			logger.Warn().Str("tenant", cfg.Tenant).Msgf("%s is ignored, as the worker is pinned to a tenant and it would reach the repos of every tenant", name)
+			return ""
+		}
+		return token
+	}
+
+	if token := adminToken("LOG_STREAM_TOKEN"); token != "" {
+		mux.Handle("/logs/stream", logstream.Handler(&logger, logStreams, []byte(token)))
+	}
+
+	if token := adminToken("API_TOKEN"); token != "" {
+		mux.Handle(api.Prefix, api.Handler(&logger, pool, logStreams, []byte(token)))
+	}
+
+	// metrics are only served in debug mode, as is pprof (unless ENABLE_PPROF is set, e.g. to profile a production worker)
+	if cfg.Debug {
+		mux.Handle("/metrics", promhttp.Handler())
+	}
+
+	if cfg.Debug || cfg.EnablePprof {
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
 	}
 
+	go func() {
+		if err := http.ListenAndServe(":8080", mux); err != nil {
+			logger.Err(err).Msgf("could not start HTTP handler")
+		}
+	}()
+
 	// start the worker
 	if err = worker.Start(); err != nil {
 		logger.Fatal().Err(err).Msg("failed to start background worker")
@@ -317,4 +411,46 @@ func main() {
 	if err = worker.Shutdown(30 * time.Second); err != nil {
 		logger.Err(err).Msg("failed to terminate worker gracefully")
 	}
+
+	// wait for the syncer to drain, allowing canceled syncs a little extra time to be requeued
+	select {
+	case <-syncerDone:
+	case <-time.After(cfg.SyncerDrainTimeout() + 10*time.Second):
+		logger.Warn().Msg("failed to drain syncer gracefully")
+	}
+
+	if rowSink != nil {
+		if err = rowSink.Close(); err != nil {
+			logger.Err(err).Msgf("could not close event sink: %v", err)
+		}
+	}
+}
+
+// migrateSchema applies the migrations of sqlq and of mergestat, exiting if any of them fails
+func migrateSchema(logger *zerolog.Logger, upstream *sql.DB, connection string) {
+	// apply sqlq migrations
+	if err := schema.Apply(upstream); err != nil {
+		logger.Fatal().Err(err).Msg("failed to apply sqlq migrations")
+	}
+
+	m, err := migrate.New("file://migrations", connection)
+	if err != nil {
+		logger.Err(err).Msgf("could not initialize migrations")
+		os.Exit(1)
+	}
+
+	if err := m.Up(); err != nil {
+		if !errors.Is(err, migrate.ErrNoChange) {
+			logger.Err(err).Msgf("could not run migrations: %v", err)
+			os.Exit(1)
+		}
+	}
+
+	srcErr, dbErr := m.Close()
+	if srcErr != nil {
+		logger.Err(srcErr).Msgf("could not close migrations with source error: %v", srcErr)
+	}
+	if dbErr != nil {
+		logger.Err(dbErr).Msgf("could not close migrations with db error: %v", dbErr)
+	}
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"database/sql"
+
+	"github.com/mergestat/mergestat/pkg/syncplugin"
+	"github.com/rs/zerolog"
+)
+
+// Plugins providing sync types out-of-tree (see pkg/syncplugin) are compiled into the worker by importing them here
+// for their side effects (i.e. the registration of their sync types), e.g.
+//
+//	import _ "github.com/acme/mergestat-deploys"
+
+// applyPluginSchemas creates the tables of the sync types registered by plugins (with their Schema), exiting if any of
+// them fails. Like the migrations, it's run by the owner of the schema (the worker registers the sync types themselves
+// once it starts). The roles of mergestat are granted access to the tables of the public schema it creates, as default
+// privileges only apply to the tables created by the role that set them up.
+func applyPluginSchemas(logger *zerolog.Logger, upstream *sql.DB) {
+	var types = syncplugin.SyncTypes()
+	if len(types) == 0 {
+		return
+	}
+
+	tx, err := upstream.Begin()
+	if err != nil {
+		logger.Fatal().Err(err).Msg("failed to apply the schemas of plugin sync types")
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, t := range types {
+		if t.Schema == "" {
+			continue
+		}
+		if _, err = tx.Exec(t.Schema); err != nil {
+			logger.Fatal().Err(err).Msgf("failed to apply the schema of plugin sync type %s", t.Name)
+		}
+	}
+
+	const grants = `
+		GRANT SELECT ON ALL TABLES IN SCHEMA public TO mergestat_role_readonly, mergestat_role_user;
+		GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA public TO mergestat_role_admin WITH GRANT OPTION;
+		GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO mergestat_role_worker;
+		GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO mergestat_role_worker;`
+	if _, err = tx.Exec(grants); err != nil {
+		logger.Fatal().Err(err).Msg("failed to grant access to the tables of plugin sync types")
+	}
+
+	if err = tx.Commit(); err != nil {
+		logger.Fatal().Err(err).Msg("failed to apply the schemas of plugin sync types")
+	}
+}
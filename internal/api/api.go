@@ -0,0 +1,333 @@
+// Package api serves a small JSON API to manage MergeStat over HTTP, exposing repos, their syncs, the state of the sync
+// queue and the logs of sync jobs, along with mutations to add repos, (un)schedule, trigger and pause syncs, so that
+// frontends and automation don't need direct access to the database. Every request must be authorized with a bearer
+// token.
+//
+// The API isn't scoped to a tenant: its token manages the repos (and reads the jobs and logs) of every tenant, so it's
+// an administrator's token, which must never be handed to a tenant. Workers pinned to a tenant don't serve it.
+//
+//	GET  /api/repos                                  lists repos
+//	POST /api/repos                                  adds a repo, {"repo": url, "provider": id or name, "syncs": [type]}
+//	GET  /api/repos/{repo}/syncs                     lists the syncs of a repo, given by id or (url-encoded) url
+//	PUT  /api/repos/{repo}/syncs/{type}              (un)schedules a sync of a repo, {"enabled": bool}
+//	POST /api/repos/{repo}/syncs/{type}/trigger      enqueues a sync of a repo to run right away
+//	POST /api/repos/{repo}/pause                     pauses the syncs of a repo, {"reason": text}
+//	POST /api/repos/{repo}/resume                    resumes the syncs of a repo
+//	GET  /api/queue                                  counts the queued and running jobs, by sync type
+//	GET  /api/jobs?repo=&status=&limit=              lists the latest jobs
+//	GET  /api/jobs/{id}/logs?after=                  lists the logs of a job
+//	GET  /api/logs/stream?repo=&job=&lines=          streams logs as server-sent events (see package logstream)
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/logstream"
+	"github.com/rs/zerolog"
+)
+
+// Prefix is the path the API is served under
+const Prefix = "/api/"
+
+// maxBodyBytes bounds the size of the body of a request
+const maxBodyBytes = 1 << 20
+
+// the codes of the Postgres errors responded as client errors
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+// maxJobs bounds the number of jobs (and log lines) listed by a request
+const maxJobs = 1000
+
+type server struct {
+	logger *zerolog.Logger
+	db     *db.Queries
+	token  []byte
+	logs   http.Handler
+}
+
+// Handler returns the handler of the API (to be served under Prefix), authorizing requests with the given (administrator's)
+// bearer token.
+// Logs are streamed within the bound of the given streams.
+func Handler(logger *zerolog.Logger, pool *pgxpool.Pool, streams *logstream.Streams, token []byte) http.Handler {
+	return &server{logger: logger, db: db.New(pool), token: token, logs: logstream.Handler(logger, streams, token)}
+}
+
+// apiError is an error responded to a request, with a status of its own
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string { return e.message }
+
+func badRequest(message string) error {
+	return &apiError{status: http.StatusBadRequest, message: message}
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), s.token) != 1 {
+		s.respond(w, nil, &apiError{status: http.StatusUnauthorized, message: "unauthorized"})
+		return
+	}
+
+	// the path is split on its escaped form, so that repo urls (escaped) are kept as a single segment
+	var path = strings.Split(strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), Prefix), "/"), "/")
+	for i := range path {
+		path[i], _ = url.PathUnescape(path[i])
+	}
+
+	var ctx, write = r.Context(), func(result interface{}, err error) { s.respond(w, result, err) }
+	switch {
+	case r.Method == http.MethodGet && match(path, "logs", "stream"):
+		s.logs.ServeHTTP(w, r)
+	case r.Method == http.MethodGet && match(path, "repos"):
+		write(s.db.ListRepos(ctx))
+	case r.Method == http.MethodPost && match(path, "repos"):
+		write(s.addRepo(r))
+	case r.Method == http.MethodGet && match(path, "repos", "*", "syncs"):
+		write(s.listRepoSyncs(r, path[1]))
+	case r.Method == http.MethodPut && match(path, "repos", "*", "syncs", "*"):
+		write(s.setRepoSyncEnabled(r, path[1], path[3]))
+	case r.Method == http.MethodPost && match(path, "repos", "*", "syncs", "*", "trigger"):
+		write(s.triggerRepoSync(r, path[1], path[3]))
+	case r.Method == http.MethodPost && match(path, "repos", "*", "pause"):
+		write(s.pauseRepo(r, path[1]))
+	case r.Method == http.MethodPost && match(path, "repos", "*", "resume"):
+		write(s.resumeRepo(r, path[1]))
+	case r.Method == http.MethodGet && match(path, "queue"):
+		write(s.db.QueueStats(ctx))
+	case r.Method == http.MethodGet && match(path, "jobs"):
+		write(s.listJobs(r))
+	case r.Method == http.MethodGet && match(path, "jobs", "*", "logs"):
+		write(s.listJobLogs(r, path[1]))
+	default:
+		write(nil, &apiError{status: http.StatusNotFound, message: "not found"})
+	}
+}
+
+// match returns whether the given path matches the given pattern, whose * segments match any (non-empty) segment
+func match(path []string, pattern ...string) bool {
+	if len(path) != len(pattern) {
+		return false
+	}
+	for i, segment := range pattern {
+		if (segment == "*" && path[i] == "") || (segment != "*" && segment != path[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// respond responds with the given result as JSON, or with the given error if it's not nil
+func (s *server) respond(w http.ResponseWriter, result interface{}, err error) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if err != nil {
+		var status = http.StatusInternalServerError
+		var message = "internal error"
+
+		var e *apiError
+		var pgErr *pgconn.PgError
+		switch {
+		case errors.As(err, &e):
+			status, message = e.status, e.message
+		case errors.Is(err, db.ErrNotFound):
+			status, message = http.StatusNotFound, err.Error()
+		case errors.As(err, &pgErr) && pgErr.Code == uniqueViolation:
+			status, message = http.StatusConflict, "already exists"
+		case errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation:
+			status, message = http.StatusBadRequest, pgErr.Detail // e.g. an unknown sync type
+		default:
+			s.logger.Err(err).Msgf("error serving api request: %v", err)
+		}
+
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// decode decodes the JSON body of the given request into v
+func decode(r *http.Request, v interface{}) error {
+	var dec = json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return badRequest("invalid body: " + err.Error())
+	}
+	return nil
+}
+
+func (s *server) addRepo(r *http.Request) (interface{}, error) {
+	var body struct {
+		Repo     string   `json:"repo"`
+		Provider string   `json:"provider"`
+		Syncs    []string `json:"syncs"`
+	}
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	if body.Repo == "" {
+		return nil, badRequest("repo must be set")
+	}
+
+	provider, err := s.db.FindProvider(r.Context(), body.Provider)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := s.db.AddRepo(r.Context(), body.Repo, provider.ID, body.Syncs)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"id": id, "repo": body.Repo, "provider": provider}, nil
+}
+
+func (s *server) listRepoSyncs(r *http.Request, repo string) (interface{}, error) {
+	id, err := s.db.FindRepo(r.Context(), repo)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.ListRepoSyncs(r.Context(), id)
+}
+
+func (s *server) setRepoSyncEnabled(r *http.Request, repo, syncType string) (interface{}, error) {
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	if body.Enabled == nil {
+		return nil, badRequest("enabled must be set")
+	}
+
+	id, err := s.db.FindRepo(r.Context(), repo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.db.SetRepoSyncEnabled(r.Context(), id, strings.ToUpper(syncType), *body.Enabled); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"sync_type": strings.ToUpper(syncType), "enabled": *body.Enabled}, nil
+}
+
+func (s *server) triggerRepoSync(r *http.Request, repo, syncType string) (interface{}, error) {
+	id, err := s.db.FindRepo(r.Context(), repo)
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := s.db.SyncRepoNow(r.Context(), id, strings.ToUpper(syncType))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]int64{"job_id": job}, nil
+}
+
+func (s *server) pauseRepo(r *http.Request, repo string) (interface{}, error) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := decode(r, &body); err != nil {
+			return nil, err
+		}
+	}
+
+	id, err := s.db.FindRepo(r.Context(), repo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.db.PauseRepoSyncs(r.Context(), id, body.Reason); err != nil {
+		return nil, err
+	}
+	return map[string]bool{"paused": true}, nil
+}
+
+func (s *server) resumeRepo(r *http.Request, repo string) (interface{}, error) {
+	id, err := s.db.FindRepo(r.Context(), repo)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.db.ResumeRepoSyncs(r.Context(), id); err != nil {
+		return nil, err
+	}
+	return map[string]bool{"paused": false}, nil
+}
+
+func (s *server) listJobs(r *http.Request) (interface{}, error) {
+	var query = r.URL.Query()
+	var filter = db.SyncJobFilter{Status: strings.ToUpper(query.Get("status"))}
+
+	if repo := query.Get("repo"); repo != "" {
+		id, err := s.db.FindRepo(r.Context(), repo)
+		if err != nil {
+			return nil, err
+		}
+		filter.Repo = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	limit, err := limitOf(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.ListSyncJobs(r.Context(), filter, limit)
+}
+
+func (s *server) listJobLogs(r *http.Request, job string) (interface{}, error) {
+	var filter db.SyncLogFilter
+	var err error
+	if filter.Job, err = strconv.ParseInt(job, 10, 64); err != nil {
+		return nil, badRequest("invalid job")
+	}
+
+	var query = r.URL.Query()
+	var after int64
+	if a := query.Get("after"); a != "" {
+		if after, err = strconv.ParseInt(a, 10, 64); err != nil {
+			return nil, badRequest("invalid after")
+		}
+	}
+
+	limit, err := limitOf(query)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.ListSyncLogsAfter(r.Context(), after, filter, limit)
+}
+
+// limitOf returns the limit of the number of items listed, set with ?limit= (maxJobs by default, and at most)
+func limitOf(query url.Values) (int, error) {
+	var l = query.Get("limit")
+	if l == "" {
+		return maxJobs, nil
+	}
+
+	limit, err := strconv.Atoi(l)
+	if err != nil || limit <= 0 {
+		return 0, badRequest("invalid limit")
+	}
+	if limit > maxJobs {
+		limit = maxJobs
+	}
+	return limit, nil
+}
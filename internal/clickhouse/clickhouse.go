@@ -0,0 +1,135 @@
+// Package clickhouse sends the rows written by syncs to ClickHouse, a columnar store that's a much better fit than
+// Postgres for querying e.g. the commits or the blame of all the repos of an org. Rows are inserted through the HTTP
+// interface of ClickHouse into a table of the same name (in the configured database), which must be created beforehand
+// with the columns of the Postgres table and a repo_id column of type UUID. The rows of a repo are replaced by each sync.
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mergestat/mergestat/internal/destination"
+	"github.com/pkg/errors"
+)
+
+// maxErrorBytes bounds how much of the body of a failed response is included in the error
+const maxErrorBytes = 1 << 10
+
+// ClickHouse is the destination inserting the rows sent to it into ClickHouse
+type ClickHouse struct {
+	url      string
+	database string
+	user     string
+	password string
+}
+
+// New returns a destination inserting rows into the given database of the ClickHouse server with the given HTTP
+// interface url (e.g. http://localhost:8123), as the given user
+func New(rawURL, database, user, password string) (*ClickHouse, error) {
+	if u, err := url.Parse(rawURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.Errorf("invalid ClickHouse url: %s", rawURL)
+	}
+	return &ClickHouse{url: strings.TrimRight(rawURL, "/"), database: database, user: user, password: password}, nil
+}
+
+// Name implements destination.Destination
+func (ch *ClickHouse) Name() string { return "ClickHouse" }
+
+// Send replaces the rows of the repo in the table of the given batch with the rows of the batch. The rows are deleted
+// with a lightweight DELETE (of ClickHouse 23.3 or later), so queries may briefly see neither the old nor the new rows.
+func (ch *ClickHouse) Send(ctx context.Context, batch *destination.Batch) error {
+	var table = quote(batch.Table)
+
+	var params = url.Values{"param_repo_id": {batch.RepoID.String()}}
+	if err := ch.exec(ctx, "DELETE FROM "+table+" WHERE repo_id = {repo_id:UUID}", params, nil); err != nil {
+		return errors.Wrapf(err, "failed to delete rows of %s", batch.Table)
+	}
+
+	if len(batch.Rows) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	var enc = json.NewEncoder(&body)
+	for _, values := range batch.Rows {
+		var row = make(map[string]interface{}, len(batch.Columns))
+		for i, column := range batch.Columns {
+			row[column] = value(values[i])
+		}
+		if err := enc.Encode(row); err != nil {
+			return errors.Wrapf(err, "failed to encode row of %s", batch.Table)
+		}
+	}
+
+	var columns = make([]string, len(batch.Columns))
+	for i, column := range batch.Columns {
+		columns[i] = quote(column)
+	}
+
+	// timestamps are encoded as RFC 3339, which ClickHouse only parses with best effort parsing of dates
+	var query = fmt.Sprintf("INSERT INTO %s (%s) FORMAT JSONEachRow", table, strings.Join(columns, ", "))
+	params = url.Values{"date_time_input_format": {"best_effort"}}
+	if err := ch.exec(ctx, query, params, &body); err != nil {
+		return errors.Wrapf(err, "failed to insert rows of %s", batch.Table)
+	}
+
+	return nil
+}
+
+// exec runs the given query, with the given settings (and query parameters), and data (if any) as its input
+func (ch *ClickHouse) exec(ctx context.Context, query string, params url.Values, data io.Reader) error {
+	params.Set("database", ch.database)
+
+	var body io.Reader = strings.NewReader(query)
+	if data != nil {
+		// with data to insert, the query is sent in the url and the data in the body
+		params.Set("query", query)
+		body = data
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ch.url+"/?"+params.Encode(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-ClickHouse-User", ch.user)
+	if ch.password != "" {
+		req.Header.Set("X-ClickHouse-Key", ch.password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+		return errors.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	return nil
+}
+
+// value returns the given (copied) value as it's encoded in a JSONEachRow row
+func value(v interface{}) interface{} {
+	switch v := destination.Normalize(v).(type) {
+	case []byte:
+		return string(v) // String columns of ClickHouse hold bytes, rather than base64 (as encoded in JSON by default)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
+
+// quote quotes the given identifier, e.g. a table or a column name
+func quote(ident string) string {
+	return "`" + strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(ident) + "`"
+}
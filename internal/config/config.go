@@ -0,0 +1,318 @@
+// Package config holds the configuration of the worker, parsed once at startup. Every setting has a default, which can be
+// overridden in an (optional) YAML file, set with CONFIG_FILE, and in turn by an environment variable of the same setting.
+// Secrets (e.g. ENCRYPTION_SECRET or GITHUB_TOKEN) and the variables read by third-party SDKs (e.g. OTEL_*, AWS_* or
+// VAULT_*) are deliberately left out, and are only ever read from the environment.
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// FileEnv is the environment variable with the path of the (optional) YAML config file
+const FileEnv = "CONFIG_FILE"
+
+// Config is the configuration of the worker. The environment variable overriding each setting is noted next to it.
+type Config struct {
+	PostgresConnection string `yaml:"postgres_connection"` // POSTGRES_CONNECTION
+	Concurrency        int    `yaml:"concurrency"`         // CONCURRENCY, the number of sync jobs (and background jobs) run at a time
+	Tenant             string `yaml:"tenant"`              // TENANT, to pin the worker to a tenant, only running the sync jobs of its repos
+	SkipMigrations     bool   `yaml:"skip_migrations"`     // SKIP_MIGRATIONS, for workers connecting as a least-privilege user (see mergestat.create_worker_user)
+	ManifestPath       string `yaml:"manifest_path"`       // MANIFEST_PATH, a declarative sync configuration file to reconcile the database against (see package manifest)
+
+	StandaloneRepo      string `yaml:"standalone_repo"`       // STANDALONE_REPO, a local path or a remote url of a repo to sync once into a SQLite file, without Postgres (see package standalone)
+	StandaloneOutput    string `yaml:"standalone_output"`     // STANDALONE_OUTPUT, the SQLite file written in standalone mode
+	StandaloneSyncTypes string `yaml:"standalone_sync_types"` // STANDALONE_SYNC_TYPES, the comma-separated sync types run in standalone mode
+
+	LogLevel    string `yaml:"log_level"`    // LOG_LEVEL, one of debug, info, warn or error
+	PrettyLogs  bool   `yaml:"pretty_logs"`  // PRETTY_LOGS, to use a human-friendly log format even if stdout isn't a terminal
+	Debug       bool   `yaml:"debug"`        // DEBUG, to serve /metrics and pprof
+	EnablePprof bool   `yaml:"enable_pprof"` // ENABLE_PPROF, to serve pprof without DEBUG
+	DryRun      bool   `yaml:"dry_run"`      // DRY_RUN, to run all syncs without writing anything (see also the dryRun sync setting)
+
+	SchedulerIntervalMinutes  int `yaml:"scheduler_interval_minutes"`   // SCHEDULER_INTERVAL_MINUTES
+	SyncerIntervalSeconds     int `yaml:"syncer_interval_seconds"`      // SYNCER_INTERVAL_SECONDS
+	SyncerDrainTimeoutSeconds int `yaml:"syncer_drain_timeout_seconds"` // SYNCER_DRAIN_TIMEOUT_SECONDS
+
+	GitClonePath          string `yaml:"git_clone_path"`           // GIT_CLONE_PATH, where repos are cloned to (the OS temp dir if empty)
+	GitCloneCachePath     string `yaml:"git_clone_cache_path"`     // GIT_CLONE_CACHE_PATH, enables the clone cache if set
+	GitCloneCacheEviction bool   `yaml:"git_clone_cache_eviction"` // GIT_CLONE_CACHE_EVICTION, to evict clones of the cache when out of disk space
+	GitCloneMinFreeMB     int    `yaml:"git_clone_min_free_mb"`    // GIT_CLONE_MIN_FREE_MB, the disk space left free after a clone
+
+	CopyBatchSize  int `yaml:"copy_batch_size"`  // COPY_BATCH_SIZE, the max number of rows sent per COPY (0 means no limit)
+	CopyBatchBytes int `yaml:"copy_batch_bytes"` // COPY_BATCH_BYTES, the max number of bytes sent per COPY (0 means no limit)
+
+	GitHubPerPage              int    `yaml:"github_per_page"`                // GITHUB_PER_PAGE
+	GitHubRateLimit            string `yaml:"github_rate_limit"`              // GITHUB_RATE_LIMIT, passed on to mergestat-lite as is
+	GitHubWorkflowPerPage      int    `yaml:"github_workflow_per_page"`       // GITHUB_WORKFLOW_PER_PAGE
+	GitHubWorkflowRunsPerPage  int    `yaml:"github_workflow_runs_per_page"`  // GITHUB_WORKFLOW_RUNS_PER_PAGE
+	GitHubWorkflowJobsPerPage  int    `yaml:"github_workflow_jobs_per_page"`  // GITHUB_WORKFLOW_JOBS_PER_PAGE
+	GitWorkflowLogsPath        string `yaml:"git_workflow_logs_path"`         // GIT_WORKFLOW_LOGS_PATH (the OS temp dir if empty)
+	RepoSyncQueueRetentionDays int    `yaml:"repo_sync_queue_retention_days"` // REPO_SYNC_QUEUE_RETENTION_DAYS, 0 or less to skip pruning
+	ScratchMaxAgeHours         int    `yaml:"scratch_max_age_hours"`          // SCRATCH_MAX_AGE_HOURS
+	HealthMinScratchFreeMB     int    `yaml:"health_min_scratch_free_mb"`     // HEALTH_MIN_SCRATCH_FREE_MB
+	RepoUnreachableThreshold   int    `yaml:"repo_unreachable_threshold"`     // REPO_UNREACHABLE_THRESHOLD, 0 to never quarantine unreachable repos
+	SyncEventsWebhookURL       string `yaml:"sync_events_webhook_url"`        // SYNC_EVENTS_WEBHOOK_URL, to post an event to whenever a sync completes
+	LogStreamMaxStreams        int    `yaml:"log_stream_max_streams"`         // LOG_STREAM_MAX_STREAMS, the number of logs streamed at a time (each over a connection of its own)
+
+	EventSink            string `yaml:"event_sink"`              // EVENT_SINK, kafka or nats, to also publish the rows written by syncs to (see package sink)
+	EventSinkURL         string `yaml:"event_sink_url"`          // EVENT_SINK_URL, the comma-separated Kafka brokers, or the NATS server url(s)
+	EventSinkTopicPrefix string `yaml:"event_sink_topic_prefix"` // EVENT_SINK_TOPIC_PREFIX, rows are published to a topic per table, named <prefix>.<table>
+
+	ExportDestination string `yaml:"export_destination"` // EXPORT_DESTINATION, a local dir or an s3://bucket/prefix url to also export the rows written by syncs to (see package export)
+	ExportFormat      string `yaml:"export_format"`      // EXPORT_FORMAT, parquet or csv
+
+	ClickHouseURL      string `yaml:"clickhouse_url"`      // CLICKHOUSE_URL, the url of the HTTP interface of a ClickHouse server to also insert the rows written by syncs into (see package clickhouse)
+	ClickHouseDatabase string `yaml:"clickhouse_database"` // CLICKHOUSE_DATABASE
+	ClickHouseUser     string `yaml:"clickhouse_user"`     // CLICKHOUSE_USER, whose password is set with CLICKHOUSE_PASSWORD (only)
+
+	DestinationsOnly bool `yaml:"destinations_only"` // DESTINATIONS_ONLY, to only send the rows written by syncs to the event sink, export destination or ClickHouse, not writing them to postgres
+}
+
+// Default returns the configuration used for any setting that isn't set otherwise
+func Default() *Config {
+	return &Config{
+		Concurrency:                1,
+		LogLevel:                   "info",
+		SchedulerIntervalMinutes:   1,
+		SyncerIntervalSeconds:      3,
+		SyncerDrainTimeoutSeconds:  30,
+		GitCloneMinFreeMB:          512,
+		GitHubPerPage:              50, // match the default used by mergestat-lite
+		GitHubWorkflowPerPage:      30,
+		GitHubWorkflowRunsPerPage:  30,
+		GitHubWorkflowJobsPerPage:  30,
+		RepoSyncQueueRetentionDays: 30,
+		ScratchMaxAgeHours:         24,
+		HealthMinScratchFreeMB:     512,
+		RepoUnreachableThreshold:   3,
+		LogStreamMaxStreams:        10,
+		EventSinkTopicPrefix:       "mergestat",
+		ExportFormat:               "parquet",
+		ClickHouseDatabase:         "default",
+		ClickHouseUser:             "default",
+		StandaloneOutput:           "mergestat.db",
+		StandaloneSyncTypes:        "GIT_COMMITS,GIT_REFS,GIT_FILES",
+	}
+}
+
+// Load returns the configuration of the worker, applying the YAML file set with CONFIG_FILE (if any),
+// and then the environment, on top of the defaults. It returns an error if any setting is invalid.
+func Load() (_ *Config, err error) {
+	var cfg = Default()
+
+	if path := os.Getenv(FileEnv); path != "" {
+		var contents []byte
+		if contents, err = os.ReadFile(path); err != nil {
+			return nil, errors.Wrapf(err, "failed to read config file")
+		}
+
+		// strict, so that a misspelled setting isn't silently ignored
+		if err = yaml.UnmarshalStrict(contents, cfg); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse config file %s", path)
+		}
+	}
+
+	var env = &envParser{}
+	env.str(&cfg.PostgresConnection, "POSTGRES_CONNECTION")
+	env.int(&cfg.Concurrency, "CONCURRENCY")
+	env.str(&cfg.Tenant, "TENANT")
+	env.bool(&cfg.SkipMigrations, "SKIP_MIGRATIONS")
+	env.str(&cfg.ManifestPath, "MANIFEST_PATH")
+	env.str(&cfg.LogLevel, "LOG_LEVEL")
+	env.bool(&cfg.PrettyLogs, "PRETTY_LOGS")
+	env.bool(&cfg.Debug, "DEBUG")
+	env.bool(&cfg.EnablePprof, "ENABLE_PPROF")
+	env.bool(&cfg.DryRun, "DRY_RUN")
+	env.int(&cfg.SchedulerIntervalMinutes, "SCHEDULER_INTERVAL_MINUTES")
+	env.int(&cfg.SyncerIntervalSeconds, "SYNCER_INTERVAL_SECONDS")
+	env.int(&cfg.SyncerDrainTimeoutSeconds, "SYNCER_DRAIN_TIMEOUT_SECONDS")
+	env.str(&cfg.GitClonePath, "GIT_CLONE_PATH")
+	env.str(&cfg.GitCloneCachePath, "GIT_CLONE_CACHE_PATH")
+	env.bool(&cfg.GitCloneCacheEviction, "GIT_CLONE_CACHE_EVICTION")
+	env.int(&cfg.GitCloneMinFreeMB, "GIT_CLONE_MIN_FREE_MB")
+	env.int(&cfg.CopyBatchSize, "COPY_BATCH_SIZE")
+	env.int(&cfg.CopyBatchBytes, "COPY_BATCH_BYTES")
+	env.int(&cfg.GitHubPerPage, "GITHUB_PER_PAGE")
+	env.str(&cfg.GitHubRateLimit, "GITHUB_RATE_LIMIT")
+	env.int(&cfg.GitHubWorkflowPerPage, "GITHUB_WORKFLOW_PER_PAGE")
+	env.int(&cfg.GitHubWorkflowRunsPerPage, "GITHUB_WORKFLOW_RUNS_PER_PAGE")
+	env.int(&cfg.GitHubWorkflowJobsPerPage, "GITHUB_WORKFLOW_JOBS_PER_PAGE")
+	env.str(&cfg.GitWorkflowLogsPath, "GIT_WORKFLOW_LOGS_PATH")
+	env.int(&cfg.RepoSyncQueueRetentionDays, "REPO_SYNC_QUEUE_RETENTION_DAYS")
+	env.int(&cfg.ScratchMaxAgeHours, "SCRATCH_MAX_AGE_HOURS")
+	env.int(&cfg.HealthMinScratchFreeMB, "HEALTH_MIN_SCRATCH_FREE_MB")
+	env.int(&cfg.RepoUnreachableThreshold, "REPO_UNREACHABLE_THRESHOLD")
+	env.str(&cfg.SyncEventsWebhookURL, "SYNC_EVENTS_WEBHOOK_URL")
+	env.int(&cfg.LogStreamMaxStreams, "LOG_STREAM_MAX_STREAMS")
+	env.str(&cfg.EventSink, "EVENT_SINK")
+	env.str(&cfg.EventSinkURL, "EVENT_SINK_URL")
+	env.str(&cfg.EventSinkTopicPrefix, "EVENT_SINK_TOPIC_PREFIX")
+	env.str(&cfg.ExportDestination, "EXPORT_DESTINATION")
+	env.str(&cfg.ExportFormat, "EXPORT_FORMAT")
+	env.str(&cfg.ClickHouseURL, "CLICKHOUSE_URL")
+	env.str(&cfg.ClickHouseDatabase, "CLICKHOUSE_DATABASE")
+	env.str(&cfg.ClickHouseUser, "CLICKHOUSE_USER")
+	env.bool(&cfg.DestinationsOnly, "DESTINATIONS_ONLY")
+	env.str(&cfg.StandaloneRepo, "STANDALONE_REPO")
+	env.str(&cfg.StandaloneOutput, "STANDALONE_OUTPUT")
+	env.str(&cfg.StandaloneSyncTypes, "STANDALONE_SYNC_TYPES")
+
+	if len(env.errs) > 0 {
+		return nil, fmt.Errorf("invalid environment: %s", strings.Join(env.errs, "; "))
+	}
+
+	if err = cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Validate returns an error listing every invalid setting of the configuration, if any
+func (c *Config) Validate() error {
+	var problems []string
+	var check = func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.PostgresConnection != "" || c.StandaloneRepo != "", "postgres_connection (POSTGRES_CONNECTION) must be set")
+	check(c.Concurrency > 0, "concurrency (CONCURRENCY) must be positive, got %d", c.Concurrency)
+	check(isFile(c.ManifestPath), "manifest_path (MANIFEST_PATH) must be an existing file, got %q", c.ManifestPath)
+	check(c.LogLevel == "debug" || c.LogLevel == "info" || c.LogLevel == "warn" || c.LogLevel == "error",
+		"log_level (LOG_LEVEL) must be one of debug, info, warn or error, got %q", c.LogLevel)
+	check(c.SchedulerIntervalMinutes > 0, "scheduler_interval_minutes (SCHEDULER_INTERVAL_MINUTES) must be positive, got %d", c.SchedulerIntervalMinutes)
+	check(c.SyncerIntervalSeconds > 0, "syncer_interval_seconds (SYNCER_INTERVAL_SECONDS) must be positive, got %d", c.SyncerIntervalSeconds)
+	check(c.SyncerDrainTimeoutSeconds >= 0, "syncer_drain_timeout_seconds (SYNCER_DRAIN_TIMEOUT_SECONDS) must not be negative, got %d", c.SyncerDrainTimeoutSeconds)
+	check(isDir(c.GitClonePath), "git_clone_path (GIT_CLONE_PATH) must be an existing directory, got %q", c.GitClonePath)
+	check(c.GitCloneMinFreeMB >= 0, "git_clone_min_free_mb (GIT_CLONE_MIN_FREE_MB) must not be negative, got %d", c.GitCloneMinFreeMB)
+	check(c.CopyBatchSize >= 0, "copy_batch_size (COPY_BATCH_SIZE) must not be negative, got %d", c.CopyBatchSize)
+	check(c.CopyBatchBytes >= 0, "copy_batch_bytes (COPY_BATCH_BYTES) must not be negative, got %d", c.CopyBatchBytes)
+	check(c.GitHubPerPage > 0 && c.GitHubPerPage <= 100, "github_per_page (GITHUB_PER_PAGE) must be between 1 and 100, got %d", c.GitHubPerPage)
+	check(c.GitHubWorkflowPerPage > 0 && c.GitHubWorkflowPerPage <= 100, "github_workflow_per_page (GITHUB_WORKFLOW_PER_PAGE) must be between 1 and 100, got %d", c.GitHubWorkflowPerPage)
+	check(c.GitHubWorkflowRunsPerPage > 0 && c.GitHubWorkflowRunsPerPage <= 100, "github_workflow_runs_per_page (GITHUB_WORKFLOW_RUNS_PER_PAGE) must be between 1 and 100, got %d", c.GitHubWorkflowRunsPerPage)
+	check(c.GitHubWorkflowJobsPerPage > 0 && c.GitHubWorkflowJobsPerPage <= 100, "github_workflow_jobs_per_page (GITHUB_WORKFLOW_JOBS_PER_PAGE) must be between 1 and 100, got %d", c.GitHubWorkflowJobsPerPage)
+	check(isDir(c.GitWorkflowLogsPath), "git_workflow_logs_path (GIT_WORKFLOW_LOGS_PATH) must be an existing directory, got %q", c.GitWorkflowLogsPath)
+	check(c.ScratchMaxAgeHours > 0, "scratch_max_age_hours (SCRATCH_MAX_AGE_HOURS) must be positive, got %d", c.ScratchMaxAgeHours)
+	check(c.HealthMinScratchFreeMB >= 0, "health_min_scratch_free_mb (HEALTH_MIN_SCRATCH_FREE_MB) must not be negative, got %d", c.HealthMinScratchFreeMB)
+	check(c.RepoUnreachableThreshold >= 0, "repo_unreachable_threshold (REPO_UNREACHABLE_THRESHOLD) must not be negative, got %d", c.RepoUnreachableThreshold)
+	check(c.LogStreamMaxStreams > 0, "log_stream_max_streams (LOG_STREAM_MAX_STREAMS) must be positive, got %d", c.LogStreamMaxStreams)
+	check(isHTTPURL(c.SyncEventsWebhookURL), "sync_events_webhook_url (SYNC_EVENTS_WEBHOOK_URL) must be an http(s) url, got %q", c.SyncEventsWebhookURL)
+	check(c.EventSink == "" || c.EventSink == "kafka" || c.EventSink == "nats", "event_sink (EVENT_SINK) must be one of kafka or nats, got %q", c.EventSink)
+	check(c.EventSink == "" || c.EventSinkURL != "", "event_sink_url (EVENT_SINK_URL) must be set if event_sink (EVENT_SINK) is")
+	check(c.EventSinkTopicPrefix != "", "event_sink_topic_prefix (EVENT_SINK_TOPIC_PREFIX) must be set")
+	check(strings.HasPrefix(c.ExportDestination, "s3://") || isDir(c.ExportDestination), "export_destination (EXPORT_DESTINATION) must be an existing directory or an s3:// url, got %q", c.ExportDestination)
+	check(c.ExportFormat == "parquet" || c.ExportFormat == "csv", "export_format (EXPORT_FORMAT) must be one of parquet or csv, got %q", c.ExportFormat)
+	check(isHTTPURL(c.ClickHouseURL), "clickhouse_url (CLICKHOUSE_URL) must be an http(s) url, got %q", c.ClickHouseURL)
+	check(c.ClickHouseDatabase != "", "clickhouse_database (CLICKHOUSE_DATABASE) must be set")
+	check(c.ClickHouseUser != "", "clickhouse_user (CLICKHOUSE_USER) must be set")
+	check(!c.DestinationsOnly || c.EventSink != "" || c.ExportDestination != "" || c.ClickHouseURL != "",
+		"one of event_sink (EVENT_SINK), export_destination (EXPORT_DESTINATION) or clickhouse_url (CLICKHOUSE_URL) must be set if destinations_only (DESTINATIONS_ONLY) is")
+	check(c.StandaloneOutput != "", "standalone_output (STANDALONE_OUTPUT) must be set")
+	check(len(c.StandaloneSyncTypesList()) > 0, "standalone_sync_types (STANDALONE_SYNC_TYPES) must list at least one sync type")
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid config: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// StandaloneSyncTypesList returns the sync types run in standalone mode, i.e. StandaloneSyncTypes split on commas
+func (c *Config) StandaloneSyncTypesList() []string {
+	var syncTypes []string
+	for _, syncType := range strings.Split(c.StandaloneSyncTypes, ",") {
+		if syncType = strings.TrimSpace(syncType); syncType != "" {
+			syncTypes = append(syncTypes, strings.ToUpper(syncType))
+		}
+	}
+	return syncTypes
+}
+
+// ScratchPath returns the directory repos are cloned to, i.e. GitClonePath or the OS temp dir if unset
+func (c *Config) ScratchPath() string {
+	if c.GitClonePath != "" {
+		return c.GitClonePath
+	}
+	return os.TempDir()
+}
+
+func (c *Config) SchedulerInterval() time.Duration {
+	return time.Duration(c.SchedulerIntervalMinutes) * time.Minute
+}
+
+func (c *Config) SyncerInterval() time.Duration {
+	return time.Duration(c.SyncerIntervalSeconds) * time.Second
+}
+
+func (c *Config) SyncerDrainTimeout() time.Duration {
+	return time.Duration(c.SyncerDrainTimeoutSeconds) * time.Second
+}
+
+func (c *Config) ScratchMaxAge() time.Duration {
+	return time.Duration(c.ScratchMaxAgeHours) * time.Hour
+}
+
+// isDir returns whether the given (optional) path is empty, or an existing directory
+func isDir(path string) bool {
+	if path == "" {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// isFile returns whether the given (optional) path is empty, or an existing file
+func isFile(path string) bool {
+	if path == "" {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// isHTTPURL returns whether the given (optional) url is empty, or an absolute http(s) url
+func isHTTPURL(raw string) bool {
+	if raw == "" {
+		return true
+	}
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// envParser overrides settings with the environment variables that are set, collecting the ones that can't be parsed
+type envParser struct{ errs []string }
+
+func (p *envParser) str(dest *string, name string) {
+	if v := os.Getenv(name); v != "" {
+		*dest = v
+	}
+}
+
+func (p *envParser) int(dest *int, name string) {
+	if v := os.Getenv(name); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			p.errs = append(p.errs, fmt.Sprintf("%s must be an integer, got %q", name, v))
+			return
+		}
+		*dest = n
+	}
+}
+
+// bool sets dest to whether the variable is set to anything but a false value (e.g. 0 or false), so that flags
+// that were previously only checked for being set (e.g. DEBUG=yes) keep working
+func (p *envParser) bool(dest *bool, name string) {
+	if v := os.Getenv(name); v != "" {
+		on, err := strconv.ParseBool(v)
+		*dest = err != nil || on
+	}
+}
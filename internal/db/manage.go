@@ -0,0 +1,400 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/pkg/errors"
+)
+
+// ErrNotFound is returned when a repo or provider referenced by the user (e.g. on the command line) doesn't exist
+var ErrNotFound = errors.New("not found")
+
+// Provider is a provider (e.g. GitHub) that repos and credentials belong to
+type Provider struct {
+	ID     uuid.UUID `json:"id"`
+	Name   string    `json:"name"`
+	Vendor string    `json:"vendor"`
+}
+
+// RepoSummary summarizes a repo, as listed by the mergestat CLI
+type RepoSummary struct {
+	ID        uuid.UUID `json:"id"`
+	Repo      string    `json:"repo"`
+	Provider  string    `json:"provider"`
+	Tenant    string    `json:"tenant"`
+	Syncs     []string  `json:"syncs"` // the sync types scheduled for the repo
+	Paused    bool      `json:"paused"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RepoSync is a sync configured for a repo, along with the status of its latest job
+type RepoSync struct {
+	ID         uuid.UUID       `json:"id"`
+	SyncType   string          `json:"sync_type"`
+	Enabled    bool            `json:"enabled"` // whether the sync is scheduled
+	Schedule   *string         `json:"schedule"`
+	Priority   int32           `json:"priority"`
+	Settings   json.RawMessage `json:"settings"`
+	LastJobID  *int64          `json:"last_job_id"`
+	LastStatus *string         `json:"last_status"`
+	LastDoneAt *time.Time      `json:"last_done_at"`
+}
+
+// SyncJob is a job of the sync queue
+type SyncJob struct {
+	ID        int64      `json:"id"`
+	RepoID    uuid.UUID  `json:"repo_id"`
+	Repo      string     `json:"repo"`
+	SyncType  string     `json:"sync_type"`
+	Status    string     `json:"status"`
+	Attempts  int32      `json:"attempts"`
+	LastError *string    `json:"last_error"`
+	CreatedAt time.Time  `json:"created_at"`
+	StartedAt *time.Time `json:"started_at"`
+	DoneAt    *time.Time `json:"done_at"`
+}
+
+// SyncJobFilter selects the sync jobs listed, by repo and / or status
+type SyncJobFilter struct {
+	Repo   uuid.NullUUID
+	Status string // empty for jobs of any status
+}
+
+// QueueStat is the number of jobs of a sync type with a given status
+type QueueStat struct {
+	SyncType string `json:"sync_type"`
+	Status   string `json:"status"`
+	Jobs     int64  `json:"jobs"`
+}
+
+// SyncLogLine is a line of the logs of a sync job, along with the repo and sync type of the job
+type SyncLogLine struct {
+	ID        int64     `json:"id"`
+	JobID     int64     `json:"job_id"`
+	Repo      string    `json:"repo"`
+	SyncType  string    `json:"sync_type"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// FindProvider returns the provider with the given id or name. If the given id or name is empty, the only provider is
+// returned, if there's only one.
+func (q *Queries) FindProvider(ctx context.Context, idOrName string) (*Provider, error) {
+	const query = `
+		SELECT id, name, vendor FROM mergestat.providers
+		WHERE ($1 = '' OR id::TEXT = $1 OR name = $1)
+		ORDER BY created_at LIMIT 2`
+	rows, err := q.db.Query(ctx, query, idOrName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var providers []*Provider
+	for rows.Next() {
+		var p Provider
+		if err = rows.Scan(&p.ID, &p.Name, &p.Vendor); err != nil {
+			return nil, err
+		}
+		providers = append(providers, &p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(providers) == 0:
+		return nil, errors.Wrapf(ErrNotFound, "provider %q", idOrName)
+	case len(providers) > 1:
+		return nil, errors.New("there's more than one provider, pick one by id or name")
+	}
+	return providers[0], nil
+}
+
+// FindRepo returns the id of the repo with the given id or url (ignoring case and a .git suffix)
+func (q *Queries) FindRepo(ctx context.Context, idOrURL string) (id uuid.UUID, err error) {
+	const query = `
+		SELECT id FROM public.repos
+		WHERE id::TEXT = $1 OR regexp_replace(lower(repo), '(\.git)?/*$', '') = regexp_replace(lower($1), '(\.git)?/*$', '')
+		ORDER BY created_at LIMIT 1`
+	if err = q.db.QueryRow(ctx, query, idOrURL).Scan(&id); errors.Is(err, pgx.ErrNoRows) {
+		return id, errors.Wrapf(ErrNotFound, "repo %q", idOrURL)
+	}
+	return id, err
+}
+
+// AddRepo adds the repo with the given url to the given provider, scheduling the given sync types for it
+func (q *Queries) AddRepo(ctx context.Context, repoURL string, provider uuid.UUID, syncTypes []string) (id uuid.UUID, err error) {
+	const query = `INSERT INTO public.repos (repo, provider) VALUES ($1, $2) RETURNING id`
+	if err = q.db.QueryRow(ctx, query, repoURL, provider).Scan(&id); err != nil {
+		return id, err
+	}
+
+	for _, syncType := range syncTypes {
+		if err = q.SetRepoSyncEnabled(ctx, id, syncType, true); err != nil {
+			return id, err
+		}
+	}
+	return id, nil
+}
+
+// ListRepos lists all repos, along with the sync types scheduled for each
+func (q *Queries) ListRepos(ctx context.Context) ([]*RepoSummary, error) {
+	const query = `
+		SELECT r.id, r.repo, p.name, r.tenant, r.created_at,
+			ARRAY(SELECT rs.sync_type FROM mergestat.repo_syncs rs WHERE rs.repo_id = r.id AND rs.schedule_enabled ORDER BY rs.sync_type),
+			EXISTS (SELECT 1 FROM mergestat.repo_sync_pauses rsp WHERE rsp.repo_id = r.id)
+		FROM public.repos r INNER JOIN mergestat.providers p ON p.id = r.provider
+		ORDER BY r.repo`
+	rows, err := q.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repos []*RepoSummary
+	for rows.Next() {
+		var r RepoSummary
+		if err = rows.Scan(&r.ID, &r.Repo, &r.Provider, &r.Tenant, &r.CreatedAt, &r.Syncs, &r.Paused); err != nil {
+			return nil, err
+		}
+		repos = append(repos, &r)
+	}
+	return repos, rows.Err()
+}
+
+// SetRepoSyncEnabled schedules (or unschedules) the sync of the given type of a repo, adding it if missing
+func (q *Queries) SetRepoSyncEnabled(ctx context.Context, repo uuid.UUID, syncType string, enabled bool) error {
+	const query = `
+		INSERT INTO mergestat.repo_syncs (repo_id, sync_type, priority, schedule_enabled)
+		SELECT $1, type, priority, $3 FROM mergestat.repo_sync_types WHERE type = $2
+		ON CONFLICT ON CONSTRAINT repo_syncs_repo_id_sync_type_key DO UPDATE SET schedule_enabled = EXCLUDED.schedule_enabled`
+	tag, err := q.db.Exec(ctx, query, repo, syncType, enabled)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return errors.Wrapf(ErrNotFound, "sync type %q", syncType)
+	}
+	return nil
+}
+
+// ListRepoSyncs lists the syncs configured for the given repo, along with the status of their latest job
+func (q *Queries) ListRepoSyncs(ctx context.Context, repo uuid.UUID) ([]*RepoSync, error) {
+	const query = `
+		SELECT rs.id, rs.sync_type, rs.schedule_enabled, rs.schedule, rs.priority, rs.settings, last.id, last.status, last.done_at
+		FROM mergestat.repo_syncs rs
+			LEFT JOIN LATERAL (
+				SELECT q.id, q.status, q.done_at FROM mergestat.repo_sync_queue q WHERE q.repo_sync_id = rs.id ORDER BY q.id DESC LIMIT 1
+			) last ON TRUE
+		WHERE rs.repo_id = $1
+		ORDER BY rs.sync_type`
+	rows, err := q.db.Query(ctx, query, repo)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var syncs []*RepoSync
+	for rows.Next() {
+		var s RepoSync
+		if err = rows.Scan(&s.ID, &s.SyncType, &s.Enabled, &s.Schedule, &s.Priority, &s.Settings, &s.LastJobID, &s.LastStatus, &s.LastDoneAt); err != nil {
+			return nil, err
+		}
+		syncs = append(syncs, &s)
+	}
+	return syncs, rows.Err()
+}
+
+// PauseRepoSyncs pauses all the syncs of the given repo, for the given reason (see mergestat.pause_repo_syncs)
+func (q *Queries) PauseRepoSyncs(ctx context.Context, repo uuid.UUID, reason string) error {
+	var why *string
+	if reason != "" {
+		why = &reason
+	}
+	_, err := q.db.Exec(ctx, "SELECT mergestat.pause_repo_syncs($1, $2)", repo, why)
+	return err
+}
+
+// ResumeRepoSyncs resumes the paused syncs of the given repo (see mergestat.resume_repo_syncs)
+func (q *Queries) ResumeRepoSyncs(ctx context.Context, repo uuid.UUID) error {
+	_, err := q.db.Exec(ctx, "SELECT mergestat.resume_repo_syncs($1)", repo)
+	return err
+}
+
+// ListSyncJobs lists (at most limit of) the latest sync jobs that match the given filter, latest first
+func (q *Queries) ListSyncJobs(ctx context.Context, filter SyncJobFilter, limit int) ([]*SyncJob, error) {
+	const query = `
+		SELECT q.id, r.id, r.repo, rs.sync_type, q.status, q.attempts, q.last_error, q.created_at, q.started_at, q.done_at
+		FROM mergestat.repo_sync_queue q
+			INNER JOIN mergestat.repo_syncs rs ON rs.id = q.repo_sync_id
+			INNER JOIN public.repos r ON r.id = rs.repo_id
+		WHERE ($1::UUID IS NULL OR rs.repo_id = $1) AND ($2 = '' OR q.status = $2)
+		ORDER BY q.id DESC LIMIT $3`
+	rows, err := q.db.Query(ctx, query, filter.Repo, filter.Status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*SyncJob
+	for rows.Next() {
+		var j SyncJob
+		if err = rows.Scan(&j.ID, &j.RepoID, &j.Repo, &j.SyncType, &j.Status, &j.Attempts, &j.LastError, &j.CreatedAt, &j.StartedAt, &j.DoneAt); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, &j)
+	}
+	return jobs, rows.Err()
+}
+
+// QueueStats returns the number of queued and running jobs, by sync type and status
+func (q *Queries) QueueStats(ctx context.Context) ([]*QueueStat, error) {
+	const query = `
+		SELECT rs.sync_type, q.status, COUNT(*)
+		FROM mergestat.repo_sync_queue q INNER JOIN mergestat.repo_syncs rs ON rs.id = q.repo_sync_id
+		WHERE q.status IN ('QUEUED', 'RUNNING')
+		GROUP BY rs.sync_type, q.status
+		ORDER BY rs.sync_type, q.status`
+	rows, err := q.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []*QueueStat
+	for rows.Next() {
+		var s QueueStat
+		if err = rows.Scan(&s.SyncType, &s.Status, &s.Jobs); err != nil {
+			return nil, err
+		}
+		stats = append(stats, &s)
+	}
+	return stats, rows.Err()
+}
+
+// SyncRepoNow enqueues the sync of the given type of a repo to run right away (see mergestat.sync_repo_now),
+// returning the id of the queued job
+func (q *Queries) SyncRepoNow(ctx context.Context, repo uuid.UUID, syncType string) (id int64, err error) {
+	err = q.db.QueryRow(ctx, "SELECT mergestat.sync_repo_now($1, $2)", repo, syncType).Scan(&id)
+	return id, err
+}
+
+// AddCredential adds a credential of the given type to a provider, encrypted with ENCRYPTION_SECRET (and then with
+// the worker-held key, once a worker sealing credentials picks it up), returning its id
+func (q *Queries) AddCredential(ctx context.Context, provider uuid.UUID, credentialType, username, token string) (id uuid.UUID, err error) {
+	var secret = os.Getenv("ENCRYPTION_SECRET")
+
+	var user *string
+	if username != "" {
+		user = &username
+	}
+
+	const query = `SELECT (mergestat.add_service_auth_credential($1, $2, $3, $4, $5)).id`
+	err = q.db.QueryRow(ctx, query, provider, credentialType, user, token, secret).Scan(&id)
+	return id, err
+}
+
+// CreateWorkerUser creates (or updates) the database user of a worker, with the least-privilege worker role, and pins it
+// to the given tenant, whose rows are then the only ones it can read or write. An empty tenant unpins the user.
+func (q *Queries) CreateWorkerUser(ctx context.Context, username, password, tenant string) error {
+	var pinned *string
+	if tenant != "" {
+		pinned = &tenant
+	}
+
+	_, err := q.db.Exec(ctx, `SELECT mergestat.create_worker_user($1, $2, $3)`, username, password, pinned)
+	return err
+}
+
+// EnableHistory enables the history of the rows of the given synced table (see mergestat.enable_history), whose
+// versions are then appended to its <table>_history table by every sync
+func (q *Queries) EnableHistory(ctx context.Context, table string) error {
+	_, err := q.db.Exec(ctx, `SELECT mergestat.enable_history($1)`, table)
+	return err
+}
+
+// DisableHistory disables the history of the rows of the given synced table, dropping its history table if drop is set
+func (q *Queries) DisableHistory(ctx context.Context, table string, drop bool) error {
+	_, err := q.db.Exec(ctx, `SELECT mergestat.disable_history($1, $2)`, table, drop)
+	return err
+}
+
+// ListHistoryTables lists the synced tables whose history is enabled
+func (q *Queries) ListHistoryTables(ctx context.Context) ([]string, error) {
+	const query = `
+		SELECT c.relname FROM pg_catalog.pg_trigger t INNER JOIN pg_catalog.pg_class c ON c.oid = t.tgrelid
+		WHERE t.tgname = 'mergestat_history_trigger' ORDER BY c.relname`
+	rows, err := q.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, table)
+	}
+	return tables, rows.Err()
+}
+
+// SyncLogFilter selects the lines of the logs of sync jobs listed, by repo and / or job
+type SyncLogFilter struct {
+	Repo uuid.NullUUID
+	Job  int64 // 0 for the lines of all jobs
+}
+
+// ListSyncLogsAfter lists (at most limit of) the lines of the logs of sync jobs logged after the line with the given id,
+// oldest first, that match the given filter
+func (q *Queries) ListSyncLogsAfter(ctx context.Context, after int64, filter SyncLogFilter, limit int) ([]*SyncLogLine, error) {
+	const query = `
+		SELECT l.id, l.repo_sync_queue_id, r.repo, rs.sync_type, l.log_type, l.message, l.created_at
+		FROM mergestat.repo_sync_logs l
+			INNER JOIN mergestat.repo_sync_queue q ON q.id = l.repo_sync_queue_id
+			INNER JOIN mergestat.repo_syncs rs ON rs.id = q.repo_sync_id
+			INNER JOIN public.repos r ON r.id = rs.repo_id
+		WHERE l.id > $1 AND ($2::UUID IS NULL OR rs.repo_id = $2) AND ($3::BIGINT = 0 OR l.repo_sync_queue_id = $3)
+		ORDER BY l.id LIMIT $4`
+	rows, err := q.db.Query(ctx, query, after, filter.Repo, filter.Job, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var lines []*SyncLogLine
+	for rows.Next() {
+		var l SyncLogLine
+		if err = rows.Scan(&l.ID, &l.JobID, &l.Repo, &l.SyncType, &l.Type, &l.Message, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		lines = append(lines, &l)
+	}
+	return lines, rows.Err()
+}
+
+// SyncLogIDBefore returns the id of the line of the logs of sync jobs logged right before the latest n lines (0 if
+// there are no more than n lines), i.e. the id to list the latest n lines after (see ListSyncLogsAfter)
+func (q *Queries) SyncLogIDBefore(ctx context.Context, n int) (id int64, err error) {
+	const query = `SELECT COALESCE((SELECT id FROM mergestat.repo_sync_logs ORDER BY id DESC OFFSET $1 LIMIT 1), 0)`
+	err = q.db.QueryRow(ctx, query, n).Scan(&id)
+	return id, err
+}
+
+// SyncJobStatus returns the status of the sync job with the given id
+func (q *Queries) SyncJobStatus(ctx context.Context, id int64) (status string, err error) {
+	err = q.db.QueryRow(ctx, "SELECT status FROM mergestat.repo_sync_queue WHERE id = $1", id).Scan(&status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errors.Wrapf(ErrNotFound, "job %d", id)
+	}
+	return status, err
+}
@@ -512,6 +512,8 @@ type GithubRepoInfo struct {
 	// secret scanning push protection availability
 	SecretScanningPushProtection sql.NullString
 	MirrorUrl                    sql.NullString
+	// JSON array of the topics of the repo
+	Topics pgtype.JSONB
 }
 
 // stargazers of a GitHub repo
@@ -709,6 +711,8 @@ type MergestatProvider struct {
 	Settings    pgtype.JSONB
 	CreatedAt   time.Time
 	Description sql.NullString
+	// max number of syncs of repos of this provider running at the same time (e.g. to respect API rate limits), unlimited if NULL
+	ConcurrentSyncs sql.NullInt32
 }
 
 type MergestatQueryHistory struct {
@@ -738,6 +742,20 @@ type MergestatRepoImportType struct {
 	Description string
 }
 
+// reachability of repos, tracked by the worker to pause the syncs of repos that do not exist anymore or that access to is denied
+type MergestatRepoReachability struct {
+	// foreign key for public.repos.id
+	RepoID uuid.UUID
+	// UNREACHABLE once the repo failed to be reached too many times in a row (its syncs are paused then), REACHABLE otherwise
+	Status string
+	// number of sync jobs of the repo in a row that failed to reach it
+	ConsecutiveFailures int32
+	// error of the last sync job that failed to reach the repo
+	LastError sql.NullString
+	// timestamp of the last sync job that failed to reach the repo
+	LastFailedAt sql.NullTime
+}
+
 type MergestatRepoSync struct {
 	RepoID                       uuid.UUID
 	SyncType                     string
@@ -746,6 +764,43 @@ type MergestatRepoSync struct {
 	ScheduleEnabled              bool
 	Priority                     int32
 	LastCompletedRepoSyncQueueID sql.NullInt64
+	// cron expression (e.g. 0 3 * * 0 or @weekly) or interval (e.g. @every 15m) the sync runs on, if NULL the sync runs along with the other syncs of its type group
+	Schedule sql.NullString
+	// timestamp when the sync is next enqueued, computed by the scheduler from the schedule
+	NextRunAt sql.NullTime
+}
+
+// progress of a running repo sync job, so that an interrupted job resumes where it left off
+type MergestatRepoSyncCheckpoint struct {
+	// foreign key for mergestat.repo_sync_queue.id
+	RepoSyncQueueID int64
+	// sync specific state (e.g. the page a paginated API sync is at), the rows it fetched so far are in mergestat.repo_sync_checkpoint_rows
+	State pgtype.JSONB
+	// timestamp when the checkpoint was last updated
+	UpdatedAt time.Time
+}
+
+// rows fetched by a running repo sync job, saved along with its checkpoint
+type MergestatRepoSyncCheckpointRow struct {
+	ID int64
+	// foreign key for mergestat.repo_sync_queue.id
+	RepoSyncQueueID int64
+	// sync specific kind of the rows (e.g. pull requests or their commits)
+	Kind string
+	// JSON array of the rows fetched since the checkpoint was previously saved
+	Data pgtype.JSONB
+	// timestamp when the rows were saved
+	CreatedAt time.Time
+}
+
+// global limits on the number of syncs running at the same time, holds a single row
+type MergestatRepoSyncConcurrencyLimit struct {
+	// always true, restricts the table to a single row
+	ID bool
+	// max number of syncs running at the same time across all repos, unlimited if NULL
+	ConcurrentSyncs sql.NullInt32
+	// max number of syncs of a single repo running at the same time (e.g. to avoid cloning a repo twice), unlimited if NULL
+	ConcurrentSyncsPerRepo sql.NullInt32
 }
 
 type MergestatRepoSyncLog struct {
@@ -754,6 +809,8 @@ type MergestatRepoSyncLog struct {
 	LogType         string
 	Message         string
 	RepoSyncQueueID int64
+	// progress of the sync at the time of a PROGRESS log, as {"phase", "processed", "total", "percent"}, where total (and percent) are omitted if unknown
+	Progress pgtype.JSONB
 }
 
 type MergestatRepoSyncLogType struct {
@@ -761,6 +818,16 @@ type MergestatRepoSyncLogType struct {
 	Description sql.NullString
 }
 
+// repos whose syncs are paused (e.g. during a migration or while a token is being rotated)
+type MergestatRepoSyncPause struct {
+	// foreign key for public.repos.id
+	RepoID uuid.UUID
+	// timestamp when the syncs of the repo were paused
+	PausedAt time.Time
+	// why the syncs of the repo are paused
+	Reason sql.NullString
+}
+
 type MergestatRepoSyncQueue struct {
 	ID            int64
 	CreatedAt     time.Time
@@ -769,8 +836,27 @@ type MergestatRepoSyncQueue struct {
 	StartedAt     sql.NullTime
 	DoneAt        sql.NullTime
 	LastKeepAlive sql.NullTime
-	Priority      int32
-	TypeGroup     string
+	// jobs with a lower priority are dequeued first: scheduled jobs get the priority of their repo sync (0 and up), jobs requested by users default to -1
+	Priority  int32
+	TypeGroup string
+	// number of failed attempts of the sync job
+	Attempts int32
+	// error of the last failed attempt of the sync job
+	LastError sql.NullString
+	// CPU time (in milliseconds) used by the worker process, and the processes it spawned, while the sync job ran
+	CpuTimeMs sql.NullInt64
+	// peak memory used by the worker process while the sync job ran
+	PeakMemoryBytes sql.NullInt64
+	// peak disk space used by the scratch dirs of the sync job (e.g. the clone of its repo)
+	PeakTempDiskBytes sql.NullInt64
+	// time before which a re-queued (failed) sync job is not dequeued, NULL if it can run right away
+	NextAttemptAt sql.NullTime
+}
+
+type MergestatRepoSyncQueueProgress struct {
+	RepoSyncQueueID int64
+	ReportedAt      time.Time
+	Progress        pgtype.JSONB
 }
 
 type MergestatRepoSyncQueueStatusType struct {
@@ -778,12 +864,46 @@ type MergestatRepoSyncQueueStatusType struct {
 	Description sql.NullString
 }
 
+type MergestatRepoSyncRetention struct {
+	// always true, restricts the table to a single row
+	ID bool
+	// finished sync jobs older than this are pruned, kept regardless of age if NULL
+	MaxAge pgtype.Interval
+	// max number of finished sync jobs kept per repo sync, unlimited if NULL
+	MaxJobsPerRepoSync sql.NullInt32
+}
+
+type MergestatRepoSyncRetentionOverride struct {
+	// foreign key for public.repos.id
+	RepoID uuid.UUID
+	// finished sync jobs of the repo older than this are pruned, the global max_age applies if NULL
+	MaxAge pgtype.Interval
+	// max number of finished sync jobs kept per repo sync of the repo, the global max_jobs_per_repo_sync applies if NULL
+	MaxJobsPerRepoSync sql.NullInt32
+}
+
 type MergestatRepoSyncType struct {
 	Type        string
 	Description sql.NullString
 	ShortName   string
 	Priority    int32
 	TypeGroup   string
+	// number of times a failing sync of this type is attempted before it is moved to the DEAD status
+	MaxAttempts int32
+	// number of seconds a sync of this type may run before it is canceled, no timeout if NULL
+	TimeoutSeconds sql.NullInt32
+	// syncs of this type are paused for all repos if false
+	Enabled bool
+	// number of seconds a failed sync of this type waits before its first retry, doubling with each further retry
+	RetryBackoffSeconds int32
+}
+
+// sync types that have to run (successfully) before another sync type of the same repo
+type MergestatRepoSyncTypeDependency struct {
+	// the dependent sync type
+	SyncType string
+	// the sync type it depends on
+	DependsOn string
 }
 
 type MergestatRepoSyncTypeGroup struct {
@@ -804,7 +924,13 @@ type MergestatRepoSyncTypeLabelAssociation struct {
 	RepoSyncType string
 }
 
-// Table to save queries
+// position up to which a repo sync has processed its source, used by incremental syncs
+type MergestatRepoSyncWatermark struct {
+	RepoSyncID uuid.UUID
+	Watermark  string
+	UpdatedAt  time.Time
+}
+
 type MergestatSavedQuery struct {
 	ID uuid.UUID
 	// query creator
@@ -842,6 +968,21 @@ type MergestatServiceAuthCredential struct {
 	Provider    uuid.UUID
 	IsDefault   sql.NullBool
 	Username    []byte
+	// timestamp of when the credential was last handed out to a sync, used to rotate credentials
+	LastUsedAt sql.NullTime
+	// data key the credential (and username) is encrypted with, itself encrypted with the worker-held key; NULL if encrypted with pgp_sym_encrypt
+	EncryptedKey []byte
+}
+
+// assigns credentials to specific repos or orgs, unassigned credentials are used for all other repos of their provider
+type MergestatServiceAuthCredentialAssignment struct {
+	ID uuid.UUID
+	// foreign key for mergestat.service_auth_credentials.id
+	CredentialID uuid.UUID
+	// foreign key for public.repos.id, the repo the credential is assigned to
+	RepoID uuid.NullUUID
+	// the org (owner in the repo url) the credential is assigned to
+	Org sql.NullString
 }
 
 type MergestatServiceAuthCredentialType struct {
@@ -6,22 +6,43 @@ package db
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
 )
 
 type Querier interface {
+	// saves rows fetched by a sync job (since its checkpoint was last saved) along with its checkpoint
+	AppendSyncJobCheckpointRows(ctx context.Context, arg AppendSyncJobCheckpointRowsParams) error
 	CheckRunningImps(ctx context.Context) (int64, error)
 	CleanOldJobs(ctx context.Context, dollar_1 int32) error
-	CleanOldRepoSyncQueue(ctx context.Context, dollar_1 int32) error
 	DeleteGitHubRepoInfo(ctx context.Context, repoID uuid.UUID) error
 	DeleteRemovedRepos(ctx context.Context, arg DeleteRemovedReposParams) error
-	DequeueSyncJob(ctx context.Context) (DequeueSyncJobRow, error)
+	DeleteSyncJobCheckpoint(ctx context.Context, repoSyncQueueID int64) error
+	// dequeues the queued jobs of the given sync types for a repo (unless it's paused), so that they can be run as a batch.
+	// Batched jobs count against the same concurrency limits as the ones dequeued by DequeueSyncJob, so only as many jobs
+	// as the limits allow are dequeued. It's run along with DequeueSyncJob, holding the same advisory lock (see dequeueSyncJob).
+	DequeueRepoSyncJobs(ctx context.Context, arg DequeueRepoSyncJobsParams) ([]DequeueRepoSyncJobsRow, error)
+	DequeueSyncJob(ctx context.Context, tenant sql.NullString) (DequeueSyncJobRow, error)
 	EnableContainerSync(ctx context.Context, arg EnableContainerSyncParams) error
 	// We use a CTE here to retrieve all the repo_sync_jobs that were previously enqueued, to make sure that we *do not* re-enqueue anything new until the previously enqueued jobs are *completed*.
 	// This allows us to make sure all repo syncs complete before we reschedule a new batch.
 	// We have now also added a concept of type groups which allows us to apply this same logic but by each group type which is where the PARTITION BY clause comes into play
+	// Syncs with a schedule of their own are left out, they're enqueued on their schedule instead (see EnqueueScheduledSync).
 	EnqueueAllSyncs(ctx context.Context) error
+	// enqueues the syncs of the given types of the repo with the given url (ignoring case and a .git suffix), unless they're
+	// disabled, paused, or queued or running already, e.g. once a webhook reports a change to the repo. Returns the types enqueued.
+	EnqueueRepoSyncs(ctx context.Context, arg EnqueueRepoSyncsParams) ([]string, error)
+	// enqueues a due sync with a schedule (unless it's queued or running already) and moves it to its next run. A sync whose
+	// next run changed since it was listed (e.g. because another scheduler got to it first) is left alone. Returns 1 if it was enqueued.
+	EnqueueScheduledSync(ctx context.Context, arg EnqueueScheduledSyncParams) (int64, error)
+	// estimates the disk space (in bytes) a clone of the repo takes up, from the peak temp disk usage of its previous
+	// sync jobs, or else from the size of the repo as reported by GitHub (in kilobytes). 0 if there's nothing to go by.
+	EstimateRepoSize(ctx context.Context, repoID uuid.UUID) (int64, error)
+	// records a failed attempt of a sync job, re-queueing it (to be retried after a backoff) unless it ran out of attempts,
+	// in which case it's moved to DEAD
+	FailSyncJob(ctx context.Context, arg FailSyncJobParams) (string, error)
 	FetchContainerSync(ctx context.Context, id uuid.UUID) (FetchContainerSyncRow, error)
 	FetchGitHubToken(ctx context.Context, pgpSymDecrypt string) (string, error)
 	FetchImportJob(ctx context.Context, id uuid.UUID) (FetchImportJobRow, error)
@@ -29,16 +50,49 @@ type Querier interface {
 	GetRepoIDsFromRepoImport(ctx context.Context, arg GetRepoIDsFromRepoImportParams) ([]uuid.UUID, error)
 	GetRepoImportByID(ctx context.Context, id uuid.UUID) (MergestatRepoImport, error)
 	GetRepoUrlFromImport(ctx context.Context, importid uuid.UUID) ([]string, error)
+	GetRepoProviderSettings(ctx context.Context, id uuid.UUID) (pgtype.JSONB, error)
+	GetRepoSyncWatermark(ctx context.Context, repoSyncID uuid.UUID) (string, error)
+	GetRepoVendor(ctx context.Context, id uuid.UUID) (string, error)
+	GetSyncJobCheckpoint(ctx context.Context, repoSyncQueueID int64) (pgtype.JSONB, error)
+	GetSyncTypeTimeout(ctx context.Context, syncType string) (sql.NullInt32, error)
 	InsertGitHubRepoInfo(ctx context.Context, arg InsertGitHubRepoInfoParams) error
 	InsertNewDefaultSync(ctx context.Context, arg InsertNewDefaultSyncParams) error
 	InsertSyncJobLog(ctx context.Context, arg InsertSyncJobLogParams) error
+	// lists the syncs with a schedule that are due (and not paused), a sync that was never scheduled before is due right away
+	ListDueScheduledSyncs(ctx context.Context) ([]ListDueScheduledSyncsRow, error)
+	// lists the sync types a sync type depends on, whose latest run for the repo failed (or was skipped itself)
+	ListFailedSyncDependencies(ctx context.Context, arg ListFailedSyncDependenciesParams) ([]string, error)
+	// lists the rows of the given kind saved along with the checkpoint of a sync job, in the order they were saved
+	ListSyncJobCheckpointRows(ctx context.Context, arg ListSyncJobCheckpointRowsParams) ([]pgtype.JSONB, error)
 	ListRepoImportsDueForImport(ctx context.Context) ([]ListRepoImportsDueForImportRow, error)
 	MarkRepoImportAsUpdated(ctx context.Context, id uuid.UUID) error
-	MarkSyncsAsTimedOut(ctx context.Context) ([]int64, error)
+	// marks a repo as REACHABLE (if it wasn't already), e.g. once one of its syncs succeeded
+	MarkRepoReachable(ctx context.Context, repoID uuid.UUID) error
+	// notifies the listeners of the mergestat_sync_completed channel of a completed sync job, with the given (JSON) event
+	NotifySyncCompleted(ctx context.Context, event string) error
+	// pauses the syncs of the repos of an import that are no longer part of it (e.g. as a GitHub App lost access to them),
+	// unless they're paused already
+	PauseReposMissingFromImport(ctx context.Context, arg PauseReposMissingFromImportParams) error
+	// prunes a batch of the finished sync jobs (and their logs) that fall outside of the retention policy of their repo
+	// (see mergestat.repo_sync_retention), returning the number of jobs pruned
+	PruneRepoSyncQueue(ctx context.Context, batchSize int32) (int32, error)
+	// records a failure to reach a repo, returning whether it was marked as UNREACHABLE (and its syncs paused) as a result
+	RecordRepoUnreachable(ctx context.Context, arg RecordRepoUnreachableParams) (bool, error)
+	// requeues the running jobs whose worker stopped sending keep alives (e.g. because it crashed or was killed). This counts as a
+	// failed attempt, so that a job that keeps taking its worker down is eventually moved to DEAD (see FailSyncJob).
+	RequeueStaleSyncJobs(ctx context.Context) ([]int64, error)
+	// resumes the syncs of the repos of an import that were paused (for the given reason) while they weren't part of it
+	ResumeReposOfImport(ctx context.Context, arg ResumeReposOfImportParams) error
 	SetLatestKeepAliveForJob(ctx context.Context, id int64) error
+	// records the resources used by a sync job (see the comments on the columns of mergestat.repo_sync_queue)
+	SetSyncJobResourceUsage(ctx context.Context, arg SetSyncJobResourceUsageParams) error
 	SetSyncJobStatus(ctx context.Context, arg SetSyncJobStatusParams) error
 	UpdateImportStatus(ctx context.Context, arg UpdateImportStatusParams) error
+	// updates the url of a repo (e.g. once it was renamed or transferred), keeping its id
+	UpdateRepoURL(ctx context.Context, arg UpdateRepoURLParams) error
 	UpsertRepo(ctx context.Context, arg UpsertRepoParams) error
+	UpsertRepoSyncWatermark(ctx context.Context, arg UpsertRepoSyncWatermarkParams) error
+	UpsertSyncJobCheckpoint(ctx context.Context, arg UpsertSyncJobCheckpointParams) error
 	UpsertWorkflowRunJobs(ctx context.Context, arg UpsertWorkflowRunJobsParams) error
 	UpsertWorkflowRuns(ctx context.Context, arg UpsertWorkflowRunsParams) error
 	UpsertWorkflowsInPublic(ctx context.Context, arg UpsertWorkflowsInPublicParams) error
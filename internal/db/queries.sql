@@ -26,6 +26,10 @@ UPDATE mergestat.repo_imports SET import_status = @status::TEXT, import_error =
 -- name: CheckRunningImps :one
 SELECT COUNT(*) FROM mergestat.repo_imports WHERE import_status = 'RUNNING';
 
+-- name: UpdateRepoURL :exec
+-- updates the url of a repo (e.g. once it was renamed or transferred), keeping its id
+UPDATE public.repos SET repo = @repo WHERE id = @id;
+
 -- name: UpsertRepo :exec
 INSERT INTO public.repos (repo, repo_import_id, provider, tags) VALUES($1, $2, $3, $4)
 ON CONFLICT (repo, (ref IS NULL)) WHERE ref IS NULL
@@ -40,20 +44,53 @@ WITH
 running AS (
         SELECT 
             rsq.id,
-            rstg.group
+            rstg.group,
+            rs.repo_id,
+            repo.provider,
+            rsq.tenant
         FROM mergestat.repo_sync_queue rsq
         INNER JOIN mergestat.repo_sync_type_groups rstg ON rsq.type_group = rstg.group
+        INNER JOIN mergestat.repo_syncs rs ON rs.id = rsq.repo_sync_id
+        INNER JOIN public.repos repo ON repo.id = rs.repo_id
         WHERE status = 'RUNNING'
 ),
+limits AS (
+        SELECT concurrent_syncs, concurrent_syncs_per_repo FROM mergestat.repo_sync_concurrency_limits LIMIT 1
+),
 dequeued AS (
    UPDATE mergestat.repo_sync_queue SET status = 'RUNNING'
    WHERE id IN (   
         SELECT rsq.id
         FROM mergestat.repo_sync_queue rsq
         INNER JOIN mergestat.repo_sync_type_groups rstg ON rsq.type_group = rstg.group
+        INNER JOIN mergestat.repo_syncs rs ON rs.id = rsq.repo_sync_id
+        INNER JOIN public.repos repo ON repo.id = rs.repo_id
+        INNER JOIN mergestat.providers pr ON pr.id = repo.provider
+        INNER JOIN mergestat.tenants t ON t.name = rsq.tenant
         WHERE status = 'QUEUED'
+        -- failed jobs wait for their next attempt (see FailSyncJob)
+        AND (rsq.next_attempt_at IS NULL OR rsq.next_attempt_at <= now())
+        -- a worker pinned to a tenant only runs the jobs of that tenant
+        AND (sqlc.narg('tenant')::TEXT IS NULL OR rsq.tenant = sqlc.narg('tenant')::TEXT)
         AND rstg.concurrent_syncs > (SELECT COUNT(*) FROM running WHERE running.group = rstg.group)
-        ORDER BY rsq.priority ASC, rsq.created_at ASC, rsq.id ASC LIMIT 1 FOR UPDATE SKIP LOCKED
+        AND (pr.concurrent_syncs IS NULL OR pr.concurrent_syncs > (SELECT COUNT(*) FROM running WHERE running.provider = pr.id))
+        AND (t.concurrent_syncs IS NULL OR t.concurrent_syncs > (SELECT COUNT(*) FROM running WHERE running.tenant = t.name))
+        AND COALESCE((SELECT concurrent_syncs_per_repo FROM limits), 2147483647) > (SELECT COUNT(*) FROM running WHERE running.repo_id = rs.repo_id)
+        AND COALESCE((SELECT concurrent_syncs FROM limits), 2147483647) > (SELECT COUNT(*) FROM running)
+        -- jobs of paused syncs wait in the queue until they're resumed
+        AND rs.sync_type IN (SELECT type FROM mergestat.repo_sync_types WHERE enabled)
+        AND NOT EXISTS (SELECT 1 FROM mergestat.repo_sync_pauses p WHERE p.repo_id = rs.repo_id)
+        AND NOT EXISTS (
+            -- jobs wait for the queued or running jobs of the sync types they depend on (of the same repo)
+            SELECT 1 FROM mergestat.repo_sync_type_dependencies d
+            INNER JOIN mergestat.repo_syncs drs ON drs.sync_type = d.depends_on AND drs.repo_id = rs.repo_id
+            INNER JOIN mergestat.repo_sync_queue drsq ON drsq.repo_sync_id = drs.id
+            WHERE d.sync_type = rs.sync_type AND drsq.status IN ('QUEUED', 'RUNNING')
+        )
+        -- only lock the queue, type group, provider and tenant rows; locking the repo would conflict with the
+        -- key share locks held on it by running syncs that insert rows referencing it. The per-repo and global limits
+        -- span type groups, so callers serialize dequeues with an advisory lock taken beforehand (see dequeueSyncJob)
+        ORDER BY rsq.priority ASC, rsq.created_at ASC, rsq.id ASC LIMIT 1 FOR UPDATE OF rsq, rstg, pr, t SKIP LOCKED
    ) RETURNING id, created_at, status, repo_sync_id
 )
 SELECT
@@ -67,6 +104,75 @@ JOIN mergestat.repo_syncs ON mergestat.repo_syncs.id = dequeued.repo_sync_id
 JOIN repos ON repos.id = mergestat.repo_syncs.repo_id
 ;
 
+-- name: DequeueRepoSyncJobs :many
+-- dequeues the queued jobs of the given sync types for a repo (unless it's paused), so that they can be run as a batch.
+-- Batched jobs count against the same concurrency limits as the ones dequeued by DequeueSyncJob, so only as many jobs
+-- as the limits allow are dequeued. It's run along with DequeueSyncJob, holding the same advisory lock (see dequeueSyncJob).
+WITH
+running AS (
+    SELECT rsq.type_group, rs.repo_id, repo.provider, rsq.tenant
+    FROM mergestat.repo_sync_queue rsq
+    INNER JOIN mergestat.repo_syncs rs ON rs.id = rsq.repo_sync_id
+    INNER JOIN public.repos repo ON repo.id = rs.repo_id
+    WHERE rsq.status = 'RUNNING'
+),
+limits AS (
+    SELECT concurrent_syncs, concurrent_syncs_per_repo FROM mergestat.repo_sync_concurrency_limits LIMIT 1
+),
+candidates AS (
+    SELECT rsq.id, rsq.type_group, rsq.priority, rsq.created_at
+    FROM mergestat.repo_sync_queue rsq
+    INNER JOIN mergestat.repo_syncs rs ON rs.id = rsq.repo_sync_id
+    WHERE rsq.status = 'QUEUED' AND (rsq.next_attempt_at IS NULL OR rsq.next_attempt_at <= now())
+    AND rs.repo_id = @repo_id AND rs.sync_type = ANY(@sync_types::TEXT[])
+    AND rs.sync_type IN (SELECT type FROM mergestat.repo_sync_types WHERE enabled)
+    -- jobs of paused syncs wait in the queue until they're resumed
+    AND NOT EXISTS (SELECT 1 FROM mergestat.repo_sync_pauses p WHERE p.repo_id = rs.repo_id)
+    AND NOT EXISTS (
+        -- jobs wait for the queued or running jobs of the sync types they depend on (of the same repo), so a job never shares a batch with its dependencies
+        SELECT 1 FROM mergestat.repo_sync_type_dependencies d
+        INNER JOIN mergestat.repo_syncs drs ON drs.sync_type = d.depends_on AND drs.repo_id = rs.repo_id
+        INNER JOIN mergestat.repo_sync_queue drsq ON drsq.repo_sync_id = drs.id
+        WHERE d.sync_type = rs.sync_type AND drsq.status IN ('QUEUED', 'RUNNING')
+    )
+    FOR UPDATE OF rsq SKIP LOCKED
+),
+-- how many more jobs can run, the least of what's left of the limits of the repo, of all syncs, and of its provider and tenant
+allowance AS (
+    SELECT LEAST(
+        COALESCE((SELECT concurrent_syncs_per_repo FROM limits), 2147483647) - (SELECT COUNT(*) FROM running WHERE running.repo_id = repo.id),
+        COALESCE((SELECT concurrent_syncs FROM limits), 2147483647) - (SELECT COUNT(*) FROM running),
+        COALESCE(pr.concurrent_syncs, 2147483647) - (SELECT COUNT(*) FROM running WHERE running.provider = pr.id),
+        COALESCE(t.concurrent_syncs, 2147483647) - (SELECT COUNT(*) FROM running WHERE running.tenant = t.name)
+    ) AS jobs
+    FROM public.repos repo
+    INNER JOIN mergestat.providers pr ON pr.id = repo.provider
+    INNER JOIN mergestat.tenants t ON t.name = repo.tenant
+    WHERE repo.id = @repo_id
+),
+ranked AS (
+    SELECT candidates.id,
+        row_number() OVER (ORDER BY candidates.priority, candidates.created_at, candidates.id) AS n,
+        row_number() OVER (PARTITION BY candidates.type_group ORDER BY candidates.priority, candidates.created_at, candidates.id) AS n_in_group,
+        rstg.concurrent_syncs - (SELECT COUNT(*) FROM running WHERE running.type_group = rstg.group) AS group_allowance
+    FROM candidates
+    INNER JOIN mergestat.repo_sync_type_groups rstg ON rstg.group = candidates.type_group
+),
+dequeued AS (
+    UPDATE mergestat.repo_sync_queue SET status = 'RUNNING'
+    WHERE id IN (SELECT id FROM ranked WHERE n <= (SELECT jobs FROM allowance) AND n_in_group <= group_allowance)
+    RETURNING id, created_at, status, repo_sync_id
+)
+SELECT
+    dequeued.*,
+    repo_syncs.*,
+    repos.repo,
+    repos.ref,
+    repos.settings AS repo_settings
+FROM dequeued
+JOIN mergestat.repo_syncs ON mergestat.repo_syncs.id = dequeued.repo_sync_id
+JOIN repos ON repos.id = mergestat.repo_syncs.repo_id;
+
 -- name: DeleteGitHubRepoInfo :exec
 DELETE FROM public.github_repo_info WHERE repo_id = $1;
 
@@ -77,10 +183,11 @@ INSERT INTO public.github_repo_info (
     is_archived, is_disabled, mirror_url, is_private, total_issues_count, latest_release_author,
     latest_release_created_at, latest_release_name, latest_release_published_at, license_key,
     license_name, primary_language, pushed_at, releases_count,
-    stargazers_count, updated_at, watchers_count,advanced_security,secret_scanning,secret_scanning_push_protection
+    stargazers_count, updated_at, watchers_count,advanced_security,secret_scanning,secret_scanning_push_protection,
+    topics
 ) VALUES(
     $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22,
-    $23, $24, $25, $26, $27, $28,$29
+    $23, $24, $25, $26, $27, $28,$29, $30
 );
 
 -- name: InsertSyncJobLog :exec
@@ -89,12 +196,46 @@ INSERT INTO mergestat.repo_sync_logs (log_type, message, repo_sync_queue_id) VAL
 -- name: SetSyncJobStatus :exec
 SELECT mergestat.set_sync_job_status(@Status::TEXT, @ID::BIGINT);
 
+-- name: SetSyncJobResourceUsage :exec
+-- records the resources used by a sync job (see the comments on the columns of mergestat.repo_sync_queue)
+UPDATE mergestat.repo_sync_queue SET
+    cpu_time_ms = @cpu_time_ms::BIGINT,
+    peak_memory_bytes = @peak_memory_bytes::BIGINT,
+    peak_temp_disk_bytes = @peak_temp_disk_bytes::BIGINT
+WHERE id = @id::BIGINT;
+
+-- name: NotifySyncCompleted :exec
+-- notifies the listeners of the mergestat_sync_completed channel of a completed sync job, with the given (JSON) event
+SELECT pg_notify('mergestat_sync_completed', @event::TEXT);
+
+-- name: FailSyncJob :one
+-- records a failed attempt of a sync job, re-queueing it (to be retried after a backoff) unless it ran out of attempts,
+-- in which case it's moved to DEAD
+WITH failed AS (
+    UPDATE mergestat.repo_sync_queue rsq SET
+        attempts = rsq.attempts + 1,
+        last_error = @last_error::TEXT,
+        status = CASE WHEN rsq.attempts + 1 >= rst.max_attempts THEN 'DEAD' ELSE 'QUEUED' END,
+        -- retries back off exponentially (see mergestat.repo_sync_types.retry_backoff_seconds)
+        next_attempt_at = now() + make_interval(secs => rst.retry_backoff_seconds * power(2, LEAST(rsq.attempts, 10)))
+    FROM mergestat.repo_syncs rs, mergestat.repo_sync_types rst
+    WHERE rsq.id = @id::BIGINT AND rs.id = rsq.repo_sync_id AND rst.type = rs.sync_type
+    RETURNING rsq.id, rsq.repo_sync_id, rsq.status
+),
+completed AS (
+    UPDATE mergestat.repo_syncs SET last_completed_repo_sync_queue_id = failed.id
+    FROM failed
+    WHERE failed.status = 'DEAD' AND mergestat.repo_syncs.id = failed.repo_sync_id
+)
+SELECT status FROM failed;
+
 -- name: FetchGitHubToken :one
 SELECT pgp_sym_decrypt(credentials, $1) FROM mergestat.service_auth_credentials WHERE type = 'GITHUB_PAT' ORDER BY created_at DESC LIMIT 1;
 
 -- We use a CTE here to retrieve all the repo_sync_jobs that were previously enqueued, to make sure that we *do not* re-enqueue anything new until the previously enqueued jobs are *completed*.
 -- This allows us to make sure all repo syncs complete before we reschedule a new batch.
 -- We have now also added a concept of type groups which allows us to apply this same logic but by each group type which is where the PARTITION BY clause comes into play
+-- Syncs with a schedule of their own are left out, they're enqueued on their schedule instead (see EnqueueScheduledSync).
 -- name: EnqueueAllSyncs :exec
 WITH ranked_queue AS (
     SELECT
@@ -105,7 +246,7 @@ WITH ranked_queue AS (
     FROM mergestat.repo_syncs as rs
     INNER JOIN mergestat.repo_sync_queue AS rsq ON rs.id = rsq.repo_sync_id
     INNER JOIN mergestat.repo_sync_types AS rst ON rs.sync_type = rst.type
-    WHERE rsq.done_at IS NULL
+    WHERE rsq.done_at IS NULL AND rs.schedule IS NULL
 )
 INSERT INTO mergestat.repo_sync_queue (repo_sync_id, status, priority, type_group)
 SELECT
@@ -115,7 +256,8 @@ SELECT
     rst.type_group
 FROM mergestat.repo_syncs rs
 INNER JOIN mergestat.repo_sync_types AS rst ON rs.sync_type = rst.type
-WHERE schedule_enabled
+WHERE schedule_enabled AND rs.schedule IS NULL AND rst.enabled
+    AND NOT EXISTS (SELECT 1 FROM mergestat.repo_sync_pauses p WHERE p.repo_id = rs.repo_id)
     AND id NOT IN (SELECT repo_sync_id FROM mergestat.repo_sync_queue WHERE status = 'RUNNING' OR status = 'QUEUED')
     AND NOT EXISTS (
         SELECT rq.done_at
@@ -127,19 +269,88 @@ WHERE schedule_enabled
 ORDER BY rs.priority, rs.sync_type desc
 ;
 
+-- name: EnqueueScheduledSync :execrows
+-- enqueues a due sync with a schedule (unless it's queued or running already) and moves it to its next run. A sync whose
+-- next run changed since it was listed (e.g. because another scheduler got to it first) is left alone. Returns 1 if it was enqueued.
+WITH scheduled AS (
+    UPDATE mergestat.repo_syncs SET next_run_at = @next_run_at::TIMESTAMPTZ
+    WHERE id = @id AND next_run_at IS NOT DISTINCT FROM @previous_run_at
+    RETURNING id, sync_type, priority
+)
+INSERT INTO mergestat.repo_sync_queue (repo_sync_id, status, priority, type_group)
+SELECT
+    scheduled.id,
+    'QUEUED' AS status,
+    scheduled.priority,
+    rst.type_group
+FROM scheduled
+INNER JOIN mergestat.repo_sync_types AS rst ON scheduled.sync_type = rst.type
+WHERE scheduled.id NOT IN (SELECT repo_sync_id FROM mergestat.repo_sync_queue WHERE status = 'RUNNING' OR status = 'QUEUED');
+
+-- name: EnqueueRepoSyncs :many
+-- enqueues the syncs of the given types of the repo with the given url (ignoring case and a .git suffix), unless they're
+-- disabled, paused, or queued or running already, e.g. once a webhook reports a change to the repo. Returns the types enqueued.
+WITH syncs AS (
+    SELECT rs.id, rs.sync_type, rs.priority, rst.type_group
+    FROM mergestat.repo_syncs rs
+    INNER JOIN public.repos r ON r.id = rs.repo_id
+    INNER JOIN mergestat.repo_sync_types AS rst ON rs.sync_type = rst.type
+    WHERE regexp_replace(lower(r.repo), '(\.git)?/*$', '') = regexp_replace(lower(@repo_url::TEXT), '(\.git)?/*$', '')
+        AND rs.sync_type = ANY(@sync_types::TEXT[]) AND rs.schedule_enabled AND rst.enabled
+        AND NOT EXISTS (SELECT 1 FROM mergestat.repo_sync_pauses p WHERE p.repo_id = rs.repo_id)
+        AND rs.id NOT IN (SELECT repo_sync_id FROM mergestat.repo_sync_queue WHERE status = 'RUNNING' OR status = 'QUEUED')
+), queued AS (
+    INSERT INTO mergestat.repo_sync_queue (repo_sync_id, status, priority, type_group)
+    SELECT id, 'QUEUED' AS status, priority, type_group FROM syncs
+    RETURNING repo_sync_id
+)
+SELECT syncs.sync_type FROM queued INNER JOIN syncs ON syncs.id = queued.repo_sync_id ORDER BY syncs.sync_type;
+
+-- name: ListDueScheduledSyncs :many
+-- lists the syncs with a schedule that are due (and not paused), a sync that was never scheduled before is due right away
+SELECT id, schedule, next_run_at FROM mergestat.repo_syncs
+WHERE schedule_enabled AND schedule IS NOT NULL AND (next_run_at IS NULL OR next_run_at <= now())
+    AND sync_type IN (SELECT type FROM mergestat.repo_sync_types WHERE enabled)
+    AND NOT EXISTS (SELECT 1 FROM mergestat.repo_sync_pauses p WHERE p.repo_id = repo_syncs.repo_id);
+
+-- name: ListFailedSyncDependencies :many
+-- lists the sync types a sync type depends on, whose latest run for the repo failed (or was skipped itself)
+SELECT d.depends_on FROM mergestat.repo_sync_type_dependencies d
+INNER JOIN mergestat.repo_syncs rs ON rs.sync_type = d.depends_on AND rs.repo_id = @repo_id
+INNER JOIN mergestat.repo_sync_queue rsq ON rsq.id = rs.last_completed_repo_sync_queue_id
+WHERE d.sync_type = @sync_type AND rsq.status IN ('DEAD', 'TIMED_OUT', 'SKIPPED');
+
 -- name: SetLatestKeepAliveForJob :exec
 UPDATE mergestat.repo_sync_queue SET last_keep_alive = now() WHERE id = $1;
 
--- name: MarkSyncsAsTimedOut :many
-WITH timed_out_sync_jobs AS (
-    UPDATE mergestat.repo_sync_queue SET status = 'DONE' WHERE status = 'RUNNING' AND (
-        (last_keep_alive < now() - '10 minutes'::interval)
+-- name: RequeueStaleSyncJobs :many
+-- requeues the running jobs whose worker stopped sending keep alives (e.g. because it crashed or was killed). This counts as a
+-- failed attempt, so that a job that keeps taking its worker down is eventually moved to DEAD (see FailSyncJob).
+WITH stale_sync_jobs AS (
+    UPDATE mergestat.repo_sync_queue rsq SET
+        attempts = rsq.attempts + 1,
+        last_error = 'No response from job within reasonable interval',
+        last_keep_alive = NULL,
+        status = CASE WHEN rsq.attempts + 1 >= rst.max_attempts THEN 'DEAD' ELSE 'QUEUED' END,
+        -- retries back off exponentially (see mergestat.repo_sync_types.retry_backoff_seconds)
+        next_attempt_at = now() + make_interval(secs => rst.retry_backoff_seconds * power(2, LEAST(rsq.attempts, 10)))
+    FROM mergestat.repo_syncs rs, mergestat.repo_sync_types rst
+    WHERE rsq.status = 'RUNNING' AND rs.id = rsq.repo_sync_id AND rst.type = rs.sync_type AND (
+        (rsq.last_keep_alive < now() - '10 minutes'::interval)
         OR
-        (last_keep_alive IS NULL AND started_at < now() - '10 minutes'::interval)) -- if worker crashed before last_keep_alive was first set
-    RETURNING *
+        (rsq.last_keep_alive IS NULL AND rsq.started_at < now() - '10 minutes'::interval)) -- if worker crashed before last_keep_alive was first set
+    RETURNING rsq.id, rsq.repo_sync_id, rsq.status
+),
+completed AS (
+    UPDATE mergestat.repo_syncs SET last_completed_repo_sync_queue_id = stale_sync_jobs.id
+    FROM stale_sync_jobs
+    WHERE stale_sync_jobs.status = 'DEAD' AND mergestat.repo_syncs.id = stale_sync_jobs.repo_sync_id
 )
 INSERT INTO mergestat.repo_sync_logs (repo_sync_queue_id, log_type, message)
-SELECT id, 'ERROR', 'No response from job within reasonable interval. Timing out.' FROM timed_out_sync_jobs
+SELECT id, 'ERROR', CASE
+    WHEN status = 'DEAD' THEN 'No response from job within reasonable interval. Out of attempts.'
+    ELSE 'No response from job within reasonable interval. Requeuing.'
+END FROM stale_sync_jobs
 RETURNING repo_sync_queue_id
 ;
 
@@ -147,8 +358,30 @@ RETURNING repo_sync_queue_id
 DELETE FROM public.repos WHERE repo_import_id = $1::uuid AND NOT(repo = ANY($2::TEXT[]))
 ;
 
--- name: CleanOldRepoSyncQueue :exec
-SELECT mergestat.simple_repo_sync_queue_cleanup($1::INTEGER);
+-- name: RecordRepoUnreachable :one
+-- records a failure to reach a repo, returning whether it was marked as UNREACHABLE (and its syncs paused) as a result
+SELECT mergestat.record_repo_unreachable(@repo_id::uuid, @error::TEXT, @threshold::INTEGER)::BOOLEAN;
+
+-- name: MarkRepoReachable :exec
+-- marks a repo as REACHABLE (if it wasn't already), e.g. once one of its syncs succeeded
+SELECT mergestat.mark_repo_reachable(@repo_id::uuid);
+
+-- name: PauseReposMissingFromImport :exec
+-- pauses the syncs of the repos of an import that are no longer part of it (e.g. as a GitHub App lost access to them),
+-- unless they're paused already
+INSERT INTO mergestat.repo_sync_pauses (repo_id, reason)
+SELECT id, @reason::TEXT FROM public.repos WHERE repo_import_id = @import_id::uuid AND NOT(repo = ANY(@repos::TEXT[]))
+ON CONFLICT (repo_id) DO NOTHING;
+
+-- name: ResumeReposOfImport :exec
+-- resumes the syncs of the repos of an import that were paused (for the given reason) while they weren't part of it
+DELETE FROM mergestat.repo_sync_pauses p USING public.repos r
+WHERE p.repo_id = r.id AND r.repo_import_id = @import_id::uuid AND r.repo = ANY(@repos::TEXT[]) AND p.reason = @reason::TEXT;
+
+-- name: PruneRepoSyncQueue :one
+-- prunes a batch of the finished sync jobs (and their logs) that fall outside of the retention policy of their repo
+-- (see mergestat.repo_sync_retention), returning the number of jobs pruned
+SELECT mergestat.prune_repo_sync_queue(@batch_size::INTEGER)::INTEGER;
 
 -- name: CleanOldJobs :exec
 SELECT mergestat.simple_sqlq_cleanup($1::INTEGER);
@@ -399,6 +632,58 @@ FROM t;
 -- name: GetRepoById :one
 SELECT * FROM public.repos WHERE id = @id;
 
+-- name: GetRepoProviderSettings :one
+SELECT pr.settings FROM public.repos repo
+    INNER JOIN mergestat.providers pr ON pr.id = repo.provider
+WHERE repo.id = @id;
+
+-- name: GetRepoSyncWatermark :one
+SELECT watermark FROM mergestat.repo_sync_watermarks WHERE repo_sync_id = @repo_sync_id;
+
+-- name: UpsertRepoSyncWatermark :exec
+INSERT INTO mergestat.repo_sync_watermarks (repo_sync_id, watermark) VALUES (@repo_sync_id, @watermark)
+ON CONFLICT (repo_sync_id) DO UPDATE SET watermark = excluded.watermark, updated_at = now();
+
+-- name: GetSyncJobCheckpoint :one
+SELECT state FROM mergestat.repo_sync_checkpoints WHERE repo_sync_queue_id = @repo_sync_queue_id;
+
+-- name: UpsertSyncJobCheckpoint :exec
+INSERT INTO mergestat.repo_sync_checkpoints (repo_sync_queue_id, state) VALUES (@repo_sync_queue_id, @state)
+ON CONFLICT (repo_sync_queue_id) DO UPDATE SET state = excluded.state, updated_at = now();
+
+-- name: AppendSyncJobCheckpointRows :exec
+-- saves rows fetched by a sync job (since its checkpoint was last saved) along with its checkpoint
+INSERT INTO mergestat.repo_sync_checkpoint_rows (repo_sync_queue_id, kind, data) VALUES (@repo_sync_queue_id, @kind, @data);
+
+-- name: ListSyncJobCheckpointRows :many
+-- lists the rows of the given kind saved along with the checkpoint of a sync job, in the order they were saved
+SELECT data FROM mergestat.repo_sync_checkpoint_rows WHERE repo_sync_queue_id = @repo_sync_queue_id AND kind = @kind ORDER BY id;
+
+-- name: DeleteSyncJobCheckpoint :exec
+WITH deleted_rows AS (
+    DELETE FROM mergestat.repo_sync_checkpoint_rows WHERE repo_sync_queue_id = @repo_sync_queue_id
+)
+DELETE FROM mergestat.repo_sync_checkpoints WHERE repo_sync_queue_id = @repo_sync_queue_id;
+
+-- name: GetRepoVendor :one
+SELECT pr.vendor FROM public.repos repo
+    INNER JOIN mergestat.providers pr ON pr.id = repo.provider
+WHERE repo.id = @id;
+
+-- name: EstimateRepoSize :one
+-- estimates the disk space (in bytes) a clone of the repo takes up, from the peak temp disk usage of its previous
+-- sync jobs, or else from the size of the repo as reported by GitHub (in kilobytes). 0 if there's nothing to go by.
+SELECT COALESCE(
+    (SELECT MAX(rsq.peak_temp_disk_bytes) FROM mergestat.repo_sync_queue rsq
+        INNER JOIN mergestat.repo_syncs rs ON rs.id = rsq.repo_sync_id
+    WHERE rs.repo_id = @repo_id AND rsq.peak_temp_disk_bytes > 0),
+    (SELECT gri.size::BIGINT * 1024 FROM public.github_repo_info gri WHERE gri.repo_id = @repo_id),
+    0
+)::BIGINT AS estimate;
+
+-- name: GetSyncTypeTimeout :one
+SELECT timeout_seconds FROM mergestat.repo_sync_types WHERE type = @sync_type;
+
 -- name: FetchContainerSync :one
 SELECT sync.id, sync.repo_id,
     image.type AS image_type, image.url AS image_url, image.version AS image_version,
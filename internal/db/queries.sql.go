@@ -14,6 +14,22 @@ import (
 	"github.com/jackc/pgtype"
 )
 
+const appendSyncJobCheckpointRows = `-- name: AppendSyncJobCheckpointRows :exec
+INSERT INTO mergestat.repo_sync_checkpoint_rows (repo_sync_queue_id, kind, data) VALUES ($1, $2, $3)
+`
+
+type AppendSyncJobCheckpointRowsParams struct {
+	RepoSyncQueueID int64
+	Kind            string
+	Data            pgtype.JSONB
+}
+
+// saves rows fetched by a sync job (since its checkpoint was last saved) along with its checkpoint
+func (q *Queries) AppendSyncJobCheckpointRows(ctx context.Context, arg AppendSyncJobCheckpointRowsParams) error {
+	_, err := q.db.Exec(ctx, appendSyncJobCheckpointRows, arg.RepoSyncQueueID, arg.Kind, arg.Data)
+	return err
+}
+
 const checkRunningImps = `-- name: CheckRunningImps :one
 SELECT COUNT(*) FROM mergestat.repo_imports WHERE import_status = 'RUNNING'
 `
@@ -34,15 +50,6 @@ func (q *Queries) CleanOldJobs(ctx context.Context, dollar_1 int32) error {
 	return err
 }
 
-const cleanOldRepoSyncQueue = `-- name: CleanOldRepoSyncQueue :exec
-SELECT mergestat.simple_repo_sync_queue_cleanup($1::INTEGER)
-`
-
-func (q *Queries) CleanOldRepoSyncQueue(ctx context.Context, dollar_1 int32) error {
-	_, err := q.db.Exec(ctx, cleanOldRepoSyncQueue, dollar_1)
-	return err
-}
-
 const deleteGitHubRepoInfo = `-- name: DeleteGitHubRepoInfo :exec
 DELETE FROM public.github_repo_info WHERE repo_id = $1
 `
@@ -66,30 +73,206 @@ func (q *Queries) DeleteRemovedRepos(ctx context.Context, arg DeleteRemovedRepos
 	return err
 }
 
+const deleteSyncJobCheckpoint = `-- name: DeleteSyncJobCheckpoint :exec
+WITH deleted_rows AS (
+    DELETE FROM mergestat.repo_sync_checkpoint_rows WHERE repo_sync_queue_id = $1
+)
+DELETE FROM mergestat.repo_sync_checkpoints WHERE repo_sync_queue_id = $1
+`
+
+func (q *Queries) DeleteSyncJobCheckpoint(ctx context.Context, repoSyncQueueID int64) error {
+	_, err := q.db.Exec(ctx, deleteSyncJobCheckpoint, repoSyncQueueID)
+	return err
+}
+
+const dequeueRepoSyncJobs = `-- name: DequeueRepoSyncJobs :many
+WITH
+running AS (
+    SELECT rsq.type_group, rs.repo_id, repo.provider, rsq.tenant
+    FROM mergestat.repo_sync_queue rsq
+    INNER JOIN mergestat.repo_syncs rs ON rs.id = rsq.repo_sync_id
+    INNER JOIN public.repos repo ON repo.id = rs.repo_id
+    WHERE rsq.status = 'RUNNING'
+),
+limits AS (
+    SELECT concurrent_syncs, concurrent_syncs_per_repo FROM mergestat.repo_sync_concurrency_limits LIMIT 1
+),
+candidates AS (
+    SELECT rsq.id, rsq.type_group, rsq.priority, rsq.created_at
+    FROM mergestat.repo_sync_queue rsq
+    INNER JOIN mergestat.repo_syncs rs ON rs.id = rsq.repo_sync_id
+    WHERE rsq.status = 'QUEUED' AND (rsq.next_attempt_at IS NULL OR rsq.next_attempt_at <= now())
+    AND rs.repo_id = $1 AND rs.sync_type = ANY($2::TEXT[])
+    AND rs.sync_type IN (SELECT type FROM mergestat.repo_sync_types WHERE enabled)
+    -- jobs of paused syncs wait in the queue until they're resumed
+    AND NOT EXISTS (SELECT 1 FROM mergestat.repo_sync_pauses p WHERE p.repo_id = rs.repo_id)
+    AND NOT EXISTS (
+        -- jobs wait for the queued or running jobs of the sync types they depend on (of the same repo), so a job never shares a batch with its dependencies
+        SELECT 1 FROM mergestat.repo_sync_type_dependencies d
+        INNER JOIN mergestat.repo_syncs drs ON drs.sync_type = d.depends_on AND drs.repo_id = rs.repo_id
+        INNER JOIN mergestat.repo_sync_queue drsq ON drsq.repo_sync_id = drs.id
+        WHERE d.sync_type = rs.sync_type AND drsq.status IN ('QUEUED', 'RUNNING')
+    )
+    FOR UPDATE OF rsq SKIP LOCKED
+),
+-- how many more jobs can run, the least of what's left of the limits of the repo, of all syncs, and of its provider and tenant
+allowance AS (
+    SELECT LEAST(
+        COALESCE((SELECT concurrent_syncs_per_repo FROM limits), 2147483647) - (SELECT COUNT(*) FROM running WHERE running.repo_id = repo.id),
+        COALESCE((SELECT concurrent_syncs FROM limits), 2147483647) - (SELECT COUNT(*) FROM running),
+        COALESCE(pr.concurrent_syncs, 2147483647) - (SELECT COUNT(*) FROM running WHERE running.provider = pr.id),
+        COALESCE(t.concurrent_syncs, 2147483647) - (SELECT COUNT(*) FROM running WHERE running.tenant = t.name)
+    ) AS jobs
+    FROM public.repos repo
+    INNER JOIN mergestat.providers pr ON pr.id = repo.provider
+    INNER JOIN mergestat.tenants t ON t.name = repo.tenant
+    WHERE repo.id = $1
+),
+ranked AS (
+    SELECT candidates.id,
+        row_number() OVER (ORDER BY candidates.priority, candidates.created_at, candidates.id) AS n,
+        row_number() OVER (PARTITION BY candidates.type_group ORDER BY candidates.priority, candidates.created_at, candidates.id) AS n_in_group,
+        rstg.concurrent_syncs - (SELECT COUNT(*) FROM running WHERE running.type_group = rstg.group) AS group_allowance
+    FROM candidates
+    INNER JOIN mergestat.repo_sync_type_groups rstg ON rstg.group = candidates.type_group
+),
+dequeued AS (
+    UPDATE mergestat.repo_sync_queue SET status = 'RUNNING'
+    WHERE id IN (SELECT id FROM ranked WHERE n <= (SELECT jobs FROM allowance) AND n_in_group <= group_allowance)
+    RETURNING id, created_at, status, repo_sync_id
+)
+SELECT
+    dequeued.id, dequeued.created_at, dequeued.status, dequeued.repo_sync_id,
+    repo_syncs.repo_id, repo_syncs.sync_type, repo_syncs.settings, repo_syncs.id, repo_syncs.schedule_enabled, repo_syncs.priority, repo_syncs.last_completed_repo_sync_queue_id, repo_syncs.schedule, repo_syncs.next_run_at,
+    repos.repo,
+    repos.ref,
+    repos.settings AS repo_settings
+FROM dequeued
+JOIN mergestat.repo_syncs ON mergestat.repo_syncs.id = dequeued.repo_sync_id
+JOIN repos ON repos.id = mergestat.repo_syncs.repo_id
+`
+
+type DequeueRepoSyncJobsParams struct {
+	RepoID    uuid.UUID
+	SyncTypes []string
+}
+
+type DequeueRepoSyncJobsRow struct {
+	ID                           int64
+	CreatedAt                    time.Time
+	Status                       string
+	RepoSyncID                   uuid.UUID
+	RepoID                       uuid.UUID
+	SyncType                     string
+	Settings                     pgtype.JSONB
+	ID_2                         uuid.UUID
+	ScheduleEnabled              bool
+	Priority                     int32
+	LastCompletedRepoSyncQueueID sql.NullInt64
+	Schedule                     sql.NullString
+	NextRunAt                    sql.NullTime
+	Repo                         string
+	Ref                          sql.NullString
+	RepoSettings                 pgtype.JSONB
+}
+
+// dequeues the queued jobs of the given sync types for a repo (unless it's paused), so that they can be run as a batch.
+// Batched jobs count against the same concurrency limits as the ones dequeued by DequeueSyncJob, so only as many jobs
+// as the limits allow are dequeued. It's run along with DequeueSyncJob, holding the same advisory lock (see dequeueSyncJob).
+func (q *Queries) DequeueRepoSyncJobs(ctx context.Context, arg DequeueRepoSyncJobsParams) ([]DequeueRepoSyncJobsRow, error) {
+	rows, err := q.db.Query(ctx, dequeueRepoSyncJobs, arg.RepoID, arg.SyncTypes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []DequeueRepoSyncJobsRow
+	for rows.Next() {
+		var i DequeueRepoSyncJobsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.Status,
+			&i.RepoSyncID,
+			&i.RepoID,
+			&i.SyncType,
+			&i.Settings,
+			&i.ID_2,
+			&i.ScheduleEnabled,
+			&i.Priority,
+			&i.LastCompletedRepoSyncQueueID,
+			&i.Schedule,
+			&i.NextRunAt,
+			&i.Repo,
+			&i.Ref,
+			&i.RepoSettings,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const dequeueSyncJob = `-- name: DequeueSyncJob :one
 WITH
 running AS (
         SELECT 
             rsq.id,
-            rstg.group
+            rstg.group,
+            rs.repo_id,
+            repo.provider,
+            rsq.tenant
         FROM mergestat.repo_sync_queue rsq
         INNER JOIN mergestat.repo_sync_type_groups rstg ON rsq.type_group = rstg.group
+        INNER JOIN mergestat.repo_syncs rs ON rs.id = rsq.repo_sync_id
+        INNER JOIN public.repos repo ON repo.id = rs.repo_id
         WHERE status = 'RUNNING'
 ),
+limits AS (
+        SELECT concurrent_syncs, concurrent_syncs_per_repo FROM mergestat.repo_sync_concurrency_limits LIMIT 1
+),
 dequeued AS (
    UPDATE mergestat.repo_sync_queue SET status = 'RUNNING'
    WHERE id IN (   
         SELECT rsq.id
         FROM mergestat.repo_sync_queue rsq
         INNER JOIN mergestat.repo_sync_type_groups rstg ON rsq.type_group = rstg.group
+        INNER JOIN mergestat.repo_syncs rs ON rs.id = rsq.repo_sync_id
+        INNER JOIN public.repos repo ON repo.id = rs.repo_id
+        INNER JOIN mergestat.providers pr ON pr.id = repo.provider
+        INNER JOIN mergestat.tenants t ON t.name = rsq.tenant
         WHERE status = 'QUEUED'
+        -- failed jobs wait for their next attempt (see FailSyncJob)
+        AND (rsq.next_attempt_at IS NULL OR rsq.next_attempt_at <= now())
+        -- a worker pinned to a tenant only runs the jobs of that tenant
+        AND ($1::TEXT IS NULL OR rsq.tenant = $1::TEXT)
         AND rstg.concurrent_syncs > (SELECT COUNT(*) FROM running WHERE running.group = rstg.group)
-        ORDER BY rsq.priority ASC, rsq.created_at ASC, rsq.id ASC LIMIT 1 FOR UPDATE SKIP LOCKED
+        AND (pr.concurrent_syncs IS NULL OR pr.concurrent_syncs > (SELECT COUNT(*) FROM running WHERE running.provider = pr.id))
+        AND (t.concurrent_syncs IS NULL OR t.concurrent_syncs > (SELECT COUNT(*) FROM running WHERE running.tenant = t.name))
+        AND COALESCE((SELECT concurrent_syncs_per_repo FROM limits), 2147483647) > (SELECT COUNT(*) FROM running WHERE running.repo_id = rs.repo_id)
+        AND COALESCE((SELECT concurrent_syncs FROM limits), 2147483647) > (SELECT COUNT(*) FROM running)
+        -- jobs of paused syncs wait in the queue until they're resumed
+        AND rs.sync_type IN (SELECT type FROM mergestat.repo_sync_types WHERE enabled)
+        AND NOT EXISTS (SELECT 1 FROM mergestat.repo_sync_pauses p WHERE p.repo_id = rs.repo_id)
+        AND NOT EXISTS (
+            -- jobs wait for the queued or running jobs of the sync types they depend on (of the same repo)
+            SELECT 1 FROM mergestat.repo_sync_type_dependencies d
+            INNER JOIN mergestat.repo_syncs drs ON drs.sync_type = d.depends_on AND drs.repo_id = rs.repo_id
+            INNER JOIN mergestat.repo_sync_queue drsq ON drsq.repo_sync_id = drs.id
+            WHERE d.sync_type = rs.sync_type AND drsq.status IN ('QUEUED', 'RUNNING')
+        )
+        -- only lock the queue, type group, provider and tenant rows; locking the repo would conflict with the
+        -- key share locks held on it by running syncs that insert rows referencing it. The per-repo and global limits
+        -- span type groups, so callers serialize dequeues with an advisory lock taken beforehand (see dequeueSyncJob)
+        ORDER BY rsq.priority ASC, rsq.created_at ASC, rsq.id ASC LIMIT 1 FOR UPDATE OF rsq, rstg, pr, t SKIP LOCKED
    ) RETURNING id, created_at, status, repo_sync_id
 )
 SELECT
     dequeued.id, dequeued.created_at, dequeued.status, dequeued.repo_sync_id,
-    repo_syncs.repo_id, repo_syncs.sync_type, repo_syncs.settings, repo_syncs.id, repo_syncs.schedule_enabled, repo_syncs.priority, repo_syncs.last_completed_repo_sync_queue_id,
+    repo_syncs.repo_id, repo_syncs.sync_type, repo_syncs.settings, repo_syncs.id, repo_syncs.schedule_enabled, repo_syncs.priority, repo_syncs.last_completed_repo_sync_queue_id, repo_syncs.schedule, repo_syncs.next_run_at,
     repos.repo,
     repos.ref,
     repos.settings AS repo_settings
@@ -110,13 +293,15 @@ type DequeueSyncJobRow struct {
 	ScheduleEnabled              bool
 	Priority                     int32
 	LastCompletedRepoSyncQueueID sql.NullInt64
+	Schedule                     sql.NullString
+	NextRunAt                    sql.NullTime
 	Repo                         string
 	Ref                          sql.NullString
 	RepoSettings                 pgtype.JSONB
 }
 
-func (q *Queries) DequeueSyncJob(ctx context.Context) (DequeueSyncJobRow, error) {
-	row := q.db.QueryRow(ctx, dequeueSyncJob)
+func (q *Queries) DequeueSyncJob(ctx context.Context, tenant sql.NullString) (DequeueSyncJobRow, error) {
+	row := q.db.QueryRow(ctx, dequeueSyncJob, tenant)
 	var i DequeueSyncJobRow
 	err := row.Scan(
 		&i.ID,
@@ -130,6 +315,8 @@ func (q *Queries) DequeueSyncJob(ctx context.Context) (DequeueSyncJobRow, error)
 		&i.ScheduleEnabled,
 		&i.Priority,
 		&i.LastCompletedRepoSyncQueueID,
+		&i.Schedule,
+		&i.NextRunAt,
 		&i.Repo,
 		&i.Ref,
 		&i.RepoSettings,
@@ -161,7 +348,7 @@ WITH ranked_queue AS (
     FROM mergestat.repo_syncs as rs
     INNER JOIN mergestat.repo_sync_queue AS rsq ON rs.id = rsq.repo_sync_id
     INNER JOIN mergestat.repo_sync_types AS rst ON rs.sync_type = rst.type
-    WHERE rsq.done_at IS NULL
+    WHERE rsq.done_at IS NULL AND rs.schedule IS NULL
 )
 INSERT INTO mergestat.repo_sync_queue (repo_sync_id, status, priority, type_group)
 SELECT
@@ -171,7 +358,8 @@ SELECT
     rst.type_group
 FROM mergestat.repo_syncs rs
 INNER JOIN mergestat.repo_sync_types AS rst ON rs.sync_type = rst.type
-WHERE schedule_enabled
+WHERE schedule_enabled AND rs.schedule IS NULL AND rst.enabled
+    AND NOT EXISTS (SELECT 1 FROM mergestat.repo_sync_pauses p WHERE p.repo_id = rs.repo_id)
     AND id NOT IN (SELECT repo_sync_id FROM mergestat.repo_sync_queue WHERE status = 'RUNNING' OR status = 'QUEUED')
     AND NOT EXISTS (
         SELECT rq.done_at
@@ -186,11 +374,143 @@ ORDER BY rs.priority, rs.sync_type desc
 // We use a CTE here to retrieve all the repo_sync_jobs that were previously enqueued, to make sure that we *do not* re-enqueue anything new until the previously enqueued jobs are *completed*.
 // This allows us to make sure all repo syncs complete before we reschedule a new batch.
 // We have now also added a concept of type groups which allows us to apply this same logic but by each group type which is where the PARTITION BY clause comes into play
+// Syncs with a schedule of their own are left out, they're enqueued on their schedule instead (see EnqueueScheduledSync).
 func (q *Queries) EnqueueAllSyncs(ctx context.Context) error {
 	_, err := q.db.Exec(ctx, enqueueAllSyncs)
 	return err
 }
 
+const enqueueRepoSyncs = `-- name: EnqueueRepoSyncs :many
+WITH syncs AS (
+    SELECT rs.id, rs.sync_type, rs.priority, rst.type_group
+    FROM mergestat.repo_syncs rs
+    INNER JOIN public.repos r ON r.id = rs.repo_id
+    INNER JOIN mergestat.repo_sync_types AS rst ON rs.sync_type = rst.type
+    WHERE regexp_replace(lower(r.repo), '(\.git)?/*$', '') = regexp_replace(lower($1::TEXT), '(\.git)?/*$', '')
+        AND rs.sync_type = ANY($2::TEXT[]) AND rs.schedule_enabled AND rst.enabled
+        AND NOT EXISTS (SELECT 1 FROM mergestat.repo_sync_pauses p WHERE p.repo_id = rs.repo_id)
+        AND rs.id NOT IN (SELECT repo_sync_id FROM mergestat.repo_sync_queue WHERE status = 'RUNNING' OR status = 'QUEUED')
+), queued AS (
+    INSERT INTO mergestat.repo_sync_queue (repo_sync_id, status, priority, type_group)
+    SELECT id, 'QUEUED' AS status, priority, type_group FROM syncs
+    RETURNING repo_sync_id
+)
+SELECT syncs.sync_type FROM queued INNER JOIN syncs ON syncs.id = queued.repo_sync_id ORDER BY syncs.sync_type
+`
+
+type EnqueueRepoSyncsParams struct {
+	RepoUrl   string
+	SyncTypes []string
+}
+
+// enqueues the syncs of the given types of the repo with the given url (ignoring case and a .git suffix), unless they're
+// disabled, paused, or queued or running already, e.g. once a webhook reports a change to the repo. Returns the types enqueued.
+func (q *Queries) EnqueueRepoSyncs(ctx context.Context, arg EnqueueRepoSyncsParams) ([]string, error) {
+	rows, err := q.db.Query(ctx, enqueueRepoSyncs, arg.RepoUrl, arg.SyncTypes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var sync_type string
+		if err := rows.Scan(&sync_type); err != nil {
+			return nil, err
+		}
+		items = append(items, sync_type)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const enqueueScheduledSync = `-- name: EnqueueScheduledSync :execrows
+WITH scheduled AS (
+    UPDATE mergestat.repo_syncs SET next_run_at = $1::TIMESTAMPTZ
+    WHERE id = $2 AND next_run_at IS NOT DISTINCT FROM $3
+    RETURNING id, sync_type, priority
+)
+INSERT INTO mergestat.repo_sync_queue (repo_sync_id, status, priority, type_group)
+SELECT
+    scheduled.id,
+    'QUEUED' AS status,
+    scheduled.priority,
+    rst.type_group
+FROM scheduled
+INNER JOIN mergestat.repo_sync_types AS rst ON scheduled.sync_type = rst.type
+WHERE scheduled.id NOT IN (SELECT repo_sync_id FROM mergestat.repo_sync_queue WHERE status = 'RUNNING' OR status = 'QUEUED')
+`
+
+type EnqueueScheduledSyncParams struct {
+	NextRunAt     time.Time
+	ID            uuid.UUID
+	PreviousRunAt sql.NullTime
+}
+
+// enqueues a due sync with a schedule (unless it's queued or running already) and moves it to its next run. A sync whose
+// next run changed since it was listed (e.g. because another scheduler got to it first) is left alone. Returns 1 if it was enqueued.
+func (q *Queries) EnqueueScheduledSync(ctx context.Context, arg EnqueueScheduledSyncParams) (int64, error) {
+	result, err := q.db.Exec(ctx, enqueueScheduledSync, arg.NextRunAt, arg.ID, arg.PreviousRunAt)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const estimateRepoSize = `-- name: EstimateRepoSize :one
+SELECT COALESCE(
+    (SELECT MAX(rsq.peak_temp_disk_bytes) FROM mergestat.repo_sync_queue rsq
+        INNER JOIN mergestat.repo_syncs rs ON rs.id = rsq.repo_sync_id
+    WHERE rs.repo_id = $1 AND rsq.peak_temp_disk_bytes > 0),
+    (SELECT gri.size::BIGINT * 1024 FROM public.github_repo_info gri WHERE gri.repo_id = $1),
+    0
+)::BIGINT AS estimate
+`
+
+// estimates the disk space (in bytes) a clone of the repo takes up, from the peak temp disk usage of its previous
+// sync jobs, or else from the size of the repo as reported by GitHub (in kilobytes). 0 if there's nothing to go by.
+func (q *Queries) EstimateRepoSize(ctx context.Context, repoID uuid.UUID) (int64, error) {
+	row := q.db.QueryRow(ctx, estimateRepoSize, repoID)
+	var estimate int64
+	err := row.Scan(&estimate)
+	return estimate, err
+}
+
+const failSyncJob = `-- name: FailSyncJob :one
+WITH failed AS (
+    UPDATE mergestat.repo_sync_queue rsq SET
+        attempts = rsq.attempts + 1,
+        last_error = $1::TEXT,
+        status = CASE WHEN rsq.attempts + 1 >= rst.max_attempts THEN 'DEAD' ELSE 'QUEUED' END,
+        -- retries back off exponentially (see mergestat.repo_sync_types.retry_backoff_seconds)
+        next_attempt_at = now() + make_interval(secs => rst.retry_backoff_seconds * power(2, LEAST(rsq.attempts, 10)))
+    FROM mergestat.repo_syncs rs, mergestat.repo_sync_types rst
+    WHERE rsq.id = $2::BIGINT AND rs.id = rsq.repo_sync_id AND rst.type = rs.sync_type
+    RETURNING rsq.id, rsq.repo_sync_id, rsq.status
+),
+completed AS (
+    UPDATE mergestat.repo_syncs SET last_completed_repo_sync_queue_id = failed.id
+    FROM failed
+    WHERE failed.status = 'DEAD' AND mergestat.repo_syncs.id = failed.repo_sync_id
+)
+SELECT status FROM failed;
+`
+
+type FailSyncJobParams struct {
+	LastError string
+	ID        int64
+}
+
+// records a failed attempt of a sync job, re-queueing it (to be retried after a backoff) unless it ran out of attempts,
+// in which case it's moved to DEAD
+func (q *Queries) FailSyncJob(ctx context.Context, arg FailSyncJobParams) (string, error) {
+	row := q.db.QueryRow(ctx, failSyncJob, arg.LastError, arg.ID)
+	var status string
+	err := row.Scan(&status)
+	return status, err
+}
+
 const fetchContainerSync = `-- name: FetchContainerSync :one
 SELECT sync.id, sync.repo_id,
     image.type AS image_type, image.url AS image_url, image.version AS image_version,
@@ -362,6 +682,65 @@ func (q *Queries) GetRepoUrlFromImport(ctx context.Context, importid uuid.UUID)
 	return items, nil
 }
 
+const getRepoProviderSettings = `-- name: GetRepoProviderSettings :one
+SELECT pr.settings FROM public.repos repo
+    INNER JOIN mergestat.providers pr ON pr.id = repo.provider
+WHERE repo.id = $1
+`
+
+func (q *Queries) GetRepoProviderSettings(ctx context.Context, id uuid.UUID) (pgtype.JSONB, error) {
+	row := q.db.QueryRow(ctx, getRepoProviderSettings, id)
+	var settings pgtype.JSONB
+	err := row.Scan(&settings)
+	return settings, err
+}
+
+const getRepoSyncWatermark = `-- name: GetRepoSyncWatermark :one
+SELECT watermark FROM mergestat.repo_sync_watermarks WHERE repo_sync_id = $1
+`
+
+func (q *Queries) GetRepoSyncWatermark(ctx context.Context, repoSyncID uuid.UUID) (string, error) {
+	row := q.db.QueryRow(ctx, getRepoSyncWatermark, repoSyncID)
+	var watermark string
+	err := row.Scan(&watermark)
+	return watermark, err
+}
+
+const getRepoVendor = `-- name: GetRepoVendor :one
+SELECT pr.vendor FROM public.repos repo
+    INNER JOIN mergestat.providers pr ON pr.id = repo.provider
+WHERE repo.id = $1
+`
+
+func (q *Queries) GetRepoVendor(ctx context.Context, id uuid.UUID) (string, error) {
+	row := q.db.QueryRow(ctx, getRepoVendor, id)
+	var vendor string
+	err := row.Scan(&vendor)
+	return vendor, err
+}
+
+const getSyncJobCheckpoint = `-- name: GetSyncJobCheckpoint :one
+SELECT state FROM mergestat.repo_sync_checkpoints WHERE repo_sync_queue_id = $1
+`
+
+func (q *Queries) GetSyncJobCheckpoint(ctx context.Context, repoSyncQueueID int64) (pgtype.JSONB, error) {
+	row := q.db.QueryRow(ctx, getSyncJobCheckpoint, repoSyncQueueID)
+	var state pgtype.JSONB
+	err := row.Scan(&state)
+	return state, err
+}
+
+const getSyncTypeTimeout = `-- name: GetSyncTypeTimeout :one
+SELECT timeout_seconds FROM mergestat.repo_sync_types WHERE type = $1
+`
+
+func (q *Queries) GetSyncTypeTimeout(ctx context.Context, syncType string) (sql.NullInt32, error) {
+	row := q.db.QueryRow(ctx, getSyncTypeTimeout, syncType)
+	var timeout_seconds sql.NullInt32
+	err := row.Scan(&timeout_seconds)
+	return timeout_seconds, err
+}
+
 const insertGitHubRepoInfo = `-- name: InsertGitHubRepoInfo :exec
 INSERT INTO public.github_repo_info (
     repo_id, owner, name,
@@ -369,10 +748,11 @@ INSERT INTO public.github_repo_info (
     is_archived, is_disabled, mirror_url, is_private, total_issues_count, latest_release_author,
     latest_release_created_at, latest_release_name, latest_release_published_at, license_key,
     license_name, primary_language, pushed_at, releases_count,
-    stargazers_count, updated_at, watchers_count,advanced_security,secret_scanning,secret_scanning_push_protection
+    stargazers_count, updated_at, watchers_count,advanced_security,secret_scanning,secret_scanning_push_protection,
+    topics
 ) VALUES(
     $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22,
-    $23, $24, $25, $26, $27, $28,$29
+    $23, $24, $25, $26, $27, $28,$29, $30
 )
 `
 
@@ -406,6 +786,7 @@ type InsertGitHubRepoInfoParams struct {
 	AdvancedSecurity             sql.NullString
 	SecretScanning               sql.NullString
 	SecretScanningPushProtection sql.NullString
+	Topics                       pgtype.JSONB
 }
 
 func (q *Queries) InsertGitHubRepoInfo(ctx context.Context, arg InsertGitHubRepoInfoParams) error {
@@ -439,6 +820,7 @@ func (q *Queries) InsertGitHubRepoInfo(ctx context.Context, arg InsertGitHubRepo
 		arg.AdvancedSecurity,
 		arg.SecretScanning,
 		arg.SecretScanningPushProtection,
+		arg.Topics,
 	)
 	return err
 }
@@ -476,6 +858,103 @@ func (q *Queries) InsertSyncJobLog(ctx context.Context, arg InsertSyncJobLogPara
 	return err
 }
 
+const listDueScheduledSyncs = `-- name: ListDueScheduledSyncs :many
+SELECT id, schedule, next_run_at FROM mergestat.repo_syncs
+WHERE schedule_enabled AND schedule IS NOT NULL AND (next_run_at IS NULL OR next_run_at <= now())
+    AND sync_type IN (SELECT type FROM mergestat.repo_sync_types WHERE enabled)
+    AND NOT EXISTS (SELECT 1 FROM mergestat.repo_sync_pauses p WHERE p.repo_id = repo_syncs.repo_id)
+`
+
+type ListDueScheduledSyncsRow struct {
+	ID        uuid.UUID
+	Schedule  sql.NullString
+	NextRunAt sql.NullTime
+}
+
+// lists the syncs with a schedule that are due (and not paused), a sync that was never scheduled before is due right away
+func (q *Queries) ListDueScheduledSyncs(ctx context.Context) ([]ListDueScheduledSyncsRow, error) {
+	rows, err := q.db.Query(ctx, listDueScheduledSyncs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListDueScheduledSyncsRow
+	for rows.Next() {
+		var i ListDueScheduledSyncsRow
+		if err := rows.Scan(&i.ID, &i.Schedule, &i.NextRunAt); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listFailedSyncDependencies = `-- name: ListFailedSyncDependencies :many
+SELECT d.depends_on FROM mergestat.repo_sync_type_dependencies d
+INNER JOIN mergestat.repo_syncs rs ON rs.sync_type = d.depends_on AND rs.repo_id = $1
+INNER JOIN mergestat.repo_sync_queue rsq ON rsq.id = rs.last_completed_repo_sync_queue_id
+WHERE d.sync_type = $2 AND rsq.status IN ('DEAD', 'TIMED_OUT', 'SKIPPED')
+`
+
+type ListFailedSyncDependenciesParams struct {
+	RepoID   uuid.UUID
+	SyncType string
+}
+
+// lists the sync types a sync type depends on, whose latest run for the repo failed (or was skipped itself)
+func (q *Queries) ListFailedSyncDependencies(ctx context.Context, arg ListFailedSyncDependenciesParams) ([]string, error) {
+	rows, err := q.db.Query(ctx, listFailedSyncDependencies, arg.RepoID, arg.SyncType)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []string
+	for rows.Next() {
+		var depends_on string
+		if err := rows.Scan(&depends_on); err != nil {
+			return nil, err
+		}
+		items = append(items, depends_on)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSyncJobCheckpointRows = `-- name: ListSyncJobCheckpointRows :many
+SELECT data FROM mergestat.repo_sync_checkpoint_rows WHERE repo_sync_queue_id = $1 AND kind = $2 ORDER BY id
+`
+
+type ListSyncJobCheckpointRowsParams struct {
+	RepoSyncQueueID int64
+	Kind            string
+}
+
+// lists the rows of the given kind saved along with the checkpoint of a sync job, in the order they were saved
+func (q *Queries) ListSyncJobCheckpointRows(ctx context.Context, arg ListSyncJobCheckpointRowsParams) ([]pgtype.JSONB, error) {
+	rows, err := q.db.Query(ctx, listSyncJobCheckpointRows, arg.RepoSyncQueueID, arg.Kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []pgtype.JSONB
+	for rows.Next() {
+		var data pgtype.JSONB
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		items = append(items, data)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listRepoImportsDueForImport = `-- name: ListRepoImportsDueForImport :many
 WITH dequeued AS (
     UPDATE mergestat.repo_imports SET last_import_started_at = now()
@@ -542,21 +1021,109 @@ func (q *Queries) MarkRepoImportAsUpdated(ctx context.Context, id uuid.UUID) err
 	return err
 }
 
-const markSyncsAsTimedOut = `-- name: MarkSyncsAsTimedOut :many
-WITH timed_out_sync_jobs AS (
-    UPDATE mergestat.repo_sync_queue SET status = 'DONE' WHERE status = 'RUNNING' AND (
-        (last_keep_alive < now() - '10 minutes'::interval)
+const markRepoReachable = `-- name: MarkRepoReachable :exec
+SELECT mergestat.mark_repo_reachable($1::uuid)
+`
+
+// marks a repo as REACHABLE (if it wasn't already), e.g. once one of its syncs succeeded
+func (q *Queries) MarkRepoReachable(ctx context.Context, repoID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, markRepoReachable, repoID)
+	return err
+}
+
+const notifySyncCompleted = `-- name: NotifySyncCompleted :exec
+SELECT pg_notify('mergestat_sync_completed', $1::TEXT)
+`
+
+// notifies the listeners of the mergestat_sync_completed channel of a completed sync job, with the given (JSON) event
+func (q *Queries) NotifySyncCompleted(ctx context.Context, event string) error {
+	_, err := q.db.Exec(ctx, notifySyncCompleted, event)
+	return err
+}
+
+const pauseReposMissingFromImport = `-- name: PauseReposMissingFromImport :exec
+INSERT INTO mergestat.repo_sync_pauses (repo_id, reason)
+SELECT id, $1::TEXT FROM public.repos WHERE repo_import_id = $2::uuid AND NOT(repo = ANY($3::TEXT[]))
+ON CONFLICT (repo_id) DO NOTHING
+`
+
+type PauseReposMissingFromImportParams struct {
+	Reason   string
+	ImportID uuid.UUID
+	Repos    []string
+}
+
+// pauses the syncs of the repos of an import that are no longer part of it (e.g. as a GitHub App lost access to them),
+// unless they're paused already
+func (q *Queries) PauseReposMissingFromImport(ctx context.Context, arg PauseReposMissingFromImportParams) error {
+	_, err := q.db.Exec(ctx, pauseReposMissingFromImport, arg.Reason, arg.ImportID, arg.Repos)
+	return err
+}
+
+const pruneRepoSyncQueue = `-- name: PruneRepoSyncQueue :one
+SELECT mergestat.prune_repo_sync_queue($1::INTEGER)::INTEGER
+`
+
+// prunes a batch of the finished sync jobs (and their logs) that fall outside of the retention policy of their repo
+// (see mergestat.repo_sync_retention), returning the number of jobs pruned
+func (q *Queries) PruneRepoSyncQueue(ctx context.Context, batchSize int32) (int32, error) {
+	row := q.db.QueryRow(ctx, pruneRepoSyncQueue, batchSize)
+	var column_1 int32
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const recordRepoUnreachable = `-- name: RecordRepoUnreachable :one
+SELECT mergestat.record_repo_unreachable($1::uuid, $2::TEXT, $3::INTEGER)::BOOLEAN
+`
+
+type RecordRepoUnreachableParams struct {
+	RepoID    uuid.UUID
+	Error     string
+	Threshold int32
+}
+
+// records a failure to reach a repo, returning whether it was marked as UNREACHABLE (and its syncs paused) as a result
+func (q *Queries) RecordRepoUnreachable(ctx context.Context, arg RecordRepoUnreachableParams) (bool, error) {
+	row := q.db.QueryRow(ctx, recordRepoUnreachable, arg.RepoID, arg.Error, arg.Threshold)
+	var column_1 bool
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const requeueStaleSyncJobs = `-- name: RequeueStaleSyncJobs :many
+WITH stale_sync_jobs AS (
+    UPDATE mergestat.repo_sync_queue rsq SET
+        attempts = rsq.attempts + 1,
+        last_error = 'No response from job within reasonable interval',
+        last_keep_alive = NULL,
+        status = CASE WHEN rsq.attempts + 1 >= rst.max_attempts THEN 'DEAD' ELSE 'QUEUED' END,
+        -- retries back off exponentially (see mergestat.repo_sync_types.retry_backoff_seconds)
+        next_attempt_at = now() + make_interval(secs => rst.retry_backoff_seconds * power(2, LEAST(rsq.attempts, 10)))
+    FROM mergestat.repo_syncs rs, mergestat.repo_sync_types rst
+    WHERE rsq.status = 'RUNNING' AND rs.id = rsq.repo_sync_id AND rst.type = rs.sync_type AND (
+        (rsq.last_keep_alive < now() - '10 minutes'::interval)
         OR
-        (last_keep_alive IS NULL AND started_at < now() - '10 minutes'::interval)) -- if worker crashed before last_keep_alive was first set
-    RETURNING id, created_at, repo_sync_id, status, started_at, done_at, last_keep_alive, priority, type_group
+        (rsq.last_keep_alive IS NULL AND rsq.started_at < now() - '10 minutes'::interval)) -- if worker crashed before last_keep_alive was first set
+    RETURNING rsq.id, rsq.repo_sync_id, rsq.status
+),
+completed AS (
+    UPDATE mergestat.repo_syncs SET last_completed_repo_sync_queue_id = stale_sync_jobs.id
+    FROM stale_sync_jobs
+    WHERE stale_sync_jobs.status = 'DEAD' AND mergestat.repo_syncs.id = stale_sync_jobs.repo_sync_id
 )
 INSERT INTO mergestat.repo_sync_logs (repo_sync_queue_id, log_type, message)
-SELECT id, 'ERROR', 'No response from job within reasonable interval. Timing out.' FROM timed_out_sync_jobs
+SELECT id, 'ERROR', CASE
+    WHEN status = 'DEAD' THEN 'No response from job within reasonable interval. Out of attempts.'
+    ELSE 'No response from job within reasonable interval. Requeuing.'
+END FROM stale_sync_jobs
 RETURNING repo_sync_queue_id
 `
 
-func (q *Queries) MarkSyncsAsTimedOut(ctx context.Context) ([]int64, error) {
-	rows, err := q.db.Query(ctx, markSyncsAsTimedOut)
+// requeues the running jobs whose worker stopped sending keep alives (e.g. because it crashed or was killed). This counts as a
+// failed attempt, so that a job that keeps taking its worker down is eventually moved to DEAD (see FailSyncJob).
+func (q *Queries) RequeueStaleSyncJobs(ctx context.Context) ([]int64, error) {
+	rows, err := q.db.Query(ctx, requeueStaleSyncJobs)
 	if err != nil {
 		return nil, err
 	}
@@ -575,6 +1142,23 @@ func (q *Queries) MarkSyncsAsTimedOut(ctx context.Context) ([]int64, error) {
 	return items, nil
 }
 
+const resumeReposOfImport = `-- name: ResumeReposOfImport :exec
+DELETE FROM mergestat.repo_sync_pauses p USING public.repos r
+WHERE p.repo_id = r.id AND r.repo_import_id = $1::uuid AND r.repo = ANY($2::TEXT[]) AND p.reason = $3::TEXT
+`
+
+type ResumeReposOfImportParams struct {
+	ImportID uuid.UUID
+	Repos    []string
+	Reason   string
+}
+
+// resumes the syncs of the repos of an import that were paused (for the given reason) while they weren't part of it
+func (q *Queries) ResumeReposOfImport(ctx context.Context, arg ResumeReposOfImportParams) error {
+	_, err := q.db.Exec(ctx, resumeReposOfImport, arg.ImportID, arg.Repos, arg.Reason)
+	return err
+}
+
 const setLatestKeepAliveForJob = `-- name: SetLatestKeepAliveForJob :exec
 UPDATE mergestat.repo_sync_queue SET last_keep_alive = now() WHERE id = $1
 `
@@ -584,6 +1168,32 @@ func (q *Queries) SetLatestKeepAliveForJob(ctx context.Context, id int64) error
 	return err
 }
 
+const setSyncJobResourceUsage = `-- name: SetSyncJobResourceUsage :exec
+UPDATE mergestat.repo_sync_queue SET
+    cpu_time_ms = $1::BIGINT,
+    peak_memory_bytes = $2::BIGINT,
+    peak_temp_disk_bytes = $3::BIGINT
+WHERE id = $4::BIGINT
+`
+
+type SetSyncJobResourceUsageParams struct {
+	CpuTimeMs         int64
+	PeakMemoryBytes   int64
+	PeakTempDiskBytes int64
+	ID                int64
+}
+
+// records the resources used by a sync job (see the comments on the columns of mergestat.repo_sync_queue)
+func (q *Queries) SetSyncJobResourceUsage(ctx context.Context, arg SetSyncJobResourceUsageParams) error {
+	_, err := q.db.Exec(ctx, setSyncJobResourceUsage,
+		arg.CpuTimeMs,
+		arg.PeakMemoryBytes,
+		arg.PeakTempDiskBytes,
+		arg.ID,
+	)
+	return err
+}
+
 const setSyncJobStatus = `-- name: SetSyncJobStatus :exec
 SELECT mergestat.set_sync_job_status($1::TEXT, $2::BIGINT)
 `
@@ -613,6 +1223,21 @@ func (q *Queries) UpdateImportStatus(ctx context.Context, arg UpdateImportStatus
 	return err
 }
 
+const updateRepoURL = `-- name: UpdateRepoURL :exec
+UPDATE public.repos SET repo = $1 WHERE id = $2
+`
+
+type UpdateRepoURLParams struct {
+	Repo string
+	ID   uuid.UUID
+}
+
+// updates the url of a repo (e.g. once it was renamed or transferred), keeping its id
+func (q *Queries) UpdateRepoURL(ctx context.Context, arg UpdateRepoURLParams) error {
+	_, err := q.db.Exec(ctx, updateRepoURL, arg.Repo, arg.ID)
+	return err
+}
+
 const upsertRepo = `-- name: UpsertRepo :exec
 INSERT INTO public.repos (repo, repo_import_id, provider, tags) VALUES($1, $2, $3, $4)
 ON CONFLICT (repo, (ref IS NULL)) WHERE ref IS NULL
@@ -637,6 +1262,36 @@ func (q *Queries) UpsertRepo(ctx context.Context, arg UpsertRepoParams) error {
 	return err
 }
 
+const upsertRepoSyncWatermark = `-- name: UpsertRepoSyncWatermark :exec
+INSERT INTO mergestat.repo_sync_watermarks (repo_sync_id, watermark) VALUES ($1, $2)
+ON CONFLICT (repo_sync_id) DO UPDATE SET watermark = excluded.watermark, updated_at = now()
+`
+
+type UpsertRepoSyncWatermarkParams struct {
+	RepoSyncID uuid.UUID
+	Watermark  string
+}
+
+func (q *Queries) UpsertRepoSyncWatermark(ctx context.Context, arg UpsertRepoSyncWatermarkParams) error {
+	_, err := q.db.Exec(ctx, upsertRepoSyncWatermark, arg.RepoSyncID, arg.Watermark)
+	return err
+}
+
+const upsertSyncJobCheckpoint = `-- name: UpsertSyncJobCheckpoint :exec
+INSERT INTO mergestat.repo_sync_checkpoints (repo_sync_queue_id, state) VALUES ($1, $2)
+ON CONFLICT (repo_sync_queue_id) DO UPDATE SET state = excluded.state, updated_at = now()
+`
+
+type UpsertSyncJobCheckpointParams struct {
+	RepoSyncQueueID int64
+	State           pgtype.JSONB
+}
+
+func (q *Queries) UpsertSyncJobCheckpoint(ctx context.Context, arg UpsertSyncJobCheckpointParams) error {
+	_, err := q.db.Exec(ctx, upsertSyncJobCheckpoint, arg.RepoSyncQueueID, arg.State)
+	return err
+}
+
 const upsertWorkflowRunJobs = `-- name: UpsertWorkflowRunJobs :exec
 WITH t AS (
 	INSERT INTO public.github_actions_workflow_run_jobs (
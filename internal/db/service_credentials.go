@@ -5,25 +5,158 @@ import (
 	"database/sql"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/envelope"
+	"github.com/mergestat/mergestat/internal/githubapp"
+	"github.com/mergestat/mergestat/internal/secrets"
 	"github.com/pkg/errors"
 	"os"
 )
 
-// FetchCredential fetches service credential for the given provider and type.
+// defaultTenant is the tenant of the providers of a deployment that isn't shared by several tenants
+const defaultTenant = "default"
+
+// FetchCredential fetches service credential for the given provider, preferring its credentials that aren't assigned to
+// any specific repo or org. For a GitHub App credential, a short-lived installation access token is minted and returned instead,
+// and for a credential referencing an external secrets backend (such as Vault), the secret is fetched from that backend.
 func (q *Queries) FetchCredential(ctx context.Context, provider uuid.UUID) (_, _ string, err error) {
+	return q.fetchCredential(ctx, provider, uuid.NullUUID{})
+}
+
+// FetchRepoCredential fetches service credential for the given repo of the provider, preferring the credentials assigned
+// to the repo, then the ones assigned to its org. The default credential is used, unless the provider rotates its
+// credentials (see mergestat.providers.rotate_credentials), in which case the least recently used one is.
+func (q *Queries) FetchRepoCredential(ctx context.Context, provider, repo uuid.UUID) (_, _ string, err error) {
+	return q.fetchCredential(ctx, provider, uuid.NullUUID{UUID: repo, Valid: true})
+}
+
+func (q *Queries) fetchCredential(ctx context.Context, provider uuid.UUID, repo uuid.NullUUID) (_, _ string, err error) {
 	var secret = os.Getenv("ENCRYPTION_SECRET")
-	var username, credential sql.NullString
+	var username, credential, credentialType, baseURL, tenant sql.NullString
+	var encryptedKey, encryptedUsername, encryptedCredential []byte
 
-	const query = "SELECT username, token FROM mergestat.fetch_service_auth_credential($1, NULL, $2)"
-	var row = q.db.QueryRow(ctx, query, provider, secret)
-	if err = row.Scan(&username, &credential); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+	const query = `
+		SELECT c.username, c.token, c.encrypted_key, c.encrypted_username, c.encrypted_token, c.type, p.settings->>'url', p.tenant
+			FROM mergestat.providers p LEFT JOIN LATERAL mergestat.next_service_auth_credential($1, $2, $3) c ON TRUE
+		WHERE p.id = $1`
+	var row = q.db.QueryRow(ctx, query, provider, repo, secret)
+	if err = row.Scan(&username, &credential, &encryptedKey, &encryptedUsername, &encryptedCredential, &credentialType, &baseURL, &tenant); err != nil && !errors.Is(err, pgx.ErrNoRows) {
 		return "", "", err
 	}
 
-	if !credential.Valid {
-		// default to the `GITHUB_TOKEN` env var if nothing in the DB
+	// credential is encrypted with the worker-held key, and is decrypted here (in memory)
+	if encryptedKey != nil {
+		if username, credential, err = openCredential(encryptedKey, encryptedUsername, encryptedCredential); err != nil {
+			return "", "", err
+		}
+	}
+
+	// default to the `GITHUB_TOKEN` env var if nothing in the DB, unless the provider belongs to a tenant (other than the
+	// default one), as the token of the deployment isn't one of the tenant's credentials
+	if !credential.Valid && (!tenant.Valid || tenant.String == defaultTenant) {
 		credential.String = os.Getenv("GITHUB_TOKEN")
 	}
 
+	// credential only references a secret in an external secrets backend, which is fetched now
+	var backend secrets.Backend
+	if backend, err = secrets.ForType(credentialType.String); err != nil {
+		return "", "", err
+	} else if backend != nil {
+		var secret *secrets.Secret
+		if secret, err = backend.Fetch(ctx, credential.String); err != nil {
+			return "", "", err
+		}
+		return secret.Username, secret.Token, nil
+	}
+
+	if credentialType.String == "GITHUB_APP" {
+		var app *githubapp.Credential
+		if app, err = githubapp.Parse(credential.String); err != nil {
+			return "", "", err
+		}
+
+		var token string
+		if token, err = app.Token(ctx, baseURL.String); err != nil {
+			return "", "", err
+		}
+
+		return githubapp.Username, token, nil
+	}
+
 	return username.String, credential.String, nil
 }
+
+// openCredential decrypts a credential (and its username) encrypted with the worker-held key
+func openCredential(encryptedKey, encryptedUsername, encryptedCredential []byte) (username, credential sql.NullString, err error) {
+	var keyring envelope.Keyring
+	if keyring, err = envelope.FromEnv(); err != nil {
+		return username, credential, err
+	} else if keyring == nil {
+		return username, credential, errors.Errorf("credential is encrypted with a worker-held key, but neither %s nor %s is set", envelope.KeyEnv, envelope.KMSKeyEnv)
+	}
+
+	var values [][]byte
+	if values, err = envelope.Open(keyring, encryptedKey, encryptedUsername, encryptedCredential); err != nil {
+		return username, credential, errors.Wrapf(err, "failed to decrypt credential")
+	}
+
+	username = sql.NullString{String: string(values[0]), Valid: values[0] != nil}
+	credential = sql.NullString{String: string(values[1]), Valid: values[1] != nil}
+	return username, credential, nil
+}
+
+// SealCredentials re-encrypts the credentials that are encrypted with pgp_sym_encrypt (i.e. the ones added through the app)
+// with the worker-held key, after which they can only be decrypted by the worker. It returns the number of credentials sealed.
+func (q *Queries) SealCredentials(ctx context.Context, keyring envelope.Keyring) (sealed int, err error) {
+	var secret = os.Getenv("ENCRYPTION_SECRET")
+
+	const query = `
+		SELECT id, pgp_sym_decrypt(username, $1), pgp_sym_decrypt(credentials, $1)
+			FROM mergestat.service_auth_credentials WHERE encrypted_key IS NULL`
+	rows, err := q.db.Query(ctx, query, secret)
+	if err != nil {
+		return 0, err
+	}
+
+	type plaintext struct {
+		id                   uuid.UUID
+		username, credential sql.NullString
+	}
+
+	var credentials []plaintext
+	for rows.Next() {
+		var c plaintext
+		if err = rows.Scan(&c.id, &c.username, &c.credential); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		credentials = append(credentials, c)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	// only update credentials that weren't sealed (by another worker) in the meantime
+	const update = `
+		UPDATE mergestat.service_auth_credentials SET encrypted_key = $2, username = $3, credentials = $4
+			WHERE id = $1 AND encrypted_key IS NULL`
+	for _, c := range credentials {
+		var username []byte
+		if c.username.Valid {
+			username = []byte(c.username.String)
+		}
+
+		var encryptedKey []byte
+		var values [][]byte
+		if encryptedKey, values, err = envelope.Seal(keyring, username, []byte(c.credential.String)); err != nil {
+			return sealed, errors.Wrapf(err, "failed to encrypt credential")
+		}
+
+		if _, err = q.db.Exec(ctx, update, c.id, encryptedKey, values[0], values[1]); err != nil {
+			return sealed, err
+		}
+		sealed++
+	}
+
+	return sealed, nil
+}
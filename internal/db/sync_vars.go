@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
 	"os"
@@ -39,3 +40,20 @@ WHERE sync.id = $1 AND vars.repo_id = sync.repo_id`
 
 	return result, nil
 }
+
+// FetchRepoSyncVar fetches (and decrypts) the variable with the given key of the given repo, returning false if the
+// repo has no such variable.
+func (q *Queries) FetchRepoSyncVar(ctx context.Context, repo uuid.UUID, key string) (_ string, _ bool, err error) {
+	var secret = os.Getenv("ENCRYPTION_SECRET")
+	var value sql.NullString
+
+	const query = `SELECT value FROM mergestat.fetch_sync_variable($1, $2, $3)`
+	if err = q.db.QueryRow(ctx, query, repo, key, secret).Scan(&value); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+
+	return value.String, true, nil
+}
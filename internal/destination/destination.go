@@ -0,0 +1,46 @@
+// Package destination defines the stores the rows written by syncs are sent to besides Postgres (or instead of it),
+// e.g. an event sink (see package sink), files in a data lake (see package export) or ClickHouse (see package clickhouse).
+package destination
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Batch are the rows copied into a table by a sync of a repo, sent to a destination once they're committed
+type Batch struct {
+	JobID    int64
+	RepoID   uuid.UUID
+	Repo     string
+	SyncType string
+	Table    string
+	Columns  []string
+	Rows     [][]interface{} // the values of each row, in the order of Columns, as they were copied
+}
+
+// Destination is a store the rows written by syncs are sent to
+type Destination interface {
+	// Name identifies the destination, e.g. in the errors of a sync
+	Name() string
+
+	// Send sends the given batch to the destination, returning once it's stored. Destinations that keep a snapshot of the
+	// synced data (rather than a stream of it) replace the rows sent by previous syncs of the repo to the same table.
+	Send(ctx context.Context, batch *Batch) error
+}
+
+// Normalize returns the given (copied) value as one of nil, int64, float64, bool, []byte, string or time.Time, i.e.
+// the value of a driver.Valuer (e.g. a uuid or a sql.NullString), or of a pointer. Any other value is encoded as JSON.
+func Normalize(v interface{}) interface{} {
+	value, err := driver.DefaultParameterConverter.ConvertValue(v)
+	if err != nil {
+		if encoded, err := json.Marshal(v); err == nil {
+			return string(encoded)
+		}
+		return fmt.Sprint(v)
+	}
+	return value
+}
@@ -0,0 +1,158 @@
+// Package envelope implements envelope encryption of credentials, using a key held by the worker (and never stored in,
+// or sent to, the database). Every credential is encrypted with its own random data key, which is in turn encrypted
+// (wrapped) with the worker-held key and stored alongside the credential. Credentials are only ever decrypted in memory.
+//
+// The worker-held key is either a local key (CREDENTIALS_ENCRYPTION_KEY) or an AWS KMS key (CREDENTIALS_KMS_KEY_ID).
+// Data keys can only be unwrapped by the key that wrapped them, so credentials have to be added again after switching.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// KeyEnv is the env var holding the (base64 encoded, 32 byte) worker-held key
+const KeyEnv = "CREDENTIALS_ENCRYPTION_KEY"
+
+// Keyring wraps and unwraps data keys with a key encryption key, either a local one or one held by a KMS
+// (which never hands out the key encryption key itself).
+type Keyring interface {
+	Wrap(dataKey []byte) ([]byte, error)
+	Unwrap(wrapped []byte) ([]byte, error)
+}
+
+// FromEnv returns a Keyring using the key in the CREDENTIALS_ENCRYPTION_KEY env var, or the AWS KMS key in the
+// CREDENTIALS_KMS_KEY_ID env var, or nil if neither is set
+func FromEnv() (Keyring, error) {
+	var encoded, kmsKeyID = os.Getenv(KeyEnv), os.Getenv(KMSKeyEnv)
+	if encoded != "" && kmsKeyID != "" {
+		return nil, errors.Errorf("only one of %s and %s can be set", KeyEnv, KMSKeyEnv)
+	}
+
+	if kmsKeyID != "" {
+		k, err := newKMSKey(kmsKeyID)
+		if err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+
+	if encoded == "" {
+		return nil, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", KeyEnv)
+	}
+
+	if len(key) != 32 {
+		return nil, errors.Errorf("%s must be a base64 encoded 32 byte key", KeyEnv)
+	}
+
+	return localKey(key), nil
+}
+
+// localKey is a Keyring using an AES-256 key held in memory
+type localKey []byte
+
+func (k localKey) Wrap(dataKey []byte) ([]byte, error) { return encrypt(k, dataKey) }
+
+func (k localKey) Unwrap(wrapped []byte) ([]byte, error) { return decrypt(k, wrapped) }
+
+// Seal encrypts the given values with a new data key, and returns the wrapped data key along with the encrypted values.
+// A nil value is left as is.
+func Seal(k Keyring, values ...[]byte) (wrappedKey []byte, sealed [][]byte, err error) {
+	var dataKey = make([]byte, 32)
+	if _, err = io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, nil, err
+	}
+
+	sealed = make([][]byte, len(values))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+
+		if sealed[i], err = encrypt(dataKey, value); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if wrappedKey, err = k.Wrap(dataKey); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to wrap data key")
+	}
+
+	return wrappedKey, sealed, nil
+}
+
+// Open decrypts the values sealed with the given wrapped data key. A nil value is left as is.
+func Open(k Keyring, wrappedKey []byte, sealed ...[]byte) (values [][]byte, err error) {
+	var dataKey []byte
+	if dataKey, err = k.Unwrap(wrappedKey); err != nil {
+		return nil, errors.Wrapf(err, "failed to unwrap data key")
+	}
+
+	values = make([][]byte, len(sealed))
+	for i, value := range sealed {
+		if value == nil {
+			continue
+		}
+
+		if values[i], err = decrypt(dataKey, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return values, nil
+}
+
+// encrypt encrypts plaintext using AES-GCM, prefixing the ciphertext with its random nonce
+func encrypt(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var nonce = make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decrypt decrypts ciphertext produced by encrypt
+func decrypt(key, ciphertext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	var nonce, data = ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	// opened into a non-nil slice, so that an empty value isn't mistaken for a nil one
+	plaintext, err := aead.Open([]byte{}, nonce, data, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decrypt")
+	}
+
+	return plaintext, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
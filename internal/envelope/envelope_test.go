@@ -0,0 +1,228 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newLocalKey(t *testing.T) localKey {
+	var key = make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return localKey(key)
+}
+
+func TestSealOpen(t *testing.T) {
+	var k = newLocalKey(t)
+
+	wrappedKey, sealed, err := Seal(k, []byte("user"), nil, []byte("token"), []byte{})
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	if len(sealed) != 4 {
+		t.Fatalf("Seal() sealed %d values, want 4", len(sealed))
+	}
+	if sealed[1] != nil {
+		t.Errorf("Seal() sealed a nil value to %v, want nil", sealed[1])
+	}
+	if bytes.Contains(sealed[2], []byte("token")) {
+		t.Errorf("Seal() sealed value contains its plaintext")
+	}
+
+	values, err := Open(k, wrappedKey, sealed...)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	var want = [][]byte{[]byte("user"), nil, []byte("token"), {}}
+	for i := range want {
+		if !bytes.Equal(values[i], want[i]) || (values[i] == nil) != (want[i] == nil) {
+			t.Errorf("Open() value %d = %q, want %q", i, values[i], want[i])
+		}
+	}
+}
+
+func TestSealUsesNewDataKeys(t *testing.T) {
+	var k = newLocalKey(t)
+
+	firstKey, first, err := Seal(k, []byte("token"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+	secondKey, second, err := Seal(k, []byte("token"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	if bytes.Equal(firstKey, secondKey) || bytes.Equal(first[0], second[0]) {
+		t.Errorf("Seal() sealed the same value twice to the same ciphertext or data key")
+	}
+}
+
+func TestOpenTampered(t *testing.T) {
+	var k = newLocalKey(t)
+
+	wrappedKey, sealed, err := Seal(k, []byte("token"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	var flip = func(b []byte, i int) []byte {
+		var c = append([]byte(nil), b...)
+		c[i] ^= 0x01
+		return c
+	}
+
+	var tests = []struct {
+		name       string
+		keyring    Keyring
+		wrappedKey []byte
+		sealed     []byte
+	}{
+		{name: "tampered nonce", keyring: k, wrappedKey: wrappedKey, sealed: flip(sealed[0], 0)},
+		{name: "tampered ciphertext", keyring: k, wrappedKey: wrappedKey, sealed: flip(sealed[0], 12)},
+		{name: "tampered tag", keyring: k, wrappedKey: wrappedKey, sealed: flip(sealed[0], len(sealed[0])-1)},
+		{name: "truncated ciphertext", keyring: k, wrappedKey: wrappedKey, sealed: sealed[0][:8]},
+		{name: "tampered wrapped key", keyring: k, wrappedKey: flip(wrappedKey, 20), sealed: sealed[0]},
+		{name: "wrong key", keyring: newLocalKey(t), wrappedKey: wrappedKey, sealed: sealed[0]},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if values, err := Open(test.keyring, test.wrappedKey, test.sealed); err == nil {
+				t.Errorf("Open() = %q, want an error", values)
+			}
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	var key = base64.StdEncoding.EncodeToString(make([]byte, 32))
+
+	var tests = []struct {
+		name     string
+		key      string
+		kmsKeyID string
+		wantNil  bool
+		wantErr  bool
+	}{
+		{name: "unset", wantNil: true},
+		{name: "local key", key: key},
+		{name: "kms key", kmsKeyID: "arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"},
+		{name: "not base64", key: "not a key!", wantErr: true},
+		{name: "short key", key: base64.StdEncoding.EncodeToString(make([]byte, 16)), wantErr: true},
+		{name: "both keys", key: key, kmsKeyID: "alias/mergestat", wantErr: true},
+		{name: "kms key without region", kmsKeyID: "alias/mergestat", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(KeyEnv, test.key)
+			t.Setenv(KMSKeyEnv, test.kmsKeyID)
+			t.Setenv("AWS_REGION", "")
+			t.Setenv("AWS_DEFAULT_REGION", "")
+			t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+			t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+
+			k, err := FromEnv()
+			if (err != nil) != test.wantErr {
+				t.Fatalf("FromEnv() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && (k == nil) != test.wantNil {
+				t.Errorf("FromEnv() = %v, wantNil %v", k, test.wantNil)
+			}
+		})
+	}
+}
+
+// fakeKMS is a KMS endpoint "wrapping" data keys with a local key, and checking the requests are signed
+func fakeKMS(t *testing.T, keyID string) *httptest.Server {
+	var k = newLocalKey(t)
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") ||
+			!strings.Contains(r.Header.Get("Authorization"), "/us-east-1/kms/aws4_request") {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+
+		var req struct {
+			KeyId          string
+			Plaintext      []byte
+			CiphertextBlob []byte
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+
+		if req.KeyId != keyID {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"__type":"IncorrectKeyException","message":"the key ID doesn't match"}`))
+			return
+		}
+
+		var resp = make(map[string]interface{})
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			wrapped, _ := encrypt(k, req.Plaintext)
+			resp["CiphertextBlob"], resp["KeyId"] = wrapped, keyID
+		case "TrentService.Decrypt":
+			dataKey, err := decrypt(k, req.CiphertextBlob)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"__type":"InvalidCiphertextException"}`))
+				return
+			}
+			resp["Plaintext"], resp["KeyId"] = dataKey, keyID
+		default:
+			t.Errorf("unexpected X-Amz-Target %q", r.Header.Get("X-Amz-Target"))
+		}
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+}
+
+func TestKMSKey(t *testing.T) {
+	const keyID = "alias/mergestat"
+	var server = fakeKMS(t, keyID)
+	defer server.Close()
+
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+
+	k, err := newKMSKey(keyID)
+	if err != nil {
+		t.Fatalf("newKMSKey() error = %v", err)
+	}
+	k.endpoint = server.URL
+
+	wrappedKey, sealed, err := Seal(k, []byte("token"))
+	if err != nil {
+		t.Fatalf("Seal() error = %v", err)
+	}
+
+	values, err := Open(k, wrappedKey, sealed...)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if string(values[0]) != "token" {
+		t.Errorf("Open() = %q, want %q", values[0], "token")
+	}
+
+	if _, err = Open(k, append([]byte{0}, wrappedKey...), sealed...); err == nil {
+		t.Errorf("Open() of a tampered wrapped key, want an error")
+	}
+
+	var other = *k
+	other.keyID = "alias/other"
+	if _, err = Open(&other, wrappedKey, sealed...); err == nil {
+		t.Errorf("Open() with another KMS key, want an error")
+	}
+}
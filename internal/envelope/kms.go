@@ -0,0 +1,114 @@
+package envelope
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mergestat/mergestat/internal/sigv4"
+	"github.com/pkg/errors"
+)
+
+// KMSKeyEnv is the env var holding the id, ARN or alias (e.g. alias/mergestat) of the AWS KMS key wrapping the data keys
+const KMSKeyEnv = "CREDENTIALS_KMS_KEY_ID"
+
+// kmsKey is a Keyring wrapping data keys with a symmetric AWS KMS key, which never leaves KMS.
+// Its requests are signed with AWS Signature Version 4, using the standard AWS env vars.
+type kmsKey struct {
+	keyID    string
+	region   string
+	endpoint string // the KMS endpoint of the region, overridden in tests
+	creds    sigv4.Credentials
+	client   *http.Client
+}
+
+// newKMSKey configures a Keyring using the given KMS key, in the region of its ARN (or the configured region)
+func newKMSKey(keyID string) (*kmsKey, error) {
+	creds, err := sigv4.CredentialsFromEnv()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to configure %s", KMSKeyEnv)
+	}
+
+	var region = sigv4.RegionOfARN(keyID)
+	if region == "" {
+		region = sigv4.RegionFromEnv()
+	}
+
+	if region == "" {
+		return nil, errors.Errorf("AWS_REGION must be set to use %s (unless it's an ARN)", KMSKeyEnv)
+	}
+
+	return &kmsKey{
+		keyID:    keyID,
+		region:   region,
+		endpoint: fmt.Sprintf("https://kms.%s.amazonaws.com/", region),
+		creds:    creds,
+		client:   &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (k *kmsKey) Wrap(dataKey []byte) ([]byte, error) {
+	var resp struct {
+		CiphertextBlob []byte `json:"CiphertextBlob"`
+	}
+
+	var req = map[string]interface{}{"KeyId": k.keyID, "Plaintext": dataKey}
+	if err := k.call("TrentService.Encrypt", req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.CiphertextBlob, nil
+}
+
+func (k *kmsKey) Unwrap(wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte `json:"Plaintext"`
+	}
+
+	// the key is passed along, so that a data key wrapped by some other KMS key is rejected
+	var req = map[string]interface{}{"KeyId": k.keyID, "CiphertextBlob": wrapped}
+	if err := k.call("TrentService.Decrypt", req, &resp); err != nil {
+		return nil, err
+	}
+
+	return resp.Plaintext, nil
+}
+
+// call invokes the given KMS action, decoding its response into resp
+func (k *kmsKey) call(target string, req, resp interface{}) error {
+	var payload, err = json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	r, err := http.NewRequest(http.MethodPost, k.endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	r.Header.Set("X-Amz-Target", target)
+	sigv4.Sign(r, payload, k.creds, "kms", k.region, time.Now())
+
+	res, err := k.client.Do(r)
+	if err != nil {
+		return errors.Wrapf(err, "failed to call AWS KMS")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		var kmsErr struct {
+			Type    string `json:"__type"`
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(res.Body).Decode(&kmsErr)
+		return errors.Errorf("AWS KMS %s failed: unexpected status %s %s %s", target, res.Status, kmsErr.Type, kmsErr.Message)
+	}
+
+	if err = json.NewDecoder(res.Body).Decode(resp); err != nil {
+		return errors.Wrapf(err, "failed to decode AWS KMS response")
+	}
+
+	return nil
+}
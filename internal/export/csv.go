@@ -0,0 +1,51 @@
+package export
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// writeCSV writes the given (normalized) rows as CSV, with a header of the column names. Null values are left empty.
+func writeCSV(w io.Writer, columns []string, rows [][]interface{}) error {
+	var cw = csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+
+	var record = make([]string, len(columns))
+	for _, row := range rows {
+		for i, v := range row {
+			record[i] = csvValue(v)
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	default:
+		return ""
+	}
+}
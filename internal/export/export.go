@@ -0,0 +1,100 @@
+// Package export writes the rows written by syncs as Parquet (or CSV) files, to a local dir or an S3 bucket, for data lakes
+// queried with e.g. Athena or DuckDB. The rows a sync writes to a table for a repo are exported to a file of their own,
+// at <table>/repo_id=<repo id>/data.<format>, which is replaced by every sync so that the files mirror the synced data.
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mergestat/mergestat/internal/destination"
+)
+
+// store stores the exported files
+type store interface {
+	put(ctx context.Context, key string, body []byte) error
+}
+
+// Exporter exports the rows of syncs in the configured format, to the configured destination
+type Exporter struct {
+	format string
+	store  store
+}
+
+// New returns an exporter writing files in the given format (parquet or csv) to the given destination, either a local
+// dir or an s3://bucket/prefix url (using the standard AWS_* env vars for credentials, see newS3FromEnv)
+func New(destination, format string) (_ *Exporter, err error) {
+	if format != "parquet" && format != "csv" {
+		return nil, fmt.Errorf("unknown export format: %s", format)
+	}
+
+	var exp = &Exporter{format: format}
+	if strings.HasPrefix(destination, "s3://") {
+		if exp.store, err = newS3FromEnv(destination); err != nil {
+			return nil, err
+		}
+	} else {
+		exp.store = localDir(destination)
+	}
+
+	return exp, nil
+}
+
+// Name implements destination.Destination
+func (exp *Exporter) Name() string { return "export" }
+
+// Send exports the rows of the given batch, replacing any rows of the repo exported to the same table before
+func (exp *Exporter) Send(ctx context.Context, batch *destination.Batch) (err error) {
+	var normalized = make([][]interface{}, len(batch.Rows))
+	for i, row := range batch.Rows {
+		normalized[i] = make([]interface{}, len(row))
+		for j, v := range row {
+			normalized[i][j] = destination.Normalize(v)
+		}
+	}
+
+	var body bytes.Buffer
+	switch exp.format {
+	case "parquet":
+		err = writeParquet(&body, batch.Table, batch.Columns, normalized)
+	case "csv":
+		err = writeCSV(&body, batch.Columns, normalized)
+	}
+	if err != nil {
+		return fmt.Errorf("encode rows of %s: %w", batch.Table, err)
+	}
+
+	var key = fmt.Sprintf("%s/repo_id=%s/data.%s", batch.Table, batch.RepoID, exp.format)
+	return exp.store.put(ctx, key, body.Bytes())
+}
+
+// localDir is a store writing files under a local dir
+type localDir string
+
+func (dir localDir) put(_ context.Context, key string, body []byte) error {
+	var path = filepath.Join(string(dir), filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temp file first, so that readers never see a partially written file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(body); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,124 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/segmentio/parquet-go"
+)
+
+// writeParquet writes the given (normalized) rows as Parquet. The type of each (optional) column is inferred from its
+// values, and columns with values of mixed types (or only null values) are written as strings.
+func writeParquet(w io.Writer, table string, columns []string, rows [][]interface{}) error {
+	var kinds = make([]interface{}, len(columns)) // a zero value of the type of each column, nil for strings
+	for i := range columns {
+		kinds[i] = columnKind(rows, i)
+	}
+
+	var group = make(parquet.Group, len(columns))
+	for i, column := range columns {
+		group[column] = parquet.Optional(parquetNode(kinds[i]))
+	}
+	var schema = parquet.NewSchema(table, group)
+
+	// the columns of a schema are sorted by name, values are written in the order of the schema
+	var index = make(map[string]int, len(columns))
+	for i, column := range columns {
+		index[column] = i
+	}
+	var fields = schema.Fields()
+
+	var writer = parquet.NewWriter(w, schema, parquet.Compression(&parquet.Snappy))
+	var batch = make([]parquet.Row, 0, len(rows))
+	for _, row := range rows {
+		var values = make(parquet.Row, len(fields))
+		for f, field := range fields {
+			var i = index[field.Name()]
+			if row[i] == nil {
+				values[f] = parquet.NullValue().Level(0, 0, f)
+			} else {
+				values[f] = parquetValue(kinds[i], row[i]).Level(0, 1, f)
+			}
+		}
+		batch = append(batch, values)
+	}
+
+	if _, err := writer.WriteRows(batch); err != nil {
+		_ = writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+// columnKind returns a zero value of the type of the values of the i-th column, or nil if it's to be written as strings
+func columnKind(rows [][]interface{}, i int) (kind interface{}) {
+	for _, row := range rows {
+		if row[i] == nil {
+			continue
+		}
+		if kind == nil {
+			kind = zeroOf(row[i])
+		} else if fmt.Sprintf("%T", kind) != fmt.Sprintf("%T", row[i]) {
+			return nil
+		}
+	}
+	if _, ok := kind.(string); ok {
+		return nil
+	}
+	return kind
+}
+
+func zeroOf(v interface{}) interface{} {
+	switch v.(type) {
+	case int64:
+		return int64(0)
+	case float64:
+		return float64(0)
+	case bool:
+		return false
+	case []byte:
+		return []byte(nil)
+	case time.Time:
+		return time.Time{}
+	default:
+		return ""
+	}
+}
+
+func parquetNode(kind interface{}) parquet.Node {
+	switch kind.(type) {
+	case int64:
+		return parquet.Int(64)
+	case float64:
+		return parquet.Leaf(parquet.DoubleType)
+	case bool:
+		return parquet.Leaf(parquet.BooleanType)
+	case []byte:
+		return parquet.Leaf(parquet.ByteArrayType)
+	case time.Time:
+		return parquet.Timestamp(parquet.Microsecond)
+	default:
+		return parquet.String()
+	}
+}
+
+func parquetValue(kind, v interface{}) parquet.Value {
+	switch kind.(type) {
+	case int64:
+		return parquet.Int64Value(v.(int64))
+	case float64:
+		return parquet.DoubleValue(v.(float64))
+	case bool:
+		return parquet.BooleanValue(v.(bool))
+	case []byte:
+		return parquet.ByteArrayValue(v.([]byte))
+	case time.Time:
+		return parquet.Int64Value(v.(time.Time).UnixMicro())
+	default:
+		if s, ok := v.(string); ok {
+			return parquet.ByteArrayValue([]byte(s))
+		}
+		return parquet.ByteArrayValue([]byte(csvValue(v)))
+	}
+}
@@ -0,0 +1,159 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// s3 is a store writing files to an S3 bucket (under a prefix), signing its requests with AWS Signature Version 4
+type s3 struct {
+	bucket, prefix                             string
+	region, endpoint                           string
+	accessKeyID, secretAccessKey, sessionToken string
+}
+
+// newS3FromEnv configures an S3 store for the given s3://bucket/prefix url, using the standard AWS_REGION (or
+// AWS_DEFAULT_REGION), AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and (optional) AWS_SESSION_TOKEN env vars. If set,
+// AWS_ENDPOINT_URL_S3 points to an S3-compatible service (e.g. MinIO) instead, whose buckets are addressed by path.
+func newS3FromEnv(destination string) (*s3, error) {
+	u, err := url.Parse(destination)
+	if err != nil || u.Host == "" {
+		return nil, errors.Errorf("invalid S3 export destination: %s", destination)
+	}
+
+	var dest = &s3{
+		bucket:          u.Host,
+		prefix:          strings.Trim(u.Path, "/"),
+		region:          os.Getenv("AWS_REGION"),
+		endpoint:        strings.TrimSuffix(os.Getenv("AWS_ENDPOINT_URL_S3"), "/"),
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if dest.region == "" {
+		dest.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+
+	if dest.region == "" {
+		return nil, errors.New("AWS_REGION must be set to export to S3")
+	}
+
+	if dest.accessKeyID == "" || dest.secretAccessKey == "" {
+		return nil, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to export to S3")
+	}
+
+	return dest, nil
+}
+
+func (dest *s3) put(ctx context.Context, key string, body []byte) error {
+	if dest.prefix != "" {
+		key = dest.prefix + "/" + key
+	}
+
+	var host, path = fmt.Sprintf("%s.s3.%s.amazonaws.com", dest.bucket, dest.region), "/" + key
+	var base = "https://" + host
+	if dest.endpoint != "" {
+		endpoint, err := url.Parse(dest.endpoint)
+		if err != nil {
+			return errors.Wrapf(err, "invalid AWS_ENDPOINT_URL_S3")
+		}
+		host, path, base = endpoint.Host, "/"+dest.bucket+path, dest.endpoint
+	}
+
+	var escapedPath = uriEncode(path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, base+escapedPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	dest.sign(req, host, escapedPath, body, time.Now().UTC())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to export %s to S3", key)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("failed to export %s to S3: unexpected status %s", key, resp.Status)
+	}
+
+	return nil
+}
+
+// sign signs the request using AWS Signature Version 4.
+// See: https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+func (dest *s3) sign(req *http.Request, host, path string, payload []byte, now time.Time) {
+	var amzDate, date = now.Format("20060102T150405Z"), now.Format("20060102")
+	var payloadHash = sha256.Sum256(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+	if dest.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", dest.sessionToken)
+	}
+
+	// canonical headers must be sorted by (lowercase) name
+	var headers = []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if dest.sessionToken != "" {
+		headers = append(headers, "x-amz-security-token")
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headers {
+		var value = req.Header.Get(name)
+		if name == "host" {
+			value = host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	var signedHeaders = strings.Join(headers, ";")
+
+	var canonicalRequest = strings.Join([]string{
+		req.Method, path, "", canonicalHeaders.String(), signedHeaders, hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	var scope = fmt.Sprintf("%s/%s/s3/aws4_request", date, dest.region)
+	var requestHash = sha256.Sum256([]byte(canonicalRequest))
+	var stringToSign = strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	var key = hmacSHA256([]byte("AWS4"+dest.secretAccessKey), date)
+	key = hmacSHA256(key, dest.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	var signature = hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		dest.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	var h = hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode encodes every byte of the given path but the unreserved characters (and slashes), as required of the
+// canonical uri of a signed request (url.URL.EscapedPath leaves some reserved characters as is, e.g. '=')
+func uriEncode(path string) string {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		var c = path[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-_.~/", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
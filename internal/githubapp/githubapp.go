@@ -0,0 +1,167 @@
+// Package githubapp authenticates as a GitHub App installation, by minting short-lived installation access tokens
+// from the app's private key. These tokens are used in place of personal access tokens, for both clones and API calls.
+package githubapp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Username is the username to clone repos over http(s) with, using an installation access token as the password
+const Username = "x-access-token"
+
+// defaultBaseURL is the base url of the GitHub REST API on github.com
+const defaultBaseURL = "https://api.github.com"
+
+// Credential is a GitHub App installation credential, as stored (JSON encoded) in mergestat.service_auth_credentials
+type Credential struct {
+	AppID          int64  `json:"appId"`
+	InstallationID int64  `json:"installationId"`
+	PrivateKey     string `json:"privateKey"` // PEM encoded private key of the app
+}
+
+// Parse parses a JSON encoded GitHub App credential
+func Parse(data string) (*Credential, error) {
+	var c Credential
+	if err := json.Unmarshal([]byte(data), &c); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse GitHub App credential")
+	}
+
+	if c.AppID == 0 || c.InstallationID == 0 || c.PrivateKey == "" {
+		return nil, errors.New("GitHub App credential requires an appId, installationId and privateKey")
+	}
+
+	return &c, nil
+}
+
+// installationToken is an installation access token, along with when it expires
+type installationToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+// tokens caches installation access tokens (which are valid for an hour) by installation and base url
+var tokens sync.Map
+
+// Token returns an installation access token of the app installation, minting a new one if there's no cached token or it's
+// about to expire. The baseURL is the url of a GitHub Enterprise Server installation, or empty for github.com.
+func (c *Credential) Token(ctx context.Context, baseURL string) (string, error) {
+	var apiURL = defaultBaseURL
+	if baseURL != "" {
+		apiURL = strings.TrimSuffix(baseURL, "/") + "/api/v3"
+	}
+
+	var key = fmt.Sprintf("%s/%d/%d", apiURL, c.AppID, c.InstallationID)
+	if cached, ok := tokens.Load(key); ok && time.Until(cached.(*installationToken).expiresAt) > 5*time.Minute {
+		return cached.(*installationToken).token, nil
+	}
+
+	token, err := c.mint(ctx, apiURL)
+	if err != nil {
+		return "", err
+	}
+
+	tokens.Store(key, token)
+	return token.token, nil
+}
+
+// mint exchanges a JWT signed with the app's private key for an installation access token
+func (c *Credential) mint(ctx context.Context, apiURL string) (*installationToken, error) {
+	jwt, err := c.jwt(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var url = fmt.Sprintf("%s/app/installations/%d/access_tokens", apiURL, c.InstallationID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to mint GitHub App installation token")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, errors.Errorf("failed to mint GitHub App installation token: unexpected status %s", resp.Status)
+	}
+
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode GitHub App installation token")
+	}
+
+	return &installationToken{token: body.Token, expiresAt: body.ExpiresAt}, nil
+}
+
+// jwt returns a JWT authenticating as the app, signed (RS256) with the app's private key.
+// See: https://docs.github.com/en/apps/creating-github-apps/authenticating-with-a-github-app/generating-a-json-web-token-jwt-for-a-github-app
+func (c *Credential) jwt(now time.Time) (string, error) {
+	key, err := parsePrivateKey(c.PrivateKey)
+	if err != nil {
+		return "", err
+	}
+
+	var header, _ = json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	var claims, _ = json.Marshal(map[string]interface{}{
+		"iat": now.Add(-60 * time.Second).Unix(), // allow for clock drift
+		"exp": now.Add(9 * time.Minute).Unix(),   // at most 10 minutes in the future
+		"iss": strconv.FormatInt(c.AppID, 10),
+	})
+
+	var enc = base64.RawURLEncoding
+	var unsigned = enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+
+	var digest = sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to sign GitHub App JWT")
+	}
+
+	return unsigned + "." + enc.EncodeToString(signature), nil
+}
+
+// parsePrivateKey parses a PEM encoded (PKCS #1 or PKCS #8) RSA private key
+func parsePrivateKey(data string) (*rsa.PrivateKey, error) {
+	var block, _ = pem.Decode(bytes.TrimSpace([]byte(data)))
+	if block == nil {
+		return nil, errors.New("GitHub App private key is not PEM encoded")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse GitHub App private key")
+	}
+
+	if rsaKey, ok := key.(*rsa.PrivateKey); ok {
+		return rsaKey, nil
+	}
+	return nil, errors.New("GitHub App private key is not an RSA key")
+}
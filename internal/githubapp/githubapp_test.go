@@ -0,0 +1,186 @@
+package githubapp
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testKey is the RSA key of the app in tests, generated once as it's slow to generate
+var testKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+func encodePEM(typ string, der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}))
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(testKey)
+	if err != nil {
+		t.Fatalf("failed to encode PKCS #8 key: %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	ecPKCS8, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("failed to encode EC key: %v", err)
+	}
+
+	var tests = []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{name: "PKCS #1", data: encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testKey))},
+		{name: "PKCS #8", data: encodePEM("PRIVATE KEY", pkcs8)},
+		{name: "surrounding whitespace", data: "\n  " + encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testKey)) + "\n\n"},
+		{name: "not PEM", data: "not a key", wantErr: true},
+		{name: "not a key", data: encodePEM("PRIVATE KEY", []byte("garbage")), wantErr: true},
+		{name: "not an RSA key", data: encodePEM("PRIVATE KEY", ecPKCS8), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := parsePrivateKey(test.data)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parsePrivateKey() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !key.Equal(testKey) {
+				t.Errorf("parsePrivateKey() returned another key")
+			}
+		})
+	}
+}
+
+func TestJWT(t *testing.T) {
+	var c = Credential{AppID: 1234, InstallationID: 5678, PrivateKey: encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testKey))}
+	var now = time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	jwt, err := c.jwt(now)
+	if err != nil {
+		t.Fatalf("jwt() error = %v", err)
+	}
+
+	var parts = strings.Split(jwt, ".")
+	if len(parts) != 3 {
+		t.Fatalf("jwt() = %q, want 3 parts", jwt)
+	}
+
+	var enc = base64.RawURLEncoding
+	var decode = func(part string, v interface{}) {
+		data, err := enc.DecodeString(part)
+		if err != nil {
+			t.Fatalf("failed to decode JWT part %q: %v", part, err)
+		}
+		if err = json.Unmarshal(data, v); err != nil {
+			t.Fatalf("failed to unmarshal JWT part %q: %v", data, err)
+		}
+	}
+
+	var header map[string]string
+	decode(parts[0], &header)
+	if header["alg"] != "RS256" || header["typ"] != "JWT" {
+		t.Errorf("jwt() header = %v, want RS256 JWT", header)
+	}
+
+	var claims struct {
+		IssuedAt  int64  `json:"iat"`
+		ExpiresAt int64  `json:"exp"`
+		Issuer    string `json:"iss"`
+	}
+	decode(parts[1], &claims)
+
+	if claims.Issuer != "1234" {
+		t.Errorf("jwt() iss = %q, want %q", claims.Issuer, "1234")
+	}
+	if want := now.Add(-time.Minute).Unix(); claims.IssuedAt != want {
+		t.Errorf("jwt() iat = %d, want %d (a minute ago)", claims.IssuedAt, want)
+	}
+	if want := now.Add(9 * time.Minute).Unix(); claims.ExpiresAt != want {
+		t.Errorf("jwt() exp = %d, want %d (in 9 minutes)", claims.ExpiresAt, want)
+	}
+	if claims.ExpiresAt-claims.IssuedAt > int64((10 * time.Minute).Seconds()) {
+		t.Errorf("jwt() is valid for %ds, GitHub allows at most 10 minutes", claims.ExpiresAt-claims.IssuedAt)
+	}
+
+	signature, err := enc.DecodeString(parts[2])
+	if err != nil {
+		t.Fatalf("failed to decode JWT signature: %v", err)
+	}
+	var digest = sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err = rsa.VerifyPKCS1v15(&testKey.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("jwt() signature doesn't verify: %v", err)
+	}
+}
+
+func TestToken(t *testing.T) {
+	var c = Credential{AppID: 1234, InstallationID: 5678, PrivateKey: encodePEM("RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testKey))}
+
+	var minted int
+	var expiresIn = time.Hour
+	var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v3/app/installations/5678/access_tokens" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			t.Errorf("unexpected Authorization header %q", r.Header.Get("Authorization"))
+		}
+
+		minted++
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":      fmt.Sprintf("ghs_%d", minted),
+			"expires_at": time.Now().Add(expiresIn).UTC().Format(time.RFC3339),
+		})
+	}))
+	defer server.Close()
+
+	var ctx = context.Background()
+	var token = func() string {
+		token, err := c.Token(ctx, server.URL+"/")
+		if err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+		return token
+	}
+
+	// the token is cached until it's about to expire
+	if got := token(); got != "ghs_1" {
+		t.Errorf("Token() = %q, want %q", got, "ghs_1")
+	}
+	if got := token(); got != "ghs_1" {
+		t.Errorf("Token() = %q, want the cached %q", got, "ghs_1")
+	}
+
+	// a token about to expire is replaced with a new one on the next call
+	expiresIn = 2 * time.Minute
+	tokens.Delete(fmt.Sprintf("%s/api/v3/%d/%d", server.URL, c.AppID, c.InstallationID))
+	if got := token(); got != "ghs_2" {
+		t.Errorf("Token() = %q, want %q", got, "ghs_2")
+	}
+	if got := token(); got != "ghs_3" {
+		t.Errorf("Token() = %q, want a new token (%q) as the cached one is about to expire", got, "ghs_3")
+	}
+}
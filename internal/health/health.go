@@ -0,0 +1,90 @@
+// Package health implements the health (liveness) and readiness checks of the worker, served over HTTP
+// (as /healthz and /readyz) so that an orchestrator such as Kubernetes can restart, or stop routing to, unhealthy workers.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// checkTimeout is how long a single check may take, before it's considered failed
+const checkTimeout = 5 * time.Second
+
+// Check checks a single dependency of the worker, returning an error if it's unhealthy
+type Check func(ctx context.Context) error
+
+// Handler runs the given checks on every request, responding with the result of each (as JSON),
+// and a 503 status if any of them failed
+func Handler(checks map[string]Check) http.Handler {
+	var names = make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var status, results = http.StatusOK, make(map[string]string, len(checks))
+		for _, name := range names {
+			ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+			if err := checks[name](ctx); err != nil {
+				status, results[name] = http.StatusServiceUnavailable, err.Error()
+			} else {
+				results[name] = "ok"
+			}
+			cancel()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(results)
+	})
+}
+
+// Database checks that the database can be reached
+func Database(pool *pgxpool.Pool) Check {
+	return func(ctx context.Context) error {
+		return pool.Ping(ctx)
+	}
+}
+
+// ScratchDisk checks that the scratch directory (where repos are cloned to) is writable, and has at least minFree bytes available
+func ScratchDisk(path string, minFree uint64) Check {
+	return func(ctx context.Context) error {
+		f, err := os.CreateTemp(path, "mergestat-healthz-*")
+		if err != nil {
+			return errors.Wrapf(err, "scratch directory is not writable")
+		}
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+
+		var stat unix.Statfs_t
+		if err = unix.Statfs(path, &stat); err != nil {
+			return errors.Wrapf(err, "failed to stat scratch directory")
+		}
+
+		if available := uint64(stat.Bavail) * uint64(stat.Bsize); available < minFree {
+			return fmt.Errorf("scratch directory has %d bytes available, less than the required %d", available, minFree)
+		}
+
+		return nil
+	}
+}
+
+// Heartbeat checks that a background routine (e.g. the scheduler) ran within the last maxAge
+func Heartbeat(last func() time.Time, maxAge time.Duration) Check {
+	return func(ctx context.Context) error {
+		if since := time.Since(last()); since > maxAge {
+			return fmt.Errorf("last ran %s ago", since.Round(time.Second))
+		}
+		return nil
+	}
+}
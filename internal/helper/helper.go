@@ -2,16 +2,23 @@ package helper
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/google/go-github/v50/github"
 	"github.com/mergestat/mergestat/queries"
 	"github.com/rs/zerolog"
+	"github.com/shurcooL/githubv4"
+	"golang.org/x/oauth2"
 )
 
 // GetRepoOwnerAndRepoName extracts the owner and repo name from a GitHub-like repo url
@@ -29,6 +36,28 @@ func GetRepoOwnerAndRepoName(repoUrl string) (string, string, error) {
 	return s[0], s[1], nil
 }
 
+// GetAzureDevOpsRepoComponents extracts the organization, project and repository name from an Azure DevOps repo url.
+// Both the dev.azure.com/{organization}/{project}/_git/{repo} and the legacy {organization}.visualstudio.com/{project}/_git/{repo}
+// url formats are supported.
+func GetAzureDevOpsRepoComponents(repoUrl string) (organization, project, repository string, err error) {
+	var parsedURL *url.URL
+	if parsedURL, err = url.Parse(repoUrl); err != nil {
+		return "", "", "", err
+	}
+
+	var s = strings.Split(strings.Trim(parsedURL.Path, "/"), "/")
+	if strings.HasSuffix(parsedURL.Hostname(), ".visualstudio.com") {
+		// prepend the organization (taken from the subdomain) so both formats can be handled alike
+		s = append([]string{strings.TrimSuffix(parsedURL.Hostname(), ".visualstudio.com")}, s...)
+	}
+
+	if len(s) != 4 || s[2] != "_git" {
+		return "", "", "", fmt.Errorf("invalid Azure DevOps repo url: %s", repoUrl)
+	}
+
+	return s[0], s[1], s[3], nil
+}
+
 // CreateTempDir creates a temporary directory for any needed case with a specific path and pattern .
 // returning the new directory path as string, a cleaup fn and err .
 // if not provided will default to /tmp/randomNumber
@@ -69,6 +98,108 @@ func GetRepositoryURL(r *github.Repository) *string {
 	return r.URL
 }
 
+// GetTimeFromTimestamp is a helper function to get the time value of a
+// GitHub timestamp, if the timestamp is nil we return nil instead
+func GetTimeFromTimestamp(t *github.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+
+	return &t.Time
+}
+
+// GetUserLogin is a helper function to get the login of a GitHub user,
+// if the user is nil we return nil instead
+func GetUserLogin(u *github.User) *string {
+	if u == nil {
+		return nil
+	}
+
+	return u.Login
+}
+
+// NewGitHubClient returns a GitHub REST API client authenticated with the given token (an empty token returns
+// an unauthenticated client). If a base url is provided (e.g. https://github.example.com for a GitHub Enterprise
+// Server installation) the client talks to the API of that installation instead of github.com.
+// Requests made by the client wait for the rate limit to reset once it's exhausted (see rateLimitTransport).
+func NewGitHubClient(ctx context.Context, token, baseURL string) (*github.Client, error) {
+	var tc = newGitHubHTTPClient(ctx, token)
+
+	if len(baseURL) <= 0 {
+		return github.NewClient(tc), nil
+	}
+
+	return github.NewEnterpriseClient(baseURL, baseURL, tc)
+}
+
+// NewGitHubGraphQLClient returns a GitHub GraphQL API client, authenticated and rate limited as the REST API client of
+// NewGitHubClient, for the data the REST API doesn't expose (e.g. discussions).
+func NewGitHubGraphQLClient(ctx context.Context, token, baseURL string) *githubv4.Client {
+	var tc = newGitHubHTTPClient(ctx, token)
+
+	if len(baseURL) <= 0 {
+		return githubv4.NewClient(tc)
+	}
+
+	return githubv4.NewEnterpriseClient(strings.TrimSuffix(baseURL, "/")+"/api/graphql", tc)
+}
+
+// newGitHubHTTPClient returns the http client of the GitHub API clients, authenticated with the given token (if any)
+func newGitHubHTTPClient(ctx context.Context, token string) *http.Client {
+	var tc = &http.Client{Transport: &rateLimitTransport{base: http.DefaultTransport}}
+	if len(token) > 0 {
+		tc = oauth2.NewClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}))
+		tc.Transport = &rateLimitTransport{base: tc.Transport}
+	}
+	return tc
+}
+
+// GetGitAuthMethod returns the method to authenticate with when cloning from (or fetching) the given endpoint.
+// Over SSH the token is expected to be a PEM encoded private key. If no private key is provided, the keys of the
+// running ssh-agent (see SSH_AUTH_SOCK) are used instead. Host keys are verified against the known_hosts files
+// (see SSH_KNOWN_HOSTS). Over HTTP(S) the username and token are used for basic auth, if a token is provided.
+func GetGitAuthMethod(endpoint *transport.Endpoint, username, token string) (transport.AuthMethod, error) {
+	switch endpoint.Protocol {
+	case "ssh":
+		if username == "" {
+			username = endpoint.User // in case the username is encoded into the url (very common)
+		}
+		if username == "" {
+			username = "git"
+		}
+
+		// the credential might not be a private key at all (e.g. the GITHUB_TOKEN fallback for providers without a credential)
+		if strings.Contains(token, "PRIVATE KEY") {
+			auth, err := ssh.NewPublicKeys(username, []byte(token), "")
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse ssh key: %w", err)
+			}
+			return auth, nil
+		}
+
+		if os.Getenv("SSH_AUTH_SOCK") == "" {
+			return nil, errors.New("an ssh private key credential (or a running ssh-agent) is required to clone over ssh")
+		}
+
+		auth, err := ssh.NewSSHAgentAuth(username)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+		}
+		return auth, nil
+	case "http", "https", "git":
+		if token == "" {
+			return nil, nil
+		}
+
+		if username == "" {
+			username = "git"
+		}
+		return &githttp.BasicAuth{Username: username, Password: token}, nil
+	}
+
+	return nil, nil
+}
+
 func RestRatelimitHandler(ctx context.Context, resp *github.Response, l *zerolog.Logger, qry queries.Querier, impRunning bool) {
 	var remaining = resp.Rate.Remaining
 	var delay = 800 * time.Millisecond
@@ -1,11 +1,20 @@
 package helper
 
 import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
 	"errors"
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/google/go-github/v50/github"
 )
 
@@ -67,6 +76,57 @@ func TestGetRepoOwnerAndRepoName(t *testing.T) {
 	}
 }
 
+func TestGetAzureDevOpsRepoComponents(t *testing.T) {
+	tests := []struct {
+		description      string
+		value            string
+		wantOrganization string
+		wantProject      string
+		wantRepository   string
+		wantErr          bool
+	}{
+		{
+			description:      "successful operation with dev.azure.com url",
+			value:            "https://dev.azure.com/mergestat/platform/_git/mergestat",
+			wantOrganization: "mergestat",
+			wantProject:      "platform",
+			wantRepository:   "mergestat",
+		},
+		{
+			description:      "successful operation with username in url",
+			value:            "https://mergestat@dev.azure.com/mergestat/platform/_git/mergestat",
+			wantOrganization: "mergestat",
+			wantProject:      "platform",
+			wantRepository:   "mergestat",
+		},
+		{
+			description:      "successful operation with visualstudio.com url",
+			value:            "https://mergestat.visualstudio.com/platform/_git/mergestat",
+			wantOrganization: "mergestat",
+			wantProject:      "platform",
+			wantRepository:   "mergestat",
+		},
+		{
+			description: "error operation with GitHub url",
+			value:       "https://github.com/mergestat/mergestat",
+			wantErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			gotOrganization, gotProject, gotRepository, err := GetAzureDevOpsRepoComponents(test.value)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("GetAzureDevOpsRepoComponents() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if gotOrganization != test.wantOrganization || gotProject != test.wantProject || gotRepository != test.wantRepository {
+				t.Errorf("GetAzureDevOpsRepoComponents() = (%v, %v, %v), want (%v, %v, %v)", gotOrganization, gotProject, gotRepository,
+					test.wantOrganization, test.wantProject, test.wantRepository)
+			}
+		})
+	}
+}
+
 func TestCreateTempDir(t *testing.T) {
 	type testArgs struct {
 		basePath    string
@@ -163,3 +223,164 @@ func TestGetRepositoryURL(t *testing.T) {
 		})
 	}
 }
+
+func TestGetTimeFromTimestamp(t *testing.T) {
+	type testArgs struct {
+		description string
+		testValue   *github.Timestamp
+		want        *time.Time
+	}
+
+	var now = time.Now()
+
+	tests := []testArgs{{
+		description: "nil management",
+		testValue:   nil,
+		want:        nil,
+	}, {
+		description: "successful conversion",
+		testValue:   &github.Timestamp{Time: now},
+		want:        &now,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got := GetTimeFromTimestamp(test.testValue)
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("GetTimeFromTimestamp got = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetUserLogin(t *testing.T) {
+	type testArgs struct {
+		description string
+		testValue   *github.User
+		want        *string
+	}
+
+	var login = "mergestat"
+
+	tests := []testArgs{{
+		description: "nil management",
+		testValue:   nil,
+		want:        nil,
+	}, {
+		description: "successful operation",
+		testValue:   &github.User{Login: &login},
+		want:        &login,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			got := GetUserLogin(test.testValue)
+
+			if !reflect.DeepEqual(got, test.want) {
+				t.Fatalf("GetUserLogin got = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGetGitAuthMethod(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pemKey = string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	// make sure the test doesn't pick up the ssh-agent of whoever runs it
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	tests := []struct {
+		description string
+		url         string
+		username    string
+		token       string
+		check       func(*testing.T, transport.AuthMethod)
+		wantErr     bool
+	}{
+		{
+			description: "https with token uses basic auth",
+			url:         "https://github.com/mergestat/mergestat",
+			token:       "token",
+			check: func(t *testing.T, auth transport.AuthMethod) {
+				if basic, ok := auth.(*http.BasicAuth); !ok || basic.Username != "git" || basic.Password != "token" {
+					t.Errorf("GetGitAuthMethod() = %v, want basic auth with default username", auth)
+				}
+			},
+		},
+		{
+			description: "https without token uses no auth",
+			url:         "https://github.com/mergestat/mergestat",
+			check: func(t *testing.T, auth transport.AuthMethod) {
+				if auth != nil {
+					t.Errorf("GetGitAuthMethod() = %v, want nil", auth)
+				}
+			},
+		},
+		{
+			description: "ssh with private key uses public keys auth with username from url",
+			url:         "ssh://gerrit@review.example.com:29418/mergestat",
+			token:       pemKey,
+			check: func(t *testing.T, auth transport.AuthMethod) {
+				if keys, ok := auth.(*ssh.PublicKeys); !ok || keys.User != "gerrit" {
+					t.Errorf("GetGitAuthMethod() = %v, want public keys auth for user gerrit", auth)
+				}
+			},
+		},
+		{
+			description: "ssh without private key nor ssh-agent fails",
+			url:         "git@example.com:mergestat/mergestat.git",
+			token:       "ghp_not_a_private_key",
+			wantErr:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			endpoint, err := transport.NewEndpoint(test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			auth, err := GetGitAuthMethod(endpoint, test.username, test.token)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("GetGitAuthMethod() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.check != nil {
+				test.check(t, auth)
+			}
+		})
+	}
+}
+
+func TestNewGitHubClient(t *testing.T) {
+	tests := []struct {
+		description string
+		baseURL     string
+		want        string
+	}{
+		{description: "github.com by default", baseURL: "", want: "https://api.github.com/"},
+		{description: "enterprise server", baseURL: "https://github.example.com", want: "https://github.example.com/api/v3/"},
+		{description: "enterprise server with api path", baseURL: "https://github.example.com/api/v3/", want: "https://github.example.com/api/v3/"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			client, err := NewGitHubClient(context.Background(), "ghp_token", test.baseURL)
+			if err != nil {
+				t.Fatalf("NewGitHubClient() error = %v", err)
+			}
+			if got := client.BaseURL.String(); got != test.want {
+				t.Errorf("NewGitHubClient() base url = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
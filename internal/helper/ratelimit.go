@@ -0,0 +1,144 @@
+package helper
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// maxRateLimitWait is the longest a request waits for the GitHub API rate limit to reset, before giving up
+// (and returning the rate limited response). The primary rate limit resets every hour.
+const maxRateLimitWait = time.Hour + time.Minute
+
+// maxSecondaryRateLimitRetries is how many times a request that hit a secondary rate limit is retried
+const maxSecondaryRateLimitRetries = 5
+
+var (
+	githubRateLimitRemaining = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mergestat_github_rate_limit_remaining",
+		Help: "Remaining GitHub API requests (or points) in the current rate limit window",
+	}, []string{"resource"})
+
+	githubRateLimitReset = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "mergestat_github_rate_limit_reset_timestamp_seconds",
+		Help: "Time at which the current GitHub API rate limit window resets",
+	}, []string{"resource"})
+
+	githubRateLimitWaits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "mergestat_github_rate_limit_waits_total",
+		Help: "Number of GitHub API requests that waited for the rate limit to reset, rather than failing",
+	}, []string{"resource"})
+
+	githubSecondaryRateLimits = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "mergestat_github_secondary_rate_limits_total",
+		Help: "Number of GitHub API requests that hit a secondary rate limit, and were retried",
+	})
+)
+
+// RecordGitHubRateLimit surfaces the rate limit of the given GitHub API resource (e.g. core or graphql) as metrics
+func RecordGitHubRateLimit(resource string, remaining int, reset time.Time) {
+	githubRateLimitRemaining.WithLabelValues(resource).Set(float64(remaining))
+	githubRateLimitReset.WithLabelValues(resource).Set(float64(reset.Unix()))
+}
+
+// rateLimitTransport tracks the rate limit of the GitHub API from the headers of its responses. Once the rate limit is
+// exhausted (or a secondary rate limit is hit), requests wait until it resets and are retried, rather than failing the sync mid-pagination.
+type rateLimitTransport struct {
+	base http.RoundTripper
+}
+
+// rateLimit returns the rate limit reported in the headers of a GitHub API response, if any
+func rateLimit(resp *http.Response) (resource string, remaining int, reset time.Time, ok bool) {
+	var err error
+	if remaining, err = strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining")); err != nil {
+		return "", 0, time.Time{}, false
+	}
+
+	var epoch int64
+	if epoch, err = strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err != nil {
+		return "", 0, time.Time{}, false
+	}
+
+	if resource = resp.Header.Get("X-RateLimit-Resource"); resource == "" {
+		resource = "core"
+	}
+
+	return resource, remaining, time.Unix(epoch, 0), true
+}
+
+// secondaryRateLimitWait returns how long to wait before retrying a request that hit a secondary rate limit (also known
+// as abuse detection), if the response is one. GitHub asks to honor the Retry-After header, or else wait at least a minute
+// (longer after each attempt). See: https://docs.github.com/en/rest/overview/resources-in-the-rest-api#secondary-rate-limits
+func secondaryRateLimitWait(resp *http.Response, attempt int) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	// the body has to be read to tell a secondary rate limit apart from other errors, so it's restored for the caller
+	body, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil || !bytes.Contains(bytes.ToLower(body), []byte("secondary rate limit")) {
+		return 0, false
+	}
+
+	return time.Minute << attempt, true
+}
+
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var resource, wait = "core", time.Duration(0)
+		if res, remaining, reset, ok := rateLimit(resp); ok {
+			RecordGitHubRateLimit(res, remaining, reset)
+			resource = res
+
+			if (resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests) && remaining == 0 {
+				wait = time.Until(reset) + time.Second // allow for clock drift
+			}
+		}
+
+		// a secondary rate limit is retried in place, so that a paginated sync carries on from the page it was at
+		if wait == 0 && attempt < maxSecondaryRateLimitRetries {
+			if secondary, ok := secondaryRateLimitWait(resp, attempt); ok {
+				wait = secondary
+				githubSecondaryRateLimits.Inc()
+			}
+		}
+
+		if wait <= 0 || wait > maxRateLimitWait || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+
+		githubRateLimitWaits.WithLabelValues(resource).Inc()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+
+		// retry the request, with a fresh copy of its body (if any)
+		req = req.Clone(req.Context())
+		if req.GetBody != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
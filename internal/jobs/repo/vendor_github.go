@@ -4,18 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
+	"path"
+	"strings"
 
 	"github.com/google/go-github/v50/github"
 	"github.com/google/uuid"
 	"github.com/jackc/pgtype"
 	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
 	"github.com/pkg/errors"
-	"golang.org/x/oauth2"
 )
 
 type fetchFunc func(ctx context.Context, page int) ([]*github.Repository, *github.Response, error)
 
+// lostAccessReason is the reason the syncs of a repo are paused with, once the GitHub App installation
+// of its import loses access to it (see mergestat.repo_sync_pauses)
+const lostAccessReason = "GitHub App installation lost access to the repo"
+
 func handleGithubImport(ctx context.Context, qry *db.Queries, imp db.FetchImportJobRow) (err error) {
 	var token string
 	if _, token, err = qry.FetchCredential(ctx, imp.Provider); err != nil {
@@ -24,27 +29,40 @@ func handleGithubImport(ctx context.Context, qry *db.Queries, imp db.FetchImport
 
 	var public = token == ""
 
+	// the provider may point to a GitHub Enterprise Server installation instead of github.com
+	var providerSettings struct {
+		URL string `json:"url"`
+	}
+
+	if imp.ProviderSettings.Status == pgtype.Present {
+		if err = json.Unmarshal(imp.ProviderSettings.Bytes, &providerSettings); err != nil {
+			return errors.Wrapf(err, "failed to parse provider settings")
+		}
+	}
+
 	var client *github.Client
-	if !public {
-		var tokenSource = oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token})
-		client = github.NewClient(oauth2.NewClient(ctx, tokenSource))
-	} else {
-		client = github.NewClient(&http.Client{})
+	if client, err = helper.NewGitHubClient(ctx, token, providerSettings.URL); err != nil {
+		return errors.Wrapf(err, "failed to create github client")
 	}
 
 	var settings struct {
-		Type                   string      `json:"type"`
-		Login                  string      `json:"userOrOrg"`
-		RemoveDeletedRepos     bool        `json:"removeDeletedRepos"`
-		IncludeArchivedRepos   bool        `json:"includeArchivedRepos"`
-		DefaultSyncTypes       []string    `json:"defaultSyncTypes"`
-		DefaultContainerImages []uuid.UUID `json:"defaultContainerImages"`
+		Type                   string              `json:"type"`
+		Login                  string              `json:"userOrOrg"`
+		RemoveDeletedRepos     bool                `json:"removeDeletedRepos"`
+		IncludeArchivedRepos   bool                `json:"includeArchivedRepos"`
+		DefaultSyncTypes       []string            `json:"defaultSyncTypes"`
+		DefaultContainerImages []uuid.UUID         `json:"defaultContainerImages"`
+		Filters                githubImportFilters `json:"filters"`
 	}
 
 	if err = json.Unmarshal(imp.Settings.Bytes, &settings); err != nil {
 		return errors.Wrapf(err, "failed to parse import settings")
 	}
 
+	if err = settings.Filters.validate(); err != nil {
+		return errors.Wrapf(err, "invalid import filters")
+	}
+
 	var fetchFunction fetchFunc
 	switch settings.Type {
 	case "GITHUB_ORG":
@@ -59,6 +77,11 @@ func handleGithubImport(ctx context.Context, qry *db.Queries, imp db.FetchImport
 			opts.Type = "public"
 		}
 		fetchFunction = fetchByUser(client, settings.Login, opts)
+	case "GITHUB_APP_INSTALLATION":
+		if public {
+			return errors.New("GITHUB_APP_INSTALLATION imports require a GitHub App credential")
+		}
+		fetchFunction = fetchByInstallation(client, &github.ListOptions{PerPage: 100})
 	default:
 		return errors.Errorf("unknown import type: %s", settings.Type)
 	}
@@ -68,6 +91,9 @@ func handleGithubImport(ctx context.Context, qry *db.Queries, imp db.FetchImport
 		return errors.Wrapf(err, "failed to fetch repositories")
 	}
 
+	// repos that are filtered out are left out of the import altogether, as if they didn't exist
+	repos = settings.Filters.apply(repos)
+
 	var repoUrls = make([]string, len(repos))
 	for i, repo := range repos {
 		repoUrls[i] = fmt.Sprintf("https://github.com/%s/%s", *repo.Owner.Login, *repo.Name)
@@ -81,6 +107,20 @@ func handleGithubImport(ctx context.Context, qry *db.Queries, imp db.FetchImport
 		}
 	}
 
+	// the syncs of repos the app installation lost access to are paused (unless they were removed above),
+	// and resumed once it's granted access to them again
+	if settings.Type == "GITHUB_APP_INSTALLATION" {
+		var pause = db.PauseReposMissingFromImportParams{Reason: lostAccessReason, ImportID: imp.ID, Repos: repoUrls}
+		if err = qry.PauseReposMissingFromImport(ctx, pause); err != nil {
+			return errors.Wrapf(err, "failed to pause inaccessible repositories")
+		}
+
+		var resume = db.ResumeReposOfImportParams{ImportID: imp.ID, Repos: repoUrls, Reason: lostAccessReason}
+		if err = qry.ResumeReposOfImport(ctx, resume); err != nil {
+			return errors.Wrapf(err, "failed to resume accessible repositories")
+		}
+	}
+
 	// capture list of existing repositories before we do the upsert below
 	var existing []string
 	if len(settings.DefaultSyncTypes) > 0 || len(settings.DefaultContainerImages) > 0 { // only if default syncs are configured
@@ -188,9 +228,113 @@ func fetchByUser(client *github.Client, user string, opts *github.RepositoryList
 	}
 }
 
+func fetchByInstallation(client *github.Client, opts *github.ListOptions) fetchFunc {
+	return func(ctx context.Context, page int) ([]*github.Repository, *github.Response, error) {
+		opts.Page = page
+		list, resp, err := client.Apps.ListRepos(ctx, opts)
+		if err != nil {
+			return nil, resp, err
+		}
+		return list.Repositories, resp, nil
+	}
+}
+
 func fetchByOrg(client *github.Client, org string, opts *github.RepositoryListByOrgOptions) fetchFunc {
 	return func(ctx context.Context, page int) ([]*github.Repository, *github.Response, error) {
 		opts.Page = page
 		return client.Repositories.ListByOrg(ctx, org, opts)
 	}
 }
+
+// githubImportFilters narrow down the repos imported from a GitHub user or org. Repos that are filtered out aren't
+// imported (and are removed, if the import removes deleted repos). Name patterns use the syntax of path.Match,
+// and are matched case-insensitively. Empty filters match all repos.
+type githubImportFilters struct {
+	IncludeNames  []string `json:"includeNames"`  // only import repos whose name matches any of these patterns
+	ExcludeNames  []string `json:"excludeNames"`  // don't import repos whose name matches any of these patterns
+	IncludeTopics []string `json:"includeTopics"` // only import repos with any of these topics
+	ExcludeTopics []string `json:"excludeTopics"` // don't import repos with any of these topics
+	Visibility    []string `json:"visibility"`    // only import repos with one of these visibilities (public, private or internal)
+}
+
+func (f *githubImportFilters) validate() error {
+	for _, pattern := range append(append([]string{}, f.IncludeNames...), f.ExcludeNames...) {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return errors.Wrapf(err, "invalid name pattern %q", pattern)
+		}
+	}
+
+	for _, visibility := range f.Visibility {
+		if visibility != "public" && visibility != "private" && visibility != "internal" {
+			return errors.Errorf("unknown visibility: %s", visibility)
+		}
+	}
+
+	return nil
+}
+
+// apply returns the repos that pass the filters
+func (f *githubImportFilters) apply(repos []*github.Repository) []*github.Repository {
+	var result = make([]*github.Repository, 0, len(repos))
+	for _, repo := range repos {
+		if f.match(repo) {
+			result = append(result, repo)
+		}
+	}
+	return result
+}
+
+func (f *githubImportFilters) match(repo *github.Repository) bool {
+	var name = strings.ToLower(repo.GetName())
+	var matchesName = func(patterns []string) bool {
+		for _, pattern := range patterns {
+			if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	var hasTopic = func(topics []string) bool {
+		for _, topic := range topics {
+			for _, t := range repo.Topics {
+				if strings.EqualFold(topic, t) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	// older GitHub Enterprise Server versions don't report the visibility of repos
+	var visibility = repo.GetVisibility()
+	if visibility == "" && repo.GetPrivate() {
+		visibility = "private"
+	} else if visibility == "" {
+		visibility = "public"
+	}
+
+	switch {
+	case len(f.IncludeNames) > 0 && !matchesName(f.IncludeNames):
+		return false
+	case matchesName(f.ExcludeNames):
+		return false
+	case len(f.IncludeTopics) > 0 && !hasTopic(f.IncludeTopics):
+		return false
+	case hasTopic(f.ExcludeTopics):
+		return false
+	case len(f.Visibility) > 0 && !contains(f.Visibility, visibility):
+		return false
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -19,8 +19,6 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/cache"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/go-git/go-git/v5/storage/filesystem"
 	"github.com/google/uuid"
 	"github.com/mergestat/mergestat/internal/db"
@@ -32,7 +30,7 @@ import (
 
 // ContainerSync implements a sqlq.Handler that utilizes a Container-based execution environment
 // to run user-provided, custom sync jobs.
-func ContainerSync(pgUrl string, workerLogger *zerolog.Logger, querier *db.Queries) sqlq.Handler {
+func ContainerSync(pgUrl string, workerLogger *zerolog.Logger, querier *db.Queries, clonePath string) sqlq.Handler {
 	type ImageMetadata = struct{ Labels map[string]string } // used to un-marshal output from podman-image-inspect
 
 	postgresUrl, _ := url.Parse(pgUrl)
@@ -123,7 +121,7 @@ func ContainerSync(pgUrl string, workerLogger *zerolog.Logger, querier *db.Queri
 			logger.Infof("running image %s", url)
 
 			var username, token string
-			if username, token, err = querier.FetchCredential(ctx, repo.Provider); err != nil {
+			if username, token, err = querier.FetchRepoCredential(ctx, repo.Provider, repo.ID); err != nil {
 				return err
 			}
 
@@ -175,7 +173,7 @@ func ContainerSync(pgUrl string, workerLogger *zerolog.Logger, querier *db.Queri
 				var tmpPath string
 				var cleanup func() error
 				// create a new temporary location to clone the repository
-				tmpPath, cleanup, err = helper.CreateTempDir(os.Getenv("GIT_CLONE_PATH"), fmt.Sprintf("mergestat-repo-%s-*", repo.ID.String()))
+				tmpPath, cleanup, err = helper.CreateTempDir(clonePath, fmt.Sprintf("mergestat-repo-%s-*", repo.ID.String()))
 				if err != nil {
 					logger.Errorf("failed to create directory for cloning: %s", err.Error())
 					return errors.Wrapf(err, "failed to create directory")
@@ -242,7 +240,7 @@ func clone(ctx context.Context, logger *sqlq.Logger, q *db.Queries, path string,
 
 	// fetch the username and token for the provider
 	var username, token string
-	if username, token, err = q.FetchCredential(ctx, repo.Provider); err != nil {
+	if username, token, err = q.FetchRepoCredential(ctx, repo.Provider, repo.ID); err != nil {
 		return err
 	}
 
@@ -252,16 +250,8 @@ func clone(ctx context.Context, logger *sqlq.Logger, q *db.Queries, path string,
 	}
 
 	var auth transport.AuthMethod
-	if endpoint.Protocol == "ssh" {
-		if username == "" {
-			username = endpoint.User // in case the username is encoded into the url (very common)
-		}
-
-		if auth, err = ssh.NewPublicKeys(username, []byte(token), ""); err != nil {
-			return errors.Wrapf(err, "failed to parse ssh key")
-		}
-	} else if endpoint.Protocol == "http" || endpoint.Protocol == "https" || endpoint.Protocol == "git" {
-		auth = &http.BasicAuth{Username: username, Password: token}
+	if auth, err = helper.GetGitAuthMethod(endpoint, username, token); err != nil {
+		return err
 	}
 
 	// fs and target are different! target is a subdirectory of fs. target stores git objects (like commits, etc.)
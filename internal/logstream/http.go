@@ -0,0 +1,167 @@
+package logstream
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/rs/zerolog"
+)
+
+// errTooManyStreams is returned by Streams.connect if as many logs as allowed are already streamed
+var errTooManyStreams = errors.New("too many log streams")
+
+// Streams bounds the number of logs streamed at a time, by the handlers sharing it. Each stream holds a connection of
+// its own for as long as it lasts (listening to Channel), rather than one of the pool sync jobs are run with, so that
+// clients following logs can't starve sync jobs of connections.
+type Streams struct {
+	config *pgx.ConnConfig
+	slots  chan struct{} // a slot per log streamed at a time
+}
+
+// NewStreams returns a bound of max logs streamed at a time, each over a connection made with the given config
+func NewStreams(config *pgx.ConnConfig, max int) *Streams {
+	return &Streams{config: config, slots: make(chan struct{}, max)}
+}
+
+// connect takes a slot and makes the connection of a stream, returning a func to close it (and free the slot) with
+func (s *Streams) connect(ctx context.Context) (_ *pgx.Conn, _ func(), err error) {
+	select {
+	case s.slots <- struct{}{}:
+	default:
+		return nil, nil, errTooManyStreams
+	}
+
+	var conn *pgx.Conn
+	if conn, err = pgx.ConnectConfig(ctx, s.config); err != nil {
+		<-s.slots
+		return nil, nil, err
+	}
+
+	return conn, func() { _ = conn.Close(context.Background()); <-s.slots }, nil
+}
+
+type handler struct {
+	logger  *zerolog.Logger
+	streams *Streams
+	token   []byte
+}
+
+// Handler returns a handler streaming the lines logged by sync jobs as server-sent events (each line as the JSON data
+// of a "log" event), optionally only of a repo (?repo=<id or url>) or a job (?job=<id>). Lines logged before the
+// request are sent first, all the lines of a job, or the latest ?lines=<n> lines (10 by default) otherwise. The stream
+// of a job ends once the job is done. Requests must be authorized with the given token, as a bearer token. Requests
+// beyond the bound of the given streams are responded to with a 503.
+func Handler(logger *zerolog.Logger, streams *Streams, token []byte) http.Handler {
+	return &handler{logger: logger, streams: streams, token: token}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var token = strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), h.token) != 1 {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	conn, release, err := h.streams.connect(r.Context())
+	if errors.Is(err, errTooManyStreams) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
+		h.logger.Err(err).Msgf("error connecting to stream sync logs: %v", err)
+		http.Error(w, "could not connect to database", http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
+	var q = db.New(conn)
+	var filter db.SyncLogFilter
+	var after int64
+
+	var query = r.URL.Query()
+	if repo := query.Get("repo"); repo != "" {
+		var id uuid.UUID
+		if id, err = q.FindRepo(r.Context(), repo); err != nil {
+			h.fail(w, err)
+			return
+		}
+		filter.Repo = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	if job := query.Get("job"); job != "" {
+		if filter.Job, err = strconv.ParseInt(job, 10, 64); err != nil {
+			http.Error(w, "invalid job", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// a reconnecting client resumes after the last line it received, as noted by the id of the last event
+	if last := r.Header.Get("Last-Event-ID"); last != "" {
+		if after, err = strconv.ParseInt(last, 10, 64); err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+	} else if filter.Job == 0 {
+		var lines = 10
+		if n := query.Get("lines"); n != "" {
+			if lines, err = strconv.Atoi(n); err != nil || lines < 0 {
+				http.Error(w, "invalid lines", http.StatusBadRequest)
+				return
+			}
+		}
+		if after, err = q.SyncLogIDBefore(r.Context(), lines); err != nil {
+			h.fail(w, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	err = Follow(r.Context(), conn, filter, after, func(line *db.SyncLogLine) error {
+		data, err := json.Marshal(line)
+		if err != nil {
+			return err
+		}
+		if _, err = fmt.Fprintf(w, "id: %d\nevent: log\ndata: %s\n\n", line.ID, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+	if err != nil && r.Context().Err() == nil {
+		h.logger.Err(err).Msgf("error streaming sync logs: %v", err)
+	}
+}
+
+// fail responds with the given error, a 404 if something referenced by the request doesn't exist
+func (h *handler) fail(w http.ResponseWriter, err error) {
+	if errors.Is(err, db.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	h.logger.Err(err).Msgf("error streaming sync logs: %v", err)
+	http.Error(w, "could not stream logs", http.StatusInternalServerError)
+}
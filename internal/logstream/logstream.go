@@ -0,0 +1,101 @@
+// Package logstream streams the lines logged by sync jobs as they're logged, the way `kubectl logs -f` does, listening
+// for the notifications sent whenever lines are logged (see the repo_sync_logs_notify_trigger) rather than polling.
+package logstream
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// Channel is notified whenever lines are logged by a sync job, with the id of the job as the payload
+const Channel = "mergestat_repo_sync_logs"
+
+// batchSize is the max number of lines fetched at a time
+const batchSize = 500
+
+// statusInterval is how often the status of a followed job is checked, as a job may finish without logging anything
+const statusInterval = 5 * time.Second
+
+// Follow calls fn with each line matching the filter that's logged after the line with the given id, first with the
+// lines logged so far and then with each new line as it's logged, until the context is canceled or fn returns an
+// error. If the filter selects a job, Follow returns once the job is done (or failed) and all of its lines are sent.
+// The connection is held (listening to Channel) until Follow returns.
+func Follow(ctx context.Context, conn *pgx.Conn, filter db.SyncLogFilter, after int64, fn func(*db.SyncLogLine) error) (err error) {
+	if _, err = conn.Exec(ctx, "LISTEN "+Channel); err != nil {
+		return err
+	}
+	defer func() {
+		if !conn.IsClosed() {
+			_, _ = conn.Exec(context.Background(), "UNLISTEN "+Channel)
+		}
+	}()
+
+	var q = db.New(conn)
+	for {
+		// the status is checked before catching up, so that the lines logged until the job finished are all sent
+		var finished bool
+		if filter.Job != 0 {
+			var status string
+			if status, err = q.SyncJobStatus(ctx, filter.Job); err != nil {
+				return err
+			}
+			finished = status != "QUEUED" && status != "RUNNING"
+		}
+
+		// catch up with the lines logged since the last line sent (already listening, so that none is missed)
+		for {
+			var lines []*db.SyncLogLine
+			if lines, err = q.ListSyncLogsAfter(ctx, after, filter, batchSize); err != nil {
+				return err
+			}
+
+			for _, line := range lines {
+				if err = fn(line); err != nil {
+					return err
+				}
+				after = line.ID
+			}
+
+			if len(lines) < batchSize {
+				break
+			}
+		}
+
+		if finished {
+			return nil
+		}
+
+		if err = wait(ctx, conn, filter.Job); err != nil {
+			return err
+		}
+	}
+}
+
+// wait blocks until lines are logged by the given job (by any job if 0), or the context is canceled. If waiting for a
+// job, it returns after statusInterval at the latest, so that the status of the job is checked again.
+func wait(ctx context.Context, conn *pgx.Conn, job int64) error {
+	if job != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, statusInterval)
+		defer cancel()
+	}
+
+	for {
+		n, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if job != 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil
+			}
+			return err
+		}
+
+		if job == 0 || n.Payload == strconv.FormatInt(job, 10) {
+			return nil
+		}
+	}
+}
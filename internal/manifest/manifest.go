@@ -0,0 +1,166 @@
+// Package manifest reconciles the database against a declarative sync configuration file (a YAML manifest of providers,
+// credentials, repos and their syncs), on startup and whenever the file changes, so that what's synced can be reviewed
+// and versioned alongside the rest of an infrastructure (GitOps style) rather than only managed through the app.
+//
+// An example manifest:
+//
+//	providers:
+//	  - name: github
+//	    vendor: github
+//	    credentials:
+//	      - type: GITHUB_PAT
+//	        env: GITHUB_TOKEN # the name of the env var of the worker holding the token, never the token itself
+//	repos:
+//	  - repo: https://github.com/mergestat/mergestat
+//	    provider: github
+//	    syncs:
+//	      - type: GIT_COMMITS
+//	        schedule: "0 * * * *"
+//	      - type: GITHUB_REPO_PRS
+//	prune: true # remove the repos added by the manifest once they're dropped from it
+//
+// The manifest owns what it lists: the providers, repos and syncs it lists are created or updated to match it, and the
+// syncs of its repos that it doesn't list are unscheduled. Everything else (e.g. repos added through the app) is left as is.
+package manifest
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v2"
+)
+
+// Manifest is a declarative sync configuration file
+type Manifest struct {
+	Providers []Provider `yaml:"providers"`
+	Repos     []Repo     `yaml:"repos"`
+	Prune     bool       `yaml:"prune"` // to remove the repos added by the manifest once they're dropped from it
+}
+
+// Provider is a provider (e.g. GitHub), along with the credentials of the provider
+type Provider struct {
+	Name        string                 `yaml:"name"`
+	Vendor      string                 `yaml:"vendor"`
+	Tenant      string                 `yaml:"tenant"`   // the tenant the provider (and its repos and credentials) belongs to, default if not set
+	Settings    map[string]interface{} `yaml:"settings"` // left as is if not set
+	Credentials []Credential           `yaml:"credentials"`
+}
+
+// Credential references a credential of a provider, which is never written in the manifest itself: either the
+// env var of the worker holding it, or the reference of an external secret (e.g. for a VAULT_SECRET credential)
+type Credential struct {
+	Type     string `yaml:"type"`
+	Username string `yaml:"username"`
+	Env      string `yaml:"env"`
+	Secret   string `yaml:"secret"`
+}
+
+// Repo is a repo of a provider, along with the syncs scheduled for it
+type Repo struct {
+	Repo     string `yaml:"repo"`
+	Provider string `yaml:"provider"` // the name of the provider, which needn't be in the manifest itself
+	Syncs    []Sync `yaml:"syncs"`
+}
+
+// Sync is a sync scheduled for a repo
+type Sync struct {
+	Type     string                 `yaml:"type"`
+	Schedule string                 `yaml:"schedule"` // a cron expression, the default interval of the scheduler if empty
+	Settings map[string]interface{} `yaml:"settings"`
+}
+
+// Load reads and validates the manifest at the given path, returning it along with its raw contents
+func Load(path string) (_ *Manifest, contents []byte, err error) {
+	if contents, err = os.ReadFile(path); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read manifest")
+	}
+
+	var m Manifest
+	// strict, so that a misspelled key isn't silently ignored
+	if err = yaml.UnmarshalStrict(contents, &m); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to parse manifest %s", path)
+	}
+
+	if err = m.validate(); err != nil {
+		return nil, nil, errors.Wrapf(err, "invalid manifest %s", path)
+	}
+	return &m, contents, nil
+}
+
+// validate reports all the problems of the manifest at once, rather than only the first one
+func (m *Manifest) validate() error {
+	var problems []string
+	var check = func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	var providers = make(map[string]bool)
+	for i, p := range m.Providers {
+		check(p.Name != "", "providers[%d] must have a name", i)
+		check(p.Vendor != "", "provider %q must have a vendor", p.Name)
+		check(!providers[p.Name], "provider %q is listed more than once", p.Name)
+		providers[p.Name] = true
+
+		for j, c := range p.Credentials {
+			check(c.Type != "", "credentials[%d] of provider %q must have a type", j, p.Name)
+			check((c.Env == "") != (c.Secret == ""), "credentials[%d] of provider %q must set exactly one of env or secret", j, p.Name)
+		}
+	}
+
+	var repos = make(map[string]bool)
+	for i, r := range m.Repos {
+		check(r.Repo != "", "repos[%d] must have a repo url", i)
+		check(r.Provider != "", "repo %q must have a provider", r.Repo)
+		check(!repos[r.Repo], "repo %q is listed more than once", r.Repo)
+		repos[r.Repo] = true
+
+		var syncs = make(map[string]bool)
+		for j, s := range r.Syncs {
+			check(s.Type != "", "syncs[%d] of repo %q must have a type", j, r.Repo)
+			check(!syncs[s.Type], "sync %s of repo %q is listed more than once", s.Type, r.Repo)
+			syncs[s.Type] = true
+
+			if s.Schedule != "" {
+				_, err := cron.ParseStandard(s.Schedule)
+				check(err == nil, "sync %s of repo %q has an invalid schedule %q", s.Type, r.Repo, s.Schedule)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// jsonValue converts a value decoded from YAML to one that can be encoded as JSON, as YAML maps are decoded
+// with keys of any type (i.e. as map[interface{}]interface{}), which encoding/json doesn't support
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		var m = make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case map[string]interface{}:
+		var m = make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[key] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		var s = make([]interface{}, len(v))
+		for i, value := range v {
+			s[i] = jsonValue(value)
+		}
+		return s
+	default:
+		return v
+	}
+}
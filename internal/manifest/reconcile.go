@@ -0,0 +1,231 @@
+package manifest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/pkg/errors"
+)
+
+// Result summarizes the changes made by a reconciliation
+type Result struct {
+	Providers          int // providers created or updated
+	CredentialsAdded   int
+	CredentialsRemoved int
+	ReposAdded         int
+	ReposPruned        int
+	SyncsScheduled     int // syncs created or updated
+	SyncsUnscheduled   int
+}
+
+// Reconcile makes the database match the given manifest, in a single transaction, so that a manifest
+// that fails to apply (e.g. referencing a provider that doesn't exist) leaves the database untouched
+func Reconcile(ctx context.Context, pool *pgxpool.Pool, m *Manifest) (*Result, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+
+	var res Result
+	for _, p := range m.Providers {
+		if err = reconcileProvider(ctx, tx, &p, &res); err != nil {
+			return nil, errors.Wrapf(err, "failed to reconcile provider %q", p.Name)
+		}
+	}
+
+	var listed = make([]uuid.UUID, 0, len(m.Repos))
+	for _, r := range m.Repos {
+		id, err := reconcileRepo(ctx, tx, &r, &res)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to reconcile repo %q", r.Repo)
+		}
+		listed = append(listed, id)
+	}
+
+	if m.Prune {
+		const prune = `DELETE FROM public.repos WHERE id IN (SELECT repo_id FROM mergestat.manifest_repos) AND id <> ALL($1::UUID[])`
+		tag, err := tx.Exec(ctx, prune, listed)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to prune repos")
+		}
+		res.ReposPruned = int(tag.RowsAffected())
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return &res, nil
+}
+
+// reconcileProvider creates or updates the given provider, adding the credentials the manifest lists for it that it
+// doesn't have yet, and removing the ones added by the manifest that it doesn't list anymore
+func reconcileProvider(ctx context.Context, tx pgx.Tx, p *Provider, res *Result) error {
+	var settings []byte
+	if p.Settings != nil {
+		var err error
+		if settings, err = json.Marshal(jsonValue(p.Settings)); err != nil {
+			return errors.Wrapf(err, "invalid settings")
+		}
+	}
+
+	var tenant = p.Tenant
+	if tenant == "" {
+		tenant = "default"
+	}
+
+	// tenants are only added if missing, as workers connecting as a least-privilege user can't add them
+	var exists bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM mergestat.tenants WHERE name = $1)`, tenant).Scan(&exists); err != nil {
+		return err
+	} else if !exists {
+		if _, err = tx.Exec(ctx, `INSERT INTO mergestat.tenants (name) VALUES ($1)`, tenant); err != nil {
+			return errors.Wrapf(err, "failed to add tenant %q", tenant)
+		}
+	}
+
+	const upsert = `
+		INSERT INTO mergestat.providers (name, vendor, tenant, settings) VALUES ($1, $2, $3, COALESCE($4::JSONB, '{}'::JSONB))
+		ON CONFLICT (name) DO UPDATE SET vendor = EXCLUDED.vendor, tenant = EXCLUDED.tenant, settings = COALESCE($4::JSONB, providers.settings)
+		RETURNING id`
+	var id uuid.UUID
+	if err := tx.QueryRow(ctx, upsert, p.Name, p.Vendor, tenant, settings).Scan(&id); err != nil {
+		return err
+	}
+	res.Providers++
+
+	var secret = os.Getenv("ENCRYPTION_SECRET")
+	var digests = make([]string, 0, len(p.Credentials))
+	for i, c := range p.Credentials {
+		var token = c.Secret
+		if c.Env != "" {
+			if token = os.Getenv(c.Env); token == "" {
+				return errors.Errorf("env var %s of credentials[%d] is not set", c.Env, i)
+			}
+		}
+
+		var digest = credentialDigest(secret, &c, token)
+		digests = append(digests, digest)
+
+		var exists bool
+		const find = `SELECT EXISTS (SELECT 1 FROM mergestat.service_auth_credentials WHERE provider = $1 AND manifest_digest = $2)`
+		if err := tx.QueryRow(ctx, find, id, digest).Scan(&exists); err != nil {
+			return err
+		} else if exists {
+			continue
+		}
+
+		var username *string
+		if c.Username != "" {
+			username = &c.Username
+		}
+
+		const add = `
+			UPDATE mergestat.service_auth_credentials SET manifest_digest = $6
+			WHERE id = (SELECT (mergestat.add_service_auth_credential($1, $2, $3, $4, $5)).id)`
+		if _, err := tx.Exec(ctx, add, id, c.Type, username, token, secret, digest); err != nil {
+			return errors.Wrapf(err, "failed to add credentials[%d]", i)
+		}
+		res.CredentialsAdded++
+	}
+
+	const remove = `
+		DELETE FROM mergestat.service_auth_credentials
+		WHERE provider = $1 AND manifest_digest IS NOT NULL AND manifest_digest <> ALL($2::TEXT[])`
+	tag, err := tx.Exec(ctx, remove, id, digests)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove credentials")
+	}
+	res.CredentialsRemoved += int(tag.RowsAffected())
+	return nil
+}
+
+// reconcileRepo adds the given repo if it doesn't exist yet (marking it as managed by the manifest either way), and
+// schedules the syncs the manifest lists for it, unscheduling the ones it doesn't list. It returns the id of the repo.
+func reconcileRepo(ctx context.Context, tx pgx.Tx, r *Repo, res *Result) (id uuid.UUID, err error) {
+	var provider uuid.UUID
+	if err = tx.QueryRow(ctx, `SELECT id FROM mergestat.providers WHERE name = $1`, r.Provider).Scan(&provider); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return id, errors.Errorf("provider %q does not exist", r.Provider)
+		}
+		return id, err
+	}
+
+	// only a repo of the provider (and so of its tenant) is adopted, as the same url may be a repo of another tenant,
+	// which the manifest must never manage (and prune)
+	const find = `SELECT id, provider = $2 FROM public.repos WHERE repo = $1 AND ref IS NULL`
+	var ofProvider bool
+	if err = tx.QueryRow(ctx, find, r.Repo, provider).Scan(&id, &ofProvider); errors.Is(err, pgx.ErrNoRows) {
+		const add = `INSERT INTO public.repos (repo, provider) VALUES ($1, $2) RETURNING id`
+		if err = tx.QueryRow(ctx, add, r.Repo, provider).Scan(&id); err != nil {
+			return id, err
+		}
+		res.ReposAdded++
+	} else if err != nil {
+		return id, err
+	} else if !ofProvider {
+		return uuid.Nil, errors.Errorf("repo %s already exists with another provider", r.Repo)
+	}
+
+	const manage = `INSERT INTO mergestat.manifest_repos (repo_id) VALUES ($1) ON CONFLICT DO NOTHING`
+	if _, err = tx.Exec(ctx, manage, id); err != nil {
+		return id, err
+	}
+
+	var syncTypes = make([]string, 0, len(r.Syncs))
+	for _, s := range r.Syncs {
+		var settings = []byte(`{}`)
+		if s.Settings != nil {
+			if settings, err = json.Marshal(jsonValue(s.Settings)); err != nil {
+				return id, errors.Wrapf(err, "invalid settings of sync %s", s.Type)
+			}
+		}
+
+		var schedule *string
+		if s.Schedule != "" {
+			schedule = &s.Schedule
+		}
+
+		const upsert = `
+			INSERT INTO mergestat.repo_syncs (repo_id, sync_type, priority, schedule_enabled, schedule, settings)
+			SELECT $1, type, priority, TRUE, $3, $4::JSONB FROM mergestat.repo_sync_types WHERE type = $2
+			ON CONFLICT ON CONSTRAINT repo_syncs_repo_id_sync_type_key
+			DO UPDATE SET schedule_enabled = TRUE, schedule = EXCLUDED.schedule, settings = EXCLUDED.settings`
+		tag, err := tx.Exec(ctx, upsert, id, s.Type, schedule, settings)
+		if err != nil {
+			return id, errors.Wrapf(err, "failed to schedule sync %s", s.Type)
+		} else if tag.RowsAffected() == 0 {
+			return id, errors.Errorf("sync type %s does not exist", s.Type)
+		}
+		syncTypes = append(syncTypes, s.Type)
+		res.SyncsScheduled++
+	}
+
+	const unschedule = `
+		UPDATE mergestat.repo_syncs SET schedule_enabled = FALSE
+		WHERE repo_id = $1 AND schedule_enabled AND sync_type <> ALL($2::TEXT[])`
+	tag, err := tx.Exec(ctx, unschedule, id, syncTypes)
+	if err != nil {
+		return id, errors.Wrapf(err, "failed to unschedule syncs")
+	}
+	res.SyncsUnscheduled += int(tag.RowsAffected())
+	return id, nil
+}
+
+// credentialDigest returns the digest of a credential, which identifies it (without revealing it) so that it's only
+// added once. It's keyed with ENCRYPTION_SECRET, as the digest of a short token could otherwise be brute-forced.
+func credentialDigest(secret string, c *Credential, token string) string {
+	var mac = hmac.New(sha256.New, []byte(secret))
+	for _, part := range []string{c.Type, c.Username, token} {
+		mac.Write([]byte(part))
+		mac.Write([]byte{0})
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
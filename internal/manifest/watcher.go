@@ -0,0 +1,70 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+type watcher struct {
+	logger *zerolog.Logger
+	pool   *pgxpool.Pool
+	path   string
+
+	applied []byte // the contents of the manifest last reconciled successfully
+}
+
+// New returns a routine reconciling the database against the manifest at the given path
+func New(logger *zerolog.Logger, pool *pgxpool.Pool, path string) *watcher {
+	return &watcher{logger: logger, pool: pool, path: path}
+}
+
+// Start reconciles the database against the manifest right away, and then whenever its contents change, checking for
+// changes on every interval. Polling (rather than watching the file) also picks up the changes of a mounted ConfigMap,
+// which are applied by swapping a symlink. A manifest that fails to apply is retried on every interval.
+func (w *watcher) Start(ctx context.Context, interval time.Duration) {
+	w.logger.Info().Str("path", w.path).Msg("starting manifest routine")
+
+	exec := func() {
+		m, contents, err := Load(w.path)
+		if err != nil {
+			w.logger.Err(err).Msgf("error loading manifest: %v", err)
+			return
+		}
+
+		if w.applied != nil && bytes.Equal(contents, w.applied) {
+			return
+		}
+
+		res, err := Reconcile(ctx, w.pool, m)
+		if err != nil {
+			w.logger.Err(err).Msgf("error reconciling manifest: %v", err)
+			return
+		}
+		w.applied = contents
+
+		w.logger.Info().Str("path", w.path).
+			Int("providers", res.Providers).
+			Int("credentials_added", res.CredentialsAdded).
+			Int("credentials_removed", res.CredentialsRemoved).
+			Int("repos_added", res.ReposAdded).
+			Int("repos_pruned", res.ReposPruned).
+			Int("syncs_scheduled", res.SyncsScheduled).
+			Int("syncs_unscheduled", res.SyncsUnscheduled).
+			Msg("reconciled manifest")
+	}
+	exec()
+
+	for {
+		select {
+		case <-ctx.Done():
+			w.logger.Info().Msg("stopping manifest routine")
+			return
+		case <-time.After(interval):
+			exec()
+		}
+	}
+}
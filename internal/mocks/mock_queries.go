@@ -6,10 +6,12 @@ package mocks
 
 import (
 	context "context"
+	sql "database/sql"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
 	uuid "github.com/google/uuid"
+	pgtype "github.com/jackc/pgtype"
 	pgx "github.com/jackc/pgx/v4"
 	db "github.com/mergestat/mergestat/internal/db"
 	queries "github.com/mergestat/mergestat/queries"
@@ -38,6 +40,20 @@ func (m *MockQuerier) EXPECT() *MockQuerierMockRecorder {
 	return m.recorder
 }
 
+// AppendSyncJobCheckpointRows mocks base method.
+func (m *MockQuerier) AppendSyncJobCheckpointRows(ctx context.Context, arg db.AppendSyncJobCheckpointRowsParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AppendSyncJobCheckpointRows", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AppendSyncJobCheckpointRows indicates an expected call of AppendSyncJobCheckpointRows.
+func (mr *MockQuerierMockRecorder) AppendSyncJobCheckpointRows(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AppendSyncJobCheckpointRows", reflect.TypeOf((*MockQuerier)(nil).AppendSyncJobCheckpointRows), ctx, arg)
+}
+
 // CheckRunningImps mocks base method.
 func (m *MockQuerier) CheckRunningImps(ctx context.Context) (int64, error) {
 	m.ctrl.T.Helper()
@@ -67,20 +83,6 @@ func (mr *MockQuerierMockRecorder) CleanOldJobs(ctx, dollar_1 interface{}) *gomo
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanOldJobs", reflect.TypeOf((*MockQuerier)(nil).CleanOldJobs), ctx, dollar_1)
 }
 
-// CleanOldRepoSyncQueue mocks base method.
-func (m *MockQuerier) CleanOldRepoSyncQueue(ctx context.Context, dollar_1 int32) error {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "CleanOldRepoSyncQueue", ctx, dollar_1)
-	ret0, _ := ret[0].(error)
-	return ret0
-}
-
-// CleanOldRepoSyncQueue indicates an expected call of CleanOldRepoSyncQueue.
-func (mr *MockQuerierMockRecorder) CleanOldRepoSyncQueue(ctx, dollar_1 interface{}) *gomock.Call {
-	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CleanOldRepoSyncQueue", reflect.TypeOf((*MockQuerier)(nil).CleanOldRepoSyncQueue), ctx, dollar_1)
-}
-
 // DeleteGitHubRepoInfo mocks base method.
 func (m *MockQuerier) DeleteGitHubRepoInfo(ctx context.Context, repoID uuid.UUID) error {
 	m.ctrl.T.Helper()
@@ -109,19 +111,48 @@ func (mr *MockQuerierMockRecorder) DeleteRemovedRepos(ctx, arg interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteRemovedRepos", reflect.TypeOf((*MockQuerier)(nil).DeleteRemovedRepos), ctx, arg)
 }
 
+// DeleteSyncJobCheckpoint mocks base method.
+func (m *MockQuerier) DeleteSyncJobCheckpoint(ctx context.Context, repoSyncQueueID int64) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSyncJobCheckpoint", ctx, repoSyncQueueID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSyncJobCheckpoint indicates an expected call of DeleteSyncJobCheckpoint.
+func (mr *MockQuerierMockRecorder) DeleteSyncJobCheckpoint(ctx, repoSyncQueueID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSyncJobCheckpoint", reflect.TypeOf((*MockQuerier)(nil).DeleteSyncJobCheckpoint), ctx, repoSyncQueueID)
+}
+
+// DequeueRepoSyncJobs mocks base method.
+func (m *MockQuerier) DequeueRepoSyncJobs(ctx context.Context, arg db.DequeueRepoSyncJobsParams) ([]db.DequeueRepoSyncJobsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DequeueRepoSyncJobs", ctx, arg)
+	ret0, _ := ret[0].([]db.DequeueRepoSyncJobsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DequeueRepoSyncJobs indicates an expected call of DequeueRepoSyncJobs.
+func (mr *MockQuerierMockRecorder) DequeueRepoSyncJobs(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DequeueRepoSyncJobs", reflect.TypeOf((*MockQuerier)(nil).DequeueRepoSyncJobs), ctx, arg)
+}
+
 // DequeueSyncJob mocks base method.
-func (m *MockQuerier) DequeueSyncJob(ctx context.Context) (db.DequeueSyncJobRow, error) {
+func (m *MockQuerier) DequeueSyncJob(ctx context.Context, tenant sql.NullString) (db.DequeueSyncJobRow, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DequeueSyncJob", ctx)
+	ret := m.ctrl.Call(m, "DequeueSyncJob", ctx, tenant)
 	ret0, _ := ret[0].(db.DequeueSyncJobRow)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // DequeueSyncJob indicates an expected call of DequeueSyncJob.
-func (mr *MockQuerierMockRecorder) DequeueSyncJob(ctx interface{}) *gomock.Call {
+func (mr *MockQuerierMockRecorder) DequeueSyncJob(ctx, tenant interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DequeueSyncJob", reflect.TypeOf((*MockQuerier)(nil).DequeueSyncJob), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DequeueSyncJob", reflect.TypeOf((*MockQuerier)(nil).DequeueSyncJob), ctx, tenant)
 }
 
 // EnableContainerSync mocks base method.
@@ -152,6 +183,66 @@ func (mr *MockQuerierMockRecorder) EnqueueAllSyncs(ctx interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueAllSyncs", reflect.TypeOf((*MockQuerier)(nil).EnqueueAllSyncs), ctx)
 }
 
+// EnqueueRepoSyncs mocks base method.
+func (m *MockQuerier) EnqueueRepoSyncs(ctx context.Context, arg db.EnqueueRepoSyncsParams) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueRepoSyncs", ctx, arg)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnqueueRepoSyncs indicates an expected call of EnqueueRepoSyncs.
+func (mr *MockQuerierMockRecorder) EnqueueRepoSyncs(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueRepoSyncs", reflect.TypeOf((*MockQuerier)(nil).EnqueueRepoSyncs), ctx, arg)
+}
+
+// EnqueueScheduledSync mocks base method.
+func (m *MockQuerier) EnqueueScheduledSync(ctx context.Context, arg db.EnqueueScheduledSyncParams) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EnqueueScheduledSync", ctx, arg)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EnqueueScheduledSync indicates an expected call of EnqueueScheduledSync.
+func (mr *MockQuerierMockRecorder) EnqueueScheduledSync(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EnqueueScheduledSync", reflect.TypeOf((*MockQuerier)(nil).EnqueueScheduledSync), ctx, arg)
+}
+
+// EstimateRepoSize mocks base method.
+func (m *MockQuerier) EstimateRepoSize(ctx context.Context, repoID uuid.UUID) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EstimateRepoSize", ctx, repoID)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EstimateRepoSize indicates an expected call of EstimateRepoSize.
+func (mr *MockQuerierMockRecorder) EstimateRepoSize(ctx, repoID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EstimateRepoSize", reflect.TypeOf((*MockQuerier)(nil).EstimateRepoSize), ctx, repoID)
+}
+
+// FailSyncJob mocks base method.
+func (m *MockQuerier) FailSyncJob(ctx context.Context, arg db.FailSyncJobParams) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FailSyncJob", ctx, arg)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FailSyncJob indicates an expected call of FailSyncJob.
+func (mr *MockQuerierMockRecorder) FailSyncJob(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FailSyncJob", reflect.TypeOf((*MockQuerier)(nil).FailSyncJob), ctx, arg)
+}
+
 // FetchContainerSync mocks base method.
 func (m *MockQuerier) FetchContainerSync(ctx context.Context, id uuid.UUID) (db.FetchContainerSyncRow, error) {
 	m.ctrl.T.Helper()
@@ -257,6 +348,81 @@ func (mr *MockQuerierMockRecorder) GetRepoUrlFromImport(ctx, importid interface{
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepoUrlFromImport", reflect.TypeOf((*MockQuerier)(nil).GetRepoUrlFromImport), ctx, importid)
 }
 
+// GetRepoProviderSettings mocks base method.
+func (m *MockQuerier) GetRepoProviderSettings(ctx context.Context, id uuid.UUID) (pgtype.JSONB, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRepoProviderSettings", ctx, id)
+	ret0, _ := ret[0].(pgtype.JSONB)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRepoProviderSettings indicates an expected call of GetRepoProviderSettings.
+func (mr *MockQuerierMockRecorder) GetRepoProviderSettings(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepoProviderSettings", reflect.TypeOf((*MockQuerier)(nil).GetRepoProviderSettings), ctx, id)
+}
+
+// GetRepoSyncWatermark mocks base method.
+func (m *MockQuerier) GetRepoSyncWatermark(ctx context.Context, repoSyncID uuid.UUID) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRepoSyncWatermark", ctx, repoSyncID)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRepoSyncWatermark indicates an expected call of GetRepoSyncWatermark.
+func (mr *MockQuerierMockRecorder) GetRepoSyncWatermark(ctx, repoSyncID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepoSyncWatermark", reflect.TypeOf((*MockQuerier)(nil).GetRepoSyncWatermark), ctx, repoSyncID)
+}
+
+// GetRepoVendor mocks base method.
+func (m *MockQuerier) GetRepoVendor(ctx context.Context, id uuid.UUID) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRepoVendor", ctx, id)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRepoVendor indicates an expected call of GetRepoVendor.
+func (mr *MockQuerierMockRecorder) GetRepoVendor(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRepoVendor", reflect.TypeOf((*MockQuerier)(nil).GetRepoVendor), ctx, id)
+}
+
+// GetSyncJobCheckpoint mocks base method.
+func (m *MockQuerier) GetSyncJobCheckpoint(ctx context.Context, repoSyncQueueID int64) (pgtype.JSONB, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSyncJobCheckpoint", ctx, repoSyncQueueID)
+	ret0, _ := ret[0].(pgtype.JSONB)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSyncJobCheckpoint indicates an expected call of GetSyncJobCheckpoint.
+func (mr *MockQuerierMockRecorder) GetSyncJobCheckpoint(ctx, repoSyncQueueID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSyncJobCheckpoint", reflect.TypeOf((*MockQuerier)(nil).GetSyncJobCheckpoint), ctx, repoSyncQueueID)
+}
+
+// GetSyncTypeTimeout mocks base method.
+func (m *MockQuerier) GetSyncTypeTimeout(ctx context.Context, syncType string) (sql.NullInt32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSyncTypeTimeout", ctx, syncType)
+	ret0, _ := ret[0].(sql.NullInt32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSyncTypeTimeout indicates an expected call of GetSyncTypeTimeout.
+func (mr *MockQuerierMockRecorder) GetSyncTypeTimeout(ctx, syncType interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSyncTypeTimeout", reflect.TypeOf((*MockQuerier)(nil).GetSyncTypeTimeout), ctx, syncType)
+}
+
 // InsertGitHubRepoInfo mocks base method.
 func (m *MockQuerier) InsertGitHubRepoInfo(ctx context.Context, arg db.InsertGitHubRepoInfoParams) error {
 	m.ctrl.T.Helper()
@@ -299,6 +465,51 @@ func (mr *MockQuerierMockRecorder) InsertSyncJobLog(ctx, arg interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertSyncJobLog", reflect.TypeOf((*MockQuerier)(nil).InsertSyncJobLog), ctx, arg)
 }
 
+// ListDueScheduledSyncs mocks base method.
+func (m *MockQuerier) ListDueScheduledSyncs(ctx context.Context) ([]db.ListDueScheduledSyncsRow, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListDueScheduledSyncs", ctx)
+	ret0, _ := ret[0].([]db.ListDueScheduledSyncsRow)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListDueScheduledSyncs indicates an expected call of ListDueScheduledSyncs.
+func (mr *MockQuerierMockRecorder) ListDueScheduledSyncs(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListDueScheduledSyncs", reflect.TypeOf((*MockQuerier)(nil).ListDueScheduledSyncs), ctx)
+}
+
+// ListFailedSyncDependencies mocks base method.
+func (m *MockQuerier) ListFailedSyncDependencies(ctx context.Context, arg db.ListFailedSyncDependenciesParams) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListFailedSyncDependencies", ctx, arg)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListFailedSyncDependencies indicates an expected call of ListFailedSyncDependencies.
+func (mr *MockQuerierMockRecorder) ListFailedSyncDependencies(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListFailedSyncDependencies", reflect.TypeOf((*MockQuerier)(nil).ListFailedSyncDependencies), ctx, arg)
+}
+
+// ListSyncJobCheckpointRows mocks base method.
+func (m *MockQuerier) ListSyncJobCheckpointRows(ctx context.Context, arg db.ListSyncJobCheckpointRowsParams) ([]pgtype.JSONB, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListSyncJobCheckpointRows", ctx, arg)
+	ret0, _ := ret[0].([]pgtype.JSONB)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListSyncJobCheckpointRows indicates an expected call of ListSyncJobCheckpointRows.
+func (mr *MockQuerierMockRecorder) ListSyncJobCheckpointRows(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListSyncJobCheckpointRows", reflect.TypeOf((*MockQuerier)(nil).ListSyncJobCheckpointRows), ctx, arg)
+}
+
 // ListRepoImportsDueForImport mocks base method.
 func (m *MockQuerier) ListRepoImportsDueForImport(ctx context.Context) ([]db.ListRepoImportsDueForImportRow, error) {
 	m.ctrl.T.Helper()
@@ -328,19 +539,105 @@ func (mr *MockQuerierMockRecorder) MarkRepoImportAsUpdated(ctx, id interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkRepoImportAsUpdated", reflect.TypeOf((*MockQuerier)(nil).MarkRepoImportAsUpdated), ctx, id)
 }
 
-// MarkSyncsAsTimedOut mocks base method.
-func (m *MockQuerier) MarkSyncsAsTimedOut(ctx context.Context) ([]int64, error) {
+// MarkRepoReachable mocks base method.
+func (m *MockQuerier) MarkRepoReachable(ctx context.Context, repoID uuid.UUID) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "MarkSyncsAsTimedOut", ctx)
+	ret := m.ctrl.Call(m, "MarkRepoReachable", ctx, repoID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkRepoReachable indicates an expected call of MarkRepoReachable.
+func (mr *MockQuerierMockRecorder) MarkRepoReachable(ctx, repoID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkRepoReachable", reflect.TypeOf((*MockQuerier)(nil).MarkRepoReachable), ctx, repoID)
+}
+
+// NotifySyncCompleted mocks base method.
+func (m *MockQuerier) NotifySyncCompleted(ctx context.Context, event string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NotifySyncCompleted", ctx, event)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NotifySyncCompleted indicates an expected call of NotifySyncCompleted.
+func (mr *MockQuerierMockRecorder) NotifySyncCompleted(ctx, event interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NotifySyncCompleted", reflect.TypeOf((*MockQuerier)(nil).NotifySyncCompleted), ctx, event)
+}
+
+// PauseReposMissingFromImport mocks base method.
+func (m *MockQuerier) PauseReposMissingFromImport(ctx context.Context, arg db.PauseReposMissingFromImportParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PauseReposMissingFromImport", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PauseReposMissingFromImport indicates an expected call of PauseReposMissingFromImport.
+func (mr *MockQuerierMockRecorder) PauseReposMissingFromImport(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseReposMissingFromImport", reflect.TypeOf((*MockQuerier)(nil).PauseReposMissingFromImport), ctx, arg)
+}
+
+// PruneRepoSyncQueue mocks base method.
+func (m *MockQuerier) PruneRepoSyncQueue(ctx context.Context, batchSize int32) (int32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneRepoSyncQueue", ctx, batchSize)
+	ret0, _ := ret[0].(int32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PruneRepoSyncQueue indicates an expected call of PruneRepoSyncQueue.
+func (mr *MockQuerierMockRecorder) PruneRepoSyncQueue(ctx, batchSize interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneRepoSyncQueue", reflect.TypeOf((*MockQuerier)(nil).PruneRepoSyncQueue), ctx, batchSize)
+}
+
+// RecordRepoUnreachable mocks base method.
+func (m *MockQuerier) RecordRepoUnreachable(ctx context.Context, arg db.RecordRepoUnreachableParams) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RecordRepoUnreachable", ctx, arg)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RecordRepoUnreachable indicates an expected call of RecordRepoUnreachable.
+func (mr *MockQuerierMockRecorder) RecordRepoUnreachable(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RecordRepoUnreachable", reflect.TypeOf((*MockQuerier)(nil).RecordRepoUnreachable), ctx, arg)
+}
+
+// RequeueStaleSyncJobs mocks base method.
+func (m *MockQuerier) RequeueStaleSyncJobs(ctx context.Context) ([]int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RequeueStaleSyncJobs", ctx)
 	ret0, _ := ret[0].([]int64)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// MarkSyncsAsTimedOut indicates an expected call of MarkSyncsAsTimedOut.
-func (mr *MockQuerierMockRecorder) MarkSyncsAsTimedOut(ctx interface{}) *gomock.Call {
+// RequeueStaleSyncJobs indicates an expected call of RequeueStaleSyncJobs.
+func (mr *MockQuerierMockRecorder) RequeueStaleSyncJobs(ctx interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkSyncsAsTimedOut", reflect.TypeOf((*MockQuerier)(nil).MarkSyncsAsTimedOut), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RequeueStaleSyncJobs", reflect.TypeOf((*MockQuerier)(nil).RequeueStaleSyncJobs), ctx)
+}
+
+// ResumeReposOfImport mocks base method.
+func (m *MockQuerier) ResumeReposOfImport(ctx context.Context, arg db.ResumeReposOfImportParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeReposOfImport", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResumeReposOfImport indicates an expected call of ResumeReposOfImport.
+func (mr *MockQuerierMockRecorder) ResumeReposOfImport(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeReposOfImport", reflect.TypeOf((*MockQuerier)(nil).ResumeReposOfImport), ctx, arg)
 }
 
 // SetLatestKeepAliveForJob mocks base method.
@@ -357,6 +654,20 @@ func (mr *MockQuerierMockRecorder) SetLatestKeepAliveForJob(ctx, id interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetLatestKeepAliveForJob", reflect.TypeOf((*MockQuerier)(nil).SetLatestKeepAliveForJob), ctx, id)
 }
 
+// SetSyncJobResourceUsage mocks base method.
+func (m *MockQuerier) SetSyncJobResourceUsage(ctx context.Context, arg db.SetSyncJobResourceUsageParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetSyncJobResourceUsage", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetSyncJobResourceUsage indicates an expected call of SetSyncJobResourceUsage.
+func (mr *MockQuerierMockRecorder) SetSyncJobResourceUsage(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSyncJobResourceUsage", reflect.TypeOf((*MockQuerier)(nil).SetSyncJobResourceUsage), ctx, arg)
+}
+
 // SetSyncJobStatus mocks base method.
 func (m *MockQuerier) SetSyncJobStatus(ctx context.Context, arg db.SetSyncJobStatusParams) error {
 	m.ctrl.T.Helper()
@@ -385,6 +696,20 @@ func (mr *MockQuerierMockRecorder) UpdateImportStatus(ctx, arg interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateImportStatus", reflect.TypeOf((*MockQuerier)(nil).UpdateImportStatus), ctx, arg)
 }
 
+// UpdateRepoURL mocks base method.
+func (m *MockQuerier) UpdateRepoURL(ctx context.Context, arg db.UpdateRepoURLParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRepoURL", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRepoURL indicates an expected call of UpdateRepoURL.
+func (mr *MockQuerierMockRecorder) UpdateRepoURL(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRepoURL", reflect.TypeOf((*MockQuerier)(nil).UpdateRepoURL), ctx, arg)
+}
+
 // UpsertRepo mocks base method.
 func (m *MockQuerier) UpsertRepo(ctx context.Context, arg db.UpsertRepoParams) error {
 	m.ctrl.T.Helper()
@@ -399,6 +724,34 @@ func (mr *MockQuerierMockRecorder) UpsertRepo(ctx, arg interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertRepo", reflect.TypeOf((*MockQuerier)(nil).UpsertRepo), ctx, arg)
 }
 
+// UpsertRepoSyncWatermark mocks base method.
+func (m *MockQuerier) UpsertRepoSyncWatermark(ctx context.Context, arg db.UpsertRepoSyncWatermarkParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertRepoSyncWatermark", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertRepoSyncWatermark indicates an expected call of UpsertRepoSyncWatermark.
+func (mr *MockQuerierMockRecorder) UpsertRepoSyncWatermark(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertRepoSyncWatermark", reflect.TypeOf((*MockQuerier)(nil).UpsertRepoSyncWatermark), ctx, arg)
+}
+
+// UpsertSyncJobCheckpoint mocks base method.
+func (m *MockQuerier) UpsertSyncJobCheckpoint(ctx context.Context, arg db.UpsertSyncJobCheckpointParams) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpsertSyncJobCheckpoint", ctx, arg)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpsertSyncJobCheckpoint indicates an expected call of UpsertSyncJobCheckpoint.
+func (mr *MockQuerierMockRecorder) UpsertSyncJobCheckpoint(ctx, arg interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpsertSyncJobCheckpoint", reflect.TypeOf((*MockQuerier)(nil).UpsertSyncJobCheckpoint), ctx, arg)
+}
+
 // UpsertWorkflowRunJobs mocks base method.
 func (m *MockQuerier) UpsertWorkflowRunJobs(ctx context.Context, arg db.UpsertWorkflowRunJobsParams) error {
 	m.ctrl.T.Helper()
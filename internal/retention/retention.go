@@ -0,0 +1,84 @@
+// Package retention prunes the history of sync jobs (along with their logs) and of background jobs, which would
+// otherwise grow unbounded. Sync jobs are pruned based on the retention policy in mergestat.repo_sync_retention
+// (which can be overridden per repo in mergestat.repo_sync_retention_overrides).
+package retention
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/rs/zerolog"
+)
+
+// batchSize is the number of sync jobs pruned per statement, so that pruning a large backlog
+// of history doesn't hold locks on (or bloat) the queue for long
+const batchSize = 1000
+
+type retention struct {
+	logger *zerolog.Logger
+	pool   *pgxpool.Pool
+	db     *db.Queries
+	days   int
+}
+
+// New returns a retention routine keeping sqlq jobs for the given number of days, which can be 0 (or less) to skip all pruning
+func New(logger *zerolog.Logger, pool *pgxpool.Pool, days int) *retention {
+	return &retention{
+		logger: logger,
+		pool:   pool,
+		db:     db.New(pool),
+		days:   days,
+	}
+}
+
+func (r *retention) Start(ctx context.Context, interval time.Duration) {
+	r.logger.Info().Msg("starting retention routine")
+
+	if r.days <= 0 {
+		r.logger.Info().Msg("REPO_SYNC_QUEUE_RETENTION_DAYS is not positive, skipping retention routine")
+		return
+	}
+
+	exec := func() {
+		r.pruneSyncJobs(ctx)
+
+		if err := r.db.CleanOldJobs(ctx, int32(r.days)); err != nil {
+			r.logger.Err(err).Msg("encountered error cleaning sqlq logs")
+		} else {
+			r.logger.Info().Msgf("successfully removed sqlq jobs older than %d days", r.days)
+		}
+	}
+	exec()
+
+	for {
+		select {
+		case <-ctx.Done():
+			r.logger.Info().Msg("stopping retention routine")
+			return
+		case <-time.After(interval):
+			exec()
+		}
+	}
+}
+
+// pruneSyncJobs prunes the sync jobs that fall outside of the retention policy, a batch at a time
+func (r *retention) pruneSyncJobs(ctx context.Context) {
+	var pruned int
+	for {
+		n, err := r.db.PruneRepoSyncQueue(ctx, batchSize)
+		if err != nil {
+			r.logger.Err(err).Msg("encountered error pruning repo sync jobs")
+			break
+		}
+
+		if pruned += int(n); n < batchSize || ctx.Err() != nil {
+			break
+		}
+	}
+
+	if pruned > 0 {
+		r.logger.Info().Msgf("pruned %d repo sync job(s) outside of the retention policy", pruned)
+	}
+}
@@ -2,27 +2,40 @@ package scheduler
 
 import (
 	"context"
-	"os"
-	"strconv"
+	"sync/atomic"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/mergestat/mergestat/internal/db"
+	"github.com/robfig/cron/v3"
 	"github.com/rs/zerolog"
 )
 
 type scheduler struct {
-	logger *zerolog.Logger
-	pool   *pgxpool.Pool
-	db     *db.Queries
+	logger  *zerolog.Logger
+	pool    *pgxpool.Pool
+	db      *db.Queries
+	lastRun atomic.Int64 // unix nanos of when the scheduler last ran (see LastRun)
+
+	invalidSchedules map[uuid.UUID]string // the invalid schedules of repo syncs that were reported already
 }
 
 func New(logger *zerolog.Logger, pool *pgxpool.Pool) *scheduler {
-	return &scheduler{
+	var s = &scheduler{
 		logger: logger,
 		pool:   pool,
 		db:     db.New(pool),
+
+		invalidSchedules: make(map[uuid.UUID]string),
 	}
+	s.lastRun.Store(time.Now().UnixNano()) // counts as alive until it's due to run
+	return s
+}
+
+// LastRun returns when the scheduler last ran, which tells whether it's still alive (see health.Heartbeat)
+func (s *scheduler) LastRun() time.Time {
+	return time.Unix(0, s.lastRun.Load())
 }
 
 func (s *scheduler) Start(ctx context.Context, interval time.Duration) {
@@ -34,30 +47,8 @@ func (s *scheduler) Start(ctx context.Context, interval time.Duration) {
 			s.logger.Info().Msg("re-scheduling all completed syncs to run again")
 		}
 
-		// TODO(patrickdevivo) this should probably be lifted up into a config/param
-		// of the scheduler, which is passed into New and defined by the caller
-		retentionPeriodDays := 30
-		if days := os.Getenv("REPO_SYNC_QUEUE_RETENTION_DAYS"); days != "" {
-			var err error
-			if retentionPeriodDays, err = strconv.Atoi(days); err != nil {
-				s.logger.Err(err).Msgf("could not parse REPO_SYNC_QUEUE_RETENTION_DAYS env: %v", err)
-			}
-		}
-
-		// allows for REPO_SYNC_QUEUE_RETENTION_DAYS=-1 to skip the cleanup
-		if retentionPeriodDays > 0 {
-			if err := s.db.CleanOldRepoSyncQueue(ctx, int32(retentionPeriodDays)); err != nil {
-				s.logger.Err(err).Msg("encountered error cleaning queue logs")
-			} else {
-				s.logger.Info().Msgf("successfully removed repo sync jobs older than %d days", retentionPeriodDays)
-			}
-
-			if err := s.db.CleanOldJobs(ctx, int32(retentionPeriodDays)); err != nil {
-				s.logger.Err(err).Msg("encountered error cleaning sqlq logs")
-			} else {
-				s.logger.Info().Msgf("successfully removed sqlq jobs older than %d days", retentionPeriodDays)
-			}
-		}
+		s.enqueueScheduledSyncs(ctx)
+		s.lastRun.Store(time.Now().UnixNano())
 	}
 	exec()
 
@@ -71,3 +62,44 @@ func (s *scheduler) Start(ctx context.Context, interval time.Duration) {
 		}
 	}
 }
+
+// enqueueScheduledSyncs enqueues the syncs with a schedule of their own that are due, and moves them to their next run.
+// Schedules are standard cron expressions, which also support descriptors such as @weekly and intervals such as @every 15m.
+func (s *scheduler) enqueueScheduledSyncs(ctx context.Context) {
+	syncs, err := s.db.ListDueScheduledSyncs(ctx)
+	if err != nil {
+		s.logger.Err(err).Msg("encountered error listing scheduled syncs")
+		return
+	}
+
+	var now = time.Now()
+	var enqueued int64
+	for _, rs := range syncs {
+		schedule, err := cron.ParseStandard(rs.Schedule.String)
+		if err != nil {
+			// an invalid schedule (written before schedules were checked) is still listed as due on every run, so
+			// it's only reported once, until it's changed
+			if s.invalidSchedules[rs.ID] != rs.Schedule.String {
+				s.logger.Err(err).Msgf("invalid schedule of repo sync %s: %q", rs.ID, rs.Schedule.String)
+				s.invalidSchedules[rs.ID] = rs.Schedule.String
+			}
+			continue
+		}
+		delete(s.invalidSchedules, rs.ID)
+
+		n, err := s.db.EnqueueScheduledSync(ctx, db.EnqueueScheduledSyncParams{
+			NextRunAt:     schedule.Next(now),
+			ID:            rs.ID,
+			PreviousRunAt: rs.NextRunAt,
+		})
+		if err != nil {
+			s.logger.Err(err).Msgf("encountered error enqueuing scheduled repo sync %s", rs.ID)
+			continue
+		}
+		enqueued += n
+	}
+
+	if enqueued > 0 {
+		s.logger.Info().Msgf("enqueued %d scheduled sync(s)", enqueued)
+	}
+}
@@ -0,0 +1,108 @@
+// Package scratch sweeps the scratch space of the worker, removing the temp dirs (and files) left behind by jobs that didn't
+// get to clean up after themselves, e.g. because the worker crashed or was killed mid-sync. Leftovers are only removed once
+// nothing in them was modified for a while, so that the scratch dirs of running jobs are left alone.
+package scratch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// errRecent stops walking a scratch dir as soon as a recently modified file is found in it
+var errRecent = errors.New("recently modified")
+
+type sweeper struct {
+	logger   *zerolog.Logger
+	maxAge   time.Duration
+	patterns []string
+}
+
+// New returns a sweeper removing the scratch dirs (and files) that weren't modified for at least maxAge,
+// from the dir repos are cloned to, the OS temp dir and the clone cache (if enabled, i.e. cacheDir isn't empty)
+func New(logger *zerolog.Logger, maxAge time.Duration, clonePath, cacheDir string) *sweeper {
+	var patterns = []string{
+		filepath.Join(clonePath, "mergestat-repo-*"), // repos cloned by sync jobs
+		filepath.Join(os.TempDir(), "mergestat-*"),   // ssh keys of remotes, and files of container syncs
+	}
+
+	// interrupted clones into the clone cache
+	if cacheDir != "" {
+		patterns = append(patterns, filepath.Join(cacheDir, "clone-*"))
+	}
+
+	return &sweeper{logger: logger, maxAge: maxAge, patterns: patterns}
+}
+
+func (s *sweeper) Start(ctx context.Context, interval time.Duration) {
+	s.logger.Info().Msg("starting scratch sweeper routine")
+	exec := func() {
+		if removed := s.sweep(); removed > 0 {
+			s.logger.Info().Msgf("removed %d orphaned scratch dir(s)", removed)
+		}
+	}
+	exec()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("stopping scratch sweeper routine")
+			return
+		case <-time.After(interval):
+			exec()
+		}
+	}
+}
+
+// sweep removes the scratch dirs (and files) that weren't modified for at least maxAge, returning how many were removed
+func (s *sweeper) sweep() (removed int) {
+	var cutoff = time.Now().Add(-s.maxAge)
+	for _, pattern := range s.patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			s.logger.Err(err).Msgf("invalid scratch dir pattern: %s", pattern)
+			continue
+		}
+
+		for _, path := range matches {
+			if modifiedSince(path, cutoff) {
+				continue
+			}
+
+			if err := os.RemoveAll(path); err != nil {
+				s.logger.Err(err).Msgf("error removing orphaned scratch dir: %s", path)
+				continue
+			}
+			removed++
+		}
+	}
+
+	return removed
+}
+
+// modifiedSince returns whether anything under the given path was modified after the cutoff. Paths that can't be read (or
+// were removed meanwhile, e.g. by the job they belong to) are considered modified, so that they're never removed by mistake.
+func modifiedSince(path string, cutoff time.Time) bool {
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if info.ModTime().After(cutoff) {
+			return errRecent
+		}
+		return nil
+	})
+
+	return err != nil
+}
@@ -0,0 +1,50 @@
+package sealer
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/envelope"
+	"github.com/rs/zerolog"
+)
+
+// sealer periodically re-encrypts credentials added through the app with the worker-held key (see db.SealCredentials)
+type sealer struct {
+	logger  *zerolog.Logger
+	pool    *pgxpool.Pool
+	db      *db.Queries
+	keyring envelope.Keyring
+}
+
+func New(logger *zerolog.Logger, pool *pgxpool.Pool, keyring envelope.Keyring) *sealer {
+	return &sealer{
+		logger:  logger,
+		pool:    pool,
+		db:      db.New(pool),
+		keyring: keyring,
+	}
+}
+
+func (s *sealer) Start(ctx context.Context, interval time.Duration) {
+	s.logger.Info().Msg("starting credential sealing routine")
+	exec := func() {
+		if sealed, err := s.db.SealCredentials(ctx, s.keyring); err != nil {
+			s.logger.Err(err).Msg("encountered error during credential sealing")
+		} else if sealed > 0 {
+			s.logger.Info().Msgf("encrypted %d credential(s) with the worker-held key", sealed)
+		}
+	}
+	exec()
+
+	for {
+		select {
+		case <-ctx.Done():
+			s.logger.Info().Msg("stopping credential sealing routine")
+			return
+		case <-time.After(interval):
+			exec()
+		}
+	}
+}
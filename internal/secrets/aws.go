@@ -0,0 +1,77 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/mergestat/mergestat/internal/sigv4"
+	"github.com/pkg/errors"
+)
+
+// secretsManager fetches secrets from AWS Secrets Manager, signing its requests with AWS Signature Version 4
+type secretsManager struct {
+	region string
+	creds  sigv4.Credentials
+}
+
+// newSecretsManagerFromEnv configures an AWS Secrets Manager backend using the standard AWS_REGION (or AWS_DEFAULT_REGION),
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and (optional) AWS_SESSION_TOKEN env vars
+func newSecretsManagerFromEnv() (Backend, error) {
+	creds, err := sigv4.CredentialsFromEnv()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to configure AWS Secrets Manager")
+	}
+
+	return &secretsManager{region: sigv4.RegionFromEnv(), creds: creds}, nil
+}
+
+// Fetch reads the current value of the secret with the given name or ARN.
+// The region of an ARN takes precedence over the configured region.
+func (sm *secretsManager) Fetch(ctx context.Context, ref string) (*Secret, error) {
+	var region = sm.region
+	if r := sigv4.RegionOfARN(ref); r != "" {
+		region = r
+	}
+
+	if region == "" {
+		return nil, errors.New("AWS_REGION must be set to fetch credentials from AWS Secrets Manager")
+	}
+
+	var host = fmt.Sprintf("secretsmanager.%s.amazonaws.com", region)
+	var payload, _ = json.Marshal(map[string]string{"SecretId": ref})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://"+host+"/", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	sigv4.Sign(req, payload, sm.creds, "secretsmanager", region, time.Now())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch secret from AWS Secrets Manager")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to fetch secret %s from AWS Secrets Manager: unexpected status %s", ref, resp.Status)
+	}
+
+	var body struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode AWS Secrets Manager secret")
+	}
+
+	if body.SecretString == "" {
+		return nil, errors.Errorf("AWS Secrets Manager secret %s has no value", ref)
+	}
+
+	return parse(body.SecretString), nil
+}
@@ -0,0 +1,116 @@
+// Package secrets fetches credentials from external secrets backends (such as HashiCorp Vault or AWS Secrets Manager)
+// at job time, rather than storing them in the database. A credential of one of the external types only stores a
+// reference to the secret (its path, or id), which is resolved by the backend whenever the credential is used.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Secret is a credential fetched from an external secrets backend
+type Secret struct {
+	Username string
+	Token    string
+}
+
+// Backend fetches the secret with the given reference from an external secrets backend
+type Backend interface {
+	Fetch(ctx context.Context, ref string) (*Secret, error)
+}
+
+// backends are the external secrets backends, by the credential type referencing their secrets.
+// Backends are configured from env vars, at the time a credential is fetched.
+var backends = map[string]func() (Backend, error){
+	"VAULT_SECRET":               newVaultFromEnv,
+	"AWS_SECRETS_MANAGER_SECRET": newSecretsManagerFromEnv,
+}
+
+// Register registers a backend for credentials of the given type, e.g. to plug in another secrets manager
+func Register(credentialType string, fn func() (Backend, error)) {
+	backends[credentialType] = fn
+}
+
+// CacheTTLEnv is the env var holding for how long fetched secrets are cached (e.g. 1m, or 0 to not cache them)
+const CacheTTLEnv = "SECRETS_CACHE_TTL"
+
+// defaultCacheTTL is for how long fetched secrets are cached, unless configured otherwise
+const defaultCacheTTL = 5 * time.Minute
+
+// ForType returns the backend for credentials of the given type, or nil if credentials of that type are stored in the database.
+// The secrets fetched by the backend are cached, so that they aren't fetched again for every job using the credential.
+func ForType(credentialType string) (Backend, error) {
+	var fn, ok = backends[credentialType]
+	if !ok {
+		return nil, nil
+	}
+
+	var ttl = defaultCacheTTL
+	if value := os.Getenv(CacheTTLEnv); value != "" {
+		var err error
+		if ttl, err = time.ParseDuration(value); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s", CacheTTLEnv)
+		}
+	}
+
+	backend, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl <= 0 {
+		return backend, nil
+	}
+	return &cachingBackend{credentialType: credentialType, backend: backend, ttl: ttl}, nil
+}
+
+// cachedSecret is a fetched secret, along with when it expires from the cache
+type cachedSecret struct {
+	secret    Secret
+	expiresAt time.Time
+}
+
+// cache caches the fetched secrets by credential type and reference
+var cache sync.Map
+
+// cachingBackend is a Backend caching the secrets fetched by another one, for the given ttl.
+// Failed fetches aren't cached.
+type cachingBackend struct {
+	credentialType string
+	backend        Backend
+	ttl            time.Duration
+}
+
+func (c *cachingBackend) Fetch(ctx context.Context, ref string) (*Secret, error) {
+	var key = c.credentialType + "/" + ref
+	if cached, ok := cache.Load(key); ok && time.Now().Before(cached.(*cachedSecret).expiresAt) {
+		var secret = cached.(*cachedSecret).secret
+		return &secret, nil
+	}
+
+	secret, err := c.backend.Fetch(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.Store(key, &cachedSecret{secret: *secret, expiresAt: time.Now().Add(c.ttl)})
+	return secret, nil
+}
+
+// parse parses the value of a secret, which is either a JSON object (with a token, and optionally a username) or a plain token
+func parse(value string) *Secret {
+	var s struct {
+		Username string `json:"username"`
+		Token    string `json:"token"`
+	}
+	if err := json.Unmarshal([]byte(value), &s); err != nil || s.Token == "" {
+		return &Secret{Token: strings.TrimSpace(value)}
+	}
+	return &Secret{Username: s.Username, Token: s.Token}
+}
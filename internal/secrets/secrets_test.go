@@ -0,0 +1,120 @@
+package secrets
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		name  string
+		value string
+		want  Secret
+	}{
+		{name: "plain token", value: "ghp_token\n", want: Secret{Token: "ghp_token"}},
+		{name: "JSON", value: `{"username": "mergestat", "token": "ghp_token"}`, want: Secret{Username: "mergestat", Token: "ghp_token"}},
+		{name: "JSON without username", value: `{"token": "ghp_token"}`, want: Secret{Token: "ghp_token"}},
+		{name: "JSON without token", value: `{"username": "mergestat"}`, want: Secret{Token: `{"username": "mergestat"}`}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parse(test.value); *got != test.want {
+				t.Errorf("parse() = %+v, want %+v", *got, test.want)
+			}
+		})
+	}
+}
+
+// countingBackend counts its fetches, failing them while err is set
+type countingBackend struct {
+	fetches int
+	err     error
+}
+
+func (b *countingBackend) Fetch(_ context.Context, ref string) (*Secret, error) {
+	b.fetches++
+	if b.err != nil {
+		return nil, b.err
+	}
+	return &Secret{Token: ref}, nil
+}
+
+func TestCachingBackend(t *testing.T) {
+	cache.Range(func(key, _ interface{}) bool { cache.Delete(key); return true })
+
+	var ctx = context.Background()
+	var backend = &countingBackend{}
+	var c = &cachingBackend{credentialType: "TEST_CACHING_SECRET", backend: backend, ttl: 50 * time.Millisecond}
+
+	var fetch = func(ref string) *Secret {
+		secret, err := c.Fetch(ctx, ref)
+		if err != nil {
+			t.Fatalf("Fetch() error = %v", err)
+		}
+		return secret
+	}
+
+	fetch("a")
+	if secret := fetch("a"); secret.Token != "a" || backend.fetches != 1 {
+		t.Errorf("Fetch() = %+v after %d fetches, want the cached secret", secret, backend.fetches)
+	}
+
+	// the cached secret can't be changed by its callers
+	fetch("a").Token = "changed"
+	if secret := fetch("a"); secret.Token != "a" {
+		t.Errorf("Fetch() = %+v, want the cached secret to be unchanged", secret)
+	}
+
+	fetch("b")
+	if backend.fetches != 2 {
+		t.Errorf("Fetch() of another secret made %d fetches, want 2", backend.fetches)
+	}
+
+	// expired secrets are fetched again, and failures aren't cached
+	time.Sleep(60 * time.Millisecond)
+	backend.err = errors.New("unavailable")
+	if _, err := c.Fetch(ctx, "a"); err == nil {
+		t.Errorf("Fetch() of an expired secret, want the error of the backend")
+	}
+
+	backend.err = nil
+	fetch("a")
+	if backend.fetches != 4 {
+		t.Errorf("Fetch() made %d fetches, want 4", backend.fetches)
+	}
+}
+
+func TestForTypeCacheTTL(t *testing.T) {
+	Register("TEST_TTL_SECRET", func() (Backend, error) { return &countingBackend{}, nil })
+
+	var tests = []struct {
+		ttl         string
+		wantCaching bool
+		wantErr     bool
+	}{
+		{ttl: "", wantCaching: true},
+		{ttl: "1m", wantCaching: true},
+		{ttl: "0", wantCaching: false},
+		{ttl: "soon", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Setenv(CacheTTLEnv, test.ttl)
+
+		backend, err := ForType("TEST_TTL_SECRET")
+		if (err != nil) != test.wantErr {
+			t.Fatalf("ForType() with %s=%q error = %v, wantErr %v", CacheTTLEnv, test.ttl, err, test.wantErr)
+		}
+		if _, caching := backend.(*cachingBackend); !test.wantErr && caching != test.wantCaching {
+			t.Errorf("ForType() with %s=%q = %T, want caching %v", CacheTTLEnv, test.ttl, backend, test.wantCaching)
+		}
+	}
+
+	if backend, err := ForType("GITHUB_PAT"); backend != nil || err != nil {
+		t.Errorf("ForType() of a credential stored in the database = %v, %v, want nil", backend, err)
+	}
+}
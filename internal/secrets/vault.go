@@ -0,0 +1,71 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// vault fetches secrets from the KV secrets engine (v1 or v2) of a HashiCorp Vault server
+type vault struct {
+	addr, token, namespace string
+}
+
+// newVaultFromEnv configures a vault backend using the standard VAULT_ADDR, VAULT_TOKEN and VAULT_NAMESPACE env vars
+func newVaultFromEnv() (Backend, error) {
+	var v = &vault{addr: os.Getenv("VAULT_ADDR"), token: os.Getenv("VAULT_TOKEN"), namespace: os.Getenv("VAULT_NAMESPACE")}
+	if v.addr == "" || v.token == "" {
+		return nil, errors.New("VAULT_ADDR and VAULT_TOKEN must be set to fetch credentials from Vault")
+	}
+	return v, nil
+}
+
+// Fetch reads the secret at the given path, e.g. secret/data/mergestat/github (for a KV v2 engine mounted at secret/)
+func (v *vault) Fetch(ctx context.Context, ref string) (*Secret, error) {
+	var url = fmt.Sprintf("%s/v1/%s", strings.TrimSuffix(v.addr, "/"), strings.TrimPrefix(ref, "/"))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.token)
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch secret from Vault")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("failed to fetch secret %s from Vault: unexpected status %s", ref, resp.Status)
+	}
+
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode Vault secret")
+	}
+
+	// the KV v2 engine nests the secret (along with its metadata) under data
+	var data = body.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+
+	var s Secret
+	s.Username, _ = data["username"].(string)
+	s.Token, _ = data["token"].(string)
+	if s.Token == "" {
+		return nil, errors.Errorf("Vault secret %s has no token", ref)
+	}
+
+	return &s, nil
+}
@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVaultFetch(t *testing.T) {
+	var tests = []struct {
+		name         string
+		path         string
+		body         string
+		status       int
+		wantUsername string
+		wantToken    string
+		wantErr      bool
+	}{
+		{
+			name: "KV v1", path: "/v1/kv/mergestat/github",
+			body:         `{"data": {"username": "mergestat", "token": "ghp_v1"}, "lease_duration": 2764800}`,
+			wantUsername: "mergestat", wantToken: "ghp_v1",
+		},
+		{
+			name: "KV v2", path: "/v1/secret/data/mergestat/github",
+			body:         `{"data": {"data": {"username": "mergestat", "token": "ghp_v2"}, "metadata": {"version": 3}}}`,
+			wantUsername: "mergestat", wantToken: "ghp_v2",
+		},
+		{
+			name: "KV v2 without username", path: "/v1/secret/data/mergestat/github",
+			body:      `{"data": {"data": {"token": "ghp_v2"}, "metadata": {"version": 1}}}`,
+			wantToken: "ghp_v2",
+		},
+		{
+			name: "no token", path: "/v1/secret/data/mergestat/github",
+			body:    `{"data": {"data": {"username": "mergestat"}, "metadata": {"version": 1}}}`,
+			wantErr: true,
+		},
+		{
+			name: "not found", path: "/v1/secret/data/mergestat/github",
+			body: `{"errors": []}`, status: http.StatusNotFound,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != test.path {
+					t.Errorf("unexpected request path %q, want %q", r.URL.Path, test.path)
+				}
+				if got := r.Header.Get("X-Vault-Token"); got != "s.token" {
+					t.Errorf("unexpected X-Vault-Token %q", got)
+				}
+				if got := r.Header.Get("X-Vault-Namespace"); got != "team" {
+					t.Errorf("unexpected X-Vault-Namespace %q", got)
+				}
+
+				if test.status != 0 {
+					w.WriteHeader(test.status)
+				}
+				_, _ = w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			var v = &vault{addr: server.URL + "/", token: "s.token", namespace: "team"}
+			secret, err := v.Fetch(context.Background(), test.path[len("/v1"):])
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Fetch() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			if secret.Username != test.wantUsername || secret.Token != test.wantToken {
+				t.Errorf("Fetch() = %+v, want username %q and token %q", secret, test.wantUsername, test.wantToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Package sigv4 signs requests to AWS APIs with AWS Signature Version 4, for the few AWS services the worker talks to
+// (Secrets Manager and KMS) without pulling in the AWS SDK.
+// See: https://docs.aws.amazon.com/IAM/latest/UserGuide/create-signed-request.html
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Credentials are the AWS credentials requests are signed with
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string // optional, for temporary credentials
+}
+
+// CredentialsFromEnv reads the credentials from the standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and (optional)
+// AWS_SESSION_TOKEN env vars
+func CredentialsFromEnv() (Credentials, error) {
+	var creds = Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return Credentials{}, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set")
+	}
+
+	return creds, nil
+}
+
+// RegionFromEnv returns the region in the standard AWS_REGION (or AWS_DEFAULT_REGION) env var, if any
+func RegionFromEnv() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// RegionOfARN returns the region of the given ARN, or an empty string if it isn't an ARN (or has no region)
+func RegionOfARN(ref string) string {
+	if parts := strings.Split(ref, ":"); len(parts) > 3 && parts[0] == "arn" {
+		return parts[3]
+	}
+	return ""
+}
+
+// Sign signs the request (with the given payload as its body) for the given service and region, setting its
+// X-Amz-Date, X-Amz-Security-Token (with temporary credentials) and Authorization headers. The host and every header
+// set on the request when it's signed are signed.
+func Sign(req *http.Request, payload []byte, creds Credentials, service, region string, now time.Time) {
+	now = now.UTC()
+	var amzDate, date = now.Format("20060102T150405Z"), now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	var host = req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	// canonical headers must be sorted by (lowercase) name, with their values trimmed and any sequential spaces
+	// collapsed into one
+	var values = map[string]string{"host": host}
+	for name, vs := range req.Header {
+		var trimmed = make([]string, len(vs))
+		for i, v := range vs {
+			trimmed[i] = strings.Join(strings.Fields(v), " ")
+		}
+		values[strings.ToLower(name)] = strings.Join(trimmed, ",")
+	}
+
+	var headers = make([]string, 0, len(values))
+	for name := range values {
+		headers = append(headers, name)
+	}
+	sort.Strings(headers)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range headers {
+		canonicalHeaders.WriteString(name + ":" + values[name] + "\n")
+	}
+	var signedHeaders = strings.Join(headers, ";")
+
+	var payloadHash = sha256.Sum256(payload)
+	var canonicalRequest = strings.Join([]string{
+		req.Method, canonicalURI(req.URL), canonicalQuery(req.URL), canonicalHeaders.String(), signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	var scope = fmt.Sprintf("%s/%s/%s/aws4_request", date, region, service)
+	var requestHash = sha256.Sum256([]byte(canonicalRequest))
+	var stringToSign = strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	var key = hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	var signature = hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// canonicalURI returns the path of the URL, with each of its segments URI encoded
+func canonicalURI(u *url.URL) string {
+	if u.Path == "" {
+		return "/"
+	}
+
+	var segments = strings.Split(u.Path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// canonicalQuery returns the URI encoded parameters of the query string of the URL, sorted by name (and value)
+func canonicalQuery(u *url.URL) string {
+	var encoded = make(map[string][]string)
+	var names []string
+	for name, values := range u.Query() {
+		var n = uriEncode(name)
+		for _, value := range values {
+			encoded[n] = append(encoded[n], uriEncode(value))
+		}
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var params []string
+	for _, name := range names {
+		sort.Strings(encoded[name])
+		for _, value := range encoded[name] {
+			params = append(params, name+"="+value)
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// uriEncode encodes every byte of s but the unreserved characters of RFC 3986, as required by AWS
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		var c = s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	var h = hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
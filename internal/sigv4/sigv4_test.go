@@ -0,0 +1,139 @@
+package sigv4
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSign signs the requests of the AWS Signature Version 4 test suite, which are signed with example credentials
+// for the "service" service in us-east-1, on 2015-08-30 at 12:36:00 UTC.
+func TestSign(t *testing.T) {
+	var creds = Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	var now = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	var tests = []struct {
+		name          string
+		method, url   string
+		headers       map[string]string
+		payload       string
+		signedHeaders string
+		signature     string
+	}{
+		{
+			name: "get-vanilla", method: http.MethodGet, url: "https://example.amazonaws.com/",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name: "get-vanilla-query-order-key-case", method: http.MethodGet, url: "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			signedHeaders: "host;x-amz-date",
+			signature:     "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name: "get-space", method: http.MethodGet, url: "https://example.amazonaws.com/example%20space/",
+			signedHeaders: "host;x-amz-date",
+			signature:     "652487583200325589f1fba4c7e578f72c47cb61beeca81406b39ddec1366741",
+		},
+		{
+			name: "get-utf8", method: http.MethodGet, url: "https://example.amazonaws.com/%E1%88%B4",
+			signedHeaders: "host;x-amz-date",
+			signature:     "8318018e0b0f223aa2bbf98705b62bb787dc9c0e678f255a891fd03141be5d85",
+		},
+		{
+			name: "post-vanilla", method: http.MethodPost, url: "https://example.amazonaws.com/",
+			signedHeaders: "host;x-amz-date",
+			signature:     "5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		},
+		{
+			name: "post-header-key-sort", method: http.MethodPost, url: "https://example.amazonaws.com/",
+			headers:       map[string]string{"My-Header1": "value1"},
+			signedHeaders: "host;my-header1;x-amz-date",
+			signature:     "c5410059b04c1ee005303aed430f6e6645f61f4dc9e1461ec8f8916fdf18852c",
+		},
+		{
+			name: "post-x-www-form-urlencoded", method: http.MethodPost, url: "https://example.amazonaws.com/",
+			headers:       map[string]string{"Content-Type": "application/x-www-form-urlencoded"},
+			payload:       "Param1=value1",
+			signedHeaders: "content-type;host;x-amz-date",
+			signature:     "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, test.url, strings.NewReader(test.payload))
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+
+			Sign(req, []byte(test.payload), creds, "service", "us-east-1", now)
+
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("Sign() X-Amz-Date = %q, want %q", got, "20150830T123600Z")
+			}
+
+			var want = "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=" + test.signedHeaders + ", Signature=" + test.signature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Sign() Authorization = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestSignSessionToken(t *testing.T) {
+	var creds = Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", SessionToken: "token"}
+
+	req, err := http.NewRequest(http.MethodPost, "https://example.amazonaws.com/", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	Sign(req, nil, creds, "service", "us-east-1", time.Now())
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "token" {
+		t.Errorf("Sign() X-Amz-Security-Token = %q, want %q", got, "token")
+	}
+	if got := req.Header.Get("Authorization"); !strings.Contains(got, "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("Sign() Authorization = %q, want the session token to be signed", got)
+	}
+}
+
+func TestCanonicalHeaderValues(t *testing.T) {
+	var creds = Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	var now = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	// values are trimmed and their sequential spaces collapsed, so they're signed the same way
+	var sign = func(value string) string {
+		req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+		req.Header.Set("My-Header1", value)
+		Sign(req, nil, creds, "service", "us-east-1", now)
+		return req.Header.Get("Authorization")
+	}
+
+	if a, b := sign("a b c"), sign("  a   b  c "); a != b {
+		t.Errorf("Sign() = %q and %q, want the same signature", a, b)
+	}
+}
+
+func TestRegionOfARN(t *testing.T) {
+	var tests = []struct {
+		ref, want string
+	}{
+		{ref: "arn:aws:secretsmanager:eu-west-1:111122223333:secret:mergestat-github", want: "eu-west-1"},
+		{ref: "arn:aws:kms:us-east-2:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab", want: "us-east-2"},
+		{ref: "mergestat-github"},
+		{ref: "alias/mergestat"},
+	}
+
+	for _, test := range tests {
+		if got := RegionOfARN(test.ref); got != test.want {
+			t.Errorf("RegionOfARN(%q) = %q, want %q", test.ref, got, test.want)
+		}
+	}
+}
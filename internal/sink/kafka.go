@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// kafkaBatchTimeout bounds how long messages wait to be batched before they're sent, as a sync publishes its rows all at once
+const kafkaBatchTimeout = 50 * time.Millisecond
+
+type kafkaSink struct {
+	writer *kafka.Writer
+	prefix string
+}
+
+func newKafka(brokers, prefix string) *kafkaSink {
+	return &kafkaSink{
+		prefix: prefix,
+		writer: &kafka.Writer{
+			Addr:                   kafka.TCP(strings.Split(brokers, ",")...),
+			Balancer:               &kafka.Hash{},
+			BatchTimeout:           kafkaBatchTimeout,
+			RequiredAcks:           kafka.RequireAll,
+			AllowAutoTopicCreation: true,
+		},
+	}
+}
+
+func (s *kafkaSink) Publish(ctx context.Context, topic string, messages []Message) error {
+	var msgs = make([]kafka.Message, len(messages))
+	for i, m := range messages {
+		msgs[i] = kafka.Message{Topic: s.prefix + "." + topic, Key: m.Key, Value: m.Value}
+	}
+	return s.writer.WriteMessages(ctx, msgs...)
+}
+
+func (s *kafkaSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,76 @@
+package sink
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nats-io/nats.go"
+)
+
+// natsStream is the JetStream stream the subjects of the sink are stored in, created if it doesn't exist yet
+const natsStream = "MERGESTAT"
+
+// natsKeyHeader is the header of a message carrying its key, as NATS messages have none of their own
+const natsKeyHeader = "Mergestat-Key"
+
+type natsSink struct {
+	conn   *nats.Conn
+	js     nats.JetStreamContext
+	prefix string
+}
+
+func newNATS(ctx context.Context, url, prefix string) (_ *natsSink, err error) {
+	var conn *nats.Conn
+	if conn, err = nats.Connect(url, nats.Name("mergestat-worker")); err != nil {
+		return nil, err
+	}
+
+	var js nats.JetStreamContext
+	if js, err = conn.JetStream(nats.Context(ctx)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	// a stream set up beforehand (e.g. with other retention settings) is left as is
+	if _, err = js.StreamInfo(natsStream); errors.Is(err, nats.ErrStreamNotFound) {
+		_, err = js.AddStream(&nats.StreamConfig{Name: natsStream, Subjects: []string{prefix + ".>"}})
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &natsSink{conn: conn, js: js, prefix: prefix}, nil
+}
+
+func (s *natsSink) Publish(ctx context.Context, topic string, messages []Message) error {
+	var subject = s.prefix + "." + topic
+
+	var futures = make([]nats.PubAckFuture, 0, len(messages))
+	for _, m := range messages {
+		var msg = nats.NewMsg(subject)
+		msg.Header.Set(natsKeyHeader, string(m.Key))
+		msg.Data = m.Value
+
+		future, err := s.js.PublishMsgAsync(msg)
+		if err != nil {
+			return err
+		}
+		futures = append(futures, future)
+	}
+
+	for _, future := range futures {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-future.Ok():
+		case err := <-future.Err():
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *natsSink) Close() error {
+	return s.conn.Drain()
+}
@@ -0,0 +1,66 @@
+package sink
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/mergestat/mergestat/internal/destination"
+)
+
+// row is a row written by a sync, as published to a sink. A sync publishes every row it copies, so a new job id marks a
+// new snapshot of the table (for the repo) downstream.
+type row struct {
+	JobID    int64                  `json:"job_id"`
+	RepoID   uuid.UUID              `json:"repo_id"`
+	Repo     string                 `json:"repo"`
+	SyncType string                 `json:"sync_type"`
+	Table    string                 `json:"table"`
+	Row      map[string]interface{} `json:"row"`
+}
+
+// rows is the destination publishing the rows sent to it to a sink, a message per row, keyed by repo
+type rows struct{ sink Sink }
+
+// Destination returns a destination publishing the rows sent to it to the given sink, to the topic of their table
+func Destination(s Sink) destination.Destination { return &rows{sink: s} }
+
+func (r *rows) Name() string { return "event sink" }
+
+func (r *rows) Send(ctx context.Context, batch *destination.Batch) error {
+	var messages = make([]Message, 0, len(batch.Rows))
+	for _, values := range batch.Rows {
+		var msg = row{
+			JobID:    batch.JobID,
+			RepoID:   batch.RepoID,
+			Repo:     batch.Repo,
+			SyncType: batch.SyncType,
+			Table:    batch.Table,
+			Row:      make(map[string]interface{}, len(batch.Columns)),
+		}
+		for i, column := range batch.Columns {
+			msg.Row[column] = value(values[i])
+		}
+
+		encoded, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("encode row of %s: %w", batch.Table, err)
+		}
+		messages = append(messages, Message{Key: []byte(batch.RepoID.String()), Value: encoded})
+	}
+
+	return r.sink.Publish(ctx, batch.Table, messages)
+}
+
+// value returns the given (copied) value as it's encoded in a published row, i.e. the value of a driver.Valuer
+// (e.g. a uuid or a sql.NullString) rather than the value itself
+func value(v interface{}) interface{} {
+	if valuer, ok := v.(driver.Valuer); ok {
+		if value, err := valuer.Value(); err == nil {
+			return value
+		}
+	}
+	return v
+}
@@ -0,0 +1,37 @@
+// Package sink publishes the rows written by syncs to an event-streaming platform (Kafka or NATS JetStream), alongside
+// Postgres, so that they can be consumed from there rather than by polling the database. Rows are published to a topic
+// (or subject) per table, named <prefix>.<table>, e.g. mergestat.git_commits.
+package sink
+
+import (
+	"context"
+	"fmt"
+)
+
+// Message is a single row published to a sink
+type Message struct {
+	Key   []byte // the rows of a repo have the same key, and so end up in the same (Kafka) partition
+	Value []byte
+}
+
+// Sink publishes messages to the topics of an event-streaming platform
+type Sink interface {
+	// Publish publishes the given messages to the given topic, returning once they're all acknowledged
+	Publish(ctx context.Context, topic string, messages []Message) error
+
+	// Close flushes any pending messages and closes the connection to the platform
+	Close() error
+}
+
+// New returns a sink of the given kind (kafka or nats), connected to the given comma-separated Kafka brokers or NATS
+// server urls. Topics are prefixed with the given prefix.
+func New(ctx context.Context, kind, url, prefix string) (Sink, error) {
+	switch kind {
+	case "kafka":
+		return newKafka(url, prefix), nil
+	case "nats":
+		return newNATS(ctx, url, prefix)
+	default:
+		return nil, fmt.Errorf("unknown sink: %s", kind)
+	}
+}
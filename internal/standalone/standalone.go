@@ -0,0 +1,169 @@
+// Package standalone syncs a single repo into a local SQLite file, without Postgres or a sync queue, for laptops, demos
+// and one-off analyses of a repo. The git syncs are run with the tables of mergestat-lite (which must be registered with
+// the sqlite3 driver beforehand), each writing a table of the same name as in Postgres (e.g. git_commits) to the file,
+// replacing the table written by a previous run. Every run is recorded in the mergestat_syncs table of the file.
+package standalone
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/rs/zerolog"
+)
+
+// syncQuery is a sync type run in standalone mode, creating a table from a mergestat-lite query of the repo
+type syncQuery struct {
+	table string
+	query string // the repo path is bound to every ? of the query
+}
+
+// syncs are the sync types that can be run in standalone mode, by type
+var syncs = map[string]syncQuery{
+	"GIT_COMMITS": {table: "git_commits", query: `
+		SELECT hash, message, author_name, author_email, author_when, committer_name, committer_email, committer_when, parents
+		FROM commits(?)`},
+	"GIT_REFS": {table: "git_refs", query: `
+		SELECT full_name, hash, name, remote, target, type,
+			(CASE type WHEN 'tag' THEN COALESCE(COMMIT_FROM_TAG(tag), hash) END) AS tag_commit_hash
+		FROM refs(?)`},
+	"GIT_FILES": {table: "git_files", query: `SELECT path, executable, contents FROM files(?)`},
+	"GIT_COMMIT_STATS": {table: "git_commit_stats", query: `
+		SELECT commits.hash AS commit_hash, stats.file_path, stats.additions, stats.deletions
+		FROM commits(?), stats(?, commits.hash)`},
+}
+
+// createSyncs creates the table recording the runs of each sync type, if it doesn't exist yet
+const createSyncs = `
+CREATE TABLE IF NOT EXISTS mergestat_syncs (
+	repo TEXT NOT NULL,
+	sync_type TEXT NOT NULL,
+	"table" TEXT NOT NULL,
+	row_count INTEGER NOT NULL,
+	started_at DATETIME NOT NULL,
+	completed_at DATETIME NOT NULL
+)`
+
+// Validate returns an error if any of the given sync types can't be run in standalone mode
+func Validate(syncTypes []string) error {
+	for _, syncType := range syncTypes {
+		if _, ok := syncs[syncType]; !ok {
+			return fmt.Errorf("sync type %s can't be run in standalone mode", syncType)
+		}
+	}
+	return nil
+}
+
+// Sync runs the given sync types of the given repo, a local path or a remote url (cloned under clonePath, using
+// GITHUB_TOKEN to authenticate if set), writing their tables into the SQLite file at the given path
+func Sync(ctx context.Context, logger *zerolog.Logger, repo, output string, syncTypes []string, clonePath string) (err error) {
+	if err = Validate(syncTypes); err != nil {
+		return err
+	}
+
+	var path = repo
+	if isRemote(repo) {
+		var cleanup func() error
+		if path, cleanup, err = helper.CreateTempDir(clonePath, "mergestat-standalone-*"); err != nil {
+			return fmt.Errorf("temp dir: %w", err)
+		}
+		defer func() {
+			if err := cleanup(); err != nil {
+				logger.Err(err).Msgf("error cleaning up repo at: %s, %v", path, err)
+			}
+		}()
+
+		logger.Info().Str("repo", repo).Msg("cloning repo")
+		if err = clone(ctx, path, repo); err != nil {
+			return fmt.Errorf("git clone: %w", err)
+		}
+	}
+
+	var db *sql.DB
+	if db, err = sql.Open("sqlite3", output); err != nil {
+		return fmt.Errorf("open %s: %w", output, err)
+	}
+	defer db.Close()
+
+	if _, err = db.ExecContext(ctx, createSyncs); err != nil {
+		return fmt.Errorf("create mergestat_syncs: %w", err)
+	}
+
+	for _, syncType := range syncTypes {
+		var startedAt = time.Now()
+		var rows int64
+		if rows, err = run(ctx, db, repo, path, syncType); err != nil {
+			return fmt.Errorf("sync %s: %w", syncType, err)
+		}
+
+		logger.Info().Str("repo", repo).Str("sync-type", syncType).Int64("rows", rows).
+			Str("duration", time.Since(startedAt).String()).Msgf("synced %d row(s) into %s", rows, syncs[syncType].table)
+	}
+
+	return nil
+}
+
+// run (re)creates the table of the given sync type from the repo at path, recording the run in mergestat_syncs
+func run(ctx context.Context, db *sql.DB, repo, path, syncType string) (rows int64, err error) {
+	var s, startedAt = syncs[syncType], time.Now()
+
+	var tx *sql.Tx
+	if tx, err = db.BeginTx(ctx, nil); err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", s.table)); err != nil {
+		return 0, err
+	}
+
+	var args = make([]interface{}, strings.Count(s.query, "?"))
+	for i := range args {
+		args[i] = path
+	}
+	if _, err = tx.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s AS %s", s.table, s.query), args...); err != nil {
+		return 0, err
+	}
+
+	if err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", s.table)).Scan(&rows); err != nil {
+		return 0, err
+	}
+
+	const record = `INSERT INTO mergestat_syncs (repo, sync_type, "table", row_count, started_at, completed_at) VALUES (?, ?, ?, ?, ?, ?)`
+	if _, err = tx.ExecContext(ctx, record, repo, syncType, s.table, rows, startedAt.UTC(), time.Now().UTC()); err != nil {
+		return 0, err
+	}
+
+	return rows, tx.Commit()
+}
+
+// isRemote returns whether the given repo is a remote url, rather than a local path
+func isRemote(repo string) bool {
+	if _, err := os.Stat(repo); err == nil {
+		return false
+	}
+	return strings.Contains(repo, "://") || strings.HasPrefix(repo, "git@")
+}
+
+// clone clones the repo at the given remote url into path
+func clone(ctx context.Context, path, url string) error {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil {
+		return err
+	}
+
+	var auth transport.AuthMethod
+	if auth, err = helper.GetGitAuthMethod(endpoint, "", os.Getenv("GITHUB_TOKEN")); err != nil {
+		return err
+	}
+
+	_, err = git.PlainCloneContext(ctx, path, true, &git.CloneOptions{URL: url, Auth: auth})
+	return err
+}
@@ -0,0 +1,163 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	azure "github.com/mergestat/mergestat/internal/vendors/azure/client"
+	uuid "github.com/satori/go.uuid"
+)
+
+// fetchAzureRepoBuilds pages through all the builds (pipeline runs) of an Azure DevOps repo
+func fetchAzureRepoBuilds(ctx context.Context, client *azure.Client, organization, project, repository string) (_ []*azure.Build, err error) {
+	// the builds endpoint only filters by repository id, so look it up first
+	var repo *azure.Repository
+	if repo, err = client.Repositories().Get(ctx, organization, project, repository); err != nil {
+		return nil, err
+	}
+
+	var result []*azure.Build
+
+	var params = azure.BuildListOptions{Organization: organization, Project: project, RepositoryID: repo.ID, Top: 100}
+	for {
+		var response *azure.List[*azure.Build]
+		if response, params.ContinuationToken, err = client.Builds().List(ctx, params); err != nil {
+			return nil, err
+		}
+
+		result = append(result, response.Value...)
+		if params.ContinuationToken == "" {
+			break
+		}
+	}
+	return result, nil
+}
+
+// sendBatchAzureRepoBuilds uses the pg COPY protocol to send a batch of Azure DevOps repo builds
+func (w *worker) sendBatchAzureRepoBuilds(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*azure.Build) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"build_number",
+		"definition_id",
+		"definition_name",
+		"status",
+		"result",
+		"reason",
+		"source_branch",
+		"source_version",
+		"requested_for",
+		"queued_at",
+		"started_at",
+		"finished_at",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, b := range batch {
+		var requestedFor *string
+		if b.RequestedFor != nil {
+			requestedFor = &b.RequestedFor.UniqueName
+		}
+
+		input := []interface{}{
+			repo,
+			b.ID,
+			b.BuildNumber,
+			b.Definition.ID,
+			b.Definition.Name,
+			b.Status,
+			b.Result,
+			b.Reason,
+			b.SourceBranch,
+			b.SourceVersion,
+			requestedFor,
+			b.QueueTime,
+			b.StartTime,
+			b.FinishTime,
+			b.Links.Web.Href,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("azure_builds")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleAzureRepoBuilds(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var token string
+	if _, token, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var organization, project, repository string
+	if organization, project, repository, err = helper.GetAzureDevOpsRepoComponents(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client = newAzureClient(ctx, token)
+	builds, err := fetchAzureRepoBuilds(ctx, client, organization, project, repository)
+	if err != nil {
+		return fmt.Errorf("fetch builds: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "azure_builds"); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchAzureRepoBuilds(ctx, tx, id, builds); err != nil {
+		return fmt.Errorf("insert builds: %w", err)
+	}
+
+	l.Info().Msgf("inserted repo builds: %d", len(builds))
+
+	if err := w.mergeStaged(ctx, tx, j, "azure_builds", int64(len(builds))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,190 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	azure "github.com/mergestat/mergestat/internal/vendors/azure/client"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/oauth2"
+)
+
+// newAzureClient returns an Azure DevOps REST client, authenticated with the personal access token if one is provided
+func newAzureClient(ctx context.Context, token string) *azure.Client {
+	if token != "" {
+		var tokenSource = &azure.PersonalAccessToken{Value: token}
+		return azure.NewDefaultClient(oauth2.NewClient(ctx, tokenSource))
+	}
+	return azure.NewDefaultClient(http.DefaultClient)
+}
+
+// fetchAzureRepoPRs pages through all the pull requests (in any status) of an Azure DevOps repo
+func fetchAzureRepoPRs(ctx context.Context, client *azure.Client, organization, project, repository string) (_ []*azure.PullRequest, err error) {
+	var result []*azure.PullRequest
+
+	var params = azure.PullRequestListOptions{
+		Organization: organization, Project: project, Repository: repository, Status: "all", Top: 100,
+	}
+	for {
+		var response *azure.List[*azure.PullRequest]
+		if response, err = client.PullRequests().List(ctx, params); err != nil {
+			return nil, err
+		}
+
+		result = append(result, response.Value...)
+		if len(response.Value) < params.Top {
+			break
+		}
+		params.Skip += len(response.Value)
+	}
+	return result, nil
+}
+
+// sendBatchAzureRepoPRs uses the pg COPY protocol to send a batch of Azure DevOps repo pull requests
+func (w *worker) sendBatchAzureRepoPRs(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*azure.PullRequest) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"title",
+		"description",
+		"status",
+		"merge_status",
+		"is_draft",
+		"created_by",
+		"closed_by",
+		"created_at",
+		"closed_at",
+		"source_ref_name",
+		"target_ref_name",
+		"source_commit",
+		"merge_commit",
+		"reviewers",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, pr := range batch {
+		var createdBy, closedBy *string
+		if pr.CreatedBy != nil {
+			createdBy = &pr.CreatedBy.UniqueName
+		}
+		if pr.ClosedBy != nil {
+			closedBy = &pr.ClosedBy.UniqueName
+		}
+
+		var sourceCommit, mergeCommit *string
+		if pr.LastMergeSourceCommit != nil {
+			sourceCommit = &pr.LastMergeSourceCommit.CommitID
+		}
+		if pr.LastMergeCommit != nil {
+			mergeCommit = &pr.LastMergeCommit.CommitID
+		}
+
+		reviewers, err := json.Marshal(pr.Reviewers)
+		if err != nil {
+			return fmt.Errorf("marshal pull request reviewers: %w", err)
+		}
+
+		input := []interface{}{
+			repo,
+			pr.PullRequestID,
+			pr.Title,
+			pr.Description,
+			pr.Status,
+			pr.MergeStatus,
+			pr.IsDraft,
+			createdBy,
+			closedBy,
+			pr.CreationDate,
+			pr.ClosedDate,
+			pr.SourceRefName,
+			pr.TargetRefName,
+			sourceCommit,
+			mergeCommit,
+			reviewers,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("azure_pull_requests")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleAzureRepoPRs(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var token string
+	if _, token, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var organization, project, repository string
+	if organization, project, repository, err = helper.GetAzureDevOpsRepoComponents(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client = newAzureClient(ctx, token)
+	prs, err := fetchAzureRepoPRs(ctx, client, organization, project, repository)
+	if err != nil {
+		return fmt.Errorf("fetch pull requests: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "azure_pull_requests"); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchAzureRepoPRs(ctx, tx, id, prs); err != nil {
+		return fmt.Errorf("insert pull requests: %w", err)
+	}
+
+	l.Info().Msgf("inserted repo pull requests: %d", len(prs))
+
+	if err := w.mergeStaged(ctx, tx, j, "azure_pull_requests", int64(len(prs))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
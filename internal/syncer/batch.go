@@ -0,0 +1,166 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// sharedCheckoutKey is the context key of the sharedCheckouts of a batch of jobs
+type sharedCheckoutKey struct{}
+
+// sharedCheckouts are the clones of a repo shared by the jobs of a batch, one for each of the clone settings of the jobs
+type sharedCheckouts struct {
+	dir string // the directory the clones are made in
+
+	mu        sync.Mutex
+	checkouts map[cloneSettingsKey]*sharedCheckout
+}
+
+// cloneSettingsKey identifies the clone settings jobs of a batch share a clone with
+type cloneSettingsKey struct {
+	bare   bool
+	depth  int
+	filter string
+}
+
+// get returns the clone shared by the jobs with the given clone settings
+func (s *sharedCheckouts) get(settings *cloneSettings) *sharedCheckout {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var key = cloneSettingsKey{bare: *settings.Bare, depth: settings.Depth, filter: *settings.Filter}
+	if checkout, ok := s.checkouts[key]; ok {
+		return checkout
+	}
+
+	var checkout = &sharedCheckout{path: filepath.Join(s.dir, fmt.Sprintf("%d", len(s.checkouts)))}
+	s.checkouts[key] = checkout
+	return checkout
+}
+
+// sharedCheckout is a clone of a repo shared by the jobs of a batch with the same clone settings
+type sharedCheckout struct {
+	path string
+
+	mu   sync.Mutex
+	done bool
+	err  error
+}
+
+// clone makes the clone with the given func, unless it was made already, and returns its error. A clone interrupted
+// by the context of the job making it (e.g. as the job timed out or was canceled) is discarded rather than kept,
+// so that the next job of the batch clones the repo again instead of failing with the same error.
+func (c *sharedCheckout) clone(ctx context.Context, fn func(ctx context.Context, path string) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.done {
+		return c.err
+	}
+
+	var err = fn(ctx, c.path)
+	if err != nil && ctx.Err() != nil {
+		if rmErr := os.RemoveAll(c.path); rmErr != nil {
+			// the next job can't clone into what's left of this clone, so it fails as well
+			c.done, c.err = true, rmErr
+		}
+		return err
+	}
+
+	c.done, c.err = true, err
+	return err
+}
+
+// dequeueBatch returns the batch of jobs to run along with the given (already dequeued) job. Jobs that read the repo
+// from a clone of it (see bareCloneSyncTypes) are batched with all the other queued jobs of these types for the same repo.
+// Batched jobs share clones and run one after the other, but they count against the concurrency limits all the same
+// (see DequeueRepoSyncJobs), so a batch only takes as many jobs as the limits allow. It's run in the transaction of the
+// given job's dequeue (see dequeueSyncJob).
+func (w *worker) dequeueBatch(ctx context.Context, q *db.Queries, j *db.DequeueSyncJobRow) ([]*db.DequeueSyncJobRow, error) {
+	var jobs = []*db.DequeueSyncJobRow{j}
+	if !bareCloneSyncTypes[j.SyncType] {
+		return jobs, nil
+	}
+
+	var syncTypes = make([]string, 0, len(bareCloneSyncTypes))
+	for syncType := range bareCloneSyncTypes {
+		syncTypes = append(syncTypes, syncType)
+	}
+
+	rows, err := q.DequeueRepoSyncJobs(ctx, db.DequeueRepoSyncJobsParams{RepoID: j.RepoID, SyncTypes: syncTypes})
+	if err != nil {
+		return jobs, err
+	}
+
+	for _, row := range rows {
+		var job = db.DequeueSyncJobRow(row)
+		jobs = append(jobs, &job)
+	}
+
+	return jobs, nil
+}
+
+// lockRepo blocks until no other job of the given repo is being handled by this worker,
+// and returns a func releasing the repo once done
+func (w *worker) lockRepo(repo uuid.UUID) func() {
+	var mu, _ = w.repoLocks.LoadOrStore(repo, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// handleBatch runs the given jobs (of the same repo) one after the other, sharing clones of the repo (see sharedCheckouts).
+// The jobs run with jobCtx, while the ones that didn't start yet are requeued as soon as ctx is canceled.
+func (w *worker) handleBatch(ctx, jobCtx context.Context, jobs []*db.DequeueSyncJobRow) {
+	// keep the jobs alive while they wait for their turn, so that they aren't timed out
+	var keepAlives = make([]func(), len(jobs))
+	for i, j := range jobs {
+		keepAlives[i] = w.startKeepAlives(j, 30*time.Second)
+	}
+	defer func() {
+		for _, done := range keepAlives {
+			done()
+		}
+	}()
+
+	var unlock = w.lockRepo(jobs[0].RepoID)
+	defer unlock()
+
+	// with the clone cache enabled, the jobs share the cached clone of the repo instead
+	if len(jobs) > 1 && w.config.GitCloneCachePath == "" {
+		w.logger.Info().Msgf("handling batch of %d jobs for repo: %s", len(jobs), jobs[0].RepoID.String())
+
+		tmpPath, cleanup, err := w.createTempDir(jobs[0].RepoID)
+		if err != nil {
+			// each job clones the repo on its own then
+			w.logger.Err(err).Msgf("error creating shared checkout: %v", err)
+		} else {
+			defer func() {
+				if err := cleanup(); err != nil {
+					w.logger.Err(err).Msgf("error cleaning up shared checkout at: %s, %v", tmpPath, err)
+				}
+			}()
+
+			jobCtx = context.WithValue(jobCtx, sharedCheckoutKey{}, &sharedCheckouts{dir: tmpPath, checkouts: make(map[cloneSettingsKey]*sharedCheckout)})
+		}
+	}
+
+	for i, j := range jobs {
+		// if the worker is shutting down, give the jobs that didn't run back to the queue
+		if ctx.Err() != nil {
+			for _, remaining := range jobs[i:] {
+				w.requeue(remaining)
+			}
+			return
+		}
+
+		w.run(jobCtx, j)
+		keepAlives[i]()
+	}
+}
@@ -0,0 +1,170 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	bitbucket "github.com/mergestat/mergestat/internal/vendors/bitbucket/client"
+	uuid "github.com/satori/go.uuid"
+)
+
+// fetchBitbucketRepoPipelines pages through all the pipelines of a Bitbucket repo (most recent first)
+func fetchBitbucketRepoPipelines(ctx context.Context, client *bitbucket.Client, workspace, repoSlug string) (_ []*bitbucket.Pipeline, err error) {
+	var result []*bitbucket.Pipeline
+
+	var params = bitbucket.PipelineListOptions{Workspace: workspace, RepoSlug: repoSlug, Page: 1, PageLen: 100}
+	for {
+		var response *bitbucket.Paginated[*bitbucket.Pipeline]
+		if response, err = client.Pipelines().List(ctx, params); err != nil {
+			return nil, err
+		}
+
+		result = append(result, response.Values...)
+		if len(response.Values) == 0 || len(result) >= response.Size {
+			break
+		}
+		params.Page++
+	}
+	return result, nil
+}
+
+// sendBatchBitbucketRepoPipelines uses the pg COPY protocol to send a batch of Bitbucket repo pipelines
+func (w *worker) sendBatchBitbucketRepoPipelines(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*bitbucket.Pipeline) error {
+	cols := []string{
+		"repo_id",
+		"uuid",
+		"build_number",
+		"run_number",
+		"creator_display_name",
+		"trigger",
+		"state",
+		"result",
+		"target_ref_type",
+		"target_ref_name",
+		"target_commit",
+		"created_on",
+		"completed_on",
+		"duration_in_seconds",
+		"build_seconds_used",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, p := range batch {
+		var creator, result, commit *string
+		if p.Creator != nil {
+			creator = &p.Creator.DisplayName
+		}
+		if p.State.Result != nil {
+			result = &p.State.Result.Name
+		}
+		if p.Target.Commit != nil {
+			commit = &p.Target.Commit.Hash
+		}
+
+		var completedOn *time.Time
+		if p.CompletedOn != nil && !p.CompletedOn.IsZero() {
+			completedOn = p.CompletedOn
+		}
+
+		input := []interface{}{
+			repo,
+			p.UUID,
+			p.BuildNumber,
+			p.RunNumber,
+			creator,
+			p.Trigger.Name,
+			p.State.Name,
+			result,
+			p.Target.RefType,
+			p.Target.RefName,
+			commit,
+			p.CreatedOn,
+			completedOn,
+			p.DurationInSeconds,
+			p.BuildSecondsUsed,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("bitbucket_pipelines")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleBitbucketRepoPipelines(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var username, password string
+	if username, password, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var workspace, repoSlug string
+	if workspace, repoSlug, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client = newBitbucketClient(ctx, username, password)
+	pipelines, err := fetchBitbucketRepoPipelines(ctx, client, workspace, repoSlug)
+	if err != nil {
+		return fmt.Errorf("fetch pipelines: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "bitbucket_pipelines"); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchBitbucketRepoPipelines(ctx, tx, id, pipelines); err != nil {
+		return fmt.Errorf("insert pipelines: %w", err)
+	}
+
+	l.Info().Msgf("inserted repo pipelines: %d", len(pipelines))
+
+	if err := w.mergeStaged(ctx, tx, j, "bitbucket_pipelines", int64(len(pipelines))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,196 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	bitbucket "github.com/mergestat/mergestat/internal/vendors/bitbucket/client"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/oauth2"
+)
+
+// newBitbucketClient returns a Bitbucket Cloud REST client, authenticated with the app password if one is provided
+func newBitbucketClient(ctx context.Context, username, password string) *bitbucket.Client {
+	if password != "" {
+		var tokenSource = &bitbucket.AppPassword{Username: username, Password: password}
+		return bitbucket.NewDefaultClient(oauth2.NewClient(ctx, tokenSource))
+	}
+	return bitbucket.NewDefaultClient(http.DefaultClient)
+}
+
+// fetchBitbucketRepoPRs pages through all the pull requests (in any state) of a Bitbucket repo
+func fetchBitbucketRepoPRs(ctx context.Context, client *bitbucket.Client, workspace, repoSlug string) (_ []*bitbucket.PullRequest, err error) {
+	var result []*bitbucket.PullRequest
+
+	var next string
+	for {
+		var response *bitbucket.Paginated[*bitbucket.PullRequest]
+		var params = bitbucket.PullRequestListOptions{
+			Workspace: workspace, RepoSlug: repoSlug, NextPage: next,
+			State: []string{"OPEN", "MERGED", "DECLINED", "SUPERSEDED"},
+		}
+		if response, err = client.PullRequests().List(ctx, params); err != nil {
+			return nil, err
+		}
+
+		result = append(result, response.Values...)
+		if next = response.Next; next == "" {
+			break
+		}
+	}
+	return result, nil
+}
+
+// sendBatchBitbucketRepoPRs uses the pg COPY protocol to send a batch of Bitbucket repo pull requests
+func (w *worker) sendBatchBitbucketRepoPRs(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*bitbucket.PullRequest) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"title",
+		"description",
+		"state",
+		"author_display_name",
+		"author_uuid",
+		"source_branch",
+		"source_commit",
+		"destination_branch",
+		"destination_commit",
+		"merge_commit",
+		"comment_count",
+		"task_count",
+		"close_source_branch",
+		"closed_by_display_name",
+		"reason",
+		"created_on",
+		"updated_on",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, pr := range batch {
+		var authorName, authorUUID, closedBy *string
+		if pr.Author != nil {
+			authorName, authorUUID = &pr.Author.DisplayName, &pr.Author.UUID
+		}
+		if pr.ClosedBy != nil {
+			closedBy = &pr.ClosedBy.DisplayName
+		}
+
+		var sourceCommit, destinationCommit, mergeCommit *string
+		if pr.Source.Commit != nil {
+			sourceCommit = &pr.Source.Commit.Hash
+		}
+		if pr.Destination.Commit != nil {
+			destinationCommit = &pr.Destination.Commit.Hash
+		}
+		if pr.MergeCommit != nil {
+			mergeCommit = &pr.MergeCommit.Hash
+		}
+
+		input := []interface{}{
+			repo,
+			pr.ID,
+			pr.Title,
+			pr.Description,
+			pr.State,
+			authorName,
+			authorUUID,
+			pr.Source.Branch.Name,
+			sourceCommit,
+			pr.Destination.Branch.Name,
+			destinationCommit,
+			mergeCommit,
+			pr.CommentCount,
+			pr.TaskCount,
+			pr.CloseSourceBranch,
+			closedBy,
+			pr.Reason,
+			pr.CreatedOn,
+			pr.UpdatedOn,
+			pr.Links.HTML.Href,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("bitbucket_pull_requests")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleBitbucketRepoPRs(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var username, password string
+	if username, password, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var workspace, repoSlug string
+	if workspace, repoSlug, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client = newBitbucketClient(ctx, username, password)
+	prs, err := fetchBitbucketRepoPRs(ctx, client, workspace, repoSlug)
+	if err != nil {
+		return fmt.Errorf("fetch pull requests: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "bitbucket_pull_requests"); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchBitbucketRepoPRs(ctx, tx, id, prs); err != nil {
+		return fmt.Errorf("insert pull requests: %w", err)
+	}
+
+	l.Info().Msgf("inserted repo pull requests: %d", len(prs))
+
+	if err := w.mergeStaged(ctx, tx, j, "bitbucket_pull_requests", int64(len(prs))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
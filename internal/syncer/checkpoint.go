@@ -0,0 +1,171 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// checkpointInterval is how often a long running API sync saves its progress
+const checkpointInterval = 30 * time.Second
+
+// checkpointVersion is the version of the format checkpoints are saved in, checkpoints saved in another format are ignored
+const checkpointVersion = 2
+
+// checkpoint keeps track of the progress of a long running (API) sync job, and periodically saves it to
+// mergestat.repo_sync_checkpoints, so that the job resumes where it left off if it's interrupted and requeued.
+// The state of a checkpoint only holds the cursors of the sync (e.g. the page it's at), the rows it fetched are staged
+// in the checkpoint (see stage) and saved to mergestat.repo_sync_checkpoint_rows as they're fetched.
+type checkpoint struct {
+	w     *worker
+	job   *db.DequeueSyncJobRow
+	state interface{}
+	saved time.Time
+
+	// rows staged since the checkpoint was last saved, by kind
+	pending map[string][]interface{}
+}
+
+// savedCheckpoint is the format a checkpoint is saved in
+type savedCheckpoint struct {
+	Version int             `json:"version"`
+	State   json.RawMessage `json:"state"`
+}
+
+// loadCheckpoint restores the state saved by a previous (interrupted) run of the job into state, if there is any.
+// The returned checkpoint saves the (then current) value of state.
+func (w *worker) loadCheckpoint(ctx context.Context, j *db.DequeueSyncJobRow, state interface{}) (_ *checkpoint, resumed bool, err error) {
+	var c = &checkpoint{w: w, job: j, state: state, saved: time.Now(), pending: make(map[string][]interface{})}
+
+	var raw pgtype.JSONB
+	if raw, err = w.db.GetSyncJobCheckpoint(ctx, j.ID); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return c, false, nil
+		}
+		return nil, false, err
+	}
+
+	// a checkpoint that can't be restored (e.g. saved by an older version of the sync) is dropped, and the sync starts over
+	var saved savedCheckpoint
+	if err = json.Unmarshal(raw.Bytes, &saved); err == nil && saved.Version != checkpointVersion {
+		err = fmt.Errorf("unknown checkpoint version: %d", saved.Version)
+	}
+	if err == nil {
+		err = json.Unmarshal(saved.State, state)
+	}
+	if err != nil {
+		w.loggerForJob(j).Warn().Err(err).Msg("ignoring sync checkpoint that can't be restored")
+		if err = w.db.DeleteSyncJobCheckpoint(ctx, j.ID); err != nil {
+			return nil, false, err
+		}
+		return c, false, nil
+	}
+
+	return c, true, nil
+}
+
+// stage adds a row of the given kind, fetched by the sync, to the checkpoint. It's saved along with the next save of the
+// state of the checkpoint, so the state should account for it by then (e.g. by pointing at the next page to fetch).
+func (c *checkpoint) stage(kind string, row interface{}) {
+	c.pending[kind] = append(c.pending[kind], row)
+}
+
+// checkpointRows returns the rows of the given kind staged in the checkpoint, in the order they were staged
+func checkpointRows[T any](ctx context.Context, c *checkpoint, kind string) ([]T, error) {
+	var saved, err = c.w.db.ListSyncJobCheckpointRows(ctx, db.ListSyncJobCheckpointRowsParams{RepoSyncQueueID: c.job.ID, Kind: kind})
+	if err != nil {
+		return nil, err
+	}
+
+	var rows = make([]T, 0)
+	for _, data := range saved {
+		var batch []T
+		if err = json.Unmarshal(data.Bytes, &batch); err != nil {
+			return nil, err
+		}
+		rows = append(rows, batch...)
+	}
+
+	for _, row := range c.pending[kind] {
+		rows = append(rows, row.(T))
+	}
+
+	return rows, nil
+}
+
+// save saves the current state of the checkpoint, along with the rows staged since it was last saved
+func (c *checkpoint) save(ctx context.Context) (err error) {
+	var state []byte
+	if state, err = json.Marshal(c.state); err != nil {
+		return err
+	}
+
+	var data []byte
+	if data, err = json.Marshal(savedCheckpoint{Version: checkpointVersion, State: state}); err != nil {
+		return err
+	}
+
+	var tx pgx.Tx
+	if tx, err = c.w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			c.w.logger.Err(err).Msgf("rollback transaction: %v", err)
+		}
+	}()
+
+	var q = c.w.db.WithTx(tx)
+	for kind, rows := range c.pending {
+		var batch []byte
+		if batch, err = json.Marshal(rows); err != nil {
+			return err
+		}
+
+		if err = q.AppendSyncJobCheckpointRows(ctx, db.AppendSyncJobCheckpointRowsParams{
+			RepoSyncQueueID: c.job.ID,
+			Kind:            kind,
+			Data:            pgtype.JSONB{Bytes: batch, Status: pgtype.Present},
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err = q.UpsertSyncJobCheckpoint(ctx, db.UpsertSyncJobCheckpointParams{
+		RepoSyncQueueID: c.job.ID,
+		State:           pgtype.JSONB{Bytes: data, Status: pgtype.Present},
+	}); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return err
+	}
+
+	c.pending = make(map[string][]interface{})
+	c.saved = time.Now()
+	return nil
+}
+
+// maybeSave saves the current state of the checkpoint, unless it was saved within the checkpoint interval
+func (c *checkpoint) maybeSave(ctx context.Context) error {
+	if time.Since(c.saved) < checkpointInterval {
+		return nil
+	}
+	return c.save(ctx)
+}
+
+// saveOnError saves the current state of the checkpoint once the sync failed (or was canceled) with the given error, so
+// that the next attempt picks up from here. It uses its own context, as the context of the job might be done already.
+func (c *checkpoint) saveOnError(err error) error {
+	if saveErr := c.save(context.Background()); saveErr != nil {
+		c.w.loggerForJob(c.job).Err(saveErr).Msgf("error saving sync checkpoint: %v", saveErr)
+	}
+	return err
+}
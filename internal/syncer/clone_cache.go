@@ -0,0 +1,243 @@
+package syncer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/pkg/errors"
+)
+
+// cachedClone returns the path of a bare clone of the repository tied to this job, kept in the clone cache at cacheDir.
+// The first job of a repo clones the repo (keyed by the repo url) into the cache, subsequent jobs only fetch into it,
+// instead of cloning the same repo over and over. The clone always holds the entire repo (ignoring depth and filter settings).
+//
+// Cached clones have the same refs as (bare) clones made by jobs on their own (see fullClone): the branches of the repo
+// as remote-tracking branches (refs/remotes/origin/*), its tags, and a local branch for its default branch. Other refs
+// (such as GitHub's refs/pull/*, which a mirror would fetch) are left out.
+//
+// The clone is read-locked until the job running with ctx is done (see withCachedCloneReaders), so that it isn't
+// evicted while the job still reads it (see evictClones).
+func (w *worker) cachedClone(ctx context.Context, cacheDir string, job *db.DequeueSyncJobRow) (_ string, err error) {
+	var repo db.Repo
+	if repo, err = w.db.GetRepoById(ctx, job.RepoID); err != nil {
+		return "", err
+	}
+
+	var r *remote
+	if r, err = w.remoteFor(ctx, repo); err != nil {
+		return "", err
+	}
+
+	if err = w.followRename(ctx, job, &repo, r); err != nil {
+		return "", err
+	}
+
+	// cached clones used to be mirrors (without a .git suffix), which are left to be evicted (see evictClones)
+	var sum = sha256.Sum256([]byte(repo.Repo))
+	var path = filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".git")
+
+	// the job read the clone before, and it's kept as is until the job is done
+	var readers, _ = ctx.Value(cachedCloneReadersKey{}).(*cachedCloneReaders)
+	if readers.holds(path) {
+		return path, nil
+	}
+
+	var mu, _ = w.cacheLocks.LoadOrStore(path, &sync.RWMutex{})
+	var lock = mu.(*sync.RWMutex)
+	for {
+		if err = w.updateCachedClone(ctx, cacheDir, path, lock, repo, r, job); err != nil {
+			return "", err
+		}
+
+		// the clone may have been evicted in between its update and the read lock, in which case it's cloned again
+		lock.RLock()
+		if _, err = os.Stat(path); err == nil {
+			readers.hold(path, lock.RUnlock)
+			return path, nil
+		}
+		lock.RUnlock()
+	}
+}
+
+// updateCachedClone clones the repo into the cache at path, or fetches into the clone if it's there already. Updates of
+// the same clone by concurrent jobs of this worker are serialized with the write lock of the clone.
+func (w *worker) updateCachedClone(ctx context.Context, cacheDir, path string, lock *sync.RWMutex, repo db.Repo, r *remote, job *db.DequeueSyncJobRow) (err error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	if _, err = os.Stat(path); err == nil {
+		if err = w.sendBatchLogMessages(ctx, []*syncLog{{
+			Type:            SyncLogTypeInfo,
+			RepoSyncQueueID: job.ID,
+			Message:         "updating cached git repository: " + repo.Repo,
+		}}); err != nil {
+			return err
+		}
+
+		if err = fetchCachedClone(ctx, path, r); err != nil {
+			return errors.Wrapf(err, "failed to update cached repository")
+		}
+
+		touch(path)
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err = w.sendBatchLogMessages(ctx, []*syncLog{{
+		Type:            SyncLogTypeInfo,
+		RepoSyncQueueID: job.ID,
+		Message:         "starting git clone into cache: " + repo.Repo,
+	}}); err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(cacheDir, 0o755); err != nil {
+		return err
+	}
+
+	if err = w.ensureDiskSpace(ctx, cacheDir, job); err != nil {
+		return err
+	}
+
+	// clone next to the final path and move it in place once done, so that
+	// a failed (or interrupted) clone never leaves a broken clone behind
+	var tmp string
+	if tmp, err = os.MkdirTemp(cacheDir, "clone-*"); err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err = runGit(ctx, "", r, "init", "--bare", "--quiet", "--", tmp); err != nil {
+		return errors.Wrapf(err, "failed to clone repository")
+	}
+
+	// fetches the branches of the repo into refs/remotes/origin/*
+	if err = runGit(ctx, tmp, r, "remote", "add", "origin", "--", r.endpoint.String()); err != nil {
+		return errors.Wrapf(err, "failed to clone repository")
+	}
+
+	if err = fetchCachedClone(ctx, tmp, r); err != nil {
+		return errors.Wrapf(err, "failed to clone repository")
+	}
+
+	if err = os.Rename(tmp, path); err != nil {
+		return err
+	}
+	touch(path)
+
+	if err = w.sendBatchLogMessages(ctx, []*syncLog{{
+		Type:            SyncLogTypeInfo,
+		RepoSyncQueueID: job.ID,
+		Message:         "finished git clone into cache successfully: " + repo.Repo,
+	}}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// fetchCachedClone fetches the branches and tags of the remote into the cached clone at path, pruning the ones that
+// were deleted, and points its local branch (and HEAD) at the default branch of the remote
+func fetchCachedClone(ctx context.Context, path string, r *remote) (err error) {
+	if err = runGit(ctx, path, r, "fetch", "--quiet", "--prune", "--prune-tags", "origin"); err != nil {
+		return err
+	}
+
+	// the first line is the default branch of the remote (e.g. "ref: refs/heads/main	HEAD"), if it has one
+	var output []byte
+	if output, err = gitOutput(ctx, path, r, "ls-remote", "--symref", "origin", "HEAD"); err != nil {
+		return err
+	}
+
+	var head string
+	if line, _, _ := strings.Cut(string(output), "\n"); strings.HasPrefix(line, "ref: ") {
+		head, _, _ = strings.Cut(strings.TrimPrefix(line, "ref: "), "\t")
+	}
+	if !strings.HasPrefix(head, "refs/heads/") {
+		return nil // an empty repo
+	}
+
+	var tracking = "refs/remotes/origin/" + strings.TrimPrefix(head, "refs/heads/")
+	if err = runGit(ctx, path, r, "update-ref", head, tracking); err != nil {
+		return err
+	}
+	if err = runGit(ctx, path, r, "symbolic-ref", "HEAD", head); err != nil {
+		return err
+	}
+
+	// the previous default branch, if it changed
+	if output, err = gitOutput(ctx, path, r, "for-each-ref", "--format=%(refname)", "refs/heads/"); err != nil {
+		return err
+	}
+	for _, branch := range strings.Fields(string(output)) {
+		if branch != head {
+			if err = runGit(ctx, path, r, "update-ref", "-d", branch); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// cachedCloneReadersKey is the context key of the cachedCloneReaders of a running job
+type cachedCloneReadersKey struct{}
+
+// cachedCloneReaders holds the read locks of the cached clones a running job reads, until it's done
+type cachedCloneReaders struct {
+	mu     sync.Mutex
+	unlock map[string]func()
+}
+
+// withCachedCloneReaders returns a context that the cached clones read by a job running with it are read-locked with
+// (see cachedClone), along with a func releasing them once the job is done
+func withCachedCloneReaders(ctx context.Context) (context.Context, func()) {
+	var readers = &cachedCloneReaders{unlock: make(map[string]func())}
+	return context.WithValue(ctx, cachedCloneReadersKey{}, readers), func() {
+		readers.mu.Lock()
+		defer readers.mu.Unlock()
+
+		for path, unlock := range readers.unlock {
+			unlock()
+			delete(readers.unlock, path)
+		}
+	}
+}
+
+// holds returns whether the job holds the read lock of the clone at path
+func (r *cachedCloneReaders) holds(path string) bool {
+	if r == nil {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.unlock[path]
+	return ok
+}
+
+// hold keeps the read lock of the clone at path until the job is done. Without a job to hold it for, it's released right away.
+func (r *cachedCloneReaders) hold(path string, unlock func()) {
+	if r == nil {
+		unlock()
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.unlock[path] = unlock
+}
+
+// touch sets the mod time of a cached clone to now, marking it as recently used (see evictClones)
+func touch(path string) {
+	var now = time.Now()
+	_ = os.Chtimes(path, now, now)
+}
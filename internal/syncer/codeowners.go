@@ -0,0 +1,162 @@
+package syncer
+
+import (
+	"bufio"
+	"regexp"
+	"strings"
+)
+
+// codeownersPaths are the paths a CODEOWNERS file is looked for at, in order: only the first one found is used, as
+// on GitHub (and GitLab, whose .gitlab/ directory comes last)
+var codeownersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+
+// codeownersRule is an owner of the files matching a pattern of a CODEOWNERS file. A rule without any owner (which
+// removes the ownership of the files matching it) has a single row, without an owner.
+type codeownersRule struct {
+	Line      int    // of the pattern in the file, the last rule matching a file taking precedence
+	Ordinal   int    // of the owner among the owners of the pattern
+	Pattern   string // as written in the file, e.g. /docs/ or *.go
+	Regex     string // matching the paths the pattern matches, as a (POSIX) regex
+	Owner     string // e.g. @org/team, @user or jane@example.com, empty if the pattern has no owner
+	OwnerType string // team, user, email or role, empty if the pattern has no owner
+	Section   string // the GitLab section of the rule, if any
+	Optional  bool   // whether the approval of the owners is optional (of the optional sections of GitLab)
+}
+
+// codeownersSection matches the header of a GitLab section (e.g. ^[Docs][2] @docs-team), capturing whether the
+// section is optional, its name, and its default owners
+var codeownersSection = regexp.MustCompile(`^(\^)?\[([^\]]+)\](?:\[\d+\])?\s*(.*)$`)
+
+// parseCodeowners parses the rules of a CODEOWNERS file (of either the GitHub or GitLab syntax)
+func parseCodeowners(contents string) []codeownersRule {
+	var rules []codeownersRule
+
+	var section string
+	var optional bool
+	var defaults []string
+
+	var scanner = bufio.NewScanner(strings.NewReader(contents))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		var line = strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if m := codeownersSection.FindStringSubmatch(line); m != nil {
+			optional, section, defaults = m[1] != "", m[2], splitOwners(m[3])
+			continue
+		}
+
+		var pattern, rest = splitCodeownersPattern(line)
+		var owners = splitOwners(rest)
+		if len(owners) == 0 {
+			// in GitLab sections, patterns without owners are owned by the default owners of the section
+			owners = defaults
+		}
+
+		var rule = codeownersRule{Line: lineNo, Pattern: pattern, Regex: codeownersPatternRegex(pattern), Section: section, Optional: optional}
+		if len(owners) == 0 {
+			rules = append(rules, rule)
+			continue
+		}
+		for i, owner := range owners {
+			rule.Ordinal, rule.Owner, rule.OwnerType = i, owner, codeownerType(owner)
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// splitCodeownersPattern splits a line of a CODEOWNERS file into its pattern (whose spaces can be escaped) and the
+// rest of the line
+func splitCodeownersPattern(line string) (pattern, rest string) {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case c == '\\' && i+1 < len(line):
+			i++
+			b.WriteByte(line[i])
+		case c == ' ' || c == '\t':
+			return b.String(), line[i:]
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), ""
+}
+
+// splitOwners splits the owners of a rule, up to the comment ending the line (if any)
+func splitOwners(s string) []string {
+	var owners []string
+	for _, owner := range strings.Fields(s) {
+		if strings.HasPrefix(owner, "#") {
+			break
+		}
+		owners = append(owners, owner)
+	}
+	return owners
+}
+
+// codeownerType returns the type of the given owner: team (@org/team), role (@@developer, of GitLab), user (@user)
+// or email
+func codeownerType(owner string) string {
+	switch {
+	case strings.HasPrefix(owner, "@@"):
+		return "role"
+	case strings.HasPrefix(owner, "@") && strings.Contains(owner, "/"):
+		return "team"
+	case strings.HasPrefix(owner, "@"):
+		return "user"
+	default:
+		return "email"
+	}
+}
+
+// codeownersPatternRegex translates a (gitignore-style) pattern of a CODEOWNERS file into a regex matching the paths
+// of the files it matches, that both Go and Postgres (e.g. git_files.path ~ regex) understand
+func codeownersPatternRegex(pattern string) string {
+	// a pattern with a slash (but for a trailing one) is relative to the root, otherwise it matches at any depth
+	var anchored = strings.Contains(strings.TrimSuffix(pattern, "/"), "/")
+	var directory = strings.HasSuffix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+
+	var b strings.Builder
+	b.WriteString("^")
+	if !anchored {
+		b.WriteString("(.*/)?")
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				// ** matches any number of directories, **/ including none of them
+				if i+2 < len(pattern) && pattern[i+2] == '/' {
+					b.WriteString("(.*/)?")
+					i += 2
+				} else {
+					b.WriteString(".*")
+					i++
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	// a pattern matches the files within the directories it matches (but for those ending with /*, which only match
+	// the files directly within a directory); a trailing slash only matches directories
+	switch {
+	case directory:
+		b.WriteString("/.*$")
+	case strings.HasSuffix(pattern, "/*"):
+		b.WriteString("$")
+	default:
+		b.WriteString("(/.*)?$")
+	}
+	return b.String()
+}
@@ -0,0 +1,353 @@
+package syncer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	uuid "github.com/satori/go.uuid"
+)
+
+// containerExecMaxLine is the maximum size of a line (i.e. of a record) of the output of a CONTAINER_EXEC container
+const containerExecMaxLine = 16 * 1024 * 1024
+
+// containerExecRepoPath is where the clone of the repo is mounted (read-only) in the container, as for container syncs
+const containerExecRepoPath = "/mergestat/repo"
+
+// envVarName matches the valid names of the environment variables passed to the container
+var envVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// imageReference matches the valid references of the images of CONTAINER_EXEC syncs ([registry/]path[:tag][@digest]),
+// which are passed as an argument of podman run, and so must never be parsed as one of its flags
+var imageReference = regexp.MustCompile(`^` +
+	`(?:[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*(?::[0-9]+)?/)?` +
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	`(?::\w[\w.-]{0,127})?` +
+	`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?$`)
+
+// containerExecSettings are the settings accepted by a CONTAINER_EXEC repo sync, which runs an image against the clone
+// of the repo and ingests the records it outputs (as newline-delimited JSON objects on stdout) into a table (that the
+// operator creates, with a repo_id column), e.g.
+//
+//	{
+//	  "image": "docker.io/returntocorp/semgrep:1.50.0",
+//	  "command": ["sh", "-c", "semgrep scan --config auto --json . | jq -c '.results[]'"],
+//	  "table": "semgrep_findings",
+//	  "fields": {"rule": "$.check_id", "path": "$.path", "line": "$.start.line", "message": "$.extra.message"}
+//	}
+type containerExecSettings struct {
+	// Image is the image to run, pulled if it's missing
+	Image string `json:"image"`
+	// Command overrides the command of the image, if set
+	Command []string `json:"command"`
+	// Env are environment variables set in the container
+	Env map[string]string `json:"env"`
+	// Variables are the sync variables of the repo set (by name) as environment variables in the container, e.g. tokens
+	Variables []string `json:"variables"`
+	// Network allows the container to access the network, it has none by default
+	Network bool `json:"network"`
+	// Table is the table (of the custom_syncs schema) the records are written to, replacing the ones of the repo
+	Table string `json:"table"`
+	// Fields maps the columns of the table to the JSONPath of their value in a record. If not set, the members of the
+	// records are written to the columns of the same name.
+	Fields map[string]string `json:"fields"`
+}
+
+// validate checks the settings, and parses the paths of their fields
+func (s *containerExecSettings) validate() (map[string]jsonPath, error) {
+	if s.Image == "" {
+		return nil, errors.New("an image is required")
+	}
+	if !imageReference.MatchString(s.Image) {
+		return nil, fmt.Errorf("invalid image %q", s.Image)
+	}
+	if err := validateTable(s.Table); err != nil {
+		return nil, err
+	}
+
+	for name := range s.Env {
+		if !envVarName.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment variable %q", name)
+		}
+	}
+	for _, name := range s.Variables {
+		if !envVarName.MatchString(name) {
+			return nil, fmt.Errorf("invalid environment variable %q", name)
+		}
+	}
+
+	var fields = make(map[string]jsonPath, len(s.Fields))
+	for column, p := range s.Fields {
+		if !sqlIdentifier.MatchString(column) || column == "repo_id" {
+			return nil, fmt.Errorf("invalid column %q", column)
+		}
+
+		var err error
+		if fields[column], err = parseJSONPath(p); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// runContainerExec runs the image of the settings against the repo at the given path, and returns the records it
+// output, mapped to the fields of the settings (if any)
+func (w *worker) runContainerExec(ctx context.Context, j *db.DequeueSyncJobRow, settings *containerExecSettings, fields map[string]jsonPath, repoPath string, env map[string]string) (_ []map[string]interface{}, err error) {
+	var args = []string{"run", "--rm", "--quiet", "--pull", "missing"}
+	args = append(args, "-v", fmt.Sprintf("%s:%s:ro", repoPath, containerExecRepoPath), "-w", containerExecRepoPath)
+	if !settings.Network {
+		args = append(args, "--network", "none")
+	}
+
+	// the values are passed through an env file (rather than the arguments of podman), so that secrets don't show up in
+	// the process list, and (rather than the environment of podman) so that they can't change how podman itself runs
+	if len(env) > 0 {
+		var envFile string
+		if envFile, err = writeEnvFile(env); err != nil {
+			return nil, err
+		}
+		defer func() { _ = os.Remove(envFile) }()
+		args = append(args, "--env-file", envFile)
+	}
+
+	args = append(args, settings.Image)
+	args = append(args, settings.Command...)
+
+	var stderr bytes.Buffer
+	var cmd = exec.CommandContext(ctx, "podman", args...)
+	cmd.Stderr = &stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start container: %w", err)
+	}
+
+	var records []map[string]interface{}
+	var progress = w.startProgress(ctx, j, "reading records", 0)
+
+	var scanner = bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), containerExecMaxLine)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		// numbers are kept as they are (rather than as float64), so that large ids don't lose precision
+		var record map[string]interface{}
+		var decoder = json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		if err = decoder.Decode(&record); err != nil || record == nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return nil, fmt.Errorf("line %d of the output is not a JSON object", line)
+		}
+
+		if len(fields) > 0 {
+			var mapped = make(map[string]interface{}, len(fields))
+			for column, p := range fields {
+				if matches := p.eval(record); p.hasWildcard() {
+					mapped[column] = matches
+				} else if len(matches) > 0 {
+					mapped[column] = matches[0]
+				}
+			}
+			record = mapped
+		}
+
+		records = append(records, record)
+		progress.set(ctx, int64(len(records)))
+	}
+	// the container is stopped if its output couldn't be read, as it would otherwise block on writing to it
+	var scanErr = scanner.Err()
+	if scanErr != nil {
+		_ = cmd.Process.Kill()
+	}
+
+	if err = cmd.Wait(); err != nil && scanErr == nil {
+		w.logger.Warn().AnErr("error", err).Str("stderr", stderr.String()).Msgf("error running container")
+		return nil, fmt.Errorf("run container: %w: %s", err, lastLine(stderr.String()))
+	}
+	if scanErr != nil {
+		return nil, fmt.Errorf("read output: %w", scanErr)
+	}
+	progress.done(ctx)
+
+	return records, nil
+}
+
+// writeEnvFile writes the given environment variables to a temp file (readable only by the worker) in the format of
+// podman's --env-file, a NAME=value per line, and returns its path
+func writeEnvFile(env map[string]string) (_ string, err error) {
+	var names = make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var contents strings.Builder
+	for _, name := range names {
+		if strings.ContainsAny(env[name], "\r\n") {
+			return "", fmt.Errorf("the value of environment variable %s can't span several lines", name)
+		}
+		fmt.Fprintf(&contents, "%s=%s\n", name, env[name])
+	}
+
+	var f *os.File
+	if f, err = os.CreateTemp("", "mergestat-env-*"); err != nil {
+		return "", fmt.Errorf("create env file: %w", err)
+	}
+	if _, err = f.WriteString(contents.String()); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("write env file: %w", err)
+	}
+	if err = f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("write env file: %w", err)
+	}
+	return f.Name(), nil
+}
+
+// lastLine returns the last (non-empty) line of s, e.g. the error message of a command that failed
+func lastLine(s string) string {
+	var lines = strings.Split(strings.TrimSpace(s), "\n")
+	return lines[len(lines)-1]
+}
+
+// recordColumns returns the columns written for the given records: the ones of the fields (if any), or the members of
+// the records that are columns of the table
+func recordColumns(fields map[string]jsonPath, records []map[string]interface{}, available []string) []string {
+	var columns []string
+	if len(fields) > 0 {
+		for column := range fields {
+			columns = append(columns, column)
+		}
+	} else {
+		var present = make(map[string]bool)
+		for _, r := range records {
+			for member := range r {
+				present[member] = true
+			}
+		}
+		for _, column := range available {
+			if column != "repo_id" && present[column] {
+				columns = append(columns, column)
+			}
+		}
+	}
+	sort.Strings(columns)
+	return columns
+}
+
+// handleContainerExec runs a container image (e.g. an analyzer such as semgrep or scc, or a custom script) against the
+// clone of a repo, and ingests the records it outputs into a table (see containerExecSettings)
+func (w *worker) handleContainerExec(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	var settings containerExecSettings
+	if err = decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	var fields map[string]jsonPath
+	if fields, err = settings.validate(); err != nil {
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+
+	var env = make(map[string]string, len(settings.Env)+len(settings.Variables))
+	for name, value := range settings.Env {
+		env[name] = value
+	}
+	for _, name := range settings.Variables {
+		var value string
+		var found bool
+		if value, found, err = w.db.FetchRepoSyncVar(ctx, j.RepoID, name); err != nil {
+			return fmt.Errorf("fetch sync variable: %w", err)
+		} else if !found {
+			return fmt.Errorf("the %s sync variable of the repo is not set", name)
+		}
+		env[name] = value
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+		}
+	}()
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	records, err := w.runContainerExec(ctx, j, &settings, fields, repoPath, env)
+	if err != nil {
+		return err
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	var columns []string
+	if columns, err = tableColumns(ctx, tx, settings.Table); err != nil {
+		return err
+	}
+
+	if err = replaceJSONRecords(ctx, tx, settings.Table, recordColumns(fields, records, columns), id, records); err != nil {
+		return fmt.Errorf("replace records: %w", err)
+	}
+
+	l.Info().Msgf("inserted records into %s: %d", settings.Table, len(records))
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,62 @@
+package syncer
+
+import (
+	"os"
+	"testing"
+)
+
+func TestImageReference(t *testing.T) {
+	var tests = []struct {
+		image string
+		valid bool
+	}{
+		{image: "alpine", valid: true},
+		{image: "alpine:3.19", valid: true},
+		{image: "docker.io/returntocorp/semgrep:1.50.0", valid: true},
+		{image: "localhost:5000/team/analyzer", valid: true},
+		{image: "ghcr.io/mergestat/scc@sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef", valid: true},
+		{image: ""},
+		{image: "--privileged"},
+		{image: "-v"},
+		{image: "-v=/:/host"},
+		{image: "--volume=/:/host alpine"},
+		{image: "alpine --privileged"},
+		{image: "Alpine"},
+		{image: "alpine:"},
+		{image: "alpine@sha256:abc"},
+	}
+
+	for _, test := range tests {
+		if got := imageReference.MatchString(test.image); got != test.valid {
+			t.Errorf("imageReference.MatchString(%q) = %v, want %v", test.image, got, test.valid)
+		}
+	}
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	path, err := writeEnvFile(map[string]string{"TOKEN": "s3cr3t=", "LANG": "C.UTF-8"})
+	if err != nil {
+		t.Fatalf("writeEnvFile() error = %v", err)
+	}
+	defer func() { _ = os.Remove(path) }()
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read env file: %v", err)
+	}
+	if want := "LANG=C.UTF-8\nTOKEN=s3cr3t=\n"; string(contents) != want {
+		t.Errorf("writeEnvFile() wrote %q, want %q", contents, want)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("failed to stat env file: %v", err)
+	}
+	if mode := info.Mode().Perm(); mode != 0600 {
+		t.Errorf("writeEnvFile() wrote a file with mode %v, want 0600", mode)
+	}
+
+	if _, err = writeEnvFile(map[string]string{"KEY": "line\nLD_PRELOAD=/tmp/evil.so"}); err == nil {
+		t.Errorf("writeEnvFile() of a value spanning several lines, want an error")
+	}
+}
@@ -0,0 +1,100 @@
+package syncer
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// copyBatchSettings are the settings, accepted by the syncs that stream large result sets into postgres, controlling
+// how many rows are sent per COPY. Smaller batches bound the size of each COPY, larger batches save round-trips.
+type copyBatchSettings struct {
+	// BatchSize is the max number of rows sent per COPY. 0 means no limit.
+	BatchSize int `json:"batchSize"`
+
+	// BatchBytes is the (approximate) max number of bytes sent per COPY. 0 means no limit.
+	BatchBytes int `json:"batchBytes"`
+}
+
+// copyBatchSettingsFor returns the batch settings of the given job. Settings of the repo sync take
+// precedence over the defaults of the worker (see config.Config.CopyBatchSize and CopyBatchBytes).
+func (w *worker) copyBatchSettingsFor(job *db.DequeueSyncJobRow) (_ *copyBatchSettings, err error) {
+	var settings = copyBatchSettings{BatchSize: w.config.CopyBatchSize, BatchBytes: w.config.CopyBatchBytes}
+	if err = decodeSettings(job, &settings); err != nil {
+		return nil, err
+	}
+
+	return &settings, nil
+}
+
+// batchedSource wraps a pgx.CopyFromSource, ending the current batch whenever one of the limits is reached
+type batchedSource struct {
+	pgx.CopyFromSource
+	settings    *copyBatchSettings
+	rows, bytes int
+	done        bool
+
+	ctx      context.Context
+	progress *progressReporter // reports the rows sent so far, if set
+}
+
+func (b *batchedSource) Next() bool {
+	if (b.settings.BatchSize > 0 && b.rows >= b.settings.BatchSize) || (b.settings.BatchBytes > 0 && b.bytes >= b.settings.BatchBytes) {
+		return false
+	}
+
+	if !b.CopyFromSource.Next() {
+		b.done = true
+		return false
+	}
+
+	b.rows++
+	b.progress.add(b.ctx, 1)
+	if b.settings.BatchBytes > 0 {
+		if values, err := b.CopyFromSource.Values(); err == nil {
+			b.bytes += approximateSize(values)
+		}
+	}
+
+	return true
+}
+
+// approximateSize returns the approximate number of bytes the given values take up on the wire
+func approximateSize(values []interface{}) (size int) {
+	for _, v := range values {
+		switch v := v.(type) {
+		case string:
+			size += len(v)
+		case *string:
+			if v != nil {
+				size += len(*v)
+			}
+		case []byte:
+			size += len(v)
+		default:
+			size += 8
+		}
+	}
+	return size
+}
+
+// copyInBatches uses the pg COPY protocol to send all the rows of src into the given table,
+// splitting them into as many COPY operations as needed to respect the given batch settings. The number of rows sent
+// is reported as the progress of the job, if a progressReporter is given.
+func copyInBatches(ctx context.Context, tx pgx.Tx, table pgx.Identifier, columns []string, src pgx.CopyFromSource, settings *copyBatchSettings, progress *progressReporter) (int64, error) {
+	var total int64
+	var batch = &batchedSource{CopyFromSource: src, settings: settings, ctx: ctx, progress: progress}
+	for !batch.done {
+		n, err := tx.CopyFrom(ctx, table, columns, batch)
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+		batch.rows, batch.bytes = 0, 0
+	}
+
+	progress.done(ctx)
+	return total, nil
+}
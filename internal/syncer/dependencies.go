@@ -0,0 +1,352 @@
+package syncer
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"golang.org/x/mod/modfile"
+)
+
+// dependency is a dependency declared by a manifest (e.g. package.json), or resolved by a lockfile (e.g. Cargo.lock)
+type dependency struct {
+	ManifestPath string
+	Ecosystem    string // go, npm, pypi, cargo, packagist or rubygems
+	Name         string
+	Version      string // the version (or version constraint, of manifests), empty if not specified
+	Direct       bool   // whether the project depends on it directly, rather than through another dependency
+	Scope        string // runtime, dev, build, peer or optional
+}
+
+// manifestParser parses the dependencies of a manifest (or lockfile) of the given contents
+type manifestParser func(contents []byte) ([]dependency, error)
+
+// manifestParsers are the parsers of the manifests and lockfiles, by their file name
+var manifestParsers = map[string]manifestParser{
+	"go.mod":            parseGoMod,
+	"package.json":      parsePackageJSON,
+	"package-lock.json": parsePackageLock,
+	"requirements.txt":  parseRequirements,
+	"Cargo.toml":        parseCargoToml,
+	"Cargo.lock":        parseCargoLock,
+	"composer.json":     parseComposerJSON,
+	"Gemfile.lock":      parseGemfileLock,
+}
+
+// manifestParserFor returns the parser of the manifest at the given path, or nil if it's not a manifest
+func manifestParserFor(filePath string) manifestParser {
+	var name = path.Base(filePath)
+	// vendored dependencies are dependencies of the dependencies, not of the project
+	for _, dir := range strings.Split(path.Dir(filePath), "/") {
+		if dir == "node_modules" || dir == "vendor" {
+			return nil
+		}
+	}
+	return manifestParsers[name]
+}
+
+// parseManifest parses the dependencies of the manifest at the given path, each of them once
+func parseManifest(filePath string, contents []byte) ([]dependency, error) {
+	var parse = manifestParserFor(filePath)
+	if parse == nil {
+		return nil, nil
+	}
+
+	deps, err := parse(contents)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filePath, err)
+	}
+
+	// the same version of a dependency can be resolved more than once (e.g. at different depths of a package-lock.json)
+	var unique []dependency
+	var seen = make(map[[3]string]int)
+	for _, d := range deps {
+		var key = [3]string{d.Ecosystem, d.Name, d.Version}
+		if i, ok := seen[key]; ok {
+			unique[i].Direct = unique[i].Direct || d.Direct
+			continue
+		}
+		d.ManifestPath = filePath
+		seen[key] = len(unique)
+		unique = append(unique, d)
+	}
+	return unique, nil
+}
+
+// parseGoMod parses the requirements of a go.mod, those marked // indirect being transitive
+func parseGoMod(contents []byte) ([]dependency, error) {
+	f, err := modfile.ParseLax("go.mod", contents, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var deps []dependency
+	for _, r := range f.Require {
+		deps = append(deps, dependency{Ecosystem: "go", Name: r.Mod.Path, Version: r.Mod.Version, Direct: !r.Indirect, Scope: "runtime"})
+	}
+	return deps, nil
+}
+
+// npmScopes are the dependency fields of a package.json, by their scope
+var npmScopes = []struct{ Field, Scope string }{
+	{"dependencies", "runtime"}, {"devDependencies", "dev"}, {"peerDependencies", "peer"}, {"optionalDependencies", "optional"},
+}
+
+// parsePackageJSON parses the dependencies (of all the scopes) of a package.json
+func parsePackageJSON(contents []byte) ([]dependency, error) {
+	var manifest map[string]json.RawMessage
+	if err := json.Unmarshal(contents, &manifest); err != nil {
+		return nil, err
+	}
+
+	var deps []dependency
+	for _, s := range npmScopes {
+		var versions map[string]string
+		if raw, ok := manifest[s.Field]; !ok {
+			continue
+		} else if err := json.Unmarshal(raw, &versions); err != nil {
+			return nil, fmt.Errorf("%s: %w", s.Field, err)
+		}
+
+		for name, version := range versions {
+			deps = append(deps, dependency{Ecosystem: "npm", Name: name, Version: version, Direct: true, Scope: s.Scope})
+		}
+	}
+	return deps, nil
+}
+
+// parsePackageLock parses the packages resolved by a package-lock.json. Of lockfiles of version 1, which don't record
+// the dependencies of the project itself, all the packages are considered transitive.
+func parsePackageLock(contents []byte) ([]dependency, error) {
+	type lockedPackage struct {
+		Version              string                     `json:"version"`
+		Dev                  bool                       `json:"dev"`
+		Optional             bool                       `json:"optional"`
+		DevOptional          bool                       `json:"devOptional"`
+		Dependencies         map[string]json.RawMessage `json:"dependencies"`
+		DevDependencies      map[string]json.RawMessage `json:"devDependencies"`
+		PeerDependencies     map[string]json.RawMessage `json:"peerDependencies"`
+		OptionalDependencies map[string]json.RawMessage `json:"optionalDependencies"`
+	}
+
+	var lock struct {
+		Packages     map[string]lockedPackage   `json:"packages"`
+		Dependencies map[string]json.RawMessage `json:"dependencies"`
+	}
+	if err := json.Unmarshal(contents, &lock); err != nil {
+		return nil, err
+	}
+
+	var scope = func(p lockedPackage) string {
+		switch {
+		case p.Dev || p.DevOptional:
+			return "dev"
+		case p.Optional:
+			return "optional"
+		default:
+			return "runtime"
+		}
+	}
+
+	var deps []dependency
+	if lock.Packages != nil {
+		var root, direct = lock.Packages[""], make(map[string]bool)
+		for _, m := range []map[string]json.RawMessage{root.Dependencies, root.DevDependencies, root.PeerDependencies, root.OptionalDependencies} {
+			for name := range m {
+				direct[name] = true
+			}
+		}
+
+		for key, p := range lock.Packages {
+			// packages of the workspace (and the root itself) are part of the project, not dependencies
+			var i = strings.LastIndex(key, "node_modules/")
+			if i < 0 || p.Version == "" {
+				continue
+			}
+
+			var name = key[i+len("node_modules/"):]
+			var nested = strings.Count(key, "node_modules/") > 1
+			deps = append(deps, dependency{Ecosystem: "npm", Name: name, Version: p.Version, Direct: !nested && direct[name], Scope: scope(p)})
+		}
+		return deps, nil
+	}
+
+	// lockfiles of version 1 nest the dependencies of packages within them
+	var walk func(map[string]json.RawMessage) error
+	walk = func(m map[string]json.RawMessage) error {
+		for name, raw := range m {
+			var p lockedPackage
+			if err := json.Unmarshal(raw, &p); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			deps = append(deps, dependency{Ecosystem: "npm", Name: name, Version: p.Version, Scope: scope(p)})
+			if err := walk(p.Dependencies); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(lock.Dependencies); err != nil {
+		return nil, err
+	}
+	return deps, nil
+}
+
+// requirement matches a requirement of a requirements.txt, capturing the name of the package and its version specifier
+var requirement = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*([^;]*)`)
+
+// parseRequirements parses the requirements of a (pip) requirements.txt, ignoring the options (e.g. -r or -e) and
+// the requirements of URLs
+func parseRequirements(contents []byte) ([]dependency, error) {
+	var deps []dependency
+	var scanner = bufio.NewScanner(strings.NewReader(string(contents)))
+	for scanner.Scan() {
+		var line = scanner.Text()
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "-") || strings.Contains(line, "://") {
+			continue
+		}
+
+		if m := requirement.FindStringSubmatch(line); m != nil {
+			deps = append(deps, dependency{Ecosystem: "pypi", Name: m[1], Version: strings.TrimSpace(m[2]), Direct: true, Scope: "runtime"})
+		}
+	}
+	return deps, scanner.Err()
+}
+
+// parseCargoToml parses the dependencies (of all the scopes) of a Cargo.toml
+func parseCargoToml(contents []byte) ([]dependency, error) {
+	var manifest map[string]interface{}
+	if err := toml.Unmarshal(contents, &manifest); err != nil {
+		return nil, err
+	}
+
+	var deps []dependency
+	for _, s := range []struct{ Table, Scope string }{{"dependencies", "runtime"}, {"dev-dependencies", "dev"}, {"build-dependencies", "build"}} {
+		var table, _ = manifest[s.Table].(map[string]interface{})
+		for name, value := range table {
+			// a dependency is either its version, or a table of its version (if any) and source (e.g. path or git)
+			var version string
+			switch v := value.(type) {
+			case string:
+				version = v
+			case map[string]interface{}:
+				version, _ = v["version"].(string)
+			}
+			deps = append(deps, dependency{Ecosystem: "cargo", Name: name, Version: version, Direct: true, Scope: s.Scope})
+		}
+	}
+	return deps, nil
+}
+
+// parseCargoLock parses the packages resolved by a Cargo.lock. The packages of the project (of its workspace) are the
+// ones without a source, and the dependencies of those are direct.
+func parseCargoLock(contents []byte) ([]dependency, error) {
+	var lock struct {
+		Packages []struct {
+			Name         string   `toml:"name"`
+			Version      string   `toml:"version"`
+			Source       string   `toml:"source"`
+			Dependencies []string `toml:"dependencies"`
+		} `toml:"package"`
+	}
+	if err := toml.Unmarshal(contents, &lock); err != nil {
+		return nil, err
+	}
+
+	var direct = make(map[string]bool)
+	for _, p := range lock.Packages {
+		if p.Source != "" {
+			continue
+		}
+		// dependencies are listed as their name, followed by their version when several versions are resolved
+		for _, d := range p.Dependencies {
+			direct[strings.Fields(d)[0]] = true
+		}
+	}
+
+	var deps []dependency
+	for _, p := range lock.Packages {
+		if p.Source == "" {
+			continue
+		}
+		deps = append(deps, dependency{Ecosystem: "cargo", Name: p.Name, Version: p.Version, Direct: direct[p.Name], Scope: "runtime"})
+	}
+	return deps, nil
+}
+
+// parseComposerJSON parses the requirements of a composer.json, but for those on php itself and its extensions
+func parseComposerJSON(contents []byte) ([]dependency, error) {
+	var manifest struct {
+		Require    map[string]string `json:"require"`
+		RequireDev map[string]string `json:"require-dev"`
+	}
+	if err := json.Unmarshal(contents, &manifest); err != nil {
+		return nil, err
+	}
+
+	var deps []dependency
+	for _, s := range []struct {
+		Versions map[string]string
+		Scope    string
+	}{{manifest.Require, "runtime"}, {manifest.RequireDev, "dev"}} {
+		for name, version := range s.Versions {
+			if name == "php" || strings.HasPrefix(name, "ext-") {
+				continue
+			}
+			deps = append(deps, dependency{Ecosystem: "packagist", Name: name, Version: version, Direct: true, Scope: s.Scope})
+		}
+	}
+	return deps, nil
+}
+
+// gemSpec matches a gem resolved by a Gemfile.lock (of its specs), or depended upon (of its DEPENDENCIES section),
+// capturing its name and version (or version constraint)
+var gemSpec = regexp.MustCompile(`^\s+([^\s(!]+)!?(?:\s+\(([^)]*)\))?$`)
+
+// parseGemfileLock parses the gems resolved by a Gemfile.lock, the ones of its DEPENDENCIES section being direct
+func parseGemfileLock(contents []byte) ([]dependency, error) {
+	var deps []dependency
+	var direct = make(map[string]bool)
+
+	var section string
+	var scanner = bufio.NewScanner(strings.NewReader(string(contents)))
+	for scanner.Scan() {
+		var line = strings.TrimRight(scanner.Text(), " \r")
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			section = line
+			continue
+		}
+
+		var m = gemSpec.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		switch {
+		case section == "DEPENDENCIES":
+			direct[m[1]] = true
+		case (section == "GEM" || section == "GIT" || section == "PATH") && strings.HasPrefix(line, "    ") && !strings.HasPrefix(line, "      "):
+			// specs are indented by 4 spaces, and their own dependencies by 6
+			deps = append(deps, dependency{Ecosystem: "rubygems", Name: m[1], Version: m[2], Scope: "runtime"})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range deps {
+		deps[i].Direct = direct[deps[i].Name]
+	}
+	return deps, nil
+}
@@ -0,0 +1,96 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/destination"
+)
+
+// destinationsJobKey is the context key of a job whose rows are sent to the destinations of the worker (see handle)
+type destinationsJobKey struct{}
+
+// destinationTx is a transaction whose copied rows are sent to the destinations of the worker (see package destination)
+// once it's committed. In destinations-only mode (see config.Config.DestinationsOnly) the rows are only sent to the
+// destinations, and the transaction is rolled back on commit. Rows written with other statements (e.g. an
+// INSERT ... SELECT) aren't sent.
+type destinationTx struct {
+	pgx.Tx
+	destinations []destination.Destination
+	job          *db.DequeueSyncJobRow
+	only         bool
+
+	mu     sync.Mutex
+	tables map[string]*destination.Batch // rows to send, by table
+}
+
+func (tx *destinationTx) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	var name = unstaged(strings.Join(table, "."))
+
+	tx.mu.Lock()
+	if _, ok := tx.tables[name]; !ok {
+		tx.tables[name] = &destination.Batch{JobID: tx.job.ID, RepoID: tx.job.RepoID, Repo: tx.job.Repo,
+			SyncType: tx.job.SyncType, Table: name, Columns: columns}
+	}
+	tx.mu.Unlock()
+
+	return tx.Tx.CopyFrom(ctx, table, columns, &destinationSource{CopyFromSource: src, tx: tx, table: name})
+}
+
+func (tx *destinationTx) Commit(ctx context.Context) (err error) {
+	if tx.only {
+		err = tx.Tx.Rollback(ctx)
+	} else {
+		err = tx.Tx.Commit(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	var tables = make([]string, 0, len(tx.tables))
+	for name := range tx.tables {
+		tables = append(tables, name)
+	}
+	sort.Strings(tables)
+
+	for _, dest := range tx.destinations {
+		for _, name := range tables {
+			if err := dest.Send(ctx, tx.tables[name]); err != nil {
+				return fmt.Errorf("send rows of %s to %s: %w", name, dest.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// destinationSource wraps a pgx.CopyFromSource, queueing every row copied from it to be sent to the destinations
+type destinationSource struct {
+	pgx.CopyFromSource
+	tx    *destinationTx
+	table string
+}
+
+func (s *destinationSource) Values() ([]interface{}, error) {
+	values, err := s.CopyFromSource.Values()
+	if err != nil {
+		return nil, err
+	}
+
+	// the values of a row may be reused by the source once the next row is read
+	var row = make([]interface{}, len(values))
+	copy(row, values)
+
+	s.tx.mu.Lock()
+	s.tx.tables[s.table].Rows = append(s.tx.tables[s.table].Rows, row)
+	s.tx.mu.Unlock()
+
+	return values, nil
+}
@@ -0,0 +1,134 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
+)
+
+// ensureDiskSpace makes sure there's room to clone the repo of the job into dir, based on an estimate of its size (see
+// EstimateRepoSize) plus the headroom to leave free. If there isn't, and eviction of the clone cache is enabled, the least
+// recently used clones of the clone cache (if it's on the same filesystem) are evicted to make room. Otherwise the job is
+// refused with an error saying as much, rather than failing midway through the clone once the disk is full.
+func (w *worker) ensureDiskSpace(ctx context.Context, dir string, job *db.DequeueSyncJobRow) (err error) {
+	var minFreeMB = w.config.GitCloneMinFreeMB
+
+	var estimate int64
+	if estimate, err = w.db.EstimateRepoSize(ctx, job.RepoID); err != nil {
+		return errors.Wrapf(err, "failed to estimate repo size")
+	}
+
+	var required = uint64(estimate) + uint64(minFreeMB)<<20
+
+	var available uint64
+	if available, err = availableDiskSpace(dir); err != nil {
+		return err
+	}
+
+	if available < required && w.config.GitCloneCacheEviction {
+		if cacheDir := w.config.GitCloneCachePath; cacheDir != "" && sameFilesystem(dir, cacheDir) {
+			var evicted int
+			if evicted, err = w.evictClones(cacheDir, required-available); err != nil {
+				w.loggerForJob(job).Err(err).Msgf("error evicting clone cache: %v", err)
+			} else if evicted > 0 {
+				w.loggerForJob(job).Info().Msgf("evicted %d clone(s) from the clone cache to make room", evicted)
+			}
+
+			if available, err = availableDiskSpace(dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	if available < required {
+		return fmt.Errorf("not enough disk space to clone repo: %d MB available in %s, but %d MB is needed (an estimated %d MB for the clone, plus %d MB of headroom)",
+			available>>20, dir, required>>20, estimate>>20, minFreeMB)
+	}
+
+	return nil
+}
+
+// evictClones removes the least recently used clones from the clone cache, until at least the given number of bytes is freed
+// (or there's nothing left to evict). Clones being updated or read by a job (which holds their lock, see cachedClone) are
+// skipped, as are clones still being made (in temp dirs). It returns the number of clones evicted.
+func (w *worker) evictClones(cacheDir string, bytes uint64) (evicted int, err error) {
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(cacheDir); err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	// clones are touched whenever they're used (see cachedClone), so their mod time tells when they were last used
+	type clone struct {
+		path string
+		used int64
+	}
+	var clones []clone
+	for _, entry := range entries {
+		// clones (<sha256>.git) and the mirrors they used to be (<sha256>), but not the temp dirs of clones being made
+		if !strings.HasSuffix(entry.Name(), ".git") && !legacyCachedMirror.MatchString(entry.Name()) {
+			continue
+		}
+
+		if info, err := entry.Info(); err == nil && entry.IsDir() {
+			clones = append(clones, clone{path: filepath.Join(cacheDir, entry.Name()), used: info.ModTime().UnixNano()})
+		}
+	}
+	sort.Slice(clones, func(i, j int) bool { return clones[i].used < clones[j].used })
+
+	var freed uint64
+	for _, c := range clones {
+		if freed >= bytes {
+			break
+		}
+
+		var mu, _ = w.cacheLocks.LoadOrStore(c.path, &sync.RWMutex{})
+		if !mu.(*sync.RWMutex).TryLock() {
+			continue
+		}
+
+		var size = diskUsage(c.path)
+		err = os.RemoveAll(c.path)
+		mu.(*sync.RWMutex).Unlock()
+		if err != nil {
+			return evicted, err
+		}
+
+		freed += size
+		evicted++
+	}
+
+	return evicted, nil
+}
+
+// legacyCachedMirror matches the names of the mirrors cached clones used to be, which aren't used anymore
+var legacyCachedMirror = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// availableDiskSpace returns the number of bytes available (to unprivileged users) on the filesystem of the given path
+func availableDiskSpace(path string) (uint64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, errors.Wrapf(err, "failed to stat filesystem of %s", path)
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
+
+// sameFilesystem returns whether the given paths are on the same filesystem (device)
+func sameFilesystem(a, b string) bool {
+	var statA, statB unix.Stat_t
+	if unix.Stat(a, &statA) != nil || unix.Stat(b, &statB) != nil {
+		return false
+	}
+	return statA.Dev == statB.Dev
+}
@@ -0,0 +1,116 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/destination"
+)
+
+// dryRunKey is the context key of the dryRun of a job running in dry-run mode
+type dryRunKey struct{}
+
+// dryRunUnsupportedSyncTypes are the sync types that write outside of their handler's transaction
+// (e.g. GitHub Actions, which writes through the warehouse), and so can't be run in dry-run mode
+var dryRunUnsupportedSyncTypes = map[string]bool{
+	syncTypeGitHubActions: true,
+}
+
+// dryRunSettings are the settings, accepted by all sync types, that run a sync in dry-run mode
+type dryRunSettings struct {
+	// DryRun runs the sync without writing anything, only reporting the rows it would have written
+	DryRun bool `json:"dryRun"`
+}
+
+// dryRun marks a job running in dry-run mode, whose writes are all rolled back in the end (see dryRunTx)
+type dryRun struct {
+	counts *rowCounts // rows the job would have written
+}
+
+// isDryRun returns whether the given job runs in dry-run mode, i.e. if it's enabled for the whole worker, or in the
+// settings of its repo sync
+func (w *worker) isDryRun(job *db.DequeueSyncJobRow) (bool, error) {
+	var settings dryRunSettings
+	if err := decodeSettings(job, &settings); err != nil {
+		return false, err
+	}
+	return w.config.DryRun || settings.DryRun, nil
+}
+
+// beginTx begins the transaction a handler writes its rows in. Everything written in the transaction is counted (see
+// rowCounts), and the copied rows are sent to the destinations of the worker once committed (see destinationTx). If
+// the job runs in dry-run mode (see handle), it's rolled back instead of being committed.
+func (w *worker) beginTx(ctx context.Context) (pgx.Tx, error) {
+	tx, err := w.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	if counts := rowCountsOf(ctx); counts != nil {
+		tx = &countingTx{Tx: tx, counts: counts}
+	}
+
+	if j, ok := ctx.Value(destinationsJobKey{}).(*db.DequeueSyncJobRow); ok {
+		tx = &destinationTx{Tx: tx, destinations: w.destinations, job: j, only: w.config.DestinationsOnly,
+			tables: make(map[string]*destination.Batch)}
+	}
+
+	if _, ok := ctx.Value(dryRunKey{}).(*dryRun); ok {
+		return &dryRunTx{Tx: tx}, nil
+	}
+	return tx, nil
+}
+
+// finishDryRun reports the rows written by a job in dry-run mode, and marks it as done
+// (as the status set by its handler was rolled back along with everything else)
+func (w *worker) finishDryRun(ctx context.Context, j *db.DequeueSyncJobRow, dr *dryRun) error {
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
+		Type:            SyncLogTypeInfo,
+		RepoSyncQueueID: j.ID,
+		Message:         dr.summary(),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	if err := w.db.SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("update status done: %w", err)
+	}
+
+	return nil
+}
+
+// summary returns a (job log) message summing up the rows written by the job
+func (dr *dryRun) summary() string {
+	var copiedRows, inserted, updated, deleted = dr.counts.snapshot()
+
+	var tables = make([]string, 0, len(copiedRows))
+	for table := range copiedRows {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var copied = make([]string, 0, len(tables))
+	for _, table := range tables {
+		copied = append(copied, fmt.Sprintf("%d row(s) into %s", copiedRows[table], table))
+	}
+	if len(copied) == 0 {
+		copied = append(copied, "no rows")
+	}
+
+	return fmt.Sprintf("dry run, nothing was written: the sync would have copied %s, and inserted %d, updated %d and deleted %d row(s)",
+		strings.Join(copied, ", "), inserted, updated, deleted)
+}
+
+// dryRunTx is a transaction of a job in dry-run mode. Statements run as they would otherwise (so that e.g. constraint
+// violations still fail the job) but the transaction is rolled back on commit.
+type dryRunTx struct {
+	pgx.Tx
+}
+
+func (tx *dryRunTx) Commit(ctx context.Context) error {
+	return tx.Tx.Rollback(ctx)
+}
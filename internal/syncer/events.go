@@ -0,0 +1,104 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// eventsWebhookTimeout bounds the request posting a completion event to the events webhook (if configured)
+const eventsWebhookTimeout = 10 * time.Second
+
+// eventsSignatureHeader is the header of the (hex encoded) HMAC-SHA256 of the body of a completion event posted to the
+// events webhook, keyed with SYNC_EVENTS_WEBHOOK_SECRET (if set), in the same format as the signatures of GitHub webhooks
+const eventsSignatureHeader = "X-Mergestat-Signature-256"
+
+// syncCompletedEvent is published whenever a sync job completes, so that downstream pipelines can react to it right away
+type syncCompletedEvent struct {
+	JobID        int64            `json:"job_id"`
+	RepoID       uuid.UUID        `json:"repo_id"`
+	Repo         string           `json:"repo"`
+	SyncType     string           `json:"sync_type"`
+	Status       string           `json:"status"`
+	RowsCopied   map[string]int64 `json:"rows_copied"` // by table
+	RowsInserted int64            `json:"rows_inserted"`
+	RowsUpdated  int64            `json:"rows_updated"`
+	RowsDeleted  int64            `json:"rows_deleted"`
+	StartedAt    time.Time        `json:"started_at"`
+	CompletedAt  time.Time        `json:"completed_at"`
+	DurationMs   int64            `json:"duration_ms"`
+}
+
+// publishCompleted publishes the completion event of a job that committed, with pg_notify (on the
+// mergestat_sync_completed channel) and to the events webhook, if configured. Publishing is best effort,
+// errors are only logged, and the webhook is posted to in the background so that it never holds up the job.
+func (w *worker) publishCompleted(ctx context.Context, j *db.DequeueSyncJobRow, counts *rowCounts, startedAt time.Time) {
+	var completedAt = time.Now()
+	var event = syncCompletedEvent{
+		JobID:       j.ID,
+		RepoID:      j.RepoID,
+		Repo:        j.Repo,
+		SyncType:    j.SyncType,
+		Status:      "DONE",
+		StartedAt:   startedAt,
+		CompletedAt: completedAt,
+		DurationMs:  completedAt.Sub(startedAt).Milliseconds(),
+	}
+	event.RowsCopied, event.RowsInserted, event.RowsUpdated, event.RowsDeleted = counts.snapshot()
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		w.loggerForJob(j).Err(err).Msgf("error encoding sync completed event: %v", err)
+		return
+	}
+
+	if err := w.db.NotifySyncCompleted(ctx, string(payload)); err != nil {
+		w.loggerForJob(j).Err(err).Msgf("error notifying sync completed event: %v", err)
+	}
+
+	if w.config.SyncEventsWebhookURL != "" {
+		go func() {
+			if err := w.postEvent(payload); err != nil {
+				w.loggerForJob(j).Err(err).Msgf("error posting sync completed event to webhook: %v", err)
+			}
+		}()
+	}
+}
+
+// postEvent posts the given (JSON) event to the events webhook, signed with the events webhook secret (if set)
+func (w *worker) postEvent(payload []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), eventsWebhookTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.config.SyncEventsWebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if len(w.eventsSecret) > 0 {
+		var mac = hmac.New(sha256.New, w.eventsSecret)
+		mac.Write(payload)
+		req.Header.Set(eventsSignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -10,6 +10,7 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"strings"
 	"time"
@@ -20,10 +21,42 @@ import (
 	"github.com/mergestat/gitutils/blame"
 	"github.com/mergestat/gitutils/lstree"
 	"github.com/mergestat/mergestat/internal/db"
-	"github.com/mergestat/mergestat/internal/helper"
 	uuid "github.com/satori/go.uuid"
 )
 
+// blameLinesSource streams blamed lines from a json file into the pg COPY protocol
+type blameLinesSource struct {
+	repo    uuid.UUID
+	decoder *json.Decoder
+	current []interface{}
+	err     error
+}
+
+func (s *blameLinesSource) Next() bool {
+	var bl *blameLine
+	if err := s.decoder.Decode(&bl); err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		return false
+	}
+
+	// sanitize the line of null chars, similar to what's done in GIT_FILES syncer
+	var line interface{}
+	if bl.Line != nil && utf8.ValidString(*bl.Line) {
+		line = strings.ReplaceAll(*bl.Line, "\u0000", "")
+	} else {
+		line = nil
+	}
+
+	s.current = []interface{}{s.repo, bl.AuthorEmail, bl.AuthorName, bl.AuthorWhen, bl.CommitHash, bl.LineNo, line, bl.Path}
+	return true
+}
+
+func (s *blameLinesSource) Values() ([]interface{}, error) { return s.current, nil }
+
+func (s *blameLinesSource) Err() error { return s.err }
+
 func (w *worker) sendBatchBlameLines(ctx context.Context, blameTmpPath string, tx pgx.Tx, j *db.DequeueSyncJobRow) (int, error) {
 	var (
 		f   *os.File
@@ -35,74 +68,26 @@ func (w *worker) sendBatchBlameLines(ctx context.Context, blameTmpPath string, t
 	}
 
 	defer os.Remove(f.Name())
+	defer f.Close()
 
-	var (
-		// Create a new JSON decoder for the file
-		decoder       = json.NewDecoder(f)
-		inputs        = make([][]interface{}, 0, 100)
-		insertedLines = 0
-		isEOF         = false
-	)
-
-	// Using a double loop to walk through json file  until the len of inputs
-	// is equal to the capacity or is OEF, either will break inner loop and the outer
-	// loop copies batches of 100 or less items untils EOF is reached
-	for {
-
-		for {
-			// Decode the next JSON value into a blameLine struct
-			var bl *blameLine
-			err = decoder.Decode(&bl)
-
-			// If we've reached the end of the file, break out of the loop
-			// and set isEOF to true
-			if err == io.EOF {
-				isEOF = true
-				break
-			}
-
-			if err != nil {
-				return 0, err
-			}
-
-			var repoID uuid.UUID
-			var err error
-			if repoID, err = uuid.FromString(j.RepoID.String()); err != nil {
-				return 0, fmt.Errorf("uuid: %w", err)
-			}
-
-			// sanitize the line of null chars, similar to what's done in GIT_FILES syncer
-			var line interface{}
-			if bl.Line != nil && utf8.ValidString(*bl.Line) {
-				line = strings.ReplaceAll(*bl.Line, "\u0000", "")
-			} else {
-				line = nil
-			}
-
-			input := []interface{}{repoID, bl.AuthorEmail, bl.AuthorName, bl.AuthorWhen, bl.CommitHash, bl.LineNo, line, bl.Path}
-			inputs = append(inputs, input)
-
-			if len(inputs) == cap(inputs) {
-				break
-			}
-		}
-
-		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"git_blame"}, []string{"repo_id", "author_email", "author_name", "author_when", "commit_hash", "line_no", "line", "path"}, pgx.CopyFromRows(inputs)); err != nil {
-			return 0, fmt.Errorf("tx copy from: %w", err)
-		}
+	var repoID uuid.UUID
+	if repoID, err = uuid.FromString(j.RepoID.String()); err != nil {
+		return 0, fmt.Errorf("uuid: %w", err)
+	}
 
-		insertedLines += len(inputs)
+	var settings *copyBatchSettings
+	if settings, err = w.copyBatchSettingsFor(j); err != nil {
+		return 0, err
+	}
 
-		//cleaning slice and keeping capacity
-		inputs = inputs[:0]
+	var src = &blameLinesSource{repo: repoID, decoder: json.NewDecoder(f)}
 
-		// if we reach EOF we exit
-		if isEOF {
-			break
-		}
+	var inserted int64
+	if inserted, err = copyInBatches(ctx, tx, pgx.Identifier{staging("git_blame")}, []string{"repo_id", "author_email", "author_name", "author_when", "commit_hash", "line_no", "line", "path"}, src, settings, w.startProgress(ctx, j, "copying blamed lines", 0)); err != nil {
+		return 0, fmt.Errorf("tx copy from: %w", err)
 	}
 
-	return insertedLines, nil
+	return int(inserted), nil
 }
 
 type blameLine struct {
@@ -115,6 +100,45 @@ type blameLine struct {
 	Path        *string
 }
 
+// gitBlameSettings are the settings accepted by a GIT_BLAME repo sync
+type gitBlameSettings struct {
+	// Include is a list of path globs, if set only matching files are blamed
+	Include []string `json:"include"`
+	// Exclude is a list of path globs, matching files are never blamed
+	Exclude []string `json:"exclude"`
+}
+
+// validate checks that all the configured globs are well-formed
+func (s *gitBlameSettings) validate() error {
+	for _, pattern := range append(s.Include, s.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid path glob %q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// shouldBlame reports whether the file at the given path should be blamed
+func (s *gitBlameSettings) shouldBlame(filePath string) bool {
+	if len(s.Include) > 0 && !matchesAnyGlob(s.Include, filePath) {
+		return false
+	}
+	return !matchesAnyGlob(s.Exclude, filePath)
+}
+
+// matchesAnyGlob reports whether filePath, or any of its parent directories, matches one of the patterns.
+// Matching parent directories allows a pattern such as "vendor" to cover everything beneath it.
+func matchesAnyGlob(patterns []string, filePath string) bool {
+	for _, pattern := range patterns {
+		for p := filePath; p != "." && p != "/"; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (w *worker) handleGitBlame(ctx context.Context, j *db.DequeueSyncJobRow) error {
 	var err error
 	l := w.loggerForJob(j)
@@ -125,7 +149,16 @@ func (w *worker) handleGitBlame(ctx context.Context, j *db.DequeueSyncJobRow) er
 		return fmt.Errorf("send batch log messages: %w", err)
 	}
 
-	tmpPath, cleanup, err := helper.CreateTempDir(os.Getenv("GIT_CLONE_PATH"), fmt.Sprintf("mergestat-repo-%s-*", j.RepoID.String()))
+	var settings gitBlameSettings
+	if err = decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	if err = settings.validate(); err != nil {
+		return err
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
 	if err != nil {
 		return fmt.Errorf("temp dir: %w", err)
 	}
@@ -173,6 +206,11 @@ func (w *worker) handleGitBlame(ctx context.Context, j *db.DequeueSyncJobRow) er
 			continue
 		}
 
+		// skip any files filtered out by the configured path globs
+		if !settings.shouldBlame(o.Path) {
+			continue
+		}
+
 		// skip running git blame on binary files
 		// first detect if a file is binary or not
 		fullPath := filepath.Join(tmpPath, o.Path)
@@ -255,7 +293,7 @@ func (w *worker) handleGitBlame(ctx context.Context, j *db.DequeueSyncJobRow) er
 	}
 
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer func() {
@@ -266,16 +304,7 @@ func (w *worker) handleGitBlame(ctx context.Context, j *db.DequeueSyncJobRow) er
 		}
 	}()
 
-	r, err := tx.Exec(ctx, "DELETE FROM git_blame WHERE repo_id = $1;", j.RepoID.String())
-	if err != nil {
-		return fmt.Errorf("exec delete: %w", err)
-	}
-
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("removed %d row(s) from git_blame", r.RowsAffected()),
-	}}); err != nil {
+	if err = stage(ctx, tx, "git_blame"); err != nil {
 		return err
 	}
 	var blamedLines int
@@ -285,11 +314,7 @@ func (w *worker) handleGitBlame(ctx context.Context, j *db.DequeueSyncJobRow) er
 
 	l.Info().Msgf("sent batch of %d blamed lines", blamedLines)
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("inserted %d row(s) into git_blame", blamedLines),
-	}}); err != nil {
+	if err := w.mergeStaged(ctx, tx, j, "git_blame", int64(blamedLines)); err != nil {
 		return err
 	}
 
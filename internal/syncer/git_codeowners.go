@@ -0,0 +1,150 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// readCodeowners returns the path and contents of the CODEOWNERS file in the HEAD of the repository at repoPath, or
+// an empty path if it has none
+func readCodeowners(repoPath string) (string, string, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return "", "", fmt.Errorf("could not open repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return "", "", fmt.Errorf("could not resolve HEAD: %w", err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, path := range codeownersPaths {
+		var f *object.File
+		if f, err = commit.File(path); errors.Is(err, object.ErrFileNotFound) {
+			continue
+		} else if err != nil {
+			return "", "", err
+		}
+
+		contents, err := f.Contents()
+		if err != nil {
+			return "", "", fmt.Errorf("read %s: %w", path, err)
+		}
+		return path, contents, nil
+	}
+
+	return "", "", nil
+}
+
+// sendBatchCodeowners uses the pg COPY protocol to send the rules of the CODEOWNERS file at the given path into the
+// given table
+func (w *worker) sendBatchCodeowners(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, path string, rules []codeownersRule) (int64, error) {
+	inputs := make([][]interface{}, 0, len(rules))
+	for _, r := range rules {
+		inputs = append(inputs, []interface{}{j.RepoID, path, r.Line, r.Ordinal, r.Pattern, r.Regex, nullIfEmpty(r.Owner), nullIfEmpty(r.OwnerType), nullIfEmpty(r.Section), r.Optional})
+	}
+
+	settings, err := w.copyBatchSettingsFor(j)
+	if err != nil {
+		return 0, err
+	}
+
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "file_path", "line", "ordinal", "pattern", "pattern_regex", "owner", "owner_type", "section", "optional"}, pgx.CopyFromRows(inputs), settings, nil)
+}
+
+func (w *worker) handleGitCodeowners(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+		}
+	}()
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	path, contents, err := readCodeowners(repoPath)
+	if err != nil {
+		return err
+	}
+
+	// a repo without a CODEOWNERS file has no rules (any it had before are deleted)
+	var rules []codeownersRule
+	if path != "" {
+		rules = parseCodeowners(contents)
+	} else {
+		if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+			Message: "no CODEOWNERS file found",
+		}}); err != nil {
+			return fmt.Errorf("send batch log messages: %w", err)
+		}
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("could not rollback transaction")
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "git_codeowners"); err != nil {
+		return err
+	}
+
+	var inserted int64
+	if inserted, err = w.sendBatchCodeowners(ctx, tx, staging("git_codeowners"), j, path, rules); err != nil {
+		return fmt.Errorf("send batch codeowners: %w", err)
+	}
+
+	l.Info().Msgf("sent batch of %d codeowners rules", inserted)
+
+	if err := w.mergeStaged(ctx, tx, j, "git_codeowners", inserted); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("update status done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	return err
+}
@@ -3,14 +3,15 @@ package syncer
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/jackc/pgx/v4"
 	libgit2 "github.com/libgit2/git2go/v33"
 	"github.com/mergestat/mergestat/internal/db"
-	"github.com/mergestat/mergestat/internal/helper"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -41,81 +42,116 @@ func gitFileModeObjectTypeFromUint16(mode uint16) GitFileModeObjectType {
 	}
 }
 
-// sendBatchCommitStats uses the pg COPY protocol to send a batch of commit stats
-func (w *worker) sendBatchCommitStats(ctx context.Context, tx pgx.Tx, j *db.DequeueSyncJobRow, batch []*commitStat) error {
-	inputs := make([][]interface{}, 0, len(batch))
-	for _, c := range batch {
-		var repoID uuid.UUID
-		var err error
-		if repoID, err = uuid.FromString(j.RepoID.String()); err != nil {
-			return err
+// commitStatsSource streams commit stats from a json file (as written by collectCommitStats) into the pg COPY protocol
+type commitStatsSource struct {
+	repo    uuid.UUID
+	decoder *json.Decoder
+	current []interface{}
+	err     error
+}
+
+func (s *commitStatsSource) Next() bool {
+	var c commitStat
+	if err := s.decoder.Decode(&c); err != nil {
+		if err != io.EOF {
+			s.err = err
 		}
-		input := []interface{}{repoID, c.CommitHash.String, c.FilePath.String, c.Additions.Int64, c.Deletions.Int64, c.NewFileMode.String, c.OldFileMode.String}
-		inputs = append(inputs, input)
+		return false
 	}
 
-	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"git_commit_stats"}, []string{"repo_id", "commit_hash", "file_path", "additions", "deletions", "old_file_mode", "new_file_mode"}, pgx.CopyFromRows(inputs)); err != nil {
-		return err
+	s.current = []interface{}{s.repo, c.CommitHash.String, c.FilePath.String, c.OldFilePath.String, c.Additions.Int64, c.Deletions.Int64, c.OldFileMode.String, c.NewFileMode.String}
+	return true
+}
+
+func (s *commitStatsSource) Values() ([]interface{}, error) { return s.current, nil }
+
+func (s *commitStatsSource) Err() error { return s.err }
+
+// sendBatchCommitStats uses the pg COPY protocol to send the commit stats collected in jsonTmpPath
+func (w *worker) sendBatchCommitStats(ctx context.Context, tx pgx.Tx, j *db.DequeueSyncJobRow, jsonTmpPath string) (int, error) {
+	var (
+		f   *os.File
+		err error
+	)
+
+	if f, err = os.Open(jsonTmpPath); err != nil {
+		return 0, err
+	}
+
+	// making sure we remove file after operation
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	var repoID uuid.UUID
+	if repoID, err = uuid.FromString(j.RepoID.String()); err != nil {
+		return 0, err
 	}
-	return nil
+
+	var settings *copyBatchSettings
+	if settings, err = w.copyBatchSettingsFor(j); err != nil {
+		return 0, err
+	}
+
+	var src = &commitStatsSource{repo: repoID, decoder: json.NewDecoder(f)}
+
+	var inserted int64
+	if inserted, err = copyInBatches(ctx, tx, pgx.Identifier{staging("git_commit_stats")}, []string{"repo_id", "commit_hash", "file_path", "old_file_path", "additions", "deletions", "old_file_mode", "new_file_mode"}, src, settings, w.startProgress(ctx, j, "copying commit stats", 0)); err != nil {
+		return 0, err
+	}
+
+	return int(inserted), nil
 }
 
 type commitStat struct {
 	CommitHash  sql.NullString `db:"commit_hash"`
 	FilePath    sql.NullString `db:"file_path"`
+	OldFilePath sql.NullString `db:"old_file_path"`
 	Additions   sql.NullInt64  `db:"additions"`
 	Deletions   sql.NullInt64  `db:"deletions"`
 	OldFileMode sql.NullString `db:"old_file_mode"`
 	NewFileMode sql.NullString `db:"new_file_mode"`
 }
 
-func (w *worker) handleGitCommitStats(ctx context.Context, j *db.DequeueSyncJobRow) error {
+// collectCommitStats walks the history of the repository at repoPath and writes the per-file stats
+// of each commit to a json file (in tmpPath) as it goes, returning the path of that file
+func (w *worker) collectCommitStats(ctx context.Context, repoPath, tmpPath string) (string, error) {
 	var err error
-	l := w.loggerForJob(j)
-
-	// indicate that we're starting query execution
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
-		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
-	}}); err != nil {
-		return fmt.Errorf("send batch log messages: %w", err)
-	}
+	var repo *libgit2.Repository
 
-	tmpPath, cleanup, err := helper.CreateTempDir(os.Getenv("GIT_CLONE_PATH"), fmt.Sprintf("mergestat-repo-%s-*", j.RepoID.String()))
-	if err != nil {
-		return fmt.Errorf("temp dir: %w", err)
+	var f *os.File
+	if f, err = os.CreateTemp(tmpPath, "commit-stats-objects-*.json"); err != nil {
+		return "", err
 	}
-	defer func() {
-		if err = cleanup(); err != nil {
-			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
 
-		}
-	}()
+	defer f.Close()
 
-	if err = w.clone(ctx, tmpPath, j); err != nil {
-		return fmt.Errorf("git clone: %w", err)
-	}
+	encoder := json.NewEncoder(f)
 
-	var stats = make([]*commitStat, 0)
-	var repo *libgit2.Repository
-	if repo, err = libgit2.OpenRepository(tmpPath); err != nil {
-		return err
+	if repo, err = libgit2.OpenRepository(repoPath); err != nil {
+		return "", err
 	}
 
 	defer repo.Free()
 
 	walk, err := repo.Walk()
 	if err != nil {
-		return err
+		return "", err
 	}
 	defer walk.Free()
 
 	if err := walk.PushHead(); err != nil {
-		return err
+		return "", err
 	}
 
 	if err := walk.Iterate(func(c *libgit2.Commit) bool {
 		defer c.Free()
 
+		select {
+		case <-ctx.Done():
+			return false
+		default:
+		}
+
 		toTree, err := c.Tree()
 		if err != nil {
 			return false
@@ -153,16 +189,22 @@ func (w *worker) handleGitCommitStats(ctx context.Context, j *db.DequeueSyncJobR
 			return false
 		}
 
+		// renamed files are detected regardless of the diff.renames config of the repo, so that a rename shows up as a
+		// single (renamed) file, rather than as a deleted and an added one, and the history of files survives renames
+		diffFindOpts.Flags |= libgit2.DiffFindRenames
+
 		if err = diff.FindSimilar(&diffFindOpts); err != nil {
 			return false
 		}
 
+		// stats only need to be held in memory for the current commit
+		var stats []*commitStat
 		err = diff.ForEach(func(delta libgit2.DiffDelta, progress float64) (libgit2.DiffForEachHunkCallback, error) {
-			// TODO(patrickdevivo) should we also include the old file path? (delta.OldFile.Path)
-			// if so, we might want to change file_path column to new_file_path and add old_file_path
+			// the old path differs from the (new) file path for renamed files only
 			stat := &commitStat{
 				CommitHash:  sql.NullString{String: c.Id().String(), Valid: true},
 				FilePath:    sql.NullString{String: delta.NewFile.Path, Valid: true},
+				OldFilePath: sql.NullString{String: delta.OldFile.Path, Valid: true},
 				Additions:   sql.NullInt64{Int64: 0, Valid: true},
 				Deletions:   sql.NullInt64{Int64: 0, Valid: true},
 				OldFileMode: sql.NullString{String: string(gitFileModeObjectTypeFromUint16((delta.OldFile.Mode))), Valid: true},
@@ -188,13 +230,56 @@ func (w *worker) handleGitCommitStats(ctx context.Context, j *db.DequeueSyncJobR
 			return false
 		}
 
+		// encode the commit's stats to the json file before moving on to the next commit
+		for _, stat := range stats {
+			if err = encoder.Encode(stat); err != nil {
+				w.logger.Err(err).Msgf("%v", err)
+				return false
+			}
+		}
+
 		return true
 	}); err != nil {
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+func (w *worker) handleGitCommitStats(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err = cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+
+		}
+	}()
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	jsonTmpPath, err := w.collectCommitStats(ctx, repoPath, tmpPath)
+	if err != nil {
 		return err
 	}
 
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return err
 	}
 	defer func() {
@@ -205,31 +290,30 @@ func (w *worker) handleGitCommitStats(ctx context.Context, j *db.DequeueSyncJobR
 		}
 	}()
 
-	r, err := tx.Exec(ctx, "DELETE FROM git_commit_stats WHERE repo_id = $1;", j.RepoID.String())
-	if err != nil {
+	if err = stage(ctx, tx, "git_commit_stats"); err != nil {
 		return err
 	}
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("removed %d row(s) from git_commit_stats", r.RowsAffected()),
-	}}); err != nil {
+	var insertedStats int
+	if insertedStats, err = w.sendBatchCommitStats(ctx, tx, j, jsonTmpPath); err != nil {
 		return err
 	}
 
-	if err := w.sendBatchCommitStats(ctx, tx, j, stats); err != nil {
-		return nil
+	l.Info().Msgf("imported %d commit stats", insertedStats)
+
+	if err := w.mergeStaged(ctx, tx, j, "git_commit_stats", int64(insertedStats)); err != nil {
+		return err
 	}
 
-	l.Info().Msgf("imported %d commit stats", len(stats))
+	// the rollups are refreshed in the same transaction, so that they're never out of sync with the stats
+	if _, err := tx.Exec(ctx, `SELECT mergestat.refresh_churn_rollups($1)`, j.RepoID); err != nil {
+		return fmt.Errorf("refresh churn rollups: %w", err)
+	}
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("inserted %d row(s) into git_commit_stats", len(stats)),
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: "refreshed the per-file and per-author weekly churn rollups",
 	}}); err != nil {
-		return err
+		return fmt.Errorf("send batch log messages: %w", err)
 	}
 
 	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
@@ -12,12 +12,117 @@ import (
 	"github.com/jackc/pgx/v4"
 	libgit2 "github.com/libgit2/git2go/v33"
 	"github.com/mergestat/mergestat/internal/db"
-	"github.com/mergestat/mergestat/internal/helper"
 	uuid "github.com/satori/go.uuid"
 )
 
-// sendBatchCommits uses the pg COPY protocol to send a batch of commits
-func (w *worker) sendBatchCommits(ctx context.Context, tx pgx.Tx, j *db.DequeueSyncJobRow, jsonTmpPath string) (int, error) {
+// commitsSource streams commits from a json file (as written by collectCommits) straight into the pg COPY
+// protocol (see pgx.CopyFromSource), so that commits never have to be held in memory all at once
+type commitsSource struct {
+	repo    uuid.UUID
+	decoder *json.Decoder
+	current []interface{}
+	err     error
+}
+
+func (s *commitsSource) Next() bool {
+	var c commit
+	if err := s.decoder.Decode(&c); err != nil {
+		if err != io.EOF {
+			s.err = err
+		}
+		return false
+	}
+
+	s.current = []interface{}{s.repo, c.Hash.String, c.Message.String,
+		c.AuthorName.String, c.AuthorEmail.String, c.AuthorWhen.Time,
+		c.CommitterName.String, c.CommitterEmail.String, c.CommitterWhen.Time,
+		c.Parents.Int32,
+	}
+	return true
+}
+
+func (s *commitsSource) Values() ([]interface{}, error) { return s.current, nil }
+
+func (s *commitsSource) Err() error { return s.err }
+
+// commitParentsSource streams the parents of commits from a json file (as written by collectCommits) into the pg
+// COPY protocol, one row per parent
+type commitParentsSource struct {
+	repo    uuid.UUID
+	decoder *json.Decoder
+	pending []commitParent // parents of the current commit not streamed yet
+	current []interface{}
+	err     error
+}
+
+func (s *commitParentsSource) Next() bool {
+	for len(s.pending) == 0 {
+		if err := s.decoder.Decode(&s.pending); err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			return false
+		}
+	}
+
+	var p = s.pending[0]
+	s.pending = s.pending[1:]
+	s.current = []interface{}{s.repo, p.ChildHash, p.ParentHash, p.Ordinal}
+	return true
+}
+
+func (s *commitParentsSource) Values() ([]interface{}, error) { return s.current, nil }
+
+func (s *commitParentsSource) Err() error { return s.err }
+
+// commitParent is a parent of a commit, and its position among the parents of the commit
+type commitParent struct {
+	ChildHash  string
+	ParentHash string
+	Ordinal    int
+}
+
+// commitTrailersSource streams the trailers of commits from a json file (as written by collectCommits) into the pg
+// COPY protocol, one row per trailer
+type commitTrailersSource struct {
+	repo    uuid.UUID
+	decoder *json.Decoder
+	pending []commitTrailer // trailers of the current commit not streamed yet
+	current []interface{}
+	err     error
+}
+
+func (s *commitTrailersSource) Next() bool {
+	for len(s.pending) == 0 {
+		if err := s.decoder.Decode(&s.pending); err != nil {
+			if err != io.EOF {
+				s.err = err
+			}
+			return false
+		}
+	}
+
+	var t = s.pending[0]
+	s.pending = s.pending[1:]
+	s.current = []interface{}{s.repo, t.CommitHash, t.Ordinal, t.Key, t.Value, nullIfEmpty(t.Name), nullIfEmpty(t.Email), nullIfEmpty(t.Issue)}
+	return true
+}
+
+func (s *commitTrailersSource) Values() ([]interface{}, error) { return s.current, nil }
+
+func (s *commitTrailersSource) Err() error { return s.err }
+
+// nullIfEmpty returns s, or nil if s is empty
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// sendBatchCommits uses the pg COPY protocol to stream the (given total number of) commits of the given json file
+// into the given table
+func (w *worker) sendBatchCommits(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, jsonTmpPath string, total int64) (int, error) {
 	var (
 		f   *os.File
 		err error
@@ -29,61 +134,86 @@ func (w *worker) sendBatchCommits(ctx context.Context, tx pgx.Tx, j *db.DequeueS
 
 	// making sure we remove file after operation
 	defer os.Remove(f.Name())
+	defer f.Close()
+
+	var repoID uuid.UUID
+	if repoID, err = uuid.FromString(j.RepoID.String()); err != nil {
+		return 0, err
+	}
+
+	var settings *copyBatchSettings
+	if settings, err = w.copyBatchSettingsFor(j); err != nil {
+		return 0, err
+	}
+
+	var src = &commitsSource{repo: repoID, decoder: json.NewDecoder(f)}
+
+	var inserted int64
+	if inserted, err = copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "hash", "message", "author_name", "author_email", "author_when", "committer_name", "committer_email", "committer_when", "parents"}, src, settings, w.startProgress(ctx, j, "copying commits", total)); err != nil {
+		return 0, err
+	}
 
+	return int(inserted), nil
+}
+
+// sendBatchCommitParents uses the pg COPY protocol to stream the parents of the commits of the given json file into
+// the given table
+func (w *worker) sendBatchCommitParents(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, jsonTmpPath string) (int64, error) {
 	var (
-		inputs          = make([][]interface{}, 0, 100)
-		insertedCommits = 0
-		isEOF           = false
-		repoID          uuid.UUID
-		decoder         = json.NewDecoder(f)
+		f   *os.File
+		err error
 	)
 
+	if f, err = os.Open(jsonTmpPath); err != nil {
+		return 0, err
+	}
+
+	// making sure we remove file after operation
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	var repoID uuid.UUID
 	if repoID, err = uuid.FromString(j.RepoID.String()); err != nil {
 		return 0, err
 	}
-	for {
-		for {
 
-			var c commit
-			err = decoder.Decode(&c)
+	var settings *copyBatchSettings
+	if settings, err = w.copyBatchSettingsFor(j); err != nil {
+		return 0, err
+	}
 
-			// If we've reached the end of the file, break out of the loop
-			// and set isEOF to true
-			if err == io.EOF {
-				isEOF = true
-				break
-			}
+	var src = &commitParentsSource{repo: repoID, decoder: json.NewDecoder(f)}
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "child_hash", "parent_hash", "ordinal"}, src, settings, nil)
+}
 
-			if err != nil {
-				return insertedCommits, err
-			}
+// sendBatchCommitTrailers uses the pg COPY protocol to stream the trailers of the commits of the given json file into
+// the given table
+func (w *worker) sendBatchCommitTrailers(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, jsonTmpPath string) (int64, error) {
+	var (
+		f   *os.File
+		err error
+	)
 
-			input := []interface{}{repoID, c.Hash.String, c.Message.String,
-				c.AuthorName.String, c.AuthorEmail.String, c.AuthorWhen.Time,
-				c.CommitterName.String, c.CommitterEmail.String, c.CommitterWhen.Time,
-				c.Parents.Int32,
-			}
-			inputs = append(inputs, input)
+	if f, err = os.Open(jsonTmpPath); err != nil {
+		return 0, err
+	}
 
-			if len(inputs) == cap(inputs) {
-				break
-			}
-		}
-		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"git_commits"}, []string{"repo_id", "hash", "message", "author_name", "author_email", "author_when", "committer_name", "committer_email", "committer_when", "parents"}, pgx.CopyFromRows(inputs)); err != nil {
-			return 0, err
-		}
-		insertedCommits += len(inputs)
+	// making sure we remove file after operation
+	defer os.Remove(f.Name())
+	defer f.Close()
 
-		//cleaning slice and keeping capacity
-		inputs = inputs[:0]
+	var repoID uuid.UUID
+	if repoID, err = uuid.FromString(j.RepoID.String()); err != nil {
+		return 0, err
+	}
 
-		// if we reach EOF we exit
-		if isEOF {
-			break
-		}
+	var settings *copyBatchSettings
+	if settings, err = w.copyBatchSettingsFor(j); err != nil {
+		return 0, err
 	}
 
-	return insertedCommits, nil
+	var src = &commitTrailersSource{repo: repoID, decoder: json.NewDecoder(f)}
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "commit_hash", "ordinal", "key", "value", "name", "email", "issue"}, src, settings, nil)
 }
 
 type commit struct {
@@ -98,34 +228,94 @@ type commit struct {
 	Parents        sql.NullInt32  `db:"parents"`
 }
 
-// collectCommits retrieves all the commits for a given repository and returns them as a slice
-func (w *worker) collectCommits(ctx context.Context, tmpPath string) (string, error) {
+// gitCommitsSettings are the settings accepted by a GIT_COMMITS repo sync
+type gitCommitsSettings struct {
+	// FullResync ignores the watermark of the previous run and re-walks the entire history of the repo
+	FullResync bool `json:"fullResync"`
+}
+
+// collectedCommits describes the outcome of a commit walk
+type collectedCommits struct {
+	path         string // path of the json file the commits were written to
+	parentsPath  string // path of the json file the parents of the commits were written to
+	trailersPath string // path of the json file the trailers of the commits were written to
+	head         string // hash of the commit the walk started from
+	incremental  bool   // whether the walk skipped the commits that were already synced
+	count        int64  // number of commits written to the json file
+}
+
+// collectCommits retrieves the commits for a given repository and writes them to a json file. If since is set and
+// HEAD descends from it, the commits reachable from since are skipped. Otherwise (e.g. the history was rewritten
+// since the previous sync) all the commits are collected.
+func (w *worker) collectCommits(ctx context.Context, repoPath, tmpPath, since string) (*collectedCommits, error) {
 	var err error
 	var repo *libgit2.Repository
 
 	var f *os.File
 	if f, err = os.CreateTemp(tmpPath, "commits-objects-*.json"); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	defer f.Close()
 
 	encoder := json.NewEncoder(f)
 
-	if repo, err = libgit2.OpenRepository(tmpPath); err != nil {
-		return "", err
+	// the parents and trailers of each commit are written to files of their own, as they're copied into tables of their own
+	var pf, tf *os.File
+	if pf, err = os.CreateTemp(tmpPath, "commit-parents-*.json"); err != nil {
+		return nil, err
+	}
+
+	defer pf.Close()
+
+	if tf, err = os.CreateTemp(tmpPath, "commit-trailers-*.json"); err != nil {
+		return nil, err
+	}
+
+	defer tf.Close()
+
+	parentsEncoder, trailersEncoder := json.NewEncoder(pf), json.NewEncoder(tf)
+
+	if repo, err = libgit2.OpenRepository(repoPath); err != nil {
+		return nil, err
 	}
 
 	defer repo.Free()
 
+	var head *libgit2.Reference
+	if head, err = repo.Head(); err != nil {
+		return nil, err
+	}
+	defer head.Free()
+
+	var result = &collectedCommits{path: f.Name(), parentsPath: pf.Name(), trailersPath: tf.Name(), head: head.Target().String()}
+
 	walk, err := repo.Walk()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer walk.Free()
 
 	if err := walk.PushHead(); err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if since != "" {
+		var oid *libgit2.Oid
+		if oid, err = libgit2.NewOid(since); err != nil {
+			return nil, err
+		}
+
+		if result.incremental = since == result.head; !result.incremental {
+			// an error here means the watermark commit doesn't exist (anymore) in the repo
+			result.incremental, _ = repo.DescendantOf(head.Target(), oid)
+		}
+
+		if result.incremental {
+			if err = walk.Hide(oid); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	if err := walk.Iterate(func(c *libgit2.Commit) bool {
@@ -154,13 +344,28 @@ func (w *worker) collectCommits(ctx context.Context, tmpPath string) (string, er
 			w.logger.Err(err).Msgf("%v", err)
 			return false
 		}
+		result.count++
+
+		var parents = make([]commitParent, c.ParentCount())
+		for i := range parents {
+			parents[i] = commitParent{ChildHash: c.Id().String(), ParentHash: c.ParentId(uint(i)).String(), Ordinal: i}
+		}
+		if err = parentsEncoder.Encode(parents); err != nil {
+			w.logger.Err(err).Msgf("%v", err)
+			return false
+		}
+
+		if err = trailersEncoder.Encode(parseTrailers(c.Id().String(), c.Message())); err != nil {
+			w.logger.Err(err).Msgf("%v", err)
+			return false
+		}
 
 		return true
 	}); err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return f.Name(), nil
+	return result, nil
 }
 
 func (w *worker) handleGitCommits(ctx context.Context, j *db.DequeueSyncJobRow) error {
@@ -174,7 +379,7 @@ func (w *worker) handleGitCommits(ctx context.Context, j *db.DequeueSyncJobRow)
 		return fmt.Errorf("send batch log messages: %w", err)
 	}
 
-	tmpPath, cleanup, err := helper.CreateTempDir(os.Getenv("GIT_CLONE_PATH"), fmt.Sprintf("mergestat-repo-%s-*", j.RepoID.String()))
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
 	if err != nil {
 		return fmt.Errorf("temp dir: %w", err)
 	}
@@ -184,17 +389,31 @@ func (w *worker) handleGitCommits(ctx context.Context, j *db.DequeueSyncJobRow)
 		}
 	}()
 
-	if err = w.clone(ctx, tmpPath, j); err != nil {
+	var settings gitCommitsSettings
+	if err = decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	// resume from the newest commit synced by the previous run, unless a full resync is requested
+	var since string
+	if !settings.FullResync {
+		if since, err = w.db.GetRepoSyncWatermark(ctx, j.RepoSyncID); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("fetch watermark: %w", err)
+		}
+	}
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
 		return fmt.Errorf("git clone: %w", err)
 	}
 
-	jsonTmpPath, err := w.collectCommits(ctx, tmpPath)
+	commits, err := w.collectCommits(ctx, repoPath, tmpPath, since)
 	if err != nil {
 		return err
 	}
 
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return err
 	}
 	defer func() {
@@ -205,30 +424,71 @@ func (w *worker) handleGitCommits(ctx context.Context, j *db.DequeueSyncJobRow)
 		}
 	}()
 
-	r, err := tx.Exec(ctx, "DELETE FROM git_commits WHERE repo_id = $1;", j.RepoID.String())
-	if err != nil {
+	// on an incremental sync, the previously synced commits are kept and only newer ones are inserted. On a full sync,
+	// all commits are staged and merged, deleting the commits that aren't reachable anymore (e.g. after a force push).
+	var table = "git_commits"
+	if commits.incremental {
+		if err := w.sendBatchLogMessages(ctx, []*syncLog{{
+			Type:            SyncLogTypeInfo,
+			RepoSyncQueueID: j.ID,
+			Message:         fmt.Sprintf("resuming from previously synced commit %s", since),
+		}}); err != nil {
+			return err
+		}
+	} else {
+		for _, t := range []string{"git_commits", "git_commit_parents", "git_commit_trailers"} {
+			if err = stage(ctx, tx, t); err != nil {
+				return err
+			}
+		}
+		table = staging("git_commits")
+	}
+
+	var insertedCommits int
+	if insertedCommits, err = w.sendBatchCommits(ctx, tx, table, j, commits.path, commits.count); err != nil {
 		return err
 	}
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("removed %d row(s) from git_commits", r.RowsAffected()),
-	}}); err != nil {
+	var parentsTable, trailersTable = "git_commit_parents", "git_commit_trailers"
+	if !commits.incremental {
+		parentsTable, trailersTable = staging(parentsTable), staging(trailersTable)
+	}
+
+	var insertedParents, insertedTrailers int64
+	if insertedParents, err = w.sendBatchCommitParents(ctx, tx, parentsTable, j, commits.parentsPath); err != nil {
 		return err
 	}
-	var insertedCommits int
-	if insertedCommits, err = w.sendBatchCommits(ctx, tx, j, jsonTmpPath); err != nil {
+
+	if insertedTrailers, err = w.sendBatchCommitTrailers(ctx, tx, trailersTable, j, commits.trailersPath); err != nil {
 		return err
 	}
 
+	if err := w.db.WithTx(tx).UpsertRepoSyncWatermark(ctx, db.UpsertRepoSyncWatermarkParams{RepoSyncID: j.RepoSyncID, Watermark: commits.head}); err != nil {
+		return fmt.Errorf("update watermark: %w", err)
+	}
+
 	l.Info().Msgf("sent batch of %d commits", insertedCommits)
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("inserted %d row(s) into git_commits", insertedCommits),
-	}}); err != nil {
+	if commits.incremental {
+		err = w.sendBatchLogMessages(ctx, []*syncLog{{
+			Type:            SyncLogTypeInfo,
+			RepoSyncQueueID: j.ID,
+			Message:         fmt.Sprintf("inserted %d row(s) into git_commits", insertedCommits),
+		}, {
+			Type:            SyncLogTypeInfo,
+			RepoSyncQueueID: j.ID,
+			Message:         fmt.Sprintf("inserted %d row(s) into git_commit_parents", insertedParents),
+		}, {
+			Type:            SyncLogTypeInfo,
+			RepoSyncQueueID: j.ID,
+			Message:         fmt.Sprintf("inserted %d row(s) into git_commit_trailers", insertedTrailers),
+		}})
+	} else if err = w.mergeStaged(ctx, tx, j, "git_commits", int64(insertedCommits)); err == nil {
+		if err = w.mergeStaged(ctx, tx, j, "git_commit_parents", insertedParents); err == nil {
+			err = w.mergeStaged(ctx, tx, j, "git_commit_trailers", insertedTrailers)
+		}
+	}
+	if err != nil {
 		return err
 	}
 
@@ -0,0 +1,159 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// collectDependencies parses the dependencies of the manifests and lockfiles in the HEAD of the repository at
+// repoPath. Manifests that can't be parsed are skipped, and returned with the error parsing them.
+func collectDependencies(ctx context.Context, repoPath string) (deps []dependency, invalid map[string]error, err error) {
+	var repo *git.Repository
+	if repo, err = git.PlainOpen(repoPath); err != nil {
+		return nil, nil, fmt.Errorf("could not open repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not resolve HEAD: %w", err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	invalid = make(map[string]error)
+	if err = tree.Files().ForEach(func(f *object.File) error {
+		if manifestParserFor(f.Name) == nil {
+			return nil
+		}
+
+		contents, err := f.Contents()
+		if err != nil {
+			return fmt.Errorf("read %s: %w", f.Name, err)
+		}
+
+		parsed, err := parseManifest(f.Name, []byte(contents))
+		if err != nil {
+			invalid[f.Name] = err
+			return nil
+		}
+		deps = append(deps, parsed...)
+
+		return ctx.Err()
+	}); err != nil {
+		return nil, nil, err
+	}
+
+	return deps, invalid, nil
+}
+
+// sendBatchDependencies uses the pg COPY protocol to send the dependencies into the given table
+func (w *worker) sendBatchDependencies(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, deps []dependency) (int64, error) {
+	inputs := make([][]interface{}, 0, len(deps))
+	for _, d := range deps {
+		inputs = append(inputs, []interface{}{j.RepoID, d.ManifestPath, d.Ecosystem, d.Name, d.Version, d.Direct, d.Scope})
+	}
+
+	settings, err := w.copyBatchSettingsFor(j)
+	if err != nil {
+		return 0, err
+	}
+
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "manifest_path", "ecosystem", "name", "version", "direct", "scope"}, pgx.CopyFromRows(inputs), settings, nil)
+}
+
+func (w *worker) handleGitDependencies(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+		}
+	}()
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	deps, invalid, err := collectDependencies(ctx, repoPath)
+	if err != nil {
+		return err
+	}
+
+	// a manifest that can't be parsed shouldn't fail the sync of the other ones
+	for manifestPath, err := range invalid {
+		if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeWarn, RepoSyncQueueID: j.ID,
+			Message: fmt.Sprintf(LogFormatErrorWarningMessage, "skipping invalid manifest "+manifestPath, err),
+		}}); err != nil {
+			return fmt.Errorf("send batch log messages: %w", err)
+		}
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("could not rollback transaction")
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "repo_dependencies"); err != nil {
+		return err
+	}
+
+	var inserted int64
+	if inserted, err = w.sendBatchDependencies(ctx, tx, staging("repo_dependencies"), j, deps); err != nil {
+		return fmt.Errorf("send batch dependencies: %w", err)
+	}
+
+	l.Info().Msgf("sent batch of %d dependencies", inserted)
+
+	if err := w.mergeStaged(ctx, tx, j, "repo_dependencies", inserted); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("update status done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	return err
+}
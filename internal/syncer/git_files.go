@@ -5,17 +5,63 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"os"
 	"strings"
 	"unicode/utf8"
 
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
 	"github.com/jackc/pgx/v4"
 	"github.com/mergestat/mergestat/internal/db"
-	"github.com/mergestat/mergestat/internal/helper"
 	uuid "github.com/satori/go.uuid"
 )
 
-func (w *worker) sendBatchFiles(ctx context.Context, tx pgx.Tx, j *db.DequeueSyncJobRow, batch []*file) error {
+// gitFilesSettings are the settings accepted by a GIT_FILES repo sync
+type gitFilesSettings struct {
+	// MaxContentSize caps the number of bytes of contents stored for each file.
+	// Files larger than this only have an excerpt of their contents stored. 0 means no limit.
+	MaxContentSize int `json:"maxContentSize"`
+}
+
+// truncateContents returns at most max bytes of contents, cut at a rune boundary
+// so that the excerpt remains valid utf-8. A max of 0 or less returns contents as is.
+func truncateContents(contents string, max int) string {
+	if max <= 0 || len(contents) <= max {
+		return contents
+	}
+
+	for max > 0 && !utf8.RuneStart(contents[max]) {
+		max--
+	}
+
+	return contents[:max]
+}
+
+// headGitAttributes reads the attributes of the .gitattributes files of the HEAD of the repository at repoPath
+func headGitAttributes(repoPath string) ([]gitattributes.MatchAttribute, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve HEAD: %w", err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	return readGitAttributes(tree)
+}
+
+func (w *worker) sendBatchFiles(ctx context.Context, tx pgx.Tx, j *db.DequeueSyncJobRow, batch []*file, maxContentSize int, stack []gitattributes.MatchAttribute) error {
 	inputs := make([][]interface{}, 0, len(batch))
 	for _, c := range batch {
 		var repoID uuid.UUID
@@ -26,15 +72,22 @@ func (w *worker) sendBatchFiles(ctx context.Context, tx pgx.Tx, j *db.DequeueSyn
 
 		var contents interface{}
 		if utf8.ValidString(c.Contents.String) {
-			contents = strings.ReplaceAll(c.Contents.String, "\u0000", "")
+			contents = strings.ReplaceAll(truncateContents(c.Contents.String, maxContentSize), "\u0000", "")
 		} else {
 			contents = nil
 		}
-		input := []interface{}{repoID, c.Path.String, c.Executable.Bool, contents}
+		// files are flagged from the beginning of their contents, before it's truncated
+		var sample = c.Contents.String
+		if len(sample) > languageSampleSize {
+			sample = sample[:languageSampleSize]
+		}
+		var flags = classifyFile(c.Path.String, []byte(sample), matchAttributes(stack, c.Path.String))
+
+		input := []interface{}{repoID, c.Path.String, c.Executable.Bool, contents, flags.Binary, flags.Generated, flags.Vendored}
 		inputs = append(inputs, input)
 	}
 
-	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"git_files"}, []string{"repo_id", "path", "executable", "contents"}, pgx.CopyFromRows(inputs)); err != nil {
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("git_files")}, []string{"repo_id", "path", "executable", "contents", "binary", "generated", "vendored"}, pgx.CopyFromRows(inputs)); err != nil {
 		return fmt.Errorf("tx copy from: %w", err)
 	}
 	return nil
@@ -63,7 +116,12 @@ func (w *worker) handleGitFiles(ctx context.Context, j *db.DequeueSyncJobRow) er
 		return fmt.Errorf("send batch log messages: %w", err)
 	}
 
-	tmpPath, cleanup, err := helper.CreateTempDir(os.Getenv("GIT_CLONE_PATH"), fmt.Sprintf("mergestat-repo-%s-*", j.RepoID.String()))
+	var settings gitFilesSettings
+	if err = decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
 	if err != nil {
 		return fmt.Errorf("temp dir: %w", err)
 	}
@@ -73,17 +131,23 @@ func (w *worker) handleGitFiles(ctx context.Context, j *db.DequeueSyncJobRow) er
 		}
 	}()
 
-	if err = w.clone(ctx, tmpPath, j); err != nil {
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
 		return fmt.Errorf("git clone: %w", err)
 	}
 
 	files := make([]*file, 0)
-	if err = w.mergestat.SelectContext(ctx, &files, selectFiles, tmpPath, tmpPath); err != nil {
+	if err = w.selectMergestat(ctx, &files, selectFiles, repoPath); err != nil {
 		return fmt.Errorf("mergestat query files: %w", err)
 	}
 
+	stack, err := headGitAttributes(repoPath)
+	if err != nil {
+		return fmt.Errorf("read gitattributes: %w", err)
+	}
+
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer func() {
@@ -94,30 +158,17 @@ func (w *worker) handleGitFiles(ctx context.Context, j *db.DequeueSyncJobRow) er
 		}
 	}()
 
-	r, err := tx.Exec(ctx, "DELETE FROM git_files WHERE repo_id = $1;", j.RepoID.String())
-	if err != nil {
-		return fmt.Errorf("exec delete: %w", err)
-	}
-
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("removed %d row(s) from git_files", r.RowsAffected()),
-	}}); err != nil {
+	if err = stage(ctx, tx, "git_files"); err != nil {
 		return err
 	}
 
-	if err := w.sendBatchFiles(ctx, tx, j, files); err != nil {
+	if err := w.sendBatchFiles(ctx, tx, j, files, settings.MaxContentSize, stack); err != nil {
 		return fmt.Errorf("send batch files: %w", err)
 	}
 
 	l.Info().Msgf("sent batch of %d files", len(files))
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("inserted %d row(s) into git_files", len(files)),
-	}}); err != nil {
+	if err := w.mergeStaged(ctx, tx, j, "git_files", int64(len(files))); err != nil {
 		return err
 	}
 
@@ -0,0 +1,317 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/go-enry/go-enry/v2"
+	"github.com/go-enry/go-enry/v2/data"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// languageSampleSize is the number of bytes of a file its language is detected from, as the heuristics (and the
+// classifier) of enry only need the beginning of a file
+const languageSampleSize = 64 << 10
+
+// fileLanguage is the language of a file, and how it counts towards the language breakdown of its repo
+type fileLanguage struct {
+	Path          string
+	Language      string // empty if no language was detected (e.g. of a binary file)
+	Type          string // programming, markup, data, prose or unknown
+	Bytes         int64
+	Vendored      bool
+	Generated     bool
+	Documentation bool
+	Detectable    bool // whether the file counts towards the language breakdown of its repo
+}
+
+// readGitAttributes reads the attributes of all the .gitattributes files of the given tree, in order of increasing
+// priority (i.e. the ones of the root first, then of subdirectories). Of those, the linguist-* overrides are honored,
+// see https://github.com/github-linguist/linguist/blob/master/docs/overrides.md
+func readGitAttributes(tree *object.Tree) ([]gitattributes.MatchAttribute, error) {
+	var files []*object.File
+	if err := tree.Files().ForEach(func(f *object.File) error {
+		if path.Base(f.Name) == ".gitattributes" {
+			files = append(files, f)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return strings.Count(files[i].Name, "/") < strings.Count(files[j].Name, "/")
+	})
+
+	var stack []gitattributes.MatchAttribute
+	for _, f := range files {
+		contents, err := f.Contents()
+		if err != nil {
+			return nil, err
+		}
+
+		var domain []string
+		if dir := path.Dir(f.Name); dir != "." {
+			domain = strings.Split(dir, "/")
+		}
+
+		attributes, err := gitattributes.ReadAttributes(strings.NewReader(contents), domain, len(domain) == 0)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", f.Name, err)
+		}
+		stack = append(stack, attributes...)
+	}
+	return stack, nil
+}
+
+// matchAttributes returns the attributes of the file at the given path, the attributes of the patterns of higher
+// priority overriding the ones of lower priority
+func matchAttributes(stack []gitattributes.MatchAttribute, filePath string) map[string]gitattributes.Attribute {
+	var attributes = make(map[string]gitattributes.Attribute)
+	var parts = strings.Split(filePath, "/")
+	for _, m := range stack {
+		if m.Pattern == nil || !m.Pattern.Match(parts) {
+			continue
+		}
+		for _, a := range m.Attributes {
+			attributes[a.Name()] = a
+		}
+	}
+	return attributes
+}
+
+// overridden returns whether the given boolean attribute is set (or unset), and its value if so
+func overridden(attributes map[string]gitattributes.Attribute, name string) (value, ok bool) {
+	var a, found = attributes[name]
+	switch {
+	case !found || a.IsUnspecified():
+		return false, false
+	case a.IsValueSet():
+		return a.Value() != "false", true
+	default:
+		return a.IsSet(), true
+	}
+}
+
+// fileFlags are the flags of a file that analytics usually exclude files by
+type fileFlags struct {
+	Binary    bool
+	Generated bool
+	Vendored  bool
+}
+
+// classifyFile flags the file at the given path, of the given sample (of the beginning of its contents), honoring the
+// binary, text and linguist-* attributes of .gitattributes
+func classifyFile(filePath string, sample []byte, attributes map[string]gitattributes.Attribute) fileFlags {
+	var flags fileFlags
+
+	// the binary macro attribute implies -text, and text forces a file to be text (or binary, if unset)
+	var binary, isBinarySet = overridden(attributes, "binary")
+	var text, isTextSet = overridden(attributes, "text")
+	switch {
+	case isBinarySet && binary:
+		flags.Binary = true
+	case isTextSet:
+		flags.Binary = !text
+	default:
+		flags.Binary = enry.IsBinary(sample)
+	}
+
+	var ok bool
+	if flags.Vendored, ok = overridden(attributes, "linguist-vendored"); !ok {
+		flags.Vendored = enry.IsVendor(filePath)
+	}
+	if flags.Generated, ok = overridden(attributes, "linguist-generated"); !ok {
+		flags.Generated = enry.IsGenerated(filePath, sample)
+	}
+	return flags
+}
+
+// detectLanguage detects the language of the given file, honoring the linguist overrides of .gitattributes
+func detectLanguage(f *object.File, stack []gitattributes.MatchAttribute) (*fileLanguage, error) {
+	r, err := f.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	sample, err := io.ReadAll(io.LimitReader(r, languageSampleSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var l = &fileLanguage{Path: f.Name, Bytes: f.Size}
+	var attributes = matchAttributes(stack, f.Name)
+
+	if a, ok := attributes["linguist-language"]; ok && a.IsValueSet() {
+		// the value of the override can be an alias of the language (e.g. linguist-language=js)
+		if l.Language, ok = enry.GetLanguageByAlias(a.Value()); !ok {
+			l.Language = a.Value()
+		}
+	} else if !enry.IsBinary(sample) {
+		l.Language = enry.GetLanguage(path.Base(f.Name), sample)
+	}
+	l.Type = data.Type(enry.GetLanguageType(l.Language)).String()
+
+	var flags = classifyFile(f.Name, sample, attributes)
+	l.Vendored, l.Generated = flags.Vendored, flags.Generated
+
+	var ok bool
+	if l.Documentation, ok = overridden(attributes, "linguist-documentation"); !ok {
+		l.Documentation = enry.IsDocumentation(f.Name)
+	}
+
+	// like linguist, only programming and markup languages count by default, unless the file is detectable explicitly
+	if l.Detectable, ok = overridden(attributes, "linguist-detectable"); !ok {
+		l.Detectable = l.Type == "programming" || l.Type == "markup"
+	}
+	l.Detectable = l.Detectable && l.Language != "" && !l.Vendored && !l.Generated && !l.Documentation
+
+	return l, nil
+}
+
+// collectFileLanguages detects the language of each file in the HEAD of the repository at repoPath
+func collectFileLanguages(ctx context.Context, repoPath string) ([]*fileLanguage, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve HEAD: %w", err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	stack, err := readGitAttributes(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var languages []*fileLanguage
+	if err = tree.Files().ForEach(func(f *object.File) error {
+		// submodules and symlinks have no contents of their own
+		if f.Mode == filemode.Submodule || f.Mode == filemode.Symlink {
+			return nil
+		}
+
+		l, err := detectLanguage(f, stack)
+		if err != nil {
+			return fmt.Errorf("detect language of %s: %w", f.Name, err)
+		}
+		languages = append(languages, l)
+		return ctx.Err()
+	}); err != nil {
+		return nil, err
+	}
+
+	return languages, nil
+}
+
+// sendBatchFileLanguages uses the pg COPY protocol to send the languages of files into the given table
+func (w *worker) sendBatchFileLanguages(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, languages []*fileLanguage) (int64, error) {
+	inputs := make([][]interface{}, 0, len(languages))
+	for _, l := range languages {
+		inputs = append(inputs, []interface{}{j.RepoID, l.Path, nullIfEmpty(l.Language), l.Type, l.Bytes, l.Vendored, l.Generated, l.Documentation, l.Detectable})
+	}
+
+	settings, err := w.copyBatchSettingsFor(j)
+	if err != nil {
+		return 0, err
+	}
+
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "path", "language", "language_type", "bytes", "vendored", "generated", "documentation", "detectable"}, pgx.CopyFromRows(inputs), settings, nil)
+}
+
+func (w *worker) handleGitFilesLanguages(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+		}
+	}()
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	var languages []*fileLanguage
+	if languages, err = collectFileLanguages(ctx, repoPath); err != nil {
+		return err
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("could not rollback transaction")
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "git_files_languages"); err != nil {
+		return err
+	}
+
+	var inserted int64
+	if inserted, err = w.sendBatchFileLanguages(ctx, tx, staging("git_files_languages"), j, languages); err != nil {
+		return fmt.Errorf("send batch file languages: %w", err)
+	}
+
+	l.Info().Msgf("sent batch of %d file languages", inserted)
+
+	if err := w.mergeStaged(ctx, tx, j, "git_files_languages", inserted); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("update status done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	return err
+}
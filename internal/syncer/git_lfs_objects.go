@@ -0,0 +1,236 @@
+package syncer
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// lfsPointerMaxSize is the size of the largest LFS pointer file, see
+// https://github.com/git-lfs/git-lfs/blob/main/docs/spec.md#the-pointer
+const lfsPointerMaxSize = 1024
+
+// lfsPointerVersions are the versions of the LFS pointer files, as the first line of a pointer file
+var lfsPointerVersions = []string{"version https://git-lfs.github.com/spec/v1", "version https://hawser.github.com/spec/v1"}
+
+// lfsObject is an LFS object of a repo, as referenced by the pointer file at its path
+type lfsObject struct {
+	Path string
+	OID  string // e.g. sha256:4d7a...
+	Size int64  // of the object, not the pointer file
+}
+
+// parseLFSPointer parses the LFS pointer file of the given contents, returning false if it's not one
+func parseLFSPointer(contents string) (oid string, size int64, ok bool) {
+	var scanner = bufio.NewScanner(strings.NewReader(contents))
+	if !scanner.Scan() {
+		return "", 0, false
+	}
+
+	var version = strings.TrimSpace(scanner.Text())
+	var known bool
+	for _, v := range lfsPointerVersions {
+		if known = version == v; known {
+			break
+		}
+	}
+	if !known {
+		return "", 0, false
+	}
+
+	var hasSize bool
+	for scanner.Scan() {
+		var key, value, _ = strings.Cut(scanner.Text(), " ")
+		switch key {
+		case "oid":
+			oid = value
+		case "size":
+			var err error
+			if size, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return "", 0, false
+			}
+			hasSize = true
+		}
+	}
+	if oid == "" || !hasSize {
+		return "", 0, false
+	}
+	return oid, size, true
+}
+
+// collectLFSObjects returns the LFS objects referenced by the pointer files in the HEAD of the repository at repoPath.
+// Only the (small) pointer files are read, the objects themselves are never downloaded.
+func collectLFSObjects(ctx context.Context, repoPath string) ([]lfsObject, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve HEAD: %w", err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []lfsObject
+	var walker = object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if !entry.Mode.IsFile() || entry.Mode == filemode.Symlink {
+			continue
+		}
+
+		// blobs larger than a pointer file aren't downloaded by the partial clone of the sync (see cloneFilterSyncTypes)
+		blob, err := repo.BlobObject(entry.Hash)
+		if errors.Is(err, plumbing.ErrObjectNotFound) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+		if blob.Size > lfsPointerMaxSize {
+			continue
+		}
+
+		var f = object.NewFile(name, entry.Mode, blob)
+		contents, err := f.Contents()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", name, err)
+		}
+
+		if oid, size, ok := parseLFSPointer(contents); ok {
+			objects = append(objects, lfsObject{Path: name, OID: oid, Size: size})
+		}
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return objects, nil
+}
+
+// sendBatchLFSObjects uses the pg COPY protocol to send the LFS objects into the given table
+func (w *worker) sendBatchLFSObjects(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, objects []lfsObject) (int64, error) {
+	inputs := make([][]interface{}, 0, len(objects))
+	for _, o := range objects {
+		inputs = append(inputs, []interface{}{j.RepoID, o.Path, o.OID, o.Size})
+	}
+
+	settings, err := w.copyBatchSettingsFor(j)
+	if err != nil {
+		return 0, err
+	}
+
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "path", "oid", "size"}, pgx.CopyFromRows(inputs), settings, nil)
+}
+
+func (w *worker) handleGitLFSObjects(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+		}
+	}()
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	var objects []lfsObject
+	if objects, err = collectLFSObjects(ctx, repoPath); err != nil {
+		return err
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("could not rollback transaction")
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "git_lfs_objects"); err != nil {
+		return err
+	}
+
+	var inserted int64
+	if inserted, err = w.sendBatchLFSObjects(ctx, tx, staging("git_lfs_objects"), j, objects); err != nil {
+		return fmt.Errorf("send batch lfs objects: %w", err)
+	}
+
+	l.Info().Msgf("sent batch of %d lfs objects", inserted)
+
+	if err := w.mergeStaged(ctx, tx, j, "git_lfs_objects", inserted); err != nil {
+		return err
+	}
+
+	// the usage of each sync is kept, so that the growth of the LFS storage of a repo can be tracked over time
+	const snapshot = `
+		INSERT INTO public.git_lfs_usage (repo_id, objects, size)
+		SELECT $1, COUNT(*), COALESCE(SUM(size), 0) FROM (
+			SELECT DISTINCT oid, size FROM public.git_lfs_objects WHERE repo_id = $1 AND _deleted_at IS NULL
+		) o`
+	if _, err := tx.Exec(ctx, snapshot, j.RepoID); err != nil {
+		return fmt.Errorf("snapshot lfs usage: %w", err)
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("update status done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	return err
+}
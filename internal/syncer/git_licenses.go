@@ -0,0 +1,180 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+const (
+	// licenseFileMaxSize is the number of bytes of a license file its license is detected from
+	licenseFileMaxSize = 256 << 10
+
+	// licenseHeaderSize is the number of bytes at the beginning of a file an SPDX-License-Identifier tag is looked for
+	licenseHeaderSize = 4 << 10
+)
+
+// readHead returns (at most) the first n bytes of the given file
+func readHead(f *object.File, n int64) (string, error) {
+	r, err := f.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	b, err := io.ReadAll(io.LimitReader(r, n))
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// collectLicenses detects the licenses of the files in the HEAD of the repository at repoPath: license files are
+// classified by their text, and any other file by its SPDX-License-Identifier tag
+func collectLicenses(ctx context.Context, repoPath string) ([]detectedLicense, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve HEAD: %w", err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var licenses []detectedLicense
+	if err = tree.Files().ForEach(func(f *object.File) error {
+		// submodules and symlinks have no contents of their own
+		if f.Mode == filemode.Submodule || f.Mode == filemode.Symlink {
+			return nil
+		}
+
+		var licenseFile = isLicenseFile(f.Name)
+		var size int64 = licenseHeaderSize
+		if licenseFile {
+			size = licenseFileMaxSize
+		}
+
+		contents, err := readHead(f, size)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", f.Name, err)
+		}
+
+		if licenseFile {
+			licenses = append(licenses, classifyLicenseText(f.Name, contents))
+		}
+		licenses = append(licenses, parseSPDXTag(f.Name, contents)...)
+
+		return ctx.Err()
+	}); err != nil {
+		return nil, err
+	}
+
+	return licenses, nil
+}
+
+// sendBatchLicenses uses the pg COPY protocol to send the detected licenses into the given table
+func (w *worker) sendBatchLicenses(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, licenses []detectedLicense) (int64, error) {
+	inputs := make([][]interface{}, 0, len(licenses))
+	for _, l := range licenses {
+		inputs = append(inputs, []interface{}{j.RepoID, l.Path, l.SPDXID, l.Source, l.Confidence, nullIfEmpty(l.Expression)})
+	}
+
+	settings, err := w.copyBatchSettingsFor(j)
+	if err != nil {
+		return 0, err
+	}
+
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "path", "spdx_id", "source", "confidence", "expression"}, pgx.CopyFromRows(inputs), settings, nil)
+}
+
+func (w *worker) handleGitLicenses(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+		}
+	}()
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	var licenses []detectedLicense
+	if licenses, err = collectLicenses(ctx, repoPath); err != nil {
+		return err
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("could not rollback transaction")
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "git_licenses"); err != nil {
+		return err
+	}
+
+	var inserted int64
+	if inserted, err = w.sendBatchLicenses(ctx, tx, staging("git_licenses"), j, licenses); err != nil {
+		return fmt.Errorf("send batch licenses: %w", err)
+	}
+
+	l.Info().Msgf("sent batch of %d licenses", inserted)
+
+	if err := w.mergeStaged(ctx, tx, j, "git_licenses", inserted); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("update status done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	return err
+}
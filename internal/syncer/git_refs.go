@@ -5,64 +5,123 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"os"
 
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/jackc/pgx/v4"
+	"github.com/jmoiron/sqlx"
+	libgit2 "github.com/libgit2/git2go/v33"
 	"github.com/mergestat/mergestat/internal/db"
-	"github.com/mergestat/mergestat/internal/helper"
 	uuid "github.com/satori/go.uuid"
 )
 
-// sendBatchGitRefs uses the pg COPY protocol to send a batch of git refs
-func (w *worker) sendBatchGitRefs(ctx context.Context, tx pgx.Tx, j *db.DequeueSyncJobRow, batch []*ref) error {
-	inputs := make([][]interface{}, 0, len(batch))
-	for _, r := range batch {
-		var repoID uuid.UUID
-		var err error
-		if repoID, err = uuid.FromString(j.RepoID.String()); err != nil {
-			return err
-		}
-		input := []interface{}{repoID, r.FullName.String, r.Name.String}
+// gitRefsSource streams git refs from a query cursor straight into the pg COPY protocol (see pgx.CopyFromSource),
+// so that refs never have to be held in memory all at once
+type gitRefsSource struct {
+	repo    uuid.UUID
+	rows    *sqlx.Rows
+	tags    *git.Repository // to look the details of annotated tags up, which mergestat-lite doesn't expose
+	graph   *libgit2.Repository
+	head    *libgit2.Oid // the commit of the default branch, branches are compared to (nil if unborn)
+	current []interface{}
+	err     error
+}
 
-		if r.Hash.Valid {
-			input = append(input, r.Hash.String)
-		} else {
-			input = append(input, nil)
-		}
+func (s *gitRefsSource) Next() bool {
+	if !s.rows.Next() {
+		return false
+	}
 
-		if r.Remote.Valid {
-			input = append(input, r.Remote.String)
-		} else {
-			input = append(input, nil)
-		}
+	var r ref
+	if s.err = s.rows.StructScan(&r); s.err != nil {
+		return false
+	}
 
-		if r.Target.Valid {
-			input = append(input, r.Target.String)
-		} else {
-			input = append(input, nil)
+	var message, taggerName, taggerEmail, taggerWhen interface{}
+	if r.Type.String == "tag" && r.Hash.Valid {
+		// the hash of the ref of an annotated tag is the one of its tag object, lightweight tags have none
+		tag, err := s.tags.TagObject(plumbing.NewHash(r.Hash.String))
+		if err != nil && !errors.Is(err, plumbing.ErrObjectNotFound) {
+			s.err = fmt.Errorf("tag object of %s: %w", r.FullName.String, err)
+			return false
+		} else if tag != nil {
+			message, taggerName, taggerEmail, taggerWhen = tag.Message, tag.Tagger.Name, tag.Tagger.Email, tag.Tagger.When
 		}
+	}
 
-		if r.Type.Valid {
-			input = append(input, r.Type.String)
-		} else {
-			input = append(input, nil)
+	var ahead, behind interface{}
+	if r.Type.String == "branch" && r.Hash.Valid && s.head != nil {
+		oid, err := libgit2.NewOid(r.Hash.String)
+		if err != nil {
+			s.err = fmt.Errorf("hash of %s: %w", r.FullName.String, err)
+			return false
 		}
 
-		if r.TagCommitHash.Valid {
-			input = append(input, r.TagCommitHash.String)
-		} else {
-			input = append(input, nil)
+		if ahead, behind, s.err = s.graph.AheadBehind(oid, s.head); s.err != nil {
+			s.err = fmt.Errorf("ahead / behind of %s: %w", r.FullName.String, s.err)
+			return false
 		}
+	}
 
-		inputs = append(inputs, input)
+	s.current = []interface{}{s.repo, r.FullName.String, r.Name.String,
+		nullString(r.Hash), nullString(r.Remote), nullString(r.Target), nullString(r.Type), nullString(r.TagCommitHash),
+		message, taggerName, taggerEmail, taggerWhen, ahead, behind}
+	return true
+}
+
+func (s *gitRefsSource) Values() ([]interface{}, error) { return s.current, nil }
+
+func (s *gitRefsSource) Err() error {
+	if s.err != nil {
+		return s.err
 	}
+	return s.rows.Err()
+}
 
-	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"git_refs"}, []string{"repo_id", "full_name", "name", "hash", "remote", "target", "type", "tag_commit_hash"}, pgx.CopyFromRows(inputs)); err != nil {
-		return err
+// nullString returns the value of s, or nil if s is null
+func nullString(s sql.NullString) interface{} {
+	if s.Valid {
+		return s.String
 	}
 	return nil
 }
 
+// sendBatchGitRefs uses the pg COPY protocol to stream the git refs of the given rows (of the repository at repoPath)
+// into the given table, along with the details of annotated tags, and how far branches are ahead of (and behind) the
+// default branch
+func (w *worker) sendBatchGitRefs(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, repoPath string, rows *sqlx.Rows) (int64, error) {
+	repoID, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return 0, err
+	}
+
+	var tags *git.Repository
+	if tags, err = git.PlainOpen(repoPath); err != nil {
+		return 0, fmt.Errorf("could not open repository: %w", err)
+	}
+
+	var graph *libgit2.Repository
+	if graph, err = libgit2.OpenRepository(repoPath); err != nil {
+		return 0, fmt.Errorf("could not open repository: %w", err)
+	}
+	defer graph.Free()
+
+	// an unborn HEAD (e.g. of an empty repo) has no commit to compare branches to
+	var head *libgit2.Oid
+	if ref, err := graph.Head(); err == nil {
+		head = ref.Target()
+		ref.Free()
+	}
+
+	var settings *copyBatchSettings
+	if settings, err = w.copyBatchSettingsFor(j); err != nil {
+		return 0, err
+	}
+
+	var src = &gitRefsSource{repo: repoID, rows: rows, tags: tags, graph: graph, head: head}
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "full_name", "name", "hash", "remote", "target", "type", "tag_commit_hash", "tag_message", "tagger_name", "tagger_email", "tagger_when", "ahead", "behind"}, src, settings, nil)
+}
+
 type ref struct {
 	FullName      sql.NullString `db:"full_name"`
 	Hash          sql.NullString `db:"hash"`
@@ -86,7 +145,7 @@ func (w *worker) handleGitRefs(ctx context.Context, j *db.DequeueSyncJobRow) err
 		return fmt.Errorf("send batch log messages: %w", err)
 	}
 
-	tmpPath, cleanup, err := helper.CreateTempDir(os.Getenv("GIT_CLONE_PATH"), fmt.Sprintf("mergestat-repo-%s-*", j.RepoID.String()))
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
 	if err != nil {
 		return fmt.Errorf("temp dir: %w", err)
 	}
@@ -96,19 +155,13 @@ func (w *worker) handleGitRefs(ctx context.Context, j *db.DequeueSyncJobRow) err
 		}
 	}()
 
-	if err = w.clone(ctx, tmpPath, j); err != nil {
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
 		return fmt.Errorf("git clone: %w", err)
 	}
 
-	refs := make([]*ref, 0)
-	if err = w.mergestat.SelectContext(ctx, &refs, selectRefs, tmpPath); err != nil {
-		return err
-	}
-
-	l.Info().Msgf("retrieved refs: %d", len(refs))
-
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return err
 	}
 	defer func() {
@@ -119,30 +172,25 @@ func (w *worker) handleGitRefs(ctx context.Context, j *db.DequeueSyncJobRow) err
 		}
 	}()
 
-	r, err := tx.Exec(ctx, "DELETE FROM git_refs WHERE repo_id = $1;", j.RepoID.String())
-	if err != nil {
+	if err = stage(ctx, tx, "git_refs"); err != nil {
 		return err
 	}
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("removed %d row(s) from git_refs", r.RowsAffected()),
-	}}); err != nil {
+	var rows *sqlx.Rows
+	if rows, err = w.mergestat.QueryxContext(ctx, selectRefs, repoPath); err != nil {
 		return err
 	}
+	defer rows.Close()
 
-	if err := w.sendBatchGitRefs(ctx, tx, j, refs); err != nil {
+	var sent int64
+	if sent, err = w.sendBatchGitRefs(ctx, tx, staging("git_refs"), j, repoPath, rows); err != nil {
 		return err
 	}
 
-	l.Info().Msgf("sent batch of %d refs", len(refs))
+	l.Info().Msgf("sent batch of %d refs", sent)
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("inserted %d row(s) into git_refs", len(refs)),
-	}}); err != nil {
+	// rows of refs that didn't change are left untouched, and rows of removed refs are deleted
+	if err := w.mergeStaged(ctx, tx, j, "git_refs", sent); err != nil {
 		return err
 	}
 
@@ -37,7 +37,7 @@ func (w *worker) handleGitRemotes(ctx context.Context, j *db.DequeueSyncJobRow)
 	}
 
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return err
 	}
 	defer func() {
@@ -0,0 +1,476 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	"golang.org/x/crypto/ssh"
+)
+
+// gitSignaturesSettings are the settings accepted by a GIT_SIGNATURES repo sync
+type gitSignaturesSettings struct {
+	// GPGKeyring is an armored keyring of the public GPG keys trusted to sign commits and tags
+	GPGKeyring string `json:"gpg_keyring"`
+	// SSHAllowedSigners lists the SSH keys trusted to sign commits and tags, in the format of git's
+	// gpg.ssh.allowedSignersFile (i.e. "<principals> [options] <key type> <key>" lines)
+	SSHAllowedSigners string `json:"ssh_allowed_signers"`
+	// GitHub is set to verify the signatures that couldn't be verified against the keyrings with the GitHub API
+	GitHub bool `json:"github"`
+}
+
+// signatureVerification is the verification status of the signature of a commit or tag
+type signatureVerification struct {
+	Hash          string
+	ObjectType    string // commit or tag
+	TagName       string
+	SignatureType string // gpg, ssh, x509 or unknown, empty if unsigned
+	Verified      bool
+	Reason        string
+	Signer        string
+	VerifiedBy    string // keyring or github, empty if unsigned
+}
+
+// allowedSigner is an SSH key trusted to sign commits and tags, and the principals it's trusted for
+type allowedSigner struct {
+	principals string
+	key        ssh.PublicKey
+}
+
+// signatureVerifier verifies signatures against the keyrings of a sync's settings
+type signatureVerifier struct {
+	gpg openpgp.EntityList
+	ssh []allowedSigner
+}
+
+func newSignatureVerifier(settings *gitSignaturesSettings) (_ *signatureVerifier, err error) {
+	var v signatureVerifier
+	if strings.TrimSpace(settings.GPGKeyring) != "" {
+		if v.gpg, err = openpgp.ReadArmoredKeyRing(strings.NewReader(settings.GPGKeyring)); err != nil {
+			return nil, fmt.Errorf("invalid gpg keyring: %w", err)
+		}
+	}
+
+	for i, line := range strings.Split(settings.SSHAllowedSigners, "\n") {
+		if line = strings.TrimSpace(line); line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// the options between the principals and the key are skipped, the key being the first thing that parses as one
+		var fields = strings.Fields(line)
+		var signer *allowedSigner
+		for k := 1; k < len(fields) && signer == nil; k++ {
+			if key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(strings.Join(fields[k:], " "))); err == nil {
+				signer = &allowedSigner{principals: fields[0], key: key}
+			}
+		}
+		if signer == nil {
+			return nil, fmt.Errorf("invalid line %d of the ssh allowed signers", i+1)
+		}
+		v.ssh = append(v.ssh, *signer)
+	}
+
+	return &v, nil
+}
+
+// verify verifies the given signature of the given (signed) payload, setting the verification status of s
+func (v *signatureVerifier) verify(s *signatureVerification, signature string, payload []byte) {
+	s.SignatureType, s.VerifiedBy = signatureType(signature), "keyring"
+
+	switch s.SignatureType {
+	case "gpg":
+		if len(v.gpg) == 0 {
+			s.Reason = "unknown_key"
+			return
+		}
+
+		entity, err := openpgp.CheckArmoredDetachedSignature(v.gpg, bytes.NewReader(payload), strings.NewReader(signature), nil)
+		switch {
+		case err == nil:
+			s.Verified, s.Reason, s.Signer = true, "valid", fmt.Sprintf("%X", entity.PrimaryKey.Fingerprint)
+		case errors.Is(err, pgperrors.ErrUnknownIssuer):
+			s.Reason = "unknown_key"
+		case errors.Is(err, pgperrors.ErrKeyExpired), errors.Is(err, pgperrors.ErrSignatureExpired):
+			s.Reason = "expired_key"
+		default:
+			s.Reason = "invalid"
+		}
+	case "ssh":
+		s.Verified, s.Reason, s.Signer = v.verifySSH(signature, payload)
+	default:
+		s.Reason = "unknown_signature_type"
+	}
+}
+
+// verifySSH verifies an armored SSH signature (as made by ssh-keygen -Y sign, in the git namespace) of the given
+// payload against the allowed signers, see https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+func (v *signatureVerifier) verifySSH(signature string, payload []byte) (verified bool, reason, signer string) {
+	var armored = strings.TrimSpace(signature)
+	armored = strings.TrimPrefix(armored, "-----BEGIN SSH SIGNATURE-----")
+	armored = strings.TrimSuffix(armored, "-----END SSH SIGNATURE-----")
+
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(armored), ""))
+	if err != nil || !bytes.HasPrefix(blob, []byte("SSHSIG")) {
+		return false, "malformed_signature", ""
+	}
+
+	var sig struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if err = ssh.Unmarshal(blob[len("SSHSIG"):], &sig); err != nil || sig.Version != 1 {
+		return false, "malformed_signature", ""
+	}
+
+	key, err := ssh.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return false, "malformed_signature", ""
+	}
+
+	var trusted *allowedSigner
+	for i := range v.ssh {
+		if bytes.Equal(v.ssh[i].key.Marshal(), key.Marshal()) {
+			trusted = &v.ssh[i]
+			break
+		}
+	}
+	if trusted == nil {
+		return false, "unknown_key", ""
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return false, "malformed_signature", ""
+	}
+	h.Write(payload)
+
+	var s ssh.Signature
+	if err = ssh.Unmarshal(sig.Signature, &s); err != nil {
+		return false, "malformed_signature", ""
+	}
+
+	var signed = append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sig.Namespace, sig.Reserved, sig.HashAlgorithm, h.Sum(nil)})...)
+	if sig.Namespace != "git" || key.Verify(signed, &s) != nil {
+		return false, "invalid", ""
+	}
+	return true, "valid", trusted.principals
+}
+
+// signatureType returns the type of the given (armored) signature, or an empty string if there's none
+func signatureType(signature string) string {
+	switch {
+	case signature == "":
+		return ""
+	case strings.HasPrefix(signature, "-----BEGIN PGP SIGNATURE-----"):
+		return "gpg"
+	case strings.HasPrefix(signature, "-----BEGIN SSH SIGNATURE-----"):
+		return "ssh"
+	case strings.HasPrefix(signature, "-----BEGIN SIGNED MESSAGE-----"):
+		return "x509"
+	default:
+		return "unknown"
+	}
+}
+
+// signedPayload returns the payload a signature was made over, i.e. the object encoded without its signature
+func signedPayload(encodeWithoutSignature func(plumbing.EncodedObject) error) ([]byte, error) {
+	var o = &plumbing.MemoryObject{}
+	if err := encodeWithoutSignature(o); err != nil {
+		return nil, err
+	}
+
+	r, err := o.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// collectSignatures verifies the signatures of the commits reachable from HEAD, and of the annotated tags, of the
+// repository at repoPath (lightweight tags can't be signed)
+func collectSignatures(ctx context.Context, repoPath string, v *signatureVerifier) ([]*signatureVerification, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open repository: %w", err)
+	}
+
+	var verifications []*signatureVerification
+	var verify = func(s *signatureVerification, signature string, encode func(plumbing.EncodedObject) error) error {
+		if s.Reason = "unsigned"; signature != "" {
+			payload, err := signedPayload(encode)
+			if err != nil {
+				return err
+			}
+			v.verify(s, signature, payload)
+		}
+		verifications = append(verifications, s)
+		return ctx.Err()
+	}
+
+	commits, err := repo.Log(&git.LogOptions{})
+	if err != nil && !errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, fmt.Errorf("could not walk commits: %w", err)
+	} else if err == nil {
+		if err = commits.ForEach(func(c *object.Commit) error {
+			return verify(&signatureVerification{Hash: c.Hash.String(), ObjectType: "commit"}, c.PGPSignature, c.EncodeWithoutSignature)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	tags, err := repo.TagObjects()
+	if err != nil {
+		return nil, fmt.Errorf("could not list tags: %w", err)
+	}
+	if err = tags.ForEach(func(t *object.Tag) error {
+		return verify(&signatureVerification{Hash: t.Hash.String(), ObjectType: "tag", TagName: t.Name}, t.PGPSignature, t.EncodeWithoutSignature)
+	}); err != nil {
+		return nil, err
+	}
+
+	return verifications, nil
+}
+
+// verifyWithGitHub verifies the signatures that couldn't be verified against the keyrings with the GitHub API,
+// which verifies them against the keys of the GitHub users they were made by. Commits are paged through (100 at a
+// time, from the default branch) until all of them have been verified, while tags are fetched one at a time.
+func (w *worker) verifyWithGitHub(ctx context.Context, j *db.DequeueSyncJobRow, verifications []*signatureVerification) error {
+	var pending = make(map[string]*signatureVerification)
+	var tags []*signatureVerification
+	for _, s := range verifications {
+		if s.SignatureType == "" || s.Verified {
+			continue
+		}
+
+		if s.ObjectType == "tag" {
+			tags = append(tags, s)
+		} else {
+			pending[s.Hash] = s
+		}
+	}
+	if len(pending) == 0 && len(tags) == 0 {
+		return nil
+	}
+
+	var err error
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return err
+	}
+
+	var apply = func(s *signatureVerification, v *github.SignatureVerification) {
+		if v != nil {
+			s.Verified, s.Reason, s.VerifiedBy = v.GetVerified(), v.GetReason(), "github"
+		}
+	}
+
+	opts := &github.CommitsListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for len(pending) > 0 {
+		page, resp, err := client.Repositories.ListCommits(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		for _, c := range page {
+			if s, ok := pending[c.GetSHA()]; ok {
+				apply(s, c.GetCommit().GetVerification())
+				delete(pending, c.GetSHA())
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	for _, s := range tags {
+		tag, resp, err := client.Git.GetTag(ctx, repoOwner, repoName, s.Hash)
+		if err != nil {
+			return err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+		apply(s, tag.GetVerification())
+	}
+
+	return nil
+}
+
+// sendBatchGitSignatures uses the pg COPY protocol to send the verified signatures into the given table
+func (w *worker) sendBatchGitSignatures(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, verifications []*signatureVerification) (int64, error) {
+	var nullable = func(s string) interface{} {
+		if s == "" {
+			return nil
+		}
+		return s
+	}
+
+	inputs := make([][]interface{}, 0, len(verifications))
+	for _, s := range verifications {
+		inputs = append(inputs, []interface{}{j.RepoID, s.Hash, s.ObjectType, nullable(s.TagName), nullable(s.SignatureType),
+			s.SignatureType != "", s.Verified, s.Reason, nullable(s.Signer), nullable(s.VerifiedBy)})
+	}
+
+	settings, err := w.copyBatchSettingsFor(j)
+	if err != nil {
+		return 0, err
+	}
+
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "hash", "object_type", "tag_name", "signature_type", "signed", "verified", "reason", "signer", "verified_by"}, pgx.CopyFromRows(inputs), settings, nil)
+}
+
+func (w *worker) handleGitSignatures(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var settings gitSignaturesSettings
+	if err = decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	var verifier *signatureVerifier
+	if verifier, err = newSignatureVerifier(&settings); err != nil {
+		return err
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err = cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+		}
+	}()
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	var verifications []*signatureVerification
+	if verifications, err = collectSignatures(ctx, repoPath, verifier); err != nil {
+		return err
+	}
+
+	if settings.GitHub {
+		if err = w.verifyWithGitHub(ctx, j, verifications); err != nil {
+			return fmt.Errorf("verify with github: %w", err)
+		}
+	}
+
+	var signed, verified int
+	for _, s := range verifications {
+		if s.SignatureType != "" {
+			signed++
+		}
+		if s.Verified {
+			verified++
+		}
+	}
+
+	l.Info().Msgf("verified %d of %d signed objects (out of %d)", verified, signed, len(verifications))
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("could not rollback transaction")
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "git_signatures"); err != nil {
+		return err
+	}
+
+	var inserted int64
+	if inserted, err = w.sendBatchGitSignatures(ctx, tx, staging("git_signatures"), j, verifications); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf("verified %d of %d signed commits and tags (out of %d)", verified, signed, len(verifications)),
+	}}); err != nil {
+		return err
+	}
+
+	if err := w.mergeStaged(ctx, tx, j, "git_signatures", inserted); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return err
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	return err
+}
@@ -0,0 +1,262 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// findRepoByURL is the query of the id of the repo of the given url (ignoring case and a .git suffix), as db.FindRepo
+const findRepoByURL = `SELECT id FROM public.repos WHERE regexp_replace(lower(repo), '(\.git)?/*$', '') = regexp_replace(lower($1), '(\.git)?/*$', '') ORDER BY created_at LIMIT 1`
+
+// submodulesSettings are the settings accepted by a GIT_SUBMODULES repo sync
+type submodulesSettings struct {
+	// Enqueue adds the repos of the submodules (that aren't synced yet) to the provider of the repo, and schedules
+	// their syncs, so that they're synced themselves
+	Enqueue bool `json:"enqueue"`
+
+	// SyncTypes are the syncs scheduled for the repos of the submodules. Defaults to the ones scheduled for the repo.
+	SyncTypes []string `json:"syncTypes"`
+}
+
+// submodule is a submodule of a repo, as declared by its .gitmodules, and the commit it's pinned to
+type submodule struct {
+	Name        string
+	Path        string
+	URL         string // as declared, which can be relative to the URL of the repo
+	ResolvedURL string
+	Branch      string
+	CommitHash  string     // empty if the path of the submodule has no gitlink in the tree
+	RepoID      *uuid.UUID // of the repo of the submodule, if it's synced
+}
+
+// resolveSubmoduleURL resolves the URL of a submodule relative to the URL of its repo (e.g. ../other.git), as git does
+func resolveSubmoduleURL(repoURL, submoduleURL string) string {
+	if !strings.HasPrefix(submoduleURL, "./") && !strings.HasPrefix(submoduleURL, "../") {
+		return submoduleURL
+	}
+
+	if u, err := url.Parse(repoURL); err == nil && u.Scheme != "" {
+		u.Path = path.Join(u.Path, submoduleURL)
+		return u.String()
+	}
+
+	// scp-like URLs (e.g. git@github.com:org/repo.git)
+	if host, repoPath, ok := strings.Cut(repoURL, ":"); ok {
+		return host + ":" + strings.TrimPrefix(path.Join("/"+repoPath, submoduleURL), "/")
+	}
+	return submoduleURL
+}
+
+// collectSubmodules returns the submodules of the HEAD of the repository at repoPath
+func collectSubmodules(repoPath, repoURL string) ([]submodule, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve HEAD: %w", err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := commit.File(".gitmodules")
+	if errors.Is(err, object.ErrFileNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	contents, err := f.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("read .gitmodules: %w", err)
+	}
+
+	var modules = config.NewModules()
+	if err = modules.Unmarshal([]byte(contents)); err != nil {
+		return nil, fmt.Errorf("parse .gitmodules: %w", err)
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var submodules []submodule
+	for _, m := range modules.Submodules {
+		var s = submodule{Name: m.Name, Path: m.Path, URL: m.URL, ResolvedURL: resolveSubmoduleURL(repoURL, m.URL), Branch: m.Branch}
+
+		// the commit a submodule is pinned to is the hash of its gitlink
+		if entry, err := tree.FindEntry(m.Path); err == nil && entry.Mode == filemode.Submodule {
+			s.CommitHash = entry.Hash.String()
+		} else if err != nil && !errors.Is(err, object.ErrEntryNotFound) && !errors.Is(err, object.ErrDirectoryNotFound) {
+			return nil, fmt.Errorf("find %s: %w", m.Path, err)
+		}
+
+		submodules = append(submodules, s)
+	}
+
+	return submodules, nil
+}
+
+// enqueueSubmodules adds the repos of the given submodules that aren't synced yet (to the provider and tenant of the
+// given repo), and schedules the given syncs of those repos (or the ones of the given repo, if none), leaving the ones
+// already configured as they are
+func enqueueSubmodules(ctx context.Context, tx pgx.Tx, j *db.DequeueSyncJobRow, submodules []submodule, syncTypes []string) (added int, err error) {
+	for _, s := range submodules {
+		var id uuid.UUID
+		if err = tx.QueryRow(ctx, findRepoByURL, s.ResolvedURL).Scan(&id); errors.Is(err, pgx.ErrNoRows) {
+			const add = `INSERT INTO public.repos (repo, provider, tenant) SELECT $1, provider, tenant FROM public.repos WHERE id = $2 RETURNING id`
+			if err = tx.QueryRow(ctx, add, s.ResolvedURL, j.RepoID).Scan(&id); err != nil {
+				return added, fmt.Errorf("add repo %s: %w", s.ResolvedURL, err)
+			}
+			added++
+		} else if err != nil {
+			return added, err
+		}
+
+		const schedule = `
+			INSERT INTO mergestat.repo_syncs (repo_id, sync_type, priority, schedule_enabled)
+			SELECT $1, t.type, t.priority, TRUE FROM mergestat.repo_sync_types t
+			WHERE CASE WHEN CARDINALITY($3::TEXT[]) > 0 THEN t.type = ANY($3::TEXT[])
+				ELSE t.type IN (SELECT sync_type FROM mergestat.repo_syncs WHERE repo_id = $2 AND schedule_enabled) END
+			ON CONFLICT ON CONSTRAINT repo_syncs_repo_id_sync_type_key DO NOTHING`
+		if _, err = tx.Exec(ctx, schedule, id, j.RepoID, syncTypes); err != nil {
+			return added, fmt.Errorf("schedule syncs of %s: %w", s.ResolvedURL, err)
+		}
+	}
+	return added, nil
+}
+
+// sendBatchSubmodules uses the pg COPY protocol to send the submodules into the given table
+func (w *worker) sendBatchSubmodules(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, submodules []submodule) (int64, error) {
+	inputs := make([][]interface{}, 0, len(submodules))
+	for _, s := range submodules {
+		inputs = append(inputs, []interface{}{j.RepoID, s.Path, s.Name, s.URL, s.ResolvedURL, nullIfEmpty(s.Branch), nullIfEmpty(s.CommitHash), s.RepoID})
+	}
+
+	settings, err := w.copyBatchSettingsFor(j)
+	if err != nil {
+		return 0, err
+	}
+
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "path", "name", "url", "resolved_url", "branch", "commit_hash", "submodule_repo_id"}, pgx.CopyFromRows(inputs), settings, nil)
+}
+
+func (w *worker) handleGitSubmodules(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	var settings submodulesSettings
+	if err = decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+		}
+	}()
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	var submodules []submodule
+	if submodules, err = collectSubmodules(repoPath, j.Repo); err != nil {
+		return err
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("could not rollback transaction")
+			}
+		}
+	}()
+
+	if settings.Enqueue {
+		var added int
+		if added, err = enqueueSubmodules(ctx, tx, j, submodules, settings.SyncTypes); err != nil {
+			return err
+		}
+
+		if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+			Message: fmt.Sprintf("enqueued the repos of %d submodule(s), %d of which were added", len(submodules), added),
+		}}); err != nil {
+			return fmt.Errorf("send batch log messages: %w", err)
+		}
+	}
+
+	// link the submodules to their repos, for those that are synced
+	for i := range submodules {
+		var id uuid.UUID
+		if err = tx.QueryRow(ctx, findRepoByURL, submodules[i].ResolvedURL).Scan(&id); err == nil {
+			submodules[i].RepoID = &id
+		} else if !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("find repo of submodule %s: %w", submodules[i].Path, err)
+		}
+	}
+
+	if err = stage(ctx, tx, "git_submodules"); err != nil {
+		return err
+	}
+
+	var inserted int64
+	if inserted, err = w.sendBatchSubmodules(ctx, tx, staging("git_submodules"), j, submodules); err != nil {
+		return fmt.Errorf("send batch submodules: %w", err)
+	}
+
+	l.Info().Msgf("sent batch of %d submodules", inserted)
+
+	if err := w.mergeStaged(ctx, tx, j, "git_submodules", inserted); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("update status done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	return err
+}
@@ -0,0 +1,222 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/go-enry/go-enry/v2"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/gitattributes"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// treeEntry is a path of the tree of a repo, either a file (blob), a directory (tree) or a submodule (commit)
+type treeEntry struct {
+	Path  string
+	Type  string // blob, tree or commit
+	Mode  string // as in git ls-tree, e.g. 100644
+	Hash  string
+	Size  int64     // of the blob, or the total size of the blobs within the tree (0 for submodules)
+	Depth int       // of the path, 0 being the entries at the root of the repo
+	Flags fileFlags // of the file, only directories being flagged as vendored (by their path) as well
+}
+
+// treeEntryType returns the (git object) type of the entries of the given mode
+func treeEntryType(mode filemode.FileMode) string {
+	switch mode {
+	case filemode.Dir:
+		return "tree"
+	case filemode.Submodule:
+		return "commit"
+	default:
+		return "blob"
+	}
+}
+
+// collectTree returns every path of the HEAD tree of the repository at repoPath. The sizes of the blobs are read from
+// the headers of their objects, and only the beginning of their contents is read, to flag them (see classifyFile).
+func collectTree(ctx context.Context, repoPath string) ([]treeEntry, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve HEAD: %w", err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	stack, err := readGitAttributes(tree)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []treeEntry
+	var directories = make(map[string]int) // index of the entry of each directory, to add the sizes of its blobs to
+
+	var walker = object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		var e = treeEntry{Path: name, Type: treeEntryType(entry.Mode), Mode: fmt.Sprintf("%06o", uint32(entry.Mode)), Hash: entry.Hash.String(), Depth: strings.Count(name, "/")}
+		switch e.Type {
+		case "tree":
+			directories[name] = len(entries)
+			e.Flags.Vendored = enry.IsVendor(name + "/")
+		case "blob":
+			if e.Size, err = repo.Storer.EncodedObjectSize(entry.Hash); err != nil {
+				return nil, fmt.Errorf("size of %s: %w", name, err)
+			}
+			if e.Flags, err = classifyBlob(repo, name, entry, stack); err != nil {
+				return nil, fmt.Errorf("classify %s: %w", name, err)
+			}
+			for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+				if i, ok := directories[dir]; ok {
+					entries[i].Size += e.Size
+				}
+			}
+		}
+		entries = append(entries, e)
+
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// classifyBlob flags the blob of the given entry, from the beginning of its contents (but for symlinks, whose contents
+// is their target)
+func classifyBlob(repo *git.Repository, name string, entry object.TreeEntry, stack []gitattributes.MatchAttribute) (fileFlags, error) {
+	var sample []byte
+	if entry.Mode != filemode.Symlink {
+		blob, err := repo.BlobObject(entry.Hash)
+		if err != nil {
+			return fileFlags{}, err
+		}
+
+		r, err := blob.Reader()
+		if err != nil {
+			return fileFlags{}, err
+		}
+		defer r.Close()
+
+		if sample, err = io.ReadAll(io.LimitReader(r, languageSampleSize)); err != nil {
+			return fileFlags{}, err
+		}
+	}
+	return classifyFile(name, sample, matchAttributes(stack, name)), nil
+}
+
+// sendBatchTree uses the pg COPY protocol to send the entries of a tree into the given table
+func (w *worker) sendBatchTree(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, entries []treeEntry) (int64, error) {
+	inputs := make([][]interface{}, 0, len(entries))
+	for _, e := range entries {
+		inputs = append(inputs, []interface{}{j.RepoID, e.Path, e.Type, e.Mode, e.Hash, e.Size, e.Depth, e.Flags.Binary, e.Flags.Generated, e.Flags.Vendored})
+	}
+
+	settings, err := w.copyBatchSettingsFor(j)
+	if err != nil {
+		return 0, err
+	}
+
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "path", "type", "mode", "hash", "size", "depth", "binary", "generated", "vendored"}, pgx.CopyFromRows(inputs), settings, nil)
+}
+
+func (w *worker) handleGitTree(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+		}
+	}()
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	var entries []treeEntry
+	if entries, err = collectTree(ctx, repoPath); err != nil {
+		return err
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("could not rollback transaction")
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "git_tree"); err != nil {
+		return err
+	}
+
+	var inserted int64
+	if inserted, err = w.sendBatchTree(ctx, tx, staging("git_tree"), j, entries); err != nil {
+		return fmt.Errorf("send batch tree: %w", err)
+	}
+
+	l.Info().Msgf("sent batch of %d tree entries", inserted)
+
+	if err := w.mergeStaged(ctx, tx, j, "git_tree", inserted); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("update status done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	return err
+}
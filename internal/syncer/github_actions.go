@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/google/go-github/v50/github"
 	"github.com/jackc/pgx/v4"
 	"github.com/mergestat/mergestat/internal/db"
 	"github.com/mergestat/mergestat/internal/warehouse"
@@ -30,13 +31,25 @@ func (w *worker) handleGithubActions(ctx context.Context, j *db.DequeueSyncJobRo
 		return errGitHubTokenRequired
 	}
 
-	if err := warehouse.New(ctx, w.db, w.pool, l, ghToken).GitHubActions(ctx, j); err != nil {
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	var options = warehouse.Options{
+		WorkflowsPerPage:    w.config.GitHubWorkflowPerPage,
+		WorkflowRunsPerPage: w.config.GitHubWorkflowRunsPerPage,
+		WorkflowJobsPerPage: w.config.GitHubWorkflowJobsPerPage,
+		LogsPath:            w.config.GitWorkflowLogsPath,
+	}
+
+	if err := warehouse.New(w.db, w.pool, l, client, options).GitHubActions(ctx, j); err != nil {
 		return err
 	}
 
 	var tx pgx.Tx
 
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 
@@ -0,0 +1,337 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+)
+
+// githubBranchProtection is the protection of a branch of a repo
+type githubBranchProtection struct {
+	Branch     string
+	Protection *github.Protection
+}
+
+// githubRuleset is a ruleset of a repo (or of its org), which the GitHub client doesn't support yet, see
+// https://docs.github.com/en/rest/repos/rules#get-a-repository-ruleset
+type githubRuleset struct {
+	ID           int64           `json:"id"`
+	Name         string          `json:"name"`
+	Target       string          `json:"target"`      // branch or tag
+	SourceType   string          `json:"source_type"` // Repository or Organization
+	Source       string          `json:"source"`
+	Enforcement  string          `json:"enforcement"` // disabled, active or evaluate
+	Conditions   json.RawMessage `json:"conditions"`
+	Rules        json.RawMessage `json:"rules"`
+	BypassActors json.RawMessage `json:"bypass_actors"`
+}
+
+// fetchGitHubBranchProtections returns the protection of all the protected branches of a repo, using the GitHub REST
+// API. Branches only protected by rulesets (see fetchGitHubRulesets) have no protection of their own.
+func (w *worker) fetchGitHubBranchProtections(ctx context.Context, client *github.Client, repoOwner, repoName string) ([]*githubBranchProtection, error) {
+	var branches = make([]*github.Branch, 0)
+
+	opts := &github.BranchListOptions{Protected: github.Bool(true), ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Repositories.ListBranches(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		branches = append(branches, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	var protections = make([]*githubBranchProtection, 0, len(branches))
+	for _, b := range branches {
+		protection, resp, err := client.Repositories.GetBranchProtection(ctx, repoOwner, repoName, b.GetName())
+		if errors.Is(err, github.ErrBranchNotProtected) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("protection of %s: %w", b.GetName(), err)
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		protections = append(protections, &githubBranchProtection{Branch: b.GetName(), Protection: protection})
+	}
+
+	return protections, nil
+}
+
+// fetchGitHubRulesets returns the rulesets of a repo (including the ones of its org that apply to it), along with
+// their rules, using the GitHub REST API. Repos that rulesets aren't available to (e.g. private repos of free plans) have none.
+func (w *worker) fetchGitHubRulesets(ctx context.Context, client *github.Client, repoOwner, repoName string) ([]*githubRuleset, error) {
+	var summaries = make([]*githubRuleset, 0)
+
+	for page := 1; page != 0; {
+		req, err := client.NewRequest(http.MethodGet, fmt.Sprintf("repos/%s/%s/rulesets?includes_parents=true&per_page=100&page=%d", repoOwner, repoName, page), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var rulesets []*githubRuleset
+		resp, err := client.Do(ctx, req, &rulesets)
+		if err != nil {
+			if resp != nil && resp.StatusCode == http.StatusNotFound {
+				return summaries, nil
+			}
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		summaries = append(summaries, rulesets...)
+		page = resp.NextPage
+	}
+
+	// the rules of a ruleset are only returned along with the ruleset itself
+	var rulesets = make([]*githubRuleset, 0, len(summaries))
+	for _, s := range summaries {
+		req, err := client.NewRequest(http.MethodGet, fmt.Sprintf("repos/%s/%s/rulesets/%d", repoOwner, repoName, s.ID), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var ruleset githubRuleset
+		resp, err := client.Do(ctx, req, &ruleset)
+		if err != nil {
+			return nil, fmt.Errorf("ruleset %d: %w", s.ID, err)
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		rulesets = append(rulesets, &ruleset)
+	}
+
+	return rulesets, nil
+}
+
+// sendBatchGitHubBranchProtections uses the pg COPY protocol to send a batch of GitHub branch protections
+func (w *worker) sendBatchGitHubBranchProtections(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*githubBranchProtection) error {
+	cols := []string{
+		"repo_id",
+		"branch",
+		"required_approving_review_count",
+		"dismiss_stale_reviews",
+		"require_code_owner_reviews",
+		"require_last_push_approval",
+		"required_status_checks",
+		"strict_status_checks",
+		"enforce_admins",
+		"require_linear_history",
+		"allow_force_pushes",
+		"allow_deletions",
+		"required_conversation_resolution",
+		"lock_branch",
+		"protection",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, b := range batch {
+		var p = b.Protection
+
+		// a NULL review count means that PRs (and their reviews) aren't required
+		var reviews = p.GetRequiredPullRequestReviews()
+		var reviewCount *int
+		if reviews != nil {
+			reviewCount = &reviews.RequiredApprovingReviewCount
+		}
+
+		var checks = p.GetRequiredStatusChecks()
+		var requiredChecks = make([]string, 0)
+		if checks != nil {
+			requiredChecks = append(requiredChecks, checks.Contexts...)
+			if len(checks.Contexts) == 0 {
+				for _, c := range checks.Checks {
+					requiredChecks = append(requiredChecks, c.Context)
+				}
+			}
+		}
+
+		protection, err := json.Marshal(p)
+		if err != nil {
+			return fmt.Errorf("marshal protection: %w", err)
+		}
+
+		input := []interface{}{
+			repo,
+			b.Branch,
+			reviewCount,
+			reviews != nil && reviews.DismissStaleReviews,
+			reviews != nil && reviews.RequireCodeOwnerReviews,
+			reviews != nil && reviews.RequireLastPushApproval,
+			requiredChecks,
+			checks != nil && checks.Strict,
+			p.GetEnforceAdmins().Enabled,
+			p.GetRequireLinearHistory().Enabled,
+			p.GetAllowForcePushes().Enabled,
+			p.GetAllowDeletions().Enabled,
+			p.GetRequiredConversationResolution().Enabled,
+			p.GetLockBranch().GetEnabled(),
+			protection,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_branch_protections")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendBatchGitHubRulesets uses the pg COPY protocol to send a batch of GitHub rulesets
+func (w *worker) sendBatchGitHubRulesets(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*githubRuleset) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"name",
+		"target",
+		"source_type",
+		"source",
+		"enforcement",
+		"conditions",
+		"rules",
+		"bypass_actors",
+	}
+
+	// missing documents are stored as empty ones, rather than as JSON nulls
+	var orEmpty = func(raw json.RawMessage, empty string) []byte {
+		if len(raw) == 0 || string(raw) == "null" {
+			return []byte(empty)
+		}
+		return raw
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, r := range batch {
+		input := []interface{}{
+			repo,
+			r.ID,
+			r.Name,
+			nullIfEmpty(r.Target),
+			nullIfEmpty(r.SourceType),
+			nullIfEmpty(r.Source),
+			nullIfEmpty(r.Enforcement),
+			orEmpty(r.Conditions, "{}"),
+			orEmpty(r.Rules, "[]"),
+			orEmpty(r.BypassActors, "[]"),
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_rulesets")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleGitHubBranchProtections(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	protections, err := w.fetchGitHubBranchProtections(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch branch protections: %w", err)
+	}
+
+	rulesets, err := w.fetchGitHubRulesets(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch rulesets: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "github_branch_protections"); err != nil {
+		return err
+	}
+	if err = stage(ctx, tx, "github_rulesets"); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchGitHubBranchProtections(ctx, tx, id, protections); err != nil {
+		return fmt.Errorf("insert branch protections: %w", err)
+	}
+
+	if err := w.sendBatchGitHubRulesets(ctx, tx, id, rulesets); err != nil {
+		return fmt.Errorf("insert rulesets: %w", err)
+	}
+
+	l.Info().Msgf("inserted branch protections: %d, rulesets: %d", len(protections), len(rulesets))
+
+	if err := w.mergeStaged(ctx, tx, j, "github_branch_protections", int64(len(protections))); err != nil {
+		return err
+	}
+	if err := w.mergeStaged(ctx, tx, j, "github_rulesets", int64(len(rulesets))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
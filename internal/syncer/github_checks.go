@@ -0,0 +1,336 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+)
+
+// githubChecksSettings are the settings accepted by a GITHUB_CHECKS repo sync
+type githubChecksSettings struct {
+	// Commits is the number of the latest commits of the default branch whose checks are synced, along with the ones
+	// of the head commits of the open PRs. Defaults to 50.
+	Commits int `json:"commits"`
+}
+
+// fetchGitHubCheckSHAs returns the SHAs of the latest commits of the default branch of a repo, and of the head commits
+// of its open PRs, without duplicates
+func (w *worker) fetchGitHubCheckSHAs(ctx context.Context, client *github.Client, repoOwner, repoName string, commits int) ([]string, error) {
+	var shas []string
+	var seen = make(map[string]bool)
+	var add = func(sha string) {
+		if sha != "" && !seen[sha] {
+			seen[sha] = true
+			shas = append(shas, sha)
+		}
+	}
+
+	// the commits are listed from the latest one, so pages are only fetched until there are enough of them
+	commitOpts := &github.CommitsListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for len(shas) < commits {
+		page, resp, err := client.Repositories.ListCommits(ctx, repoOwner, repoName, commitOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		for _, c := range page {
+			if len(shas) < commits {
+				add(c.GetSHA())
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		commitOpts.Page = resp.NextPage
+	}
+
+	prOpts := &github.PullRequestListOptions{State: "open", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.PullRequests.List(ctx, repoOwner, repoName, prOpts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		for _, pr := range page {
+			add(pr.GetHead().GetSHA())
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		prOpts.Page = resp.NextPage
+	}
+
+	return shas, nil
+}
+
+// fetchGitHubCheckRuns pages through all the check runs of a commit using the GitHub REST API, including the ones
+// re-run since (which are the attempts flaky checks are told apart by)
+func (w *worker) fetchGitHubCheckRuns(ctx context.Context, client *github.Client, repoOwner, repoName, sha string) ([]*github.CheckRun, error) {
+	var runs = make([]*github.CheckRun, 0)
+
+	opts := &github.ListCheckRunsOptions{Filter: github.String("all"), ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Checks.ListCheckRunsForRef(ctx, repoOwner, repoName, sha, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		runs = append(runs, page.CheckRuns...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return runs, nil
+}
+
+// fetchGitHubCommitStatuses pages through all the (legacy) statuses of a commit using the GitHub REST API
+func (w *worker) fetchGitHubCommitStatuses(ctx context.Context, client *github.Client, repoOwner, repoName, sha string) ([]*github.RepoStatus, error) {
+	var statuses = make([]*github.RepoStatus, 0)
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Repositories.ListStatuses(ctx, repoOwner, repoName, sha, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		statuses = append(statuses, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return statuses, nil
+}
+
+// sendBatchGitHubCheckRuns uses the pg COPY protocol to send a batch of GitHub check runs
+func (w *worker) sendBatchGitHubCheckRuns(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*github.CheckRun) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"commit_sha",
+		"name",
+		"status",
+		"conclusion",
+		"started_at",
+		"completed_at",
+		"app_slug",
+		"app_name",
+		"check_suite_id",
+		"pr_numbers",
+		"details_url",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, r := range batch {
+		var prNumbers = make([]int, 0, len(r.PullRequests))
+		for _, pr := range r.PullRequests {
+			prNumbers = append(prNumbers, pr.GetNumber())
+		}
+
+		input := []interface{}{
+			repo,
+			r.ID,
+			r.HeadSHA,
+			r.Name,
+			r.Status,
+			r.Conclusion,
+			helper.GetTimeFromTimestamp(r.StartedAt),
+			helper.GetTimeFromTimestamp(r.CompletedAt),
+			r.GetApp().Slug,
+			r.GetApp().Name,
+			r.GetCheckSuite().ID,
+			prNumbers,
+			r.DetailsURL,
+			r.HTMLURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"github_check_runs"}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendBatchGitHubCommitStatuses uses the pg COPY protocol to send a batch of GitHub commit statuses (of the given commit)
+func (w *worker) sendBatchGitHubCommitStatuses(ctx context.Context, tx pgx.Tx, repo uuid.UUID, sha string, batch []*github.RepoStatus) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"commit_sha",
+		"context",
+		"state",
+		"description",
+		"creator_login",
+		"created_at",
+		"updated_at",
+		"target_url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, s := range batch {
+		input := []interface{}{
+			repo,
+			s.ID,
+			sha,
+			s.Context,
+			s.State,
+			s.Description,
+			helper.GetUserLogin(s.Creator),
+			helper.GetTimeFromTimestamp(s.CreatedAt),
+			helper.GetTimeFromTimestamp(s.UpdatedAt),
+			s.TargetURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"github_commit_statuses"}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// handleGitHubChecks syncs the check runs and (legacy) commit statuses of the latest commits of a repo, and of the head
+// commits of its open PRs. The rows of the commits synced are replaced, the ones of older commits are kept, so that
+// the history of the checks of a repo builds up over its syncs.
+func (w *worker) handleGitHubChecks(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	var settings = githubChecksSettings{Commits: 50}
+	if err = decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	shas, err := w.fetchGitHubCheckSHAs(ctx, client, repoOwner, repoName, settings.Commits)
+	if err != nil {
+		return fmt.Errorf("fetch commits: %w", err)
+	}
+
+	var runs = make([]*github.CheckRun, 0)
+	var statuses = make(map[string][]*github.RepoStatus, len(shas))
+	var progress = w.startProgress(ctx, j, "fetching checks", int64(len(shas)))
+	for i, sha := range shas {
+		page, err := w.fetchGitHubCheckRuns(ctx, client, repoOwner, repoName, sha)
+		if err != nil {
+			return fmt.Errorf("fetch check runs of %s: %w", sha, err)
+		}
+		runs = append(runs, page...)
+
+		if statuses[sha], err = w.fetchGitHubCommitStatuses(ctx, client, repoOwner, repoName, sha); err != nil {
+			return fmt.Errorf("fetch statuses of %s: %w", sha, err)
+		}
+
+		progress.set(ctx, int64(i+1))
+	}
+	progress.done(ctx)
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if _, err := tx.Exec(ctx, "DELETE FROM github_check_runs WHERE repo_id = $1 AND commit_sha = ANY($2);", id.String(), shas); err != nil {
+		return fmt.Errorf("delete check runs: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM github_commit_statuses WHERE repo_id = $1 AND commit_sha = ANY($2);", id.String(), shas); err != nil {
+		return fmt.Errorf("delete commit statuses: %w", err)
+	}
+
+	if err := w.sendBatchGitHubCheckRuns(ctx, tx, id, runs); err != nil {
+		return fmt.Errorf("insert check runs: %w", err)
+	}
+
+	var insertedStatuses int
+	for sha, batch := range statuses {
+		if err := w.sendBatchGitHubCommitStatuses(ctx, tx, id, sha, batch); err != nil {
+			return fmt.Errorf("insert commit statuses: %w", err)
+		}
+		insertedStatuses += len(batch)
+	}
+
+	l.Info().Msgf("inserted check runs: %d, commit statuses: %d, of %d commit(s)", len(runs), insertedStatuses, len(shas))
+
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
+		Type:            SyncLogTypeInfo,
+		RepoSyncQueueID: j.ID,
+		Message:         fmt.Sprintf("inserted %d check run(s) and %d commit status(es) of %d commit(s)", len(runs), insertedStatuses, len(shas)),
+	}}); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,218 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+)
+
+// githubComment is a (non-review) comment of an issue or a PR, which GitHub lists along with the comments of issues
+type githubComment struct {
+	Comment *github.IssueComment
+	Number  int  // of the issue (or PR) the comment was made on
+	OnPR    bool // whether the comment was made on a PR
+}
+
+// parseGitHubComment returns the number of the issue (or PR) of the given comment, and whether it is a PR, from the
+// URLs of the comment (e.g. https://github.com/owner/repo/pull/1#issuecomment-2)
+func parseGitHubComment(c *github.IssueComment) (*githubComment, error) {
+	number, err := strconv.Atoi(path.Base(c.GetIssueURL()))
+	if err != nil {
+		return nil, fmt.Errorf("issue number of comment %d: %w", c.GetID(), err)
+	}
+	return &githubComment{Comment: c, Number: number, OnPR: strings.Contains(c.GetHTMLURL(), "/pull/")}, nil
+}
+
+// githubReactionsSummary returns the number of reactions of each kind (of those with any), e.g. {"+1": 2, "heart": 1}
+func githubReactionsSummary(r *github.Reactions) ([]byte, error) {
+	var summary = make(map[string]int)
+	for kind, count := range map[string]int{
+		"+1": r.GetPlusOne(), "-1": r.GetMinusOne(), "laugh": r.GetLaugh(), "confused": r.GetConfused(),
+		"heart": r.GetHeart(), "hooray": r.GetHooray(), "rocket": r.GetRocket(), "eyes": r.GetEyes(),
+	} {
+		if count > 0 {
+			summary[kind] = count
+		}
+	}
+	return json.Marshal(summary)
+}
+
+// fetchGitHubComments pages through all the comments of the issues (or PRs, if pulls is set) of a repo using the
+// GitHub REST API. Comments of issues and PRs are listed together by GitHub, so the ones of the other kind are skipped.
+func (w *worker) fetchGitHubComments(ctx context.Context, client *github.Client, repoOwner, repoName string, pulls bool) ([]*githubComment, error) {
+	var comments = make([]*githubComment, 0)
+
+	opts := &github.IssueListCommentsOptions{Sort: github.String("created"), Direction: github.String("asc"), ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Issues.ListComments(ctx, repoOwner, repoName, 0, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		for _, c := range page {
+			comment, err := parseGitHubComment(c)
+			if err != nil {
+				return nil, err
+			}
+			if comment.OnPR == pulls {
+				comments = append(comments, comment)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return comments, nil
+}
+
+// sendBatchGitHubComments uses the pg COPY protocol to send a batch of GitHub comments into the given table, whose
+// issue (or PR) number column is the given one
+func (w *worker) sendBatchGitHubComments(ctx context.Context, tx pgx.Tx, table, numberColumn string, repo uuid.UUID, batch []*githubComment) error {
+	cols := []string{
+		"repo_id",
+		numberColumn,
+		"id",
+		"author_login",
+		"author_association",
+		"body",
+		"reaction_count",
+		"reactions",
+		"created_at",
+		"updated_at",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, comment := range batch {
+		var c = comment.Comment
+		reactions, err := githubReactionsSummary(c.Reactions)
+		if err != nil {
+			return fmt.Errorf("marshal reactions: %w", err)
+		}
+
+		input := []interface{}{
+			repo,
+			comment.Number,
+			c.ID,
+			helper.GetUserLogin(c.User),
+			c.AuthorAssociation,
+			c.Body,
+			c.Reactions.GetTotalCount(),
+			reactions,
+			helper.GetTimeFromTimestamp(c.CreatedAt),
+			helper.GetTimeFromTimestamp(c.UpdatedAt),
+			c.HTMLURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging(table)}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleGitHubIssueComments(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	return w.handleGitHubComments(ctx, j, "github_issue_comments", "issue_number", false)
+}
+
+func (w *worker) handleGitHubPRComments(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	return w.handleGitHubComments(ctx, j, "github_pull_request_comments", "pr_number", true)
+}
+
+// handleGitHubComments syncs the comments of the issues (or PRs, if pulls is set) of a repo into the given table
+func (w *worker) handleGitHubComments(ctx context.Context, j *db.DequeueSyncJobRow, table, numberColumn string, pulls bool) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	comments, err := w.fetchGitHubComments(ctx, client, repoOwner, repoName, pulls)
+	if err != nil {
+		return fmt.Errorf("fetch comments: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, table); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchGitHubComments(ctx, tx, table, numberColumn, id, comments); err != nil {
+		return fmt.Errorf("insert comments: %w", err)
+	}
+
+	l.Info().Msgf("inserted comments: %d", len(comments))
+
+	if err := w.mergeStaged(ctx, tx, j, table, int64(len(comments))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,313 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+)
+
+// fetchGitHubEnvironments pages through all the environments of a repo using the GitHub REST API
+func (w *worker) fetchGitHubEnvironments(ctx context.Context, client *github.Client, repoOwner, repoName string) ([]*github.Environment, error) {
+	var environments = make([]*github.Environment, 0)
+
+	opts := &github.EnvironmentListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Repositories.ListEnvironments(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		environments = append(environments, page.Environments...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return environments, nil
+}
+
+// fetchGitHubDeployments pages through all the deployments of a repo using the GitHub REST API
+func (w *worker) fetchGitHubDeployments(ctx context.Context, client *github.Client, repoOwner, repoName string) ([]*github.Deployment, error) {
+	var deployments = make([]*github.Deployment, 0)
+
+	opts := &github.DeploymentsListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Repositories.ListDeployments(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		deployments = append(deployments, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return deployments, nil
+}
+
+// fetchGitHubDeploymentStatuses pages through all the statuses of a deployment using the GitHub REST API
+func (w *worker) fetchGitHubDeploymentStatuses(ctx context.Context, client *github.Client, repoOwner, repoName string, deployment int64) ([]*github.DeploymentStatus, error) {
+	var statuses = make([]*github.DeploymentStatus, 0)
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Repositories.ListDeploymentStatuses(ctx, repoOwner, repoName, deployment, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		statuses = append(statuses, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return statuses, nil
+}
+
+// sendBatchGitHubEnvironments uses the pg COPY protocol to send a batch of GitHub environments
+func (w *worker) sendBatchGitHubEnvironments(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*github.Environment) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"name",
+		"wait_timer",
+		"protection_rule_count",
+		"created_at",
+		"updated_at",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, e := range batch {
+		input := []interface{}{
+			repo,
+			e.ID,
+			e.Name,
+			e.WaitTimer,
+			len(e.ProtectionRules),
+			helper.GetTimeFromTimestamp(e.CreatedAt),
+			helper.GetTimeFromTimestamp(e.UpdatedAt),
+			e.HTMLURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_environments")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendBatchGitHubDeployments uses the pg COPY protocol to send a batch of GitHub deployments
+func (w *worker) sendBatchGitHubDeployments(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*github.Deployment) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"sha",
+		"ref",
+		"task",
+		"environment",
+		"description",
+		"creator_login",
+		"created_at",
+		"updated_at",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, d := range batch {
+		input := []interface{}{
+			repo,
+			d.ID,
+			d.SHA,
+			d.Ref,
+			d.Task,
+			d.Environment,
+			d.Description,
+			helper.GetUserLogin(d.Creator),
+			helper.GetTimeFromTimestamp(d.CreatedAt),
+			helper.GetTimeFromTimestamp(d.UpdatedAt),
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_deployments")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendBatchGitHubDeploymentStatuses uses the pg COPY protocol to send the statuses of a batch of GitHub deployments
+func (w *worker) sendBatchGitHubDeploymentStatuses(ctx context.Context, tx pgx.Tx, repo uuid.UUID, statuses map[int64][]*github.DeploymentStatus) (int, error) {
+	cols := []string{
+		"repo_id",
+		"deployment_id",
+		"id",
+		"state",
+		"description",
+		"environment",
+		"creator_login",
+		"created_at",
+		"updated_at",
+		"environment_url",
+		"log_url",
+	}
+
+	inputs := make([][]interface{}, 0)
+	for deployment, batch := range statuses {
+		for _, s := range batch {
+			input := []interface{}{
+				repo,
+				deployment,
+				s.ID,
+				s.State,
+				s.Description,
+				s.Environment,
+				helper.GetUserLogin(s.Creator),
+				helper.GetTimeFromTimestamp(s.CreatedAt),
+				helper.GetTimeFromTimestamp(s.UpdatedAt),
+				s.EnvironmentURL,
+				s.LogURL,
+			}
+			inputs = append(inputs, input)
+		}
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_deployment_statuses")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return 0, err
+	}
+	return len(inputs), nil
+}
+
+func (w *worker) handleGitHubDeployments(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	environments, err := w.fetchGitHubEnvironments(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch environments: %w", err)
+	}
+
+	deployments, err := w.fetchGitHubDeployments(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch deployments: %w", err)
+	}
+
+	var statuses = make(map[int64][]*github.DeploymentStatus, len(deployments))
+	var progress = w.startProgress(ctx, j, "fetching deployment statuses", int64(len(deployments)))
+	for i, d := range deployments {
+		if statuses[d.GetID()], err = w.fetchGitHubDeploymentStatuses(ctx, client, repoOwner, repoName, d.GetID()); err != nil {
+			return fmt.Errorf("fetch statuses of deployment %d: %w", d.GetID(), err)
+		}
+		progress.set(ctx, int64(i+1))
+	}
+	progress.done(ctx)
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	for _, table := range []string{"github_environments", "github_deployments", "github_deployment_statuses"} {
+		if err = stage(ctx, tx, table); err != nil {
+			return err
+		}
+	}
+
+	if err := w.sendBatchGitHubEnvironments(ctx, tx, id, environments); err != nil {
+		return fmt.Errorf("insert environments: %w", err)
+	}
+
+	if err := w.sendBatchGitHubDeployments(ctx, tx, id, deployments); err != nil {
+		return fmt.Errorf("insert deployments: %w", err)
+	}
+
+	var insertedStatuses int
+	if insertedStatuses, err = w.sendBatchGitHubDeploymentStatuses(ctx, tx, id, statuses); err != nil {
+		return fmt.Errorf("insert deployment statuses: %w", err)
+	}
+
+	l.Info().Msgf("inserted environments: %d, deployments: %d, statuses: %d", len(environments), len(deployments), insertedStatuses)
+
+	// deployments are merged before their statuses, which reference them
+	if err := w.mergeStaged(ctx, tx, j, "github_environments", int64(len(environments))); err != nil {
+		return err
+	}
+	if err := w.mergeStaged(ctx, tx, j, "github_deployments", int64(len(deployments))); err != nil {
+		return err
+	}
+	if err := w.mergeStaged(ctx, tx, j, "github_deployment_statuses", int64(insertedStatuses)); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
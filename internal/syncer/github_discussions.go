@@ -0,0 +1,351 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shurcooL/githubv4"
+)
+
+// githubDiscussionsPerPage is the number of discussions fetched per GraphQL query (at most 100)
+const githubDiscussionsPerPage = 50
+
+// githubActor is the author of a GitHub discussion (or answer), nil if the account was deleted
+type githubActor struct {
+	Login string
+}
+
+// githubDiscussionCategory is a category of the discussions of a GitHub repo, as returned by the GraphQL API
+type githubDiscussionCategory struct {
+	ID           string
+	Name         string
+	Slug         string
+	Description  *string
+	Emoji        string
+	IsAnswerable bool
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// githubDiscussion is a discussion of a GitHub repo, and its chosen answer (if any), as returned by the GraphQL API
+type githubDiscussion struct {
+	ID       string
+	Number   int
+	Title    string
+	Body     string
+	URL      string
+	Author   *githubActor
+	Category struct {
+		ID string
+	}
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Closed         bool
+	ClosedAt       *time.Time
+	Locked         bool
+	UpvoteCount    int
+	AnswerChosenAt *time.Time
+	AnswerChosenBy *githubActor
+	Answer         *struct {
+		ID          string
+		Author      *githubActor
+		CreatedAt   time.Time
+		UpvoteCount int
+	}
+	Comments struct {
+		TotalCount int
+	}
+	Reactions struct {
+		TotalCount int
+	}
+}
+
+// githubRateLimit is the rate limit of the GitHub GraphQL API, queried along with the data of a query
+type githubRateLimit struct {
+	Remaining int
+	ResetAt   time.Time
+}
+
+// fetchGitHubDiscussionCategories returns the discussion categories of a repo (of which there are at most 25) using the GitHub GraphQL API
+func (w *worker) fetchGitHubDiscussionCategories(ctx context.Context, client *githubv4.Client, repoOwner, repoName string) ([]githubDiscussionCategory, error) {
+	var query struct {
+		Repository struct {
+			DiscussionCategories struct {
+				Nodes []githubDiscussionCategory
+			} `graphql:"discussionCategories(first: 100)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+		RateLimit githubRateLimit
+	}
+
+	var variables = map[string]interface{}{"owner": githubv4.String(repoOwner), "name": githubv4.String(repoName)}
+	if err := client.Query(ctx, &query, variables); err != nil {
+		return nil, err
+	}
+
+	helper.RecordGitHubRateLimit("graphql", query.RateLimit.Remaining, query.RateLimit.ResetAt)
+
+	return query.Repository.DiscussionCategories.Nodes, nil
+}
+
+// fetchGitHubDiscussions pages through all the discussions of a repo using the GitHub GraphQL API
+func (w *worker) fetchGitHubDiscussions(ctx context.Context, j *db.DequeueSyncJobRow, client *githubv4.Client, repoOwner, repoName string) ([]githubDiscussion, error) {
+	var query struct {
+		Repository struct {
+			Discussions struct {
+				TotalCount int
+				PageInfo   struct {
+					EndCursor   githubv4.String
+					HasNextPage bool
+				}
+				Nodes []githubDiscussion
+			} `graphql:"discussions(first: $perPage, after: $cursor)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+		RateLimit githubRateLimit
+	}
+
+	var discussions = make([]githubDiscussion, 0)
+	var progress = w.startProgress(ctx, j, "fetching discussions", 0)
+
+	var variables = map[string]interface{}{
+		"owner":   githubv4.String(repoOwner),
+		"name":    githubv4.String(repoName),
+		"perPage": githubv4.Int(githubDiscussionsPerPage),
+		"cursor":  (*githubv4.String)(nil),
+	}
+	for {
+		if err := client.Query(ctx, &query, variables); err != nil {
+			return nil, err
+		}
+
+		helper.RecordGitHubRateLimit("graphql", query.RateLimit.Remaining, query.RateLimit.ResetAt)
+
+		discussions = append(discussions, query.Repository.Discussions.Nodes...)
+
+		progress.setTotal(int64(query.Repository.Discussions.TotalCount))
+		progress.set(ctx, int64(len(discussions)))
+
+		if !query.Repository.Discussions.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(query.Repository.Discussions.PageInfo.EndCursor)
+	}
+
+	progress.done(ctx)
+	return discussions, nil
+}
+
+// sendBatchGitHubDiscussionCategories uses the pg COPY protocol to send a batch of GitHub discussion categories
+func (w *worker) sendBatchGitHubDiscussionCategories(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []githubDiscussionCategory) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"name",
+		"slug",
+		"description",
+		"emoji",
+		"is_answerable",
+		"created_at",
+		"updated_at",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, c := range batch {
+		input := []interface{}{
+			repo,
+			c.ID,
+			c.Name,
+			c.Slug,
+			c.Description,
+			c.Emoji,
+			c.IsAnswerable,
+			c.CreatedAt,
+			c.UpdatedAt,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_discussion_categories")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendBatchGitHubDiscussions uses the pg COPY protocol to send a batch of GitHub discussions
+func (w *worker) sendBatchGitHubDiscussions(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []githubDiscussion) error {
+	cols := []string{
+		"repo_id",
+		"number",
+		"id",
+		"title",
+		"body",
+		"author_login",
+		"category_id",
+		"created_at",
+		"updated_at",
+		"closed",
+		"closed_at",
+		"locked",
+		"upvote_count",
+		"comment_count",
+		"reaction_count",
+		"answer_id",
+		"answer_author_login",
+		"answer_created_at",
+		"answer_upvote_count",
+		"answer_chosen_at",
+		"answer_chosen_by_login",
+		"url",
+	}
+
+	// the login of an actor, nil if the account was deleted (or the discussion isn't answered)
+	var login = func(a *githubActor) *string {
+		if a == nil {
+			return nil
+		}
+		return &a.Login
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, d := range batch {
+		var answerID, answerAuthor *string
+		var answerCreatedAt *time.Time
+		var answerUpvotes *int
+		if a := d.Answer; a != nil {
+			answerID, answerAuthor, answerCreatedAt, answerUpvotes = &a.ID, login(a.Author), &a.CreatedAt, &a.UpvoteCount
+		}
+
+		input := []interface{}{
+			repo,
+			d.Number,
+			d.ID,
+			d.Title,
+			d.Body,
+			login(d.Author),
+			nullIfEmpty(d.Category.ID),
+			d.CreatedAt,
+			d.UpdatedAt,
+			d.Closed,
+			d.ClosedAt,
+			d.Locked,
+			d.UpvoteCount,
+			d.Comments.TotalCount,
+			d.Reactions.TotalCount,
+			answerID,
+			answerAuthor,
+			answerCreatedAt,
+			answerUpvotes,
+			d.AnswerChosenAt,
+			login(d.AnswerChosenBy),
+			d.URL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_discussions")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// handleGitHubDiscussions syncs the discussion categories and discussions (with their answers) of a repo. Discussions
+// are only exposed by the GraphQL API of GitHub, repos that don't have them enabled have none.
+func (w *worker) handleGitHubDiscussions(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	// the GraphQL API of GitHub doesn't allow unauthenticated requests
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *githubv4.Client
+	if client, err = w.newGitHubGraphQLClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	categories, err := w.fetchGitHubDiscussionCategories(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch discussion categories: %w", err)
+	}
+
+	discussions, err := w.fetchGitHubDiscussions(ctx, j, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch discussions: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	for _, table := range []string{"github_discussion_categories", "github_discussions"} {
+		if err = stage(ctx, tx, table); err != nil {
+			return err
+		}
+	}
+
+	if err := w.sendBatchGitHubDiscussionCategories(ctx, tx, id, categories); err != nil {
+		return fmt.Errorf("insert discussion categories: %w", err)
+	}
+
+	if err := w.sendBatchGitHubDiscussions(ctx, tx, id, discussions); err != nil {
+		return fmt.Errorf("insert discussions: %w", err)
+	}
+
+	l.Info().Msgf("inserted discussion categories: %d, discussions: %d", len(categories), len(discussions))
+
+	if err := w.mergeStaged(ctx, tx, j, "github_discussion_categories", int64(len(categories))); err != nil {
+		return err
+	}
+	if err := w.mergeStaged(ctx, tx, j, "github_discussions", int64(len(discussions))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
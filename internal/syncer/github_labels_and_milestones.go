@@ -0,0 +1,322 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+)
+
+// githubIssueLabel is a label of an issue (or pull request, which GitHub lists among the issues of a repo)
+type githubIssueLabel struct {
+	Number      int
+	PullRequest bool
+	Label       *github.Label
+}
+
+// fetchGitHubLabels pages through all the labels of a repo using the GitHub REST API
+func (w *worker) fetchGitHubLabels(ctx context.Context, client *github.Client, repoOwner, repoName string) ([]*github.Label, error) {
+	var labels = make([]*github.Label, 0)
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Issues.ListLabels(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		labels = append(labels, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return labels, nil
+}
+
+// fetchGitHubMilestones pages through all the milestones (open and closed) of a repo using the GitHub REST API
+func (w *worker) fetchGitHubMilestones(ctx context.Context, client *github.Client, repoOwner, repoName string) ([]*github.Milestone, error) {
+	var milestones = make([]*github.Milestone, 0)
+
+	opts := &github.MilestoneListOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Issues.ListMilestones(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		milestones = append(milestones, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return milestones, nil
+}
+
+// fetchGitHubIssueLabels pages through all the issues (and pull requests) of a repo using the GitHub REST API
+// and collects the labels of each
+func (w *worker) fetchGitHubIssueLabels(ctx context.Context, j *db.DequeueSyncJobRow, client *github.Client, repoOwner, repoName string) ([]githubIssueLabel, error) {
+	var labels = make([]githubIssueLabel, 0)
+	var progress = w.startProgress(ctx, j, "fetching issue labels", 0)
+
+	var fetched int64
+	opts := &github.IssueListByRepoOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		issues, resp, err := client.Issues.ListByRepo(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		for _, issue := range issues {
+			for _, label := range issue.Labels {
+				if label != nil {
+					labels = append(labels, githubIssueLabel{Number: issue.GetNumber(), PullRequest: issue.IsPullRequest(), Label: label})
+				}
+			}
+		}
+
+		// the number of issues is estimated from the number of pages (reported on all but the last page)
+		fetched += int64(len(issues))
+		if resp.LastPage > 0 {
+			progress.setTotal(int64(resp.LastPage * opts.PerPage))
+		}
+		progress.set(ctx, fetched)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	progress.done(ctx)
+	return labels, nil
+}
+
+// sendBatchGitHubLabels uses the pg COPY protocol to send a batch of GitHub labels
+func (w *worker) sendBatchGitHubLabels(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*github.Label) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"name",
+		"color",
+		"description",
+		"is_default",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, l := range batch {
+		input := []interface{}{
+			repo,
+			l.ID,
+			l.Name,
+			l.Color,
+			l.Description,
+			l.GetDefault(),
+			l.URL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_labels")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendBatchGitHubMilestones uses the pg COPY protocol to send a batch of GitHub milestones
+func (w *worker) sendBatchGitHubMilestones(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*github.Milestone) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"number",
+		"title",
+		"description",
+		"state",
+		"creator_login",
+		"open_issues",
+		"closed_issues",
+		"created_at",
+		"updated_at",
+		"closed_at",
+		"due_on",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, m := range batch {
+		input := []interface{}{
+			repo,
+			m.ID,
+			m.Number,
+			m.Title,
+			m.Description,
+			m.State,
+			helper.GetUserLogin(m.Creator),
+			m.OpenIssues,
+			m.ClosedIssues,
+			helper.GetTimeFromTimestamp(m.CreatedAt),
+			helper.GetTimeFromTimestamp(m.UpdatedAt),
+			helper.GetTimeFromTimestamp(m.ClosedAt),
+			helper.GetTimeFromTimestamp(m.DueOn),
+			m.HTMLURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_milestones")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendBatchGitHubIssueLabels uses the pg COPY protocol to send a batch of the labels of GitHub issues
+func (w *worker) sendBatchGitHubIssueLabels(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []githubIssueLabel) error {
+	cols := []string{
+		"repo_id",
+		"issue_number",
+		"label_id",
+		"label_name",
+		"pull_request",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, l := range batch {
+		input := []interface{}{
+			repo,
+			l.Number,
+			l.Label.ID,
+			l.Label.Name,
+			l.PullRequest,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_issue_labels")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleGitHubLabelsAndMilestones(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	labels, err := w.fetchGitHubLabels(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch labels: %w", err)
+	}
+
+	milestones, err := w.fetchGitHubMilestones(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch milestones: %w", err)
+	}
+
+	issueLabels, err := w.fetchGitHubIssueLabels(ctx, j, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch issue labels: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	for _, table := range []string{"github_labels", "github_milestones", "github_issue_labels"} {
+		if err = stage(ctx, tx, table); err != nil {
+			return err
+		}
+	}
+
+	if err := w.sendBatchGitHubLabels(ctx, tx, id, labels); err != nil {
+		return fmt.Errorf("insert labels: %w", err)
+	}
+
+	if err := w.sendBatchGitHubMilestones(ctx, tx, id, milestones); err != nil {
+		return fmt.Errorf("insert milestones: %w", err)
+	}
+
+	if err := w.sendBatchGitHubIssueLabels(ctx, tx, id, issueLabels); err != nil {
+		return fmt.Errorf("insert issue labels: %w", err)
+	}
+
+	l.Info().Msgf("inserted labels: %d, milestones: %d, issue labels: %d", len(labels), len(milestones), len(issueLabels))
+
+	if err := w.mergeStaged(ctx, tx, j, "github_labels", int64(len(labels))); err != nil {
+		return err
+	}
+	if err := w.mergeStaged(ctx, tx, j, "github_milestones", int64(len(milestones))); err != nil {
+		return err
+	}
+	if err := w.mergeStaged(ctx, tx, j, "github_issue_labels", int64(len(issueLabels))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
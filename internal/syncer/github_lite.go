@@ -0,0 +1,53 @@
+package syncer
+
+import (
+	"context"
+	"sync/atomic"
+
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/shurcooL/githubv4"
+)
+
+// mergestat-lite's GitHub tables (github_stargazers, github_repo_issues, ...) get their client from a single getter (see
+// cmd/worker) that knows nothing of the query it's called for. Queries of jobs on those tables are therefore run one at
+// a time, each with a client for the repo of its job: authenticated with the repo's credential, and talking to the
+// GitHub installation of the repo's provider. GitHub requests are serialized by the worker anyway (to avoid its secondary
+// rate limits), so running the queries one at a time doesn't make syncs any slower.
+var (
+	// githubLiteSlot is held by the job whose query is running
+	githubLiteSlot = make(chan struct{}, 1)
+	// githubLiteClient is the *githubv4.Client of the job holding githubLiteSlot
+	githubLiteClient atomic.Value
+)
+
+// GitHubClient returns the client of the job whose mergestat-lite GitHub query is running, and is used as the GitHub
+// client getter of mergestat-lite. Outside of such a query, an unauthenticated client (of github.com) is returned.
+func GitHubClient() *githubv4.Client {
+	if client, ok := githubLiteClient.Load().(*githubv4.Client); ok && client != nil {
+		return client
+	}
+	return helper.NewGitHubGraphQLClient(context.Background(), "", "")
+}
+
+// selectGitHub runs a mergestat query on its GitHub tables for the given job (as selectMergestat), with a client of the
+// job's repo authenticated with the given token
+func (w *worker) selectGitHub(ctx context.Context, j *db.DequeueSyncJobRow, ghToken string, dest interface{}, query string, args ...interface{}) error {
+	client, err := w.newGitHubGraphQLClient(ctx, j, ghToken)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case githubLiteSlot <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() {
+		githubLiteClient.Store((*githubv4.Client)(nil))
+		<-githubLiteSlot
+	}()
+
+	githubLiteClient.Store(client)
+	return w.selectMergestat(ctx, dest, query, args...)
+}
@@ -0,0 +1,241 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+)
+
+// githubOrgMember is a member (or outside collaborator) of an org
+type githubOrgMember struct {
+	Login string
+	Role  string // admin (i.e. owner), member or outside_collaborator
+}
+
+// githubOrgTeam is a team of an org, along with its members
+type githubOrgTeam struct {
+	Team    *github.Team
+	Members []*githubOrgMember // whose role is either maintainer or member
+}
+
+// githubOrgPeople are the people of an org: its members, outside collaborators and teams
+type githubOrgPeople struct {
+	Members []*githubOrgMember
+	Teams   []*githubOrgTeam
+}
+
+// fetchGitHubOrgPeople fetches the members (by role), outside collaborators and teams (and their members) of an org
+// using the GitHub REST API
+func (w *worker) fetchGitHubOrgPeople(ctx context.Context, client *github.Client, org string) (*githubOrgPeople, error) {
+	var people githubOrgPeople
+
+	for _, role := range []string{"admin", "member"} {
+		opts := &github.ListMembersOptions{Role: role, ListOptions: github.ListOptions{PerPage: 100}}
+		for {
+			page, resp, err := client.Organizations.ListMembers(ctx, org, opts)
+			if err != nil {
+				return nil, fmt.Errorf("list members: %w", err)
+			}
+
+			helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+			for _, u := range page {
+				people.Members = append(people.Members, &githubOrgMember{Login: u.GetLogin(), Role: role})
+			}
+
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
+	}
+
+	collaboratorOpts := &github.ListOutsideCollaboratorsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Organizations.ListOutsideCollaborators(ctx, org, collaboratorOpts)
+		if err != nil {
+			return nil, fmt.Errorf("list outside collaborators: %w", err)
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		for _, u := range page {
+			people.Members = append(people.Members, &githubOrgMember{Login: u.GetLogin(), Role: "outside_collaborator"})
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		collaboratorOpts.Page = resp.NextPage
+	}
+
+	teamOpts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Teams.ListTeams(ctx, org, teamOpts)
+		if err != nil {
+			return nil, fmt.Errorf("list teams: %w", err)
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		for _, t := range page {
+			people.Teams = append(people.Teams, &githubOrgTeam{Team: t})
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		teamOpts.Page = resp.NextPage
+	}
+
+	for _, t := range people.Teams {
+		for _, role := range []string{"maintainer", "member"} {
+			opts := &github.TeamListTeamMembersOptions{Role: role, ListOptions: github.ListOptions{PerPage: 100}}
+			for {
+				page, resp, err := client.Teams.ListTeamMembersBySlug(ctx, org, t.Team.GetSlug(), opts)
+				if err != nil {
+					return nil, fmt.Errorf("list members of team %s: %w", t.Team.GetSlug(), err)
+				}
+
+				helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+				for _, u := range page {
+					t.Members = append(t.Members, &githubOrgMember{Login: u.GetLogin(), Role: role})
+				}
+
+				if resp.NextPage == 0 {
+					break
+				}
+				opts.Page = resp.NextPage
+			}
+		}
+	}
+
+	return &people, nil
+}
+
+// replaceGitHubOrgPeople replaces the members, outside collaborators and teams of an org with the given ones
+func (w *worker) replaceGitHubOrgPeople(ctx context.Context, tx pgx.Tx, org string, people *githubOrgPeople) error {
+	// the people of an org are synced by the syncs of all its repos, which can run concurrently
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('github_org_people:' || lower($1)))", org); err != nil {
+		return fmt.Errorf("lock org: %w", err)
+	}
+
+	for _, table := range []string{"github_org_team_members", "github_org_teams", "github_org_members"} {
+		if _, err := tx.Exec(ctx, fmt.Sprintf("DELETE FROM %s WHERE lower(org) = lower($1)", pgx.Identifier{table}.Sanitize()), org); err != nil {
+			return fmt.Errorf("delete %s: %w", table, err)
+		}
+	}
+
+	var members = make([][]interface{}, 0, len(people.Members))
+	for _, m := range people.Members {
+		members = append(members, []interface{}{org, m.Login, m.Role})
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"github_org_members"}, []string{"org", "login", "role"}, pgx.CopyFromRows(members)); err != nil {
+		return fmt.Errorf("insert members: %w", err)
+	}
+
+	var teams, teamMembers = make([][]interface{}, 0, len(people.Teams)), make([][]interface{}, 0)
+	for _, t := range people.Teams {
+		teams = append(teams, []interface{}{org, t.Team.ID, t.Team.Slug, t.Team.Name, t.Team.Description, t.Team.Privacy, t.Team.Parent.GetSlug(), t.Team.HTMLURL})
+		for _, m := range t.Members {
+			teamMembers = append(teamMembers, []interface{}{org, t.Team.ID, m.Login, m.Role})
+		}
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"github_org_teams"}, []string{"org", "id", "slug", "name", "description", "privacy", "parent_slug", "url"}, pgx.CopyFromRows(teams)); err != nil {
+		return fmt.Errorf("insert teams: %w", err)
+	}
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"github_org_team_members"}, []string{"org", "team_id", "login", "role"}, pgx.CopyFromRows(teamMembers)); err != nil {
+		return fmt.Errorf("insert team members: %w", err)
+	}
+
+	return nil
+}
+
+// handleGitHubOrgMembers syncs the people of the org owning a repo: its members, outside collaborators and teams.
+// Repos owned by users have no org, and nothing to sync.
+func (w *worker) handleGitHubOrgMembers(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	var org string
+	if org, _, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	owner, _, err := client.Users.Get(ctx, org)
+	if err != nil {
+		return fmt.Errorf("get owner: %w", err)
+	}
+
+	var people *githubOrgPeople
+	if owner.GetType() != "Organization" {
+		if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+			Message: fmt.Sprintf("%s is not an org, there are no members to sync", org),
+		}}); err != nil {
+			return fmt.Errorf("send batch log messages: %w", err)
+		}
+	} else if people, err = w.fetchGitHubOrgPeople(ctx, client, org); err != nil {
+		return fmt.Errorf("fetch org people: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if people != nil {
+		if err := w.replaceGitHubOrgPeople(ctx, tx, org, people); err != nil {
+			return err
+		}
+
+		l.Info().Msgf("inserted org members: %d, teams: %d", len(people.Members), len(people.Teams))
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,387 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+	"github.com/shurcooL/githubv4"
+)
+
+// githubPackageTypes are the types of the packages of GitHub Packages, which are listed one type at a time
+var githubPackageTypes = []string{"container", "docker", "npm", "maven", "rubygems", "nuget"}
+
+// githubPackage is a package (published from a repo) of GitHub Packages, and its versions
+type githubPackage struct {
+	Package  *github.Package
+	Versions []*github.PackageVersion
+}
+
+// githubPackageStatistics are the downloads and size of a package (or package version), only reported by the GraphQL
+// API for the registries other than the container registry (ghcr.io)
+type githubPackageStatistics struct {
+	Downloads int64
+	Size      int64
+}
+
+// fetchGitHubPackages returns the packages of the owner of a repo (an org or a user) that are published from the repo,
+// and their versions, using the GitHub REST API
+func (w *worker) fetchGitHubPackages(ctx context.Context, client *github.Client, repoOwner, repoName string, org bool) ([]*githubPackage, error) {
+	var packages = make([]*githubPackage, 0)
+
+	for _, packageType := range githubPackageTypes {
+		opts := &github.PackageListOptions{PackageType: github.String(packageType), ListOptions: github.ListOptions{PerPage: 100}}
+		for {
+			var page []*github.Package
+			var resp *github.Response
+			var err error
+			if org {
+				page, resp, err = client.Organizations.ListPackages(ctx, repoOwner, opts)
+			} else {
+				page, resp, err = client.Users.ListPackages(ctx, repoOwner, opts)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("list %s packages: %w", packageType, err)
+			}
+
+			helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+			for _, p := range page {
+				if strings.EqualFold(p.GetRepository().GetName(), repoName) {
+					packages = append(packages, &githubPackage{Package: p})
+				}
+			}
+
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
+	}
+
+	for _, p := range packages {
+		opts := &github.PackageListOptions{State: github.String("active"), ListOptions: github.ListOptions{PerPage: 100}}
+		for {
+			var page []*github.PackageVersion
+			var resp *github.Response
+			var err error
+			if org {
+				page, resp, err = client.Organizations.PackageGetAllVersions(ctx, repoOwner, p.Package.GetPackageType(), p.Package.GetName(), opts)
+			} else {
+				page, resp, err = client.Users.PackageGetAllVersions(ctx, repoOwner, p.Package.GetPackageType(), p.Package.GetName(), opts)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("list versions of package %s: %w", p.Package.GetName(), err)
+			}
+
+			helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+			p.Versions = append(p.Versions, page...)
+
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
+	}
+
+	return packages, nil
+}
+
+// githubPackageKey is the key of the statistics of a package (or of a version, given one) of a repo, as the REST and
+// GraphQL APIs of GitHub share neither ids nor the case of package types
+func githubPackageKey(packageType, name, version string) string {
+	return strings.ToLower(packageType) + "/" + name + "@" + version
+}
+
+// fetchGitHubPackageStatistics returns the downloads and sizes of the packages (and package versions) of a repo using the
+// GitHub GraphQL API, keyed by githubPackageKey. Only the latest 100 versions of a package are reported.
+func (w *worker) fetchGitHubPackageStatistics(ctx context.Context, client *githubv4.Client, repoOwner, repoName string) (map[string]*githubPackageStatistics, error) {
+	var query struct {
+		Repository struct {
+			Packages struct {
+				PageInfo struct {
+					EndCursor   githubv4.String
+					HasNextPage bool
+				}
+				Nodes []struct {
+					Name        string
+					PackageType string
+					Statistics  *struct {
+						DownloadsTotalCount int64
+					}
+					Versions struct {
+						Nodes []struct {
+							Version    string
+							Statistics *struct {
+								DownloadsTotalCount int64
+							}
+							Files struct {
+								Nodes []struct {
+									Size *int64
+								}
+							} `graphql:"files(first: 50)"`
+						}
+					} `graphql:"versions(first: 100)"`
+				}
+			} `graphql:"packages(first: 20, after: $cursor)"`
+		} `graphql:"repository(owner: $owner, name: $name)"`
+		RateLimit githubRateLimit
+	}
+
+	var statistics = make(map[string]*githubPackageStatistics)
+	var variables = map[string]interface{}{
+		"owner":  githubv4.String(repoOwner),
+		"name":   githubv4.String(repoName),
+		"cursor": (*githubv4.String)(nil),
+	}
+	for {
+		if err := client.Query(ctx, &query, variables); err != nil {
+			return nil, err
+		}
+
+		helper.RecordGitHubRateLimit("graphql", query.RateLimit.Remaining, query.RateLimit.ResetAt)
+
+		for _, p := range query.Repository.Packages.Nodes {
+			var total = &githubPackageStatistics{}
+			if p.Statistics != nil {
+				total.Downloads = p.Statistics.DownloadsTotalCount
+			}
+
+			for _, v := range p.Versions.Nodes {
+				var version = &githubPackageStatistics{}
+				if v.Statistics != nil {
+					version.Downloads = v.Statistics.DownloadsTotalCount
+				}
+				for _, f := range v.Files.Nodes {
+					if f.Size != nil {
+						version.Size += *f.Size
+					}
+				}
+				total.Size += version.Size
+				statistics[githubPackageKey(p.PackageType, p.Name, v.Version)] = version
+			}
+
+			statistics[githubPackageKey(p.PackageType, p.Name, "")] = total
+		}
+
+		if !query.Repository.Packages.PageInfo.HasNextPage {
+			break
+		}
+		variables["cursor"] = githubv4.NewString(query.Repository.Packages.PageInfo.EndCursor)
+	}
+
+	return statistics, nil
+}
+
+// sendBatchGitHubPackages uses the pg COPY protocol to send a batch of GitHub packages
+func (w *worker) sendBatchGitHubPackages(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*githubPackage, statistics map[string]*githubPackageStatistics) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"name",
+		"package_type",
+		"visibility",
+		"version_count",
+		"download_count",
+		"size",
+		"owner_login",
+		"created_at",
+		"updated_at",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, p := range batch {
+		var downloads, size *int64
+		if s, ok := statistics[githubPackageKey(p.Package.GetPackageType(), p.Package.GetName(), "")]; ok {
+			downloads, size = &s.Downloads, &s.Size
+		}
+
+		input := []interface{}{
+			repo,
+			p.Package.ID,
+			p.Package.Name,
+			p.Package.PackageType,
+			p.Package.Visibility,
+			p.Package.VersionCount,
+			downloads,
+			size,
+			helper.GetUserLogin(p.Package.Owner),
+			helper.GetTimeFromTimestamp(p.Package.CreatedAt),
+			helper.GetTimeFromTimestamp(p.Package.UpdatedAt),
+			p.Package.HTMLURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_packages")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendBatchGitHubPackageVersions uses the pg COPY protocol to send the versions of a batch of GitHub packages
+func (w *worker) sendBatchGitHubPackageVersions(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*githubPackage, statistics map[string]*githubPackageStatistics) (int, error) {
+	cols := []string{
+		"repo_id",
+		"package_id",
+		"id",
+		"name",
+		"tags",
+		"download_count",
+		"size",
+		"created_at",
+		"updated_at",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0)
+	for _, p := range batch {
+		for _, v := range p.Versions {
+			// the tags of a container image, e.g. latest or 1.2.3 (the name of its versions being their digest)
+			var tags = make([]string, 0)
+			if m := v.GetMetadata(); m != nil && m.Container != nil {
+				tags = m.Container.Tags
+			}
+
+			var downloads, size *int64
+			if s, ok := statistics[githubPackageKey(p.Package.GetPackageType(), p.Package.GetName(), v.GetName())]; ok {
+				downloads, size = &s.Downloads, &s.Size
+			}
+
+			input := []interface{}{
+				repo,
+				p.Package.ID,
+				v.ID,
+				v.Name,
+				tags,
+				downloads,
+				size,
+				helper.GetTimeFromTimestamp(v.CreatedAt),
+				helper.GetTimeFromTimestamp(v.UpdatedAt),
+				v.HTMLURL,
+			}
+			inputs = append(inputs, input)
+		}
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_package_versions")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return 0, err
+	}
+	return len(inputs), nil
+}
+
+func (w *worker) handleGitHubPackages(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	var graphql *githubv4.Client
+	if graphql, err = w.newGitHubGraphQLClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	// packages belong to the owner of a repo (rather than the repo itself), and are listed through the org or user owning it
+	owner, _, err := client.Users.Get(ctx, repoOwner)
+	if err != nil {
+		return fmt.Errorf("get owner: %w", err)
+	}
+
+	packages, err := w.fetchGitHubPackages(ctx, client, repoOwner, repoName, owner.GetType() == "Organization")
+	if err != nil {
+		return fmt.Errorf("fetch packages: %w", err)
+	}
+
+	statistics, err := w.fetchGitHubPackageStatistics(ctx, graphql, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch package statistics: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	for _, table := range []string{"github_packages", "github_package_versions"} {
+		if err = stage(ctx, tx, table); err != nil {
+			return err
+		}
+	}
+
+	if err := w.sendBatchGitHubPackages(ctx, tx, id, packages, statistics); err != nil {
+		return fmt.Errorf("insert packages: %w", err)
+	}
+
+	var insertedVersions int
+	if insertedVersions, err = w.sendBatchGitHubPackageVersions(ctx, tx, id, packages, statistics); err != nil {
+		return fmt.Errorf("insert package versions: %w", err)
+	}
+
+	l.Info().Msgf("inserted packages: %d, versions: %d", len(packages), insertedVersions)
+
+	// packages are merged before their versions, which reference them
+	if err := w.mergeStaged(ctx, tx, j, "github_packages", int64(len(packages))); err != nil {
+		return err
+	}
+	if err := w.mergeStaged(ctx, tx, j, "github_package_versions", int64(insertedVersions)); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -71,7 +71,7 @@ func (w *worker) sendBatchGitHubPRCommits(ctx context.Context, tx pgx.Tx, repo u
 		inputs = append(inputs, input)
 	}
 
-	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"github_pull_request_commits"}, cols, pgx.CopyFromRows(inputs)); err != nil {
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_pull_request_commits")}, cols, pgx.CopyFromRows(inputs)); err != nil {
 		return fmt.Errorf("tx copy from: %w", err)
 	}
 	return nil
@@ -111,14 +111,14 @@ func (w *worker) handleGitHubPRCommits(ctx context.Context, j *db.DequeueSyncJob
 	repoFullName := fmt.Sprintf("%s/%s", repoOwner, repoName)
 
 	commits := make([]*githubPRCommit, 0)
-	if err := w.mergestat.SelectContext(ctx, &commits, selectGitHubPRCommits, repoFullName, repoFullName); err != nil {
+	if err := w.selectGitHub(ctx, j, ghToken, &commits, selectGitHubPRCommits, repoFullName, repoFullName); err != nil {
 		return fmt.Errorf("mergestat select: %w", err)
 	}
 
 	l.Info().Msgf("retrieved PR commits: %d", len(commits))
 
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer func() {
@@ -129,16 +129,7 @@ func (w *worker) handleGitHubPRCommits(ctx context.Context, j *db.DequeueSyncJob
 		}
 	}()
 
-	r, err := tx.Exec(ctx, "DELETE FROM github_pull_request_commits WHERE repo_id = $1;", j.RepoID.String())
-	if err != nil {
-		return fmt.Errorf("exec delete: %w", err)
-	}
-
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("removed %d row(s) from github_pull_request_commits", r.RowsAffected()),
-	}}); err != nil {
+	if err = stage(ctx, tx, "github_pull_request_commits"); err != nil {
 		return err
 	}
 
@@ -146,11 +137,7 @@ func (w *worker) handleGitHubPRCommits(ctx context.Context, j *db.DequeueSyncJob
 		return fmt.Errorf("insert pr commits: %w", err)
 	}
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("inserted %d row(s) into github_pull_request_commits", len(commits)),
-	}}); err != nil {
+	if err := w.mergeStaged(ctx, tx, j, "github_pull_request_commits", int64(len(commits))); err != nil {
 		return err
 	}
 
@@ -82,7 +82,7 @@ func (w *worker) sendBatchGitHubPRReviews(ctx context.Context, tx pgx.Tx, repo u
 		inputs = append(inputs, input)
 	}
 
-	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"github_pull_request_reviews"}, cols, pgx.CopyFromRows(inputs)); err != nil {
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_pull_request_reviews")}, cols, pgx.CopyFromRows(inputs)); err != nil {
 		return err
 	}
 	return nil
@@ -123,12 +123,12 @@ func (w *worker) handleGitHubPRReviews(ctx context.Context, j *db.DequeueSyncJob
 	repoFullName := fmt.Sprintf("%s/%s", repoOwner, repoName)
 
 	reviews := make([]*githubPRReview, 0)
-	if err = w.mergestat.SelectContext(ctx, &reviews, selectGitHubPRReviews, repoFullName, repoFullName); err != nil {
+	if err = w.selectGitHub(ctx, j, ghToken, &reviews, selectGitHubPRReviews, repoFullName, repoFullName); err != nil {
 		return fmt.Errorf("mergestat query: %w", err)
 	}
 
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer func() {
@@ -139,16 +139,7 @@ func (w *worker) handleGitHubPRReviews(ctx context.Context, j *db.DequeueSyncJob
 		}
 	}()
 
-	r, err := tx.Exec(ctx, "DELETE FROM github_pull_request_reviews WHERE repo_id = $1;", j.RepoID.String())
-	if err != nil {
-		return fmt.Errorf("delete rows: %w", err)
-	}
-
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("removed %d row(s) from github_pull_request_reviews", r.RowsAffected()),
-	}}); err != nil {
+	if err = stage(ctx, tx, "github_pull_request_reviews"); err != nil {
 		return err
 	}
 
@@ -158,11 +149,7 @@ func (w *worker) handleGitHubPRReviews(ctx context.Context, j *db.DequeueSyncJob
 
 	l.Info().Msgf("retrieved PR reviews: %d", len(reviews))
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("inserted %d row(s) into github_pull_request_reviews", len(reviews)),
-	}}); err != nil {
+	if err := w.mergeStaged(ctx, tx, j, "github_pull_request_reviews", int64(len(reviews))); err != nil {
 		return err
 	}
 
@@ -6,8 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
-	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -17,7 +15,6 @@ import (
 	"github.com/mergestat/mergestat/internal/helper"
 	"github.com/mergestat/mergestat/queries"
 	uuid "github.com/satori/go.uuid"
-	"golang.org/x/oauth2"
 )
 
 func (w *worker) handleGitHubRepoPRsAndCommits(ctx context.Context, j *db.DequeueSyncJobRow) error {
@@ -52,30 +49,122 @@ func (w *worker) handleGitHubRepoPRsAndCommits(ctx context.Context, j *db.Dequeu
 	repoOwner := components[1]
 	repoName := components[2]
 
-	prsToInsert := make([]*githubRepoPR, 0)
+	client, err := w.newGitHubClient(ctx, j, ghToken)
+	if err != nil {
+		return err
+	}
 
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: ghToken},
-	)
+	var perPage = w.config.GitHubPerPage
 
-	tc := oauth2.NewClient(ctx, ts)
-	if len(ghToken) <= 0 {
-		tc = nil
+	// resume from the progress saved by a previous (interrupted) attempt of this job, if any
+	var state githubRepoPRsCheckpoint
+	cp, resumed, err := w.loadCheckpoint(ctx, j, &state)
+	if err != nil {
+		return fmt.Errorf("load checkpoint: %w", err)
 	}
 
-	client := github.NewClient(tc)
+	if resumed {
+		l.Info().Msgf("resuming repo PRs sync from checkpoint (%d PR(s) listed, %d fetched)", state.PRsListed, state.PRsFetched)
+	}
+
+	if err = w.fetchGitHubRepoPRsAndCommits(ctx, client, repoOwner, repoName, perPage, cp, &state); err != nil {
+		return cp.saveOnError(err)
+	}
 
-	var perPage = 50 // match the default used by mergestat-lite
-	if perPageEnv := os.Getenv("GITHUB_PER_PAGE"); perPageEnv != "" {
-		if perPage, err = strconv.Atoi(perPageEnv); err != nil {
-			l.Error().Err(err).Msg("could not parse GITHUB_PER_PAGE")
+	var prsToInsert []*githubRepoPR
+	if prsToInsert, err = checkpointRows[*githubRepoPR](ctx, cp, checkpointRowsPRs); err != nil {
+		return fmt.Errorf("load checkpoint rows: %w", err)
+	}
+
+	var allPRCommitsToInsert []*githubPRCommit
+	if allPRCommitsToInsert, err = checkpointRows[*githubPRCommit](ctx, cp, checkpointRowsPRCommits); err != nil {
+		return fmt.Errorf("load checkpoint rows: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
 		}
+	}()
+
+	if err = stage(ctx, tx, "github_pull_requests"); err != nil {
+		return err
+	}
+	if err = stage(ctx, tx, "github_pull_request_commits"); err != nil {
+		return err
 	}
 
-	opt := &github.ListOptions{PerPage: perPage}
-	allPRs := make([]*github.PullRequest, 0)
+	// Insert all rows into github_pull_requests and github_pull_request_commits
+	if err := w.sendBatchGitHubRepoPRs(ctx, tx, id, prsToInsert); err != nil {
+		return fmt.Errorf("insert PRs: %w", err)
+	}
 
-	for {
+	l.Info().Msgf("inserted repo PRs: %d", len(prsToInsert))
+
+	if err := w.mergeStaged(ctx, tx, j, "github_pull_requests", int64(len(prsToInsert))); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchGitHubPRCommits(ctx, tx, id, allPRCommitsToInsert); err != nil {
+		return fmt.Errorf("insert pr commits: %w", err)
+	}
+
+	if err := w.mergeStaged(ctx, tx, j, "github_pull_request_commits", int64(len(allPRCommitsToInsert))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).DeleteSyncJobCheckpoint(ctx, j.ID); err != nil {
+		return fmt.Errorf("delete checkpoint: %w", err)
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// kinds of the rows a GitHub repo PRs (and commits) sync stages in its checkpoint
+const (
+	checkpointRowsPRNumbers = "numbers" // numbers of the PRs listed, in the order they were listed
+	checkpointRowsPRs       = "prs"     // PRs fetched, in the order they were listed
+	checkpointRowsPRCommits = "commits" // PR commits fetched
+)
+
+// githubRepoPRsCheckpoint is the progress of a GitHub repo PRs (and commits) sync, saved so that an interrupted sync resumes where it left off
+type githubRepoPRsCheckpoint struct {
+	ListPage    int  `json:"listPage"`    // next page of PRs to list
+	Listed      bool `json:"listed"`      // whether all the PRs were listed
+	PRsListed   int  `json:"prsListed"`   // number of PRs listed so far
+	PRsFetched  int  `json:"prsFetched"`  // number of PRs fetched so far, in the order they were listed
+	CommitsPR   int  `json:"commitsPR"`   // index (into the listed PRs) of the PR commits are being fetched of
+	CommitsPage int  `json:"commitsPage"` // next page of commits to fetch of that PR
+}
+
+// fetchGitHubRepoPRsAndCommits lists all the PRs of a repo, and fetches each of them along with their commits using the
+// GitHub REST API, picking up from (and periodically saving) the progress kept in the checkpoint state. The PRs and
+// commits fetched are staged in the checkpoint.
+func (w *worker) fetchGitHubRepoPRsAndCommits(ctx context.Context, client *github.Client, repoOwner, repoName string, perPage int, cp *checkpoint, state *githubRepoPRsCheckpoint) error {
+	var listing *progressReporter
+	if !state.Listed {
+		listing = w.startProgress(ctx, cp.job, "listing pull requests", 0)
+	}
+
+	opt := &github.ListOptions{PerPage: perPage, Page: state.ListPage}
+	for !state.Listed {
 		page, resp, err := client.PullRequests.List(ctx, repoOwner, repoName, &github.PullRequestListOptions{
 			State:       "all",
 			ListOptions: *opt,
@@ -84,7 +173,10 @@ func (w *worker) handleGitHubRepoPRsAndCommits(ctx context.Context, j *db.Dequeu
 			return err
 		}
 
-		allPRs = append(allPRs, page...)
+		for _, pr := range page {
+			cp.stage(checkpointRowsPRNumbers, pr.GetNumber())
+		}
+		state.PRsListed += len(page)
 
 		// TODO(patrickdevivo) add additional context to this log message
 		// also send to database?
@@ -92,14 +184,31 @@ func (w *worker) handleGitHubRepoPRsAndCommits(ctx context.Context, j *db.Dequeu
 
 		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), true)
 
-		if resp.NextPage == 0 {
-			break
-		}
+		state.ListPage, state.Listed = resp.NextPage, resp.NextPage == 0
 		opt.Page = resp.NextPage
+
+		// the number of PRs is estimated from the number of pages (reported on all but the last page)
+		if resp.LastPage > 0 {
+			listing.setTotal(int64(resp.LastPage * perPage))
+		}
+		listing.set(ctx, int64(state.PRsListed))
+
+		if err = cp.maybeSave(ctx); err != nil {
+			return fmt.Errorf("save checkpoint: %w", err)
+		}
+	}
+
+	listing.done(ctx)
+
+	numbers, err := checkpointRows[int](ctx, cp, checkpointRowsPRNumbers)
+	if err != nil {
+		return fmt.Errorf("load checkpoint rows: %w", err)
 	}
 
-	for _, pr := range allPRs {
-		fetchedPR, resp, err := client.PullRequests.Get(ctx, repoOwner, repoName, pr.GetNumber())
+	// PRs are fetched in the order they were listed, so the ones fetched so far are skipped
+	var fetching = w.startProgress(ctx, cp.job, "fetching pull requests", int64(len(numbers)))
+	for _, number := range numbers[state.PRsFetched:] {
+		fetchedPR, resp, err := client.PullRequests.Get(ctx, repoOwner, repoName, number)
 		if err != nil {
 			return err
 		}
@@ -140,7 +249,7 @@ func (w *worker) handleGitHubRepoPRsAndCommits(ctx context.Context, j *db.Dequeu
 			updatedAt = &fetchedPR.UpdatedAt.Time
 		}
 
-		prsToInsert = append(prsToInsert, &githubRepoPR{
+		cp.stage(checkpointRowsPRs, &githubRepoPR{
 			Additions:           fetchedPR.Additions,
 			AuthorLogin:         fetchedPR.User.Login,
 			AuthorAssociation:   fetchedPR.AuthorAssociation,
@@ -182,19 +291,28 @@ func (w *worker) handleGitHubRepoPRsAndCommits(ctx context.Context, j *db.Dequeu
 			URL:                 fetchedPR.URL,
 			Labels:              labels,
 		})
+		state.PRsFetched++
+		fetching.set(ctx, int64(state.PRsFetched))
+
+		if err = cp.maybeSave(ctx); err != nil {
+			return fmt.Errorf("save checkpoint: %w", err)
+		}
 	}
 
-	allPRCommitsToInsert := make([]*githubPRCommit, 0)
+	fetching.done(ctx)
 
-	for _, pr := range allPRs {
-		opt := &github.ListOptions{PerPage: perPage}
+	// commits are fetched one PR at a time, resuming from the PR (and page) the commits were last fetched of
+	var commits = w.startProgress(ctx, cp.job, "fetching pull request commits", int64(len(numbers)))
+	for ; state.CommitsPR < len(numbers); state.CommitsPR, state.CommitsPage = state.CommitsPR+1, 0 {
+		var number = numbers[state.CommitsPR]
+		opt := &github.ListOptions{PerPage: perPage, Page: state.CommitsPage}
 		for {
-			page, resp, err := client.PullRequests.ListCommits(ctx, repoOwner, repoName, pr.GetNumber(), opt)
+			page, resp, err := client.PullRequests.ListCommits(ctx, repoOwner, repoName, number, opt)
 			if err != nil {
 				return err
 			}
 
-			w.logger.Info().Msgf("fetched page of commits for PR %d", pr.GetNumber())
+			w.logger.Info().Msgf("fetched page of commits for PR %d", number)
 
 			helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), true)
 
@@ -204,8 +322,8 @@ func (w *worker) handleGitHubRepoPRsAndCommits(ctx context.Context, j *db.Dequeu
 					additions = commit.Stats.Additions
 					deletions = commit.Stats.Deletions
 				}
-				allPRCommitsToInsert = append(allPRCommitsToInsert, &githubPRCommit{
-					PRNumber:       pr.Number,
+				cp.stage(checkpointRowsPRCommits, &githubPRCommit{
+					PRNumber:       github.Int(number),
 					Hash:           commit.SHA,
 					Message:        commit.Commit.Message,
 					AuthorName:     commit.Commit.Author.Name,
@@ -224,86 +342,15 @@ func (w *worker) handleGitHubRepoPRsAndCommits(ctx context.Context, j *db.Dequeu
 			if resp.NextPage == 0 {
 				break
 			}
-			opt.Page = resp.NextPage
-		}
-	}
+			opt.Page, state.CommitsPage = resp.NextPage, resp.NextPage
 
-	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
-		return fmt.Errorf("begin tx: %w", err)
-	}
-	defer func() {
-		if err := tx.Rollback(ctx); err != nil {
-			if !errors.Is(err, pgx.ErrTxClosed) {
-				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			if err = cp.maybeSave(ctx); err != nil {
+				return fmt.Errorf("save checkpoint: %w", err)
 			}
 		}
-	}()
-
-	// Delete all rows from github_pull_requests and github_pull_request_commits
-	r, err := tx.Exec(ctx, "DELETE FROM github_pull_requests WHERE repo_id = $1;", j.RepoID.String())
-	if err != nil {
-		return fmt.Errorf("delete rows: %w", err)
-	}
-
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("removed %d row(s) from github_pull_requests", r.RowsAffected()),
-	}}); err != nil {
-		return err
-	}
-
-	r, err = tx.Exec(ctx, "DELETE FROM github_pull_request_commits WHERE repo_id = $1;", j.RepoID.String())
-	if err != nil {
-		return fmt.Errorf("exec delete: %w", err)
-	}
-
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("removed %d row(s) from github_pull_request_commits", r.RowsAffected()),
-	}}); err != nil {
-		return err
-	}
-
-	// Insert all rows into github_pull_requests and github_pull_request_commits
-	if err := w.sendBatchGitHubRepoPRs(ctx, tx, id, prsToInsert); err != nil {
-		return fmt.Errorf("insert PRs: %w", err)
-	}
-
-	l.Info().Msgf("inserted repo PRs: %d", len(prsToInsert))
-
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("inserted %d row(s) into github_pull_requests", len(prsToInsert)),
-	}}); err != nil {
-		return err
-	}
-
-	if err := w.sendBatchGitHubPRCommits(ctx, tx, id, allPRCommitsToInsert); err != nil {
-		return fmt.Errorf("insert pr commits: %w", err)
+		commits.set(ctx, int64(state.CommitsPR+1))
 	}
+	commits.done(ctx)
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("inserted %d row(s) into github_pull_request_commits", len(allPRCommitsToInsert)),
-	}}); err != nil {
-		return err
-	}
-
-	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
-		return fmt.Errorf("sync job done: %w", err)
-	}
-
-	// indicate that we're finishing query execution
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
-		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
-	}}); err != nil {
-		return fmt.Errorf("send batch log messages: %w", err)
-	}
-
-	return tx.Commit(ctx)
+	return nil
 }
@@ -0,0 +1,207 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+)
+
+// fetchGitHubRepoCodeScanningAlerts pages through all the code scanning alerts (in any state) of a repo using the GitHub REST API
+func (w *worker) fetchGitHubRepoCodeScanningAlerts(ctx context.Context, client *github.Client, repoOwner, repoName string) ([]*github.Alert, error) {
+	var alerts = make([]*github.Alert, 0)
+
+	opts := &github.AlertListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.CodeScanning.ListAlertsForRepo(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		alerts = append(alerts, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	return alerts, nil
+}
+
+// sendBatchGitHubRepoCodeScanningAlerts uses the pg COPY protocol to send a batch of GitHub repo code scanning alerts
+func (w *worker) sendBatchGitHubRepoCodeScanningAlerts(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*github.Alert) error {
+	cols := []string{
+		"repo_id",
+		"number",
+		"state",
+		"rule_id",
+		"rule_name",
+		"rule_description",
+		"rule_severity",
+		"security_severity_level",
+		"tool_name",
+		"tool_version",
+		"ref",
+		"commit_sha",
+		"path",
+		"start_line",
+		"end_line",
+		"start_column",
+		"end_column",
+		"created_at",
+		"updated_at",
+		"fixed_at",
+		"dismissed_at",
+		"dismissed_by",
+		"dismissed_reason",
+		"dismissed_comment",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, a := range batch {
+		var ruleID, ruleName, ruleDescription, ruleSeverity, securitySeverityLevel *string
+		if rule := a.GetRule(); rule != nil {
+			ruleID, ruleName, ruleDescription = rule.ID, rule.Name, rule.Description
+			ruleSeverity, securitySeverityLevel = rule.Severity, rule.SecuritySeverityLevel
+		}
+
+		var toolName, toolVersion *string
+		if tool := a.GetTool(); tool != nil {
+			toolName, toolVersion = tool.Name, tool.Version
+		}
+
+		// the location is that of the most recent instance of the alert (on the default branch)
+		var ref, commitSHA, path *string
+		var startLine, endLine, startColumn, endColumn *int
+		if instance := a.GetMostRecentInstance(); instance != nil {
+			ref, commitSHA = instance.Ref, instance.CommitSHA
+			if loc := instance.GetLocation(); loc != nil {
+				path, startLine, endLine, startColumn, endColumn = loc.Path, loc.StartLine, loc.EndLine, loc.StartColumn, loc.EndColumn
+			}
+		}
+
+		input := []interface{}{
+			repo,
+			a.Number,
+			a.State,
+			ruleID,
+			ruleName,
+			ruleDescription,
+			ruleSeverity,
+			securitySeverityLevel,
+			toolName,
+			toolVersion,
+			ref,
+			commitSHA,
+			path,
+			startLine,
+			endLine,
+			startColumn,
+			endColumn,
+			helper.GetTimeFromTimestamp(a.CreatedAt),
+			helper.GetTimeFromTimestamp(a.UpdatedAt),
+			helper.GetTimeFromTimestamp(a.FixedAt),
+			helper.GetTimeFromTimestamp(a.DismissedAt),
+			helper.GetUserLogin(a.DismissedBy),
+			a.DismissedReason,
+			a.DismissedComment,
+			a.HTMLURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_code_scanning_alerts")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleGitHubRepoCodeScanningAlerts(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	alerts, err := w.fetchGitHubRepoCodeScanningAlerts(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch code scanning alerts: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "github_code_scanning_alerts"); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchGitHubRepoCodeScanningAlerts(ctx, tx, id, alerts); err != nil {
+		return fmt.Errorf("insert code scanning alerts: %w", err)
+	}
+
+	l.Info().Msgf("inserted repo code scanning alerts: %d", len(alerts))
+
+	if err := w.mergeStaged(ctx, tx, j, "github_code_scanning_alerts", int64(len(alerts))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,261 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+)
+
+// githubPermissions are the permissions of a repo, from the highest to the lowest
+var githubPermissions = []string{"admin", "maintain", "push", "triage", "pull"}
+
+// highestGitHubPermission returns the highest of the given permissions (of a user or team on a repo)
+func highestGitHubPermission(permissions map[string]bool) string {
+	for _, p := range githubPermissions {
+		if permissions[p] {
+			return p
+		}
+	}
+	return ""
+}
+
+// githubRepoCollaborator is a user with access to a repo
+type githubRepoCollaborator struct {
+	User    *github.User
+	Outside bool // whether the user is an outside collaborator (of the org owning the repo)
+}
+
+// fetchGitHubRepoCollaborators pages through all the collaborators of a repo (with the given affiliation) using the
+// GitHub REST API
+func (w *worker) fetchGitHubRepoCollaborators(ctx context.Context, client *github.Client, repoOwner, repoName, affiliation string) ([]*github.User, error) {
+	var collaborators = make([]*github.User, 0)
+
+	opts := &github.ListCollaboratorsOptions{Affiliation: affiliation, ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Repositories.ListCollaborators(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		collaborators = append(collaborators, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return collaborators, nil
+}
+
+// fetchGitHubRepoTeams pages through all the teams with access to a repo using the GitHub REST API
+func (w *worker) fetchGitHubRepoTeams(ctx context.Context, client *github.Client, repoOwner, repoName string) ([]*github.Team, error) {
+	var teams = make([]*github.Team, 0)
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Repositories.ListTeams(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		teams = append(teams, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return teams, nil
+}
+
+// sendBatchGitHubRepoCollaborators uses the pg COPY protocol to send a batch of GitHub repo collaborators
+func (w *worker) sendBatchGitHubRepoCollaborators(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*githubRepoCollaborator) error {
+	cols := []string{
+		"repo_id",
+		"login",
+		"permission",
+		"role_name",
+		"outside",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, c := range batch {
+		input := []interface{}{
+			repo,
+			c.User.GetLogin(),
+			highestGitHubPermission(c.User.Permissions),
+			c.User.RoleName,
+			c.Outside,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_repo_collaborators")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendBatchGitHubRepoTeams uses the pg COPY protocol to send a batch of the GitHub teams with access to a repo
+func (w *worker) sendBatchGitHubRepoTeams(ctx context.Context, tx pgx.Tx, repo uuid.UUID, org string, batch []*github.Team) error {
+	cols := []string{
+		"repo_id",
+		"team_id",
+		"org",
+		"team_slug",
+		"permission",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, t := range batch {
+		// the permission of a team is the one it has on the repo, when listed as one of the teams of the repo
+		var permission = highestGitHubPermission(t.Permissions)
+		if permission == "" {
+			permission = t.GetPermission()
+		}
+
+		input := []interface{}{
+			repo,
+			t.ID,
+			org,
+			t.Slug,
+			permission,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_repo_teams")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleGitHubRepoCollaborators(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	users, err := w.fetchGitHubRepoCollaborators(ctx, client, repoOwner, repoName, "all")
+	if err != nil {
+		return fmt.Errorf("fetch collaborators: %w", err)
+	}
+
+	// only the repos of orgs have outside collaborators (and teams), the ones of users have neither
+	owner, _, err := client.Users.Get(ctx, repoOwner)
+	if err != nil {
+		return fmt.Errorf("get owner: %w", err)
+	}
+
+	var outside = make(map[string]bool)
+	var teams = make([]*github.Team, 0)
+	if owner.GetType() == "Organization" {
+		outsiders, err := w.fetchGitHubRepoCollaborators(ctx, client, repoOwner, repoName, "outside")
+		if err != nil {
+			return fmt.Errorf("fetch outside collaborators: %w", err)
+		}
+		for _, u := range outsiders {
+			outside[u.GetLogin()] = true
+		}
+
+		if teams, err = w.fetchGitHubRepoTeams(ctx, client, repoOwner, repoName); err != nil {
+			return fmt.Errorf("fetch teams: %w", err)
+		}
+	}
+
+	var collaborators = make([]*githubRepoCollaborator, 0, len(users))
+	for _, u := range users {
+		collaborators = append(collaborators, &githubRepoCollaborator{User: u, Outside: outside[u.GetLogin()]})
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "github_repo_collaborators"); err != nil {
+		return err
+	}
+	if err = stage(ctx, tx, "github_repo_teams"); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchGitHubRepoCollaborators(ctx, tx, id, collaborators); err != nil {
+		return fmt.Errorf("insert collaborators: %w", err)
+	}
+
+	if err := w.sendBatchGitHubRepoTeams(ctx, tx, id, repoOwner, teams); err != nil {
+		return fmt.Errorf("insert teams: %w", err)
+	}
+
+	l.Info().Msgf("inserted repo collaborators: %d, teams: %d", len(collaborators), len(teams))
+
+	if err := w.mergeStaged(ctx, tx, j, "github_repo_collaborators", int64(len(collaborators))); err != nil {
+		return err
+	}
+	if err := w.mergeStaged(ctx, tx, j, "github_repo_teams", int64(len(teams))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
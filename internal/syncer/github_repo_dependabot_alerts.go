@@ -0,0 +1,200 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+)
+
+// fetchGitHubRepoDependabotAlerts pages through all the Dependabot alerts (in any state) of a repo using the GitHub REST API
+func (w *worker) fetchGitHubRepoDependabotAlerts(ctx context.Context, client *github.Client, repoOwner, repoName string) ([]*github.DependabotAlert, error) {
+	var alerts = make([]*github.DependabotAlert, 0)
+
+	// the dependabot alerts endpoint uses cursor based pagination (through the Link header)
+	opts := &github.ListAlertsOptions{ListCursorOptions: github.ListCursorOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.Dependabot.ListRepoAlerts(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		alerts = append(alerts, page...)
+
+		if resp.After == "" {
+			break
+		}
+		opts.ListCursorOptions.After = resp.After
+	}
+
+	return alerts, nil
+}
+
+// sendBatchGitHubRepoDependabotAlerts uses the pg COPY protocol to send a batch of GitHub repo Dependabot alerts
+func (w *worker) sendBatchGitHubRepoDependabotAlerts(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*github.DependabotAlert) error {
+	cols := []string{
+		"repo_id",
+		"number",
+		"state",
+		"package_ecosystem",
+		"package_name",
+		"manifest_path",
+		"scope",
+		"severity",
+		"ghsa_id",
+		"cve_id",
+		"summary",
+		"vulnerable_version_range",
+		"first_patched_version",
+		"created_at",
+		"updated_at",
+		"dismissed_at",
+		"dismissed_by",
+		"dismissed_reason",
+		"dismissed_comment",
+		"fixed_at",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, a := range batch {
+		var ecosystem, packageName, manifestPath, scope *string
+		if dep := a.GetDependency(); dep != nil {
+			manifestPath, scope = dep.ManifestPath, dep.Scope
+			if pkg := dep.GetPackage(); pkg != nil {
+				ecosystem, packageName = pkg.Ecosystem, pkg.Name
+			}
+		}
+
+		var severity, ghsaID, cveID, summary *string
+		if adv := a.GetSecurityAdvisory(); adv != nil {
+			severity, ghsaID, cveID, summary = adv.Severity, adv.GHSAID, adv.CVEID, adv.Summary
+		}
+
+		var vulnerableVersionRange, firstPatchedVersion *string
+		if vuln := a.GetSecurityVulnerability(); vuln != nil {
+			vulnerableVersionRange = vuln.VulnerableVersionRange
+			if vuln.FirstPatchedVersion != nil {
+				firstPatchedVersion = vuln.FirstPatchedVersion.Identifier
+			}
+		}
+
+		input := []interface{}{
+			repo,
+			a.Number,
+			a.State,
+			ecosystem,
+			packageName,
+			manifestPath,
+			scope,
+			severity,
+			ghsaID,
+			cveID,
+			summary,
+			vulnerableVersionRange,
+			firstPatchedVersion,
+			helper.GetTimeFromTimestamp(a.CreatedAt),
+			helper.GetTimeFromTimestamp(a.UpdatedAt),
+			helper.GetTimeFromTimestamp(a.DismissedAt),
+			helper.GetUserLogin(a.DismissedBy),
+			a.DismissedReason,
+			a.DismissedComment,
+			helper.GetTimeFromTimestamp(a.FixedAt),
+			a.HTMLURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_dependabot_alerts")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleGitHubRepoDependabotAlerts(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	alerts, err := w.fetchGitHubRepoDependabotAlerts(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch dependabot alerts: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "github_dependabot_alerts"); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchGitHubRepoDependabotAlerts(ctx, tx, id, alerts); err != nil {
+		return fmt.Errorf("insert dependabot alerts: %w", err)
+	}
+
+	l.Info().Msgf("inserted repo dependabot alerts: %d", len(alerts))
+
+	if err := w.mergeStaged(ctx, tx, j, "github_dependabot_alerts", int64(len(alerts))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
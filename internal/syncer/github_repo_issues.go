@@ -2,14 +2,18 @@ package syncer
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/url"
 	"strings"
 	"time"
 
+	"github.com/google/go-github/v50/github"
 	"github.com/jackc/pgx/v4"
 	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
 	uuid "github.com/satori/go.uuid"
 )
 
@@ -41,6 +45,119 @@ type githubRepoIssue struct {
 	UpdatedAt           *time.Time `db:"updated_at"`
 	URL                 *string    `db:"url"`
 	Labels              []byte     `db:"labels"`
+	Assignees           []byte     `db:"-"`
+}
+
+// kinds of the rows a GitHub repo issues sync stages in its checkpoint
+const (
+	checkpointRowsIssues         = "issues"    // issues fetched using mergestat-lite
+	checkpointRowsIssueAssignees = "assignees" // assignees of the issues fetched so far
+)
+
+// githubRepoIssuesSettings are the settings of a GitHub repo issues sync
+type githubRepoIssuesSettings struct {
+	// FullResync ignores the watermark of the previous run and fetches the assignees of all the issues of the repo
+	FullResync bool `json:"fullResync"`
+}
+
+// githubRepoIssuesCheckpoint is the progress of a GitHub repo issues sync, saved so that an interrupted sync resumes where it left off
+type githubRepoIssuesCheckpoint struct {
+	IssuesFetched  bool   `json:"issuesFetched"`  // whether the issues were fetched using mergestat-lite
+	AssigneesPage  int    `json:"assigneesPage"`  // next page of issues to fetch the assignees of
+	AssigneesDone  bool   `json:"assigneesDone"`  // whether the assignees of all the issues were fetched
+	AssigneesCount int    `json:"assigneesCount"` // number of issues the assignees were fetched of so far
+	AssigneesSince string `json:"assigneesSince"` // only the assignees of the issues updated since then are fetched, of all the issues if empty
+	AssigneesAt    string `json:"assigneesAt"`    // when fetching the assignees started, the watermark of the next sync
+}
+
+// githubIssueAssignees are the logins of the users assigned to an issue
+type githubIssueAssignees struct {
+	Number int      `json:"number"`
+	Logins []string `json:"logins"`
+}
+
+// fetchGitHubIssueAssignees pages through the issues of a repo (updated since state.AssigneesSince, if set) using the
+// GitHub REST API and stages the logins of the users assigned to each in the checkpoint
+func (w *worker) fetchGitHubIssueAssignees(ctx context.Context, client *github.Client, repoOwner, repoName string, cp *checkpoint, state *githubRepoIssuesCheckpoint) error {
+	var progress *progressReporter
+	if !state.AssigneesDone {
+		progress = w.startProgress(ctx, cp.job, "fetching issue assignees", 0)
+	}
+
+	opts := &github.IssueListByRepoOptions{State: "all", ListOptions: github.ListOptions{PerPage: 100, Page: state.AssigneesPage}}
+	if state.AssigneesSince != "" {
+		var err error
+		if opts.Since, err = time.Parse(time.RFC3339, state.AssigneesSince); err != nil {
+			return fmt.Errorf("parse watermark: %w", err)
+		}
+	}
+	for !state.AssigneesDone {
+		issues, resp, err := client.Issues.ListByRepo(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		for _, issue := range issues {
+			// pull requests are listed along with the issues, but aren't part of github_repo_issues
+			if issue.Number == nil || issue.IsPullRequest() {
+				continue
+			}
+
+			logins := make([]string, 0, len(issue.Assignees))
+			for _, assignee := range issue.Assignees {
+				if assignee != nil && assignee.Login != nil {
+					logins = append(logins, *assignee.Login)
+				}
+			}
+			cp.stage(checkpointRowsIssueAssignees, &githubIssueAssignees{Number: *issue.Number, Logins: logins})
+			state.AssigneesCount++
+		}
+
+		state.AssigneesPage, state.AssigneesDone = resp.NextPage, resp.NextPage == 0
+		opts.Page = resp.NextPage
+
+		// the number of issues is estimated from the number of pages (reported on all but the last page)
+		if resp.LastPage > 0 {
+			progress.setTotal(int64(resp.LastPage * opts.PerPage))
+		}
+		progress.set(ctx, int64(state.AssigneesCount))
+
+		if err = cp.maybeSave(ctx); err != nil {
+			return fmt.Errorf("save checkpoint: %w", err)
+		}
+	}
+
+	progress.done(ctx)
+	return nil
+}
+
+// syncedGitHubIssueAssignees returns the logins of the users assigned to each (previously synced) issue of a repo,
+// keyed by issue number
+func (w *worker) syncedGitHubIssueAssignees(ctx context.Context, repo string) (_ map[int][]string, err error) {
+	var rows pgx.Rows
+	if rows, err = w.pool.Query(ctx, "SELECT number, assignees FROM github_issues WHERE repo_id = $1 AND _deleted_at IS NULL;", repo); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignees = make(map[int][]string)
+	for rows.Next() {
+		var number int
+		var data []byte
+		if err = rows.Scan(&number, &data); err != nil {
+			return nil, err
+		}
+
+		var logins []string
+		if err = json.Unmarshal(data, &logins); err != nil {
+			return nil, err
+		}
+		assignees[number] = logins
+	}
+
+	return assignees, rows.Err()
 }
 
 // sendBatchGitHubRepoIssues uses the pg COPY protocol to send a batch of GitHub repo issues
@@ -70,6 +187,7 @@ func (w *worker) sendBatchGitHubRepoIssues(ctx context.Context, tx pgx.Tx, repo
 		"updated_at",
 		"url",
 		"labels",
+		"assignees",
 	}
 
 	inputs := make([][]interface{}, 0, len(batch))
@@ -80,6 +198,10 @@ func (w *worker) sendBatchGitHubRepoIssues(ctx context.Context, tx pgx.Tx, repo
 			issue.Labels = []byte("[]")
 		}
 
+		if issue.Assignees == nil {
+			issue.Assignees = []byte("[]")
+		}
+
 		input := []interface{}{
 			repo,
 			issue.AuthorLogin,
@@ -105,11 +227,12 @@ func (w *worker) sendBatchGitHubRepoIssues(ctx context.Context, tx pgx.Tx, repo
 			issue.UpdatedAt,
 			issue.URL,
 			issue.Labels,
+			issue.Assignees,
 		}
 		inputs = append(inputs, input)
 	}
 
-	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"github_issues"}, cols, pgx.CopyFromRows(inputs)); err != nil {
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_issues")}, cols, pgx.CopyFromRows(inputs)); err != nil {
 		return err
 	}
 	return nil
@@ -147,13 +270,93 @@ func (w *worker) handleGitHubRepoIssues(ctx context.Context, j *db.DequeueSyncJo
 	repoOwner := components[1]
 	repoName := components[2]
 
-	issues := make([]*githubRepoIssue, 0)
-	if err = w.mergestat.SelectContext(ctx, &issues, selectGitHubRepoIssues, fmt.Sprintf("%s/%s", repoOwner, repoName)); err != nil {
-		return fmt.Errorf("mergestat query: %w", err)
+	// resume from the progress saved by a previous (interrupted) attempt of this job, if any
+	var state githubRepoIssuesCheckpoint
+	cp, resumed, err := w.loadCheckpoint(ctx, j, &state)
+	if err != nil {
+		return fmt.Errorf("load checkpoint: %w", err)
+	}
+
+	if resumed {
+		l.Info().Msgf("resuming repo issues sync from checkpoint (assignees page %d)", state.AssigneesPage)
+	}
+
+	if !state.IssuesFetched {
+		issues := make([]*githubRepoIssue, 0)
+		if err = w.selectGitHub(ctx, j, ghToken, &issues, selectGitHubRepoIssues, fmt.Sprintf("%s/%s", repoOwner, repoName)); err != nil {
+			return fmt.Errorf("mergestat query: %w", err)
+		}
+
+		for _, issue := range issues {
+			cp.stage(checkpointRowsIssues, issue)
+		}
+		state.IssuesFetched = true
+
+		if err = cp.save(ctx); err != nil {
+			return fmt.Errorf("save checkpoint: %w", err)
+		}
+	}
+
+	// only the assignees of the issues updated since the previous run are fetched, unless a full resync is requested
+	if state.AssigneesAt == "" {
+		var settings githubRepoIssuesSettings
+		if err = decodeSettings(j, &settings); err != nil {
+			return err
+		}
+
+		if !settings.FullResync {
+			if state.AssigneesSince, err = w.db.GetRepoSyncWatermark(ctx, j.RepoSyncID); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("fetch watermark: %w", err)
+			}
+		}
+		state.AssigneesAt = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	if err = w.fetchGitHubIssueAssignees(ctx, client, repoOwner, repoName, cp, &state); err != nil {
+		return cp.saveOnError(fmt.Errorf("fetch issue assignees: %w", err))
+	}
+
+	var issues []*githubRepoIssue
+	if issues, err = checkpointRows[*githubRepoIssue](ctx, cp, checkpointRowsIssues); err != nil {
+		return fmt.Errorf("load checkpoint rows: %w", err)
+	}
+
+	var fetchedAssignees []*githubIssueAssignees
+	if fetchedAssignees, err = checkpointRows[*githubIssueAssignees](ctx, cp, checkpointRowsIssueAssignees); err != nil {
+		return fmt.Errorf("load checkpoint rows: %w", err)
+	}
+
+	// logins of the users assigned to each issue, keyed by issue number. The issues that weren't updated since the
+	// previous run keep the assignees it synced.
+	var assignees = make(map[int][]string, len(fetchedAssignees))
+	if state.AssigneesSince != "" {
+		if assignees, err = w.syncedGitHubIssueAssignees(ctx, j.RepoID.String()); err != nil {
+			return fmt.Errorf("fetch synced issue assignees: %w", err)
+		}
+	}
+	for _, a := range fetchedAssignees {
+		assignees[a.Number] = a.Logins
+	}
+
+	for _, issue := range issues {
+		if issue.Number == nil {
+			continue
+		}
+
+		if logins, ok := assignees[*issue.Number]; ok {
+			if issue.Assignees, err = json.Marshal(logins); err != nil {
+				return fmt.Errorf("marshal issue assignees: %w", err)
+			}
+		}
 	}
 
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer func() {
@@ -164,16 +367,7 @@ func (w *worker) handleGitHubRepoIssues(ctx context.Context, j *db.DequeueSyncJo
 		}
 	}()
 
-	r, err := tx.Exec(ctx, "DELETE FROM github_issues WHERE repo_id = $1;", j.RepoID.String())
-	if err != nil {
-		return fmt.Errorf("delete rows: %w", err)
-	}
-
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("removed %d row(s) from github_issues", r.RowsAffected()),
-	}}); err != nil {
+	if err = stage(ctx, tx, "github_issues"); err != nil {
 		return err
 	}
 
@@ -183,14 +377,18 @@ func (w *worker) handleGitHubRepoIssues(ctx context.Context, j *db.DequeueSyncJo
 
 	l.Info().Msgf("inserted repo issues: %d", len(issues))
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("inserted %d row(s) into github_issues", len(issues)),
-	}}); err != nil {
+	if err := w.mergeStaged(ctx, tx, j, "github_issues", int64(len(issues))); err != nil {
 		return err
 	}
 
+	if err := w.db.WithTx(tx).UpsertRepoSyncWatermark(ctx, db.UpsertRepoSyncWatermarkParams{RepoSyncID: j.RepoSyncID, Watermark: state.AssigneesAt}); err != nil {
+		return fmt.Errorf("update watermark: %w", err)
+	}
+
+	if err := w.db.WithTx(tx).DeleteSyncJobCheckpoint(ctx, j.ID); err != nil {
+		return fmt.Errorf("delete checkpoint: %w", err)
+	}
+
 	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
 		return fmt.Errorf("sync job done: %w", err)
 	}
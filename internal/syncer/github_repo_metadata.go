@@ -10,11 +10,12 @@ import (
 	"strings"
 
 	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"github.com/mergestat/mergestat/internal/db"
 	"github.com/mergestat/mergestat/internal/helper"
 	"github.com/mergestat/mergestat/queries"
-	"golang.org/x/oauth2"
+	"github.com/shurcooL/githubv4"
 )
 
 func (w *worker) handleGitHubRepoMetadata(ctx context.Context, j *db.DequeueSyncJobRow) error {
@@ -52,14 +53,26 @@ func (w *worker) handleGitHubRepoMetadata(ctx context.Context, j *db.DequeueSync
 		latestRelease *github.RepositoryRelease
 		releaseCount  int
 	)
-	if repo, latestRelease, releaseCount, err = w.getRepositoryInfo(ctx, ghToken, j.Repo); err != nil {
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	if repo, latestRelease, releaseCount, err = w.getRepositoryInfo(ctx, client, ghToken, j.Repo); err != nil {
 		return err
 	}
 
 	l.Info().Msgf("retrieved repo info as JSON")
 
+	// the API follows the redirects of renamed and transferred repos, returning the repo under its current name
+	if newURL := renamedURL(j.Repo, repo.GetHTMLURL()); repo.GetHTMLURL() != "" && newURL != "" {
+		if err = w.renameRepo(ctx, j, newURL); err != nil {
+			return err
+		}
+	}
+
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return err
 	}
 	defer func() {
@@ -198,6 +211,15 @@ func (w *worker) handleGitHubRepoMetadata(ctx context.Context, j *db.DequeueSync
 		}
 	}
 
+	var topics = repo.Topics
+	if topics == nil {
+		topics = []string{}
+	}
+
+	if insertParams.Topics, err = helper.InterfaceToSqlJSONB(topics); err != nil {
+		return err
+	}
+
 	// nightmare over..gotta be a better way than hand typing this
 	if err := w.db.WithTx(tx).InsertGitHubRepoInfo(ctx, insertParams); err != nil {
 		return err
@@ -217,7 +239,54 @@ func (w *worker) handleGitHubRepoMetadata(ctx context.Context, j *db.DequeueSync
 	return tx.Commit(ctx)
 }
 
-func (w *worker) getRepositoryInfo(ctx context.Context, ghToken string, currentRepo string) (*github.Repository, *github.RepositoryRelease, int, error) {
+// githubProviderSettings are the settings of a GitHub provider that the syncer cares about
+type githubProviderSettings struct {
+	// URL is the base url of a GitHub Enterprise Server installation (e.g. https://github.example.com).
+	// When empty, github.com is used.
+	URL string `json:"url"`
+}
+
+// newGitHubClient returns a GitHub REST API client authenticated with the given token (an empty token
+// returns an unauthenticated client) that talks to the GitHub installation configured on the repo's provider.
+func (w *worker) newGitHubClient(ctx context.Context, j *db.DequeueSyncJobRow, ghToken string) (*github.Client, error) {
+	settings, err := w.githubProviderSettings(ctx, j)
+	if err != nil {
+		return nil, err
+	}
+
+	return helper.NewGitHubClient(ctx, ghToken, settings.URL)
+}
+
+// newGitHubGraphQLClient returns a GitHub GraphQL API client authenticated with the given token, that talks to the
+// GitHub installation configured on the repo's provider (as newGitHubClient)
+func (w *worker) newGitHubGraphQLClient(ctx context.Context, j *db.DequeueSyncJobRow, ghToken string) (*githubv4.Client, error) {
+	settings, err := w.githubProviderSettings(ctx, j)
+	if err != nil {
+		return nil, err
+	}
+
+	return helper.NewGitHubGraphQLClient(ctx, ghToken, settings.URL), nil
+}
+
+// githubProviderSettings returns the settings of the GitHub provider of the repo of the given job
+func (w *worker) githubProviderSettings(ctx context.Context, j *db.DequeueSyncJobRow) (*githubProviderSettings, error) {
+	var settings githubProviderSettings
+
+	raw, err := w.db.GetRepoProviderSettings(ctx, j.RepoID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("fetch provider settings: %w", err)
+	}
+
+	if raw.Status == pgtype.Present {
+		if err = raw.AssignTo(&settings); err != nil {
+			return nil, fmt.Errorf("parse provider settings: %w", err)
+		}
+	}
+
+	return &settings, nil
+}
+
+func (w *worker) getRepositoryInfo(ctx context.Context, client *github.Client, ghToken string, currentRepo string) (*github.Repository, *github.RepositoryRelease, int, error) {
 	var (
 		err           error
 		repo          *github.Repository
@@ -226,17 +295,6 @@ func (w *worker) getRepositoryInfo(ctx context.Context, ghToken string, currentR
 		resp          *github.Response
 	)
 
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: ghToken},
-	)
-
-	tc := oauth2.NewClient(ctx, ts)
-	if len(ghToken) <= 0 {
-		tc = nil
-	}
-
-	client := github.NewClient(tc)
-
 	if len(ghToken) > 0 {
 		// we check the rate limit before any call to the GitHub API
 		if _, resp, err = client.RateLimits(ctx); err != nil {
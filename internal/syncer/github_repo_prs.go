@@ -160,7 +160,7 @@ func (w *worker) sendBatchGitHubRepoPRs(ctx context.Context, tx pgx.Tx, repo uui
 		inputs = append(inputs, input)
 	}
 
-	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"github_pull_requests"}, cols, pgx.CopyFromRows(inputs)); err != nil {
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_pull_requests")}, cols, pgx.CopyFromRows(inputs)); err != nil {
 		return err
 	}
 	return nil
@@ -199,12 +199,12 @@ func (w *worker) handleGitHubRepoPRs(ctx context.Context, j *db.DequeueSyncJobRo
 	repoName := components[2]
 
 	prs := make([]*githubRepoPR, 0)
-	if err = w.mergestat.SelectContext(ctx, &prs, selectGitHubRepoPRs, fmt.Sprintf("%s/%s", repoOwner, repoName)); err != nil {
+	if err = w.selectGitHub(ctx, j, ghToken, &prs, selectGitHubRepoPRs, fmt.Sprintf("%s/%s", repoOwner, repoName)); err != nil {
 		return fmt.Errorf("mergestat query: %w", err)
 	}
 
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer func() {
@@ -215,16 +215,7 @@ func (w *worker) handleGitHubRepoPRs(ctx context.Context, j *db.DequeueSyncJobRo
 		}
 	}()
 
-	r, err := tx.Exec(ctx, "DELETE FROM github_pull_requests WHERE repo_id = $1;", j.RepoID.String())
-	if err != nil {
-		return fmt.Errorf("delete rows: %w", err)
-	}
-
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("removed %d row(s) from github_pull_requests", r.RowsAffected()),
-	}}); err != nil {
+	if err = stage(ctx, tx, "github_pull_requests"); err != nil {
 		return err
 	}
 
@@ -234,11 +225,7 @@ func (w *worker) handleGitHubRepoPRs(ctx context.Context, j *db.DequeueSyncJobRo
 
 	l.Info().Msgf("inserted repo PRs: %d", len(prs))
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: j.ID,
-		Message:         fmt.Sprintf("inserted %d row(s) into github_pull_requests", len(prs)),
-	}}); err != nil {
+	if err := w.mergeStaged(ctx, tx, j, "github_pull_requests", int64(len(prs))); err != nil {
 		return err
 	}
 
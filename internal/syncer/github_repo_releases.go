@@ -0,0 +1,216 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+)
+
+// fetchGitHubRepoReleases pages through all the releases (and their assets) of a repo using the GitHub REST API
+func (w *worker) fetchGitHubRepoReleases(ctx context.Context, client *github.Client, repoOwner, repoName string) ([]*github.RepositoryRelease, error) {
+	var releases = make([]*github.RepositoryRelease, 0)
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		page, resp, err := client.Repositories.ListReleases(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		releases = append(releases, page...)
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return releases, nil
+}
+
+// sendBatchGitHubRepoReleases uses the pg COPY protocol to send a batch of GitHub repo releases
+func (w *worker) sendBatchGitHubRepoReleases(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*github.RepositoryRelease) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"tag_name",
+		"target_commitish",
+		"name",
+		"body",
+		"draft",
+		"prerelease",
+		"author_login",
+		"created_at",
+		"published_at",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, r := range batch {
+		input := []interface{}{
+			repo,
+			r.ID,
+			r.TagName,
+			r.TargetCommitish,
+			r.Name,
+			r.Body,
+			r.Draft,
+			r.Prerelease,
+			helper.GetUserLogin(r.Author),
+			helper.GetTimeFromTimestamp(r.CreatedAt),
+			helper.GetTimeFromTimestamp(r.PublishedAt),
+			r.HTMLURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_releases")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendBatchGitHubRepoReleaseAssets uses the pg COPY protocol to send the assets of a batch of GitHub repo releases
+func (w *worker) sendBatchGitHubRepoReleaseAssets(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*github.RepositoryRelease) (int, error) {
+	cols := []string{
+		"repo_id",
+		"release_id",
+		"id",
+		"name",
+		"label",
+		"state",
+		"content_type",
+		"size",
+		"download_count",
+		"created_at",
+		"updated_at",
+		"browser_download_url",
+	}
+
+	inputs := make([][]interface{}, 0)
+	for _, r := range batch {
+		for _, a := range r.Assets {
+			input := []interface{}{
+				repo,
+				r.ID,
+				a.ID,
+				a.Name,
+				a.Label,
+				a.State,
+				a.ContentType,
+				a.Size,
+				a.DownloadCount,
+				helper.GetTimeFromTimestamp(a.CreatedAt),
+				helper.GetTimeFromTimestamp(a.UpdatedAt),
+				a.BrowserDownloadURL,
+			}
+			inputs = append(inputs, input)
+		}
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_release_assets")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return 0, err
+	}
+	return len(inputs), nil
+}
+
+func (w *worker) handleGitHubRepoReleases(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	releases, err := w.fetchGitHubRepoReleases(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch releases: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "github_releases"); err != nil {
+		return err
+	}
+	if err = stage(ctx, tx, "github_release_assets"); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchGitHubRepoReleases(ctx, tx, id, releases); err != nil {
+		return fmt.Errorf("insert releases: %w", err)
+	}
+
+	var insertedAssets int
+	if insertedAssets, err = w.sendBatchGitHubRepoReleaseAssets(ctx, tx, id, releases); err != nil {
+		return fmt.Errorf("insert release assets: %w", err)
+	}
+
+	l.Info().Msgf("inserted repo releases: %d, assets: %d", len(releases), insertedAssets)
+
+	// releases are merged first, as assets reference them
+	if err := w.mergeStaged(ctx, tx, j, "github_releases", int64(len(releases))); err != nil {
+		return err
+	}
+	if err := w.mergeStaged(ctx, tx, j, "github_release_assets", int64(insertedAssets)); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
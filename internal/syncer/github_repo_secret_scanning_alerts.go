@@ -0,0 +1,198 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+)
+
+// githubSecretScanningAlert is a secret scanning alert along with all the locations the secret was found at
+type githubSecretScanningAlert struct {
+	*github.SecretScanningAlert
+	Locations []*github.SecretScanningAlertLocation
+}
+
+// fetchGitHubRepoSecretScanningAlerts pages through all the secret scanning alerts (in any state) of a repo,
+// and the locations of each alert, using the GitHub REST API
+func (w *worker) fetchGitHubRepoSecretScanningAlerts(ctx context.Context, client *github.Client, repoOwner, repoName string) ([]*githubSecretScanningAlert, error) {
+	var alerts = make([]*githubSecretScanningAlert, 0)
+
+	opts := &github.SecretScanningAlertListOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		page, resp, err := client.SecretScanning.ListAlertsForRepo(ctx, repoOwner, repoName, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+		for _, a := range page {
+			alerts = append(alerts, &githubSecretScanningAlert{SecretScanningAlert: a})
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.ListOptions.Page = resp.NextPage
+	}
+
+	for _, a := range alerts {
+		locOpts := &github.ListOptions{PerPage: 100}
+		for {
+			page, resp, err := client.SecretScanning.ListLocationsForAlert(ctx, repoOwner, repoName, int64(a.GetNumber()), locOpts)
+			if err != nil {
+				return nil, err
+			}
+
+			helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+			a.Locations = append(a.Locations, page...)
+
+			if resp.NextPage == 0 {
+				break
+			}
+			locOpts.Page = resp.NextPage
+		}
+	}
+
+	return alerts, nil
+}
+
+// sendBatchGitHubRepoSecretScanningAlerts uses the pg COPY protocol to send a batch of GitHub repo secret scanning alerts.
+// Note that the value of the detected secret is intentionally never stored.
+func (w *worker) sendBatchGitHubRepoSecretScanningAlerts(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*githubSecretScanningAlert) error {
+	cols := []string{
+		"repo_id",
+		"number",
+		"state",
+		"secret_type",
+		"resolution",
+		"resolved_at",
+		"resolved_by",
+		"created_at",
+		"locations",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, a := range batch {
+		locations := make([]*github.SecretScanningAlertLocationDetails, 0, len(a.Locations))
+		for _, loc := range a.Locations {
+			if loc.Details != nil {
+				locations = append(locations, loc.Details)
+			}
+		}
+
+		jsonLocations, err := json.Marshal(locations)
+		if err != nil {
+			return fmt.Errorf("marshal alert locations: %w", err)
+		}
+
+		input := []interface{}{
+			repo,
+			a.Number,
+			a.State,
+			a.SecretType,
+			a.Resolution,
+			helper.GetTimeFromTimestamp(a.ResolvedAt),
+			helper.GetUserLogin(a.ResolvedBy),
+			helper.GetTimeFromTimestamp(a.CreatedAt),
+			jsonLocations,
+			a.HTMLURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("github_secret_scanning_alerts")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleGitHubRepoSecretScanningAlerts(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	alerts, err := w.fetchGitHubRepoSecretScanningAlerts(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch secret scanning alerts: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "github_secret_scanning_alerts"); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchGitHubRepoSecretScanningAlerts(ctx, tx, id, alerts); err != nil {
+		return fmt.Errorf("insert secret scanning alerts: %w", err)
+	}
+
+	l.Info().Msgf("inserted repo secret scanning alerts: %d", len(alerts))
+
+	if err := w.mergeStaged(ctx, tx, j, "github_secret_scanning_alerts", int64(len(alerts))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
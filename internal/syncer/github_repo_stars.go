@@ -15,6 +15,12 @@ import (
 
 const selectGitHubRepoStars = `SELECT * FROM github_stargazers(?)`
 
+// selectGitHubRepoStarsSince only pages through stars newer than the given timestamp. Ordering by starred_at (descending)
+// lets the underlying table function stop paging as soon as it reaches stars that were already synced.
+const selectGitHubRepoStarsSince = `SELECT * FROM github_stargazers(?) WHERE starred_at > ? ORDER BY starred_at DESC`
+
+const selectGitHubRepoStarCount = `SELECT github_stargazer_count(?)`
+
 type githubRepoStar struct {
 	Login     *string    `db:"login"`
 	Email     *string    `db:"email"`
@@ -30,8 +36,39 @@ type githubRepoStar struct {
 	StarredAt *time.Time `db:"starred_at"`
 }
 
-// sendBatchGitHubRepoStars uses the pg COPY protocol to send a batch of GitHub repo stars
-func (w *worker) sendBatchGitHubRepoStars(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*githubRepoStar) error {
+// lastGitHubRepoStarredAt returns the timestamp of the most recently synced star of a repo, or nil if none were synced yet
+func (w *worker) lastGitHubRepoStarredAt(ctx context.Context, repo uuid.UUID) (*time.Time, error) {
+	var lastStarredAt *time.Time
+	if err := w.pool.QueryRow(ctx, "SELECT MAX(starred_at) FROM github_stargazers WHERE repo_id = $1;", repo.String()).Scan(&lastStarredAt); err != nil {
+		return nil, err
+	}
+	return lastStarredAt, nil
+}
+
+// countGitHubRepoStarsAfter returns the number of stars a repo will have once the given (new) stars of an incremental
+// sync are inserted: the synced stars of the users that didn't star it again, plus the new ones
+func (w *worker) countGitHubRepoStarsAfter(ctx context.Context, repo uuid.UUID, stars []*githubRepoStar) (int, error) {
+	var logins = make(map[string]struct{}, len(stars))
+	for _, s := range stars {
+		if s.Login != nil {
+			logins[*s.Login] = struct{}{}
+		}
+	}
+
+	var others = make([]string, 0, len(logins))
+	for login := range logins {
+		others = append(others, login)
+	}
+
+	var count int
+	if err := w.pool.QueryRow(ctx, "SELECT COUNT(*) FROM github_stargazers WHERE repo_id = $1 AND _deleted_at IS NULL AND login <> ALL($2);", repo.String(), others).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count + len(logins), nil
+}
+
+// sendBatchGitHubRepoStars uses the pg COPY protocol to send a batch of GitHub repo stars into the given table
+func (w *worker) sendBatchGitHubRepoStars(ctx context.Context, tx pgx.Tx, table string, repo uuid.UUID, batch []*githubRepoStar) error {
 	cols := []string{
 		"repo_id",
 		"login",
@@ -68,7 +105,7 @@ func (w *worker) sendBatchGitHubRepoStars(ctx context.Context, tx pgx.Tx, repo u
 		inputs = append(inputs, input)
 	}
 
-	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"github_stargazers"}, cols, pgx.CopyFromRows(inputs)); err != nil {
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{table}, cols, pgx.CopyFromRows(inputs)); err != nil {
 		return err
 	}
 	return nil
@@ -106,15 +143,55 @@ func (w *worker) handleGitHubRepoStars(ctx context.Context, j *db.DequeueSyncJob
 	repoOwner := components[1]
 	repoName := components[2]
 
+	// if stars were synced before, resume from the most recent one instead of re-fetching the entire history
+	var lastStarredAt *time.Time
+	if lastStarredAt, err = w.lastGitHubRepoStarredAt(ctx, id); err != nil {
+		return fmt.Errorf("last starred at: %w", err)
+	}
+
 	stars := make([]*githubRepoStar, 0)
-	if err := w.mergestat.SelectContext(ctx, &stars, selectGitHubRepoStars, fmt.Sprintf("%s/%s", repoOwner, repoName)); err != nil {
-		return fmt.Errorf("mergestat select: %w", err)
+	if lastStarredAt != nil {
+		since := lastStarredAt.UTC().Format(time.RFC3339)
+		if err := w.selectGitHub(ctx, j, ghToken, &stars, selectGitHubRepoStarsSince, fmt.Sprintf("%s/%s", repoOwner, repoName), since); err != nil {
+			return fmt.Errorf("mergestat select: %w", err)
+		}
+		l.Info().Msgf("resuming repo stargazers sync from %s", since)
+
+		// users that unstarred the repo don't show up in the new stars, so the stars are checked against the number of
+		// stargazers of the repo, falling back to a full sync (removing the stars that are gone) if they don't add up
+		var counts []int
+		if err := w.selectGitHub(ctx, j, ghToken, &counts, selectGitHubRepoStarCount, fmt.Sprintf("%s/%s", repoOwner, repoName)); err != nil {
+			return fmt.Errorf("mergestat select: %w", err)
+		}
+
+		var total, expected int
+		if len(counts) > 0 {
+			total = counts[0]
+		}
+		if expected, err = w.countGitHubRepoStarsAfter(ctx, id, stars); err != nil {
+			return fmt.Errorf("count stars: %w", err)
+		}
+
+		if total != expected {
+			if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+				Message: fmt.Sprintf("repo has %d stargazer(s) rather than %d, falling back to a full sync", total, expected),
+			}}); err != nil {
+				return fmt.Errorf("send batch log messages: %w", err)
+			}
+			lastStarredAt, stars = nil, make([]*githubRepoStar, 0)
+		}
+	}
+
+	if lastStarredAt == nil {
+		if err := w.selectGitHub(ctx, j, ghToken, &stars, selectGitHubRepoStars, fmt.Sprintf("%s/%s", repoOwner, repoName)); err != nil {
+			return fmt.Errorf("mergestat select: %w", err)
+		}
 	}
 
 	l.Info().Msgf("retrieved repo stargazers: %d", len(stars))
 
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer func() {
@@ -125,7 +202,50 @@ func (w *worker) handleGitHubRepoStars(ctx context.Context, j *db.DequeueSyncJob
 		}
 	}()
 
-	r, err := tx.Exec(ctx, "DELETE FROM github_stargazers WHERE repo_id = $1;", id.String())
+	// on a full sync, all stars are staged and merged, deleting the rows of users that don't star the repo anymore. On
+	// an incremental sync, only rows of users that starred the repo again (un-star and re-star) are removed,
+	// so that the history keeps a single row per user.
+	if lastStarredAt == nil {
+		if err = stage(ctx, tx, "github_stargazers"); err != nil {
+			return err
+		}
+
+		if err := w.sendBatchGitHubRepoStars(ctx, tx, staging("github_stargazers"), id, stars); err != nil {
+			return fmt.Errorf("batch insert stars: %w", err)
+		}
+
+		if err := w.mergeStaged(ctx, tx, j, "github_stargazers", int64(len(stars))); err != nil {
+			return err
+		}
+	} else if err := w.insertNewGitHubRepoStars(ctx, tx, j, id, stars); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// insertNewGitHubRepoStars inserts the given (new) stars of an incremental sync, replacing the rows of users that starred
+// the repo again (un-star and re-star)
+func (w *worker) insertNewGitHubRepoStars(ctx context.Context, tx pgx.Tx, j *db.DequeueSyncJobRow, id uuid.UUID, stars []*githubRepoStar) error {
+	logins := make([]string, 0, len(stars))
+	for _, s := range stars {
+		if s.Login != nil {
+			logins = append(logins, *s.Login)
+		}
+	}
+
+	r, err := tx.Exec(ctx, "DELETE FROM github_stargazers WHERE repo_id = $1 AND login = ANY($2);", id.String(), logins)
 	if err != nil {
 		return fmt.Errorf("delete stars: %w", err)
 	}
@@ -138,28 +258,13 @@ func (w *worker) handleGitHubRepoStars(ctx context.Context, j *db.DequeueSyncJob
 		return err
 	}
 
-	if err := w.sendBatchGitHubRepoStars(ctx, tx, id, stars); err != nil {
+	if err := w.sendBatchGitHubRepoStars(ctx, tx, "github_stargazers", id, stars); err != nil {
 		return fmt.Errorf("batch insert stars: %w", err)
 	}
 
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
+	return w.sendBatchLogMessages(ctx, []*syncLog{{
 		Type:            SyncLogTypeInfo,
 		RepoSyncQueueID: j.ID,
 		Message:         fmt.Sprintf("inserted %d row(s) into github_stargazers", len(stars)),
-	}}); err != nil {
-		return err
-	}
-
-	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
-		return fmt.Errorf("sync job done: %w", err)
-	}
-
-	// indicate that we're finishing query execution
-	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
-		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
-	}}); err != nil {
-		return fmt.Errorf("send batch log messages: %w", err)
-	}
-
-	return tx.Commit(ctx)
+	}})
 }
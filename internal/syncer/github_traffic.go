@@ -0,0 +1,170 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/queries"
+	uuid "github.com/satori/go.uuid"
+)
+
+// githubTraffic is the traffic of a GitHub repo, as reported by the traffic API for the last 14 days
+type githubTraffic struct {
+	Views     []*github.TrafficData // per day
+	Clones    []*github.TrafficData // per day
+	Referrers []*github.TrafficReferrer
+	Paths     []*github.TrafficPath
+}
+
+// fetchGitHubTraffic returns the traffic of a repo using the GitHub REST API. The traffic API requires push access to the repo.
+func (w *worker) fetchGitHubTraffic(ctx context.Context, client *github.Client, repoOwner, repoName string) (*githubTraffic, error) {
+	var traffic githubTraffic
+	var daily = &github.TrafficBreakdownOptions{Per: "day"}
+
+	views, resp, err := client.Repositories.ListTrafficViews(ctx, repoOwner, repoName, daily)
+	if err != nil {
+		return nil, fmt.Errorf("views: %w", err)
+	}
+	helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+	traffic.Views = views.Views
+
+	clones, resp, err := client.Repositories.ListTrafficClones(ctx, repoOwner, repoName, daily)
+	if err != nil {
+		return nil, fmt.Errorf("clones: %w", err)
+	}
+	helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+	traffic.Clones = clones.Clones
+
+	if traffic.Referrers, resp, err = client.Repositories.ListTrafficReferrers(ctx, repoOwner, repoName); err != nil {
+		return nil, fmt.Errorf("referrers: %w", err)
+	}
+	helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+	if traffic.Paths, resp, err = client.Repositories.ListTrafficPaths(ctx, repoOwner, repoName); err != nil {
+		return nil, fmt.Errorf("paths: %w", err)
+	}
+	helper.RestRatelimitHandler(ctx, resp, w.logger, queries.NewQuerier(w.db), false)
+
+	return &traffic, nil
+}
+
+// upsertGitHubTraffic inserts the traffic of a repo, updating the days (and, for the referrers and paths, the snapshot of
+// the day) already synced. GitHub only retains 14 days of traffic, so rows are never removed: they're the whole history.
+func upsertGitHubTraffic(ctx context.Context, tx pgx.Tx, repo uuid.UUID, traffic *githubTraffic) error {
+	const upsertViews = `
+		INSERT INTO public.github_traffic_views (repo_id, day, views, unique_visitors) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (repo_id, day) DO UPDATE SET views = EXCLUDED.views, unique_visitors = EXCLUDED.unique_visitors, _mergestat_synced_at = now()`
+	for _, v := range traffic.Views {
+		if _, err := tx.Exec(ctx, upsertViews, repo, helper.GetTimeFromTimestamp(v.Timestamp), v.Count, v.Uniques); err != nil {
+			return fmt.Errorf("views: %w", err)
+		}
+	}
+
+	const upsertClones = `
+		INSERT INTO public.github_traffic_clones (repo_id, day, clones, unique_cloners) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (repo_id, day) DO UPDATE SET clones = EXCLUDED.clones, unique_cloners = EXCLUDED.unique_cloners, _mergestat_synced_at = now()`
+	for _, c := range traffic.Clones {
+		if _, err := tx.Exec(ctx, upsertClones, repo, helper.GetTimeFromTimestamp(c.Timestamp), c.Count, c.Uniques); err != nil {
+			return fmt.Errorf("clones: %w", err)
+		}
+	}
+
+	// the top referrers and paths are a ranking of the last 14 days (rather than a series), kept as a snapshot per day
+	const upsertReferrer = `
+		INSERT INTO public.github_traffic_referrers (repo_id, day, referrer, count, uniques) VALUES ($1, CURRENT_DATE, $2, $3, $4)
+		ON CONFLICT (repo_id, day, referrer) DO UPDATE SET count = EXCLUDED.count, uniques = EXCLUDED.uniques, _mergestat_synced_at = now()`
+	for _, r := range traffic.Referrers {
+		if _, err := tx.Exec(ctx, upsertReferrer, repo, r.Referrer, r.Count, r.Uniques); err != nil {
+			return fmt.Errorf("referrers: %w", err)
+		}
+	}
+
+	const upsertPath = `
+		INSERT INTO public.github_traffic_paths (repo_id, day, path, title, count, uniques) VALUES ($1, CURRENT_DATE, $2, $3, $4, $5)
+		ON CONFLICT (repo_id, day, path) DO UPDATE SET title = EXCLUDED.title, count = EXCLUDED.count, uniques = EXCLUDED.uniques, _mergestat_synced_at = now()`
+	for _, p := range traffic.Paths {
+		if _, err := tx.Exec(ctx, upsertPath, repo, p.Path, p.Title, p.Count, p.Uniques); err != nil {
+			return fmt.Errorf("paths: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (w *worker) handleGitHubTraffic(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var ghToken string
+	if _, ghToken, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	if len(ghToken) <= 0 {
+		return errGitHubTokenRequired
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var repoOwner, repoName string
+	if repoOwner, repoName, err = helper.GetRepoOwnerAndRepoName(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *github.Client
+	if client, err = w.newGitHubClient(ctx, j, ghToken); err != nil {
+		return err
+	}
+
+	traffic, err := w.fetchGitHubTraffic(ctx, client, repoOwner, repoName)
+	if err != nil {
+		return fmt.Errorf("fetch traffic: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err := upsertGitHubTraffic(ctx, tx, id, traffic); err != nil {
+		return fmt.Errorf("upsert traffic: %w", err)
+	}
+
+	l.Info().Msgf("upserted traffic views: %d days, clones: %d days, referrers: %d, paths: %d",
+		len(traffic.Views), len(traffic.Clones), len(traffic.Referrers), len(traffic.Paths))
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
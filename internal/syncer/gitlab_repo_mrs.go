@@ -0,0 +1,210 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	uuid "github.com/satori/go.uuid"
+	"github.com/xanzy/go-gitlab"
+)
+
+// gitlabProject returns the base url of the GitLab installation of a repo (e.g. https://gitlab.com) and the path of its
+// project, which (unlike the repos of other vendors) can be nested in any number of groups, e.g. group/subgroup/project
+func gitlabProject(repoURL string) (baseURL, project string, err error) {
+	var u *url.URL
+	if u, err = url.Parse(repoURL); err != nil {
+		return "", "", err
+	}
+
+	project = strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if !strings.Contains(project, "/") {
+		return "", "", fmt.Errorf("invalid gitlab project url: %s", repoURL)
+	}
+	return u.Scheme + "://" + u.Host, project, nil
+}
+
+// newGitLabClient returns a GitLab REST client of the given installation, authenticated with the personal access token
+// if one is provided
+func newGitLabClient(baseURL, token string) (*gitlab.Client, error) {
+	var client = gitlab.NewClient(http.DefaultClient, token)
+	if err := client.SetBaseURL(baseURL); err != nil {
+		return nil, fmt.Errorf("set gitlab base url: %w", err)
+	}
+	return client, nil
+}
+
+// fetchGitLabRepoMRs pages through all the merge requests (in any state) of a GitLab project
+func fetchGitLabRepoMRs(ctx context.Context, client *gitlab.Client, project string) (_ []*gitlab.MergeRequest, err error) {
+	var result []*gitlab.MergeRequest
+
+	var opts = &gitlab.ListProjectMergeRequestsOptions{State: gitlab.String("all"), ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1}}
+	for {
+		var page []*gitlab.MergeRequest
+		var resp *gitlab.Response
+		if page, resp, err = client.MergeRequests.ListProjectMergeRequests(project, opts, gitlab.WithContext(ctx)); err != nil {
+			return nil, err
+		}
+
+		result = append(result, page...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+	return result, nil
+}
+
+// sendBatchGitLabRepoMRs uses the pg COPY protocol to send a batch of GitLab merge requests
+func (w *worker) sendBatchGitLabRepoMRs(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*gitlab.MergeRequest) error {
+	cols := []string{
+		"repo_id",
+		"iid",
+		"id",
+		"title",
+		"description",
+		"state",
+		"author_username",
+		"assignee_username",
+		"source_branch",
+		"target_branch",
+		"sha",
+		"merge_commit_sha",
+		"merge_status",
+		"work_in_progress",
+		"labels",
+		"upvotes",
+		"downvotes",
+		"user_notes_count",
+		"merged_by_username",
+		"merged_at",
+		"closed_by_username",
+		"closed_at",
+		"created_at",
+		"updated_at",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, mr := range batch {
+		var labels = mr.Labels
+		if labels == nil {
+			labels = []string{}
+		}
+
+		input := []interface{}{
+			repo,
+			mr.IID,
+			mr.ID,
+			mr.Title,
+			nullIfEmpty(mr.Description),
+			mr.State,
+			nullIfEmpty(mr.Author.Username),
+			nullIfEmpty(mr.Assignee.Username),
+			mr.SourceBranch,
+			mr.TargetBranch,
+			nullIfEmpty(mr.SHA),
+			nullIfEmpty(mr.MergeCommitSHA),
+			nullIfEmpty(mr.MergeStatus),
+			mr.WorkInProgress,
+			labels,
+			mr.Upvotes,
+			mr.Downvotes,
+			mr.UserNotesCount,
+			nullIfEmpty(mr.MergedBy.Username),
+			mr.MergedAt,
+			nullIfEmpty(mr.ClosedBy.Username),
+			mr.ClosedAt,
+			mr.CreatedAt,
+			mr.UpdatedAt,
+			mr.WebURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("gitlab_merge_requests")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (w *worker) handleGitLabRepoMRs(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var token string
+	if _, token, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var baseURL, project string
+	if baseURL, project, err = gitlabProject(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *gitlab.Client
+	if client, err = newGitLabClient(baseURL, token); err != nil {
+		return err
+	}
+
+	mrs, err := fetchGitLabRepoMRs(ctx, client, project)
+	if err != nil {
+		return fmt.Errorf("fetch merge requests: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "gitlab_merge_requests"); err != nil {
+		return err
+	}
+
+	if err := w.sendBatchGitLabRepoMRs(ctx, tx, id, mrs); err != nil {
+		return fmt.Errorf("insert merge requests: %w", err)
+	}
+
+	l.Info().Msgf("inserted repo merge requests: %d", len(mrs))
+
+	if err := w.mergeStaged(ctx, tx, j, "gitlab_merge_requests", int64(len(mrs))); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
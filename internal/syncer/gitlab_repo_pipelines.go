@@ -0,0 +1,262 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	uuid "github.com/satori/go.uuid"
+	"github.com/xanzy/go-gitlab"
+)
+
+// gitlabPipeline is a pipeline of a GitLab project, and its jobs
+type gitlabPipeline struct {
+	Pipeline *gitlab.Pipeline
+	Jobs     []*gitlab.Job
+}
+
+// fetchGitLabRepoPipelines pages through all the pipelines of a GitLab project (most recent first), and fetches the
+// details and the jobs of each, which the list of pipelines doesn't include
+func (w *worker) fetchGitLabRepoPipelines(ctx context.Context, j *db.DequeueSyncJobRow, client *gitlab.Client, project string) (_ []*gitlabPipeline, err error) {
+	var ids []int
+
+	var opts = &gitlab.ListProjectPipelinesOptions{ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1}}
+	for {
+		var page gitlab.PipelineList
+		var resp *gitlab.Response
+		if page, resp, err = client.Pipelines.ListProjectPipelines(project, opts, gitlab.WithContext(ctx)); err != nil {
+			return nil, err
+		}
+
+		for _, p := range page {
+			ids = append(ids, p.ID)
+		}
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	var result = make([]*gitlabPipeline, 0, len(ids))
+	var progress = w.startProgress(ctx, j, "fetching pipelines", int64(len(ids)))
+	for i, id := range ids {
+		var p = &gitlabPipeline{}
+		if p.Pipeline, _, err = client.Pipelines.GetPipeline(project, id, gitlab.WithContext(ctx)); err != nil {
+			return nil, fmt.Errorf("get pipeline %d: %w", id, err)
+		}
+
+		var jobOpts = &gitlab.ListJobsOptions{ListOptions: gitlab.ListOptions{PerPage: 100, Page: 1}}
+		for {
+			var page []*gitlab.Job
+			var resp *gitlab.Response
+			if page, resp, err = client.Jobs.ListPipelineJobs(project, id, jobOpts, gitlab.WithContext(ctx)); err != nil {
+				return nil, fmt.Errorf("list jobs of pipeline %d: %w", id, err)
+			}
+
+			p.Jobs = append(p.Jobs, page...)
+			if resp.NextPage == 0 {
+				break
+			}
+			jobOpts.Page = resp.NextPage
+		}
+
+		result = append(result, p)
+		progress.set(ctx, int64(i+1))
+	}
+	progress.done(ctx)
+
+	return result, nil
+}
+
+// sendBatchGitLabRepoPipelines uses the pg COPY protocol to send a batch of GitLab pipelines
+func (w *worker) sendBatchGitLabRepoPipelines(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*gitlabPipeline) error {
+	cols := []string{
+		"repo_id",
+		"id",
+		"status",
+		"ref",
+		"sha",
+		"before_sha",
+		"tag",
+		"user_username",
+		"yaml_errors",
+		"created_at",
+		"updated_at",
+		"started_at",
+		"finished_at",
+		"duration",
+		"coverage",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, b := range batch {
+		var p = b.Pipeline
+		input := []interface{}{
+			repo,
+			p.ID,
+			p.Status,
+			p.Ref,
+			p.SHA,
+			nullIfEmpty(p.BeforeSHA),
+			p.Tag,
+			nullIfEmpty(p.User.Username),
+			nullIfEmpty(p.YamlErrors),
+			p.CreatedAt,
+			p.UpdatedAt,
+			p.StartedAt,
+			p.FinishedAt,
+			p.Duration,
+			nullIfEmpty(p.Coverage),
+			p.WebURL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("gitlab_pipelines")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sendBatchGitLabRepoPipelineJobs uses the pg COPY protocol to send the jobs of a batch of GitLab pipelines
+func (w *worker) sendBatchGitLabRepoPipelineJobs(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []*gitlabPipeline) (int, error) {
+	cols := []string{
+		"repo_id",
+		"pipeline_id",
+		"id",
+		"name",
+		"stage",
+		"status",
+		"ref",
+		"tag",
+		"user_username",
+		"runner_description",
+		"created_at",
+		"started_at",
+		"finished_at",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0)
+	for _, b := range batch {
+		for _, job := range b.Jobs {
+			var user *string
+			if job.User != nil {
+				user = &job.User.Username
+			}
+
+			input := []interface{}{
+				repo,
+				b.Pipeline.ID,
+				job.ID,
+				job.Name,
+				job.Stage,
+				job.Status,
+				job.Ref,
+				job.Tag,
+				user,
+				nullIfEmpty(job.Runner.Description),
+				job.CreatedAt,
+				job.StartedAt,
+				job.FinishedAt,
+				job.WebURL,
+			}
+			inputs = append(inputs, input)
+		}
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("gitlab_pipeline_jobs")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return 0, err
+	}
+	return len(inputs), nil
+}
+
+func (w *worker) handleGitLabRepoPipelines(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var token string
+	if _, token, err = w.fetchCredentials(ctx, j); err != nil {
+		return err
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var baseURL, project string
+	if baseURL, project, err = gitlabProject(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+
+	var client *gitlab.Client
+	if client, err = newGitLabClient(baseURL, token); err != nil {
+		return err
+	}
+
+	pipelines, err := w.fetchGitLabRepoPipelines(ctx, j, client, project)
+	if err != nil {
+		return fmt.Errorf("fetch pipelines: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	for _, table := range []string{"gitlab_pipelines", "gitlab_pipeline_jobs"} {
+		if err = stage(ctx, tx, table); err != nil {
+			return err
+		}
+	}
+
+	if err := w.sendBatchGitLabRepoPipelines(ctx, tx, id, pipelines); err != nil {
+		return fmt.Errorf("insert pipelines: %w", err)
+	}
+
+	var insertedJobs int
+	if insertedJobs, err = w.sendBatchGitLabRepoPipelineJobs(ctx, tx, id, pipelines); err != nil {
+		return fmt.Errorf("insert pipeline jobs: %w", err)
+	}
+
+	l.Info().Msgf("inserted repo pipelines: %d, jobs: %d", len(pipelines), insertedJobs)
+
+	// pipelines are merged before their jobs, which reference them
+	if err := w.mergeStaged(ctx, tx, j, "gitlab_pipelines", int64(len(pipelines))); err != nil {
+		return err
+	}
+	if err := w.mergeStaged(ctx, tx, j, "gitlab_pipeline_jobs", int64(insertedJobs)); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
@@ -10,7 +10,6 @@ import (
 
 	"github.com/jackc/pgx/v4"
 	"github.com/mergestat/mergestat/internal/db"
-	"github.com/mergestat/mergestat/internal/helper"
 )
 
 // handleGitleaksRepoScan executes `gitleaks detect {git-repo} -f json` for a repo
@@ -18,7 +17,7 @@ import (
 func (w *worker) handleGitleaksRepoScan(ctx context.Context, j *db.DequeueSyncJobRow) error {
 	l := w.loggerForJob(j)
 
-	tmpPath, cleanup, err := helper.CreateTempDir(os.Getenv("GIT_CLONE_PATH"), fmt.Sprintf("mergestat-repo-%s-*", j.RepoID.String()))
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
 	if err != nil {
 		return fmt.Errorf("temp dir: %w", err)
 	}
@@ -55,7 +54,7 @@ func (w *worker) handleGitleaksRepoScan(ctx context.Context, j *db.DequeueSyncJo
 	}
 
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer func() {
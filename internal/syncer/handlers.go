@@ -15,6 +15,9 @@ const (
 	SyncLogTypeInfo  syncLogType = "INFO"
 	SyncLogTypeWarn  syncLogType = "WARNING"
 	SyncLogTypeError syncLogType = "ERROR"
+
+	// SyncLogTypeProgress logs carry the structured progress of a running sync (see progressReporter)
+	SyncLogTypeProgress syncLogType = "PROGRESS"
 )
 
 const (
@@ -32,17 +35,27 @@ type syncLog struct {
 	Type            syncLogType
 	Message         string
 	RepoSyncQueueID int64
+	Progress        *progress // only set on PROGRESS logs
 }
 
 // sendBatchLogMessages uses the pg COPY protocol to send a batch of sync logs
 func (w *worker) sendBatchLogMessages(ctx context.Context, batch []*syncLog) error {
+	if phase, ok := ctx.Value(jobPhaseKey{}).(*jobPhase); ok && len(batch) > 0 {
+		phase.set(batch[len(batch)-1].Message)
+	}
+
 	inputs := make([][]interface{}, 0, len(batch))
 	for _, l := range batch {
-		input := []interface{}{l.Type, l.Message, l.RepoSyncQueueID}
+		var progress interface{}
+		if l.Progress != nil {
+			progress = l.Progress
+		}
+
+		input := []interface{}{l.Type, l.Message, l.RepoSyncQueueID, progress}
 		inputs = append(inputs, input)
 	}
 
-	if _, err := w.pool.CopyFrom(ctx, pgx.Identifier{"mergestat", "repo_sync_logs"}, []string{"log_type", "message", "repo_sync_queue_id"}, pgx.CopyFromRows(inputs)); err != nil {
+	if _, err := w.pool.CopyFrom(ctx, pgx.Identifier{"mergestat", "repo_sync_logs"}, []string{"log_type", "message", "repo_sync_queue_id", "progress"}, pgx.CopyFromRows(inputs)); err != nil {
 		return err
 	}
 	return nil
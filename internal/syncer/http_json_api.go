@@ -0,0 +1,399 @@
+package syncer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	uuid "github.com/satori/go.uuid"
+)
+
+// httpJSONAPISettings are the settings accepted by an HTTP_JSON_API repo sync, which ingests the records returned by
+// an API into a table (that the operator creates, with a repo_id column and the columns of the fields), e.g.
+//
+//	{
+//	  "url": "https://deploys.acme.com/api/{{.Owner}}/{{.Name}}/deploys",
+//	  "auth": {"type": "bearer", "variable": "DEPLOYS_TOKEN"},
+//	  "records": "$.data[*]",
+//	  "fields": {"id": "$.id", "environment": "$.env.name", "deployed_at": "$.finished_at"},
+//	  "table": "deploys",
+//	  "next": "$.links.next"
+//	}
+type httpJSONAPISettings struct {
+	// URL is a text/template of the url to request, executed with the repo (see httpJSONAPIRepo)
+	URL string `json:"url"`
+	// Method is the HTTP method of the requests, GET by default
+	Method string `json:"method"`
+	// Headers are added to the requests
+	Headers map[string]string `json:"headers"`
+	// Body is a text/template of the body of the requests (e.g. of a POST), executed with the repo
+	Body string `json:"body"`
+	// Auth is how the requests are authenticated, if at all
+	Auth *httpJSONAPIAuth `json:"auth"`
+	// Records is the JSONPath of the records in a response, $ (i.e. the response is an array of records) by default
+	Records string `json:"records"`
+	// Fields maps the columns of the table to the JSONPath of their value in a record
+	Fields map[string]string `json:"fields"`
+	// Table is the table (of the custom_syncs schema) the records are written to, replacing the ones of the repo
+	Table string `json:"table"`
+	// Next is the JSONPath of the url of the next page in a response, if the API is paginated. It has to be on the same
+	// host as the url of the API.
+	Next string `json:"next"`
+	// MaxPages is the maximum number of pages requested, 100 by default
+	MaxPages int `json:"max_pages"`
+}
+
+// httpJSONAPIAuth authenticates the requests of an HTTP_JSON_API sync with a token held by a sync variable of the repo
+type httpJSONAPIAuth struct {
+	// Type is bearer (an Authorization: Bearer header), basic (basic auth with Username) or header (the Header header)
+	Type     string `json:"type"`
+	Variable string `json:"variable"`
+	Username string `json:"username"`
+	Header   string `json:"header"`
+}
+
+// httpJSONAPIRepo is the repo the templates of an HTTP_JSON_API sync are executed with
+type httpJSONAPIRepo struct {
+	RepoID string
+	Repo   string // the url of the repo
+	Owner  string // the path of the repo, without its name, e.g. mergestat (or group/subgroup on GitLab)
+	Name   string
+}
+
+// httpJSONAPIRequest is the parsed (and validated) settings of an HTTP_JSON_API sync
+type httpJSONAPIRequest struct {
+	settings *httpJSONAPISettings
+	url      *template.Template
+	body     *template.Template
+	records  jsonPath
+	next     jsonPath
+	columns  []string // sorted, for a stable order of the columns
+	fields   map[string]jsonPath
+}
+
+// newHTTPJSONAPIRequest validates the given settings, filling in their defaults, and parses their templates and paths
+func newHTTPJSONAPIRequest(s *httpJSONAPISettings) (_ *httpJSONAPIRequest, err error) {
+	var r = &httpJSONAPIRequest{settings: s, fields: make(map[string]jsonPath, len(s.Fields))}
+
+	if s.URL == "" {
+		return nil, errors.New("an url is required")
+	}
+	if r.url, err = template.New("url").Option("missingkey=error").Parse(s.URL); err != nil {
+		return nil, fmt.Errorf("invalid url template: %w", err)
+	}
+	if r.body, err = template.New("body").Option("missingkey=error").Parse(s.Body); err != nil {
+		return nil, fmt.Errorf("invalid body template: %w", err)
+	}
+
+	if s.Method == "" {
+		s.Method = http.MethodGet
+	}
+	if s.MaxPages <= 0 {
+		s.MaxPages = 100
+	}
+
+	if a := s.Auth; a != nil {
+		switch {
+		case a.Variable == "":
+			return nil, errors.New("the auth requires the variable holding the token")
+		case a.Type == "header" && a.Header == "":
+			return nil, errors.New("the header auth requires the name of the header")
+		case a.Type != "bearer" && a.Type != "basic" && a.Type != "header":
+			return nil, fmt.Errorf("unknown auth type %q", a.Type)
+		}
+	}
+
+	if s.Records == "" {
+		s.Records = "$"
+	}
+	if r.records, err = parseJSONPath(s.Records); err != nil {
+		return nil, err
+	}
+	if s.Next != "" {
+		if r.next, err = parseJSONPath(s.Next); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = validateTable(s.Table); err != nil {
+		return nil, err
+	}
+
+	if len(s.Fields) == 0 {
+		return nil, errors.New("at least one field is required")
+	}
+	for column, p := range s.Fields {
+		if !sqlIdentifier.MatchString(column) || column == "repo_id" {
+			return nil, fmt.Errorf("invalid column %q", column)
+		}
+		if r.fields[column], err = parseJSONPath(p); err != nil {
+			return nil, err
+		}
+		r.columns = append(r.columns, column)
+	}
+	sort.Strings(r.columns)
+
+	return r, nil
+}
+
+// executeTemplate executes the given template with the repo
+func executeTemplate(t *template.Template, repo *httpJSONAPIRepo) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, repo); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// fetchHTTPJSONAPIRecords requests the API (page by page, if paginated) and returns the records it returned, mapped
+// to the columns of the table
+func (w *worker) fetchHTTPJSONAPIRecords(ctx context.Context, j *db.DequeueSyncJobRow, r *httpJSONAPIRequest, repo *httpJSONAPIRepo, token string) (_ []map[string]interface{}, err error) {
+	var target, body string
+	if target, err = executeTemplate(r.url, repo); err != nil {
+		return nil, fmt.Errorf("url template: %w", err)
+	}
+	if body, err = executeTemplate(r.body, repo); err != nil {
+		return nil, fmt.Errorf("body template: %w", err)
+	}
+
+	var records []map[string]interface{}
+	var progress = w.startProgress(ctx, j, "fetching records", 0)
+	for page := 1; target != ""; page++ {
+		if page > r.settings.MaxPages {
+			w.loggerForJob(j).Warn().Msgf("stopped after %d pages, the maximum", r.settings.MaxPages)
+			break
+		}
+
+		var doc interface{}
+		if doc, err = r.do(ctx, target, body, token); err != nil {
+			return nil, fmt.Errorf("page %d: %w", page, err)
+		}
+
+		var values = r.records.eval(doc)
+		if len(values) == 1 && !r.records.hasWildcard() {
+			// the path selects the array of the records rather than each of them
+			if array, ok := values[0].([]interface{}); ok {
+				values = array
+			}
+		}
+
+		for _, v := range values {
+			var record = make(map[string]interface{}, len(r.columns))
+			for _, column := range r.columns {
+				var p = r.fields[column]
+				if matches := p.eval(v); p.hasWildcard() {
+					record[column] = matches
+				} else if len(matches) > 0 {
+					record[column] = matches[0]
+				}
+			}
+			records = append(records, record)
+		}
+		progress.set(ctx, int64(len(records)))
+
+		var current = target
+		target = ""
+		if r.next != nil {
+			if next := r.next.eval(doc); len(next) > 0 {
+				if s, ok := next[0].(string); ok && s != "" {
+					// the url of the next page can be relative to the one of the current page
+					var base, ref *url.URL
+					if base, err = url.Parse(current); err != nil {
+						return nil, err
+					}
+					if ref, err = url.Parse(s); err != nil {
+						return nil, fmt.Errorf("invalid url of the next page: %w", err)
+					}
+					// the credential of the API is sent along with every request, so pages are only ever fetched
+					// from the host of the (templated) url of the API
+					var resolved = base.ResolveReference(ref)
+					if !sameOrigin(base, resolved) {
+						return nil, fmt.Errorf("the url of the next page is on another host: %s://%s", resolved.Scheme, resolved.Host)
+					}
+					target = resolved.String()
+				}
+			}
+		}
+	}
+	progress.done(ctx)
+
+	return records, nil
+}
+
+// httpJSONAPIMaxResponse is the maximum size of a response (i.e. of a page of records) of an HTTP_JSON_API sync
+const httpJSONAPIMaxResponse = 64 * 1024 * 1024
+
+// httpJSONAPIClient is the client of the requests of HTTP_JSON_API syncs, which doesn't follow redirects to other
+// hosts, as they would be sent the credential of the API (in a custom header, which isn't dropped as Authorization is).
+// Requests time out, so that an API that never responds can't hold the job (and its slot of the worker) forever.
+var httpJSONAPIClient = &http.Client{
+	Timeout: 2 * time.Minute,
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		if !sameOrigin(via[0].URL, req.URL) {
+			return fmt.Errorf("redirected to another host: %s://%s", req.URL.Scheme, req.URL.Host)
+		}
+		return nil
+	},
+}
+
+// sameOrigin reports whether both urls have the same scheme and host
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+// do sends a request to the given url, and decodes its (JSON) response
+func (r *httpJSONAPIRequest) do(ctx context.Context, target, body, token string) (interface{}, error) {
+	var reader io.Reader = http.NoBody
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.settings.Method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range r.settings.Headers {
+		req.Header.Set(k, v)
+	}
+
+	if a := r.settings.Auth; a != nil {
+		switch a.Type {
+		case "bearer":
+			req.Header.Set("Authorization", "Bearer "+token)
+		case "basic":
+			req.SetBasicAuth(a.Username, token)
+		case "header":
+			req.Header.Set(a.Header, token)
+		}
+	}
+
+	resp, err := httpJSONAPIClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	// the response is read whole (up to the max size) before being decoded, so that it's reported as too large rather
+	// than as truncated JSON
+	var data []byte
+	if data, err = io.ReadAll(io.LimitReader(resp.Body, httpJSONAPIMaxResponse+1)); err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+	if len(data) > httpJSONAPIMaxResponse {
+		return nil, fmt.Errorf("response larger than %d bytes", httpJSONAPIMaxResponse)
+	}
+
+	// numbers are kept as they are (rather than as float64), so that large ids don't lose precision
+	var doc interface{}
+	var decoder = json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err = decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+	return doc, nil
+}
+
+// handleHTTPJSONAPI syncs the records of an API configured by the settings of the sync (see httpJSONAPISettings) into a
+// table, so that small bespoke APIs (e.g. of an internal build system or deploy tracker) can be ingested without code.
+func (w *worker) handleHTTPJSONAPI(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var settings httpJSONAPISettings
+	if err = decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	var request *httpJSONAPIRequest
+	if request, err = newHTTPJSONAPIRequest(&settings); err != nil {
+		return fmt.Errorf("invalid settings: %w", err)
+	}
+
+	var token string
+	if settings.Auth != nil {
+		var found bool
+		if token, found, err = w.db.FetchRepoSyncVar(ctx, j.RepoID, settings.Auth.Variable); err != nil {
+			return fmt.Errorf("fetch sync variable: %w", err)
+		} else if !found {
+			return fmt.Errorf("the %s sync variable of the repo is not set", settings.Auth.Variable)
+		}
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	var u *url.URL
+	if u, err = url.Parse(j.Repo); err != nil {
+		return fmt.Errorf("url parse: %w", err)
+	}
+	var repoPath = strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	var repo = &httpJSONAPIRepo{RepoID: id.String(), Repo: j.Repo, Owner: path.Dir(repoPath), Name: path.Base(repoPath)}
+
+	records, err := w.fetchHTTPJSONAPIRecords(ctx, j, request, repo, token)
+	if err != nil {
+		return fmt.Errorf("fetch records: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = replaceJSONRecords(ctx, tx, settings.Table, request.columns, id, records); err != nil {
+		return fmt.Errorf("replace records: %w", err)
+	}
+
+	l.Info().Msgf("inserted records into %s: %d", settings.Table, len(records))
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
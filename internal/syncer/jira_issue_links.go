@@ -0,0 +1,353 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	jira "github.com/mergestat/mergestat/internal/vendors/jira/client"
+	uuid "github.com/satori/go.uuid"
+	"golang.org/x/oauth2"
+)
+
+// jiraKey matches the key of a Jira issue, e.g. PROJ-123
+var jiraKey = regexp.MustCompile(`\b[A-Z][A-Z0-9_]+-[1-9][0-9]*\b`)
+
+// jiraIssueLinksSettings are the settings accepted by a JIRA_ISSUE_LINKS repo sync
+type jiraIssueLinksSettings struct {
+	// URL is the base url of the Jira instance, e.g. https://acme.atlassian.net
+	URL string `json:"url"`
+	// Email is the email of the Atlassian account of the API token (for Jira Cloud), if not set the token is used as a
+	// personal access token (of Jira Data Center)
+	Email string `json:"email"`
+	// TokenVariable is the sync variable of the repo holding the API token, JIRA_API_TOKEN by default
+	TokenVariable string `json:"token_variable"`
+	// StoryPointsField is the id of the custom field of the story points, customfield_10016 by default
+	StoryPointsField string `json:"story_points_field"`
+	// Projects are the keys of the Jira projects to link, if set the keys of other projects are ignored
+	Projects []string `json:"projects"`
+}
+
+// validate checks the settings, and fills in the defaults
+func (s *jiraIssueLinksSettings) validate() error {
+	if u, err := url.Parse(s.URL); err != nil || u.Host == "" {
+		return fmt.Errorf("invalid jira url %q", s.URL)
+	}
+	if s.TokenVariable == "" {
+		s.TokenVariable = "JIRA_API_TOKEN"
+	}
+	if s.StoryPointsField == "" {
+		s.StoryPointsField = "customfield_10016"
+	}
+	return nil
+}
+
+// jiraIssueRef is a reference to a Jira issue found in a commit message, the name of a branch or the title of a PR
+type jiraIssueRef struct {
+	SourceType string // commit, branch or pull_request
+	SourceID   string // the hash of the commit, the name of the branch or the number of the PR
+	IssueKey   string
+}
+
+// extractJiraKeys returns the (distinct) keys of Jira issues referenced in s, restricted to the given projects if any
+func extractJiraKeys(s string, projects []string) []string {
+	var keys []string
+	var seen = make(map[string]bool)
+	for _, key := range jiraKey.FindAllString(s, -1) {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		var project = key[:strings.LastIndex(key, "-")]
+		if len(projects) > 0 && !containsString(projects, project) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// containsString reports whether s is one of the values
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// jiraIssueRefSources are the queries of the texts (of a repo) referencing Jira issues, by the table they read from, as
+// synced by the GIT_COMMITS, GIT_REFS and PR syncs (of any provider). Branch names are often lower-cased (e.g.
+// feature/proj-123-title), so they're matched upper-cased.
+var jiraIssueRefSources = []struct{ table, query string }{
+	{"public.git_commits", `SELECT 'commit', hash, message FROM public.git_commits WHERE repo_id = $1 AND _deleted_at IS NULL`},
+	{"public.git_refs", `SELECT 'branch', name, UPPER(name) FROM public.git_refs WHERE repo_id = $1 AND type = 'branch' AND name IS NOT NULL AND _deleted_at IS NULL`},
+	{"public.pull_requests", `SELECT 'pull_request', number::TEXT, title FROM public.pull_requests WHERE repo_id = $1 AND title IS NOT NULL`},
+}
+
+// fetchJiraIssueRefs returns the references to Jira issues in the commit messages, branch names and PR titles of a repo
+func (w *worker) fetchJiraIssueRefs(ctx context.Context, repo uuid.UUID, projects []string) (_ []jiraIssueRef, err error) {
+	var refs []jiraIssueRef
+	var seen = make(map[jiraIssueRef]bool)
+	for _, source := range jiraIssueRefSources {
+		// the tables of syncs that never ran may have been dropped by 900000000000059_remove_empty_tables
+		var exists bool
+		if err = w.pool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, source.table).Scan(&exists); err != nil {
+			return nil, err
+		} else if !exists {
+			continue
+		}
+
+		var rows pgx.Rows
+		if rows, err = w.pool.Query(ctx, source.query, repo); err != nil {
+			return nil, fmt.Errorf("%s: %w", source.table, err)
+		}
+
+		for rows.Next() {
+			var sourceType, sourceID, text string
+			if err = rows.Scan(&sourceType, &sourceID, &text); err != nil {
+				rows.Close()
+				return nil, err
+			}
+
+			for _, key := range extractJiraKeys(text, projects) {
+				var ref = jiraIssueRef{SourceType: sourceType, SourceID: sourceID, IssueKey: key}
+				if !seen[ref] {
+					seen[ref] = true
+					refs = append(refs, ref)
+				}
+			}
+		}
+		rows.Close()
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return refs, nil
+}
+
+// fetchJiraIssues resolves the given keys against the Jira instance. Keys without an issue (most likely false positives,
+// such as UTF-8) are left out.
+func (w *worker) fetchJiraIssues(ctx context.Context, j *db.DequeueSyncJobRow, client *jira.Client, keys []string, storyPointsField string) (_ map[string]*jira.Issue, err error) {
+	var issues = make(map[string]*jira.Issue, len(keys))
+	var progress = w.startProgress(ctx, j, "fetching jira issues", int64(len(keys)))
+	for i, key := range keys {
+		var issue *jira.Issue
+		if issue, err = client.Issues().Get(ctx, key, storyPointsField); err == nil {
+			issues[key] = issue
+		} else if !errors.Is(err, jira.ErrNotFound) {
+			return nil, fmt.Errorf("get issue %s: %w", key, err)
+		}
+		progress.set(ctx, int64(i+1))
+	}
+	progress.done(ctx)
+
+	return issues, nil
+}
+
+// sendBatchJiraIssueLinks uses the pg COPY protocol to send a batch of references to Jira issues, along with the issues
+// they resolved to
+func (w *worker) sendBatchJiraIssueLinks(ctx context.Context, tx pgx.Tx, repo uuid.UUID, batch []jiraIssueRef, issues map[string]*jira.Issue, storyPointsField string) (int, error) {
+	cols := []string{
+		"repo_id",
+		"source_type",
+		"source_id",
+		"issue_key",
+		"issue_id",
+		"current_key",
+		"project_key",
+		"summary",
+		"issue_type",
+		"status",
+		"status_category",
+		"priority",
+		"assignee",
+		"story_points",
+		"created_at",
+		"updated_at",
+		"resolved_at",
+		"url",
+	}
+
+	inputs := make([][]interface{}, 0, len(batch))
+	for _, ref := range batch {
+		var issue, ok = issues[ref.IssueKey]
+		if !ok {
+			continue
+		}
+
+		var f = &issue.Fields
+		var issueType, status, statusCategory, priority, assignee, project *string
+		if f.IssueType != nil {
+			issueType = &f.IssueType.Name
+		}
+		if f.Status != nil {
+			status = &f.Status.Name
+			if f.Status.StatusCategory != nil {
+				statusCategory = &f.Status.StatusCategory.Key
+			}
+		}
+		if f.Priority != nil {
+			priority = &f.Priority.Name
+		}
+		if f.Assignee != nil {
+			assignee = &f.Assignee.DisplayName
+		}
+		if f.Project != nil {
+			project = &f.Project.Key
+		}
+
+		// story points are a number field, null (or missing) when not estimated
+		var storyPoints *float64
+		if raw, ok := issue.CustomFields[storyPointsField]; ok {
+			_ = json.Unmarshal(raw, &storyPoints)
+		}
+
+		input := []interface{}{
+			repo,
+			ref.SourceType,
+			ref.SourceID,
+			ref.IssueKey,
+			issue.ID,
+			issue.Key,
+			project,
+			f.Summary,
+			issueType,
+			status,
+			statusCategory,
+			priority,
+			assignee,
+			storyPoints,
+			jiraTime(f.Created),
+			jiraTime(f.Updated),
+			jiraTime(f.ResolutionDate),
+			issue.URL,
+		}
+		inputs = append(inputs, input)
+	}
+
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{staging("jira_issue_links")}, cols, pgx.CopyFromRows(inputs)); err != nil {
+		return 0, err
+	}
+	return len(inputs), nil
+}
+
+// jiraTime returns the timestamp of t, or nil if t isn't set
+func jiraTime(t *jira.Time) interface{} {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return t.Time
+}
+
+// handleJiraIssueLinks links the commits, branches and PRs of a repo to the Jira issues they reference (by key), and
+// syncs the planning data (status, type, story points) of these issues from the Jira instance of the sync's settings.
+func (w *worker) handleJiraIssueLinks(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var settings jiraIssueLinksSettings
+	if err = decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	if err = settings.validate(); err != nil {
+		return err
+	}
+
+	var token string
+	var found bool
+	if token, found, err = w.db.FetchRepoSyncVar(ctx, j.RepoID, settings.TokenVariable); err != nil {
+		return fmt.Errorf("fetch sync variable: %w", err)
+	} else if !found {
+		return fmt.Errorf("in order to run this syncer, the %s sync variable of the repo must hold a Jira API token", settings.TokenVariable)
+	}
+
+	id, err := uuid.FromString(j.RepoID.String())
+	if err != nil {
+		return fmt.Errorf("parse uuid: %w", err)
+	}
+
+	refs, err := w.fetchJiraIssueRefs(ctx, id, settings.Projects)
+	if err != nil {
+		return fmt.Errorf("fetch issue references: %w", err)
+	}
+
+	var keys []string
+	var seen = make(map[string]bool)
+	for _, ref := range refs {
+		if !seen[ref.IssueKey] {
+			seen[ref.IssueKey] = true
+			keys = append(keys, ref.IssueKey)
+		}
+	}
+	sort.Strings(keys)
+
+	var base, _ = url.Parse(settings.URL)
+	var tokenSource oauth2.TokenSource = &jira.PersonalAccessToken{Value: token}
+	if settings.Email != "" {
+		tokenSource = &jira.APIToken{Email: settings.Email, Value: token}
+	}
+	var client = jira.New(base, oauth2.NewClient(ctx, tokenSource))
+
+	issues, err := w.fetchJiraIssues(ctx, j, client, keys, settings.StoryPointsField)
+	if err != nil {
+		return fmt.Errorf("fetch issues: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "jira_issue_links"); err != nil {
+		return err
+	}
+
+	var inserted int
+	if inserted, err = w.sendBatchJiraIssueLinks(ctx, tx, id, refs, issues, settings.StoryPointsField); err != nil {
+		return fmt.Errorf("insert issue links: %w", err)
+	}
+
+	l.Info().Msgf("inserted jira issue links: %d, of issues: %d (of %d keys referenced)", inserted, len(issues), len(keys))
+
+	if err := w.mergeStaged(ctx, tx, j, "jira_issue_links", int64(inserted)); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
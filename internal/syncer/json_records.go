@@ -0,0 +1,103 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+	uuid "github.com/satori/go.uuid"
+)
+
+// sqlIdentifier matches the (unquoted, lower-case) identifiers accepted for the target tables of the syncs configured
+// by their settings (HTTP_JSON_API and CONTAINER_EXEC), and for their columns
+var sqlIdentifier = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// customSyncsSchema is the schema of the target tables of the syncs configured by their settings. Keeping them in a
+// schema of their own keeps those syncs (which replace the rows of the repo in their table) from writing to the tables
+// of the built-in syncs, or of mergestat itself.
+const customSyncsSchema = "custom_syncs"
+
+// validateTable checks that the given name of a target table is a valid identifier, optionally qualified with the
+// schema of the target tables (see customSyncsSchema)
+func validateTable(name string) error {
+	var parts = strings.Split(name, ".")
+	if len(parts) > 2 || !sqlIdentifier.MatchString(parts[len(parts)-1]) {
+		return fmt.Errorf("invalid table %q", name)
+	}
+	if len(parts) == 2 && parts[0] != customSyncsSchema {
+		return fmt.Errorf("invalid table %q, it has to be in the %s schema", name, customSyncsSchema)
+	}
+	return nil
+}
+
+// qualifiedTable returns the sanitized (quoted) name of the given target table, in the schema of the target tables
+func qualifiedTable(name string) string {
+	var parts = strings.Split(name, ".")
+	return pgx.Identifier{customSyncsSchema, parts[len(parts)-1]}.Sanitize()
+}
+
+// tableColumns returns the columns of the given target table, or an error if it doesn't exist
+func tableColumns(ctx context.Context, tx pgx.Tx, table string) (_ []string, err error) {
+	const query = `SELECT attname FROM pg_attribute WHERE attrelid = to_regclass($1) AND attnum > 0 AND NOT attisdropped ORDER BY attnum`
+
+	var rows pgx.Rows
+	if rows, err = tx.Query(ctx, query, qualifiedTable(table)); err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err = rows.Scan(&column); err != nil {
+			return nil, err
+		}
+		columns = append(columns, column)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s does not exist, it has to be created (with a repo_id column) before the sync runs", qualifiedTable(table))
+	}
+	return columns, nil
+}
+
+// replaceJSONRecords replaces the rows of the repo in the given target table with the given records, setting the given
+// columns. The records are sent as a JSON array, and cast to the types of the columns by jsonb_populate_recordset.
+func replaceJSONRecords(ctx context.Context, tx pgx.Tx, table string, columns []string, repo uuid.UUID, records []map[string]interface{}) (err error) {
+	if _, err = tableColumns(ctx, tx, table); err != nil {
+		return err
+	}
+
+	if _, err = tx.Exec(ctx, fmt.Sprintf(`DELETE FROM %s WHERE repo_id = $1`, qualifiedTable(table)), repo); err != nil {
+		return fmt.Errorf("delete: %w", err)
+	}
+
+	if len(records) == 0 || len(columns) == 0 {
+		return nil
+	}
+
+	var payload []byte
+	if payload, err = json.Marshal(records); err != nil {
+		return err
+	}
+
+	var sanitized = make([]string, 0, len(columns))
+	for _, c := range columns {
+		sanitized = append(sanitized, pgx.Identifier{c}.Sanitize())
+	}
+	var list = strings.Join(sanitized, ", ")
+
+	var insert = fmt.Sprintf(`INSERT INTO %s (repo_id, %s) SELECT $1, %s FROM jsonb_populate_recordset(NULL::%s, $2::jsonb)`,
+		qualifiedTable(table), list, list, qualifiedTable(table))
+	if _, err = tx.Exec(ctx, insert, repo, string(payload)); err != nil {
+		return fmt.Errorf("insert: %w", err)
+	}
+
+	return nil
+}
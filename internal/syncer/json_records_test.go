@@ -0,0 +1,42 @@
+package syncer
+
+import "testing"
+
+func TestValidateTable(t *testing.T) {
+	var tests = []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "deploys"},
+		{name: "semgrep_findings"},
+		{name: "custom_syncs.deploys"},
+		{name: "custom_syncs.x"},
+		{name: "public.repos", wantErr: true},
+		{name: "mergestat.repo_syncs", wantErr: true},
+		{name: "a.b.c", wantErr: true},
+		{name: "custom_syncs.deploys.x", wantErr: true},
+		{name: "custom_syncs.", wantErr: true},
+		{name: ".deploys", wantErr: true},
+		{name: "", wantErr: true},
+		{name: "Deploys", wantErr: true},
+		{name: "1deploys", wantErr: true},
+		{name: `deploys"; DROP TABLE public.repos; --`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		if err := validateTable(test.name); (err != nil) != test.wantErr {
+			t.Errorf("validateTable(%q) error = %v, wantErr %v", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestQualifiedTable(t *testing.T) {
+	for name, want := range map[string]string{
+		"deploys":              `"custom_syncs"."deploys"`,
+		"custom_syncs.deploys": `"custom_syncs"."deploys"`,
+	} {
+		if got := qualifiedTable(name); got != want {
+			t.Errorf("qualifiedTable(%q) = %s, want %s", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package syncer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonPathStep is a step of a JSONPath, selecting a member of an object (by name), an element of an array (by index),
+// or all the members (or elements) of the value with a wildcard
+type jsonPathStep struct {
+	name     string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// jsonPath is a parsed JSONPath, of the subset made of member names ($.a.b or $['a']['b']), array indexes ($.a[0])
+// and wildcards ($.a[*] or $.a.*), which covers the paths needed to map the fields of the responses of most APIs
+type jsonPath []jsonPathStep
+
+// parseJSONPath parses the given JSONPath, which has to start with $ (the root of the document)
+func parseJSONPath(path string) (jsonPath, error) {
+	var s = strings.TrimSpace(path)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("invalid json path %q: it must start with $", path)
+	}
+	s = s[1:]
+
+	var steps jsonPath
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, "."):
+			var end = strings.IndexAny(s[1:], ".[")
+			if end < 0 {
+				end = len(s) - 1
+			}
+			var name = s[1 : end+1]
+			if name == "" {
+				return nil, fmt.Errorf("invalid json path %q: empty member name", path)
+			}
+			steps = append(steps, jsonPathStep{name: name, wildcard: name == "*"})
+			s = s[end+1:]
+		case strings.HasPrefix(s, "["):
+			var end = strings.Index(s, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("invalid json path %q: unterminated [", path)
+			}
+			var selector = strings.TrimSpace(s[1:end])
+			switch {
+			case selector == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(selector) >= 2 && (selector[0] == '\'' || selector[0] == '"') && selector[len(selector)-1] == selector[0]:
+				steps = append(steps, jsonPathStep{name: selector[1 : len(selector)-1]})
+			default:
+				var index, err = strconv.Atoi(selector)
+				if err != nil {
+					return nil, fmt.Errorf("invalid json path %q: unsupported selector [%s]", path, selector)
+				}
+				steps = append(steps, jsonPathStep{index: index, isIndex: true})
+			}
+			s = s[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid json path %q: unexpected %q", path, s)
+		}
+	}
+
+	return steps, nil
+}
+
+// hasWildcard reports whether the path can select more than one value
+func (p jsonPath) hasWildcard() bool {
+	for _, step := range p {
+		if step.wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// eval returns the values the path selects in the given document (as decoded by encoding/json), which are none if
+// the path doesn't match
+func (p jsonPath) eval(doc interface{}) []interface{} {
+	var values = []interface{}{doc}
+	for _, step := range p {
+		var next []interface{}
+		for _, v := range values {
+			switch v := v.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					for _, member := range v {
+						next = append(next, member)
+					}
+				} else if member, ok := v[step.name]; ok && !step.isIndex {
+					next = append(next, member)
+				}
+			case []interface{}:
+				if step.wildcard {
+					next = append(next, v...)
+				} else if step.isIndex {
+					var i = step.index
+					if i < 0 {
+						i += len(v) // negative indexes count from the end
+					}
+					if i >= 0 && i < len(v) {
+						next = append(next, v[i])
+					}
+				}
+			}
+		}
+		values = next
+	}
+	return values
+}
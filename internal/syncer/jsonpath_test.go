@@ -0,0 +1,111 @@
+package syncer
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	var tests = []struct {
+		path    string
+		want    jsonPath
+		wantErr bool
+	}{
+		{path: "$", want: nil},
+		{path: " $.a.b ", want: jsonPath{{name: "a"}, {name: "b"}}},
+		{path: "$['a']", want: jsonPath{{name: "a"}}},
+		{path: `$["a.b"]`, want: jsonPath{{name: "a.b"}}},
+		{path: "$.a[0]", want: jsonPath{{name: "a"}, {index: 0, isIndex: true}}},
+		{path: "$.a[-1]", want: jsonPath{{name: "a"}, {index: -1, isIndex: true}}},
+		{path: "$.a[ 2 ]", want: jsonPath{{name: "a"}, {index: 2, isIndex: true}}},
+		{path: "$.a[*].b", want: jsonPath{{name: "a"}, {wildcard: true}, {name: "b"}}},
+		{path: "$.a.*", want: jsonPath{{name: "a"}, {name: "*", wildcard: true}}},
+		{path: "a.b", wantErr: true},
+		{path: "", wantErr: true},
+		{path: "$a", wantErr: true},
+		{path: "$.", wantErr: true},
+		{path: "$..a", wantErr: true},
+		{path: "$.a[0", wantErr: true},
+		{path: "$.a[b]", wantErr: true},
+		{path: "$.a['b]", wantErr: true},
+		{path: "$.a[1:2]", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			got, err := parseJSONPath(test.path)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("parseJSONPath() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if !test.wantErr && !reflect.DeepEqual(got, test.want) {
+				t.Errorf("parseJSONPath() = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestJSONPathEval(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{
+		"data": [{"id": 1, "env": {"name": "prod"}}, {"id": 2, "env": {"name": "staging"}}, {"id": 3}],
+		"links": {"next": "/page/2"},
+		"a.b": "dotted"
+	}`), &doc); err != nil {
+		t.Fatalf("failed to decode document: %v", err)
+	}
+
+	var tests = []struct {
+		path         string
+		want         []interface{}
+		wantWildcard bool
+	}{
+		{path: "$.links.next", want: []interface{}{"/page/2"}},
+		{path: "$['links']['next']", want: []interface{}{"/page/2"}},
+		{path: "$['a.b']", want: []interface{}{"dotted"}},
+		{path: "$.data[0].id", want: []interface{}{1.0}},
+		{path: "$.data[-1].id", want: []interface{}{3.0}},
+		{path: "$.data[-3].id", want: []interface{}{1.0}},
+		{path: "$.data[3].id", want: nil},
+		{path: "$.data[-4].id", want: nil},
+		{path: "$.data[*].id", want: []interface{}{1.0, 2.0, 3.0}, wantWildcard: true},
+		{path: "$.data[*].env.name", want: []interface{}{"prod", "staging"}, wantWildcard: true},
+		{path: "$.links.*", want: []interface{}{"/page/2"}, wantWildcard: true},
+		{path: "$.links[0]", want: nil},
+		{path: "$.data.id", want: nil},
+		{path: "$.missing", want: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.path, func(t *testing.T) {
+			p, err := parseJSONPath(test.path)
+			if err != nil {
+				t.Fatalf("parseJSONPath() error = %v", err)
+			}
+			if got := p.hasWildcard(); got != test.wantWildcard {
+				t.Errorf("hasWildcard() = %v, want %v", got, test.wantWildcard)
+			}
+			if got := p.eval(doc); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("eval() = %v, want %v", got, test.want)
+			}
+		})
+	}
+
+	// the members of an object selected with a wildcard come in no particular order
+	p, _ := parseJSONPath("$.data[0].*")
+	var got = p.eval(doc)
+	if len(got) != 2 {
+		t.Fatalf("eval() = %v, want the 2 members of the object", got)
+	}
+	var kinds = []string{reflect.TypeOf(got[0]).Kind().String(), reflect.TypeOf(got[1]).Kind().String()}
+	sort.Strings(kinds)
+	if !reflect.DeepEqual(kinds, []string{"float64", "map"}) {
+		t.Errorf("eval() = %v, want the id and env of the object", got)
+	}
+
+	// the whole document is selected by the root
+	if got := (jsonPath{}).eval(doc); len(got) != 1 || !reflect.DeepEqual(got[0], doc) {
+		t.Errorf("eval() of $ = %v, want the document", got)
+	}
+}
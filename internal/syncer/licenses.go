@@ -0,0 +1,202 @@
+package syncer
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// licenseSourceFile is the source of the licenses detected from the text of a license file (e.g. LICENSE)
+	licenseSourceFile = "license_file"
+
+	// licenseSourceSPDX is the source of the licenses declared by an SPDX-License-Identifier tag
+	licenseSourceSPDX = "spdx_identifier"
+
+	// noAssertion is the SPDX identifier of a license that could not be determined
+	noAssertion = "NOASSERTION"
+
+	// licenseMinConfidence is the confidence below which the text of a license file isn't considered a match
+	licenseMinConfidence = 0.5
+)
+
+// detectedLicense is a license detected in a file
+type detectedLicense struct {
+	Path       string
+	SPDXID     string
+	Confidence float64
+	Source     string // licenseSourceFile or licenseSourceSPDX
+	Expression string // the full SPDX license expression, for licenses declared by an SPDX-License-Identifier tag
+}
+
+// licenseText is the fingerprint of a license: phrases distinctive of its text, normalized by normalizeLicenseText
+type licenseText struct {
+	SPDXID  string
+	Phrases []string
+}
+
+// knownLicenses are the licenses recognized in license files. The confidence of a match is the fraction of the
+// phrases of the license found in the file. When several licenses match equally, the one with the most phrases (the
+// most specific) wins, so that e.g. BSD-3-Clause is preferred over BSD-2-Clause, whose phrases it contains.
+var knownLicenses = []licenseText{
+	{"MIT", []string{
+		"permission is hereby granted free of charge to any person obtaining a copy",
+		"the above copyright notice and this permission notice shall be included in all copies or substantial portions of the software",
+		"the software is provided as is without warranty of any kind express or implied",
+	}},
+	{"ISC", []string{
+		"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted",
+		"provided that the above copyright notice and this permission notice appear in all copies",
+		"the software is provided as is and the author disclaims all warranties",
+	}},
+	{"0BSD", []string{
+		"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted",
+		"the software is provided as is and the author disclaims all warranties",
+	}},
+	{"BSD-2-Clause", []string{
+		"redistribution and use in source and binary forms with or without modification are permitted provided that the following conditions are met",
+		"redistributions of source code must retain the above copyright notice",
+		"redistributions in binary form must reproduce the above copyright notice",
+	}},
+	{"BSD-3-Clause", []string{
+		"redistribution and use in source and binary forms with or without modification are permitted provided that the following conditions are met",
+		"redistributions of source code must retain the above copyright notice",
+		"redistributions in binary form must reproduce the above copyright notice",
+		"may be used to endorse or promote products derived from this software without specific prior written permission",
+	}},
+	{"Apache-2.0", []string{
+		"apache license version 2 0 january 2004",
+		"terms and conditions for use reproduction and distribution",
+		"grant of patent license",
+	}},
+	{"GPL-2.0", []string{
+		"gnu general public license",
+		"version 2 june 1991",
+		"this general public license applies to most of the free software foundation s software",
+	}},
+	{"GPL-3.0", []string{
+		"gnu general public license",
+		"version 3 29 june 2007",
+		"the gnu general public license is a free copyleft license for software and other kinds of works",
+	}},
+	{"LGPL-2.1", []string{
+		"gnu lesser general public license",
+		"version 2 1 february 1999",
+	}},
+	{"LGPL-3.0", []string{
+		"gnu lesser general public license",
+		"version 3 29 june 2007",
+		"this version of the gnu lesser general public license incorporates the terms and conditions of version 3 of the gnu general public license",
+	}},
+	{"AGPL-3.0", []string{
+		"gnu affero general public license",
+		"version 3 19 november 2007",
+		"the gnu affero general public license is a free copyleft license for software and other kinds of works",
+	}},
+	{"MPL-2.0", []string{
+		"mozilla public license version 2 0",
+		"covered software",
+		"this source code form is subject to the terms of the mozilla public license v 2 0",
+	}},
+	{"EPL-1.0", []string{
+		"eclipse public license v 1 0",
+		"the accompanying program is provided under the terms of this eclipse public license",
+	}},
+	{"EPL-2.0", []string{
+		"eclipse public license v 2 0",
+		"the accompanying program is provided under the terms of this eclipse public license",
+	}},
+	{"BSL-1.0", []string{
+		"boost software license version 1 0 august 17th 2003",
+		"permission is hereby granted free of charge to any person or organization obtaining a copy of the software and accompanying documentation covered by this license",
+	}},
+	{"Unlicense", []string{
+		"this is free and unencumbered software released into the public domain",
+		"anyone is free to copy modify publish use compile sell or distribute this software",
+	}},
+	{"CC0-1.0", []string{
+		"creative commons legal code",
+		"cc0 1 0 universal",
+	}},
+}
+
+var (
+	// licenseFileName matches the names of license files, e.g. LICENSE, LICENSE.md, LICENSE-MIT, COPYING or UNLICENSE
+	// (but not source files, like license.go)
+	licenseFileName = regexp.MustCompile(`(?i)^(un)?(licen[cs]e|copying)([-_][\w.-]+)?(\.(md|markdown|txt|rst|adoc|html|lesser))?$`)
+
+	// spdxTag matches an SPDX-License-Identifier tag, capturing the license expression
+	spdxTag = regexp.MustCompile(`SPDX-License-Identifier:\s*([^\r\n]*)`)
+
+	// nonAlphanumeric matches the characters removed (along with casing) by normalizeLicenseText
+	nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// isLicenseFile returns whether the file at the given path is a license file
+func isLicenseFile(filePath string) bool {
+	return licenseFileName.MatchString(path.Base(filePath))
+}
+
+// normalizeLicenseText normalizes the text of a license, so that it's matched regardless of its formatting (line
+// wrapping, punctuation, casing or the british spelling of licence)
+func normalizeLicenseText(text string) string {
+	text = strings.ReplaceAll(strings.ToLower(text), "licence", "license")
+	return strings.TrimSpace(nonAlphanumeric.ReplaceAllString(text, " "))
+}
+
+// classifyLicenseText returns the best match of the known licenses for the text of a license file, or NOASSERTION if
+// none of them is a match
+func classifyLicenseText(filePath, text string) detectedLicense {
+	var normalized = normalizeLicenseText(text)
+
+	var best = detectedLicense{Path: filePath, SPDXID: noAssertion, Source: licenseSourceFile}
+	var bestPhrases int
+	for _, license := range knownLicenses {
+		var matched int
+		for _, phrase := range license.Phrases {
+			if strings.Contains(normalized, phrase) {
+				matched++
+			}
+		}
+
+		var confidence = float64(matched) / float64(len(license.Phrases))
+		if confidence < licenseMinConfidence {
+			continue
+		}
+		if confidence > best.Confidence || (confidence == best.Confidence && len(license.Phrases) > bestPhrases) {
+			best.SPDXID, best.Confidence, bestPhrases = license.SPDXID, confidence, len(license.Phrases)
+		}
+	}
+	return best
+}
+
+// parseSPDXTag returns the licenses declared by the SPDX-License-Identifier tag of the given contents (if any). Each of
+// the licenses of a compound expression (e.g. MIT OR Apache-2.0) is returned, the exceptions (of WITH) excluded.
+func parseSPDXTag(filePath, contents string) []detectedLicense {
+	var m = spdxTag.FindStringSubmatch(contents)
+	if m == nil {
+		return nil
+	}
+
+	// the tag is usually within a comment, whose end is not part of the expression
+	var expression = strings.TrimSpace(strings.NewReplacer("*/", "", "-->", "", "#}", "", "--}", "").Replace(m[1]))
+
+	var licenses []detectedLicense
+	var seen = make(map[string]bool)
+	var fields = strings.Fields(strings.NewReplacer("(", " ", ")", " ").Replace(expression))
+	for i := 0; i < len(fields); i++ {
+		switch strings.ToUpper(fields[i]) {
+		case "AND", "OR":
+			continue
+		case "WITH":
+			i++ // skip the exception
+			continue
+		}
+
+		if id := fields[i]; !seen[id] {
+			seen[id] = true
+			licenses = append(licenses, detectedLicense{Path: filePath, SPDXID: id, Confidence: 1, Source: licenseSourceSPDX, Expression: expression})
+		}
+	}
+	return licenses
+}
@@ -0,0 +1,54 @@
+package syncer
+
+import (
+	"context"
+	"time"
+)
+
+// queueChannel is notified whenever a job is enqueued to run right away (see mergestat.sync_repo_now)
+const queueChannel = "mergestat_repo_sync_queue"
+
+// listen wakes up an idle exec loop whenever a job is enqueued to run right away, rather than having the job
+// wait for the next poll. It holds on to a connection of the pool until the context is canceled.
+func (w *worker) listen(ctx context.Context) {
+	for ctx.Err() == nil {
+		if err := w.waitForNotifications(ctx); err != nil && ctx.Err() == nil {
+			w.logger.Err(err).Msgf("error listening for queued jobs: %v", err)
+
+			// retry on the next poll
+			select {
+			case <-ctx.Done():
+			case <-time.After(w.pollInterval):
+			}
+		}
+	}
+}
+
+func (w *worker) waitForNotifications(ctx context.Context) error {
+	conn, err := w.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, "LISTEN "+queueChannel); err != nil {
+		return err
+	}
+	defer func() {
+		if !conn.Conn().IsClosed() {
+			_, _ = conn.Exec(context.Background(), "UNLISTEN "+queueChannel)
+		}
+	}()
+
+	for {
+		if _, err = conn.Conn().WaitForNotification(ctx); err != nil {
+			return err
+		}
+
+		// don't block if all the exec loops are awake already
+		select {
+		case w.wake <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -45,7 +45,7 @@ func (w *worker) handleOSSFScorecardScan(ctx context.Context, j *db.DequeueSyncJ
 	}
 
 	var tx pgx.Tx
-	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+	if tx, err = w.beginTx(ctx); err != nil {
 		return fmt.Errorf("begin tx: %w", err)
 	}
 	defer func() {
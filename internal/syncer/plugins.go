@@ -0,0 +1,135 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/pkg/syncplugin"
+)
+
+// registerPlugins adds the sync types registered by plugins (see pkg/syncplugin) to mergestat.repo_sync_types (labeled
+// as plugins), so that they can be enabled for repos. Their tables are created along with the migrations (see cmd/worker).
+func (w *worker) registerPlugins(ctx context.Context) (err error) {
+	var types = syncplugin.SyncTypes()
+	if len(types) == 0 {
+		return nil
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.pool.Begin(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	const upsertSyncType = `
+		INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group) VALUES ($1, $2, $3, 2, $4)
+		ON CONFLICT (type) DO UPDATE SET description = EXCLUDED.description, short_name = EXCLUDED.short_name, type_group = EXCLUDED.type_group`
+	const labelSyncType = `
+		INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type) VALUES ('plugin', $1)
+		ON CONFLICT DO NOTHING`
+
+	for _, t := range types {
+		if _, err = tx.Exec(ctx, upsertSyncType, t.Name, t.Description, t.ShortName, t.Group); err != nil {
+			return fmt.Errorf("sync type %s: %w", t.Name, err)
+		}
+		if _, err = tx.Exec(ctx, labelSyncType, t.Name); err != nil {
+			return fmt.Errorf("label of sync type %s: %w", t.Name, err)
+		}
+
+		w.logger.Info().Msgf("registered plugin sync type: %s", t.Name)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// pluginRuntime is the syncplugin.Runtime of a job, backed by the worker running it
+type pluginRuntime struct {
+	w *worker
+	j *db.DequeueSyncJobRow
+}
+
+func (r *pluginRuntime) Credentials(ctx context.Context) (username, token string, err error) {
+	return r.w.fetchCredentials(ctx, r.j)
+}
+
+func (r *pluginRuntime) Variable(ctx context.Context, key string) (string, bool, error) {
+	return r.w.db.FetchRepoSyncVar(ctx, r.j.RepoID, key)
+}
+
+func (r *pluginRuntime) Clone(ctx context.Context, dir string) (string, error) {
+	return r.w.openOrClone(ctx, dir, r.j)
+}
+
+func (r *pluginRuntime) Log(ctx context.Context, message string) error {
+	return r.w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: r.j.ID, Message: message}})
+}
+
+// handlePlugin runs the handler of a sync type registered by a plugin, in a transaction of the worker, so that the
+// rows it writes are counted, sent to the destinations or rolled back in dry-run mode like those of any other sync
+func (w *worker) handlePlugin(ctx context.Context, j *db.DequeueSyncJobRow, t syncplugin.SyncType) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	var settings = json.RawMessage("{}")
+	if j.Settings.Status == pgtype.Present && len(j.Settings.Bytes) > 0 {
+		settings = j.Settings.Bytes
+	}
+
+	var job = &syncplugin.Job{
+		ID:       j.ID,
+		SyncType: j.SyncType,
+		RepoID:   j.RepoID,
+		Repo:     j.Repo,
+		Settings: settings,
+		Runtime:  &pluginRuntime{w: w, j: j},
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("rollback transaction: %v", err)
+			}
+		}
+	}()
+
+	if err = t.Handler.Sync(ctx, job, tx); err != nil {
+		return fmt.Errorf("plugin %s: %w", t.Name, err)
+	}
+
+	l.Info().Msgf("plugin sync type %s done", t.Name)
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("sync job done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
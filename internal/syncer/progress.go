@@ -0,0 +1,105 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// progressInterval is how often a long running phase of a job reports its progress
+const progressInterval = 10 * time.Second
+
+// progress is the structured progress of a running job, as logged in mergestat.repo_sync_logs.progress
+type progress struct {
+	Phase     string   `json:"phase"`
+	Processed int64    `json:"processed"`
+	Total     *int64   `json:"total,omitempty"`   // estimate of the number of items to process, if known
+	Percent   *float64 `json:"percent,omitempty"` // only set if the total is known
+}
+
+func (p progress) String() string {
+	if p.Total == nil {
+		return fmt.Sprintf("%s: %d processed", p.Phase, p.Processed)
+	}
+	return fmt.Sprintf("%s: %d of %d processed (%.0f%%)", p.Phase, p.Processed, *p.Total, *p.Percent)
+}
+
+// progressReporter periodically logs the progress of a phase of a job (e.g. paging through the pull requests of a repo),
+// so that the UI can show a progress bar rather than just when the job started and finished. Reporting is best effort,
+// a failure to report progress is logged but doesn't fail the job.
+type progressReporter struct {
+	w         *worker
+	j         *db.DequeueSyncJobRow
+	phase     string
+	processed int64
+	total     int64 // 0 if unknown
+	last      time.Time
+}
+
+// startProgress starts reporting the progress of the given phase of a job. The total is the (estimated) number
+// of items the phase processes, or 0 if unknown (see progressReporter.setTotal).
+func (w *worker) startProgress(ctx context.Context, j *db.DequeueSyncJobRow, phase string, total int64) *progressReporter {
+	var p = &progressReporter{w: w, j: j, phase: phase, total: total}
+	p.report(ctx)
+	return p
+}
+
+// setTotal updates the estimated number of items the phase processes, e.g. once the first page of a paginated API reports it
+func (p *progressReporter) setTotal(total int64) {
+	if p != nil {
+		p.total = total
+	}
+}
+
+// set sets the number of items processed so far, reporting it if the last report is older than progressInterval
+func (p *progressReporter) set(ctx context.Context, processed int64) {
+	if p == nil {
+		return
+	}
+
+	if p.processed = processed; time.Since(p.last) >= progressInterval {
+		p.report(ctx)
+	}
+}
+
+// add adds to the number of items processed so far (see set)
+func (p *progressReporter) add(ctx context.Context, n int64) {
+	if p != nil {
+		p.set(ctx, p.processed+n)
+	}
+}
+
+// done reports the final progress of the phase
+func (p *progressReporter) done(ctx context.Context) {
+	if p == nil {
+		return
+	}
+
+	// the phase is done, even if the total was unknown (or the estimate was off)
+	if p.total == 0 || p.processed < p.total {
+		p.total = p.processed
+	}
+	p.report(ctx)
+}
+
+func (p *progressReporter) report(ctx context.Context) {
+	p.last = time.Now()
+
+	var current = progress{Phase: p.phase, Processed: p.processed}
+	if p.total > 0 {
+		var total, percent = p.total, math.Min(100, float64(p.processed)/float64(p.total)*100)
+		current.Total, current.Percent = &total, &percent
+	}
+
+	if err := p.w.sendBatchLogMessages(ctx, []*syncLog{{
+		Type:            SyncLogTypeProgress,
+		Message:         current.String(),
+		RepoSyncQueueID: p.j.ID,
+		Progress:        &current,
+	}}); err != nil {
+		p.w.loggerForJob(p.j).Warn().AnErr("error", err).Msgf("error reporting progress: %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/jackc/pgconn"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// redirectTimeout bounds the request made (before cloning a repo) to check whether it was renamed
+const redirectTimeout = 10 * time.Second
+
+// uniqueViolation is the postgres error code of a unique constraint violation
+const uniqueViolation = "23505"
+
+// followRename checks whether the repo was renamed or transferred before it's cloned, i.e. whether its (http) remote
+// redirects to another url. If it was, the url of the repo is updated (see renameRepo) and it's cloned from the new url
+// right away, rather than silently syncing against the old name for as long as the redirect is in place. The check is
+// best effort, the clone goes ahead from the old url if it fails.
+func (w *worker) followRename(ctx context.Context, job *db.DequeueSyncJobRow, repo *db.Repo, r *remote) error {
+	if r.endpoint.Protocol != "http" && r.endpoint.Protocol != "https" {
+		return nil
+	}
+
+	newURL, err := redirectedURL(ctx, repo.Repo, r)
+	if err != nil {
+		w.loggerForJob(job).Warn().AnErr("error", err).Msg("could not check whether repo was renamed")
+		return nil
+	} else if newURL == "" {
+		return nil
+	}
+
+	var endpoint *transport.Endpoint
+	if endpoint, err = transport.NewEndpoint(newURL); err != nil {
+		return nil
+	}
+
+	if err = w.renameRepo(ctx, job, newURL); err != nil {
+		return err
+	}
+
+	repo.Repo, r.endpoint = newURL, endpoint
+	return nil
+}
+
+// renameRepo updates the url of the job's repo to the given one, once the repo was found to have been renamed or
+// transferred. The repo keeps its id, and so its synced data and sync configuration. The rename is noted in the job's logs.
+func (w *worker) renameRepo(ctx context.Context, job *db.DequeueSyncJobRow, newURL string) error {
+	var oldURL = job.Repo
+
+	// in dry-run mode, nothing is written (see beginTx)
+	if _, ok := ctx.Value(dryRunKey{}).(*dryRun); ok {
+		return w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeWarn, RepoSyncQueueID: job.ID,
+			Message: fmt.Sprintf("repo was renamed or transferred, its url would have been updated from %s to %s", oldURL, newURL),
+		}})
+	}
+
+	if err := w.db.UpdateRepoURL(ctx, db.UpdateRepoURLParams{ID: job.RepoID, Repo: newURL}); err != nil {
+		var pgErr *pgconn.PgError
+		if !errors.As(err, &pgErr) || pgErr.Code != uniqueViolation {
+			return fmt.Errorf("update repo url: %w", err)
+		}
+
+		// the new url was added as a repo of its own (e.g. by an import), which is left for the user to resolve
+		return w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeWarn, RepoSyncQueueID: job.ID,
+			Message: fmt.Sprintf("repo was renamed or transferred to %s, but its url was not updated as that repo already exists", newURL),
+		}})
+	}
+
+	job.Repo = newURL
+	return w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeWarn, RepoSyncQueueID: job.ID,
+		Message: fmt.Sprintf("repo was renamed or transferred, updated its url from %s to %s", oldURL, newURL),
+	}})
+}
+
+// redirectedURL returns the url the (http) git remote of the repo at repoURL redirects to, or an empty string if it
+// doesn't redirect. Hosts like GitHub and GitLab redirect the remotes of renamed and transferred repos to their new url.
+func redirectedURL(ctx context.Context, repoURL string, r *remote) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, redirectTimeout)
+	defer cancel()
+
+	var infoRefs = strings.TrimSuffix(repoURL, "/") + "/info/refs?service=git-upload-pack"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, infoRefs, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if r.token != "" {
+		var username = r.username
+		if username == "" {
+			username = "git"
+		}
+		req.SetBasicAuth(username, r.token)
+	}
+
+	var client = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		return "", nil
+	}
+
+	location, err := resp.Location()
+	if err != nil {
+		return "", err
+	}
+
+	// only redirects to another git remote count, not e.g. to a login page
+	if !strings.HasSuffix(location.Path, "/info/refs") {
+		return "", nil
+	}
+	location.Path, location.RawQuery, location.User = strings.TrimSuffix(location.Path, "/info/refs"), "", nil
+
+	return renamedURL(repoURL, location.String()), nil
+}
+
+// renamedURL returns the given new url of a repo in the style of its old url (e.g. without a .git suffix),
+// or an empty string if both urls point to the same repo
+func renamedURL(oldURL, newURL string) string {
+	var trim = func(u string) string { return strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git") }
+	newURL = trim(newURL)
+	if strings.HasSuffix(oldURL, ".git") {
+		newURL += ".git"
+	}
+
+	// hosts and repo names are case-insensitive, and a switch from http to https isn't a rename
+	var repoOf = func(raw string) string {
+		if u, err := url.Parse(trim(raw)); err == nil {
+			return strings.ToLower(u.Host + u.Path)
+		}
+		return strings.ToLower(trim(raw))
+	}
+	if repoOf(oldURL) == repoOf(newURL) {
+		return ""
+	}
+
+	return newURL
+}
@@ -0,0 +1,131 @@
+package syncer
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/mergestat/mergestat/internal/db"
+	"golang.org/x/sys/unix"
+)
+
+// resourceSampleInterval is how often the memory and temp disk usage of a running job is sampled
+const resourceSampleInterval = 15 * time.Second
+
+// jobResourcesKey is the context key of the jobResources of a running job
+type jobResourcesKey struct{}
+
+// jobResources accounts for the resources used by a running job, so that operators can tell which repos and sync types are
+// the most expensive to sync. Jobs share the worker process, so memory and CPU time are those of the whole process (and
+// the processes it spawns, e.g. scanners) while the job ran, and include the usage of any other jobs running at the same time.
+type jobResources struct {
+	mu         sync.Mutex
+	dirs       map[string]struct{} // scratch dirs of the job, e.g. the one its repo is cloned into
+	peakMemory uint64
+	peakDisk   uint64
+}
+
+// trackScratchDir adds the given dir to the temp disk usage of the job running with ctx, if any. The usage is sampled
+// right away, so that a dir tracked once it's filled (e.g. with a clone) is accounted for even if the job is short-lived.
+func trackScratchDir(ctx context.Context, dir string) {
+	if r, ok := ctx.Value(jobResourcesKey{}).(*jobResources); ok {
+		r.mu.Lock()
+		r.dirs[dir] = struct{}{}
+		r.mu.Unlock()
+
+		r.sample()
+	}
+}
+
+// startResourceAccounting starts accounting for the resources used by the given job, returning a context that scratch dirs
+// are tracked with (see trackScratchDir), and a func that stops accounting and records the usage on the job's row.
+func (w *worker) startResourceAccounting(ctx context.Context, j *db.DequeueSyncJobRow) (context.Context, func()) {
+	var r = &jobResources{dirs: make(map[string]struct{})}
+	var cpuStart = cpuTime()
+
+	var done, stopped = make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			r.sample()
+			select {
+			case <-done:
+				return
+			case <-time.After(resourceSampleInterval):
+			}
+		}
+	}()
+
+	return context.WithValue(ctx, jobResourcesKey{}, r), func() {
+		close(done)
+		<-stopped
+		r.sample()
+
+		if err := w.db.SetSyncJobResourceUsage(context.TODO(), db.SetSyncJobResourceUsageParams{
+			ID:                j.ID,
+			CpuTimeMs:         (cpuTime() - cpuStart).Milliseconds(),
+			PeakMemoryBytes:   int64(r.peakMemory),
+			PeakTempDiskBytes: int64(r.peakDisk),
+		}); err != nil {
+			w.loggerForJob(j).Err(err).Msgf("error recording resource usage of job: %v", err)
+		}
+	}
+}
+
+// sample samples the memory used by the worker process, and the disk used by the scratch dirs of the job
+func (r *jobResources) sample() {
+	var samples = []metrics.Sample{{Name: "/memory/classes/total:bytes"}, {Name: "/memory/classes/heap/released:bytes"}}
+	metrics.Read(samples)
+
+	// memory that was returned to the OS doesn't count towards the memory in use
+	var memory = samples[0].Value.Uint64() - samples[1].Value.Uint64()
+
+	r.mu.Lock()
+	var dirs = make([]string, 0, len(r.dirs))
+	for dir := range r.dirs {
+		dirs = append(dirs, dir)
+	}
+	r.mu.Unlock()
+
+	var disk uint64
+	for _, dir := range dirs {
+		disk += diskUsage(dir)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if memory > r.peakMemory {
+		r.peakMemory = memory
+	}
+	if disk > r.peakDisk {
+		r.peakDisk = disk
+	}
+}
+
+// diskUsage returns the total size of the files under the given dir, ignoring files that can't be read (or were removed meanwhile)
+func diskUsage(dir string) (size uint64) {
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += uint64(info.Size())
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// cpuTime returns the (user and system) CPU time used by the worker process and its (terminated) child processes
+func cpuTime() time.Duration {
+	var total time.Duration
+	for _, who := range []int{unix.RUSAGE_SELF, unix.RUSAGE_CHILDREN} {
+		var usage unix.Rusage
+		if err := unix.Getrusage(who, &usage); err == nil {
+			total += time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+		}
+	}
+	return total
+}
@@ -0,0 +1,80 @@
+package syncer
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+)
+
+// rowCountsKey is the context key of the rowCounts of a job (see handle)
+type rowCountsKey struct{}
+
+// rowCounts records the rows written by a job in its handler's transaction (see beginTx). Rows written outside of it
+// (e.g. by GitHub Actions, which writes through the warehouse) aren't counted.
+type rowCounts struct {
+	mu                         sync.Mutex
+	copied                     map[string]int64 // rows copied, by table
+	inserted, updated, deleted int64
+}
+
+func newRowCounts() *rowCounts {
+	return &rowCounts{copied: make(map[string]int64)}
+}
+
+// rowCountsOf returns the rowCounts of the job running with the given context, or nil if it has none
+func rowCountsOf(ctx context.Context) *rowCounts {
+	counts, _ := ctx.Value(rowCountsKey{}).(*rowCounts)
+	return counts
+}
+
+// snapshot returns a copy of the counts recorded so far
+func (c *rowCounts) snapshot() (copied map[string]int64, inserted, updated, deleted int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	copied = make(map[string]int64, len(c.copied))
+	for table, n := range c.copied {
+		copied[table] = n
+	}
+	return copied, c.inserted, c.updated, c.deleted
+}
+
+// countingTx is a transaction whose copied, inserted, updated and deleted rows are counted
+type countingTx struct {
+	pgx.Tx
+	counts *rowCounts
+}
+
+func (tx *countingTx) CopyFrom(ctx context.Context, table pgx.Identifier, columns []string, src pgx.CopyFromSource) (int64, error) {
+	n, err := tx.Tx.CopyFrom(ctx, table, columns, src)
+	if err == nil {
+		tx.counts.mu.Lock()
+		tx.counts.copied[unstaged(strings.Join(table, "."))] += n
+		tx.counts.mu.Unlock()
+	}
+	return n, err
+}
+
+func (tx *countingTx) Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error) {
+	tag, err := tx.Tx.Exec(ctx, sql, args...)
+
+	// statements on the mergestat schema are the bookkeeping of the job (e.g. setting its status), not synced rows
+	if err != nil || strings.Contains(sql, "mergestat.") {
+		return tag, err
+	}
+
+	tx.counts.mu.Lock()
+	defer tx.counts.mu.Unlock()
+	switch {
+	case tag.Insert():
+		tx.counts.inserted += tag.RowsAffected()
+	case tag.Update():
+		tx.counts.updated += tag.RowsAffected()
+	case tag.Delete():
+		tx.counts.deleted += tag.RowsAffected()
+	}
+	return tag, nil
+}
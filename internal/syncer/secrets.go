@@ -0,0 +1,159 @@
+package syncer
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// secretRule is a rule detecting a kind of credential, e.g. AWS access keys
+type secretRule struct {
+	ID          string   `json:"id"`
+	Description string   `json:"description"`
+	Regex       string   `json:"regex"`      // the secret is the first group of the regex, if any, or the whole match
+	MinEntropy  float64  `json:"minEntropy"` // the Shannon entropy (bits per character) below which a match is ignored
+	Keywords    []string `json:"keywords"`   // if set, only lines containing one of them (regardless of case) are matched
+
+	regex *regexp.Regexp
+}
+
+// defaultSecretRules are the rules a secrets scan applies, unless disabled by its settings
+var defaultSecretRules = []secretRule{
+	{ID: "aws-access-key-id", Description: "AWS access key ID", Regex: `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`},
+	{ID: "aws-secret-access-key", Description: "AWS secret access key", Regex: `(?i)aws.{0,20}(?:secret|key).{0,20}['"]([0-9a-zA-Z/+]{40})['"]`, MinEntropy: 4, Keywords: []string{"aws"}},
+	{ID: "github-token", Description: "GitHub token", Regex: `\b(gh[pousr]_[0-9a-zA-Z]{36})\b`},
+	{ID: "github-fine-grained-token", Description: "GitHub fine-grained personal access token", Regex: `\b(github_pat_[0-9a-zA-Z_]{82})\b`},
+	{ID: "gitlab-token", Description: "GitLab personal access token", Regex: `\b(glpat-[0-9a-zA-Z_-]{20})\b`},
+	{ID: "slack-token", Description: "Slack token", Regex: `\b(xox[baprs]-[0-9a-zA-Z-]{10,72})\b`},
+	{ID: "slack-webhook", Description: "Slack incoming webhook URL", Regex: `(https://hooks\.slack\.com/services/T[0-9A-Z]+/B[0-9A-Z]+/[0-9a-zA-Z]+)`},
+	{ID: "stripe-secret-key", Description: "Stripe secret (or restricted) key", Regex: `\b((?:sk|rk)_live_[0-9a-zA-Z]{24,99})\b`},
+	{ID: "google-api-key", Description: "Google API key", Regex: `\b(AIza[0-9A-Za-z_-]{35})\b`},
+	{ID: "private-key", Description: "Private key", Regex: `-----BEGIN (?:[A-Z]+ )*PRIVATE KEY(?: BLOCK)?-----`},
+	{ID: "jwt", Description: "JSON Web Token", Regex: `\b(eyJ[0-9a-zA-Z_-]{10,}\.eyJ[0-9a-zA-Z_-]{10,}\.[0-9a-zA-Z_-]{10,})`},
+	{ID: "generic-secret", Description: "Secret assigned to a password, secret, token or API key", Regex: `(?i)(?:password|passwd|secret|token|api_?key)["']?\s*[:=]\s*["']([^"'\s]{8,})["']`, MinEntropy: 3.5, Keywords: []string{"pass", "secret", "token", "key"}},
+}
+
+// compileSecretRules compiles the regexes of the given rules
+func compileSecretRules(rules []secretRule) ([]secretRule, error) {
+	var compiled = make([]secretRule, 0, len(rules))
+	for _, r := range rules {
+		var err error
+		if r.ID == "" {
+			return nil, fmt.Errorf("secret rule without an id")
+		}
+		if r.regex, err = regexp.Compile(r.Regex); err != nil {
+			return nil, fmt.Errorf("invalid regex of secret rule %s: %w", r.ID, err)
+		}
+		var keywords = make([]string, 0, len(r.Keywords))
+		for _, k := range r.Keywords {
+			keywords = append(keywords, strings.ToLower(k))
+		}
+		r.Keywords = keywords
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+// secretFinding is a secret found by a rule, at a line (and column) of a file
+type secretFinding struct {
+	Source      string // head, for the secrets of the files of HEAD, or history, for the ones added by a commit
+	CommitHash  string
+	Path        string
+	Line        int
+	Column      int // of the secret in the line, starting at 1
+	RuleID      string
+	Description string
+	Redacted    string  // the secret, redacted
+	Fingerprint string  // the keyed hash of the secret, to track it (e.g. across commits) without storing it
+	Entropy     float64 // of the secret
+}
+
+// shannonEntropy returns the Shannon entropy of the given string, in bits per character
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	var counts = make(map[rune]int)
+	var n int
+	for _, c := range s {
+		counts[c]++
+		n++
+	}
+
+	var entropy float64
+	for _, count := range counts {
+		var p = float64(count) / float64(n)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// redactSecret redacts all of the given secret but its first few characters, enough to help identifying it (e.g. the
+// prefix of a key) without disclosing it
+func redactSecret(secret string) string {
+	var visible = len(secret) / 4
+	if visible > 4 {
+		visible = 4
+	}
+	return secret[:visible] + strings.Repeat("*", len(secret)-visible)
+}
+
+// scanLine returns the secrets the given rules find in the given line. Their fingerprints are keyed with the given
+// key, as the fingerprint of a short (or guessable) secret could otherwise be brute-forced.
+func scanLine(rules []secretRule, key []byte, commitHash, path string, lineNo int, line string) []secretFinding {
+	var findings []secretFinding
+	var lower string
+	for _, r := range rules {
+		if len(r.Keywords) > 0 {
+			if lower == "" {
+				lower = strings.ToLower(line)
+			}
+
+			var found bool
+			for _, k := range r.Keywords {
+				if found = strings.Contains(lower, k); found {
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+		}
+
+		for _, m := range r.regex.FindAllStringSubmatchIndex(line, -1) {
+			// the secret is the first group of the regex, if it has (and matched) one
+			var start, end = m[0], m[1]
+			if len(m) > 2 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+
+			var secret = line[start:end]
+			var entropy = shannonEntropy(secret)
+			if entropy < r.MinEntropy {
+				continue
+			}
+
+			var mac = hmac.New(sha256.New, key)
+			mac.Write([]byte(secret))
+			findings = append(findings, secretFinding{
+				CommitHash: commitHash, Path: path, Line: lineNo, Column: start + 1, RuleID: r.ID, Description: r.Description,
+				Redacted: redactSecret(secret), Fingerprint: hex.EncodeToString(mac.Sum(nil)), Entropy: entropy,
+			})
+		}
+	}
+	return findings
+}
+
+// scanContents returns the secrets the given rules find in the given contents (of a file)
+func scanContents(rules []secretRule, key []byte, commitHash, path, contents string) []secretFinding {
+	var findings []secretFinding
+	for i, line := range strings.Split(contents, "\n") {
+		findings = append(findings, scanLine(rules, key, commitHash, path, i+1, strings.TrimSuffix(line, "\r"))...)
+	}
+	return findings
+}
@@ -0,0 +1,356 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/go-enry/go-enry/v2"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/jackc/pgx/v4"
+	libgit2 "github.com/libgit2/git2go/v33"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+const (
+	secretSourceHead    = "head"
+	secretSourceHistory = "history"
+)
+
+// secretsScanSettings are the settings accepted by a SECRETS_REPO_SCAN repo sync
+type secretsScanSettings struct {
+	// History scans the lines added by each commit (reachable from HEAD) too, rather than the files of HEAD only
+	History bool `json:"history"`
+
+	// Rules are rules of the scan, in addition to the default ones (unless DisableDefaultRules is set)
+	Rules               []secretRule `json:"rules"`
+	DisableDefaultRules bool         `json:"disableDefaultRules"`
+
+	// AllowPaths are regexes of the paths of the files that aren't scanned (e.g. of test fixtures)
+	AllowPaths []string `json:"allowPaths"`
+
+	// MaxFileSize is the size (in bytes) of the files of HEAD above which they aren't scanned. 0 means 1MB.
+	MaxFileSize int64 `json:"maxFileSize"`
+}
+
+// secretsScanner scans files, and the history of commits, for secrets
+type secretsScanner struct {
+	key         []byte // the key of the fingerprints of the secrets found
+	rules       []secretRule
+	allowPaths  []*regexp.Regexp
+	maxFileSize int64
+}
+
+// newSecretsScanner returns the scanner of the given settings, fingerprinting secrets with the given key
+func newSecretsScanner(settings *secretsScanSettings, key []byte) (*secretsScanner, error) {
+	var rules []secretRule
+	if !settings.DisableDefaultRules {
+		rules = append(rules, defaultSecretRules...)
+	}
+	rules = append(rules, settings.Rules...)
+
+	var s = &secretsScanner{key: key, maxFileSize: settings.MaxFileSize}
+	if s.maxFileSize <= 0 {
+		s.maxFileSize = 1 << 20
+	}
+
+	var err error
+	if s.rules, err = compileSecretRules(rules); err != nil {
+		return nil, err
+	}
+
+	for _, p := range settings.AllowPaths {
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("invalid allowed path %q: %w", p, err)
+		}
+		s.allowPaths = append(s.allowPaths, re)
+	}
+	return s, nil
+}
+
+// allowed returns whether the file at the given path isn't scanned
+func (s *secretsScanner) allowed(path string) bool {
+	for _, re := range s.allowPaths {
+		if re.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanHead scans the (text) files of the HEAD of the repository at repoPath
+func (s *secretsScanner) scanHead(ctx context.Context, repoPath string) ([]secretFinding, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open repository: %w", err)
+	}
+
+	head, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("could not resolve HEAD: %w", err)
+	}
+
+	commit, err := repo.CommitObject(head.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []secretFinding
+	if err = tree.Files().ForEach(func(f *object.File) error {
+		if f.Mode == filemode.Submodule || f.Mode == filemode.Symlink || f.Size > s.maxFileSize || s.allowed(f.Name) {
+			return nil
+		}
+
+		r, err := f.Reader()
+		if err != nil {
+			return fmt.Errorf("read %s: %w", f.Name, err)
+		}
+		defer r.Close()
+
+		contents, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("read %s: %w", f.Name, err)
+		}
+
+		if enry.IsBinary(contents) {
+			return nil
+		}
+
+		for _, finding := range scanContents(s.rules, s.key, commit.Hash.String(), f.Name, string(contents)) {
+			finding.Source = secretSourceHead
+			findings = append(findings, finding)
+		}
+
+		return ctx.Err()
+	}); err != nil {
+		return nil, err
+	}
+
+	return findings, nil
+}
+
+// scanHistory scans the lines added by each commit reachable from the HEAD of the repository at repoPath (compared to
+// its first parent), so that secrets that were removed since (but remain in the history) are found too
+func (s *secretsScanner) scanHistory(ctx context.Context, repoPath string) ([]secretFinding, error) {
+	repo, err := libgit2.OpenRepository(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer repo.Free()
+
+	walk, err := repo.Walk()
+	if err != nil {
+		return nil, err
+	}
+	defer walk.Free()
+
+	if err = walk.PushHead(); err != nil {
+		return nil, err
+	}
+
+	var findings []secretFinding
+	var iterErr error
+	if err = walk.Iterate(func(c *libgit2.Commit) bool {
+		defer c.Free()
+
+		if iterErr = ctx.Err(); iterErr != nil {
+			return false
+		}
+
+		var hash = c.Id().String()
+
+		var toTree, fromTree *libgit2.Tree
+		if toTree, iterErr = c.Tree(); iterErr != nil {
+			return false
+		}
+		defer toTree.Free()
+
+		if parent := c.Parent(0); parent != nil {
+			defer parent.Free()
+			if fromTree, iterErr = parent.Tree(); iterErr != nil {
+				return false
+			}
+			defer fromTree.Free()
+		}
+
+		var diff *libgit2.Diff
+		if diff, iterErr = repo.DiffTreeToTree(fromTree, toTree, nil); iterErr != nil {
+			return false
+		}
+		defer func() { _ = diff.Free() }()
+
+		iterErr = diff.ForEach(func(delta libgit2.DiffDelta, progress float64) (libgit2.DiffForEachHunkCallback, error) {
+			var path = delta.NewFile.Path
+			if delta.Flags&libgit2.DiffFlagBinary != 0 || s.allowed(path) {
+				return nil, nil
+			}
+
+			return func(hunk libgit2.DiffHunk) (libgit2.DiffForEachLineCallback, error) {
+				return func(line libgit2.DiffLine) error {
+					if line.Origin != libgit2.DiffLineAddition {
+						return nil
+					}
+					for _, finding := range scanLine(s.rules, s.key, hash, path, line.NewLineno, trimNewline(line.Content)) {
+						finding.Source = secretSourceHistory
+						findings = append(findings, finding)
+					}
+					return nil
+				}, nil
+			}, nil
+		}, libgit2.DiffDetailLines)
+
+		return iterErr == nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return findings, iterErr
+}
+
+// trimNewline trims the line ending of the given line (of a diff)
+func trimNewline(line string) string {
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line
+}
+
+// sendBatchSecretFindings uses the pg COPY protocol to send the findings into the given table
+func (w *worker) sendBatchSecretFindings(ctx context.Context, tx pgx.Tx, table string, j *db.DequeueSyncJobRow, findings []secretFinding) (int64, error) {
+	type key struct {
+		source, commit, path, rule string
+		line, column               int
+	}
+
+	var seen = make(map[key]bool)
+	inputs := make([][]interface{}, 0, len(findings))
+	for _, f := range findings {
+		var k = key{f.Source, f.CommitHash, f.Path, f.RuleID, f.Line, f.Column}
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		inputs = append(inputs, []interface{}{j.RepoID, f.Source, f.CommitHash, f.Path, f.Line, f.Column, f.RuleID, f.Description, f.Redacted, f.Fingerprint, f.Entropy})
+	}
+
+	settings, err := w.copyBatchSettingsFor(j)
+	if err != nil {
+		return 0, err
+	}
+
+	return copyInBatches(ctx, tx, pgx.Identifier{table}, []string{"repo_id", "source", "commit_hash", "path", "line", "start_column", "rule_id", "description", "redacted", "fingerprint", "entropy"}, pgx.CopyFromRows(inputs), settings, nil)
+}
+
+// handleSecretsRepoScan scans the files of the HEAD of a repo (and optionally its history) for secrets, using
+// configurable regex and entropy rules, and stores the (redacted) findings
+func (w *worker) handleSecretsRepoScan(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	var settings secretsScanSettings
+	if err = decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	// the fingerprints of the secrets found are keyed with ENCRYPTION_SECRET, which is never stored with them
+	var key = os.Getenv("ENCRYPTION_SECRET")
+	if key == "" {
+		return errors.New("ENCRYPTION_SECRET must be set to fingerprint the secrets found")
+	}
+
+	var scanner *secretsScanner
+	if scanner, err = newSecretsScanner(&settings, []byte(key)); err != nil {
+		return err
+	}
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+		}
+	}()
+
+	var repoPath string
+	if repoPath, err = w.openOrClone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	var findings []secretFinding
+	if findings, err = scanner.scanHead(ctx, repoPath); err != nil {
+		return fmt.Errorf("scan head: %w", err)
+	}
+
+	if settings.History {
+		var history []secretFinding
+		if history, err = scanner.scanHistory(ctx, repoPath); err != nil {
+			return fmt.Errorf("scan history: %w", err)
+		}
+		findings = append(findings, history...)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("could not rollback transaction")
+			}
+		}
+	}()
+
+	if err = stage(ctx, tx, "repo_secret_findings"); err != nil {
+		return err
+	}
+
+	var inserted int64
+	if inserted, err = w.sendBatchSecretFindings(ctx, tx, staging("repo_secret_findings"), j, findings); err != nil {
+		return fmt.Errorf("send batch secret findings: %w", err)
+	}
+
+	l.Info().Msgf("sent batch of %d secret findings", inserted)
+
+	if err := w.mergeStaged(ctx, tx, j, "repo_secret_findings", inserted); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("update status done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	return err
+}
@@ -0,0 +1,187 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// stagingPrefix is the prefix of the staging tables, which rows are copied into before being merged into their table
+const stagingPrefix = "_mergestat_staging_"
+
+// staging returns the name of the staging table of the given (public) table
+func staging(table string) string { return stagingPrefix + table }
+
+// unstaged returns the name of the table of the given staging table, or the given name if it isn't a staging table
+func unstaged(name string) string { return strings.TrimPrefix(name, stagingPrefix) }
+
+// stage creates the (temporary) staging table of the given table, dropped once the transaction is over. Rows of a repo
+// are copied into it, and then merged into the table (see merge), rather than all the rows of the repo being deleted
+// and copied again.
+func stage(ctx context.Context, tx pgx.Tx, table string) error {
+	var sql = fmt.Sprintf(`CREATE TEMP TABLE %s (LIKE public.%s INCLUDING DEFAULTS) ON COMMIT DROP`,
+		pgx.Identifier{staging(table)}.Sanitize(), pgx.Identifier{table}.Sanitize())
+	if _, err := tx.Exec(ctx, sql); err != nil {
+		return fmt.Errorf("create staging table of %s: %w", table, err)
+	}
+	return nil
+}
+
+// softDeleteSettings are the settings, accepted by any repo sync merging its rows into their table (see merge),
+// controlling what happens to the rows that aren't synced anymore
+type softDeleteSettings struct {
+	// SoftDeletes marks the rows that aren't synced anymore as deleted (see the _deleted_at column) rather than deleting
+	// them, so that the history of what was removed (e.g. refs or PRs) is kept, and consumers can build slowly-changing
+	// dimensions. Consumers then have to filter on _deleted_at IS NULL for the current rows.
+	SoftDeletes bool `json:"softDeletes"`
+}
+
+// merge merges the rows in the staging table of the given table into it, for the given repo:
+//
+//   - rows that aren't staged anymore are deleted or, with soft deletes, marked as deleted (see softDeleteSettings)
+//   - staged rows are inserted, or update their row if anything changed (un-deleting it, if it was marked as deleted)
+//
+// Rows are matched on the primary key of the table, and all their columns are merged (but for _mergestat_synced_at,
+// which is set whenever a row changes), as they were when rows were deleted and copied again. It returns the number of
+// rows upserted, and deleted (or marked as deleted).
+func merge(ctx context.Context, tx pgx.Tx, table string, repoID uuid.UUID, softDeletes bool) (upserted, deleted int64, err error) {
+	const selectColumns = `
+		SELECT a.attname, COALESCE(a.attnum = ANY(i.indkey), FALSE) FROM pg_catalog.pg_attribute a
+			LEFT JOIN pg_catalog.pg_index i ON i.indrelid = a.attrelid AND i.indisprimary
+		WHERE a.attrelid = $1::REGCLASS AND a.attnum > 0 AND NOT a.attisdropped
+		ORDER BY a.attnum`
+	var rows pgx.Rows
+	if rows, err = tx.Query(ctx, selectColumns, "public."+pgx.Identifier{table}.Sanitize()); err != nil {
+		return 0, 0, fmt.Errorf("columns of %s: %w", table, err)
+	}
+
+	var columns []tableColumn
+	for rows.Next() {
+		var c tableColumn
+		if err = rows.Scan(&c.name, &c.key); err != nil {
+			rows.Close()
+			return 0, 0, err
+		}
+		columns = append(columns, c)
+	}
+	if rows.Close(); rows.Err() != nil {
+		return 0, 0, fmt.Errorf("columns of %s: %w", table, rows.Err())
+	}
+
+	var remove, upsert string
+	if remove, upsert, err = mergeStatements(table, columns, softDeletes); err != nil {
+		return 0, 0, err
+	}
+
+	tag, err := tx.Exec(ctx, remove, repoID)
+	if err != nil {
+		return 0, 0, fmt.Errorf("delete rows of %s: %w", table, err)
+	}
+	deleted = tag.RowsAffected()
+
+	if tag, err = tx.Exec(ctx, upsert); err != nil {
+		return 0, deleted, fmt.Errorf("upsert rows of %s: %w", table, err)
+	}
+	return tag.RowsAffected(), deleted, nil
+}
+
+// tableColumn is a column of a table, and whether it's part of its primary key
+type tableColumn struct {
+	name string
+	key  bool
+}
+
+// mergeStatements returns the statements merging the rows in the staging table of the given table (made of the given
+// columns, in order) into it (see merge): the one removing (or marking as deleted) the rows of the repo given as $1
+// that aren't staged anymore, and the one upserting the staged rows. It returns an error if the table has no primary
+// key or _deleted_at column.
+func mergeStatements(table string, columns []tableColumn, softDeletes bool) (remove, upsert string, err error) {
+	var keys []string
+	var exists = make(map[string]bool)
+	var cols, sets, current, excluded []string
+	for _, c := range columns {
+		exists[c.name] = true
+		if c.key {
+			keys = append(keys, c.name)
+		}
+		if c.name == "_mergestat_synced_at" || c.name == "_deleted_at" {
+			continue
+		}
+
+		var col = pgx.Identifier{c.name}.Sanitize()
+		cols = append(cols, col)
+		if !c.key {
+			sets = append(sets, fmt.Sprintf("%s = excluded.%s", col, col))
+			current = append(current, "t."+col)
+			excluded = append(excluded, "excluded."+col)
+		}
+	}
+	if len(keys) == 0 || !exists["_deleted_at"] {
+		return "", "", fmt.Errorf("%s has no primary key or _deleted_at column to merge rows with", table)
+	}
+
+	var conflict, matches []string
+	for _, key := range keys {
+		var col = pgx.Identifier{key}.Sanitize()
+		conflict = append(conflict, col)
+		matches = append(matches, fmt.Sprintf("s.%s = t.%s", col, col))
+	}
+
+	var t, s = "public." + pgx.Identifier{table}.Sanitize(), pgx.Identifier{staging(table)}.Sanitize()
+
+	// without soft deletes, rows that were marked as deleted before (when soft deletes were enabled) are deleted too
+	remove = fmt.Sprintf(`DELETE FROM %s t WHERE t.repo_id = $1 AND NOT EXISTS (SELECT 1 FROM %s s WHERE %s)`,
+		t, s, strings.Join(matches, " AND "))
+	if softDeletes {
+		remove = fmt.Sprintf(`UPDATE %s t SET _deleted_at = now() WHERE t.repo_id = $1 AND t._deleted_at IS NULL
+			AND NOT EXISTS (SELECT 1 FROM %s s WHERE %s)`, t, s, strings.Join(matches, " AND "))
+	}
+
+	// rows are only updated if anything changed, so that rows that didn't are left untouched (and don't churn consumers)
+	var changed = "t._deleted_at IS NOT NULL"
+	if len(current) > 0 {
+		if exists["_mergestat_synced_at"] {
+			sets = append(sets, "_mergestat_synced_at = now()")
+		}
+		changed = fmt.Sprintf("(%s) IS DISTINCT FROM (%s) OR %s", strings.Join(current, ", "), strings.Join(excluded, ", "), changed)
+	}
+	sets = append(sets, "_deleted_at = NULL")
+
+	upsert = fmt.Sprintf(`INSERT INTO %s AS t (%s) SELECT %s FROM %s
+		ON CONFLICT (%s) DO UPDATE SET %s WHERE %s`,
+		t, strings.Join(cols, ", "), strings.Join(cols, ", "), s, strings.Join(conflict, ", "), strings.Join(sets, ", "), changed)
+	return remove, upsert, nil
+}
+
+// mergeStaged merges the given number of rows copied into the staging table of the given table for a job into it (see
+// merge), logging the rows upserted, unchanged and deleted
+func (w *worker) mergeStaged(ctx context.Context, tx pgx.Tx, j *db.DequeueSyncJobRow, table string, staged int64) error {
+	var settings softDeleteSettings
+	if err := decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	upserted, deleted, err := merge(ctx, tx, table, j.RepoID, settings.SoftDeletes)
+	if err != nil {
+		return err
+	}
+
+	var removed = fmt.Sprintf("deleted %d row(s) from %s", deleted, table)
+	if settings.SoftDeletes {
+		removed = fmt.Sprintf("marked %d row(s) of %s as deleted", deleted, table)
+	}
+
+	return w.sendBatchLogMessages(ctx, []*syncLog{{
+		Type:            SyncLogTypeInfo,
+		RepoSyncQueueID: j.ID,
+		Message:         removed,
+	}, {
+		Type:            SyncLogTypeInfo,
+		RepoSyncQueueID: j.ID,
+		Message:         fmt.Sprintf("inserted or updated %d row(s) in %s (%d unchanged)", upserted, table, staged-upserted),
+	}})
+}
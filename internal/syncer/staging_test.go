@@ -0,0 +1,94 @@
+package syncer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMergeStatements(t *testing.T) {
+	var refs = []tableColumn{
+		{name: "repo_id", key: true}, {name: "full_name", key: true}, {name: "hash"}, {name: "type"},
+		{name: "_mergestat_synced_at"}, {name: "_deleted_at"},
+	}
+
+	var tests = []struct {
+		name        string
+		table       string
+		columns     []tableColumn
+		softDeletes bool
+		wantRemove  string
+		wantUpsert  string
+		wantErr     bool
+	}{
+		{
+			name: "hard deletes", table: "git_refs", columns: refs,
+			wantRemove: `DELETE FROM public."git_refs" t WHERE t.repo_id = $1 AND NOT EXISTS (SELECT 1 FROM "_mergestat_staging_git_refs" s ` +
+				`WHERE s."repo_id" = t."repo_id" AND s."full_name" = t."full_name")`,
+			wantUpsert: `INSERT INTO public."git_refs" AS t ("repo_id", "full_name", "hash", "type") ` +
+				`SELECT "repo_id", "full_name", "hash", "type" FROM "_mergestat_staging_git_refs" ` +
+				`ON CONFLICT ("repo_id", "full_name") DO UPDATE SET "hash" = excluded."hash", "type" = excluded."type", ` +
+				`_mergestat_synced_at = now(), _deleted_at = NULL ` +
+				`WHERE (t."hash", t."type") IS DISTINCT FROM (excluded."hash", excluded."type") OR t._deleted_at IS NOT NULL`,
+		},
+		{
+			name: "soft deletes", table: "git_refs", columns: refs, softDeletes: true,
+			wantRemove: `UPDATE public."git_refs" t SET _deleted_at = now() WHERE t.repo_id = $1 AND t._deleted_at IS NULL ` +
+				`AND NOT EXISTS (SELECT 1 FROM "_mergestat_staging_git_refs" s WHERE s."repo_id" = t."repo_id" AND s."full_name" = t."full_name")`,
+			wantUpsert: `INSERT INTO public."git_refs" AS t ("repo_id", "full_name", "hash", "type") ` +
+				`SELECT "repo_id", "full_name", "hash", "type" FROM "_mergestat_staging_git_refs" ` +
+				`ON CONFLICT ("repo_id", "full_name") DO UPDATE SET "hash" = excluded."hash", "type" = excluded."type", ` +
+				`_mergestat_synced_at = now(), _deleted_at = NULL ` +
+				`WHERE (t."hash", t."type") IS DISTINCT FROM (excluded."hash", excluded."type") OR t._deleted_at IS NOT NULL`,
+		},
+		{
+			// rows of a table made of its key only never change, but may have been marked as deleted
+			name: "key only", table: "git_tags",
+			columns: []tableColumn{{name: "repo_id", key: true}, {name: "name", key: true}, {name: "_mergestat_synced_at"}, {name: "_deleted_at"}},
+			wantRemove: `DELETE FROM public."git_tags" t WHERE t.repo_id = $1 AND NOT EXISTS (SELECT 1 FROM "_mergestat_staging_git_tags" s ` +
+				`WHERE s."repo_id" = t."repo_id" AND s."name" = t."name")`,
+			wantUpsert: `INSERT INTO public."git_tags" AS t ("repo_id", "name") SELECT "repo_id", "name" FROM "_mergestat_staging_git_tags" ` +
+				`ON CONFLICT ("repo_id", "name") DO UPDATE SET _deleted_at = NULL WHERE t._deleted_at IS NOT NULL`,
+		},
+		{
+			name: "without _mergestat_synced_at", table: "git_files",
+			columns: []tableColumn{{name: "repo_id", key: true}, {name: "path", key: true}, {name: "contents"}, {name: "_deleted_at"}},
+			wantRemove: `DELETE FROM public."git_files" t WHERE t.repo_id = $1 AND NOT EXISTS (SELECT 1 FROM "_mergestat_staging_git_files" s ` +
+				`WHERE s."repo_id" = t."repo_id" AND s."path" = t."path")`,
+			wantUpsert: `INSERT INTO public."git_files" AS t ("repo_id", "path", "contents") SELECT "repo_id", "path", "contents" FROM "_mergestat_staging_git_files" ` +
+				`ON CONFLICT ("repo_id", "path") DO UPDATE SET "contents" = excluded."contents", _deleted_at = NULL ` +
+				`WHERE (t."contents") IS DISTINCT FROM (excluded."contents") OR t._deleted_at IS NOT NULL`,
+		},
+		{
+			name: "no primary key", table: "git_blame",
+			columns: []tableColumn{{name: "repo_id"}, {name: "path"}, {name: "line_no"}, {name: "_deleted_at"}},
+			wantErr: true,
+		},
+		{
+			name: "no _deleted_at", table: "git_refs",
+			columns: []tableColumn{{name: "repo_id", key: true}, {name: "full_name", key: true}, {name: "hash"}},
+			wantErr: true,
+		},
+	}
+
+	// statements are compared regardless of their whitespace
+	var normalize = func(s string) string { return strings.Join(strings.Fields(s), " ") }
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			remove, upsert, err := mergeStatements(test.table, test.columns, test.softDeletes)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("mergeStatements() error = %v, wantErr %v", err, test.wantErr)
+			}
+			if test.wantErr {
+				return
+			}
+
+			if got := normalize(remove); got != test.wantRemove {
+				t.Errorf("mergeStatements() remove = %s, want %s", got, test.wantRemove)
+			}
+			if got := normalize(upsert); got != test.wantUpsert {
+				t.Errorf("mergeStatements() upsert = %s, want %s", got, test.wantUpsert)
+			}
+		})
+	}
+}
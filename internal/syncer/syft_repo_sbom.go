@@ -0,0 +1,253 @@
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/jackc/pgx/v4"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// sbomSettings are the settings accepted by a SYFT_REPO_SBOM repo sync
+type sbomSettings struct {
+	// Format is the format of the SBOM document, either cyclonedx (the default) or spdx
+	Format string `json:"format"`
+}
+
+// sbomOutputs are the syft output formats of the SBOM formats
+var sbomOutputs = map[string]string{"cyclonedx": "cyclonedx-json", "spdx": "spdx-json"}
+
+// sbomComponent is a component (or package, in SPDX) of an SBOM document, flattened
+type sbomComponent struct {
+	Ref      string
+	Type     string
+	Name     string
+	Version  string
+	PURL     string
+	Licenses []string
+}
+
+// cycloneDXDocument is the part of a CycloneDX (json) document its components are flattened from
+type cycloneDXDocument struct {
+	SpecVersion string `json:"specVersion"`
+	Components  []struct {
+		BOMRef   string `json:"bom-ref"`
+		Type     string `json:"type"`
+		Name     string `json:"name"`
+		Version  string `json:"version"`
+		PURL     string `json:"purl"`
+		Licenses []struct {
+			License struct {
+				ID   string `json:"id"`
+				Name string `json:"name"`
+			} `json:"license"`
+			Expression string `json:"expression"`
+		} `json:"licenses"`
+	} `json:"components"`
+}
+
+// spdxDocument is the part of an SPDX (json) document its packages are flattened from
+type spdxDocument struct {
+	SPDXVersion string `json:"spdxVersion"`
+	Packages    []struct {
+		SPDXID           string `json:"SPDXID"`
+		Name             string `json:"name"`
+		VersionInfo      string `json:"versionInfo"`
+		LicenseConcluded string `json:"licenseConcluded"`
+		LicenseDeclared  string `json:"licenseDeclared"`
+		PrimaryPurpose   string `json:"primaryPackagePurpose"`
+		ExternalRefs     []struct {
+			ReferenceType    string `json:"referenceType"`
+			ReferenceLocator string `json:"referenceLocator"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+}
+
+// flattenSBOM returns the spec version and the components of the given SBOM document, of the given format
+func flattenSBOM(format string, document []byte) (string, []sbomComponent, error) {
+	var components []sbomComponent
+	switch format {
+	case "cyclonedx":
+		var doc cycloneDXDocument
+		if err := json.Unmarshal(document, &doc); err != nil {
+			return "", nil, err
+		}
+		for _, c := range doc.Components {
+			var component = sbomComponent{Ref: c.BOMRef, Type: c.Type, Name: c.Name, Version: c.Version, PURL: c.PURL}
+			for _, l := range c.Licenses {
+				switch {
+				case l.Expression != "":
+					component.Licenses = append(component.Licenses, l.Expression)
+				case l.License.ID != "":
+					component.Licenses = append(component.Licenses, l.License.ID)
+				case l.License.Name != "":
+					component.Licenses = append(component.Licenses, l.License.Name)
+				}
+			}
+			components = append(components, component)
+		}
+		return doc.SpecVersion, components, nil
+
+	case "spdx":
+		var doc spdxDocument
+		if err := json.Unmarshal(document, &doc); err != nil {
+			return "", nil, err
+		}
+		for _, p := range doc.Packages {
+			var component = sbomComponent{Ref: p.SPDXID, Type: p.PrimaryPurpose, Name: p.Name, Version: p.VersionInfo}
+			for _, r := range p.ExternalRefs {
+				if r.ReferenceType == "purl" {
+					component.PURL = r.ReferenceLocator
+				}
+			}
+			// licenses that weren't determined are NOASSERTION (or NONE) in SPDX
+			for _, l := range []string{p.LicenseConcluded, p.LicenseDeclared} {
+				if l != "" && l != noAssertion && l != "NONE" {
+					component.Licenses = append(component.Licenses, l)
+					break
+				}
+			}
+			components = append(components, component)
+		}
+		return doc.SPDXVersion, components, nil
+	}
+
+	return "", nil, fmt.Errorf("unknown sbom format: %s", format)
+}
+
+// handleSyftRepoSBOM executes `syft {git-repo} -o {cyclonedx,spdx}-json` for a repo, and inserts the SBOM document
+// (and its components, flattened) of the HEAD commit into the DB. The SBOMs of previous commits are kept.
+func (w *worker) handleSyftRepoSBOM(ctx context.Context, j *db.DequeueSyncJobRow) error {
+	var err error
+	l := w.loggerForJob(j)
+
+	var settings = sbomSettings{Format: "cyclonedx"}
+	if err = decodeSettings(j, &settings); err != nil {
+		return err
+	}
+
+	var output, ok = sbomOutputs[settings.Format]
+	if !ok {
+		return fmt.Errorf("unknown sbom format: %s", settings.Format)
+	}
+
+	// indicate that we're starting query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatStartingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	tmpPath, cleanup, err := w.createTempDir(j.RepoID)
+	if err != nil {
+		return fmt.Errorf("temp dir: %w", err)
+	}
+	defer func() {
+		if err := cleanup(); err != nil {
+			l.Err(err).Msgf("error cleaning up repo at: %s, %v", tmpPath, err)
+		}
+	}()
+
+	if err = w.clone(ctx, tmpPath, j); err != nil {
+		return fmt.Errorf("git clone: %w", err)
+	}
+
+	// the SBOM is a snapshot of the repo at its HEAD commit
+	var commitHash string
+	if repo, err := git.PlainOpen(tmpPath); err != nil {
+		return fmt.Errorf("could not open repository: %w", err)
+	} else if head, err := repo.Head(); err != nil {
+		return fmt.Errorf("could not resolve HEAD: %w", err)
+	} else {
+		commitHash = head.Hash().String()
+	}
+
+	cmd := exec.CommandContext(ctx, "syft", ".", "-o", output)
+	cmd.Dir = tmpPath
+
+	var document []byte
+	if document, err = cmd.Output(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			w.logger.Warn().AnErr("error", exitErr).Str("stderr", string(exitErr.Stderr)).Msgf("error running syft sbom generation")
+		}
+		return fmt.Errorf("running syft sbom generation: %w", err)
+	}
+
+	specVersion, components, err := flattenSBOM(settings.Format, document)
+	if err != nil {
+		return fmt.Errorf("parse sbom: %w", err)
+	}
+
+	var tx pgx.Tx
+	if tx, err = w.beginTx(ctx); err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil {
+			if !errors.Is(err, pgx.ErrTxClosed) {
+				w.logger.Err(err).Msgf("could not rollback transaction")
+			}
+		}
+	}()
+
+	// a re-sync of the same commit replaces its SBOM (and the components of it, which cascade)
+	if _, err := tx.Exec(ctx, "DELETE FROM repo_sboms WHERE repo_id = $1 AND commit_hash = $2 AND format = $3;", j.RepoID, commitHash, settings.Format); err != nil {
+		return fmt.Errorf("exec delete: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, "INSERT INTO repo_sboms (repo_id, commit_hash, format, spec_version, document) VALUES ($1, $2, $3, $4, $5)",
+		j.RepoID, commitHash, settings.Format, nullIfEmpty(specVersion), document); err != nil {
+		return fmt.Errorf("inserting sbom: %w", err)
+	}
+
+	// components without a ref of their own are identified by their purl (or their name and version)
+	var inputs = make([][]interface{}, 0, len(components))
+	var seen = make(map[string]bool)
+	for _, c := range components {
+		var ref = c.Ref
+		if ref == "" {
+			if ref = c.PURL; ref == "" {
+				ref = c.Name + "@" + c.Version
+			}
+		}
+		if seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		inputs = append(inputs, []interface{}{j.RepoID, commitHash, settings.Format, ref, nullIfEmpty(c.Type), c.Name, nullIfEmpty(c.Version), nullIfEmpty(c.PURL), c.Licenses})
+	}
+
+	var inserted int64
+	if inserted, err = tx.CopyFrom(ctx, pgx.Identifier{"repo_sbom_components"}, []string{"repo_id", "commit_hash", "format", "ref", "type", "name", "version", "purl", "licenses"}, pgx.CopyFromRows(inputs)); err != nil {
+		return fmt.Errorf("tx copy from: %w", err)
+	}
+
+	l.Info().Msgf("inserted sbom of %s with %d components", commitHash, inserted)
+
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{
+		Type:            SyncLogTypeInfo,
+		RepoSyncQueueID: j.ID,
+		Message:         fmt.Sprintf("inserted the %s sbom of commit %s, with %d component(s)", settings.Format, commitHash, inserted),
+	}}); err != nil {
+		return err
+	}
+
+	if err := w.db.WithTx(tx).SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+		return fmt.Errorf("update status done: %w", err)
+	}
+
+	// indicate that we're finishing query execution
+	if err := w.sendBatchLogMessages(ctx, []*syncLog{{Type: SyncLogTypeInfo, RepoSyncQueueID: j.ID,
+		Message: fmt.Sprintf(LogFormatFinishingSync, j.SyncType, j.Repo),
+	}}); err != nil {
+		return fmt.Errorf("send batch log messages: %w", err)
+	}
+
+	err = tx.Commit(ctx)
+
+	return err
+}
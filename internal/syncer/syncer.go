@@ -2,8 +2,16 @@
 package syncer
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,44 +19,94 @@ import (
 	"github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/plumbing/cache"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 
+	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3"
 	_ "github.com/mergestat/mergestat-lite/pkg/sqlite"
+	"github.com/mergestat/mergestat/internal/config"
 	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/destination"
+	"github.com/mergestat/mergestat/internal/helper"
+	"github.com/mergestat/mergestat/internal/tracing"
+	"github.com/mergestat/mergestat/pkg/syncplugin"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
-	syncTypeGitCommits                = "GIT_COMMITS"
-	syncTypeGitCommitStats            = "GIT_COMMIT_STATS"
-	syncTypeGitRefs                   = "GIT_REFS"
-	syncTypeGitFiles                  = "GIT_FILES"
-	syncTypeGitBlame                  = "GIT_BLAME"
-	syncTypeGitRemotes                = "GIT_REMOTES"
-	syncTypeGitHubRepoMetadata        = "GITHUB_REPO_METADATA"
-	syncTypeGitHubRepoPRs             = "GITHUB_REPO_PRS"
-	syncTypeGitHubRepoIssues          = "GITHUB_REPO_ISSUES"
-	syncTypeGitHubRepoStars           = "GITHUB_REPO_STARS"
-	syncTypeGitHubPRReviews           = "GITHUB_PR_REVIEWS"
-	syncTypeGitHubPRCommits           = "GITHUB_PR_COMMITS"
-	syncTypeGitHubPRsAndCommits       = "GITHUB_PRS_AND_COMMITS"
-	syncTypeTrivyRepoScan             = "TRIVY_REPO_SCAN"
-	syncTypeSyftRepoScan              = "SYFT_REPO_SCAN"
-	syncTypeGitHubActions             = "GITHUB_ACTIONS"
-	syncTypeGitleaksRepoScan          = "GITLEAKS_REPO_SCAN"
-	syncTypeYelpDetectSecretsRepoScan = "YELP_DETECT_SECRETS_REPO_SCAN"
-	syncTypeGosecRepoScan             = "GOSEC_REPO_SCAN"
-	syncTypeOSSFScorecardRepoScan     = "OSSF_SCORECARD_REPO_SCAN"
-	syncTypeGrypeScan                 = "GRYPE_REPO_SCAN"
+	syncTypeGitCommits                 = "GIT_COMMITS"
+	syncTypeGitCommitStats             = "GIT_COMMIT_STATS"
+	syncTypeGitRefs                    = "GIT_REFS"
+	syncTypeGitFiles                   = "GIT_FILES"
+	syncTypeGitBlame                   = "GIT_BLAME"
+	syncTypeGitRemotes                 = "GIT_REMOTES"
+	syncTypeGitSignatures              = "GIT_SIGNATURES"
+	syncTypeGitFilesLanguages          = "GIT_FILES_LANGUAGES"
+	syncTypeGitLicenses                = "GIT_LICENSES"
+	syncTypeGitDependencies            = "GIT_DEPENDENCIES"
+	syncTypeGitCodeowners              = "GIT_CODEOWNERS"
+	syncTypeGitSubmodules              = "GIT_SUBMODULES"
+	syncTypeGitLFSObjects              = "GIT_LFS_OBJECTS"
+	syncTypeGitTree                    = "GIT_TREE"
+	syncTypeGitHubRepoMetadata         = "GITHUB_REPO_METADATA"
+	syncTypeGitHubRepoPRs              = "GITHUB_REPO_PRS"
+	syncTypeGitHubRepoIssues           = "GITHUB_REPO_ISSUES"
+	syncTypeGitHubRepoStars            = "GITHUB_REPO_STARS"
+	syncTypeGitHubRepoReleases         = "GITHUB_REPO_RELEASES"
+	syncTypeGitHubIssueComments        = "GITHUB_ISSUE_COMMENTS"
+	syncTypeGitHubPRComments           = "GITHUB_PR_COMMENTS"
+	syncTypeGitHubChecks               = "GITHUB_CHECKS"
+	syncTypeGitHubDeployments          = "GITHUB_DEPLOYMENTS"
+	syncTypeGitHubBranchProtections    = "GITHUB_BRANCH_PROTECTIONS"
+	syncTypeGitHubOrgMembers           = "GITHUB_ORG_MEMBERS"
+	syncTypeGitHubRepoCollaborators    = "GITHUB_REPO_COLLABORATORS"
+	syncTypeGitHubLabelsAndMilestones  = "GITHUB_LABELS_AND_MILESTONES"
+	syncTypeGitHubDiscussions          = "GITHUB_DISCUSSIONS"
+	syncTypeGitHubTraffic              = "GITHUB_TRAFFIC"
+	syncTypeGitHubPackages             = "GITHUB_PACKAGES"
+	syncTypeGitHubDependabotAlerts     = "GITHUB_DEPENDABOT_ALERTS"
+	syncTypeGitHubCodeScanningAlerts   = "GITHUB_CODE_SCANNING_ALERTS"
+	syncTypeGitHubSecretScanningAlerts = "GITHUB_SECRET_SCANNING_ALERTS"
+	syncTypeGitHubPRReviews            = "GITHUB_PR_REVIEWS"
+	syncTypeGitHubPRCommits            = "GITHUB_PR_COMMITS"
+	syncTypeGitHubPRsAndCommits        = "GITHUB_PRS_AND_COMMITS"
+	syncTypeTrivyRepoScan              = "TRIVY_REPO_SCAN"
+	syncTypeSyftRepoScan               = "SYFT_REPO_SCAN"
+	syncTypeSyftRepoSBOM               = "SYFT_REPO_SBOM"
+	syncTypeSecretsRepoScan            = "SECRETS_REPO_SCAN"
+	syncTypeGitHubActions              = "GITHUB_ACTIONS"
+	syncTypeGitleaksRepoScan           = "GITLEAKS_REPO_SCAN"
+	syncTypeYelpDetectSecretsRepoScan  = "YELP_DETECT_SECRETS_REPO_SCAN"
+	syncTypeGosecRepoScan              = "GOSEC_REPO_SCAN"
+	syncTypeOSSFScorecardRepoScan      = "OSSF_SCORECARD_REPO_SCAN"
+	syncTypeGrypeScan                  = "GRYPE_REPO_SCAN"
+	syncTypeBitbucketRepoPRs           = "BITBUCKET_REPO_PRS"
+	syncTypeBitbucketRepoPipelines     = "BITBUCKET_REPO_PIPELINES"
+	syncTypeAzureRepoPRs               = "AZURE_REPO_PRS"
+	syncTypeAzureRepoBuilds            = "AZURE_REPO_BUILDS"
+	syncTypeGitLabRepoMRs              = "GITLAB_REPO_MRS"
+	syncTypeGitLabRepoPipelines        = "GITLAB_REPO_PIPELINES"
+	syncTypeJiraIssueLinks             = "JIRA_ISSUE_LINKS"
+	syncTypeHTTPJSONAPI                = "HTTP_JSON_API"
+	syncTypeContainerExec              = "CONTAINER_EXEC"
 )
 
+// syncTypeVendors lists the sync types that can only run against repos of a particular vendor
+var syncTypeVendors = map[string]string{
+	syncTypeBitbucketRepoPRs:       "bitbucket",
+	syncTypeBitbucketRepoPipelines: "bitbucket",
+	syncTypeAzureRepoPRs:           "azure",
+	syncTypeAzureRepoBuilds:        "azure",
+	syncTypeGitLabRepoMRs:          "gitlab",
+	syncTypeGitLabRepoPipelines:    "gitlab",
+}
+
 var errGitHubTokenRequired = errors.New("in order to run this syncer, a GitHub authentication token must be present")
 
 type worker struct {
@@ -56,47 +114,99 @@ type worker struct {
 	pool         *pgxpool.Pool
 	mergestat    *sqlx.DB
 	db           *db.Queries
+	config       *config.Config
 	concurrency  int
 	pollInterval time.Duration
+	cacheLocks   sync.Map // per clone read-write locks of the clone cache (see cachedClone)
+	repoLocks    sync.Map // per repo locks, so that jobs of the same repo never run concurrently (see lockRepo)
+	wake         chan struct{}
+	eventsSecret []byte                    // key of the signature of the events posted to the events webhook (see postEvent)
+	destinations []destination.Destination // the stores the rows written by syncs are also sent to (see destinationTx)
 }
 
-func New(pool *pgxpool.Pool, mergestat *sqlx.DB, logger *zerolog.Logger, concurrency int, pollInterval time.Duration) *worker {
+func New(pool *pgxpool.Pool, mergestat *sqlx.DB, logger *zerolog.Logger, cfg *config.Config, destinations ...destination.Destination) *worker {
 	return &worker{
 		logger:       logger,
 		pool:         pool,
 		mergestat:    mergestat,
 		db:           db.New(pool),
-		concurrency:  concurrency,
-		pollInterval: pollInterval,
+		config:       cfg,
+		concurrency:  cfg.Concurrency,
+		pollInterval: cfg.SyncerInterval(),
+		wake:         make(chan struct{}, cfg.Concurrency),
+		eventsSecret: []byte(os.Getenv("SYNC_EVENTS_WEBHOOK_SECRET")),
+		destinations: destinations,
 	}
 }
 
-// dequeue blocks until a job is available or the context is canceled.
-// It checks for new jobs on the syncer pollInterval
-func (w *worker) dequeue(ctx context.Context) (*db.DequeueSyncJobRow, error) {
+// createTempDir creates a temp dir (under the configured clone path) for a job of the given repo to clone it into
+func (w *worker) createTempDir(repoID uuid.UUID) (string, func() error, error) {
+	return helper.CreateTempDir(w.config.GitClonePath, fmt.Sprintf("mergestat-repo-%s-*", repoID.String()))
+}
+
+// dequeue blocks until a job is available or the context is canceled, and returns it along with the jobs batched with it.
+// It checks for new jobs on the syncer pollInterval, or as soon as a job is enqueued to run right away (see listen)
+func (w *worker) dequeue(ctx context.Context) ([]*db.DequeueSyncJobRow, error) {
 	for {
 		select {
 		case _, ok := <-ctx.Done():
 			if !ok {
 				return nil, ctx.Err()
 			}
+			continue
 		case <-time.After(w.pollInterval):
-			var job db.DequeueSyncJobRow
-			var err error
-			if job, err = w.db.DequeueSyncJob(ctx); err != nil {
-				if errors.Is(err, pgx.ErrNoRows) {
-					continue
-				}
-				return nil, err
+		case <-w.wake:
+		}
+
+		var jobs []*db.DequeueSyncJobRow
+		var err error
+		var tenant = sql.NullString{String: w.config.Tenant, Valid: w.config.Tenant != ""}
+		if jobs, err = w.dequeueSyncJob(ctx, tenant); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				continue
 			}
+			return nil, err
+		}
+
+		return jobs, nil
+	}
+}
 
-			return &job, nil
+// dequeueSyncJob dequeues the next job within the concurrency limits (see DequeueSyncJob), along with the jobs batched
+// with it (see dequeueBatch). Workers dequeue one at a time, holding an advisory lock taken before the jobs are picked,
+// so that the running jobs counted against the limits include the ones just dequeued by other workers (of any type group),
+// rather than only the ones running as of when they started.
+func (w *worker) dequeueSyncJob(ctx context.Context, tenant sql.NullString) (jobs []*db.DequeueSyncJobRow, err error) {
+	var tx pgx.Tx
+	if tx, err = w.pool.BeginTx(ctx, pgx.TxOptions{}); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := tx.Rollback(ctx); err != nil && !errors.Is(err, pgx.ErrTxClosed) {
+			w.logger.Err(err).Msgf("rollback transaction: %v", err)
 		}
+	}()
+
+	// taken by a statement of its own, so that the jobs are counted as of when the lock is held
+	if _, err = tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext('mergestat.repo_sync_queue:dequeue'))"); err != nil {
+		return nil, fmt.Errorf("lock dequeue: %w", err)
+	}
+
+	var q = w.db.WithTx(tx)
+	var job db.DequeueSyncJobRow
+	if job, err = q.DequeueSyncJob(ctx, tenant); err != nil {
+		return nil, err
 	}
+
+	if jobs, err = w.dequeueBatch(ctx, q, &job); err != nil {
+		return nil, fmt.Errorf("dequeue batch: %w", err)
+	}
+
+	return jobs, tx.Commit(ctx)
 }
 
-// exec loops until the context is canceled, executing a sync.
-func (w *worker) exec(ctx context.Context, id string) {
+// exec loops until the context is canceled, executing a sync. Jobs run with jobCtx (see Start).
+func (w *worker) exec(ctx, jobCtx context.Context, id string) {
 	w.logger.Info().Msgf("starting exec loop: %s", id)
 	for {
 		select {
@@ -106,7 +216,7 @@ func (w *worker) exec(ctx context.Context, id string) {
 				return
 			}
 		default:
-			j, err := w.dequeue(ctx)
+			jobs, err := w.dequeue(ctx)
 			if err != nil {
 				// if error is a context cancellation, go to next tick of loop where
 				// done case will be selected
@@ -118,38 +228,100 @@ func (w *worker) exec(ctx context.Context, id string) {
 				continue
 			}
 
-			w.loggerForJob(j).Info().Msg("dequeued job")
-
-			if err := w.handle(ctx, j); err != nil {
-				if !errors.Is(err, context.Canceled) {
-					w.logger.Warn().AnErr("error", err).Msgf("error handling job: %v", j)
-
-					if err := w.db.InsertSyncJobLog(context.TODO(), db.InsertSyncJobLogParams{
-						LogType:         string(SyncLogTypeError),
-						Message:         err.Error(),
-						RepoSyncQueueID: j.ID,
-					}); err != nil {
-						w.logger.Err(err).Msgf("error sending log error message: %v", err)
-					}
-
-					if err := w.db.SetSyncJobStatus(context.TODO(), db.SetSyncJobStatusParams{
-						Status: "DONE",
-						ID:     j.ID,
-					}); err != nil {
-						w.logger.Err(err).Msgf("error marking sync job as done: %v", err)
-					}
-					continue
-				} else {
-					// if the error was a context cancellation, reset the status to QUEUED
-					if err := w.db.SetSyncJobStatus(context.TODO(), db.SetSyncJobStatusParams{
-						Status: "QUEUED",
-						ID:     j.ID,
-					}); err != nil {
-						w.logger.Err(err).Msgf("error marking sync job as queued: %v", err)
-					}
-				}
+			w.handleBatch(ctx, jobCtx, jobs)
+		}
+	}
+}
+
+// run handles a single job, recording any error it fails with
+func (w *worker) run(ctx context.Context, j *db.DequeueSyncJobRow) {
+	w.loggerForJob(j).Info().Msg("dequeued job")
+
+	var jobErr error
+	ctx, span := startJobSpan(ctx, j)
+	defer func() { tracing.End(span, jobErr) }()
+
+	// don't sync on top of inconsistent data, if a sync this one depends on failed
+	failed, err := w.db.ListFailedSyncDependencies(ctx, db.ListFailedSyncDependenciesParams{RepoID: j.RepoID, SyncType: j.SyncType})
+	if err != nil {
+		w.loggerForJob(j).Err(err).Msgf("error fetching failed sync dependencies: %v", err)
+	} else if len(failed) > 0 {
+		w.skip(j, failed)
+		return
+	}
+
+	accountingCtx, stopAccounting := w.startResourceAccounting(ctx, j)
+	defer stopAccounting()
+
+	accountingCtx, releaseCachedClones := withCachedCloneReaders(accountingCtx)
+	defer releaseCachedClones()
+
+	var phase = &jobPhase{}
+	jobCtx, cancel, timeout := w.withJobTimeout(context.WithValue(accountingCtx, jobPhaseKey{}, phase), j)
+	defer cancel()
+
+	if err := w.handle(jobCtx, j); err != nil {
+		jobErr = err
+
+		// the handler's transaction is rolled back (its connection is closed) once its context is canceled
+		if errors.Is(jobCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			w.timedOut(j, timeout, phase)
+		} else if !errors.Is(err, context.Canceled) {
+			w.logger.Warn().AnErr("error", err).Msgf("error handling job: %v", j)
+
+			if err := w.db.InsertSyncJobLog(context.TODO(), db.InsertSyncJobLogParams{
+				LogType:         string(SyncLogTypeError),
+				Message:         err.Error(),
+				RepoSyncQueueID: j.ID,
+			}); err != nil {
+				w.logger.Err(err).Msgf("error sending log error message: %v", err)
 			}
+
+			// the job is re-queued for another attempt, or moved to DEAD once it runs out of attempts
+			status, err := w.db.FailSyncJob(context.TODO(), db.FailSyncJobParams{LastError: err.Error(), ID: j.ID})
+			if err != nil {
+				w.logger.Err(err).Msgf("error marking sync job as failed: %v", err)
+			} else if status == "DEAD" {
+				w.loggerForJob(j).Warn().Msg("job ran out of attempts, moved to dead-letter queue")
+			}
+
+			w.recordUnreachable(j, jobErr)
+		} else {
+			w.requeue(j)
 		}
+	} else {
+		w.markReachable(j)
+	}
+}
+
+// skip marks a job as SKIPPED, as the latest runs of the given sync types it depends on failed
+func (w *worker) skip(j *db.DequeueSyncJobRow, failed []string) {
+	var message = fmt.Sprintf("skipping sync, as the sync(s) it depends on failed: %s", strings.Join(failed, ", "))
+	w.loggerForJob(j).Warn().Msg(message)
+
+	if err := w.db.InsertSyncJobLog(context.TODO(), db.InsertSyncJobLogParams{
+		LogType:         string(SyncLogTypeWarn),
+		Message:         message,
+		RepoSyncQueueID: j.ID,
+	}); err != nil {
+		w.logger.Err(err).Msgf("error sending log warning message: %v", err)
+	}
+
+	if err := w.db.SetSyncJobStatus(context.TODO(), db.SetSyncJobStatusParams{
+		Status: "SKIPPED",
+		ID:     j.ID,
+	}); err != nil {
+		w.logger.Err(err).Msgf("error marking sync job as skipped: %v", err)
+	}
+}
+
+// requeue resets the status of a job to QUEUED, e.g. when it was interrupted by the worker shutting down
+func (w *worker) requeue(j *db.DequeueSyncJobRow) {
+	if err := w.db.SetSyncJobStatus(context.TODO(), db.SetSyncJobStatusParams{
+		Status: "QUEUED",
+		ID:     j.ID,
+	}); err != nil {
+		w.logger.Err(err).Msgf("error marking sync job as queued: %v", err)
 	}
 }
 
@@ -157,9 +329,61 @@ func (w *worker) exec(ctx context.Context, id string) {
 func (w *worker) handle(ctx context.Context, j *db.DequeueSyncJobRow) error {
 	w.loggerForJob(j).Info().Msg("handling job")
 
-	done := w.startKeepAlives(j, 30*time.Second)
-	defer done()
+	var startedAt, counts = time.Now(), newRowCounts()
+	ctx = context.WithValue(ctx, rowCountsKey{}, counts)
+
+	// make sure vendor-specific syncs only run against repos from that vendor (based on the repo's provider)
+	if required, ok := syncTypeVendors[j.SyncType]; ok {
+		vendor, err := w.db.GetRepoVendor(ctx, j.RepoID)
+		if err != nil {
+			return fmt.Errorf("fetch repo vendor: %w", err)
+		}
+
+		if vendor != required {
+			return fmt.Errorf("sync type %s requires a %s repo, but repo is from: %s", j.SyncType, required, vendor)
+		}
+	}
+
+	// in dry-run mode, everything the handler writes is counted and rolled back (see beginTx)
+	dry, err := w.isDryRun(j)
+	if err != nil {
+		return err
+	}
 
+	if dry {
+		if dryRunUnsupportedSyncTypes[j.SyncType] {
+			return fmt.Errorf("sync type %s can't be run in dry-run mode", j.SyncType)
+		}
+
+		var dr = &dryRun{counts: counts}
+		if err = w.handleSyncType(context.WithValue(ctx, dryRunKey{}, dr), j); err != nil {
+			return err
+		}
+		return w.finishDryRun(ctx, j, dr)
+	}
+
+	// the rows written by the handler are sent to the destinations (if any) once they're committed (see destinationTx)
+	if len(w.destinations) > 0 {
+		ctx = context.WithValue(ctx, destinationsJobKey{}, j)
+	}
+
+	if err = w.handleSyncType(ctx, j); err != nil {
+		return err
+	}
+
+	// in destinations-only mode, the status set by the handler was rolled back along with its rows
+	if len(w.destinations) > 0 && w.config.DestinationsOnly {
+		if err = w.db.SetSyncJobStatus(ctx, db.SetSyncJobStatusParams{Status: "DONE", ID: j.ID}); err != nil {
+			return fmt.Errorf("update status done: %w", err)
+		}
+	}
+
+	w.publishCompleted(ctx, j, counts, startedAt)
+	return nil
+}
+
+// handleSyncType runs the handler of the job's sync type
+func (w *worker) handleSyncType(ctx context.Context, j *db.DequeueSyncJobRow) error {
 	switch j.SyncType {
 	case syncTypeGitCommits:
 		return w.handleGitCommits(ctx, j)
@@ -173,6 +397,22 @@ func (w *worker) handle(ctx context.Context, j *db.DequeueSyncJobRow) error {
 		return w.handleGitBlame(ctx, j)
 	case syncTypeGitRemotes:
 		return w.handleGitRemotes(ctx, j)
+	case syncTypeGitSignatures:
+		return w.handleGitSignatures(ctx, j)
+	case syncTypeGitFilesLanguages:
+		return w.handleGitFilesLanguages(ctx, j)
+	case syncTypeGitLicenses:
+		return w.handleGitLicenses(ctx, j)
+	case syncTypeGitDependencies:
+		return w.handleGitDependencies(ctx, j)
+	case syncTypeGitCodeowners:
+		return w.handleGitCodeowners(ctx, j)
+	case syncTypeGitSubmodules:
+		return w.handleGitSubmodules(ctx, j)
+	case syncTypeGitLFSObjects:
+		return w.handleGitLFSObjects(ctx, j)
+	case syncTypeGitTree:
+		return w.handleGitTree(ctx, j)
 	case syncTypeGitHubRepoMetadata:
 		return w.handleGitHubRepoMetadata(ctx, j)
 	case syncTypeGitHubRepoPRs:
@@ -181,6 +421,36 @@ func (w *worker) handle(ctx context.Context, j *db.DequeueSyncJobRow) error {
 		return w.handleGitHubRepoIssues(ctx, j)
 	case syncTypeGitHubRepoStars:
 		return w.handleGitHubRepoStars(ctx, j)
+	case syncTypeGitHubRepoReleases:
+		return w.handleGitHubRepoReleases(ctx, j)
+	case syncTypeGitHubIssueComments:
+		return w.handleGitHubIssueComments(ctx, j)
+	case syncTypeGitHubPRComments:
+		return w.handleGitHubPRComments(ctx, j)
+	case syncTypeGitHubChecks:
+		return w.handleGitHubChecks(ctx, j)
+	case syncTypeGitHubDeployments:
+		return w.handleGitHubDeployments(ctx, j)
+	case syncTypeGitHubBranchProtections:
+		return w.handleGitHubBranchProtections(ctx, j)
+	case syncTypeGitHubOrgMembers:
+		return w.handleGitHubOrgMembers(ctx, j)
+	case syncTypeGitHubRepoCollaborators:
+		return w.handleGitHubRepoCollaborators(ctx, j)
+	case syncTypeGitHubLabelsAndMilestones:
+		return w.handleGitHubLabelsAndMilestones(ctx, j)
+	case syncTypeGitHubDiscussions:
+		return w.handleGitHubDiscussions(ctx, j)
+	case syncTypeGitHubTraffic:
+		return w.handleGitHubTraffic(ctx, j)
+	case syncTypeGitHubPackages:
+		return w.handleGitHubPackages(ctx, j)
+	case syncTypeGitHubDependabotAlerts:
+		return w.handleGitHubRepoDependabotAlerts(ctx, j)
+	case syncTypeGitHubCodeScanningAlerts:
+		return w.handleGitHubRepoCodeScanningAlerts(ctx, j)
+	case syncTypeGitHubSecretScanningAlerts:
+		return w.handleGitHubRepoSecretScanningAlerts(ctx, j)
 	case syncTypeGitHubPRReviews:
 		return w.handleGitHubPRReviews(ctx, j)
 	case syncTypeGitHubPRCommits:
@@ -191,6 +461,10 @@ func (w *worker) handle(ctx context.Context, j *db.DequeueSyncJobRow) error {
 		return w.handleTrivyRepoScan(ctx, j)
 	case syncTypeSyftRepoScan:
 		return w.handleSyftRepoScan(ctx, j)
+	case syncTypeSyftRepoSBOM:
+		return w.handleSyftRepoSBOM(ctx, j)
+	case syncTypeSecretsRepoScan:
+		return w.handleSecretsRepoScan(ctx, j)
 	case syncTypeGitHubActions:
 		return w.handleGithubActions(ctx, j)
 	case syncTypeGitleaksRepoScan:
@@ -203,22 +477,72 @@ func (w *worker) handle(ctx context.Context, j *db.DequeueSyncJobRow) error {
 		return w.handleOSSFScorecardScan(ctx, j)
 	case syncTypeGrypeScan:
 		return w.handleGrypeRepoScan(ctx, j)
+	case syncTypeBitbucketRepoPRs:
+		return w.handleBitbucketRepoPRs(ctx, j)
+	case syncTypeBitbucketRepoPipelines:
+		return w.handleBitbucketRepoPipelines(ctx, j)
+	case syncTypeGitLabRepoMRs:
+		return w.handleGitLabRepoMRs(ctx, j)
+	case syncTypeGitLabRepoPipelines:
+		return w.handleGitLabRepoPipelines(ctx, j)
+	case syncTypeAzureRepoPRs:
+		return w.handleAzureRepoPRs(ctx, j)
+	case syncTypeAzureRepoBuilds:
+		return w.handleAzureRepoBuilds(ctx, j)
+	case syncTypeJiraIssueLinks:
+		return w.handleJiraIssueLinks(ctx, j)
+	case syncTypeHTTPJSONAPI:
+		return w.handleHTTPJSONAPI(ctx, j)
+	case syncTypeContainerExec:
+		return w.handleContainerExec(ctx, j)
 	default:
+		if t, ok := syncplugin.Lookup(j.SyncType); ok {
+			return w.handlePlugin(ctx, j, t)
+		}
 		return fmt.Errorf("unknown sync type: %s for job ID: %d", j.SyncType, j.ID)
 	}
 }
 
-// Start starts running the workers until the ctx is canceled.
-func (w *worker) Start(ctx context.Context) {
+// Start starts running the workers until the ctx is canceled. Once it is, the workers stop dequeuing new jobs
+// and the in-flight jobs are given up to drainTimeout to finish, after which they're canceled and requeued.
+func (w *worker) Start(ctx context.Context, drainTimeout time.Duration) {
+	// jobs run with a context of their own, so that they outlive ctx while draining
+	jobCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	if err := w.registerPlugins(ctx); err != nil {
+		w.logger.Err(err).Msgf("error registering plugin sync types: %v", err)
+	}
+
+	go w.listen(ctx)
+
+	var drained = make(chan struct{})
+	go func() {
+		select {
+		case <-drained:
+			return
+		case <-ctx.Done():
+		}
+
+		w.logger.Info().Msgf("draining in-flight jobs for up to %s", drainTimeout)
+		select {
+		case <-drained:
+		case <-time.After(drainTimeout):
+			w.logger.Warn().Msg("in-flight jobs did not finish in time, canceling them")
+			cancelJobs()
+		}
+	}()
+
 	g := &sync.WaitGroup{}
 	g.Add(w.concurrency)
 	for i := 0; i < w.concurrency; i++ {
 		go func(i int) {
-			w.exec(ctx, fmt.Sprintf("%d", i))
+			w.exec(ctx, jobCtx, fmt.Sprintf("%d", i))
 			g.Done()
 		}(i)
 	}
 	g.Wait()
+	close(drained)
 }
 
 func (w *worker) fetchCredentials(ctx context.Context, job *db.DequeueSyncJobRow) (_, _ string, err error) {
@@ -228,15 +552,187 @@ func (w *worker) fetchCredentials(ctx context.Context, job *db.DequeueSyncJobRow
 	}
 
 	var username, token string
-	if username, token, err = w.db.FetchCredential(ctx, repo.Provider); err != nil {
+	if username, token, err = w.db.FetchRepoCredential(ctx, repo.Provider, repo.ID); err != nil {
 		return "", "", err
 	}
 
 	return username, token, nil
 }
 
+// decodeSettings unmarshals the settings of the repo sync tied to this job into v.
+// A sync without any settings leaves v untouched.
+func decodeSettings(job *db.DequeueSyncJobRow, v interface{}) error {
+	if job.Settings.Status != pgtype.Present || len(job.Settings.Bytes) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(job.Settings.Bytes, v); err != nil {
+		return errors.Wrapf(err, "failed to parse sync settings")
+	}
+
+	return nil
+}
+
+// bareCloneSyncTypes are the sync types that only read git objects (and never the working tree).
+// Their repos are cloned without a checkout unless their settings say otherwise.
+var bareCloneSyncTypes = map[string]bool{
+	syncTypeGitCommits:        true,
+	syncTypeGitCommitStats:    true,
+	syncTypeGitRefs:           true,
+	syncTypeGitFiles:          true,
+	syncTypeGitSignatures:     true,
+	syncTypeGitFilesLanguages: true,
+	syncTypeGitLicenses:       true,
+	syncTypeGitDependencies:   true,
+	syncTypeSecretsRepoScan:   true,
+	syncTypeGitCodeowners:     true,
+	syncTypeGitSubmodules:     true,
+	syncTypeGitLFSObjects:     true,
+	syncTypeGitTree:           true,
+}
+
+// cloneFilterSyncTypes are the default partial clone filters of sync types that only need some of the git objects
+// (e.g. refs never reads the contents of files, so blobs don't have to be downloaded). Note that syncs reading the
+// repo with libgit2 (like commits and commit stats) can't open partial clones, as it doesn't support promisor remotes.
+var cloneFilterSyncTypes = map[string]string{
+	syncTypeGitRefs: "blob:none",
+	// LFS pointer files are (much) smaller than 1KiB, the objects they point to are never read
+	syncTypeGitLFSObjects: "blob:limit=1k",
+}
+
+// cloneSettings are the settings, accepted by any repo sync that clones the repo, controlling how it is cloned
+type cloneSettings struct {
+	// Bare skips checking out a working tree, which saves time and disk space on large repos.
+	// When not set, it defaults to true for the sync types in bareCloneSyncTypes.
+	Bare *bool `json:"bareClone"`
+
+	// Depth limits the clone to the given number of commits from the tip of each branch. 0 clones the entire history.
+	Depth int `json:"cloneDepth"`
+
+	// Filter is a partial clone filter (see git clone --filter), e.g. blob:none or tree:0.
+	// When not set, it defaults to the filter of the sync type in cloneFilterSyncTypes. An empty filter does a full clone.
+	Filter *string `json:"cloneFilter"`
+}
+
+// cloneSettingsFor returns the clone settings of the given job, with the defaults of its sync type applied
+func cloneSettingsFor(job *db.DequeueSyncJobRow) (*cloneSettings, error) {
+	var settings cloneSettings
+	if err := decodeSettings(job, &settings); err != nil {
+		return nil, err
+	}
+
+	if settings.Bare == nil {
+		var bare = bareCloneSyncTypes[job.SyncType]
+		settings.Bare = &bare
+	}
+
+	if settings.Filter == nil {
+		var filter = cloneFilterSyncTypes[job.SyncType]
+		settings.Filter = &filter
+	}
+
+	return &settings, nil
+}
+
+// sharedCloneSettingsFor returns the clone settings of the given job of a batch, as cloneSettingsFor, but without the
+// default filter of its sync type: it only saves downloading objects the job doesn't read, which a full clone (shared
+// with the other jobs of the batch) serves just as well.
+func sharedCloneSettingsFor(job *db.DequeueSyncJobRow) (*cloneSettings, error) {
+	var settings cloneSettings
+	if err := decodeSettings(job, &settings); err != nil {
+		return nil, err
+	}
+
+	if settings.Bare == nil {
+		var bare = bareCloneSyncTypes[job.SyncType]
+		settings.Bare = &bare
+	}
+
+	if settings.Filter == nil {
+		var filter = ""
+		settings.Filter = &filter
+	}
+
+	return &settings, nil
+}
+
+// openOrClone returns the path of the repository tied to this job. Repos of the local vendor are opened in place
+// (their url being a path on the worker's filesystem, e.g. a mounted volume of mirrors). If the clone cache is enabled
+// (with GIT_CLONE_CACHE_PATH) bare clones are served from the cache, all others are cloned into the given path.
+func (w *worker) openOrClone(ctx context.Context, path string, job *db.DequeueSyncJobRow) (_ string, err error) {
+	var vendor string
+	if vendor, err = w.db.GetRepoVendor(ctx, job.RepoID); err != nil {
+		return "", errors.Wrapf(err, "failed to fetch repo vendor")
+	}
+
+	if cacheDir := w.config.GitCloneCachePath; vendor != "local" && cacheDir != "" {
+		var settings *cloneSettings
+		if settings, err = cloneSettingsFor(job); err != nil {
+			return "", err
+		}
+
+		if *settings.Bare {
+			return w.cachedClone(ctx, cacheDir, job)
+		}
+	}
+
+	// jobs that are part of a batch share a clone with the other jobs of the batch having the same clone settings,
+	// made by the first of them that needs it
+	if checkouts, ok := ctx.Value(sharedCheckoutKey{}).(*sharedCheckouts); ok && vendor != "local" {
+		var settings *cloneSettings
+		if settings, err = sharedCloneSettingsFor(job); err != nil {
+			return "", err
+		}
+
+		var checkout = checkouts.get(settings)
+		if err = checkout.clone(ctx, func(ctx context.Context, path string) error {
+			return w.cloneWith(ctx, path, job, settings)
+		}); err != nil {
+			return "", err
+		}
+		trackScratchDir(ctx, checkout.path)
+		return checkout.path, nil
+	}
+
+	if vendor != "local" {
+		if err = w.clone(ctx, path, job); err != nil {
+			return "", err
+		}
+		trackScratchDir(ctx, path)
+		return path, nil
+	}
+
+	var repoPath = strings.TrimPrefix(job.Repo, "file://")
+	if _, err = os.Stat(repoPath); err != nil {
+		return "", errors.Wrapf(err, "failed to open local repository")
+	}
+
+	if err = w.sendBatchLogMessages(ctx, []*syncLog{{
+		Type:            SyncLogTypeInfo,
+		RepoSyncQueueID: job.ID,
+		Message:         "opening local git repository: " + repoPath,
+	}}); err != nil {
+		return "", err
+	}
+
+	return repoPath, nil
+}
+
 // clone clones the repository tied to this job into the given path.
 func (w *worker) clone(ctx context.Context, path string, job *db.DequeueSyncJobRow) (err error) {
+	var settings *cloneSettings
+	if settings, err = cloneSettingsFor(job); err != nil {
+		return err
+	}
+
+	return w.cloneWith(ctx, path, job, settings)
+}
+
+// cloneWith clones the repository tied to this job into the given path, using the given settings.
+func (w *worker) cloneWith(ctx context.Context, path string, job *db.DequeueSyncJobRow, settings *cloneSettings) (err error) {
+	ctx, span := tracing.Start(ctx, "git clone", attribute.Bool("git.bare", *settings.Bare), attribute.String("git.filter", *settings.Filter))
+	defer func() { tracing.End(span, err) }()
+
 	var logger = w.logger.With().Str("repo", job.RepoID.String()).Logger()
 	logger.Info().Msgf("starting git repository clone")
 
@@ -245,6 +741,10 @@ func (w *worker) clone(ctx context.Context, path string, job *db.DequeueSyncJobR
 		return err
 	}
 
+	if err = w.ensureDiskSpace(ctx, path, job); err != nil {
+		return err
+	}
+
 	if err = w.sendBatchLogMessages(ctx, []*syncLog{{
 		Type:            SyncLogTypeInfo,
 		RepoSyncQueueID: job.ID,
@@ -253,60 +753,166 @@ func (w *worker) clone(ctx context.Context, path string, job *db.DequeueSyncJobR
 		return err
 	}
 
+	var r *remote
+	if r, err = w.remoteFor(ctx, repo); err != nil {
+		return err
+	}
+
+	if err = w.followRename(ctx, job, &repo, r); err != nil {
+		return err
+	}
+
+	// go-git doesn't support partial clones, so those are handed off to the git cli
+	if *settings.Filter != "" {
+		if err = partialClone(ctx, path, r, settings); err != nil {
+			return errors.Wrapf(err, "failed to clone repository")
+		}
+	} else if err = fullClone(ctx, path, r, settings); err != nil {
+		return errors.Wrapf(err, "failed to clone repository")
+	}
+
+	logger.Info().Msgf("finished git repository clone: %s", repo.Repo)
+
+	if err = w.sendBatchLogMessages(ctx, []*syncLog{{
+		Type:            SyncLogTypeInfo,
+		RepoSyncQueueID: job.ID,
+		Message:         "finished git clone successfully: " + repo.Repo,
+	}}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// remote is the endpoint a repo is cloned from, along with the credentials to authenticate with
+type remote struct {
+	endpoint        *transport.Endpoint
+	username, token string
+}
+
+// remoteFor returns the remote to clone the given repo from
+func (w *worker) remoteFor(ctx context.Context, repo db.Repo) (_ *remote, err error) {
 	// TODO(@riyaz): we can improve this by first detecting the kind of url
 	// 		and then fetching the appropriate type of credential for it.
 	// 		This still involves couple of challenges (differentiating between different provider tokens etc.)
 
 	// fetch the username and token for the provider
-	var username, token string
-	if username, token, err = w.db.FetchCredential(ctx, repo.Provider); err != nil {
-		return err
+	var r remote
+	if r.username, r.token, err = w.db.FetchRepoCredential(ctx, repo.Provider, repo.ID); err != nil {
+		return nil, err
 	}
 
-	var endpoint *transport.Endpoint
-	if endpoint, err = transport.NewEndpoint(repo.Repo); err != nil {
-		return errors.Wrapf(err, "failed to parse url")
+	if r.endpoint, err = transport.NewEndpoint(repo.Repo); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse url")
 	}
 
-	var auth transport.AuthMethod
-	if endpoint.Protocol == "ssh" {
-		if username == "" {
-			username = endpoint.User // in case the username is encoded into the url (very common)
+	// Bitbucket app passwords are bound to a username (stored with the credential), whereas
+	// Bitbucket access tokens (which have no username) must use a special, fixed username.
+	if (r.endpoint.Protocol == "http" || r.endpoint.Protocol == "https") && r.username == "" && r.token != "" {
+		var vendor string
+		if vendor, err = w.db.GetRepoVendor(ctx, repo.ID); err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch repo vendor")
 		}
 
-		if auth, err = ssh.NewPublicKeys(username, []byte(token), ""); err != nil {
-			return errors.Wrapf(err, "failed to parse ssh key")
-		}
-	} else if endpoint.Protocol == "http" || endpoint.Protocol == "https" || endpoint.Protocol == "git" {
-		if username == "" {
-			username = "git"
+		if vendor == "bitbucket" {
+			r.username = "x-token-auth"
 		}
+	}
 
-		if token != "" {
-			auth = &http.BasicAuth{Username: username, Password: token}
-		}
+	return &r, nil
+}
+
+// fullClone clones the repository at the given remote into path using go-git
+func fullClone(ctx context.Context, path string, r *remote, settings *cloneSettings) (err error) {
+	var auth transport.AuthMethod
+	if auth, err = helper.GetGitAuthMethod(r.endpoint, r.username, r.token); err != nil {
+		return err
 	}
 
 	// fs and target are different! target is a subdirectory of fs. target stores git objects (like commits, etc.)
 	// whereas fs contains the working directory (a local checkout) of the cloned repository.
+	// A bare clone has no working directory, and stores git objects directly at path.
 	var fs = osfs.New(path)
-	var dotgit, _ = fs.Chroot(".git")
-	var target = filesystem.NewStorage(dotgit, cache.NewObjectLRUDefault())
+	var target *filesystem.Storage
+	if *settings.Bare {
+		target, fs = filesystem.NewStorage(fs, cache.NewObjectLRUDefault()), nil
+	} else {
+		var dotgit, _ = fs.Chroot(".git")
+		target = filesystem.NewStorage(dotgit, cache.NewObjectLRUDefault())
+	}
 
-	var opts = &git.CloneOptions{URL: endpoint.String(), Auth: auth}
-	if _, err = git.CloneContext(ctx, target, fs, opts); err != nil {
-		return errors.Wrapf(err, "failed to clone repository")
+	var opts = &git.CloneOptions{URL: r.endpoint.String(), Auth: auth, Depth: settings.Depth}
+	_, err = git.CloneContext(ctx, target, fs, opts)
+	return err
+}
+
+// partialClone clones the repository at the given remote into path using the git cli, applying the filter of the given settings.
+func partialClone(ctx context.Context, path string, r *remote, settings *cloneSettings) error {
+	var args = []string{"clone", "--filter=" + *settings.Filter}
+	if *settings.Bare {
+		args = append(args, "--bare")
+	}
+	if settings.Depth > 0 {
+		args = append(args, "--depth", strconv.Itoa(settings.Depth), "--no-single-branch")
 	}
+	args = append(args, "--", r.endpoint.String(), path)
 
-	logger.Info().Msgf("finished git repository clone: %s", repo.Repo)
+	return runGit(ctx, "", r, args...)
+}
 
-	if err = w.sendBatchLogMessages(ctx, []*syncLog{{
-		Type:            SyncLogTypeInfo,
-		RepoSyncQueueID: job.ID,
-		Message:         "finished git clone successfully: " + repo.Repo,
-	}}); err != nil {
-		return err
+// runGit runs the git cli with the given arguments in dir, authenticating against the given remote. Credentials
+// are passed through the environment (and not as arguments) so that they aren't visible in the process list.
+func runGit(ctx context.Context, dir string, r *remote, args ...string) error {
+	var _, err = gitOutput(ctx, dir, r, args...)
+	return err
+}
+
+// gitOutput runs the git cli as runGit does, and returns its (standard) output
+func gitOutput(ctx context.Context, dir string, r *remote, args ...string) (_ []byte, err error) {
+	var cmd = exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+
+	switch r.endpoint.Protocol {
+	case "ssh":
+		// without a private key, git falls back to the keys of the running ssh-agent (if any)
+		if strings.Contains(r.token, "PRIVATE KEY") {
+			var key *os.File
+			if key, err = os.CreateTemp("", "mergestat-ssh-key-*"); err != nil {
+				return nil, err
+			}
+			defer os.Remove(key.Name())
+
+			if _, err = key.WriteString(r.token); err != nil {
+				key.Close()
+				return nil, err
+			}
+			if err = key.Close(); err != nil {
+				return nil, err
+			}
+
+			cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND=ssh -i "+key.Name()+" -o IdentitiesOnly=yes")
+		}
+	default:
+		if r.token != "" {
+			var username = r.username
+			if username == "" {
+				username = "git"
+			}
+
+			var credentials = base64.StdEncoding.EncodeToString([]byte(username + ":" + r.token))
+			cmd.Env = append(cmd.Env, "GIT_CONFIG_COUNT=1",
+				"GIT_CONFIG_KEY_0=http.extraHeader", "GIT_CONFIG_VALUE_0=Authorization: Basic "+credentials)
+		}
 	}
 
-	return nil
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	var output []byte
+	if output, err = cmd.Output(); err != nil {
+		return nil, errors.Wrapf(err, "git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+
+	return output, nil
 }
@@ -0,0 +1,74 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// jobPhaseKey is the context key of the jobPhase of a running job
+type jobPhaseKey struct{}
+
+// jobPhase keeps track of what a running job is doing, based on the last message it logged (see sendBatchLogMessages),
+// so that we can tell where a job was at when it timed out
+type jobPhase struct {
+	mu      sync.Mutex
+	message string
+}
+
+func (p *jobPhase) set(message string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.message = message
+}
+
+func (p *jobPhase) String() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.message == "" {
+		return "starting"
+	}
+	return p.message
+}
+
+// withJobTimeout returns a context that is canceled once the job runs longer than the timeout of its sync type.
+// The returned timeout is zero if the sync type has none, in which case the context is only canceled along with the parent.
+func (w *worker) withJobTimeout(ctx context.Context, j *db.DequeueSyncJobRow) (context.Context, context.CancelFunc, time.Duration) {
+	seconds, err := w.db.GetSyncTypeTimeout(ctx, j.SyncType)
+	if err != nil {
+		w.loggerForJob(j).Err(err).Msgf("error fetching sync type timeout: %v", err)
+	}
+
+	if !seconds.Valid {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, cancel, 0
+	}
+
+	var timeout = time.Duration(seconds.Int32) * time.Second
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	return ctx, cancel, timeout
+}
+
+// timedOut records that the job was canceled after running for longer than the given timeout, while in the given phase
+func (w *worker) timedOut(j *db.DequeueSyncJobRow, timeout time.Duration, phase *jobPhase) {
+	var message = fmt.Sprintf("sync timed out after %s, while: %s", timeout, phase)
+	w.loggerForJob(j).Warn().Msg(message)
+
+	if err := w.db.InsertSyncJobLog(context.TODO(), db.InsertSyncJobLogParams{
+		LogType:         string(SyncLogTypeError),
+		Message:         message,
+		RepoSyncQueueID: j.ID,
+	}); err != nil {
+		w.logger.Err(err).Msgf("error sending log error message: %v", err)
+	}
+
+	if err := w.db.SetSyncJobStatus(context.TODO(), db.SetSyncJobStatusParams{
+		Status: "TIMED_OUT",
+		ID:     j.ID,
+	}); err != nil {
+		w.logger.Err(err).Msgf("error marking sync job as timed out: %v", err)
+	}
+}
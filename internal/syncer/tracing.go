@@ -0,0 +1,32 @@
+package syncer
+
+import (
+	"context"
+	"time"
+
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/mergestat/mergestat/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startJobSpan starts the root span of a job's trace, which the spans of its clone, mergestat queries and
+// postgres statements (see tracing.PgxLogger) are children of
+func startJobSpan(ctx context.Context, j *db.DequeueSyncJobRow) (context.Context, trace.Span) {
+	ctx, span := tracing.Start(ctx, "sync "+j.SyncType,
+		attribute.Int64("mergestat.job.id", j.ID),
+		attribute.String("mergestat.job.sync_type", j.SyncType),
+		attribute.String("mergestat.repo.id", j.RepoID.String()),
+		attribute.String("mergestat.repo", j.Repo),
+		attribute.Int64("mergestat.job.queue_wait_ms", time.Since(j.CreatedAt).Milliseconds()),
+	)
+	return ctx, span
+}
+
+// selectMergestat runs a mergestat (sqlite) query, scanning its rows into dest, as a span of the job's trace
+func (w *worker) selectMergestat(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, "mergestat query", attribute.String("db.system", "sqlite"), attribute.String("db.statement", query))
+	defer func() { tracing.End(span, err) }()
+
+	return w.mergestat.SelectContext(ctx, dest, query, args...)
+}
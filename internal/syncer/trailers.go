@@ -0,0 +1,71 @@
+package syncer
+
+import (
+	"regexp"
+	"strings"
+)
+
+// commitTrailer is a trailer of a commit message (e.g. Co-authored-by: Jane Doe <jane@example.com>), and its position
+// among the trailers of the commit
+type commitTrailer struct {
+	CommitHash string
+	Ordinal    int
+	Key        string // lower-cased, e.g. co-authored-by
+	Value      string
+	Name       string // of the person, for trailers whose value is a "Name <email>" identity
+	Email      string
+	Issue      string // the issue referenced, for trailers of issueTrailerKeys
+}
+
+var (
+	// trailerLine matches the first line of a trailer, whose key is made of alphanumerics and dashes
+	trailerLine = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9-]*)\s*:\s*(.*)$`)
+
+	// identity matches a "Name <email>" value, as in Signed-off-by or Co-authored-by trailers
+	identity = regexp.MustCompile(`^(.*?)\s*<([^<>]+)>$`)
+
+	// issueRef matches a reference to an issue: #123, owner/repo#123, a Jira-style key (PROJ-123), or an issue URL
+	issueRef = regexp.MustCompile(`(?:[\w.-]+/[\w.-]+)?#\d+|\b[A-Z][A-Z0-9]+-\d+\b|https?://\S+/issues/\d+`)
+)
+
+// issueTrailerKeys are the (lower-cased) keys of the trailers referencing an issue
+var issueTrailerKeys = map[string]bool{
+	"fixes": true, "fixed": true, "closes": true, "closed": true, "resolves": true, "resolved": true,
+	"refs": true, "references": true, "related": true, "related-to": true, "issue": true, "see": true,
+}
+
+// parseTrailers parses the trailers of the given commit message, following the rules of git interpret-trailers: the
+// trailers are the lines of the last paragraph of the message (which can't be its subject), provided that all of them
+// are trailers, or continuations (indented lines) of the value of the trailer before them.
+func parseTrailers(hash, message string) []commitTrailer {
+	var paragraphs = strings.Split(strings.TrimSpace(strings.ReplaceAll(message, "\r\n", "\n")), "\n\n")
+	if len(paragraphs) < 2 {
+		return nil
+	}
+
+	var trailers []commitTrailer
+	for _, line := range strings.Split(paragraphs[len(paragraphs)-1], "\n") {
+		if len(trailers) > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			var last = &trailers[len(trailers)-1]
+			last.Value += " " + strings.TrimSpace(line)
+			continue
+		}
+
+		var m = trailerLine.FindStringSubmatch(strings.TrimRight(line, " \t"))
+		if m == nil {
+			return nil
+		}
+		trailers = append(trailers, commitTrailer{CommitHash: hash, Ordinal: len(trailers), Key: strings.ToLower(m[1]), Value: m[2]})
+	}
+
+	for i := range trailers {
+		var t = &trailers[i]
+		if m := identity.FindStringSubmatch(t.Value); m != nil {
+			t.Name, t.Email = m[1], m[2]
+		}
+		if issueTrailerKeys[t.Key] {
+			t.Issue = issueRef.FindString(t.Value)
+		}
+	}
+	return trailers
+}
@@ -0,0 +1,90 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/google/go-github/v50/github"
+	"github.com/mergestat/mergestat/internal/db"
+)
+
+// unreachableMessages are (lower-cased) fragments of the errors reported by git, and the GitHub APIs, when a repo
+// doesn't exist (anymore) or access to it is denied
+var unreachableMessages = []string{
+	"repository not found",
+	"authentication failed",
+	"could not read username",
+	"does not appear to be a git repository",
+	"could not resolve to a repository",
+}
+
+// isUnreachable returns whether the given (job) error means the repo can't be reached, i.e. that it was deleted, or
+// that access to it is denied, rather than e.g. a transient network error or a bug in a handler
+func isUnreachable(err error) bool {
+	if errors.Is(err, transport.ErrRepositoryNotFound) ||
+		errors.Is(err, transport.ErrAuthenticationRequired) ||
+		errors.Is(err, transport.ErrAuthorizationFailed) {
+		return true
+	}
+
+	var ghErr *github.ErrorResponse
+	if errors.As(err, &ghErr) && ghErr.Response != nil {
+		switch ghErr.Response.StatusCode {
+		case http.StatusNotFound, http.StatusGone, http.StatusUnavailableForLegalReasons:
+			return true
+		}
+	}
+
+	// git (when cloning with the git cli) and mergestat-lite only report errors as text
+	var message = strings.ToLower(err.Error())
+	for _, m := range unreachableMessages {
+		if strings.Contains(message, m) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recordUnreachable records that a job failed with the given error if the error means its repo can't be reached (see
+// isUnreachable). Once the repo failed to be reached RepoUnreachableThreshold times in a row, it's marked as UNREACHABLE
+// and its syncs are paused, rather than its jobs being retried over and over when they'll never succeed.
+func (w *worker) recordUnreachable(j *db.DequeueSyncJobRow, jobErr error) {
+	if w.config.RepoUnreachableThreshold <= 0 || !isUnreachable(jobErr) {
+		return
+	}
+
+	quarantined, err := w.db.RecordRepoUnreachable(context.TODO(), db.RecordRepoUnreachableParams{
+		RepoID:    j.RepoID,
+		Error:     jobErr.Error(),
+		Threshold: int32(w.config.RepoUnreachableThreshold),
+	})
+	if err != nil {
+		w.logger.Err(err).Msgf("error recording repo as unreachable: %v", err)
+		return
+	} else if !quarantined {
+		return
+	}
+
+	var message = fmt.Sprintf("repo could not be reached %d time(s) in a row, marked it as unreachable and paused its syncs", w.config.RepoUnreachableThreshold)
+	w.loggerForJob(j).Warn().Msg(message)
+
+	if err := w.db.InsertSyncJobLog(context.TODO(), db.InsertSyncJobLogParams{
+		LogType:         string(SyncLogTypeWarn),
+		Message:         message,
+		RepoSyncQueueID: j.ID,
+	}); err != nil {
+		w.logger.Err(err).Msgf("error sending log warning message: %v", err)
+	}
+}
+
+// markReachable marks the repo of a job that succeeded as reachable again, resetting its count of failures to be reached
+func (w *worker) markReachable(j *db.DequeueSyncJobRow) {
+	if err := w.db.MarkRepoReachable(context.TODO(), j.RepoID); err != nil {
+		w.logger.Err(err).Msgf("error marking repo as reachable: %v", err)
+	}
+}
@@ -26,10 +26,10 @@ func New(logger *zerolog.Logger, pool *pgxpool.Pool) *timeout {
 func (s *timeout) Start(ctx context.Context, interval time.Duration) {
 	s.logger.Info().Msg("starting timeout routine")
 	exec := func() {
-		if timedOutSyncJobIDs, err := s.db.MarkSyncsAsTimedOut(ctx); err != nil {
+		if staleSyncJobIDs, err := s.db.RequeueStaleSyncJobs(ctx); err != nil {
 			s.logger.Err(err).Msg("encountered error during job timeout execution")
-		} else if len(timedOutSyncJobIDs) > 0 {
-			s.logger.Info().Msgf("timed out %d sync job(s)", len(timedOutSyncJobIDs))
+		} else if len(staleSyncJobIDs) > 0 {
+			s.logger.Info().Msgf("requeued %d stale sync job(s)", len(staleSyncJobIDs))
 		}
 	}
 	exec()
@@ -0,0 +1,123 @@
+// Package tracing sets up OpenTelemetry tracing of the worker, exporting spans over OTLP (HTTP). Tracing is only enabled
+// if an OTLP endpoint is configured, using the standard OTEL_EXPORTER_OTLP_ENDPOINT (or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT)
+// env var. Other standard env vars (such as OTEL_SERVICE_NAME or OTEL_EXPORTER_OTLP_HEADERS) are respected as well.
+package tracing
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.12.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// maxStatementLength is the length SQL statements are truncated to, when recorded on a span
+const maxStatementLength = 1024
+
+// Enabled returns whether spans are exported, i.e. whether an OTLP endpoint is configured
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup configures the global tracer provider to export spans over OTLP, if an endpoint is configured (see Enabled).
+// The returned func flushes any pending spans, and should be called before the worker exits.
+func Setup(ctx context.Context, serviceName string) (shutdown func(context.Context) error, err error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var exporter sdktrace.SpanExporter
+	if exporter, err = otlptracehttp.New(ctx); err != nil {
+		return nil, err
+	}
+
+	// OTEL_SERVICE_NAME (and OTEL_RESOURCE_ATTRIBUTES) take precedence over the given service name
+	var res *resource.Resource
+	if res, err = resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceNameKey.String(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithHost(),
+	); err != nil {
+		return nil, err
+	}
+
+	var provider = sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a span (a child of the span in ctx, if any) using the worker's tracer
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer("github.com/mergestat/mergestat").Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends the span, recording the error (if any) it ended with
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// PgxLogger records the statements (queries, COPYs and commits) pgx executes as spans, if they're executed as part of a
+// traced operation (such as a sync). Statements executed outside of a trace (e.g. polling for jobs) aren't recorded.
+// pgx v4 only reports statements once they're done, so spans are recorded after the fact, using the reported duration.
+type PgxLogger struct{}
+
+func (PgxLogger) Log(ctx context.Context, level pgx.LogLevel, msg string, data map[string]interface{}) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return
+	}
+
+	var name string
+	var attrs = []attribute.KeyValue{semconv.DBSystemPostgreSQL}
+	switch msg {
+	case "Query", "Exec":
+		var statement, _ = data["sql"].(string)
+		if name = "postgres"; len(strings.Fields(statement)) > 0 {
+			name = "postgres " + strings.ToUpper(strings.Fields(statement)[0])
+		}
+		if len(statement) > maxStatementLength {
+			statement = statement[:maxStatementLength]
+		}
+		attrs = append(attrs, semconv.DBStatementKey.String(statement))
+	case "CopyFrom":
+		var table, _ = data["tableName"].(pgx.Identifier)
+		name = "postgres COPY " + table.Sanitize()
+		attrs = append(attrs, semconv.DBSQLTableKey.String(table.Sanitize()))
+	case "SendBatch":
+		name = "postgres batch"
+	default:
+		return
+	}
+
+	if rows, ok := data["rowCount"].(int); ok {
+		attrs = append(attrs, attribute.Int("db.row_count", rows))
+	} else if rows, ok := data["rowCount"].(int64); ok {
+		attrs = append(attrs, attribute.Int64("db.row_count", rows))
+	}
+
+	var now = time.Now()
+	var duration, _ = data["time"].(time.Duration)
+	_, span := otel.Tracer("github.com/mergestat/mergestat").Start(ctx, name,
+		trace.WithTimestamp(now.Add(-duration)), trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	if err, ok := data["err"].(error); ok && level == pgx.LogLevelError {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(now))
+}
@@ -0,0 +1,70 @@
+// Package client provides a minimal client for Azure DevOps Services REST API v7.0
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// apiVersion is the version of the Azure DevOps REST API the client talks to
+const apiVersion = "7.0"
+
+// HttpClient is a shim over the default http.Client object
+type HttpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Client struct {
+	base   *url.URL
+	client HttpClient
+}
+
+// New creates a new instance of the Azure DevOps REST client.
+func New(base *url.URL, client HttpClient) *Client {
+	return &Client{base: base, client: client}
+}
+
+// NewDefaultClient creates a new instance of the Azure DevOps REST client for the hosted cloud service.
+func NewDefaultClient(client HttpClient) *Client {
+	var base, _ = url.Parse("https://dev.azure.com")
+	return New(base, client)
+}
+
+// List represents any list response from Azure DevOps API
+type List[T any] struct {
+	Count int `json:"count"`
+	Value []T `json:"value"`
+}
+
+// get performs a GET request against the given path (relative to the base url) and decodes the response into v.
+// It returns the continuation token (if any) the endpoint returned for the next page.
+func (client *Client) get(ctx context.Context, path []string, query url.Values, v interface{}) (_ string, err error) {
+	var target = client.base.JoinPath(path...)
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("api-version", apiVersion)
+	target.RawQuery = query.Encode()
+
+	var request, _ = http.NewRequest(http.MethodGet, target.String(), http.NoBody)
+	request = request.WithContext(ctx)
+
+	var response *http.Response
+	if response, err = client.client.Do(request); err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	if err = json.NewDecoder(response.Body).Decode(v); err != nil {
+		return "", err
+	}
+
+	return response.Header.Get("x-ms-continuationtoken"), nil
+}
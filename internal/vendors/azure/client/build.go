@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Build represents a single build (pipeline run) entry in Azure DevOps
+type Build struct {
+	ID            int        `json:"id"`
+	BuildNumber   string     `json:"buildNumber"`
+	Status        string     `json:"status"`
+	Result        string     `json:"result"`
+	Reason        string     `json:"reason"`
+	SourceBranch  string     `json:"sourceBranch"`
+	SourceVersion string     `json:"sourceVersion"`
+	QueueTime     *time.Time `json:"queueTime"`
+	StartTime     *time.Time `json:"startTime"`
+	FinishTime    *time.Time `json:"finishTime"`
+	RequestedFor  *Identity  `json:"requestedFor"`
+	Definition    struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	} `json:"definition"`
+	Links struct {
+		Web struct {
+			Href string `json:"href"`
+		} `json:"web"`
+	} `json:"_links"`
+}
+
+// Builds return a service that interacts with /{organization}/{project}/_apis/build/builds endpoint.
+func (client *Client) Builds() *BuildService { return &BuildService{c: client} }
+
+// BuildService represents a service that interacts with /{organization}/{project}/_apis/build/builds endpoint.
+type BuildService struct{ c *Client }
+
+type BuildListOptions struct {
+	Organization      string
+	Project           string
+	RepositoryID      string // id (not name) of the Azure Repos git repository the builds ran for
+	Top               int
+	ContinuationToken string
+}
+
+// List returns a page of Azure DevOps build items, along with the continuation token for the next page (if any)
+func (bs *BuildService) List(ctx context.Context, opts BuildListOptions) (_ *List[*Build], _ string, err error) {
+	var path = []string{opts.Organization, opts.Project, "_apis/build/builds"}
+
+	var query = url.Values{}
+	query.Set("repositoryId", opts.RepositoryID)
+	query.Set("repositoryType", "TfsGit")
+	if opts.Top > 0 {
+		query.Set("$top", strconv.Itoa(opts.Top))
+	}
+	if opts.ContinuationToken != "" {
+		query.Set("continuationToken", opts.ContinuationToken)
+	}
+
+	var result *List[*Build]
+	var next string
+	if next, err = bs.c.get(ctx, path, query, &result); err != nil {
+		return nil, "", err
+	}
+	return result, next, nil
+}
@@ -0,0 +1,79 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Identity represents a user (or group) identity in Azure DevOps
+type Identity struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	UniqueName  string `json:"uniqueName"`
+}
+
+// PullRequest represents a single pull request entry in Azure DevOps
+type PullRequest struct {
+	PullRequestID         int        `json:"pullRequestId"`
+	Title                 string     `json:"title"`
+	Description           string     `json:"description"`
+	Status                string     `json:"status"`
+	MergeStatus           string     `json:"mergeStatus"`
+	IsDraft               bool       `json:"isDraft"`
+	CreatedBy             *Identity  `json:"createdBy"`
+	ClosedBy              *Identity  `json:"closedBy"`
+	CreationDate          time.Time  `json:"creationDate"`
+	ClosedDate            *time.Time `json:"closedDate"`
+	SourceRefName         string     `json:"sourceRefName"`
+	TargetRefName         string     `json:"targetRefName"`
+	LastMergeSourceCommit *struct {
+		CommitID string `json:"commitId"`
+	} `json:"lastMergeSourceCommit"`
+	LastMergeCommit *struct {
+		CommitID string `json:"commitId"`
+	} `json:"lastMergeCommit"`
+	Reviewers []struct {
+		Identity
+		Vote       int  `json:"vote"`
+		IsRequired bool `json:"isRequired"`
+	} `json:"reviewers"`
+}
+
+// PullRequests return a service that interacts with /{organization}/{project}/_apis/git/repositories/{repository}/pullrequests endpoint.
+func (client *Client) PullRequests() *PullRequestService { return &PullRequestService{c: client} }
+
+// PullRequestService represents a service that interacts with /{organization}/{project}/_apis/git/repositories/{repository}/pullrequests endpoint.
+type PullRequestService struct{ c *Client }
+
+type PullRequestListOptions struct {
+	Organization string
+	Project      string
+	Repository   string
+	Status       string // active, abandoned, completed or all (defaults to active as per Azure DevOps API if empty)
+	Top          int
+	Skip         int
+}
+
+// List returns a page of Azure DevOps pull request items. The endpoint is paginated using Top and Skip.
+func (ps *PullRequestService) List(ctx context.Context, opts PullRequestListOptions) (_ *List[*PullRequest], err error) {
+	var path = []string{opts.Organization, opts.Project, "_apis/git/repositories", opts.Repository, "pullrequests"}
+
+	var query = url.Values{}
+	if opts.Status != "" {
+		query.Set("searchCriteria.status", opts.Status)
+	}
+	if opts.Top > 0 {
+		query.Set("$top", strconv.Itoa(opts.Top))
+	}
+	if opts.Skip > 0 {
+		query.Set("$skip", strconv.Itoa(opts.Skip))
+	}
+
+	var result *List[*PullRequest]
+	if _, err = ps.c.get(ctx, path, query, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
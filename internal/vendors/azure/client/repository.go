@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+)
+
+// Repository represents a single git repository in an Azure DevOps project
+type Repository struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	DefaultBranch string `json:"defaultBranch"`
+	RemoteURL     string `json:"remoteUrl"`
+	WebURL        string `json:"webUrl"`
+	Project       struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"project"`
+}
+
+// Repositories return a service that interacts with /{organization}/{project}/_apis/git/repositories endpoint.
+func (client *Client) Repositories() *RepositoryService { return &RepositoryService{c: client} }
+
+// RepositoryService represents a service that interacts with /{organization}/{project}/_apis/git/repositories endpoint.
+type RepositoryService struct{ c *Client }
+
+// Get returns the repository with the given name (or id) in the given organization and project
+func (rs *RepositoryService) Get(ctx context.Context, organization, project, repository string) (_ *Repository, err error) {
+	var result *Repository
+	var path = []string{organization, project, "_apis/git/repositories", repository}
+	if _, err = rs.c.get(ctx, path, nil, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,15 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/oauth2"
+)
+
+// PersonalAccessToken authenticates with an Azure DevOps personal access token (PAT) using basic auth (with an empty username)
+type PersonalAccessToken struct{ Value string }
+
+func (p *PersonalAccessToken) Token() (*oauth2.Token, error) {
+	var token = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(":%s", p.Value)))
+	return &oauth2.Token{AccessToken: token, TokenType: "basic"}, nil
+}
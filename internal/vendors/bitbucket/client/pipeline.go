@@ -0,0 +1,90 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Pipeline represents a single pipeline (run) entry in Bitbucket
+type Pipeline struct {
+	UUID        string   `json:"uuid"`
+	BuildNumber int      `json:"build_number"`
+	RunNumber   int      `json:"run_number"`
+	Creator     *Account `json:"creator"`
+	Target      struct {
+		Type    string `json:"type"`
+		RefType string `json:"ref_type"`
+		RefName string `json:"ref_name"`
+		Commit  *struct {
+			Hash string `json:"hash"`
+		} `json:"commit"`
+	} `json:"target"`
+	Trigger struct {
+		Name string `json:"name"`
+	} `json:"trigger"`
+	State struct {
+		Name   string `json:"name"`
+		Result *struct {
+			Name string `json:"name"`
+		} `json:"result"`
+	} `json:"state"`
+	CreatedOn         time.Time  `json:"created_on"`
+	CompletedOn       *time.Time `json:"completed_on"`
+	DurationInSeconds int        `json:"duration_in_seconds"`
+	BuildSecondsUsed  int        `json:"build_seconds_used"`
+}
+
+// Pipelines return a service that interacts with /2.0/repositories/{workspace}/{repo_slug}/pipelines endpoint.
+func (client *Client) Pipelines() *PipelineService { return &PipelineService{c: client} }
+
+// PipelineService represents a service that interacts with /2.0/repositories/{workspace}/{repo_slug}/pipelines endpoint.
+type PipelineService struct{ c *Client }
+
+// PipelineListOptions are the options to list pipelines with. Unlike most other endpoints,
+// the pipelines endpoint doesn't return a link to the next page and must be paginated by page number.
+type PipelineListOptions struct {
+	Workspace string
+	RepoSlug  string
+	Page      int
+	PageLen   int
+}
+
+// List returns a paginated list of Bitbucket pipeline items
+func (ps *PipelineService) List(ctx context.Context, opts PipelineListOptions) (_ *Paginated[*Pipeline], err error) {
+	// the trailing slash is required by the pipelines endpoint
+	var target = ps.c.base.JoinPath("/2.0/repositories", opts.Workspace, opts.RepoSlug, "pipelines", "/")
+
+	var query = url.Values{"sort": []string{"-created_on"}}
+	if opts.Page > 0 {
+		query.Set("page", strconv.Itoa(opts.Page))
+	}
+	if opts.PageLen > 0 {
+		query.Set("pagelen", strconv.Itoa(opts.PageLen))
+	}
+	target.RawQuery = query.Encode()
+
+	var request, _ = http.NewRequest(http.MethodGet, target.String(), http.NoBody)
+	request = request.WithContext(ctx)
+
+	var response *http.Response
+	if response, err = ps.c.client.Do(request); err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	var result *Paginated[*Pipeline]
+	if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
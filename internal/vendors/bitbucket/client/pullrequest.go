@@ -0,0 +1,97 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Account represents a user (or team) account in Bitbucket
+type Account struct {
+	DisplayName string `json:"display_name"`
+	UUID        string `json:"uuid"`
+	AccountID   string `json:"account_id"`
+	Nickname    string `json:"nickname"`
+}
+
+// PullRequestEndpoint represents the source / destination of a pull request
+type PullRequestEndpoint struct {
+	Branch struct {
+		Name string `json:"name"`
+	} `json:"branch"`
+	Commit *struct {
+		Hash string `json:"hash"`
+	} `json:"commit"`
+}
+
+// PullRequest represents a single pull request entry in Bitbucket
+type PullRequest struct {
+	ID          int                 `json:"id"`
+	Title       string              `json:"title"`
+	Description string              `json:"description"`
+	State       string              `json:"state"`
+	Author      *Account            `json:"author"`
+	Source      PullRequestEndpoint `json:"source"`
+	Destination PullRequestEndpoint `json:"destination"`
+	MergeCommit *struct {
+		Hash string `json:"hash"`
+	} `json:"merge_commit"`
+	CommentCount      int       `json:"comment_count"`
+	TaskCount         int       `json:"task_count"`
+	CloseSourceBranch bool      `json:"close_source_branch"`
+	ClosedBy          *Account  `json:"closed_by"`
+	Reason            string    `json:"reason"`
+	CreatedOn         time.Time `json:"created_on"`
+	UpdatedOn         time.Time `json:"updated_on"`
+	Links             struct {
+		HTML Link `json:"html"`
+	} `json:"links"`
+}
+
+// PullRequests return a service that interacts with /2.0/repositories/{workspace}/{repo_slug}/pullrequests endpoint.
+func (client *Client) PullRequests() *PullRequestService { return &PullRequestService{c: client} }
+
+// PullRequestService represents a service that interacts with /2.0/repositories/{workspace}/{repo_slug}/pullrequests endpoint.
+type PullRequestService struct{ c *Client }
+
+type PullRequestListOptions struct {
+	Workspace string
+	RepoSlug  string
+	State     []string // defaults to OPEN only (as per Bitbucket API) if empty
+	NextPage  string
+}
+
+// List returns a paginated list of Bitbucket pull request items
+func (ps *PullRequestService) List(ctx context.Context, opts PullRequestListOptions) (_ *Paginated[*PullRequest], err error) {
+	var target = ps.c.base.JoinPath("/2.0/repositories", opts.Workspace, opts.RepoSlug, "pullrequests")
+	if len(opts.State) > 0 {
+		target.RawQuery = url.Values{"state": opts.State}.Encode()
+	}
+
+	var next = target.String()
+	if opts.NextPage != "" {
+		next = opts.NextPage
+	}
+	var request, _ = http.NewRequest(http.MethodGet, next, http.NoBody)
+	request = request.WithContext(ctx)
+
+	var response *http.Response
+	if response, err = ps.c.client.Do(request); err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	var result *Paginated[*PullRequest]
+	if err = json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// Issue represents a single issue in Jira
+type Issue struct {
+	ID     string `json:"id"`
+	Key    string `json:"key"` // the current key of the issue, which differs from the requested one if the issue was moved
+	URL    string `json:"-"`   // of the web page of the issue
+	Fields struct {
+		Summary   string `json:"summary"`
+		IssueType *struct {
+			Name string `json:"name"`
+		} `json:"issuetype"`
+		Status *struct {
+			Name           string `json:"name"`
+			StatusCategory *struct {
+				Key string `json:"key"` // new, indeterminate or done
+			} `json:"statusCategory"`
+		} `json:"status"`
+		Priority *struct {
+			Name string `json:"name"`
+		} `json:"priority"`
+		Assignee *struct {
+			DisplayName string `json:"displayName"`
+		} `json:"assignee"`
+		Project *struct {
+			Key string `json:"key"`
+		} `json:"project"`
+		Created        *Time `json:"created"`
+		Updated        *Time `json:"updated"`
+		ResolutionDate *Time `json:"resolutiondate"`
+	} `json:"fields"`
+	// CustomFields holds the raw value of the custom fields requested, by id (e.g. customfield_10016)
+	CustomFields map[string]json.RawMessage `json:"-"`
+}
+
+// Issues return a service that interacts with /rest/api/2/issue endpoint.
+func (client *Client) Issues() *IssueService { return &IssueService{c: client} }
+
+// IssueService represents a service that interacts with /rest/api/2/issue endpoint.
+type IssueService struct{ c *Client }
+
+// issueFields are the (system) fields of an issue the service requests
+var issueFields = []string{"summary", "issuetype", "status", "priority", "assignee", "project", "created", "updated", "resolutiondate"}
+
+// Get returns the issue with the given key (or id), along with the given custom fields. It returns ErrNotFound if
+// there's no such issue.
+func (is *IssueService) Get(ctx context.Context, key string, customFields ...string) (_ *Issue, err error) {
+	var query = url.Values{"fields": {strings.Join(append(append([]string{}, issueFields...), customFields...), ",")}}
+
+	var raw struct {
+		ID     string          `json:"id"`
+		Key    string          `json:"key"`
+		Fields json.RawMessage `json:"fields"`
+	}
+	if err = is.c.get(ctx, []string{"rest/api/2/issue", key}, query, &raw); err != nil {
+		return nil, err
+	}
+
+	var issue = &Issue{ID: raw.ID, Key: raw.Key, URL: is.c.browseURL(raw.Key)}
+	if err = json.Unmarshal(raw.Fields, &issue.Fields); err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(raw.Fields, &fields); err != nil {
+		return nil, err
+	}
+	issue.CustomFields = make(map[string]json.RawMessage, len(customFields))
+	for _, f := range customFields {
+		if v, ok := fields[f]; ok {
+			issue.CustomFields[f] = v
+		}
+	}
+
+	return issue, nil
+}
@@ -0,0 +1,73 @@
+// Package client provides a minimal client for the Jira REST API v2, of Jira Cloud and Jira Data Center alike
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrNotFound is returned for the resources that don't exist (or that the user isn't allowed to see)
+var ErrNotFound = errors.New("not found")
+
+// HttpClient is a shim over the default http.Client object
+type HttpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Client struct {
+	base   *url.URL
+	client HttpClient
+}
+
+// New creates a new instance of the Jira REST client of the instance at the given base url, e.g. https://acme.atlassian.net
+func New(base *url.URL, client HttpClient) *Client {
+	return &Client{base: base, client: client}
+}
+
+// Time is a timestamp as formatted by Jira, e.g. 2023-01-02T15:04:05.000+0000 (which isn't RFC 3339)
+type Time struct{ time.Time }
+
+func (t *Time) UnmarshalJSON(data []byte) (err error) {
+	var s string
+	if err = json.Unmarshal(data, &s); err != nil || s == "" {
+		return err
+	}
+	t.Time, err = time.Parse("2006-01-02T15:04:05.000-0700", s)
+	return err
+}
+
+// get performs a GET request against the given path (relative to the base url) and decodes the response into v
+func (client *Client) get(ctx context.Context, path []string, query url.Values, v interface{}) (err error) {
+	var target = client.base.JoinPath(path...)
+	target.RawQuery = query.Encode()
+
+	var request, _ = http.NewRequest(http.MethodGet, target.String(), http.NoBody)
+	request = request.WithContext(ctx)
+	request.Header.Set("Accept", "application/json")
+
+	var response *http.Response
+	if response, err = client.client.Do(request); err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return fmt.Errorf("unexpected status code: %d", response.StatusCode)
+	}
+
+	return json.NewDecoder(response.Body).Decode(v)
+}
+
+// browseURL returns the url of the web page of the issue with the given key
+func (client *Client) browseURL(key string) string {
+	return client.base.JoinPath("browse", key).String()
+}
@@ -0,0 +1,23 @@
+package client
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/oauth2"
+)
+
+// APIToken authenticates with the API token of an Atlassian account (of Jira Cloud) using basic auth with its email
+type APIToken struct{ Email, Value string }
+
+func (p *APIToken) Token() (*oauth2.Token, error) {
+	var token = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%s", p.Email, p.Value)))
+	return &oauth2.Token{AccessToken: token, TokenType: "basic"}, nil
+}
+
+// PersonalAccessToken authenticates with a personal access token (of Jira Data Center) as a bearer token
+type PersonalAccessToken struct{ Value string }
+
+func (p *PersonalAccessToken) Token() (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: p.Value, TokenType: "Bearer"}, nil
+}
@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"net/url"
-	"os"
 
 	"github.com/google/go-github/v50/github"
 	"github.com/google/uuid"
@@ -50,10 +49,7 @@ func (w *warehouse) handleWorkflows(ctx context.Context, owner, repo string, job
 	var resp *github.Response
 	var workflowsPage *github.Workflows
 	repoID := job.RepoID
-	pagination, err := w.getPaginationOpt("GITHUB_WORKFLOW_PER_PAGE")
-	if err != nil {
-		return err
-	}
+	pagination := perPage(w.options.WorkflowsPerPage)
 	opt := &github.ListWorkflowRunsOptions{
 		ListOptions: github.ListOptions{PerPage: pagination},
 	}
@@ -115,10 +111,7 @@ func (w *warehouse) handleWorkflowRuns(ctx context.Context, owner, repo string,
 	repoID := job.RepoID
 	runsCount := 0
 	jobsCount := 0
-	pagination, err := w.getPaginationOpt("GITHUB_WORKFLOW_RUNS_PER_PAGE")
-	if err != nil {
-		return err
-	}
+	pagination := perPage(w.options.WorkflowRunsPerPage)
 	opt := &github.ListWorkflowRunsOptions{
 		ListOptions: github.ListOptions{PerPage: pagination},
 	}
@@ -218,10 +211,7 @@ func (w *warehouse) handleWorkflowRunsJobs(ctx context.Context, owner, repo stri
 	var resp *github.Response
 	var workflowRunJobsPage *github.Jobs
 	repoID := job.RepoID
-	pagination, err := w.getPaginationOpt("GITHUB_WORKFLOW_JOBS_PER_PAGE")
-	if err != nil {
-		return err
-	}
+	pagination := perPage(w.options.WorkflowJobsPerPage)
 
 	opt := &github.ListWorkflowJobsOptions{
 		ListOptions: github.ListOptions{PerPage: pagination},
@@ -290,7 +280,7 @@ func (w *warehouse) handleWorkflowJobLogs(ctx context.Context, owner, repo strin
 	var err error
 	var log string
 	// we create a  tmp dir to store all downloaded files into it
-	filepath, cleanup, err := helper.CreateTempDir(os.Getenv("GIT_WORKFLOW_LOGS_PATH"), fmt.Sprintf("mergestat-repo-%s-*", repoID.String()))
+	filepath, cleanup, err := helper.CreateTempDir(w.options.LogsPath, fmt.Sprintf("mergestat-repo-%s-*", repoID.String()))
 	if err != nil {
 		return err
 	}
@@ -1,11 +1,9 @@
 package warehouse
 
 import (
-	"context"
 	"fmt"
 	"net/url"
 	"os"
-	"strconv"
 
 	"github.com/cavaliergopher/grab/v3"
 	"github.com/google/go-github/v50/github"
@@ -14,7 +12,6 @@ import (
 	"github.com/mergestat/mergestat/internal/pool"
 	"github.com/mergestat/mergestat/queries"
 	"github.com/rs/zerolog"
-	"golang.org/x/oauth2"
 )
 
 type warehouse struct {
@@ -22,19 +19,20 @@ type warehouse struct {
 	logger       *zerolog.Logger
 	pool         pool.Pooler
 	db           queries.Querier
+	options      Options
 }
 
-func New(ctx context.Context, db *db.Queries, pgpool *pgxpool.Pool, logger *zerolog.Logger, ghToken string) *warehouse {
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: ghToken},
-	)
-
-	tc := oauth2.NewClient(ctx, ts)
-	if len(ghToken) <= 0 {
-		tc = nil
-	}
+// Options are the settings of a warehouse. Page sizes that aren't set default to 30.
+type Options struct {
+	WorkflowsPerPage    int
+	WorkflowRunsPerPage int
+	WorkflowJobsPerPage int
+	LogsPath            string // where the logs of workflow jobs are downloaded to (the OS temp dir if empty)
+}
 
-	client := github.NewClient(tc)
+// New returns a warehouse that uses the given client to talk to the GitHub API
+// (which may point at github.com or at a GitHub Enterprise Server installation).
+func New(db *db.Queries, pgpool *pgxpool.Pool, logger *zerolog.Logger, client *github.Client, options Options) *warehouse {
 	pool := pool.Init(pgpool)
 	queries := queries.NewQuerier(db)
 
@@ -43,6 +41,7 @@ func New(ctx context.Context, db *db.Queries, pgpool *pgxpool.Pool, logger *zero
 		logger:       logger,
 		pool:         pool,
 		db:           queries,
+		options:      options,
 	}
 }
 
@@ -63,20 +62,10 @@ func (w *warehouse) parseJobLogs(logsUrl *url.URL, filepathDir string, n int) (s
 	return string(bytes), nil
 }
 
-// getPaginationOpt get the pagination env values for each workflow,runs and jobs
-// if these are not provided  will default to 30
-func (w *warehouse) getPaginationOpt(pagination string) (int, error) {
-	var paginationEnv string
-
-	if paginationEnv = os.Getenv(pagination); len(paginationEnv) <= 0 {
-		return 30, nil
+// perPage returns the given page size for each workflow, runs and jobs, defaulting to 30 if not set
+func perPage(n int) int {
+	if n <= 0 {
+		return 30
 	}
-
-	paginationOpt, err := strconv.Atoi(paginationEnv)
-
-	if err != nil {
-		return 0, err
-	}
-
-	return paginationOpt, nil
+	return n
 }
@@ -0,0 +1,140 @@
+// Package webhook receives the webhooks of GitHub, enqueuing the syncs of the repo a webhook reports a change to (e.g. a
+// push) right away, so that its data is kept up to date in near real time rather than only on the schedule of its syncs.
+package webhook
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/go-github/v50/github"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/mergestat/mergestat/internal/db"
+	"github.com/rs/zerolog"
+)
+
+// maxPayloadBytes is the size of the largest payload GitHub delivers, larger payloads are dropped by GitHub itself
+const maxPayloadBytes = 25 << 20
+
+// syncTypesByEvent are the sync types enqueued for the repo a webhook event is delivered for, by event type
+var syncTypesByEvent = map[string][]string{
+	"push":                   {"GIT_COMMITS", "GIT_COMMIT_STATS", "GIT_REFS", "GIT_FILES", "GIT_BLAME", "GIT_SIGNATURES", "GIT_FILES_LANGUAGES", "GIT_LICENSES", "GIT_DEPENDENCIES", "GIT_CODEOWNERS", "GIT_SUBMODULES", "GIT_LFS_OBJECTS", "GIT_TREE"},
+	"pull_request":           {"GITHUB_REPO_PRS", "GITHUB_PR_REVIEWS", "GITHUB_PR_COMMITS", "GITHUB_PRS_AND_COMMITS"},
+	"release":                {"GITHUB_REPO_RELEASES", "GIT_REFS"},
+	"issue_comment":          {"GITHUB_ISSUE_COMMENTS", "GITHUB_PR_COMMENTS"},
+	"check_suite":            {"GITHUB_CHECKS"},
+	"status":                 {"GITHUB_CHECKS"},
+	"deployment_status":      {"GITHUB_DEPLOYMENTS"},
+	"branch_protection_rule": {"GITHUB_BRANCH_PROTECTIONS"},
+	"member":                 {"GITHUB_REPO_COLLABORATORS"},
+	"team_add":               {"GITHUB_REPO_COLLABORATORS", "GITHUB_ORG_MEMBERS"},
+	"label":                  {"GITHUB_LABELS_AND_MILESTONES"},
+	"milestone":              {"GITHUB_LABELS_AND_MILESTONES"},
+	"discussion":             {"GITHUB_DISCUSSIONS"},
+	"package":                {"GITHUB_PACKAGES"},
+}
+
+type receiver struct {
+	logger *zerolog.Logger
+	db     *db.Queries
+	secret []byte
+}
+
+// GitHub returns a handler receiving the webhooks of GitHub (of a repo, an org or a GitHub App), whose signature is
+// verified against the given secret. The events of syncTypesByEvent (e.g. push) enqueue the syncs of the repo they're
+// delivered for, other events are acknowledged and ignored.
+func GitHub(logger *zerolog.Logger, pool *pgxpool.Pool, secret []byte) http.Handler {
+	return &receiver{logger: logger, db: db.New(pool), secret: secret}
+}
+
+func (rcv *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxPayloadBytes)
+	payload, err := github.ValidatePayload(r, rcv.secret)
+	if err != nil {
+		rcv.logger.Warn().AnErr("error", err).Str("delivery", github.DeliveryID(r)).Msg("rejected webhook with invalid payload or signature")
+		http.Error(w, "invalid payload or signature", http.StatusUnauthorized)
+		return
+	}
+
+	var eventType = github.WebHookType(r)
+	syncTypes, ok := syncTypesByEvent[eventType]
+	if !ok {
+		respond(w, nil) // e.g. the ping sent when a webhook is created
+		return
+	}
+
+	event, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		http.Error(w, "invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	var repoURL = repoURLOf(event)
+	if repoURL == "" {
+		respond(w, nil)
+		return
+	}
+
+	// repos are matched by url, the syncs of a repo that isn't tracked (or that has none of the types enabled) aren't enqueued
+	enqueued, err := rcv.db.EnqueueRepoSyncs(r.Context(), db.EnqueueRepoSyncsParams{RepoUrl: repoURL, SyncTypes: syncTypes})
+	if err != nil {
+		rcv.logger.Err(err).Str("delivery", github.DeliveryID(r)).Msgf("error enqueuing syncs of webhook: %v", err)
+		http.Error(w, "could not enqueue syncs", http.StatusInternalServerError)
+		return
+	}
+
+	rcv.logger.Info().Str("event", eventType).Str("repo", repoURL).Strs("syncs", enqueued).
+		Str("delivery", github.DeliveryID(r)).Msgf("received webhook, enqueued %d sync(s)", len(enqueued))
+	respond(w, enqueued)
+}
+
+// repoURLOf returns the url of the repo the given event is delivered for, or an empty string if it has none
+func repoURLOf(event interface{}) string {
+	switch e := event.(type) {
+	case *github.PushEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.PullRequestEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.ReleaseEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.IssueCommentEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.CheckSuiteEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.StatusEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.DeploymentStatusEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.BranchProtectionRuleEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.MemberEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.TeamAddEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.LabelEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.MilestoneEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.DiscussionEvent:
+		return e.GetRepo().GetHTMLURL()
+	case *github.PackageEvent:
+		return e.GetRepo().GetHTMLURL()
+	default:
+		return ""
+	}
+}
+
+// respond acknowledges a webhook, listing the sync types it enqueued (none if the repo isn't tracked, or it was ignored)
+func respond(w http.ResponseWriter, enqueued []string) {
+	if enqueued == nil {
+		enqueued = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(map[string][]string{"enqueued": enqueued})
+}
@@ -0,0 +1,13 @@
+BEGIN;
+
+-- github_issues may have been dropped by 900000000000059_remove_empty_tables if it was never synced
+DO $$
+BEGIN
+    IF to_regclass('public.github_issues') IS NOT NULL THEN
+        ALTER TABLE public.github_issues ADD COLUMN IF NOT EXISTS assignees JSONB NOT NULL DEFAULT '[]'::JSONB;
+        COMMENT ON COLUMN public.github_issues.assignees IS 'JSON array of the logins of users assigned to the issue';
+    END IF;
+END
+$$;
+
+COMMIT;
@@ -0,0 +1,36 @@
+BEGIN;
+
+-- github_pull_requests may have been dropped by 900000000000059_remove_empty_tables if it was never synced
+DO $$
+BEGIN
+    IF to_regclass('public.github_pull_requests') IS NOT NULL THEN
+        CREATE OR REPLACE VIEW github_pull_request_cycle_times AS
+        SELECT
+            github_pull_requests.repo_id,
+            github_pull_requests.number,
+            github_pull_requests.author_login,
+            github_pull_requests.base_ref_name,
+            github_pull_requests.head_ref_name,
+            github_pull_requests.state,
+            github_pull_requests.merged,
+            github_pull_requests.merged_by,
+            github_pull_requests.review_decision,
+            github_pull_requests.additions,
+            github_pull_requests.deletions,
+            github_pull_requests.created_at,
+            github_pull_requests.merged_at,
+            github_pull_requests.closed_at,
+            github_pull_requests.merged_at - github_pull_requests.created_at AS time_to_merge,
+            COALESCE(github_pull_requests.merged_at, github_pull_requests.closed_at) - github_pull_requests.created_at AS time_to_close
+        FROM github_pull_requests;
+
+        COMMENT ON VIEW github_pull_request_cycle_times IS 'view of the cycle time of GitHub pull requests';
+        COMMENT ON COLUMN github_pull_request_cycle_times.repo_id IS 'foreign key for public.repos.id';
+        COMMENT ON COLUMN github_pull_request_cycle_times.number IS 'GitHub number of the pull request';
+        COMMENT ON COLUMN github_pull_request_cycle_times.time_to_merge IS 'interval between the creation and the merge of the pull request, NULL if it was not merged';
+        COMMENT ON COLUMN github_pull_request_cycle_times.time_to_close IS 'interval between the creation and the merge or close of the pull request, NULL if it is still open';
+    END IF;
+END
+$$;
+
+COMMIT;
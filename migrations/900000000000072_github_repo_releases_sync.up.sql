@@ -0,0 +1,78 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_REPO_RELEASES', 'Retrieves all the releases (and their assets) of a GitHub repo', 'GitHub Releases', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_REPO_RELEASES')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS github_releases (
+    repo_id uuid NOT NULL REFERENCES repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id bigint NOT NULL,
+    tag_name text,
+    target_commitish text,
+    name text,
+    body text,
+    draft boolean,
+    prerelease boolean,
+    author_login text,
+    created_at timestamp with time zone,
+    published_at timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_releases_pkey PRIMARY KEY (repo_id, id)
+);
+
+COMMENT ON TABLE github_releases IS 'releases of a GitHub repo';
+COMMENT ON COLUMN github_releases.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN github_releases.id IS 'GitHub id of the release';
+COMMENT ON COLUMN github_releases.tag_name IS 'name of the git tag the release is based on';
+COMMENT ON COLUMN github_releases.target_commitish IS 'commitish value (branch or commit SHA) the tag was created from';
+COMMENT ON COLUMN github_releases.name IS 'name of the release';
+COMMENT ON COLUMN github_releases.body IS 'description of the release';
+COMMENT ON COLUMN github_releases.draft IS 'boolean to determine if the release is a draft';
+COMMENT ON COLUMN github_releases.prerelease IS 'boolean to determine if the release is a prerelease';
+COMMENT ON COLUMN github_releases.author_login IS 'login of the author of the release';
+COMMENT ON COLUMN github_releases.created_at IS 'timestamp of when the release was created';
+COMMENT ON COLUMN github_releases.published_at IS 'timestamp of when the release was published';
+COMMENT ON COLUMN github_releases.url IS 'URL of the release';
+COMMENT ON COLUMN github_releases._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS github_release_assets (
+    repo_id uuid NOT NULL,
+    release_id bigint NOT NULL,
+    id bigint NOT NULL,
+    name text,
+    label text,
+    state text,
+    content_type text,
+    size integer,
+    download_count integer,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    browser_download_url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_release_assets_pkey PRIMARY KEY (repo_id, id),
+    CONSTRAINT github_release_assets_release_fkey FOREIGN KEY (repo_id, release_id) REFERENCES github_releases(repo_id, id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+COMMENT ON TABLE github_release_assets IS 'assets of the releases of a GitHub repo';
+COMMENT ON COLUMN github_release_assets.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN github_release_assets.release_id IS 'GitHub id of the release the asset belongs to';
+COMMENT ON COLUMN github_release_assets.id IS 'GitHub id of the asset';
+COMMENT ON COLUMN github_release_assets.name IS 'file name of the asset';
+COMMENT ON COLUMN github_release_assets.label IS 'label of the asset';
+COMMENT ON COLUMN github_release_assets.state IS 'state of the asset';
+COMMENT ON COLUMN github_release_assets.content_type IS 'content type of the asset';
+COMMENT ON COLUMN github_release_assets.size IS 'size of the asset in bytes';
+COMMENT ON COLUMN github_release_assets.download_count IS 'number of times the asset was downloaded';
+COMMENT ON COLUMN github_release_assets.created_at IS 'timestamp of when the asset was created';
+COMMENT ON COLUMN github_release_assets.updated_at IS 'timestamp of when the asset was last updated';
+COMMENT ON COLUMN github_release_assets.browser_download_url IS 'URL to download the asset';
+COMMENT ON COLUMN github_release_assets._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE INDEX IF NOT EXISTS idx_github_release_assets_release_fkey ON github_release_assets(repo_id, release_id);
+
+COMMIT;
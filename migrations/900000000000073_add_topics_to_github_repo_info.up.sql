@@ -0,0 +1,13 @@
+BEGIN;
+
+-- github_repo_info may have been dropped by 900000000000059_remove_empty_tables if it was never synced
+DO $$
+BEGIN
+    IF to_regclass('public.github_repo_info') IS NOT NULL THEN
+        ALTER TABLE public.github_repo_info ADD COLUMN IF NOT EXISTS topics JSONB NOT NULL DEFAULT '[]'::JSONB;
+        COMMENT ON COLUMN public.github_repo_info.topics IS 'JSON array of the topics of the repo';
+    END IF;
+END
+$$;
+
+COMMIT;
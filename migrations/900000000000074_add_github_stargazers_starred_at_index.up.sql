@@ -0,0 +1,13 @@
+BEGIN;
+
+-- github_stargazers may have been dropped by 900000000000059_remove_empty_tables if it was never synced
+DO $$
+BEGIN
+    IF to_regclass('public.github_stargazers') IS NOT NULL THEN
+        -- used to resume a stargazers sync from the most recently synced star
+        CREATE INDEX IF NOT EXISTS idx_github_stargazers_repo_id_starred_at ON public.github_stargazers(repo_id, starred_at);
+    END IF;
+END
+$$;
+
+COMMIT;
@@ -0,0 +1,61 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_DEPENDABOT_ALERTS', 'Retrieves all the Dependabot (vulnerability) alerts of a GitHub repo', 'GitHub Dependabot Alerts', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_DEPENDABOT_ALERTS')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS github_dependabot_alerts (
+    repo_id uuid NOT NULL REFERENCES repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    number integer NOT NULL,
+    state text,
+    package_ecosystem text,
+    package_name text,
+    manifest_path text,
+    scope text,
+    severity text,
+    ghsa_id text,
+    cve_id text,
+    summary text,
+    vulnerable_version_range text,
+    first_patched_version text,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    dismissed_at timestamp with time zone,
+    dismissed_by text,
+    dismissed_reason text,
+    dismissed_comment text,
+    fixed_at timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_dependabot_alerts_pkey PRIMARY KEY (repo_id, number)
+);
+
+COMMENT ON TABLE github_dependabot_alerts IS 'Dependabot alerts of a GitHub repo';
+COMMENT ON COLUMN github_dependabot_alerts.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN github_dependabot_alerts.number IS 'number of the alert in the repo';
+COMMENT ON COLUMN github_dependabot_alerts.state IS 'state of the alert (open, dismissed, fixed or auto_dismissed)';
+COMMENT ON COLUMN github_dependabot_alerts.package_ecosystem IS 'ecosystem of the vulnerable package (e.g. npm, pip, gomod)';
+COMMENT ON COLUMN github_dependabot_alerts.package_name IS 'name of the vulnerable package';
+COMMENT ON COLUMN github_dependabot_alerts.manifest_path IS 'path to the manifest file the vulnerable package is declared in';
+COMMENT ON COLUMN github_dependabot_alerts.scope IS 'scope of the vulnerable dependency (development or runtime)';
+COMMENT ON COLUMN github_dependabot_alerts.severity IS 'severity of the security advisory';
+COMMENT ON COLUMN github_dependabot_alerts.ghsa_id IS 'GitHub Security Advisory id';
+COMMENT ON COLUMN github_dependabot_alerts.cve_id IS 'CVE id of the security advisory';
+COMMENT ON COLUMN github_dependabot_alerts.summary IS 'short summary of the security advisory';
+COMMENT ON COLUMN github_dependabot_alerts.vulnerable_version_range IS 'range of the package versions that are vulnerable';
+COMMENT ON COLUMN github_dependabot_alerts.first_patched_version IS 'first version of the package that is not vulnerable';
+COMMENT ON COLUMN github_dependabot_alerts.created_at IS 'timestamp of when the alert was created';
+COMMENT ON COLUMN github_dependabot_alerts.updated_at IS 'timestamp of when the alert was last updated';
+COMMENT ON COLUMN github_dependabot_alerts.dismissed_at IS 'timestamp of when the alert was dismissed';
+COMMENT ON COLUMN github_dependabot_alerts.dismissed_by IS 'login of the user who dismissed the alert';
+COMMENT ON COLUMN github_dependabot_alerts.dismissed_reason IS 'reason the alert was dismissed';
+COMMENT ON COLUMN github_dependabot_alerts.dismissed_comment IS 'comment left when the alert was dismissed';
+COMMENT ON COLUMN github_dependabot_alerts.fixed_at IS 'timestamp of when the alert was fixed';
+COMMENT ON COLUMN github_dependabot_alerts.url IS 'URL of the alert';
+COMMENT ON COLUMN github_dependabot_alerts._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+COMMIT;
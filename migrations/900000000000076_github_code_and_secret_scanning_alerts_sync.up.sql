@@ -0,0 +1,99 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_CODE_SCANNING_ALERTS', 'Retrieves all the code scanning alerts of a GitHub repo', 'GitHub Code Scanning Alerts', 2, 'GITHUB'),
+       ('GITHUB_SECRET_SCANNING_ALERTS', 'Retrieves all the secret scanning alerts (and their locations) of a GitHub repo', 'GitHub Secret Scanning Alerts', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_CODE_SCANNING_ALERTS'),
+       ('github', 'GITHUB_SECRET_SCANNING_ALERTS')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS github_code_scanning_alerts (
+    repo_id uuid NOT NULL REFERENCES repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    number integer NOT NULL,
+    state text,
+    rule_id text,
+    rule_name text,
+    rule_description text,
+    rule_severity text,
+    security_severity_level text,
+    tool_name text,
+    tool_version text,
+    ref text,
+    commit_sha text,
+    path text,
+    start_line integer,
+    end_line integer,
+    start_column integer,
+    end_column integer,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    fixed_at timestamp with time zone,
+    dismissed_at timestamp with time zone,
+    dismissed_by text,
+    dismissed_reason text,
+    dismissed_comment text,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_code_scanning_alerts_pkey PRIMARY KEY (repo_id, number)
+);
+
+COMMENT ON TABLE github_code_scanning_alerts IS 'code scanning alerts of a GitHub repo';
+COMMENT ON COLUMN github_code_scanning_alerts.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN github_code_scanning_alerts.number IS 'number of the alert in the repo';
+COMMENT ON COLUMN github_code_scanning_alerts.state IS 'state of the alert (open, dismissed or fixed)';
+COMMENT ON COLUMN github_code_scanning_alerts.rule_id IS 'id of the rule that triggered the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.rule_name IS 'name of the rule that triggered the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.rule_description IS 'description of the rule that triggered the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.rule_severity IS 'severity of the rule (none, note, warning or error)';
+COMMENT ON COLUMN github_code_scanning_alerts.security_severity_level IS 'security severity of the rule (low, medium, high or critical)';
+COMMENT ON COLUMN github_code_scanning_alerts.tool_name IS 'name of the tool that detected the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.tool_version IS 'version of the tool that detected the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.ref IS 'git ref of the most recent instance of the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.commit_sha IS 'commit SHA of the most recent instance of the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.path IS 'path of the file of the most recent instance of the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.start_line IS 'start line of the most recent instance of the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.end_line IS 'end line of the most recent instance of the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.start_column IS 'start column of the most recent instance of the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.end_column IS 'end column of the most recent instance of the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.created_at IS 'timestamp of when the alert was created';
+COMMENT ON COLUMN github_code_scanning_alerts.updated_at IS 'timestamp of when the alert was last updated';
+COMMENT ON COLUMN github_code_scanning_alerts.fixed_at IS 'timestamp of when the alert was fixed';
+COMMENT ON COLUMN github_code_scanning_alerts.dismissed_at IS 'timestamp of when the alert was dismissed';
+COMMENT ON COLUMN github_code_scanning_alerts.dismissed_by IS 'login of the user who dismissed the alert';
+COMMENT ON COLUMN github_code_scanning_alerts.dismissed_reason IS 'reason the alert was dismissed';
+COMMENT ON COLUMN github_code_scanning_alerts.dismissed_comment IS 'comment left when the alert was dismissed';
+COMMENT ON COLUMN github_code_scanning_alerts.url IS 'URL of the alert';
+COMMENT ON COLUMN github_code_scanning_alerts._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS github_secret_scanning_alerts (
+    repo_id uuid NOT NULL REFERENCES repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    number integer NOT NULL,
+    state text,
+    secret_type text,
+    resolution text,
+    resolved_at timestamp with time zone,
+    resolved_by text,
+    created_at timestamp with time zone,
+    locations jsonb NOT NULL DEFAULT '[]'::jsonb,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_secret_scanning_alerts_pkey PRIMARY KEY (repo_id, number)
+);
+
+COMMENT ON TABLE github_secret_scanning_alerts IS 'secret scanning alerts of a GitHub repo (the secrets themselves are not stored)';
+COMMENT ON COLUMN github_secret_scanning_alerts.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN github_secret_scanning_alerts.number IS 'number of the alert in the repo';
+COMMENT ON COLUMN github_secret_scanning_alerts.state IS 'state of the alert (open or resolved)';
+COMMENT ON COLUMN github_secret_scanning_alerts.secret_type IS 'type of the detected secret';
+COMMENT ON COLUMN github_secret_scanning_alerts.resolution IS 'reason the alert was resolved';
+COMMENT ON COLUMN github_secret_scanning_alerts.resolved_at IS 'timestamp of when the alert was resolved';
+COMMENT ON COLUMN github_secret_scanning_alerts.resolved_by IS 'login of the user who resolved the alert';
+COMMENT ON COLUMN github_secret_scanning_alerts.created_at IS 'timestamp of when the alert was created';
+COMMENT ON COLUMN github_secret_scanning_alerts.locations IS 'JSON array of the locations (path, lines, columns, blob and commit) the secret was found at';
+COMMENT ON COLUMN github_secret_scanning_alerts.url IS 'URL of the alert';
+COMMENT ON COLUMN github_secret_scanning_alerts._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+COMMIT;
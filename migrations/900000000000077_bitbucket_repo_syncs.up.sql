@@ -0,0 +1,108 @@
+BEGIN;
+
+-- Bitbucket API requests are rate limited per user, so run Bitbucket syncs one at a time (like GitHub ones)
+INSERT INTO mergestat.repo_sync_type_groups ("group", concurrent_syncs)
+VALUES ('BITBUCKET', 1)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_labels (label, color)
+VALUES ('bitbucket', '#2684ff')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('BITBUCKET_REPO_PRS', 'Retrieves all the pull requests of a Bitbucket repo', 'Bitbucket Pull Requests', 2, 'BITBUCKET'),
+       ('BITBUCKET_REPO_PIPELINES', 'Retrieves all the pipelines of a Bitbucket repo', 'Bitbucket Pipelines', 2, 'BITBUCKET')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('bitbucket', 'BITBUCKET_REPO_PRS'),
+       ('bitbucket', 'BITBUCKET_REPO_PIPELINES')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS bitbucket_pull_requests (
+    repo_id uuid NOT NULL REFERENCES repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id integer NOT NULL,
+    title text,
+    description text,
+    state text,
+    author_display_name text,
+    author_uuid text,
+    source_branch text,
+    source_commit text,
+    destination_branch text,
+    destination_commit text,
+    merge_commit text,
+    comment_count integer,
+    task_count integer,
+    close_source_branch boolean,
+    closed_by_display_name text,
+    reason text,
+    created_on timestamp with time zone,
+    updated_on timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT bitbucket_pull_requests_pkey PRIMARY KEY (repo_id, id)
+);
+
+COMMENT ON TABLE bitbucket_pull_requests IS 'pull requests of a Bitbucket repo';
+COMMENT ON COLUMN bitbucket_pull_requests.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN bitbucket_pull_requests.id IS 'id of the pull request in the repo';
+COMMENT ON COLUMN bitbucket_pull_requests.title IS 'title of the pull request';
+COMMENT ON COLUMN bitbucket_pull_requests.description IS 'description of the pull request';
+COMMENT ON COLUMN bitbucket_pull_requests.state IS 'state of the pull request (OPEN, MERGED, DECLINED or SUPERSEDED)';
+COMMENT ON COLUMN bitbucket_pull_requests.author_display_name IS 'display name of the author of the pull request';
+COMMENT ON COLUMN bitbucket_pull_requests.author_uuid IS 'Bitbucket uuid of the author of the pull request';
+COMMENT ON COLUMN bitbucket_pull_requests.source_branch IS 'name of the branch the changes come from';
+COMMENT ON COLUMN bitbucket_pull_requests.source_commit IS 'hash of the head commit of the source branch';
+COMMENT ON COLUMN bitbucket_pull_requests.destination_branch IS 'name of the branch the changes are merged into';
+COMMENT ON COLUMN bitbucket_pull_requests.destination_commit IS 'hash of the commit of the destination branch';
+COMMENT ON COLUMN bitbucket_pull_requests.merge_commit IS 'hash of the merge commit (if merged)';
+COMMENT ON COLUMN bitbucket_pull_requests.comment_count IS 'number of comments on the pull request';
+COMMENT ON COLUMN bitbucket_pull_requests.task_count IS 'number of tasks on the pull request';
+COMMENT ON COLUMN bitbucket_pull_requests.close_source_branch IS 'boolean to determine if the source branch is closed when merged';
+COMMENT ON COLUMN bitbucket_pull_requests.closed_by_display_name IS 'display name of the user who closed the pull request';
+COMMENT ON COLUMN bitbucket_pull_requests.reason IS 'reason the pull request was declined';
+COMMENT ON COLUMN bitbucket_pull_requests.created_on IS 'timestamp of when the pull request was created';
+COMMENT ON COLUMN bitbucket_pull_requests.updated_on IS 'timestamp of when the pull request was last updated';
+COMMENT ON COLUMN bitbucket_pull_requests.url IS 'URL of the pull request';
+COMMENT ON COLUMN bitbucket_pull_requests._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS bitbucket_pipelines (
+    repo_id uuid NOT NULL REFERENCES repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    uuid text NOT NULL,
+    build_number integer,
+    run_number integer,
+    creator_display_name text,
+    trigger text,
+    state text,
+    result text,
+    target_ref_type text,
+    target_ref_name text,
+    target_commit text,
+    created_on timestamp with time zone,
+    completed_on timestamp with time zone,
+    duration_in_seconds integer,
+    build_seconds_used integer,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT bitbucket_pipelines_pkey PRIMARY KEY (repo_id, uuid)
+);
+
+COMMENT ON TABLE bitbucket_pipelines IS 'pipelines (CI runs) of a Bitbucket repo';
+COMMENT ON COLUMN bitbucket_pipelines.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN bitbucket_pipelines.uuid IS 'Bitbucket uuid of the pipeline';
+COMMENT ON COLUMN bitbucket_pipelines.build_number IS 'build number of the pipeline in the repo';
+COMMENT ON COLUMN bitbucket_pipelines.run_number IS 'run number of the pipeline (incremented on reruns)';
+COMMENT ON COLUMN bitbucket_pipelines.creator_display_name IS 'display name of the user who created the pipeline';
+COMMENT ON COLUMN bitbucket_pipelines.trigger IS 'what triggered the pipeline (e.g. PUSH, MANUAL, SCHEDULE)';
+COMMENT ON COLUMN bitbucket_pipelines.state IS 'state of the pipeline (PENDING, IN_PROGRESS or COMPLETED)';
+COMMENT ON COLUMN bitbucket_pipelines.result IS 'result of a completed pipeline (e.g. SUCCESSFUL, FAILED, STOPPED)';
+COMMENT ON COLUMN bitbucket_pipelines.target_ref_type IS 'type of the ref the pipeline ran against (branch or tag)';
+COMMENT ON COLUMN bitbucket_pipelines.target_ref_name IS 'name of the ref the pipeline ran against';
+COMMENT ON COLUMN bitbucket_pipelines.target_commit IS 'hash of the commit the pipeline ran against';
+COMMENT ON COLUMN bitbucket_pipelines.created_on IS 'timestamp of when the pipeline was created';
+COMMENT ON COLUMN bitbucket_pipelines.completed_on IS 'timestamp of when the pipeline completed';
+COMMENT ON COLUMN bitbucket_pipelines.duration_in_seconds IS 'duration of the pipeline in seconds';
+COMMENT ON COLUMN bitbucket_pipelines.build_seconds_used IS 'number of build seconds used by the pipeline';
+COMMENT ON COLUMN bitbucket_pipelines._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+COMMIT;
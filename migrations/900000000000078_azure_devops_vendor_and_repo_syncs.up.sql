@@ -0,0 +1,108 @@
+BEGIN;
+
+INSERT INTO mergestat.vendors (name, display_name)
+VALUES ('azure', 'Azure DevOps')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.service_auth_credential_types (type, description) VALUES
+('AZURE_DEVOPS_PAT', 'Authentication using Azure DevOps Personal Access Token')
+ON CONFLICT DO NOTHING;
+
+-- like GitHub and Bitbucket, Azure DevOps rate limits API requests per user, so run its syncs one at a time
+INSERT INTO mergestat.repo_sync_type_groups ("group", concurrent_syncs)
+VALUES ('AZURE', 1)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_labels (label, color)
+VALUES ('azure', '#0078d4')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('AZURE_REPO_PRS', 'Retrieves all the pull requests of an Azure DevOps repo', 'Azure DevOps Pull Requests', 2, 'AZURE'),
+       ('AZURE_REPO_BUILDS', 'Retrieves all the builds (pipeline runs) of an Azure DevOps repo', 'Azure DevOps Builds', 2, 'AZURE')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('azure', 'AZURE_REPO_PRS'),
+       ('azure', 'AZURE_REPO_BUILDS')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS azure_pull_requests (
+    repo_id uuid NOT NULL REFERENCES repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id integer NOT NULL,
+    title text,
+    description text,
+    status text,
+    merge_status text,
+    is_draft boolean,
+    created_by text,
+    closed_by text,
+    created_at timestamp with time zone,
+    closed_at timestamp with time zone,
+    source_ref_name text,
+    target_ref_name text,
+    source_commit text,
+    merge_commit text,
+    reviewers jsonb NOT NULL DEFAULT '[]'::jsonb,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT azure_pull_requests_pkey PRIMARY KEY (repo_id, id)
+);
+
+COMMENT ON TABLE azure_pull_requests IS 'pull requests of an Azure DevOps repo';
+COMMENT ON COLUMN azure_pull_requests.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN azure_pull_requests.id IS 'id of the pull request';
+COMMENT ON COLUMN azure_pull_requests.title IS 'title of the pull request';
+COMMENT ON COLUMN azure_pull_requests.description IS 'description of the pull request';
+COMMENT ON COLUMN azure_pull_requests.status IS 'status of the pull request (active, abandoned or completed)';
+COMMENT ON COLUMN azure_pull_requests.merge_status IS 'status of the most recent merge attempt of the pull request';
+COMMENT ON COLUMN azure_pull_requests.is_draft IS 'boolean to determine if the pull request is a draft';
+COMMENT ON COLUMN azure_pull_requests.created_by IS 'unique name of the user who created the pull request';
+COMMENT ON COLUMN azure_pull_requests.closed_by IS 'unique name of the user who closed the pull request';
+COMMENT ON COLUMN azure_pull_requests.created_at IS 'timestamp of when the pull request was created';
+COMMENT ON COLUMN azure_pull_requests.closed_at IS 'timestamp of when the pull request was closed';
+COMMENT ON COLUMN azure_pull_requests.source_ref_name IS 'name of the source ref of the pull request';
+COMMENT ON COLUMN azure_pull_requests.target_ref_name IS 'name of the target ref of the pull request';
+COMMENT ON COLUMN azure_pull_requests.source_commit IS 'id of the source commit of the most recent merge attempt';
+COMMENT ON COLUMN azure_pull_requests.merge_commit IS 'id of the commit of the most recent merge attempt';
+COMMENT ON COLUMN azure_pull_requests.reviewers IS 'JSON array of the reviewers of the pull request (and their votes)';
+COMMENT ON COLUMN azure_pull_requests._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS azure_builds (
+    repo_id uuid NOT NULL REFERENCES repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id integer NOT NULL,
+    build_number text,
+    definition_id integer,
+    definition_name text,
+    status text,
+    result text,
+    reason text,
+    source_branch text,
+    source_version text,
+    requested_for text,
+    queued_at timestamp with time zone,
+    started_at timestamp with time zone,
+    finished_at timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT azure_builds_pkey PRIMARY KEY (repo_id, id)
+);
+
+COMMENT ON TABLE azure_builds IS 'builds (pipeline runs) of an Azure DevOps repo';
+COMMENT ON COLUMN azure_builds.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN azure_builds.id IS 'id of the build';
+COMMENT ON COLUMN azure_builds.build_number IS 'build number of the build';
+COMMENT ON COLUMN azure_builds.definition_id IS 'id of the build definition (pipeline)';
+COMMENT ON COLUMN azure_builds.definition_name IS 'name of the build definition (pipeline)';
+COMMENT ON COLUMN azure_builds.status IS 'status of the build (e.g. inProgress, completed)';
+COMMENT ON COLUMN azure_builds.result IS 'result of the build (e.g. succeeded, failed, canceled)';
+COMMENT ON COLUMN azure_builds.reason IS 'reason the build was triggered (e.g. manual, individualCI, pullRequest)';
+COMMENT ON COLUMN azure_builds.source_branch IS 'source branch of the build';
+COMMENT ON COLUMN azure_builds.source_version IS 'source version (commit) of the build';
+COMMENT ON COLUMN azure_builds.requested_for IS 'unique name of the user the build was requested for';
+COMMENT ON COLUMN azure_builds.queued_at IS 'timestamp of when the build was queued';
+COMMENT ON COLUMN azure_builds.started_at IS 'timestamp of when the build started';
+COMMENT ON COLUMN azure_builds.finished_at IS 'timestamp of when the build finished';
+COMMENT ON COLUMN azure_builds.url IS 'URL of the build';
+COMMENT ON COLUMN azure_builds._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- watermarks let syncs resume from where their previous run stopped (e.g. the newest commit synced
+-- by GIT_COMMITS) instead of re-processing the entire history of a repo on every run
+CREATE TABLE IF NOT EXISTS mergestat.repo_sync_watermarks (
+    repo_sync_id uuid NOT NULL PRIMARY KEY REFERENCES mergestat.repo_syncs(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    watermark text NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+COMMENT ON TABLE mergestat.repo_sync_watermarks IS 'position up to which a repo sync has processed its source, used by incremental syncs';
+COMMENT ON COLUMN mergestat.repo_sync_watermarks.repo_sync_id IS 'foreign key for mergestat.repo_syncs.id';
+COMMENT ON COLUMN mergestat.repo_sync_watermarks.watermark IS 'sync specific position (e.g. hash of the newest synced commit)';
+COMMENT ON COLUMN mergestat.repo_sync_watermarks.updated_at IS 'timestamp when the watermark was last updated';
+
+COMMIT;
@@ -0,0 +1,22 @@
+BEGIN;
+
+-- limits on the number of syncs running at the same time, on top of the per type group limits
+-- in mergestat.repo_sync_type_groups. A NULL limit means the syncs are not limited.
+ALTER TABLE mergestat.providers ADD COLUMN IF NOT EXISTS concurrent_syncs INTEGER CHECK (concurrent_syncs > 0);
+
+COMMENT ON COLUMN mergestat.providers.concurrent_syncs IS 'max number of syncs of repos of this provider running at the same time (e.g. to respect API rate limits), unlimited if NULL';
+
+CREATE TABLE IF NOT EXISTS mergestat.repo_sync_concurrency_limits (
+    id boolean DEFAULT true NOT NULL PRIMARY KEY CHECK (id),
+    concurrent_syncs integer CHECK (concurrent_syncs > 0),
+    concurrent_syncs_per_repo integer CHECK (concurrent_syncs_per_repo > 0)
+);
+
+COMMENT ON TABLE mergestat.repo_sync_concurrency_limits IS 'global limits on the number of syncs running at the same time, holds a single row';
+COMMENT ON COLUMN mergestat.repo_sync_concurrency_limits.id IS 'always true, restricts the table to a single row';
+COMMENT ON COLUMN mergestat.repo_sync_concurrency_limits.concurrent_syncs IS 'max number of syncs running at the same time across all repos, unlimited if NULL';
+COMMENT ON COLUMN mergestat.repo_sync_concurrency_limits.concurrent_syncs_per_repo IS 'max number of syncs of a single repo running at the same time (e.g. to avoid cloning a repo twice), unlimited if NULL';
+
+INSERT INTO mergestat.repo_sync_concurrency_limits (id) VALUES (true) ON CONFLICT DO NOTHING;
+
+COMMIT;
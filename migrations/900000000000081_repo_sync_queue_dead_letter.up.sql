@@ -0,0 +1,55 @@
+BEGIN;
+
+-- failing sync jobs are retried (re-queued) until they run out of attempts, at which point they're moved
+-- to the DEAD status along with their last error. Dead jobs can be re-queued in bulk once the underlying
+-- issue (e.g. an expired token) is fixed, using mergestat.requeue_dead_repo_syncs().
+INSERT INTO mergestat.repo_sync_queue_status_types (type, description) VALUES ('DEAD', 'Sync job failed permanently, after exhausting its retries') ON CONFLICT DO NOTHING;
+
+ALTER TABLE mergestat.repo_sync_types ADD COLUMN IF NOT EXISTS max_attempts INTEGER DEFAULT 3 NOT NULL CHECK (max_attempts > 0);
+COMMENT ON COLUMN mergestat.repo_sync_types.max_attempts IS 'number of times a failing sync of this type is attempted before it is moved to the DEAD status';
+
+ALTER TABLE mergestat.repo_sync_queue ADD COLUMN IF NOT EXISTS attempts INTEGER DEFAULT 0 NOT NULL;
+ALTER TABLE mergestat.repo_sync_queue ADD COLUMN IF NOT EXISTS last_error TEXT;
+COMMENT ON COLUMN mergestat.repo_sync_queue.attempts IS 'number of failed attempts of the sync job';
+COMMENT ON COLUMN mergestat.repo_sync_queue.last_error IS 'error of the last failed attempt of the sync job';
+
+-- dead jobs are done too, so that they don't keep new syncs of their type group from being enqueued
+CREATE OR REPLACE FUNCTION public.repo_sync_queue_status_update_trigger() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+	IF NEW.status = 'RUNNING' AND OLD.status = 'QUEUED' THEN
+		NEW.started_at = now();
+	ELSEIF NEW.status IN ('DONE', 'DEAD') AND OLD.status = 'RUNNING' THEN
+		NEW.done_at = now();
+	ELSEIF NEW.status = 'QUEUED' AND OLD.status = 'DEAD' THEN
+		NEW.done_at = NULL;
+	END IF;
+	RETURN NEW;
+END;
+$$;
+
+CREATE OR REPLACE FUNCTION mergestat.requeue_dead_repo_syncs(provider_id UUID DEFAULT NULL, sync_type TEXT DEFAULT NULL)
+RETURNS INTEGER
+AS
+$$
+DECLARE _rows_requeued INTEGER;
+BEGIN
+    UPDATE mergestat.repo_sync_queue rsq SET status = 'QUEUED', attempts = 0
+    FROM mergestat.repo_syncs rs, public.repos r
+    WHERE rsq.status = 'DEAD'
+        AND rs.id = rsq.repo_sync_id
+        AND r.id = rs.repo_id
+        AND (requeue_dead_repo_syncs.provider_id IS NULL OR r.provider = requeue_dead_repo_syncs.provider_id)
+        AND (requeue_dead_repo_syncs.sync_type IS NULL OR rs.sync_type = requeue_dead_repo_syncs.sync_type)
+        -- only the latest run of a repo sync is re-queued, if a newer one was enqueued since it's left alone
+        AND rsq.id = (SELECT MAX(id) FROM mergestat.repo_sync_queue WHERE repo_sync_id = rsq.repo_sync_id);
+    GET DIAGNOSTICS _rows_requeued = ROW_COUNT;
+
+    RETURN _rows_requeued;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON FUNCTION mergestat.requeue_dead_repo_syncs(UUID, TEXT) IS 're-queues the dead sync jobs (optionally only the ones of a provider and/or sync type), returns the number of re-queued jobs';
+
+COMMIT;
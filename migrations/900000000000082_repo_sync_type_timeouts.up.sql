@@ -0,0 +1,50 @@
+BEGIN;
+
+-- sync types can have a timeout, after which the worker cancels the sync, rolls back its changes
+-- and moves the job to the TIMED_OUT status. A NULL timeout means the syncs of the type can run forever.
+INSERT INTO mergestat.repo_sync_queue_status_types (type, description) VALUES ('TIMED_OUT', 'Sync job was canceled after running longer than the timeout of its sync type') ON CONFLICT DO NOTHING;
+
+ALTER TABLE mergestat.repo_sync_types ADD COLUMN IF NOT EXISTS timeout_seconds INTEGER CHECK (timeout_seconds > 0);
+COMMENT ON COLUMN mergestat.repo_sync_types.timeout_seconds IS 'number of seconds a sync of this type may run before it is canceled, no timeout if NULL';
+
+CREATE OR REPLACE FUNCTION public.repo_sync_queue_status_update_trigger() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+	IF NEW.status = 'RUNNING' AND OLD.status = 'QUEUED' THEN
+		NEW.started_at = now();
+	ELSEIF NEW.status IN ('DONE', 'DEAD', 'TIMED_OUT') AND OLD.status = 'RUNNING' THEN
+		NEW.done_at = now();
+	ELSEIF NEW.status = 'QUEUED' AND OLD.status = 'DEAD' THEN
+		NEW.done_at = NULL;
+	END IF;
+	RETURN NEW;
+END;
+$$;
+
+-- a timed out job is the latest completed run of its repo sync too
+CREATE OR REPLACE FUNCTION mergestat.set_sync_job_status(new_status TEXT, repo_sync_queue_id BIGINT)
+RETURNS UUID
+AS
+$$
+DECLARE _repo_sync_id UUID;
+BEGIN
+    IF new_status IN ('DONE', 'TIMED_OUT') THEN
+            WITH update_queue AS (
+                UPDATE mergestat.repo_sync_queue SET "status" = new_status WHERE mergestat.repo_sync_queue.id = repo_sync_queue_id
+                RETURNING *
+            )
+            UPDATE mergestat.repo_syncs set last_completed_repo_sync_queue_id = repo_sync_queue_id
+            FROM update_queue
+            WHERE mergestat.repo_syncs.id = update_queue.repo_sync_id
+            RETURNING mergestat.repo_syncs.id INTO _repo_sync_id;
+    ELSE    
+            UPDATE mergestat.repo_sync_queue SET "status" = new_status WHERE mergestat.repo_sync_queue.id = repo_sync_queue_id
+            RETURNING repo_sync_id INTO _repo_sync_id;
+    END IF;
+    
+    RETURN _repo_sync_id;    
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
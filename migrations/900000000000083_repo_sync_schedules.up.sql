@@ -0,0 +1,26 @@
+BEGIN;
+
+-- repo syncs can have a schedule of their own, instead of being re-enqueued along with all the other syncs of their
+-- type group (see EnqueueAllSyncs). The scheduler computes the next run of these syncs and enqueues them once it's due.
+ALTER TABLE mergestat.repo_syncs ADD COLUMN IF NOT EXISTS schedule TEXT;
+ALTER TABLE mergestat.repo_syncs ADD COLUMN IF NOT EXISTS next_run_at TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN mergestat.repo_syncs.schedule IS 'cron expression (e.g. 0 3 * * 0 or @weekly) or interval (e.g. @every 15m) the sync runs on, if NULL the sync runs along with the other syncs of its type group';
+COMMENT ON COLUMN mergestat.repo_syncs.next_run_at IS 'timestamp when the sync is next enqueued, computed by the scheduler from the schedule';
+
+-- a changed schedule is re-evaluated by the scheduler from scratch
+CREATE OR REPLACE FUNCTION mergestat.repo_syncs_schedule_update_trigger() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+	IF NEW.schedule IS DISTINCT FROM OLD.schedule THEN
+		NEW.next_run_at = NULL;
+	END IF;
+	RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS repo_syncs_schedule_update_trigger ON mergestat.repo_syncs;
+CREATE TRIGGER repo_syncs_schedule_update_trigger BEFORE UPDATE ON mergestat.repo_syncs FOR EACH ROW EXECUTE FUNCTION mergestat.repo_syncs_schedule_update_trigger();
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- jobs are dequeued by priority (lowest first), then by age. Jobs enqueued by the scheduler always get the priority
+-- of their repo sync (0 and up), so any job enqueued without an explicit priority (e.g. a "sync now" request from
+-- the UI) defaults to a priority that jumps ahead of the routine scheduled syncs.
+ALTER TABLE mergestat.repo_sync_queue ALTER COLUMN priority SET DEFAULT -1;
+
+COMMENT ON COLUMN mergestat.repo_sync_queue.priority IS 'jobs with a lower priority are dequeued first: scheduled jobs get the priority of their repo sync (0 and up), jobs requested by users default to -1';
+
+CREATE INDEX IF NOT EXISTS idx_repo_sync_queue_queued_priority ON mergestat.repo_sync_queue (priority ASC, created_at ASC, id ASC) WHERE status = 'QUEUED';
+
+COMMIT;
@@ -0,0 +1,38 @@
+BEGIN;
+
+-- enqueues a sync of a repo to run right away, ahead of the scheduled syncs and regardless of its schedule
+-- (e.g. from the UI or CLI after pushing changes). Returns the id of the queued job.
+CREATE OR REPLACE FUNCTION mergestat.sync_repo_now(repo_id_param UUID, sync_type_param TEXT)
+RETURNS BIGINT
+LANGUAGE PLPGSQL VOLATILE
+AS $$
+DECLARE
+    _repo_sync_id UUID;
+    _queue_id BIGINT;
+BEGIN
+    --The sync doesn't need to be configured for the repo beforehand, it's added (unscheduled) if missing
+    INSERT INTO mergestat.repo_syncs (repo_id, sync_type, schedule_enabled) VALUES (repo_id_param, sync_type_param, FALSE)
+    ON CONFLICT ON CONSTRAINT repo_syncs_repo_id_sync_type_key DO NOTHING;
+
+    SELECT id INTO _repo_sync_id FROM mergestat.repo_syncs WHERE repo_id = repo_id_param AND sync_type = sync_type_param;
+
+    --An already queued job is moved ahead instead of enqueuing another one
+    SELECT id INTO _queue_id FROM mergestat.repo_sync_queue WHERE repo_sync_id = _repo_sync_id AND status = 'QUEUED' ORDER BY id LIMIT 1;
+
+    IF _queue_id IS NOT NULL THEN
+        UPDATE mergestat.repo_sync_queue SET priority = LEAST(priority, -1) WHERE id = _queue_id;
+    ELSE
+        INSERT INTO mergestat.repo_sync_queue (repo_sync_id, status, priority, type_group)
+        SELECT _repo_sync_id, 'QUEUED', -1, rst.type_group FROM mergestat.repo_sync_types rst WHERE rst.type = sync_type_param
+        RETURNING id INTO _queue_id;
+    END IF;
+
+    --Wake up an idle worker, rather than having the job wait for the next poll
+    PERFORM pg_notify('mergestat_repo_sync_queue', _queue_id::TEXT);
+
+    RETURN _queue_id;
+END; $$;
+
+COMMENT ON FUNCTION mergestat.sync_repo_now(UUID, TEXT) IS 'enqueues a sync of a repo to run right away, ahead of the scheduled syncs, returns the id of the queued job';
+
+COMMIT;
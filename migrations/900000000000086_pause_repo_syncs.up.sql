@@ -0,0 +1,39 @@
+BEGIN;
+
+-- syncs can be paused per sync type (for all repos) and per repo (for all sync types), without deleting their
+-- configuration. Paused syncs aren't enqueued by the scheduler, and their already queued jobs wait until they're resumed.
+ALTER TABLE mergestat.repo_sync_types ADD COLUMN IF NOT EXISTS enabled BOOLEAN DEFAULT TRUE NOT NULL;
+COMMENT ON COLUMN mergestat.repo_sync_types.enabled IS 'syncs of this type are paused for all repos if false';
+
+CREATE TABLE IF NOT EXISTS mergestat.repo_sync_pauses (
+    repo_id uuid NOT NULL PRIMARY KEY REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    paused_at timestamp with time zone DEFAULT now() NOT NULL,
+    reason text
+);
+
+COMMENT ON TABLE mergestat.repo_sync_pauses IS 'repos whose syncs are paused (e.g. during a migration or while a token is being rotated)';
+COMMENT ON COLUMN mergestat.repo_sync_pauses.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN mergestat.repo_sync_pauses.paused_at IS 'timestamp when the syncs of the repo were paused';
+COMMENT ON COLUMN mergestat.repo_sync_pauses.reason IS 'why the syncs of the repo are paused';
+
+CREATE OR REPLACE FUNCTION mergestat.pause_repo_syncs(repo_id_param UUID, reason_param TEXT DEFAULT NULL)
+RETURNS BOOLEAN
+LANGUAGE SQL VOLATILE
+AS $$
+    INSERT INTO mergestat.repo_sync_pauses (repo_id, reason) VALUES (repo_id_param, reason_param)
+    ON CONFLICT (repo_id) DO UPDATE SET reason = excluded.reason;
+    SELECT TRUE;
+$$;
+
+CREATE OR REPLACE FUNCTION mergestat.resume_repo_syncs(repo_id_param UUID)
+RETURNS BOOLEAN
+LANGUAGE SQL VOLATILE
+AS $$
+    DELETE FROM mergestat.repo_sync_pauses WHERE repo_id = repo_id_param;
+    SELECT TRUE;
+$$;
+
+COMMENT ON FUNCTION mergestat.pause_repo_syncs(UUID, TEXT) IS 'pauses all the syncs of a repo, until they are resumed';
+COMMENT ON FUNCTION mergestat.resume_repo_syncs(UUID) IS 'resumes the paused syncs of a repo';
+
+COMMIT;
@@ -0,0 +1,64 @@
+BEGIN;
+
+-- sync types can depend on other sync types, e.g. commit stats are only consistent with the commits synced before them.
+-- A job isn't dequeued while a job of a sync type it depends on is queued or running for the same repo, and it's
+-- skipped if the latest run of a sync type it depends on failed (or was skipped itself).
+CREATE TABLE IF NOT EXISTS mergestat.repo_sync_type_dependencies (
+    sync_type text NOT NULL REFERENCES mergestat.repo_sync_types(type) ON DELETE CASCADE ON UPDATE RESTRICT,
+    depends_on text NOT NULL REFERENCES mergestat.repo_sync_types(type) ON DELETE CASCADE ON UPDATE RESTRICT,
+    PRIMARY KEY (sync_type, depends_on),
+    CHECK (sync_type <> depends_on)
+);
+
+COMMENT ON TABLE mergestat.repo_sync_type_dependencies IS 'sync types that have to run (successfully) before another sync type of the same repo';
+COMMENT ON COLUMN mergestat.repo_sync_type_dependencies.sync_type IS 'the dependent sync type';
+COMMENT ON COLUMN mergestat.repo_sync_type_dependencies.depends_on IS 'the sync type it depends on';
+
+INSERT INTO mergestat.repo_sync_type_dependencies (sync_type, depends_on) VALUES
+('GIT_COMMIT_STATS', 'GIT_COMMITS'),
+('GIT_BLAME', 'GIT_FILES')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_queue_status_types (type, description) VALUES ('SKIPPED', 'Sync job was skipped because a sync it depends on failed') ON CONFLICT DO NOTHING;
+
+CREATE OR REPLACE FUNCTION public.repo_sync_queue_status_update_trigger() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+	IF NEW.status = 'RUNNING' AND OLD.status = 'QUEUED' THEN
+		NEW.started_at = now();
+	ELSEIF NEW.status IN ('DONE', 'DEAD', 'TIMED_OUT', 'SKIPPED') AND OLD.status = 'RUNNING' THEN
+		NEW.done_at = now();
+	ELSEIF NEW.status = 'QUEUED' AND OLD.status = 'DEAD' THEN
+		NEW.done_at = NULL;
+	END IF;
+	RETURN NEW;
+END;
+$$;
+
+-- a skipped job is the latest completed run of its repo sync too, so that its own dependents are skipped as well
+CREATE OR REPLACE FUNCTION mergestat.set_sync_job_status(new_status TEXT, repo_sync_queue_id BIGINT)
+RETURNS UUID
+AS
+$$
+DECLARE _repo_sync_id UUID;
+BEGIN
+    IF new_status IN ('DONE', 'TIMED_OUT', 'SKIPPED') THEN
+            WITH update_queue AS (
+                UPDATE mergestat.repo_sync_queue SET "status" = new_status WHERE mergestat.repo_sync_queue.id = repo_sync_queue_id
+                RETURNING *
+            )
+            UPDATE mergestat.repo_syncs set last_completed_repo_sync_queue_id = repo_sync_queue_id
+            FROM update_queue
+            WHERE mergestat.repo_syncs.id = update_queue.repo_sync_id
+            RETURNING mergestat.repo_syncs.id INTO _repo_sync_id;
+    ELSE    
+            UPDATE mergestat.repo_sync_queue SET "status" = new_status WHERE mergestat.repo_sync_queue.id = repo_sync_queue_id
+            RETURNING repo_sync_id INTO _repo_sync_id;
+    END IF;
+    
+    RETURN _repo_sync_id;    
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
@@ -0,0 +1,63 @@
+BEGIN;
+
+-- a provider can have many credentials (e.g. several GitHub tokens, to spread API rate limits across them).
+-- credentials can be assigned to specific repos, or to all the repos of an org (the owner in the repo url),
+-- and are rotated (least recently used first) whenever more than one of them applies to a repo.
+ALTER TABLE mergestat.service_auth_credentials ADD COLUMN IF NOT EXISTS last_used_at TIMESTAMP WITH TIME ZONE;
+COMMENT ON COLUMN mergestat.service_auth_credentials.last_used_at IS 'timestamp of when the credential was last handed out to a sync, used to rotate credentials';
+
+CREATE TABLE IF NOT EXISTS mergestat.service_auth_credential_assignments (
+    id uuid DEFAULT public.gen_random_uuid() NOT NULL PRIMARY KEY,
+    credential_id uuid NOT NULL REFERENCES mergestat.service_auth_credentials(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    repo_id uuid REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    org text,
+    CONSTRAINT service_auth_credential_assignments_repo_or_org CHECK ((repo_id IS NULL) <> (org IS NULL)),
+    UNIQUE (credential_id, repo_id),
+    UNIQUE (credential_id, org)
+);
+
+COMMENT ON TABLE mergestat.service_auth_credential_assignments IS 'assigns credentials to specific repos or orgs, unassigned credentials are used for all other repos of their provider';
+COMMENT ON COLUMN mergestat.service_auth_credential_assignments.credential_id IS 'foreign key for mergestat.service_auth_credentials.id';
+COMMENT ON COLUMN mergestat.service_auth_credential_assignments.repo_id IS 'foreign key for public.repos.id, the repo the credential is assigned to';
+COMMENT ON COLUMN mergestat.service_auth_credential_assignments.org IS 'the org (owner in the repo url) the credential is assigned to';
+
+-- pick the credential to use for a repo (or for the provider in general, if repo_id_param is NULL), and mark it as used.
+-- credentials assigned to the repo come first, then the ones assigned to its org, then the unassigned ones.
+-- within each of those, the least recently used credential is picked, rotating through them in a round-robin fashion.
+CREATE OR REPLACE FUNCTION mergestat.next_service_auth_credential(provider_id UUID, repo_id_param UUID, secret TEXT)
+RETURNS TABLE (id UUID, type TEXT, username TEXT, token TEXT) AS $$
+DECLARE _org TEXT; _id UUID;
+BEGIN
+    IF repo_id_param IS NOT NULL THEN
+        SELECT lower(split_part(regexp_replace(r.repo, '^[a-z+]+://[^/]+/', ''), '/', 1)) INTO _org
+            FROM public.repos r WHERE r.id = repo_id_param;
+    END IF;
+
+    SELECT c.id INTO _id FROM (
+        SELECT c.id, c.last_used_at,
+            CASE
+                WHEN EXISTS (SELECT 1 FROM mergestat.service_auth_credential_assignments a WHERE a.credential_id = c.id AND a.repo_id = repo_id_param) THEN 0
+                WHEN EXISTS (SELECT 1 FROM mergestat.service_auth_credential_assignments a WHERE a.credential_id = c.id AND lower(a.org) = _org) THEN 1
+                WHEN NOT EXISTS (SELECT 1 FROM mergestat.service_auth_credential_assignments a WHERE a.credential_id = c.id) THEN 2
+            END AS tier
+        FROM mergestat.service_auth_credentials c
+        WHERE c.provider = provider_id
+    ) c
+    WHERE c.tier IS NOT NULL
+    ORDER BY c.tier, c.last_used_at ASC NULLS FIRST
+    LIMIT 1;
+
+    IF _id IS NULL THEN
+        RETURN;
+    END IF;
+
+    UPDATE mergestat.service_auth_credentials c SET last_used_at = now() WHERE c.id = _id;
+
+    RETURN QUERY SELECT c.id, c.type, pgp_sym_decrypt(c.username, secret), pgp_sym_decrypt(c.credentials, secret)
+        FROM mergestat.service_auth_credentials c WHERE c.id = _id;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON FUNCTION mergestat.next_service_auth_credential(UUID, UUID, TEXT) IS 'picks (and rotates) the credential to use for a repo of the provider';
+
+COMMIT;
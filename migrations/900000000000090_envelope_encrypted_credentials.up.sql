@@ -0,0 +1,67 @@
+BEGIN;
+
+-- credentials can be encrypted with a key held by the worker (envelope encryption, see internal/envelope), rather than with
+-- pgp_sym_encrypt (which needs the secret to be sent to the database). Credentials added through the app are still encrypted
+-- with pgp_sym_encrypt at first, and are re-encrypted by the worker, after which only the worker is able to decrypt them.
+ALTER TABLE mergestat.service_auth_credentials ADD COLUMN IF NOT EXISTS encrypted_key BYTEA;
+COMMENT ON COLUMN mergestat.service_auth_credentials.encrypted_key IS 'data key the credential (and username) is encrypted with, itself encrypted with the worker-held key; NULL if encrypted with pgp_sym_encrypt';
+
+-- credentials encrypted with the worker-held key can't be decrypted here, so their token and username are NULL
+CREATE OR REPLACE FUNCTION mergestat.fetch_service_auth_credential(provider_id UUID, credential_type TEXT, secret TEXT)
+RETURNS TABLE (id UUID, username TEXT, token TEXT, created_at TIMESTAMP WITH TIME ZONE) AS $$
+BEGIN
+    RETURN QUERY SELECT c.id,
+            CASE WHEN c.encrypted_key IS NULL THEN pgp_sym_decrypt(c.username, secret) END,
+            CASE WHEN c.encrypted_key IS NULL THEN pgp_sym_decrypt(c.credentials, secret) END AS token,
+            c.created_at
+        FROM mergestat.service_auth_credentials c
+    WHERE c.provider = provider_id AND
+        (credential_type IS NULL OR c.type = credential_type)
+    ORDER BY is_default DESC, created_at DESC;
+END;
+$$ LANGUAGE plpgsql;
+
+-- return the still encrypted credential (and username), along with its encrypted data key, for the worker to decrypt in memory
+DROP FUNCTION IF EXISTS mergestat.next_service_auth_credential(UUID, UUID, TEXT);
+CREATE FUNCTION mergestat.next_service_auth_credential(provider_id UUID, repo_id_param UUID, secret TEXT)
+RETURNS TABLE (id UUID, type TEXT, username TEXT, token TEXT, encrypted_key BYTEA, encrypted_username BYTEA, encrypted_token BYTEA) AS $$
+DECLARE _org TEXT; _id UUID;
+BEGIN
+    IF repo_id_param IS NOT NULL THEN
+        SELECT lower(split_part(regexp_replace(r.repo, '^[a-z+]+://[^/]+/', ''), '/', 1)) INTO _org
+            FROM public.repos r WHERE r.id = repo_id_param;
+    END IF;
+
+    SELECT c.id INTO _id FROM (
+        SELECT c.id, c.last_used_at,
+            CASE
+                WHEN EXISTS (SELECT 1 FROM mergestat.service_auth_credential_assignments a WHERE a.credential_id = c.id AND a.repo_id = repo_id_param) THEN 0
+                WHEN EXISTS (SELECT 1 FROM mergestat.service_auth_credential_assignments a WHERE a.credential_id = c.id AND lower(a.org) = _org) THEN 1
+                WHEN NOT EXISTS (SELECT 1 FROM mergestat.service_auth_credential_assignments a WHERE a.credential_id = c.id) THEN 2
+            END AS tier
+        FROM mergestat.service_auth_credentials c
+        WHERE c.provider = provider_id
+    ) c
+    WHERE c.tier IS NOT NULL
+    ORDER BY c.tier, c.last_used_at ASC NULLS FIRST
+    LIMIT 1;
+
+    IF _id IS NULL THEN
+        RETURN;
+    END IF;
+
+    UPDATE mergestat.service_auth_credentials c SET last_used_at = now() WHERE c.id = _id;
+
+    RETURN QUERY SELECT c.id, c.type,
+            CASE WHEN c.encrypted_key IS NULL THEN pgp_sym_decrypt(c.username, secret) END,
+            CASE WHEN c.encrypted_key IS NULL THEN pgp_sym_decrypt(c.credentials, secret) END,
+            c.encrypted_key,
+            CASE WHEN c.encrypted_key IS NOT NULL THEN c.username END,
+            CASE WHEN c.encrypted_key IS NOT NULL THEN c.credentials END
+        FROM mergestat.service_auth_credentials c WHERE c.id = _id;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON FUNCTION mergestat.next_service_auth_credential(UUID, UUID, TEXT) IS 'picks (and rotates) the credential to use for a repo of the provider';
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- credentials of these types only store a reference to a secret in an external secrets backend (see internal/secrets),
+-- which the worker fetches at job time. The secret holds the token, and optionally a username:
+--   VAULT_SECRET: the path of a KV secret (e.g. secret/data/mergestat/github), with token and username keys
+--   AWS_SECRETS_MANAGER_SECRET: the name or ARN of a secret, either a plain token or a JSON object with token and username keys
+INSERT INTO mergestat.service_auth_credential_types (type, description) VALUES
+('VAULT_SECRET', 'Authentication using a token stored in HashiCorp Vault, referenced by the path of its secret'),
+('AWS_SECRETS_MANAGER_SECRET', 'Authentication using a token stored in AWS Secrets Manager, referenced by the name or ARN of its secret')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
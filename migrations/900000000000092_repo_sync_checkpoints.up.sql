@@ -0,0 +1,16 @@
+BEGIN;
+
+-- long running API syncs (e.g. paging through all the issues or pull requests of a large repo) periodically save their
+-- progress, so that a job that is interrupted (by a deploy, a crash or a rate limit) and requeued resumes where it left off
+CREATE TABLE IF NOT EXISTS mergestat.repo_sync_checkpoints (
+    repo_sync_queue_id bigint NOT NULL PRIMARY KEY REFERENCES mergestat.repo_sync_queue(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    state jsonb NOT NULL,
+    updated_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+COMMENT ON TABLE mergestat.repo_sync_checkpoints IS 'progress of a running repo sync job, so that an interrupted job resumes where it left off';
+COMMENT ON COLUMN mergestat.repo_sync_checkpoints.repo_sync_queue_id IS 'foreign key for mergestat.repo_sync_queue.id';
+COMMENT ON COLUMN mergestat.repo_sync_checkpoints.state IS 'sync specific state (e.g. the page a paginated API sync is at, and what it fetched so far)';
+COMMENT ON COLUMN mergestat.repo_sync_checkpoints.updated_at IS 'timestamp when the checkpoint was last updated';
+
+COMMIT;
@@ -0,0 +1,23 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_log_types(type, description) VALUES ('PROGRESS', 'Progress of a running sync')
+ON CONFLICT(type) DO UPDATE
+SET description=EXCLUDED.description;
+
+-- long running syncs periodically log their progress (the phase they're in, how many items they processed, and how many
+-- they expect to process in total) as structured data, so that the progress of a sync can be shown as a progress bar
+ALTER TABLE mergestat.repo_sync_logs ADD COLUMN IF NOT EXISTS progress JSONB;
+
+COMMENT ON COLUMN mergestat.repo_sync_logs.progress IS 'progress of the sync at the time of a PROGRESS log, as {"phase", "processed", "total", "percent"}, where total (and percent) are omitted if unknown';
+
+-- the latest progress reported by each sync job
+CREATE OR REPLACE VIEW mergestat.repo_sync_queue_progress AS (
+    SELECT DISTINCT ON (repo_sync_queue_id) repo_sync_queue_id, created_at AS reported_at, progress
+    FROM mergestat.repo_sync_logs
+    WHERE log_type = 'PROGRESS' AND progress IS NOT NULL
+    ORDER BY repo_sync_queue_id, id DESC
+);
+
+COMMENT ON VIEW mergestat.repo_sync_queue_progress IS 'latest progress reported by each repo sync job';
+
+COMMIT;
@@ -0,0 +1,60 @@
+BEGIN;
+
+-- the history of sync jobs (and their logs) is pruned by the worker, based on the age of finished jobs and/or the
+-- number of finished jobs kept per repo sync. The latest finished job of each repo sync is always kept.
+CREATE TABLE IF NOT EXISTS mergestat.repo_sync_retention (
+    id boolean DEFAULT true NOT NULL PRIMARY KEY CHECK (id),
+    max_age interval CHECK (max_age > '0'::interval),
+    max_jobs_per_repo_sync integer CHECK (max_jobs_per_repo_sync > 0)
+);
+
+COMMENT ON TABLE mergestat.repo_sync_retention IS 'global retention policy of the history of sync jobs (and their logs), holds a single row';
+COMMENT ON COLUMN mergestat.repo_sync_retention.id IS 'always true, restricts the table to a single row';
+COMMENT ON COLUMN mergestat.repo_sync_retention.max_age IS 'finished sync jobs older than this are pruned, kept regardless of age if NULL';
+COMMENT ON COLUMN mergestat.repo_sync_retention.max_jobs_per_repo_sync IS 'max number of finished sync jobs kept per repo sync, unlimited if NULL';
+
+-- keeps 30 days of history, as the worker did before (see REPO_SYNC_QUEUE_RETENTION_DAYS)
+INSERT INTO mergestat.repo_sync_retention (id, max_age) VALUES (true, '30 days') ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS mergestat.repo_sync_retention_overrides (
+    repo_id uuid NOT NULL PRIMARY KEY REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    max_age interval CHECK (max_age > '0'::interval),
+    max_jobs_per_repo_sync integer CHECK (max_jobs_per_repo_sync > 0)
+);
+
+COMMENT ON TABLE mergestat.repo_sync_retention_overrides IS 'retention policy of the history of sync jobs of a single repo, overriding mergestat.repo_sync_retention';
+COMMENT ON COLUMN mergestat.repo_sync_retention_overrides.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN mergestat.repo_sync_retention_overrides.max_age IS 'finished sync jobs of the repo older than this are pruned, the global max_age applies if NULL';
+COMMENT ON COLUMN mergestat.repo_sync_retention_overrides.max_jobs_per_repo_sync IS 'max number of finished sync jobs kept per repo sync of the repo, the global max_jobs_per_repo_sync applies if NULL';
+
+-- prunes (up to batch_size of) the finished sync jobs (and their logs) that fall outside of the retention policy of their repo,
+-- returning the number of jobs pruned. Queued and running jobs, and the latest finished job of each repo sync, are never pruned.
+CREATE OR REPLACE FUNCTION mergestat.prune_repo_sync_queue(batch_size INTEGER DEFAULT 1000)
+RETURNS INTEGER
+AS
+$$
+DECLARE _rows_deleted INTEGER;
+BEGIN
+    WITH finished AS (
+        SELECT rsq.id, COALESCE(rsq.done_at, rsq.created_at) AS finished_at,
+            COALESCE(o.max_age, r.max_age) AS max_age,
+            COALESCE(o.max_jobs_per_repo_sync, r.max_jobs_per_repo_sync) AS max_jobs,
+            ROW_NUMBER() OVER (PARTITION BY rsq.repo_sync_id ORDER BY rsq.id DESC) AS n
+        FROM mergestat.repo_sync_queue rsq
+        INNER JOIN mergestat.repo_syncs rs ON rs.id = rsq.repo_sync_id
+        LEFT JOIN mergestat.repo_sync_retention_overrides o ON o.repo_id = rs.repo_id
+        LEFT JOIN mergestat.repo_sync_retention r ON true
+        WHERE rsq.status IN ('DONE', 'DEAD', 'TIMED_OUT', 'SKIPPED')
+    )
+    DELETE FROM mergestat.repo_sync_queue WHERE id IN (
+        SELECT id FROM finished
+        WHERE n > 1 AND ((max_age IS NOT NULL AND finished_at < now() - max_age) OR (max_jobs IS NOT NULL AND n > max_jobs))
+        LIMIT batch_size
+    );
+    GET DIAGNOSTICS _rows_deleted = ROW_COUNT;
+
+    RETURN _rows_deleted;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
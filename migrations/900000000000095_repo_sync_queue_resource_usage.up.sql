@@ -0,0 +1,13 @@
+BEGIN;
+
+-- the worker records the resources used by each sync job, so that operators can tell which repos and sync types are the
+-- most expensive to sync. Jobs share the worker process, so memory and CPU time include those of other jobs running at the same time.
+ALTER TABLE mergestat.repo_sync_queue ADD COLUMN IF NOT EXISTS cpu_time_ms BIGINT;
+ALTER TABLE mergestat.repo_sync_queue ADD COLUMN IF NOT EXISTS peak_memory_bytes BIGINT;
+ALTER TABLE mergestat.repo_sync_queue ADD COLUMN IF NOT EXISTS peak_temp_disk_bytes BIGINT;
+
+COMMENT ON COLUMN mergestat.repo_sync_queue.cpu_time_ms IS 'CPU time (in milliseconds) used by the worker process, and the processes it spawned, while the sync job ran';
+COMMENT ON COLUMN mergestat.repo_sync_queue.peak_memory_bytes IS 'peak memory used by the worker process while the sync job ran';
+COMMENT ON COLUMN mergestat.repo_sync_queue.peak_temp_disk_bytes IS 'peak disk space used by the scratch dirs of the sync job (e.g. the clone of its repo)';
+
+COMMIT;
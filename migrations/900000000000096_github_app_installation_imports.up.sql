@@ -0,0 +1,10 @@
+BEGIN;
+
+-- imports of type GITHUB_APP_INSTALLATION import the repos a GitHub App installation has been granted access to (using a
+-- GITHUB_APP credential of the import's provider). Repos the app loses access to are paused, rather than deleted (unless
+-- the import removes deleted repos), and resumed once access is granted again.
+INSERT INTO mergestat.repo_import_types (type, description)
+VALUES ('GITHUB_APP_INSTALLATION', 'Import all repos a GitHub App installation has access to')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,64 @@
+BEGIN;
+
+-- the worker tracks the repos that can't be reached (i.e. that don't exist anymore, or that access to is denied). Once a
+-- repo fails to be reached a number of times in a row, it's marked as UNREACHABLE and its syncs are paused, rather than
+-- jobs of a repo that will never succeed being retried over and over. Once resumed, a sync of the repo that succeeds
+-- marks it as REACHABLE again.
+CREATE TABLE IF NOT EXISTS mergestat.repo_reachability (
+    repo_id uuid NOT NULL PRIMARY KEY REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    status text DEFAULT 'REACHABLE' NOT NULL CHECK (status IN ('REACHABLE', 'UNREACHABLE')),
+    consecutive_failures integer DEFAULT 0 NOT NULL,
+    last_error text,
+    last_failed_at timestamp with time zone
+);
+
+COMMENT ON TABLE mergestat.repo_reachability IS 'reachability of repos, tracked by the worker to pause the syncs of repos that do not exist anymore or that access to is denied';
+COMMENT ON COLUMN mergestat.repo_reachability.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN mergestat.repo_reachability.status IS 'UNREACHABLE once the repo failed to be reached too many times in a row (its syncs are paused then), REACHABLE otherwise';
+COMMENT ON COLUMN mergestat.repo_reachability.consecutive_failures IS 'number of sync jobs of the repo in a row that failed to reach it';
+COMMENT ON COLUMN mergestat.repo_reachability.last_error IS 'error of the last sync job that failed to reach the repo';
+COMMENT ON COLUMN mergestat.repo_reachability.last_failed_at IS 'timestamp of the last sync job that failed to reach the repo';
+
+CREATE OR REPLACE FUNCTION mergestat.record_repo_unreachable(repo_id_param UUID, error_param TEXT, threshold_param INTEGER)
+RETURNS BOOLEAN
+LANGUAGE PLPGSQL VOLATILE
+AS $$
+DECLARE
+    failures INTEGER;
+BEGIN
+    INSERT INTO mergestat.repo_reachability AS r (repo_id, consecutive_failures, last_error, last_failed_at)
+    VALUES (repo_id_param, 1, error_param, now())
+    ON CONFLICT (repo_id) DO UPDATE SET
+        consecutive_failures = r.consecutive_failures + 1, last_error = excluded.last_error, last_failed_at = excluded.last_failed_at
+    RETURNING consecutive_failures INTO failures;
+
+    IF threshold_param <= 0 OR failures < threshold_param THEN
+        RETURN FALSE;
+    END IF;
+
+    UPDATE mergestat.repo_reachability SET status = 'UNREACHABLE' WHERE repo_id = repo_id_param AND status <> 'UNREACHABLE';
+    IF NOT FOUND THEN
+        RETURN FALSE;
+    END IF;
+
+    -- a repo that's paused already (e.g. by hand) keeps the reason it was paused for
+    INSERT INTO mergestat.repo_sync_pauses (repo_id, reason) VALUES (repo_id_param, 'repo is unreachable')
+    ON CONFLICT (repo_id) DO NOTHING;
+
+    RETURN TRUE;
+END; $$;
+
+CREATE OR REPLACE FUNCTION mergestat.mark_repo_reachable(repo_id_param UUID)
+RETURNS BOOLEAN
+LANGUAGE SQL VOLATILE
+AS $$
+    UPDATE mergestat.repo_reachability SET status = 'REACHABLE', consecutive_failures = 0
+    WHERE repo_id = repo_id_param AND (status <> 'REACHABLE' OR consecutive_failures > 0);
+    DELETE FROM mergestat.repo_sync_pauses WHERE repo_id = repo_id_param AND reason = 'repo is unreachable';
+    SELECT TRUE;
+$$;
+
+COMMENT ON FUNCTION mergestat.record_repo_unreachable(UUID, TEXT, INTEGER) IS 'records a failure to reach a repo, marking it as UNREACHABLE and pausing its syncs once it failed threshold times in a row (returning true if it did)';
+COMMENT ON FUNCTION mergestat.mark_repo_reachable(UUID) IS 'marks a repo as REACHABLE again, resuming its syncs if they were paused as it was unreachable';
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+
+-- notifies the mergestat_repo_sync_logs channel whenever lines are logged by a sync job, with the id of the job as the
+-- payload, so that the logs can be streamed (see package logstream) rather than polled. Notifications of the same job
+-- in a transaction are collapsed into one by Postgres, and listeners fetch the new lines from the table itself.
+CREATE OR REPLACE FUNCTION mergestat.repo_sync_logs_notify_trigger() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+	PERFORM pg_notify('mergestat_repo_sync_logs', NEW.repo_sync_queue_id::TEXT);
+	RETURN NULL;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS repo_sync_logs_notify_trigger ON mergestat.repo_sync_logs;
+CREATE TRIGGER repo_sync_logs_notify_trigger AFTER INSERT ON mergestat.repo_sync_logs FOR EACH ROW EXECUTE FUNCTION mergestat.repo_sync_logs_notify_trigger();
+
+COMMIT;
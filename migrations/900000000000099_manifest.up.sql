@@ -0,0 +1,15 @@
+BEGIN;
+
+-- the repos added by the declarative sync configuration file (see package manifest), so that only those are pruned
+-- when removed from the file, never the repos added through the app or the CLI
+CREATE TABLE IF NOT EXISTS mergestat.manifest_repos (
+    repo_id UUID PRIMARY KEY REFERENCES public.repos(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+COMMENT ON TABLE mergestat.manifest_repos IS 'Repos added by the declarative sync configuration file of the worker (MANIFEST_PATH)';
+
+-- the digest (an HMAC keyed with ENCRYPTION_SECRET) of a credential added by the declarative sync configuration file,
+-- so that it's only added once, and removed when dropped from the file. NULL for credentials added otherwise.
+ALTER TABLE mergestat.service_auth_credentials ADD COLUMN IF NOT EXISTS manifest_digest TEXT;
+
+COMMIT;
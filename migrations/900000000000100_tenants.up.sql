@@ -0,0 +1,88 @@
+BEGIN;
+
+-- tenants (e.g. teams) sharing a deployment. A provider belongs to a tenant, and so do its repos and credentials, as
+-- well as the sync jobs (and logs) of its repos, which is kept up to date by the triggers below, so that the tenant is
+-- only ever set on providers. A worker can be pinned to a tenant (see TENANT), only running the sync jobs of its repos.
+CREATE TABLE IF NOT EXISTS mergestat.tenants (
+    name TEXT PRIMARY KEY,
+    concurrent_syncs INTEGER CHECK (concurrent_syncs > 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+
+COMMENT ON TABLE mergestat.tenants IS 'tenants (e.g. teams) sharing a deployment, with isolated providers, repos and credentials';
+COMMENT ON COLUMN mergestat.tenants.concurrent_syncs IS 'max number of sync jobs of the tenant running at a time, across all workers (no limit if NULL)';
+
+INSERT INTO mergestat.tenants (name) VALUES ('default') ON CONFLICT DO NOTHING;
+
+ALTER TABLE mergestat.providers ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT 'default' REFERENCES mergestat.tenants(name) ON UPDATE CASCADE ON DELETE RESTRICT;
+ALTER TABLE public.repos ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT 'default' REFERENCES mergestat.tenants(name) ON UPDATE CASCADE ON DELETE RESTRICT;
+ALTER TABLE mergestat.service_auth_credentials ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT 'default' REFERENCES mergestat.tenants(name) ON UPDATE CASCADE ON DELETE RESTRICT;
+ALTER TABLE mergestat.repo_sync_queue ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT 'default';
+ALTER TABLE mergestat.repo_sync_logs ADD COLUMN IF NOT EXISTS tenant TEXT NOT NULL DEFAULT 'default';
+
+COMMENT ON COLUMN mergestat.providers.tenant IS 'tenant the provider (and its repos and credentials) belongs to';
+COMMENT ON COLUMN public.repos.tenant IS 'tenant the repo belongs to, i.e. the tenant of its provider';
+COMMENT ON COLUMN mergestat.service_auth_credentials.tenant IS 'tenant the credential belongs to, i.e. the tenant of its provider';
+COMMENT ON COLUMN mergestat.repo_sync_queue.tenant IS 'tenant the job belongs to, i.e. the tenant of its repo when enqueued';
+COMMENT ON COLUMN mergestat.repo_sync_logs.tenant IS 'tenant the line belongs to, i.e. the tenant of its job';
+
+CREATE INDEX IF NOT EXISTS idx_repo_sync_queue_tenant_status ON mergestat.repo_sync_queue (tenant, status) WHERE status IN ('QUEUED', 'RUNNING');
+
+-- repos and credentials belong to the tenant of their provider
+CREATE OR REPLACE FUNCTION mergestat.set_tenant_of_provider_trigger() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    NEW.tenant := COALESCE((SELECT tenant FROM mergestat.providers WHERE id = NEW.provider), 'default');
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS set_tenant_trigger ON public.repos;
+CREATE TRIGGER set_tenant_trigger BEFORE INSERT OR UPDATE OF provider, tenant ON public.repos FOR EACH ROW EXECUTE FUNCTION mergestat.set_tenant_of_provider_trigger();
+
+DROP TRIGGER IF EXISTS set_tenant_trigger ON mergestat.service_auth_credentials;
+CREATE TRIGGER set_tenant_trigger BEFORE INSERT OR UPDATE OF provider, tenant ON mergestat.service_auth_credentials FOR EACH ROW EXECUTE FUNCTION mergestat.set_tenant_of_provider_trigger();
+
+-- moving a provider to another tenant moves its repos and credentials along with it
+CREATE OR REPLACE FUNCTION mergestat.providers_tenant_update_trigger() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    UPDATE public.repos SET tenant = NEW.tenant WHERE provider = NEW.id AND tenant <> NEW.tenant;
+    UPDATE mergestat.service_auth_credentials SET tenant = NEW.tenant WHERE provider = NEW.id AND tenant <> NEW.tenant;
+    RETURN NULL;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS providers_tenant_update_trigger ON mergestat.providers;
+CREATE TRIGGER providers_tenant_update_trigger AFTER UPDATE OF tenant ON mergestat.providers FOR EACH ROW WHEN (OLD.tenant IS DISTINCT FROM NEW.tenant) EXECUTE FUNCTION mergestat.providers_tenant_update_trigger();
+
+-- sync jobs belong to the tenant of their repo, and lines of logs to the tenant of their job
+CREATE OR REPLACE FUNCTION mergestat.repo_sync_queue_set_tenant_trigger() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    NEW.tenant := COALESCE((
+        SELECT r.tenant FROM mergestat.repo_syncs rs INNER JOIN public.repos r ON r.id = rs.repo_id WHERE rs.id = NEW.repo_sync_id
+    ), 'default');
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS set_tenant_trigger ON mergestat.repo_sync_queue;
+CREATE TRIGGER set_tenant_trigger BEFORE INSERT ON mergestat.repo_sync_queue FOR EACH ROW EXECUTE FUNCTION mergestat.repo_sync_queue_set_tenant_trigger();
+
+CREATE OR REPLACE FUNCTION mergestat.repo_sync_logs_set_tenant_trigger() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    NEW.tenant := COALESCE((SELECT tenant FROM mergestat.repo_sync_queue WHERE id = NEW.repo_sync_queue_id), 'default');
+    RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS set_tenant_trigger ON mergestat.repo_sync_logs;
+CREATE TRIGGER set_tenant_trigger BEFORE INSERT ON mergestat.repo_sync_logs FOR EACH ROW EXECUTE FUNCTION mergestat.repo_sync_logs_set_tenant_trigger();
+
+COMMIT;
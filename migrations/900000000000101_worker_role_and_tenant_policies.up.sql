@@ -0,0 +1,91 @@
+BEGIN;
+
+-- a least-privilege role for the database users of workers, granted only what the syncer needs: reading and writing
+-- rows (of the synced tables, and of the mergestat and sqlq schemas), but not creating, altering, dropping or truncating
+-- tables. A worker using it has to be run with SKIP_MIGRATIONS, as migrations are applied by the owner of the schema.
+DO $$
+BEGIN
+    CREATE ROLE mergestat_role_worker;
+    EXCEPTION WHEN duplicate_object THEN RAISE NOTICE '%, skipping', SQLERRM USING ERRCODE = SQLSTATE;
+END
+$$;
+
+GRANT USAGE ON SCHEMA public TO mergestat_role_worker;
+GRANT USAGE ON SCHEMA mergestat TO mergestat_role_worker;
+GRANT USAGE ON SCHEMA sqlq TO mergestat_role_worker;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA public TO mergestat_role_worker;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA mergestat TO mergestat_role_worker;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA sqlq TO mergestat_role_worker;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA public TO mergestat_role_worker;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA mergestat TO mergestat_role_worker;
+GRANT USAGE, SELECT ON ALL SEQUENCES IN SCHEMA sqlq TO mergestat_role_worker;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO mergestat_role_worker;
+ALTER DEFAULT PRIVILEGES IN SCHEMA mergestat GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO mergestat_role_worker;
+ALTER DEFAULT PRIVILEGES IN SCHEMA sqlq GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO mergestat_role_worker;
+ALTER DEFAULT PRIVILEGES IN SCHEMA public GRANT USAGE, SELECT ON SEQUENCES TO mergestat_role_worker;
+ALTER DEFAULT PRIVILEGES IN SCHEMA mergestat GRANT USAGE, SELECT ON SEQUENCES TO mergestat_role_worker;
+ALTER DEFAULT PRIVILEGES IN SCHEMA sqlq GRANT USAGE, SELECT ON SEQUENCES TO mergestat_role_worker;
+
+-- the database users of workers pinned to a tenant, which only ever see (and write) the rows of that tenant
+CREATE TABLE IF NOT EXISTS mergestat.worker_users (
+    username NAME PRIMARY KEY,
+    tenant TEXT NOT NULL REFERENCES mergestat.tenants(name) ON UPDATE CASCADE ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now()
+);
+COMMENT ON TABLE mergestat.worker_users IS 'database users of workers pinned to a tenant, whose rows are the only ones they can read or write';
+
+-- workers can't change the tenant they're pinned to, nor create tenants
+REVOKE INSERT, UPDATE, DELETE ON mergestat.worker_users FROM mergestat_role_worker;
+REVOKE INSERT, UPDATE, DELETE ON mergestat.tenants FROM mergestat_role_worker;
+
+CREATE OR REPLACE FUNCTION mergestat.worker_tenant() RETURNS TEXT
+LANGUAGE SQL STABLE
+AS $$
+    SELECT tenant FROM mergestat.worker_users WHERE username = current_user;
+$$;
+COMMENT ON FUNCTION mergestat.worker_tenant() IS 'tenant the current database user is pinned to as a worker, NULL if it is not';
+
+-- the row-level security policies of the tables with a tenant: the rows of every tenant are visible to all roles (as
+-- before), but workers pinned to a tenant are restricted to the rows of their tenant. Policies are generated for each
+-- table, so that a table getting a tenant column later on only needs to be added to the list.
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['mergestat.providers', 'public.repos', 'mergestat.service_auth_credentials', 'mergestat.repo_sync_queue', 'mergestat.repo_sync_logs'] LOOP
+        EXECUTE FORMAT('ALTER TABLE %s ENABLE ROW LEVEL SECURITY', t);
+
+        EXECUTE FORMAT('DROP POLICY IF EXISTS tenant_all_access ON %s', t);
+        EXECUTE FORMAT('CREATE POLICY tenant_all_access ON %s FOR ALL USING (TRUE) WITH CHECK (TRUE)', t);
+
+        EXECUTE FORMAT('DROP POLICY IF EXISTS tenant_worker_access ON %s', t);
+        EXECUTE FORMAT('CREATE POLICY tenant_worker_access ON %s AS RESTRICTIVE FOR ALL TO mergestat_role_worker '
+            'USING (mergestat.worker_tenant() IS NULL OR tenant = mergestat.worker_tenant()) '
+            'WITH CHECK (mergestat.worker_tenant() IS NULL OR tenant = mergestat.worker_tenant())', t);
+    END LOOP;
+END
+$$;
+
+-- creates (or updates) the database user of a worker, granting it the worker role, and pinning it to the given tenant
+-- (or unpinning it, if NULL)
+CREATE OR REPLACE FUNCTION mergestat.create_worker_user(username NAME, password TEXT, tenant TEXT DEFAULT NULL)
+RETURNS SMALLINT AS
+$BODY$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_catalog.pg_roles WHERE rolname = username) THEN
+        EXECUTE FORMAT('ALTER USER %I WITH LOGIN PASSWORD %L', username, password);
+    ELSE
+        EXECUTE FORMAT('CREATE USER %I WITH LOGIN PASSWORD %L', username, password);
+    END IF;
+    EXECUTE FORMAT('GRANT mergestat_role_worker TO %I', username);
+
+    DELETE FROM mergestat.worker_users wu WHERE wu.username = create_worker_user.username;
+    IF tenant IS NOT NULL THEN
+        INSERT INTO mergestat.worker_users (username, tenant) VALUES (create_worker_user.username, create_worker_user.tenant);
+    END IF;
+    RETURN 1;
+END;
+$BODY$
+LANGUAGE plpgsql VOLATILE;
+
+COMMIT;
@@ -0,0 +1,28 @@
+BEGIN;
+
+-- syncs merge the rows they fetch into their tables (matching rows on the primary key of the table) rather than deleting
+-- all the rows of the repo and copying them again. Rows that aren't fetched anymore (e.g. of a removed ref or PR) are
+-- marked as deleted with _deleted_at rather than deleted, so that their history is kept, and consumers can build
+-- slowly-changing dimensions. Rows that show up again are un-deleted. Current rows are the ones WHERE _deleted_at IS NULL.
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'git_commits', 'git_commit_stats', 'git_refs', 'git_files', 'git_blame',
+        'github_issues', 'github_pull_requests', 'github_pull_request_commits', 'github_pull_request_reviews',
+        'github_releases', 'github_release_assets', 'github_stargazers',
+        'github_code_scanning_alerts', 'github_dependabot_alerts', 'github_secret_scanning_alerts',
+        'bitbucket_pull_requests', 'bitbucket_pipelines', 'azure_pull_requests', 'azure_builds'
+    ] LOOP
+        -- some of the tables may have been dropped by 900000000000059_remove_empty_tables if they were never synced
+        IF to_regclass(FORMAT('public.%I', t)) IS NOT NULL THEN
+            EXECUTE FORMAT('ALTER TABLE public.%I ADD COLUMN IF NOT EXISTS _deleted_at TIMESTAMP WITH TIME ZONE', t);
+            EXECUTE FORMAT('COMMENT ON COLUMN public.%I._deleted_at IS %L', t,
+                'timestamp of when the row was found to be removed by a sync, NULL while it is current');
+        END IF;
+    END LOOP;
+END
+$$;
+
+COMMIT;
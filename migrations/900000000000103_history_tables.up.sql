@@ -0,0 +1,97 @@
+BEGIN;
+
+-- opt-in change-data-capture of the synced tables: once enabled for a table (with mergestat.enable_history), every
+-- version of its rows is appended to a <table>_history table, valid from the time it was written until the time it
+-- changed or was removed (valid_to is NULL for current versions). For instance, the branches that existed a month ago:
+--
+--   SELECT * FROM git_refs_history
+--   WHERE type = 'branch' AND valid_from <= now() - INTERVAL '1 month' AND (valid_to IS NULL OR valid_to > now() - INTERVAL '1 month');
+--
+-- Versions are matched on the primary key of the table. Rows marked as deleted (see _deleted_at) close their version.
+CREATE OR REPLACE FUNCTION mergestat.history_trigger() RETURNS trigger
+LANGUAGE plpgsql SECURITY DEFINER
+SET search_path = pg_catalog, public
+AS $$
+DECLARE
+    history TEXT := format('%I.%I', TG_TABLE_SCHEMA, TG_TABLE_NAME || '_history');
+    matches TEXT[];
+    key TEXT;
+BEGIN
+    -- the key columns of the table are the arguments of the trigger
+    IF TG_OP IN ('UPDATE', 'DELETE') THEN
+        FOREACH key IN ARRAY TG_ARGV LOOP
+            matches := matches || format('h.%1$I = ($1).%1$I', key);
+        END LOOP;
+        EXECUTE format('UPDATE %s h SET valid_to = now() WHERE h.valid_to IS NULL AND %s', history, array_to_string(matches, ' AND ')) USING OLD;
+    END IF;
+
+    -- columns are copied by name, so that a column added to the table later on doesn't break its history
+    IF TG_OP IN ('INSERT', 'UPDATE') AND to_jsonb(NEW)->>'_deleted_at' IS NULL THEN
+        EXECUTE format('INSERT INTO %1$s SELECT * FROM jsonb_populate_record(NULL::%1$s, to_jsonb($1) || jsonb_build_object(''valid_from'', now()))', history) USING NEW;
+    END IF;
+
+    RETURN NULL;
+END;
+$$;
+
+-- enables the history of the given (public) table, creating its history table if missing (and adding the columns added
+-- to the table since). Once (re-)enabled, the history starts from the current rows of the table.
+CREATE OR REPLACE FUNCTION mergestat.enable_history(table_name TEXT) RETURNS VOID
+LANGUAGE plpgsql VOLATILE
+AS $$
+DECLARE
+    tbl REGCLASS := format('public.%I', table_name)::REGCLASS;
+    history TEXT := table_name || '_history';
+    keys TEXT[];
+    col RECORD;
+BEGIN
+    SELECT array_agg(a.attname::TEXT ORDER BY a.attnum) INTO keys
+    FROM pg_catalog.pg_index i INNER JOIN pg_catalog.pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+    WHERE i.indrelid = tbl AND i.indisprimary;
+    IF keys IS NULL THEN
+        RAISE EXCEPTION 'table public.% has no primary key to track the history of its rows with', table_name;
+    END IF;
+
+    EXECUTE format('CREATE TABLE IF NOT EXISTS public.%I (LIKE public.%I, valid_from TIMESTAMP WITH TIME ZONE NOT NULL, valid_to TIMESTAMP WITH TIME ZONE)', history, table_name);
+    FOR col IN SELECT a.attname, format_type(a.atttypid, a.atttypmod) AS type FROM pg_catalog.pg_attribute a
+        WHERE a.attrelid = tbl AND a.attnum > 0 AND NOT a.attisdropped
+    LOOP
+        EXECUTE format('ALTER TABLE public.%I ADD COLUMN IF NOT EXISTS %I %s', history, col.attname, col.type);
+    END LOOP;
+
+    EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON public.%I (%s) WHERE valid_to IS NULL', history || '_current_idx', history,
+        (SELECT string_agg(format('%I', k), ', ') FROM unnest(keys) k));
+    EXECUTE format('CREATE INDEX IF NOT EXISTS %I ON public.%I (repo_id, valid_from, valid_to)', history || '_valid_idx', history);
+    EXECUTE format('COMMENT ON TABLE public.%I IS %L', history, format('history of the rows of %s, see mergestat.enable_history', table_name));
+
+    IF EXISTS (SELECT 1 FROM pg_catalog.pg_trigger WHERE tgrelid = tbl AND tgname = 'mergestat_history_trigger') THEN
+        RETURN;
+    END IF;
+
+    -- changes made while the history was disabled weren't tracked, so the versions still open are closed, and the
+    -- history starts over from the current rows
+    EXECUTE format('UPDATE public.%I SET valid_to = now() WHERE valid_to IS NULL', history);
+    EXECUTE format('INSERT INTO public.%1$I SELECT h.* FROM public.%2$I t, jsonb_populate_record(NULL::public.%1$I, to_jsonb(t) || jsonb_build_object(''valid_from'', now())) h
+        WHERE to_jsonb(t)->>''_deleted_at'' IS NULL', history, table_name);
+
+    EXECUTE format('CREATE TRIGGER mergestat_history_trigger AFTER INSERT OR UPDATE OR DELETE ON public.%I FOR EACH ROW EXECUTE FUNCTION mergestat.history_trigger(%s)',
+        table_name, (SELECT string_agg(format('%L', k), ', ') FROM unnest(keys) k));
+END;
+$$;
+
+-- disables the history of the given (public) table, keeping its history table unless drop_history is set
+CREATE OR REPLACE FUNCTION mergestat.disable_history(table_name TEXT, drop_history BOOLEAN DEFAULT FALSE) RETURNS VOID
+LANGUAGE plpgsql VOLATILE
+AS $$
+BEGIN
+    EXECUTE format('DROP TRIGGER IF EXISTS mergestat_history_trigger ON public.%I', table_name);
+    IF drop_history THEN
+        EXECUTE format('DROP TABLE IF EXISTS public.%I', table_name || '_history');
+    END IF;
+END;
+$$;
+
+COMMENT ON FUNCTION mergestat.enable_history(TEXT) IS 'enables the history of the rows of a synced table, appended to a <table>_history table with valid_from / valid_to';
+COMMENT ON FUNCTION mergestat.disable_history(TEXT, BOOLEAN) IS 'disables the history of the rows of a synced table, dropping its history table if drop_history is set';
+
+COMMIT;
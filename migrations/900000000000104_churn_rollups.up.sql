@@ -0,0 +1,84 @@
+BEGIN;
+
+-- rollups of the churn (lines added and deleted) of the commits of a repo, per file and per author by week, refreshed by
+-- the worker after each commit stats sync (in the same transaction), so that dashboards needn't aggregate the raw commit
+-- stats. Weeks start on monday (in UTC), and are those of the author date of the commits.
+CREATE TABLE IF NOT EXISTS public.git_file_churn_weekly (
+    repo_id UUID NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE,
+    file_path TEXT NOT NULL,
+    week DATE NOT NULL,
+    commits INTEGER NOT NULL,
+    additions BIGINT NOT NULL,
+    deletions BIGINT NOT NULL,
+    _mergestat_synced_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (repo_id, file_path, week)
+);
+COMMENT ON TABLE public.git_file_churn_weekly IS 'churn of the files of a repo by week, refreshed after each commit stats sync';
+COMMENT ON COLUMN public.git_file_churn_weekly.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_file_churn_weekly.file_path IS 'path of the file';
+COMMENT ON COLUMN public.git_file_churn_weekly.week IS 'first day (monday) of the week of the author date of the commits';
+COMMENT ON COLUMN public.git_file_churn_weekly.commits IS 'number of commits modifying the file that week';
+COMMENT ON COLUMN public.git_file_churn_weekly.additions IS 'number of lines added to the file that week';
+COMMENT ON COLUMN public.git_file_churn_weekly.deletions IS 'number of lines deleted from the file that week';
+COMMENT ON COLUMN public.git_file_churn_weekly._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS public.git_author_churn_weekly (
+    repo_id UUID NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE,
+    author_email TEXT NOT NULL,
+    week DATE NOT NULL,
+    author_name TEXT NOT NULL,
+    commits INTEGER NOT NULL,
+    files INTEGER NOT NULL,
+    additions BIGINT NOT NULL,
+    deletions BIGINT NOT NULL,
+    _mergestat_synced_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (repo_id, author_email, week)
+);
+COMMENT ON TABLE public.git_author_churn_weekly IS 'churn of the authors of a repo by week, refreshed after each commit stats sync';
+COMMENT ON COLUMN public.git_author_churn_weekly.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_author_churn_weekly.author_email IS 'email of the author';
+COMMENT ON COLUMN public.git_author_churn_weekly.week IS 'first day (monday) of the week of the author date of the commits';
+COMMENT ON COLUMN public.git_author_churn_weekly.author_name IS 'name of the author (the latest one, if the author used several)';
+COMMENT ON COLUMN public.git_author_churn_weekly.commits IS 'number of commits of the author that week';
+COMMENT ON COLUMN public.git_author_churn_weekly.files IS 'number of distinct files modified by the author that week';
+COMMENT ON COLUMN public.git_author_churn_weekly.additions IS 'number of lines added by the author that week';
+COMMENT ON COLUMN public.git_author_churn_weekly.deletions IS 'number of lines deleted by the author that week';
+COMMENT ON COLUMN public.git_author_churn_weekly._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE INDEX IF NOT EXISTS idx_git_file_churn_weekly_repo_week ON public.git_file_churn_weekly (repo_id, week);
+CREATE INDEX IF NOT EXISTS idx_git_author_churn_weekly_repo_week ON public.git_author_churn_weekly (repo_id, week);
+
+-- recomputes the churn rollups of a repo out of its (current) commits and commit stats. Stats of commits that weren't
+-- synced (yet) are left out, as their author and date aren't known.
+CREATE OR REPLACE FUNCTION mergestat.refresh_churn_rollups(repo UUID) RETURNS VOID
+LANGUAGE SQL VOLATILE
+AS $$
+    DELETE FROM public.git_file_churn_weekly WHERE repo_id = repo;
+    DELETE FROM public.git_author_churn_weekly WHERE repo_id = repo;
+
+    WITH stats AS (
+        SELECT s.file_path, s.commit_hash, s.additions, s.deletions, c.author_email, c.author_name, c.author_when,
+            date_trunc('week', c.author_when AT TIME ZONE 'UTC')::DATE AS week
+        FROM public.git_commit_stats s
+            INNER JOIN public.git_commits c ON c.repo_id = s.repo_id AND c.hash = s.commit_hash
+        WHERE s.repo_id = repo AND s._deleted_at IS NULL AND c._deleted_at IS NULL
+    )
+    INSERT INTO public.git_file_churn_weekly (repo_id, file_path, week, commits, additions, deletions)
+    SELECT repo, file_path, week, COUNT(DISTINCT commit_hash), SUM(additions), SUM(deletions) FROM stats
+    GROUP BY file_path, week;
+
+    WITH stats AS (
+        SELECT s.file_path, s.commit_hash, s.additions, s.deletions, c.author_email, c.author_name, c.author_when,
+            date_trunc('week', c.author_when AT TIME ZONE 'UTC')::DATE AS week
+        FROM public.git_commit_stats s
+            INNER JOIN public.git_commits c ON c.repo_id = s.repo_id AND c.hash = s.commit_hash
+        WHERE s.repo_id = repo AND s._deleted_at IS NULL AND c._deleted_at IS NULL
+    )
+    INSERT INTO public.git_author_churn_weekly (repo_id, author_email, week, author_name, commits, files, additions, deletions)
+    SELECT repo, author_email, week, (array_agg(author_name ORDER BY author_when DESC))[1],
+        COUNT(DISTINCT commit_hash), COUNT(DISTINCT file_path), SUM(additions), SUM(deletions) FROM stats
+    GROUP BY author_email, week;
+$$;
+COMMENT ON FUNCTION mergestat.refresh_churn_rollups(UUID) IS 'recomputes the per-file and per-author weekly churn rollups of a repo';
+
+COMMIT;
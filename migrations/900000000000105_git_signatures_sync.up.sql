@@ -0,0 +1,41 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority)
+VALUES ('GIT_SIGNATURES', 'Verifies the signatures of the commits and (annotated) tags of a repo', 'Git Signatures', 2)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('git', 'GIT_SIGNATURES')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.git_signatures (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    hash text NOT NULL,
+    object_type text NOT NULL,
+    tag_name text,
+    signature_type text,
+    signed boolean NOT NULL,
+    verified boolean NOT NULL,
+    reason text NOT NULL,
+    signer text,
+    verified_by text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT git_signatures_pkey PRIMARY KEY (repo_id, hash)
+);
+
+COMMENT ON TABLE public.git_signatures IS 'signature verification status of the commits (reachable from HEAD) and annotated tags of a repo';
+COMMENT ON COLUMN public.git_signatures.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_signatures.hash IS 'hash of the commit, or of the tag object';
+COMMENT ON COLUMN public.git_signatures.object_type IS 'type of the object (commit or tag)';
+COMMENT ON COLUMN public.git_signatures.tag_name IS 'name of the tag, for tags';
+COMMENT ON COLUMN public.git_signatures.signature_type IS 'type of the signature (gpg, ssh, x509 or unknown), NULL if unsigned';
+COMMENT ON COLUMN public.git_signatures.signed IS 'boolean to determine if the object is signed';
+COMMENT ON COLUMN public.git_signatures.verified IS 'boolean to determine if the signature of the object was verified';
+COMMENT ON COLUMN public.git_signatures.reason IS 'reason of the verification status (e.g. valid, unsigned, unknown_key, invalid), as reported by GitHub when verified by GitHub';
+COMMENT ON COLUMN public.git_signatures.signer IS 'fingerprint of the GPG key, or principal of the SSH key, the object was signed with (when verified against a keyring)';
+COMMENT ON COLUMN public.git_signatures.verified_by IS 'what the signature was verified against (keyring or github), NULL if unsigned';
+COMMENT ON COLUMN public.git_signatures._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.git_signatures._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
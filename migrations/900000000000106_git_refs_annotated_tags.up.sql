@@ -0,0 +1,33 @@
+BEGIN;
+
+-- the details of annotated tags (lightweight tags have none)
+ALTER TABLE public.git_refs
+    ADD COLUMN IF NOT EXISTS tag_message TEXT,
+    ADD COLUMN IF NOT EXISTS tagger_name TEXT,
+    ADD COLUMN IF NOT EXISTS tagger_email TEXT,
+    ADD COLUMN IF NOT EXISTS tagger_when TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN public.git_refs.tag_message IS 'message of the tag, for annotated tags';
+COMMENT ON COLUMN public.git_refs.tagger_name IS 'name of the tagger, for annotated tags';
+COMMENT ON COLUMN public.git_refs.tagger_email IS 'email of the tagger, for annotated tags';
+COMMENT ON COLUMN public.git_refs.tagger_when IS 'timestamp of the tag, for annotated tags';
+
+CREATE OR REPLACE VIEW public.git_tags AS
+SELECT
+    git_refs.repo_id,
+    git_refs.full_name,
+    git_refs.hash,
+    git_refs.name,
+    git_refs.remote,
+    git_refs.target,
+    git_refs.type,
+    git_refs.tag_commit_hash,
+    git_refs._mergestat_synced_at,
+    git_refs.tag_message,
+    git_refs.tagger_name,
+    git_refs.tagger_email,
+    git_refs.tagger_when
+FROM public.git_refs
+WHERE (git_refs.type = 'tag'::text);
+
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN;
+
+-- how far each branch has diverged from the default branch (the HEAD of the repo), e.g. to report on stale branches
+ALTER TABLE public.git_refs
+    ADD COLUMN IF NOT EXISTS ahead INTEGER,
+    ADD COLUMN IF NOT EXISTS behind INTEGER;
+
+COMMENT ON COLUMN public.git_refs.ahead IS 'number of commits of the branch that are not on the default branch, for branches';
+COMMENT ON COLUMN public.git_refs.behind IS 'number of commits of the default branch that are not on the branch, for branches';
+
+CREATE OR REPLACE VIEW public.git_branches AS
+SELECT
+    git_refs.repo_id,
+    git_refs.full_name,
+    git_refs.hash,
+    git_refs.name,
+    git_refs.remote,
+    git_refs.target,
+    git_refs.type,
+    git_refs.tag_commit_hash,
+    git_refs._mergestat_synced_at,
+    git_refs.ahead,
+    git_refs.behind
+FROM public.git_refs
+WHERE (git_refs.type = 'branch'::text);
+
+COMMIT;
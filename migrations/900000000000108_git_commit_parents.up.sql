@@ -0,0 +1,26 @@
+BEGIN;
+
+-- the parents of each commit synced by GIT_COMMITS, so that the commit graph (e.g. merge topology, or first-parent
+-- history) can be queried with recursive CTEs. Incremental syncs only add the parents of new commits, the parents of
+-- commits synced before are added by the next full sync (e.g. with the fullResync setting).
+CREATE TABLE IF NOT EXISTS public.git_commit_parents (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    child_hash text NOT NULL,
+    parent_hash text NOT NULL,
+    ordinal integer NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT git_commit_parents_pkey PRIMARY KEY (repo_id, child_hash, ordinal)
+);
+
+CREATE INDEX IF NOT EXISTS idx_git_commit_parents_repo_id_parent_hash ON public.git_commit_parents (repo_id, parent_hash);
+
+COMMENT ON TABLE public.git_commit_parents IS 'parents of the git commits of a repo';
+COMMENT ON COLUMN public.git_commit_parents.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_commit_parents.child_hash IS 'hash of the commit';
+COMMENT ON COLUMN public.git_commit_parents.parent_hash IS 'hash of the parent of the commit';
+COMMENT ON COLUMN public.git_commit_parents.ordinal IS 'position of the parent among the parents of the commit, starting at 0 (the first parent)';
+COMMENT ON COLUMN public.git_commit_parents._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.git_commit_parents._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
@@ -0,0 +1,34 @@
+BEGIN;
+
+-- the trailers of the messages of the commits synced by GIT_COMMITS (e.g. Signed-off-by, Co-authored-by, or Fixes), so
+-- that commits can be attributed to all of their authors, and linked to the issues they reference. Like their parents
+-- (see git_commit_parents), the trailers of commits synced before are added by the next full sync.
+CREATE TABLE IF NOT EXISTS public.git_commit_trailers (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    commit_hash text NOT NULL,
+    ordinal integer NOT NULL,
+    key text NOT NULL,
+    value text NOT NULL,
+    name text,
+    email text,
+    issue text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT git_commit_trailers_pkey PRIMARY KEY (repo_id, commit_hash, ordinal)
+);
+
+CREATE INDEX IF NOT EXISTS idx_git_commit_trailers_repo_id_key ON public.git_commit_trailers (repo_id, key);
+
+COMMENT ON TABLE public.git_commit_trailers IS 'trailers of the messages of the git commits of a repo';
+COMMENT ON COLUMN public.git_commit_trailers.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_commit_trailers.commit_hash IS 'hash of the commit';
+COMMENT ON COLUMN public.git_commit_trailers.ordinal IS 'position of the trailer among the trailers of the commit, starting at 0';
+COMMENT ON COLUMN public.git_commit_trailers.key IS 'key of the trailer, lower-cased (e.g. co-authored-by)';
+COMMENT ON COLUMN public.git_commit_trailers.value IS 'value of the trailer';
+COMMENT ON COLUMN public.git_commit_trailers.name IS 'name of the person, for trailers whose value is a "Name <email>" identity (e.g. co-authored-by)';
+COMMENT ON COLUMN public.git_commit_trailers.email IS 'email of the person, for trailers whose value is a "Name <email>" identity (e.g. co-authored-by)';
+COMMENT ON COLUMN public.git_commit_trailers.issue IS 'issue referenced (e.g. #123, owner/repo#123, PROJ-123 or an issue URL), for trailers such as fixes, closes or refs';
+COMMENT ON COLUMN public.git_commit_trailers._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.git_commit_trailers._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
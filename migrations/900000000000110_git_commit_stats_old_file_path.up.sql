@@ -0,0 +1,10 @@
+BEGIN;
+
+-- renames are detected when diffing commits, so a renamed file is a single row (whose file_path is its new path)
+-- rather than a row of a deleted file and one of an added file
+ALTER TABLE public.git_commit_stats ADD COLUMN IF NOT EXISTS old_file_path TEXT;
+
+COMMENT ON COLUMN public.git_commit_stats.file_path IS 'path of the file the modification was made in (its new path, if the file was renamed)';
+COMMENT ON COLUMN public.git_commit_stats.old_file_path IS 'path of the file before the commit, which differs from file_path if the file was renamed';
+
+COMMIT;
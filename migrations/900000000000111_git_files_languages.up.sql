@@ -0,0 +1,41 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority)
+VALUES ('GIT_FILES_LANGUAGES', 'Detects the language of each file in the HEAD of a repo (honoring the linguist overrides of .gitattributes)', 'Git Files Languages', 2)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('git', 'GIT_FILES_LANGUAGES')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.git_files_languages (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    path text NOT NULL,
+    language text,
+    language_type text NOT NULL,
+    bytes bigint NOT NULL,
+    vendored boolean NOT NULL,
+    generated boolean NOT NULL,
+    documentation boolean NOT NULL,
+    detectable boolean NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT git_files_languages_pkey PRIMARY KEY (repo_id, path)
+);
+
+CREATE INDEX IF NOT EXISTS idx_git_files_languages_language ON public.git_files_languages (language) WHERE _deleted_at IS NULL;
+
+COMMENT ON TABLE public.git_files_languages IS 'language of each file in the HEAD of a repo, as detected by linguist (enry) rules';
+COMMENT ON COLUMN public.git_files_languages.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_files_languages.path IS 'path of the file';
+COMMENT ON COLUMN public.git_files_languages.language IS 'language of the file (or of its linguist-language override), NULL if no language was detected';
+COMMENT ON COLUMN public.git_files_languages.language_type IS 'type of the language (programming, markup, data, prose or unknown)';
+COMMENT ON COLUMN public.git_files_languages.bytes IS 'size of the file in bytes';
+COMMENT ON COLUMN public.git_files_languages.vendored IS 'boolean to determine if the file is vendored (or marked linguist-vendored)';
+COMMENT ON COLUMN public.git_files_languages.generated IS 'boolean to determine if the file is generated (or marked linguist-generated)';
+COMMENT ON COLUMN public.git_files_languages.documentation IS 'boolean to determine if the file is documentation (or marked linguist-documentation)';
+COMMENT ON COLUMN public.git_files_languages.detectable IS 'boolean to determine if the file counts towards the language breakdown of the repo';
+COMMENT ON COLUMN public.git_files_languages._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.git_files_languages._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
@@ -0,0 +1,44 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority)
+VALUES ('GIT_LICENSES', 'Detects the licenses of a repo and of its files (as SPDX identifiers), from license files and SPDX-License-Identifier tags', 'Git Licenses', 2)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('git', 'GIT_LICENSES')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.git_licenses (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    path text NOT NULL,
+    spdx_id text NOT NULL,
+    source text NOT NULL,
+    confidence double precision NOT NULL,
+    expression text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT git_licenses_pkey PRIMARY KEY (repo_id, path, spdx_id, source)
+);
+
+CREATE INDEX IF NOT EXISTS idx_git_licenses_spdx_id ON public.git_licenses (spdx_id) WHERE _deleted_at IS NULL;
+
+COMMENT ON TABLE public.git_licenses IS 'licenses of the files in the HEAD of a repo, detected from the text of license files and from SPDX-License-Identifier tags';
+COMMENT ON COLUMN public.git_licenses.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_licenses.path IS 'path of the file';
+COMMENT ON COLUMN public.git_licenses.spdx_id IS 'SPDX identifier of the license, NOASSERTION for license files whose license could not be determined';
+COMMENT ON COLUMN public.git_licenses.source IS 'what the license was detected from (license_file or spdx_identifier)';
+COMMENT ON COLUMN public.git_licenses.confidence IS 'confidence of the detection, from 0 to 1 (always 1 for SPDX-License-Identifier tags)';
+COMMENT ON COLUMN public.git_licenses.expression IS 'full SPDX license expression of the SPDX-License-Identifier tag (e.g. MIT OR Apache-2.0)';
+COMMENT ON COLUMN public.git_licenses._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.git_licenses._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+-- the licenses of a repo are the ones of the license files at its root
+CREATE OR REPLACE VIEW public.git_repo_licenses AS
+SELECT repo_id, spdx_id, MAX(confidence) AS confidence, ARRAY_AGG(path ORDER BY path) AS paths
+FROM public.git_licenses
+WHERE source = 'license_file' AND path NOT LIKE '%/%' AND _deleted_at IS NULL
+GROUP BY repo_id, spdx_id;
+
+COMMENT ON VIEW public.git_repo_licenses IS 'licenses of a repo, as detected from the license files at its root';
+
+COMMIT;
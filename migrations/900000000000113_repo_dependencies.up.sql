@@ -0,0 +1,37 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority)
+VALUES ('GIT_DEPENDENCIES', 'Parses the dependencies of the manifests and lockfiles (go.mod, package.json, Cargo.lock, etc.) of a repo', 'Git Dependencies', 2)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('git', 'GIT_DEPENDENCIES')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.repo_dependencies (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    manifest_path text NOT NULL,
+    ecosystem text NOT NULL,
+    name text NOT NULL,
+    version text NOT NULL,
+    direct boolean NOT NULL,
+    scope text NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT repo_dependencies_pkey PRIMARY KEY (repo_id, manifest_path, ecosystem, name, version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_repo_dependencies_ecosystem_name ON public.repo_dependencies (ecosystem, name) WHERE _deleted_at IS NULL;
+
+COMMENT ON TABLE public.repo_dependencies IS 'dependencies declared by the manifests, and resolved by the lockfiles, in the HEAD of a repo';
+COMMENT ON COLUMN public.repo_dependencies.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.repo_dependencies.manifest_path IS 'path of the manifest (e.g. go.mod) or lockfile (e.g. package-lock.json) the dependency is from';
+COMMENT ON COLUMN public.repo_dependencies.ecosystem IS 'package ecosystem of the dependency (go, npm, pypi, cargo, packagist or rubygems)';
+COMMENT ON COLUMN public.repo_dependencies.name IS 'name of the package';
+COMMENT ON COLUMN public.repo_dependencies.version IS 'version of the package (resolved, for lockfiles) or version constraint (of manifests), empty if not specified';
+COMMENT ON COLUMN public.repo_dependencies.direct IS 'boolean to determine if the repo depends on the package directly, rather than through another dependency';
+COMMENT ON COLUMN public.repo_dependencies.scope IS 'scope of the dependency (runtime, dev, build, peer or optional)';
+COMMENT ON COLUMN public.repo_dependencies._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.repo_dependencies._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
@@ -0,0 +1,66 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority)
+VALUES ('SYFT_REPO_SBOM', 'Generates a CycloneDX (or SPDX) SBOM of the HEAD commit of a repo with syft, and flattens its components', 'Syft Repo SBOM', 3)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('scanner', 'SYFT_REPO_SBOM')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.repo_sboms (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    commit_hash text NOT NULL,
+    format text NOT NULL,
+    spec_version text,
+    document jsonb NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT repo_sboms_pkey PRIMARY KEY (repo_id, commit_hash, format)
+);
+
+COMMENT ON TABLE public.repo_sboms IS 'SBOM documents of the commits of a repo (as of when they were HEAD), generated by syft';
+COMMENT ON COLUMN public.repo_sboms.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.repo_sboms.commit_hash IS 'hash of the commit the SBOM is a snapshot of';
+COMMENT ON COLUMN public.repo_sboms.format IS 'format of the SBOM document (cyclonedx or spdx)';
+COMMENT ON COLUMN public.repo_sboms.spec_version IS 'version of the specification of the format the document follows (e.g. 1.4, SPDX-2.2)';
+COMMENT ON COLUMN public.repo_sboms.document IS 'the SBOM document, as generated by syft';
+COMMENT ON COLUMN public.repo_sboms._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS public.repo_sbom_components (
+    repo_id uuid NOT NULL,
+    commit_hash text NOT NULL,
+    format text NOT NULL,
+    ref text NOT NULL,
+    type text,
+    name text NOT NULL,
+    version text,
+    purl text,
+    licenses text[],
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT repo_sbom_components_pkey PRIMARY KEY (repo_id, commit_hash, format, ref),
+    CONSTRAINT repo_sbom_components_sbom_fkey FOREIGN KEY (repo_id, commit_hash, format) REFERENCES public.repo_sboms(repo_id, commit_hash, format) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS idx_repo_sbom_components_purl ON public.repo_sbom_components (purl);
+
+COMMENT ON TABLE public.repo_sbom_components IS 'components (packages, in SPDX) of the SBOM documents of repo_sboms, flattened';
+COMMENT ON COLUMN public.repo_sbom_components.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.repo_sbom_components.commit_hash IS 'hash of the commit of the SBOM';
+COMMENT ON COLUMN public.repo_sbom_components.format IS 'format of the SBOM document (cyclonedx or spdx)';
+COMMENT ON COLUMN public.repo_sbom_components.ref IS 'reference of the component within the document (bom-ref in CycloneDX, SPDXID in SPDX)';
+COMMENT ON COLUMN public.repo_sbom_components.type IS 'type of the component (e.g. library, application, operating-system)';
+COMMENT ON COLUMN public.repo_sbom_components.name IS 'name of the component';
+COMMENT ON COLUMN public.repo_sbom_components.version IS 'version of the component';
+COMMENT ON COLUMN public.repo_sbom_components.purl IS 'package URL of the component';
+COMMENT ON COLUMN public.repo_sbom_components.licenses IS 'licenses (SPDX identifiers, names or expressions) of the component';
+COMMENT ON COLUMN public.repo_sbom_components._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+-- the latest SBOM of each repo (and format), e.g. to report on the components of repos as they currently are
+CREATE OR REPLACE VIEW public.repo_latest_sboms AS
+SELECT DISTINCT ON (repo_id, format) *
+FROM public.repo_sboms
+ORDER BY repo_id, format, _mergestat_synced_at DESC;
+
+COMMENT ON VIEW public.repo_latest_sboms IS 'latest SBOM document of each repo, per format';
+
+COMMIT;
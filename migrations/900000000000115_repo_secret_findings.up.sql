@@ -0,0 +1,45 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority)
+VALUES ('SECRETS_REPO_SCAN', 'Scans the files of a repo (and optionally its history) for secrets, using configurable regex and entropy rules', 'Secrets Repo Scan', 3)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('scanner', 'SECRETS_REPO_SCAN')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.repo_secret_findings (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    source text NOT NULL,
+    commit_hash text NOT NULL,
+    path text NOT NULL,
+    line integer NOT NULL,
+    start_column integer NOT NULL,
+    rule_id text NOT NULL,
+    description text,
+    redacted text NOT NULL,
+    fingerprint text NOT NULL,
+    entropy double precision NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT repo_secret_findings_pkey PRIMARY KEY (repo_id, source, commit_hash, path, line, start_column, rule_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_repo_secret_findings_fingerprint ON public.repo_secret_findings (fingerprint) WHERE _deleted_at IS NULL;
+
+COMMENT ON TABLE public.repo_secret_findings IS 'secrets found in the files of the HEAD of a repo, or added by its commits, by a secrets scan';
+COMMENT ON COLUMN public.repo_secret_findings.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.repo_secret_findings.source IS 'where the secret was found: head (in the files of HEAD) or history (in the lines added by a commit)';
+COMMENT ON COLUMN public.repo_secret_findings.commit_hash IS 'hash of the commit the secret was found in (HEAD, for findings of head)';
+COMMENT ON COLUMN public.repo_secret_findings.path IS 'path of the file the secret was found in';
+COMMENT ON COLUMN public.repo_secret_findings.line IS 'line number of the secret in the file';
+COMMENT ON COLUMN public.repo_secret_findings.start_column IS 'column of the start of the secret in its line (starting at 1)';
+COMMENT ON COLUMN public.repo_secret_findings.rule_id IS 'id of the rule that found the secret (e.g. aws-access-key-id)';
+COMMENT ON COLUMN public.repo_secret_findings.description IS 'description of the rule that found the secret';
+COMMENT ON COLUMN public.repo_secret_findings.redacted IS 'the secret, redacted but for its first few characters';
+COMMENT ON COLUMN public.repo_secret_findings.fingerprint IS 'SHA-256 hash of the secret, to track the same secret across files and commits';
+COMMENT ON COLUMN public.repo_secret_findings.entropy IS 'Shannon entropy of the secret, in bits per character';
+COMMENT ON COLUMN public.repo_secret_findings._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.repo_secret_findings._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
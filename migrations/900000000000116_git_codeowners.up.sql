@@ -0,0 +1,56 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority)
+VALUES ('GIT_CODEOWNERS', 'Parses the CODEOWNERS file (of the GitHub or GitLab syntax) of a repo into its path patterns and their owners', 'Git Codeowners', 2)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('git', 'GIT_CODEOWNERS')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.git_codeowners (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    file_path text NOT NULL,
+    line integer NOT NULL,
+    ordinal integer NOT NULL,
+    pattern text NOT NULL,
+    pattern_regex text NOT NULL,
+    owner text,
+    owner_type text,
+    section text,
+    optional boolean NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT git_codeowners_pkey PRIMARY KEY (repo_id, line, ordinal)
+);
+
+COMMENT ON TABLE public.git_codeowners IS 'path patterns of the CODEOWNERS file of a repo, and their owners (one row per owner)';
+COMMENT ON COLUMN public.git_codeowners.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_codeowners.file_path IS 'path of the CODEOWNERS file (e.g. .github/CODEOWNERS)';
+COMMENT ON COLUMN public.git_codeowners.line IS 'line number of the pattern in the file, the last pattern matching a file taking precedence';
+COMMENT ON COLUMN public.git_codeowners.ordinal IS 'position of the owner among the owners of the pattern';
+COMMENT ON COLUMN public.git_codeowners.pattern IS 'path pattern, as written in the file (e.g. /docs/ or *.go)';
+COMMENT ON COLUMN public.git_codeowners.pattern_regex IS 'regex matching the paths the pattern matches (e.g. git_files.path ~ pattern_regex)';
+COMMENT ON COLUMN public.git_codeowners.owner IS 'owner of the files matching the pattern (e.g. @org/team, @user or an email), NULL for patterns without owners';
+COMMENT ON COLUMN public.git_codeowners.owner_type IS 'type of the owner (team, user, email or role)';
+COMMENT ON COLUMN public.git_codeowners.section IS 'GitLab section of the pattern, NULL outside of sections';
+COMMENT ON COLUMN public.git_codeowners.optional IS 'boolean to determine if the approval of the owners is optional (of optional GitLab sections)';
+COMMENT ON COLUMN public.git_codeowners._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.git_codeowners._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+-- the owners of a file are the ones of the last pattern matching it (of each section, as GitLab sections apply independently)
+CREATE OR REPLACE FUNCTION mergestat.file_owners(repo UUID, path TEXT)
+RETURNS TABLE (owner TEXT, owner_type TEXT, section TEXT, optional BOOLEAN, pattern TEXT)
+LANGUAGE SQL STABLE AS $$
+    SELECT o.owner, o.owner_type, o.section, o.optional, o.pattern
+    FROM public.git_codeowners o
+    WHERE o.repo_id = repo AND o._deleted_at IS NULL AND o.owner IS NOT NULL AND o.line = (
+        SELECT MAX(m.line) FROM public.git_codeowners m
+        WHERE m.repo_id = repo AND m._deleted_at IS NULL AND m.section IS NOT DISTINCT FROM o.section AND path ~ m.pattern_regex
+    )
+    ORDER BY o.section NULLS FIRST, o.ordinal
+$$;
+
+COMMENT ON FUNCTION mergestat.file_owners(UUID, TEXT) IS 'owners of the file at the given path of a repo, according to its CODEOWNERS file';
+
+COMMIT;
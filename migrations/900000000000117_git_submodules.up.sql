@@ -0,0 +1,37 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority)
+VALUES ('GIT_SUBMODULES', 'Retrieves the submodules of a repo (their path, URL and pinned commit), optionally enqueuing their repos for syncing', 'Git Submodules', 2)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('git', 'GIT_SUBMODULES')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.git_submodules (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    path text NOT NULL,
+    name text NOT NULL,
+    url text NOT NULL,
+    resolved_url text NOT NULL,
+    branch text,
+    commit_hash text,
+    submodule_repo_id uuid REFERENCES public.repos(id) ON DELETE SET NULL ON UPDATE RESTRICT,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT git_submodules_pkey PRIMARY KEY (repo_id, path)
+);
+
+COMMENT ON TABLE public.git_submodules IS 'submodules of the HEAD of a repo';
+COMMENT ON COLUMN public.git_submodules.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_submodules.path IS 'path of the submodule in the repo';
+COMMENT ON COLUMN public.git_submodules.name IS 'name of the submodule (in .gitmodules)';
+COMMENT ON COLUMN public.git_submodules.url IS 'URL of the submodule, as declared in .gitmodules (which can be relative to the URL of the repo)';
+COMMENT ON COLUMN public.git_submodules.resolved_url IS 'URL of the submodule, resolved against the URL of the repo';
+COMMENT ON COLUMN public.git_submodules.branch IS 'branch of the submodule tracked by git submodule update --remote, if any';
+COMMENT ON COLUMN public.git_submodules.commit_hash IS 'hash of the commit the submodule is pinned to, NULL if it has no gitlink in the tree';
+COMMENT ON COLUMN public.git_submodules.submodule_repo_id IS 'id of the repo of the submodule, if it is synced (see the enqueue setting of the sync)';
+COMMENT ON COLUMN public.git_submodules._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.git_submodules._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
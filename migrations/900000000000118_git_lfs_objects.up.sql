@@ -0,0 +1,45 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority)
+VALUES ('GIT_LFS_OBJECTS', 'Retrieves the Git LFS objects of a repo (their path, OID and size), without downloading them', 'Git LFS Objects', 2)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('git', 'GIT_LFS_OBJECTS')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.git_lfs_objects (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    path text NOT NULL,
+    oid text NOT NULL,
+    size bigint NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT git_lfs_objects_pkey PRIMARY KEY (repo_id, path)
+);
+
+CREATE INDEX IF NOT EXISTS idx_git_lfs_objects_oid ON public.git_lfs_objects USING btree (repo_id, oid);
+
+COMMENT ON TABLE public.git_lfs_objects IS 'Git LFS objects of the HEAD of a repo, as referenced by its LFS pointer files';
+COMMENT ON COLUMN public.git_lfs_objects.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_lfs_objects.path IS 'path of the LFS pointer file in the repo';
+COMMENT ON COLUMN public.git_lfs_objects.oid IS 'OID of the LFS object, e.g. sha256:4d7a...';
+COMMENT ON COLUMN public.git_lfs_objects.size IS 'size of the LFS object in bytes (not of its pointer file)';
+COMMENT ON COLUMN public.git_lfs_objects._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.git_lfs_objects._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.git_lfs_usage (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    objects bigint NOT NULL,
+    size bigint NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT git_lfs_usage_pkey PRIMARY KEY (repo_id, _mergestat_synced_at)
+);
+
+COMMENT ON TABLE public.git_lfs_usage IS 'Git LFS storage usage of the HEAD of a repo, as of each of its GIT_LFS_OBJECTS syncs, to track its growth over time';
+COMMENT ON COLUMN public.git_lfs_usage.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_lfs_usage.objects IS 'number of distinct LFS objects';
+COMMENT ON COLUMN public.git_lfs_usage.size IS 'total size of the distinct LFS objects in bytes';
+COMMENT ON COLUMN public.git_lfs_usage._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+COMMIT;
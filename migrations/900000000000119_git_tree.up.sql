@@ -0,0 +1,50 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority)
+VALUES ('GIT_TREE', 'Retrieves every path of the HEAD tree of a repo (its type, mode, size and depth), without the contents of its files', 'Git Tree', 2)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('git', 'GIT_TREE')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.git_tree (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    path text NOT NULL,
+    type text NOT NULL,
+    mode text NOT NULL,
+    hash text NOT NULL,
+    size bigint NOT NULL,
+    depth integer NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT git_tree_pkey PRIMARY KEY (repo_id, path)
+);
+
+COMMENT ON TABLE public.git_tree IS 'paths (files, directories and submodules) of the HEAD tree of a repo';
+COMMENT ON COLUMN public.git_tree.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.git_tree.path IS 'path of the entry in the repo';
+COMMENT ON COLUMN public.git_tree.type IS 'git object type of the entry: blob (file), tree (directory) or commit (submodule)';
+COMMENT ON COLUMN public.git_tree.mode IS 'mode of the entry, as in git ls-tree (e.g. 100644, 100755, 120000, 040000 or 160000)';
+COMMENT ON COLUMN public.git_tree.hash IS 'hash of the object of the entry';
+COMMENT ON COLUMN public.git_tree.size IS 'size in bytes of the blob, or the total size of the blobs within the directory (0 for submodules)';
+COMMENT ON COLUMN public.git_tree.depth IS 'depth of the entry, 0 for the entries at the root of the repo';
+COMMENT ON COLUMN public.git_tree._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.git_tree._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE OR REPLACE VIEW public.git_tree_directories AS
+SELECT
+    d.repo_id,
+    d.path,
+    d.depth,
+    d.size,
+    (SELECT COUNT(*) FROM public.git_tree f WHERE f.repo_id = d.repo_id AND f.type = 'blob' AND f._deleted_at IS NULL AND f.path LIKE d.path || '/%') AS files,
+    d.size::double precision / NULLIF((SELECT SUM(r.size) FROM public.git_tree r WHERE r.repo_id = d.repo_id AND r.type = 'blob' AND r._deleted_at IS NULL), 0) AS share
+FROM public.git_tree d
+WHERE d.type = 'tree' AND d._deleted_at IS NULL;
+
+COMMENT ON VIEW public.git_tree_directories IS 'size breakdown of the directories of the HEAD tree of a repo';
+COMMENT ON COLUMN public.git_tree_directories.files IS 'number of files within the directory (at any depth)';
+COMMENT ON COLUMN public.git_tree_directories.share IS 'fraction of the size of the repo (of the size of all its files) within the directory';
+
+COMMIT;
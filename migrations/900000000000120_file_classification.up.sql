@@ -0,0 +1,23 @@
+BEGIN;
+
+-- binary, generated and vendored files are flagged by the GIT_FILES and GIT_TREE syncs (honoring the binary, text and
+-- linguist-* attributes of .gitattributes), so that analytics can exclude them, e.g. WHERE NOT (generated OR vendored)
+ALTER TABLE public.git_files
+    ADD COLUMN IF NOT EXISTS binary boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS generated boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS vendored boolean NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN public.git_files.binary IS 'boolean to determine if the file is binary';
+COMMENT ON COLUMN public.git_files.generated IS 'boolean to determine if the file is generated (e.g. minified, or marked linguist-generated)';
+COMMENT ON COLUMN public.git_files.vendored IS 'boolean to determine if the file is vendored (e.g. in node_modules/, or marked linguist-vendored)';
+
+ALTER TABLE public.git_tree
+    ADD COLUMN IF NOT EXISTS binary boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS generated boolean NOT NULL DEFAULT false,
+    ADD COLUMN IF NOT EXISTS vendored boolean NOT NULL DEFAULT false;
+
+COMMENT ON COLUMN public.git_tree.binary IS 'boolean to determine if the file is binary';
+COMMENT ON COLUMN public.git_tree.generated IS 'boolean to determine if the file is generated (e.g. minified, or marked linguist-generated)';
+COMMENT ON COLUMN public.git_tree.vendored IS 'boolean to determine if the file (or directory) is vendored (e.g. in node_modules/, or marked linguist-vendored)';
+
+COMMIT;
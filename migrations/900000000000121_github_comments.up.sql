@@ -0,0 +1,104 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_ISSUE_COMMENTS', 'Retrieves the comments of the issues of a GitHub repo', 'GitHub Issue Comments', 2, 'GITHUB'),
+       ('GITHUB_PR_COMMENTS', 'Retrieves the (non-review) comments of the PRs of a GitHub repo', 'GitHub PR Comments', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_ISSUE_COMMENTS'), ('github', 'GITHUB_PR_COMMENTS')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.github_issue_comments (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    issue_number integer NOT NULL,
+    id bigint NOT NULL,
+    author_login text,
+    author_association text,
+    body text,
+    reaction_count integer NOT NULL DEFAULT 0,
+    reactions jsonb NOT NULL DEFAULT '{}'::jsonb,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_issue_comments_pkey PRIMARY KEY (repo_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_issue_comments_issue_number ON public.github_issue_comments USING btree (repo_id, issue_number);
+
+COMMENT ON TABLE public.github_issue_comments IS 'comments of the issues of a GitHub repo';
+COMMENT ON COLUMN public.github_issue_comments.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_issue_comments.issue_number IS 'number of the issue the comment was made on';
+COMMENT ON COLUMN public.github_issue_comments.id IS 'GitHub id of the comment';
+COMMENT ON COLUMN public.github_issue_comments.author_login IS 'login of the author of the comment';
+COMMENT ON COLUMN public.github_issue_comments.author_association IS 'association of the author with the repo, e.g. MEMBER, CONTRIBUTOR or NONE';
+COMMENT ON COLUMN public.github_issue_comments.body IS 'body of the comment';
+COMMENT ON COLUMN public.github_issue_comments.reaction_count IS 'total number of reactions to the comment';
+COMMENT ON COLUMN public.github_issue_comments.reactions IS 'number of reactions to the comment of each kind (of those with any), e.g. {"+1": 2, "heart": 1}';
+COMMENT ON COLUMN public.github_issue_comments.created_at IS 'timestamp of when the comment was created';
+COMMENT ON COLUMN public.github_issue_comments.updated_at IS 'timestamp of when the comment was last updated';
+COMMENT ON COLUMN public.github_issue_comments.url IS 'URL of the comment';
+COMMENT ON COLUMN public.github_issue_comments._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_issue_comments._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.github_pull_request_comments (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    pr_number integer NOT NULL,
+    id bigint NOT NULL,
+    author_login text,
+    author_association text,
+    body text,
+    reaction_count integer NOT NULL DEFAULT 0,
+    reactions jsonb NOT NULL DEFAULT '{}'::jsonb,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_pull_request_comments_pkey PRIMARY KEY (repo_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_pull_request_comments_pr_number ON public.github_pull_request_comments USING btree (repo_id, pr_number);
+
+COMMENT ON TABLE public.github_pull_request_comments IS 'comments of the PRs (but for the comments of their reviews) of a GitHub repo';
+COMMENT ON COLUMN public.github_pull_request_comments.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_pull_request_comments.pr_number IS 'number of the PR the comment was made on';
+COMMENT ON COLUMN public.github_pull_request_comments.id IS 'GitHub id of the comment';
+COMMENT ON COLUMN public.github_pull_request_comments.author_login IS 'login of the author of the comment';
+COMMENT ON COLUMN public.github_pull_request_comments.author_association IS 'association of the author with the repo, e.g. MEMBER, CONTRIBUTOR or NONE';
+COMMENT ON COLUMN public.github_pull_request_comments.body IS 'body of the comment';
+COMMENT ON COLUMN public.github_pull_request_comments.reaction_count IS 'total number of reactions to the comment';
+COMMENT ON COLUMN public.github_pull_request_comments.reactions IS 'number of reactions to the comment of each kind (of those with any), e.g. {"+1": 2, "heart": 1}';
+COMMENT ON COLUMN public.github_pull_request_comments.created_at IS 'timestamp of when the comment was created';
+COMMENT ON COLUMN public.github_pull_request_comments.updated_at IS 'timestamp of when the comment was last updated';
+COMMENT ON COLUMN public.github_pull_request_comments.url IS 'URL of the comment';
+COMMENT ON COLUMN public.github_pull_request_comments._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_pull_request_comments._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+-- the first response to each issue and PR (the first comment of anyone but its author), to measure responsiveness
+CREATE OR REPLACE VIEW public.github_first_responses AS
+SELECT i.repo_id, 'issue' AS kind, i.number, i.author_login, i.created_at, r.author_login AS responder_login, r.created_at AS responded_at,
+    r.created_at - i.created_at AS time_to_response
+FROM public.github_issues i
+    LEFT JOIN LATERAL (
+        SELECT c.author_login, c.created_at FROM public.github_issue_comments c
+        WHERE c.repo_id = i.repo_id AND c.issue_number = i.number AND c._deleted_at IS NULL AND c.author_login IS DISTINCT FROM i.author_login
+        ORDER BY c.created_at LIMIT 1
+    ) r ON TRUE
+WHERE i._deleted_at IS NULL
+UNION ALL
+SELECT p.repo_id, 'pull_request' AS kind, p.number, p.author_login, p.created_at, r.author_login AS responder_login, r.created_at AS responded_at,
+    r.created_at - p.created_at AS time_to_response
+FROM public.github_pull_requests p
+    LEFT JOIN LATERAL (
+        SELECT c.author_login, c.created_at FROM public.github_pull_request_comments c
+        WHERE c.repo_id = p.repo_id AND c.pr_number = p.number AND c._deleted_at IS NULL AND c.author_login IS DISTINCT FROM p.author_login
+        ORDER BY c.created_at LIMIT 1
+    ) r ON TRUE
+WHERE p._deleted_at IS NULL;
+
+COMMENT ON VIEW public.github_first_responses IS 'first response (comment of anyone but the author) to the issues and PRs of a GitHub repo, NULL if none yet';
+
+COMMIT;
@@ -0,0 +1,102 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_CHECKS', 'Retrieves the check runs and commit statuses of the latest commits of a GitHub repo, and of the head commits of its open PRs', 'GitHub Checks', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_CHECKS')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.github_check_runs (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id bigint NOT NULL,
+    commit_sha text NOT NULL,
+    name text,
+    status text,
+    conclusion text,
+    started_at timestamp with time zone,
+    completed_at timestamp with time zone,
+    app_slug text,
+    app_name text,
+    check_suite_id bigint,
+    pr_numbers integer[] NOT NULL DEFAULT '{}',
+    details_url text,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_check_runs_pkey PRIMARY KEY (repo_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_check_runs_commit_sha ON public.github_check_runs USING btree (repo_id, commit_sha);
+
+COMMENT ON TABLE public.github_check_runs IS 'check runs (of GitHub Apps, e.g. GitHub Actions) of the commits of a GitHub repo, including re-runs';
+COMMENT ON COLUMN public.github_check_runs.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_check_runs.id IS 'GitHub id of the check run';
+COMMENT ON COLUMN public.github_check_runs.commit_sha IS 'SHA of the commit the check ran on';
+COMMENT ON COLUMN public.github_check_runs.name IS 'name of the check';
+COMMENT ON COLUMN public.github_check_runs.status IS 'status of the check run: queued, in_progress or completed';
+COMMENT ON COLUMN public.github_check_runs.conclusion IS 'conclusion of the completed check run, e.g. success, failure, cancelled, skipped or timed_out';
+COMMENT ON COLUMN public.github_check_runs.started_at IS 'timestamp of when the check run started';
+COMMENT ON COLUMN public.github_check_runs.completed_at IS 'timestamp of when the check run completed';
+COMMENT ON COLUMN public.github_check_runs.app_slug IS 'slug of the GitHub App of the check, e.g. github-actions';
+COMMENT ON COLUMN public.github_check_runs.app_name IS 'name of the GitHub App of the check';
+COMMENT ON COLUMN public.github_check_runs.check_suite_id IS 'GitHub id of the check suite of the check run';
+COMMENT ON COLUMN public.github_check_runs.pr_numbers IS 'numbers of the PRs (of the repo) the commit is the head of';
+COMMENT ON COLUMN public.github_check_runs.details_url IS 'URL of the details of the check run on the site of its app';
+COMMENT ON COLUMN public.github_check_runs.url IS 'URL of the check run';
+COMMENT ON COLUMN public.github_check_runs._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS public.github_commit_statuses (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id bigint NOT NULL,
+    commit_sha text NOT NULL,
+    context text,
+    state text,
+    description text,
+    creator_login text,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    target_url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_commit_statuses_pkey PRIMARY KEY (repo_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_commit_statuses_commit_sha ON public.github_commit_statuses USING btree (repo_id, commit_sha);
+
+COMMENT ON TABLE public.github_commit_statuses IS '(legacy) commit statuses of the commits of a GitHub repo, every state reported being a status of its own';
+COMMENT ON COLUMN public.github_commit_statuses.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_commit_statuses.id IS 'GitHub id of the status';
+COMMENT ON COLUMN public.github_commit_statuses.commit_sha IS 'SHA of the commit of the status';
+COMMENT ON COLUMN public.github_commit_statuses.context IS 'context (i.e. the name of the check) of the status, e.g. ci/circleci: build';
+COMMENT ON COLUMN public.github_commit_statuses.state IS 'state of the status: pending, success, failure or error';
+COMMENT ON COLUMN public.github_commit_statuses.description IS 'description of the status';
+COMMENT ON COLUMN public.github_commit_statuses.creator_login IS 'login of the user (or app) that reported the status';
+COMMENT ON COLUMN public.github_commit_statuses.created_at IS 'timestamp of when the status was reported';
+COMMENT ON COLUMN public.github_commit_statuses.updated_at IS 'timestamp of when the status was last updated';
+COMMENT ON COLUMN public.github_commit_statuses.target_url IS 'URL of the status on the site of its reporter';
+COMMENT ON COLUMN public.github_commit_statuses._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+-- the check runs and statuses of each commit, in the same shape: the statuses of a context are a single check, from its
+-- first status to its latest one (whose state is the conclusion of the check, unless still pending)
+CREATE OR REPLACE VIEW public.github_commit_checks AS
+SELECT repo_id, commit_sha, 'check_run' AS source, name, app_slug AS app, status, conclusion, started_at, completed_at,
+    completed_at - started_at AS duration, pr_numbers
+FROM public.github_check_runs
+UNION ALL
+SELECT s.repo_id, s.commit_sha, 'status' AS source, s.context AS name, MIN(s.creator_login) AS app,
+    CASE WHEN latest.state = 'pending' THEN 'in_progress' ELSE 'completed' END AS status,
+    NULLIF(latest.state, 'pending') AS conclusion, MIN(s.created_at) AS started_at,
+    CASE WHEN latest.state <> 'pending' THEN MAX(s.created_at) END AS completed_at,
+    CASE WHEN latest.state <> 'pending' THEN MAX(s.created_at) - MIN(s.created_at) END AS duration,
+    '{}'::integer[] AS pr_numbers
+FROM public.github_commit_statuses s
+    INNER JOIN LATERAL (
+        SELECT l.state FROM public.github_commit_statuses l
+        WHERE l.repo_id = s.repo_id AND l.commit_sha = s.commit_sha AND l.context IS NOT DISTINCT FROM s.context
+        ORDER BY l.created_at DESC, l.id DESC LIMIT 1
+    ) latest ON TRUE
+GROUP BY s.repo_id, s.commit_sha, s.context, latest.state;
+
+COMMENT ON VIEW public.github_commit_checks IS 'check runs and (legacy) commit statuses of the commits of a GitHub repo, with their durations';
+
+COMMIT;
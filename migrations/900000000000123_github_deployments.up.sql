@@ -0,0 +1,117 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_DEPLOYMENTS', 'Retrieves the environments, deployments and deployment statuses of a GitHub repo', 'GitHub Deployments', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_DEPLOYMENTS')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.github_environments (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id bigint NOT NULL,
+    name text,
+    wait_timer integer,
+    protection_rule_count integer,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_environments_pkey PRIMARY KEY (repo_id, id)
+);
+
+COMMENT ON TABLE public.github_environments IS 'deployment environments of a GitHub repo';
+COMMENT ON COLUMN public.github_environments.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_environments.id IS 'GitHub id of the environment';
+COMMENT ON COLUMN public.github_environments.name IS 'name of the environment, e.g. production';
+COMMENT ON COLUMN public.github_environments.wait_timer IS 'number of minutes deployments to the environment are delayed by';
+COMMENT ON COLUMN public.github_environments.protection_rule_count IS 'number of protection rules of the environment';
+COMMENT ON COLUMN public.github_environments.created_at IS 'timestamp of when the environment was created';
+COMMENT ON COLUMN public.github_environments.updated_at IS 'timestamp of when the environment was last updated';
+COMMENT ON COLUMN public.github_environments.url IS 'URL of the environment';
+COMMENT ON COLUMN public.github_environments._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_environments._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.github_deployments (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id bigint NOT NULL,
+    sha text,
+    ref text,
+    task text,
+    environment text,
+    description text,
+    creator_login text,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_deployments_pkey PRIMARY KEY (repo_id, id)
+);
+
+COMMENT ON TABLE public.github_deployments IS 'deployments of a GitHub repo';
+COMMENT ON COLUMN public.github_deployments.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_deployments.id IS 'GitHub id of the deployment';
+COMMENT ON COLUMN public.github_deployments.sha IS 'SHA of the commit deployed';
+COMMENT ON COLUMN public.github_deployments.ref IS 'ref (branch, tag or SHA) the deployment was created from';
+COMMENT ON COLUMN public.github_deployments.task IS 'task of the deployment, e.g. deploy or deploy:migrations';
+COMMENT ON COLUMN public.github_deployments.environment IS 'name of the environment deployed to';
+COMMENT ON COLUMN public.github_deployments.description IS 'description of the deployment';
+COMMENT ON COLUMN public.github_deployments.creator_login IS 'login of the user (or app) that created the deployment';
+COMMENT ON COLUMN public.github_deployments.created_at IS 'timestamp of when the deployment was created';
+COMMENT ON COLUMN public.github_deployments.updated_at IS 'timestamp of when the deployment was last updated';
+COMMENT ON COLUMN public.github_deployments._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_deployments._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.github_deployment_statuses (
+    repo_id uuid NOT NULL,
+    deployment_id bigint NOT NULL,
+    id bigint NOT NULL,
+    state text,
+    description text,
+    environment text,
+    creator_login text,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    environment_url text,
+    log_url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_deployment_statuses_pkey PRIMARY KEY (repo_id, id),
+    CONSTRAINT github_deployment_statuses_deployment_fkey FOREIGN KEY (repo_id, deployment_id) REFERENCES public.github_deployments(repo_id, id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_deployment_statuses_deployment_fkey ON public.github_deployment_statuses USING btree (repo_id, deployment_id);
+
+COMMENT ON TABLE public.github_deployment_statuses IS 'statuses of the deployments of a GitHub repo, every state reported being a status of its own';
+COMMENT ON COLUMN public.github_deployment_statuses.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_deployment_statuses.deployment_id IS 'GitHub id of the deployment of the status';
+COMMENT ON COLUMN public.github_deployment_statuses.id IS 'GitHub id of the status';
+COMMENT ON COLUMN public.github_deployment_statuses.state IS 'state of the deployment: queued, pending, in_progress, success, failure, error or inactive';
+COMMENT ON COLUMN public.github_deployment_statuses.description IS 'description of the status';
+COMMENT ON COLUMN public.github_deployment_statuses.environment IS 'name of the environment of the deployment';
+COMMENT ON COLUMN public.github_deployment_statuses.creator_login IS 'login of the user (or app) that reported the status';
+COMMENT ON COLUMN public.github_deployment_statuses.created_at IS 'timestamp of when the status was reported';
+COMMENT ON COLUMN public.github_deployment_statuses.updated_at IS 'timestamp of when the status was last updated';
+COMMENT ON COLUMN public.github_deployment_statuses.environment_url IS 'URL of the deployed environment';
+COMMENT ON COLUMN public.github_deployment_statuses.log_url IS 'URL of the logs of the deployment';
+COMMENT ON COLUMN public.github_deployment_statuses._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_deployment_statuses._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+-- the deployments that succeeded, as of their first success status, to compute DORA metrics from: the deployment
+-- frequency (by environment), and the lead time of the deployed commit (if the commits of the repo are synced)
+CREATE OR REPLACE VIEW public.github_successful_deployments AS
+SELECT d.repo_id, d.id, d.environment, d.sha, d.ref, d.created_at, s.deployed_at, c.committer_when AS committed_at,
+    s.deployed_at - c.committer_when AS lead_time
+FROM public.github_deployments d
+    INNER JOIN (
+        SELECT repo_id, deployment_id, MIN(created_at) AS deployed_at FROM public.github_deployment_statuses
+        WHERE state = 'success' AND _deleted_at IS NULL GROUP BY repo_id, deployment_id
+    ) s ON s.repo_id = d.repo_id AND s.deployment_id = d.id
+    LEFT JOIN public.git_commits c ON c.repo_id = d.repo_id AND c.hash = d.sha AND c._deleted_at IS NULL
+WHERE d._deleted_at IS NULL;
+
+COMMENT ON VIEW public.github_successful_deployments IS 'successful deployments of a GitHub repo, with the lead time of the commit deployed (NULL unless the commits of the repo are synced)';
+
+COMMIT;
@@ -0,0 +1,81 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_BRANCH_PROTECTIONS', 'Retrieves the branch protection rules and rulesets of a GitHub repo', 'GitHub Branch Protections', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_BRANCH_PROTECTIONS')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.github_branch_protections (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    branch text NOT NULL,
+    required_approving_review_count integer,
+    dismiss_stale_reviews boolean NOT NULL,
+    require_code_owner_reviews boolean NOT NULL,
+    require_last_push_approval boolean NOT NULL,
+    required_status_checks text[] NOT NULL DEFAULT '{}',
+    strict_status_checks boolean NOT NULL,
+    enforce_admins boolean NOT NULL,
+    require_linear_history boolean NOT NULL,
+    allow_force_pushes boolean NOT NULL,
+    allow_deletions boolean NOT NULL,
+    required_conversation_resolution boolean NOT NULL,
+    lock_branch boolean NOT NULL,
+    protection jsonb NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_branch_protections_pkey PRIMARY KEY (repo_id, branch)
+);
+
+COMMENT ON TABLE public.github_branch_protections IS 'branch protection rules of the protected branches of a GitHub repo';
+COMMENT ON COLUMN public.github_branch_protections.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_branch_protections.branch IS 'name of the protected branch';
+COMMENT ON COLUMN public.github_branch_protections.required_approving_review_count IS 'number of approving reviews required to merge a PR, NULL if PRs are not required';
+COMMENT ON COLUMN public.github_branch_protections.dismiss_stale_reviews IS 'boolean to determine if approving reviews are dismissed when new commits are pushed';
+COMMENT ON COLUMN public.github_branch_protections.require_code_owner_reviews IS 'boolean to determine if the review of a code owner is required';
+COMMENT ON COLUMN public.github_branch_protections.require_last_push_approval IS 'boolean to determine if the last push must be approved by someone other than its pusher';
+COMMENT ON COLUMN public.github_branch_protections.required_status_checks IS 'names of the status checks required to pass before merging';
+COMMENT ON COLUMN public.github_branch_protections.strict_status_checks IS 'boolean to determine if branches must be up to date with the protected branch before merging';
+COMMENT ON COLUMN public.github_branch_protections.enforce_admins IS 'boolean to determine if the protection applies to administrators as well';
+COMMENT ON COLUMN public.github_branch_protections.require_linear_history IS 'boolean to determine if merge commits are prohibited';
+COMMENT ON COLUMN public.github_branch_protections.allow_force_pushes IS 'boolean to determine if force pushes are allowed';
+COMMENT ON COLUMN public.github_branch_protections.allow_deletions IS 'boolean to determine if the branch can be deleted';
+COMMENT ON COLUMN public.github_branch_protections.required_conversation_resolution IS 'boolean to determine if all the conversations of a PR must be resolved before merging';
+COMMENT ON COLUMN public.github_branch_protections.lock_branch IS 'boolean to determine if the branch is read-only';
+COMMENT ON COLUMN public.github_branch_protections.protection IS 'branch protection, as returned by the GitHub API (e.g. with its restrictions and bypass allowances)';
+COMMENT ON COLUMN public.github_branch_protections._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_branch_protections._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.github_rulesets (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id bigint NOT NULL,
+    name text NOT NULL,
+    target text,
+    source_type text,
+    source text,
+    enforcement text,
+    conditions jsonb NOT NULL DEFAULT '{}'::jsonb,
+    rules jsonb NOT NULL DEFAULT '[]'::jsonb,
+    bypass_actors jsonb NOT NULL DEFAULT '[]'::jsonb,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_rulesets_pkey PRIMARY KEY (repo_id, id)
+);
+
+COMMENT ON TABLE public.github_rulesets IS 'rulesets applying to a GitHub repo, including the ones of its org';
+COMMENT ON COLUMN public.github_rulesets.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_rulesets.id IS 'GitHub id of the ruleset';
+COMMENT ON COLUMN public.github_rulesets.name IS 'name of the ruleset';
+COMMENT ON COLUMN public.github_rulesets.target IS 'target of the ruleset: branch or tag';
+COMMENT ON COLUMN public.github_rulesets.source_type IS 'type of the owner of the ruleset: Repository or Organization';
+COMMENT ON COLUMN public.github_rulesets.source IS 'name of the owner of the ruleset';
+COMMENT ON COLUMN public.github_rulesets.enforcement IS 'enforcement of the ruleset: disabled, active or evaluate';
+COMMENT ON COLUMN public.github_rulesets.conditions IS 'conditions of the refs the ruleset applies to, e.g. {"ref_name": {"include": ["~DEFAULT_BRANCH"], "exclude": []}}';
+COMMENT ON COLUMN public.github_rulesets.rules IS 'rules of the ruleset, e.g. [{"type": "pull_request", "parameters": {"required_approving_review_count": 1, ...}}]';
+COMMENT ON COLUMN public.github_rulesets.bypass_actors IS 'actors allowed to bypass the ruleset';
+COMMENT ON COLUMN public.github_rulesets._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_rulesets._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
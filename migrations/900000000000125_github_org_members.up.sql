@@ -0,0 +1,108 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_ORG_MEMBERS', 'Retrieves the members, outside collaborators and teams of the GitHub org owning a repo (shared by all the repos of the org)', 'GitHub Org Members', 2, 'GITHUB'),
+       ('GITHUB_REPO_COLLABORATORS', 'Retrieves the collaborators and teams with access to a GitHub repo, and their permissions', 'GitHub Repo Collaborators', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_ORG_MEMBERS'), ('github', 'GITHUB_REPO_COLLABORATORS')
+ON CONFLICT DO NOTHING;
+
+-- the people of an org aren't tied to any of its repos: the tables of an org are replaced by the sync of any of them
+CREATE TABLE IF NOT EXISTS public.github_org_members (
+    org text NOT NULL,
+    login text NOT NULL,
+    role text NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_org_members_pkey PRIMARY KEY (org, login)
+);
+
+COMMENT ON TABLE public.github_org_members IS 'members and outside collaborators of a GitHub org';
+COMMENT ON COLUMN public.github_org_members.org IS 'login of the org';
+COMMENT ON COLUMN public.github_org_members.login IS 'login of the user';
+COMMENT ON COLUMN public.github_org_members.role IS 'role of the user in the org: admin (i.e. owner), member or outside_collaborator';
+COMMENT ON COLUMN public.github_org_members._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS public.github_org_teams (
+    org text NOT NULL,
+    id bigint NOT NULL,
+    slug text NOT NULL,
+    name text,
+    description text,
+    privacy text,
+    parent_slug text,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_org_teams_pkey PRIMARY KEY (org, id)
+);
+
+COMMENT ON TABLE public.github_org_teams IS 'teams of a GitHub org';
+COMMENT ON COLUMN public.github_org_teams.org IS 'login of the org';
+COMMENT ON COLUMN public.github_org_teams.id IS 'GitHub id of the team';
+COMMENT ON COLUMN public.github_org_teams.slug IS 'slug of the team, e.g. @org/slug in a CODEOWNERS file';
+COMMENT ON COLUMN public.github_org_teams.name IS 'name of the team';
+COMMENT ON COLUMN public.github_org_teams.description IS 'description of the team';
+COMMENT ON COLUMN public.github_org_teams.privacy IS 'privacy of the team: secret or closed';
+COMMENT ON COLUMN public.github_org_teams.parent_slug IS 'slug of the parent team, NULL if the team is not nested';
+COMMENT ON COLUMN public.github_org_teams.url IS 'URL of the team';
+COMMENT ON COLUMN public.github_org_teams._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS public.github_org_team_members (
+    org text NOT NULL,
+    team_id bigint NOT NULL,
+    login text NOT NULL,
+    role text NOT NULL,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_org_team_members_pkey PRIMARY KEY (org, team_id, login),
+    CONSTRAINT github_org_team_members_team_fkey FOREIGN KEY (org, team_id) REFERENCES public.github_org_teams(org, id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+COMMENT ON TABLE public.github_org_team_members IS 'members of the teams of a GitHub org';
+COMMENT ON COLUMN public.github_org_team_members.org IS 'login of the org';
+COMMENT ON COLUMN public.github_org_team_members.team_id IS 'GitHub id of the team';
+COMMENT ON COLUMN public.github_org_team_members.login IS 'login of the member';
+COMMENT ON COLUMN public.github_org_team_members.role IS 'role of the member in the team: maintainer or member';
+COMMENT ON COLUMN public.github_org_team_members._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS public.github_repo_collaborators (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    login text NOT NULL,
+    permission text,
+    role_name text,
+    outside boolean NOT NULL DEFAULT false,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_repo_collaborators_pkey PRIMARY KEY (repo_id, login)
+);
+
+COMMENT ON TABLE public.github_repo_collaborators IS 'users with access to a GitHub repo (directly, or through an org or team)';
+COMMENT ON COLUMN public.github_repo_collaborators.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_repo_collaborators.login IS 'login of the user';
+COMMENT ON COLUMN public.github_repo_collaborators.permission IS 'highest permission of the user on the repo: admin, maintain, push, triage or pull';
+COMMENT ON COLUMN public.github_repo_collaborators.role_name IS 'name of the role of the user on the repo, e.g. write, or the name of a custom role';
+COMMENT ON COLUMN public.github_repo_collaborators.outside IS 'boolean to determine if the user is an outside collaborator of the org owning the repo';
+COMMENT ON COLUMN public.github_repo_collaborators._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_repo_collaborators._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.github_repo_teams (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    team_id bigint NOT NULL,
+    org text NOT NULL,
+    team_slug text NOT NULL,
+    permission text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_repo_teams_pkey PRIMARY KEY (repo_id, team_id)
+);
+
+COMMENT ON TABLE public.github_repo_teams IS 'teams with access to a GitHub repo (see github_org_teams)';
+COMMENT ON COLUMN public.github_repo_teams.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_repo_teams.team_id IS 'GitHub id of the team';
+COMMENT ON COLUMN public.github_repo_teams.org IS 'login of the org of the team';
+COMMENT ON COLUMN public.github_repo_teams.team_slug IS 'slug of the team';
+COMMENT ON COLUMN public.github_repo_teams.permission IS 'highest permission of the team on the repo: admin, maintain, push, triage or pull';
+COMMENT ON COLUMN public.github_repo_teams._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_repo_teams._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
@@ -0,0 +1,97 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_LABELS_AND_MILESTONES', 'Retrieves the labels and milestones of a GitHub repo, and the labels of its issues and pull requests', 'GitHub Labels and Milestones', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_LABELS_AND_MILESTONES')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.github_labels (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id bigint NOT NULL,
+    name text NOT NULL,
+    color text,
+    description text,
+    is_default boolean NOT NULL DEFAULT false,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_labels_pkey PRIMARY KEY (repo_id, id)
+);
+
+COMMENT ON TABLE public.github_labels IS 'labels of a GitHub repo';
+COMMENT ON COLUMN public.github_labels.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_labels.id IS 'GitHub id of the label';
+COMMENT ON COLUMN public.github_labels.name IS 'name of the label, e.g. bug';
+COMMENT ON COLUMN public.github_labels.color IS 'hexadecimal color of the label, e.g. d73a4a';
+COMMENT ON COLUMN public.github_labels.description IS 'description of the label';
+COMMENT ON COLUMN public.github_labels.is_default IS 'boolean to determine if the label is one of the default labels of GitHub';
+COMMENT ON COLUMN public.github_labels.url IS 'API URL of the label';
+COMMENT ON COLUMN public.github_labels._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_labels._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.github_milestones (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id bigint NOT NULL,
+    number integer NOT NULL,
+    title text,
+    description text,
+    state text,
+    creator_login text,
+    open_issues integer,
+    closed_issues integer,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    closed_at timestamp with time zone,
+    due_on timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_milestones_pkey PRIMARY KEY (repo_id, id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_milestones_repo_id_number ON public.github_milestones (repo_id, number);
+
+COMMENT ON TABLE public.github_milestones IS 'milestones of a GitHub repo';
+COMMENT ON COLUMN public.github_milestones.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_milestones.id IS 'GitHub id of the milestone';
+COMMENT ON COLUMN public.github_milestones.number IS 'number of the milestone in the repo';
+COMMENT ON COLUMN public.github_milestones.title IS 'title of the milestone';
+COMMENT ON COLUMN public.github_milestones.description IS 'description of the milestone';
+COMMENT ON COLUMN public.github_milestones.state IS 'state of the milestone: open or closed';
+COMMENT ON COLUMN public.github_milestones.creator_login IS 'login of the user who created the milestone';
+COMMENT ON COLUMN public.github_milestones.open_issues IS 'number of open issues (and pull requests) of the milestone';
+COMMENT ON COLUMN public.github_milestones.closed_issues IS 'number of closed issues (and pull requests) of the milestone';
+COMMENT ON COLUMN public.github_milestones.created_at IS 'timestamp of when the milestone was created';
+COMMENT ON COLUMN public.github_milestones.updated_at IS 'timestamp of when the milestone was last updated';
+COMMENT ON COLUMN public.github_milestones.closed_at IS 'timestamp of when the milestone was closed, NULL while it is open';
+COMMENT ON COLUMN public.github_milestones.due_on IS 'due date of the milestone';
+COMMENT ON COLUMN public.github_milestones.url IS 'URL of the milestone';
+COMMENT ON COLUMN public.github_milestones._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_milestones._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.github_issue_labels (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    issue_number integer NOT NULL,
+    label_id bigint NOT NULL,
+    label_name text NOT NULL,
+    pull_request boolean NOT NULL DEFAULT false,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_issue_labels_pkey PRIMARY KEY (repo_id, issue_number, label_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_issue_labels_repo_id_label_id ON public.github_issue_labels (repo_id, label_id);
+
+COMMENT ON TABLE public.github_issue_labels IS 'labels of the issues and pull requests of a GitHub repo, joining them to github_labels';
+COMMENT ON COLUMN public.github_issue_labels.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_issue_labels.issue_number IS 'number of the issue (or pull request) labeled';
+COMMENT ON COLUMN public.github_issue_labels.label_id IS 'GitHub id of the label, see github_labels.id';
+COMMENT ON COLUMN public.github_issue_labels.label_name IS 'name of the label';
+COMMENT ON COLUMN public.github_issue_labels.pull_request IS 'boolean to determine if the issue is a pull request (see github_pull_requests.number)';
+COMMENT ON COLUMN public.github_issue_labels._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_issue_labels._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
@@ -0,0 +1,95 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_DISCUSSIONS', 'Retrieves the discussion categories and discussions (with their answers) of a GitHub repo', 'GitHub Discussions', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_DISCUSSIONS')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.github_discussion_categories (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id text NOT NULL,
+    name text NOT NULL,
+    slug text,
+    description text,
+    emoji text,
+    is_answerable boolean NOT NULL DEFAULT false,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_discussion_categories_pkey PRIMARY KEY (repo_id, id)
+);
+
+COMMENT ON TABLE public.github_discussion_categories IS 'discussion categories of a GitHub repo';
+COMMENT ON COLUMN public.github_discussion_categories.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_discussion_categories.id IS 'GraphQL node id of the category';
+COMMENT ON COLUMN public.github_discussion_categories.name IS 'name of the category, e.g. Q&A';
+COMMENT ON COLUMN public.github_discussion_categories.slug IS 'slug of the category';
+COMMENT ON COLUMN public.github_discussion_categories.description IS 'description of the category';
+COMMENT ON COLUMN public.github_discussion_categories.emoji IS 'emoji of the category, e.g. :pray:';
+COMMENT ON COLUMN public.github_discussion_categories.is_answerable IS 'boolean to determine if the discussions of the category can be answered (i.e. an answer can be chosen)';
+COMMENT ON COLUMN public.github_discussion_categories.created_at IS 'timestamp of when the category was created';
+COMMENT ON COLUMN public.github_discussion_categories.updated_at IS 'timestamp of when the category was last updated';
+COMMENT ON COLUMN public.github_discussion_categories._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_discussion_categories._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.github_discussions (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    number integer NOT NULL,
+    id text NOT NULL,
+    title text,
+    body text,
+    author_login text,
+    category_id text,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    closed boolean NOT NULL DEFAULT false,
+    closed_at timestamp with time zone,
+    locked boolean NOT NULL DEFAULT false,
+    upvote_count integer,
+    comment_count integer,
+    reaction_count integer,
+    answer_id text,
+    answer_author_login text,
+    answer_created_at timestamp with time zone,
+    answer_upvote_count integer,
+    answer_chosen_at timestamp with time zone,
+    answer_chosen_by_login text,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_discussions_pkey PRIMARY KEY (repo_id, number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_discussions_repo_id_category_id ON public.github_discussions (repo_id, category_id);
+
+COMMENT ON TABLE public.github_discussions IS 'discussions of a GitHub repo, and their chosen answers';
+COMMENT ON COLUMN public.github_discussions.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_discussions.number IS 'number of the discussion in the repo';
+COMMENT ON COLUMN public.github_discussions.id IS 'GraphQL node id of the discussion';
+COMMENT ON COLUMN public.github_discussions.title IS 'title of the discussion';
+COMMENT ON COLUMN public.github_discussions.body IS 'body of the discussion (markdown)';
+COMMENT ON COLUMN public.github_discussions.author_login IS 'login of the author of the discussion, NULL if the account was deleted';
+COMMENT ON COLUMN public.github_discussions.category_id IS 'id of the category of the discussion, see github_discussion_categories.id';
+COMMENT ON COLUMN public.github_discussions.created_at IS 'timestamp of when the discussion was created';
+COMMENT ON COLUMN public.github_discussions.updated_at IS 'timestamp of when the discussion was last updated';
+COMMENT ON COLUMN public.github_discussions.closed IS 'boolean to determine if the discussion is closed';
+COMMENT ON COLUMN public.github_discussions.closed_at IS 'timestamp of when the discussion was closed, NULL while it is open';
+COMMENT ON COLUMN public.github_discussions.locked IS 'boolean to determine if the discussion is locked';
+COMMENT ON COLUMN public.github_discussions.upvote_count IS 'number of upvotes of the discussion';
+COMMENT ON COLUMN public.github_discussions.comment_count IS 'number of (top level) comments of the discussion';
+COMMENT ON COLUMN public.github_discussions.reaction_count IS 'number of reactions to the discussion';
+COMMENT ON COLUMN public.github_discussions.answer_id IS 'GraphQL node id of the comment chosen as the answer of the discussion, NULL if it is not answered';
+COMMENT ON COLUMN public.github_discussions.answer_author_login IS 'login of the author of the answer';
+COMMENT ON COLUMN public.github_discussions.answer_created_at IS 'timestamp of when the answer was posted';
+COMMENT ON COLUMN public.github_discussions.answer_upvote_count IS 'number of upvotes of the answer';
+COMMENT ON COLUMN public.github_discussions.answer_chosen_at IS 'timestamp of when the answer was chosen';
+COMMENT ON COLUMN public.github_discussions.answer_chosen_by_login IS 'login of the user who chose the answer';
+COMMENT ON COLUMN public.github_discussions.url IS 'URL of the discussion';
+COMMENT ON COLUMN public.github_discussions._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_discussions._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
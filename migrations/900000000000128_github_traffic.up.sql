@@ -0,0 +1,96 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_TRAFFIC', 'Retrieves the daily views and clones, and the top referrers and paths, of a GitHub repo (which GitHub only retains for 14 days), accumulating their history. Requires push access to the repo.', 'GitHub Traffic', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_TRAFFIC')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.github_traffic_views (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    day date NOT NULL,
+    views integer,
+    unique_visitors integer,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_traffic_views_pkey PRIMARY KEY (repo_id, day)
+);
+
+COMMENT ON TABLE public.github_traffic_views IS 'daily views of a GitHub repo, accumulated across syncs';
+COMMENT ON COLUMN public.github_traffic_views.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_traffic_views.day IS 'day of the views (UTC)';
+COMMENT ON COLUMN public.github_traffic_views.views IS 'number of views of the repo on the day';
+COMMENT ON COLUMN public.github_traffic_views.unique_visitors IS 'number of unique visitors of the repo on the day';
+COMMENT ON COLUMN public.github_traffic_views._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS public.github_traffic_clones (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    day date NOT NULL,
+    clones integer,
+    unique_cloners integer,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_traffic_clones_pkey PRIMARY KEY (repo_id, day)
+);
+
+COMMENT ON TABLE public.github_traffic_clones IS 'daily clones of a GitHub repo, accumulated across syncs';
+COMMENT ON COLUMN public.github_traffic_clones.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_traffic_clones.day IS 'day of the clones (UTC)';
+COMMENT ON COLUMN public.github_traffic_clones.clones IS 'number of clones of the repo on the day';
+COMMENT ON COLUMN public.github_traffic_clones.unique_cloners IS 'number of unique cloners of the repo on the day';
+COMMENT ON COLUMN public.github_traffic_clones._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS public.github_traffic_referrers (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    day date NOT NULL,
+    referrer text NOT NULL,
+    count integer,
+    uniques integer,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_traffic_referrers_pkey PRIMARY KEY (repo_id, day, referrer)
+);
+
+COMMENT ON TABLE public.github_traffic_referrers IS 'top referrers of a GitHub repo over the 14 days up to a day, as of the sync of that day';
+COMMENT ON COLUMN public.github_traffic_referrers.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_traffic_referrers.day IS 'day of the sync, the last day of the 14 days the referrer was ranked over';
+COMMENT ON COLUMN public.github_traffic_referrers.referrer IS 'referring site, e.g. google.com';
+COMMENT ON COLUMN public.github_traffic_referrers.count IS 'number of views from the referrer over the 14 days';
+COMMENT ON COLUMN public.github_traffic_referrers.uniques IS 'number of unique visitors from the referrer over the 14 days';
+COMMENT ON COLUMN public.github_traffic_referrers._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+CREATE TABLE IF NOT EXISTS public.github_traffic_paths (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    day date NOT NULL,
+    path text NOT NULL,
+    title text,
+    count integer,
+    uniques integer,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    CONSTRAINT github_traffic_paths_pkey PRIMARY KEY (repo_id, day, path)
+);
+
+COMMENT ON TABLE public.github_traffic_paths IS 'top viewed paths of a GitHub repo over the 14 days up to a day, as of the sync of that day';
+COMMENT ON COLUMN public.github_traffic_paths.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_traffic_paths.day IS 'day of the sync, the last day of the 14 days the path was ranked over';
+COMMENT ON COLUMN public.github_traffic_paths.path IS 'path of the page viewed, e.g. /org/repo/blob/main/README.md';
+COMMENT ON COLUMN public.github_traffic_paths.title IS 'title of the page viewed';
+COMMENT ON COLUMN public.github_traffic_paths.count IS 'number of views of the path over the 14 days';
+COMMENT ON COLUMN public.github_traffic_paths.uniques IS 'number of unique visitors of the path over the 14 days';
+COMMENT ON COLUMN public.github_traffic_paths._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+
+-- the traffic of a repo is reported per day (and lost after 14 days), so traffic syncs run daily unless given a schedule
+CREATE OR REPLACE FUNCTION mergestat.repo_syncs_github_traffic_schedule_trigger() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+	IF NEW.sync_type = 'GITHUB_TRAFFIC' AND NEW.schedule IS NULL THEN
+		NEW.schedule = '@daily';
+	END IF;
+	RETURN NEW;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS repo_syncs_github_traffic_schedule_trigger ON mergestat.repo_syncs;
+CREATE TRIGGER repo_syncs_github_traffic_schedule_trigger BEFORE INSERT ON mergestat.repo_syncs FOR EACH ROW EXECUTE FUNCTION mergestat.repo_syncs_github_traffic_schedule_trigger();
+
+COMMIT;
@@ -0,0 +1,78 @@
+BEGIN;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITHUB_PACKAGES', 'Retrieves the packages (e.g. container images of ghcr.io) published from a GitHub repo, and their versions, tags, sizes and downloads', 'GitHub Packages', 2, 'GITHUB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('github', 'GITHUB_PACKAGES')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.github_packages (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id bigint NOT NULL,
+    name text NOT NULL,
+    package_type text NOT NULL,
+    visibility text,
+    version_count bigint,
+    download_count bigint,
+    size bigint,
+    owner_login text,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_packages_pkey PRIMARY KEY (repo_id, id)
+);
+
+COMMENT ON TABLE public.github_packages IS 'packages of GitHub Packages published from a GitHub repo';
+COMMENT ON COLUMN public.github_packages.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_packages.id IS 'GitHub id of the package';
+COMMENT ON COLUMN public.github_packages.name IS 'name of the package';
+COMMENT ON COLUMN public.github_packages.package_type IS 'type of the package: container, docker, npm, maven, rubygems or nuget';
+COMMENT ON COLUMN public.github_packages.visibility IS 'visibility of the package: public, internal or private';
+COMMENT ON COLUMN public.github_packages.version_count IS 'number of versions of the package';
+COMMENT ON COLUMN public.github_packages.download_count IS 'number of downloads of the package, NULL for the container packages (whose registry does not report them)';
+COMMENT ON COLUMN public.github_packages.size IS 'size in bytes of the files of the (latest 100) versions of the package, NULL for the container packages';
+COMMENT ON COLUMN public.github_packages.owner_login IS 'login of the org or user owning the package';
+COMMENT ON COLUMN public.github_packages.created_at IS 'timestamp of when the package was created';
+COMMENT ON COLUMN public.github_packages.updated_at IS 'timestamp of when the package was last updated';
+COMMENT ON COLUMN public.github_packages.url IS 'URL of the package';
+COMMENT ON COLUMN public.github_packages._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_packages._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.github_package_versions (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    package_id bigint NOT NULL,
+    id bigint NOT NULL,
+    name text,
+    tags text[] NOT NULL DEFAULT '{}',
+    download_count bigint,
+    size bigint,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT github_package_versions_pkey PRIMARY KEY (repo_id, id),
+    CONSTRAINT github_package_versions_package_fkey FOREIGN KEY (repo_id, package_id) REFERENCES public.github_packages(repo_id, id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS idx_github_package_versions_package_fkey ON public.github_package_versions USING btree (repo_id, package_id);
+
+COMMENT ON TABLE public.github_package_versions IS 'versions of the packages of GitHub Packages published from a GitHub repo';
+COMMENT ON COLUMN public.github_package_versions.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.github_package_versions.package_id IS 'GitHub id of the package of the version';
+COMMENT ON COLUMN public.github_package_versions.id IS 'GitHub id of the version';
+COMMENT ON COLUMN public.github_package_versions.name IS 'name of the version, e.g. 1.2.3 (or the digest of a container image)';
+COMMENT ON COLUMN public.github_package_versions.tags IS 'tags of the version of a container image, e.g. latest';
+COMMENT ON COLUMN public.github_package_versions.download_count IS 'number of downloads of the version, NULL for the container packages (whose registry does not report them)';
+COMMENT ON COLUMN public.github_package_versions.size IS 'size in bytes of the files of the version, NULL for the container packages';
+COMMENT ON COLUMN public.github_package_versions.created_at IS 'timestamp of when the version was published';
+COMMENT ON COLUMN public.github_package_versions.updated_at IS 'timestamp of when the version was last updated';
+COMMENT ON COLUMN public.github_package_versions.url IS 'URL of the version';
+COMMENT ON COLUMN public.github_package_versions._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.github_package_versions._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
@@ -0,0 +1,165 @@
+BEGIN;
+
+-- GitLab API requests are rate limited per user, so run GitLab syncs one at a time (like GitHub and Bitbucket ones)
+INSERT INTO mergestat.repo_sync_type_groups ("group", concurrent_syncs)
+VALUES ('GITLAB', 1)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_labels (label, color)
+VALUES ('gitlab', '#fc6d26')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('GITLAB_REPO_MRS', 'Retrieves all the merge requests of a GitLab project', 'GitLab Merge Requests', 2, 'GITLAB'),
+       ('GITLAB_REPO_PIPELINES', 'Retrieves all the pipelines of a GitLab project, and their jobs', 'GitLab Pipelines', 2, 'GITLAB')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('gitlab', 'GITLAB_REPO_MRS'),
+       ('gitlab', 'GITLAB_REPO_PIPELINES')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.gitlab_merge_requests (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    iid integer NOT NULL,
+    id integer NOT NULL,
+    title text,
+    description text,
+    state text,
+    author_username text,
+    assignee_username text,
+    source_branch text,
+    target_branch text,
+    sha text,
+    merge_commit_sha text,
+    merge_status text,
+    work_in_progress boolean,
+    labels text[] NOT NULL DEFAULT '{}',
+    upvotes integer,
+    downvotes integer,
+    user_notes_count integer,
+    merged_by_username text,
+    merged_at timestamp with time zone,
+    closed_by_username text,
+    closed_at timestamp with time zone,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT gitlab_merge_requests_pkey PRIMARY KEY (repo_id, iid)
+);
+
+COMMENT ON TABLE public.gitlab_merge_requests IS 'merge requests of a GitLab project';
+COMMENT ON COLUMN public.gitlab_merge_requests.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.gitlab_merge_requests.iid IS 'number of the merge request in the project, e.g. !42';
+COMMENT ON COLUMN public.gitlab_merge_requests.id IS 'GitLab id of the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests.title IS 'title of the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests.description IS 'description of the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests.state IS 'state of the merge request (opened, closed, merged or locked)';
+COMMENT ON COLUMN public.gitlab_merge_requests.author_username IS 'username of the author of the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests.assignee_username IS 'username of the assignee of the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests.source_branch IS 'name of the branch the changes come from';
+COMMENT ON COLUMN public.gitlab_merge_requests.target_branch IS 'name of the branch the changes are merged into';
+COMMENT ON COLUMN public.gitlab_merge_requests.sha IS 'hash of the head commit of the source branch';
+COMMENT ON COLUMN public.gitlab_merge_requests.merge_commit_sha IS 'hash of the merge commit (if merged)';
+COMMENT ON COLUMN public.gitlab_merge_requests.merge_status IS 'whether the merge request can be merged, e.g. can_be_merged';
+COMMENT ON COLUMN public.gitlab_merge_requests.work_in_progress IS 'boolean to determine if the merge request is a draft';
+COMMENT ON COLUMN public.gitlab_merge_requests.labels IS 'labels of the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests.upvotes IS 'number of upvotes of the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests.downvotes IS 'number of downvotes of the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests.user_notes_count IS 'number of comments on the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests.merged_by_username IS 'username of the user who merged the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests.merged_at IS 'timestamp of when the merge request was merged';
+COMMENT ON COLUMN public.gitlab_merge_requests.closed_by_username IS 'username of the user who closed the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests.closed_at IS 'timestamp of when the merge request was closed';
+COMMENT ON COLUMN public.gitlab_merge_requests.created_at IS 'timestamp of when the merge request was created';
+COMMENT ON COLUMN public.gitlab_merge_requests.updated_at IS 'timestamp of when the merge request was last updated';
+COMMENT ON COLUMN public.gitlab_merge_requests.url IS 'URL of the merge request';
+COMMENT ON COLUMN public.gitlab_merge_requests._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.gitlab_merge_requests._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.gitlab_pipelines (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    id integer NOT NULL,
+    status text,
+    ref text,
+    sha text,
+    before_sha text,
+    tag boolean,
+    user_username text,
+    yaml_errors text,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    started_at timestamp with time zone,
+    finished_at timestamp with time zone,
+    duration integer,
+    coverage text,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT gitlab_pipelines_pkey PRIMARY KEY (repo_id, id)
+);
+
+COMMENT ON TABLE public.gitlab_pipelines IS 'pipelines (CI runs) of a GitLab project';
+COMMENT ON COLUMN public.gitlab_pipelines.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.gitlab_pipelines.id IS 'GitLab id of the pipeline';
+COMMENT ON COLUMN public.gitlab_pipelines.status IS 'status of the pipeline (e.g. running, success, failed, canceled, skipped)';
+COMMENT ON COLUMN public.gitlab_pipelines.ref IS 'name of the branch or tag the pipeline ran against';
+COMMENT ON COLUMN public.gitlab_pipelines.sha IS 'hash of the commit the pipeline ran against';
+COMMENT ON COLUMN public.gitlab_pipelines.before_sha IS 'hash of the previous head commit of the ref, for the pipelines of a push';
+COMMENT ON COLUMN public.gitlab_pipelines.tag IS 'boolean to determine if the pipeline ran against a tag';
+COMMENT ON COLUMN public.gitlab_pipelines.user_username IS 'username of the user who triggered the pipeline';
+COMMENT ON COLUMN public.gitlab_pipelines.yaml_errors IS 'errors of the CI configuration of the pipeline, if it is invalid';
+COMMENT ON COLUMN public.gitlab_pipelines.created_at IS 'timestamp of when the pipeline was created';
+COMMENT ON COLUMN public.gitlab_pipelines.updated_at IS 'timestamp of when the pipeline was last updated';
+COMMENT ON COLUMN public.gitlab_pipelines.started_at IS 'timestamp of when the pipeline started';
+COMMENT ON COLUMN public.gitlab_pipelines.finished_at IS 'timestamp of when the pipeline finished';
+COMMENT ON COLUMN public.gitlab_pipelines.duration IS 'duration of the pipeline in seconds';
+COMMENT ON COLUMN public.gitlab_pipelines.coverage IS 'test coverage of the pipeline (percentage)';
+COMMENT ON COLUMN public.gitlab_pipelines.url IS 'URL of the pipeline';
+COMMENT ON COLUMN public.gitlab_pipelines._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.gitlab_pipelines._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+CREATE TABLE IF NOT EXISTS public.gitlab_pipeline_jobs (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    pipeline_id integer NOT NULL,
+    id integer NOT NULL,
+    name text,
+    stage text,
+    status text,
+    ref text,
+    tag boolean,
+    user_username text,
+    runner_description text,
+    created_at timestamp with time zone,
+    started_at timestamp with time zone,
+    finished_at timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT gitlab_pipeline_jobs_pkey PRIMARY KEY (repo_id, id),
+    CONSTRAINT gitlab_pipeline_jobs_pipeline_fkey FOREIGN KEY (repo_id, pipeline_id) REFERENCES public.gitlab_pipelines(repo_id, id) ON DELETE CASCADE ON UPDATE RESTRICT
+);
+
+CREATE INDEX IF NOT EXISTS idx_gitlab_pipeline_jobs_pipeline_fkey ON public.gitlab_pipeline_jobs USING btree (repo_id, pipeline_id);
+
+COMMENT ON TABLE public.gitlab_pipeline_jobs IS 'jobs of the pipelines of a GitLab project';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.pipeline_id IS 'GitLab id of the pipeline of the job';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.id IS 'GitLab id of the job';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.name IS 'name of the job';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.stage IS 'stage of the pipeline the job ran in, e.g. test';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.status IS 'status of the job (e.g. running, success, failed, canceled, skipped, manual)';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.ref IS 'name of the branch or tag the job ran against';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.tag IS 'boolean to determine if the job ran against a tag';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.user_username IS 'username of the user who triggered the job';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.runner_description IS 'description of the runner the job ran on';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.created_at IS 'timestamp of when the job was created';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.started_at IS 'timestamp of when the job started';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.finished_at IS 'timestamp of when the job finished';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs.url IS 'URL of the job';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.gitlab_pipeline_jobs._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
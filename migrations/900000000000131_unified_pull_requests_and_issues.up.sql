@@ -0,0 +1,133 @@
+BEGIN;
+
+-- pull_requests and issues are provider-neutral views of the pull requests (merge requests) and issues of all the
+-- providers, so that queries don't need a UNION of the tables of each. They are built from the tables of the providers
+-- that exist (those of GitHub may have been dropped by 900000000000059_remove_empty_tables if they were never synced),
+-- mergestat.refresh_unified_views() rebuilds them and is called again by the migrations that add a provider.
+CREATE OR REPLACE FUNCTION mergestat.refresh_unified_views() RETURNS VOID
+LANGUAGE plpgsql AS $$
+DECLARE
+    pull_requests TEXT[] := ARRAY[]::TEXT[];
+    issues TEXT[] := ARRAY[]::TEXT[];
+BEGIN
+    IF to_regclass('public.github_pull_requests') IS NOT NULL THEN
+        pull_requests := pull_requests || $sql$
+            SELECT 'github'::TEXT AS provider, repo_id, number, title, body,
+                CASE WHEN merged OR state = 'MERGED' THEN 'merged' WHEN closed OR state = 'CLOSED' THEN 'closed' ELSE 'open' END AS state,
+                author_login AS author, is_draft, head_ref_name AS source_branch, base_ref_name AS target_branch,
+                head_ref_oid AS head_commit, NULL::TEXT AS merge_commit, comment_count,
+                created_at, updated_at, merged_at, closed_at, url
+            FROM public.github_pull_requests WHERE _deleted_at IS NULL
+        $sql$;
+    END IF;
+
+    IF to_regclass('public.gitlab_merge_requests') IS NOT NULL THEN
+        pull_requests := pull_requests || $sql$
+            SELECT 'gitlab'::TEXT AS provider, repo_id, iid AS number, title, description AS body,
+                CASE state WHEN 'merged' THEN 'merged' WHEN 'opened' THEN 'open' ELSE 'closed' END AS state,
+                author_username AS author, work_in_progress AS is_draft, source_branch, target_branch,
+                sha AS head_commit, merge_commit_sha AS merge_commit, user_notes_count AS comment_count,
+                created_at, updated_at, merged_at, closed_at, url
+            FROM public.gitlab_merge_requests WHERE _deleted_at IS NULL
+        $sql$;
+    END IF;
+
+    IF to_regclass('public.bitbucket_pull_requests') IS NOT NULL THEN
+        -- Bitbucket doesn't report when a pull request was merged or declined, the last update is the closest
+        pull_requests := pull_requests || $sql$
+            SELECT 'bitbucket'::TEXT AS provider, repo_id, id AS number, title, description AS body,
+                CASE state WHEN 'MERGED' THEN 'merged' WHEN 'OPEN' THEN 'open' ELSE 'closed' END AS state,
+                author_display_name AS author, NULL::BOOLEAN AS is_draft, source_branch, destination_branch AS target_branch,
+                source_commit AS head_commit, merge_commit, comment_count,
+                created_on AS created_at, updated_on AS updated_at,
+                CASE WHEN state = 'MERGED' THEN updated_on END AS merged_at,
+                CASE WHEN state <> 'OPEN' THEN updated_on END AS closed_at, url
+            FROM public.bitbucket_pull_requests WHERE _deleted_at IS NULL
+        $sql$;
+    END IF;
+
+    IF to_regclass('public.azure_pull_requests') IS NOT NULL THEN
+        -- the ref names of Azure DevOps are full names, e.g. refs/heads/main
+        pull_requests := pull_requests || $sql$
+            SELECT 'azure'::TEXT AS provider, repo_id, id AS number, title, description AS body,
+                CASE status WHEN 'completed' THEN 'merged' WHEN 'active' THEN 'open' ELSE 'closed' END AS state,
+                created_by AS author, is_draft,
+                regexp_replace(source_ref_name, '^refs/heads/', '') AS source_branch,
+                regexp_replace(target_ref_name, '^refs/heads/', '') AS target_branch,
+                source_commit AS head_commit, merge_commit, NULL::INTEGER AS comment_count,
+                created_at, NULL::TIMESTAMP WITH TIME ZONE AS updated_at,
+                CASE WHEN status = 'completed' THEN closed_at END AS merged_at, closed_at, NULL::TEXT AS url
+            FROM public.azure_pull_requests WHERE _deleted_at IS NULL
+        $sql$;
+    END IF;
+
+    IF to_regclass('public.github_issues') IS NOT NULL THEN
+        issues := issues || $sql$
+            SELECT 'github'::TEXT AS provider, repo_id, number, title, body,
+                CASE WHEN closed OR state = 'CLOSED' THEN 'closed' ELSE 'open' END AS state,
+                author_login AS author, comment_count, created_at, updated_at, closed_at, url
+            FROM public.github_issues WHERE _deleted_at IS NULL
+        $sql$;
+    END IF;
+
+    -- keep the columns of the views when no provider has any table
+    pull_requests := pull_requests || $sql$
+        SELECT NULL::TEXT AS provider, NULL::UUID AS repo_id, NULL::INTEGER AS number, NULL::TEXT AS title,
+            NULL::TEXT AS body, NULL::TEXT AS state, NULL::TEXT AS author, NULL::BOOLEAN AS is_draft,
+            NULL::TEXT AS source_branch, NULL::TEXT AS target_branch, NULL::TEXT AS head_commit, NULL::TEXT AS merge_commit,
+            NULL::INTEGER AS comment_count, NULL::TIMESTAMP WITH TIME ZONE AS created_at, NULL::TIMESTAMP WITH TIME ZONE AS updated_at,
+            NULL::TIMESTAMP WITH TIME ZONE AS merged_at, NULL::TIMESTAMP WITH TIME ZONE AS closed_at, NULL::TEXT AS url
+        WHERE FALSE
+    $sql$;
+    issues := issues || $sql$
+        SELECT NULL::TEXT AS provider, NULL::UUID AS repo_id, NULL::INTEGER AS number, NULL::TEXT AS title,
+            NULL::TEXT AS body, NULL::TEXT AS state, NULL::TEXT AS author, NULL::INTEGER AS comment_count,
+            NULL::TIMESTAMP WITH TIME ZONE AS created_at, NULL::TIMESTAMP WITH TIME ZONE AS updated_at,
+            NULL::TIMESTAMP WITH TIME ZONE AS closed_at, NULL::TEXT AS url
+        WHERE FALSE
+    $sql$;
+
+    EXECUTE 'CREATE OR REPLACE VIEW public.pull_requests AS ' || array_to_string(pull_requests, ' UNION ALL ');
+    EXECUTE 'CREATE OR REPLACE VIEW public.issues AS ' || array_to_string(issues, ' UNION ALL ');
+
+    COMMENT ON VIEW public.pull_requests IS 'pull requests (and GitLab merge requests) of the repos of all providers, with normalized fields';
+    COMMENT ON COLUMN public.pull_requests.provider IS 'provider of the repo (github, gitlab, bitbucket or azure)';
+    COMMENT ON COLUMN public.pull_requests.repo_id IS 'foreign key for public.repos.id';
+    COMMENT ON COLUMN public.pull_requests.number IS 'number (or id) of the pull request in the repo';
+    COMMENT ON COLUMN public.pull_requests.title IS 'title of the pull request';
+    COMMENT ON COLUMN public.pull_requests.body IS 'body (description) of the pull request';
+    COMMENT ON COLUMN public.pull_requests.state IS 'state of the pull request (open, merged or closed without merging)';
+    COMMENT ON COLUMN public.pull_requests.author IS 'login (or display name, depending on the provider) of the author of the pull request';
+    COMMENT ON COLUMN public.pull_requests.is_draft IS 'boolean to determine if the pull request is a draft, NULL if the provider has no drafts';
+    COMMENT ON COLUMN public.pull_requests.source_branch IS 'name of the branch the changes come from';
+    COMMENT ON COLUMN public.pull_requests.target_branch IS 'name of the branch the changes are merged into';
+    COMMENT ON COLUMN public.pull_requests.head_commit IS 'hash of the head commit of the source branch';
+    COMMENT ON COLUMN public.pull_requests.merge_commit IS 'hash of the merge commit (if merged and reported by the provider)';
+    COMMENT ON COLUMN public.pull_requests.comment_count IS 'number of comments on the pull request, NULL if not reported by the provider';
+    COMMENT ON COLUMN public.pull_requests.created_at IS 'timestamp of when the pull request was created';
+    COMMENT ON COLUMN public.pull_requests.updated_at IS 'timestamp of when the pull request was last updated';
+    COMMENT ON COLUMN public.pull_requests.merged_at IS 'timestamp of when the pull request was merged (the last update for Bitbucket), NULL if it was not merged';
+    COMMENT ON COLUMN public.pull_requests.closed_at IS 'timestamp of when the pull request was merged or closed (the last update for Bitbucket), NULL if it is open';
+    COMMENT ON COLUMN public.pull_requests.url IS 'URL of the pull request';
+
+    COMMENT ON VIEW public.issues IS 'issues of the repos of all providers, with normalized fields';
+    COMMENT ON COLUMN public.issues.provider IS 'provider of the repo (github)';
+    COMMENT ON COLUMN public.issues.repo_id IS 'foreign key for public.repos.id';
+    COMMENT ON COLUMN public.issues.number IS 'number of the issue in the repo';
+    COMMENT ON COLUMN public.issues.title IS 'title of the issue';
+    COMMENT ON COLUMN public.issues.body IS 'body of the issue';
+    COMMENT ON COLUMN public.issues.state IS 'state of the issue (open or closed)';
+    COMMENT ON COLUMN public.issues.author IS 'login of the author of the issue';
+    COMMENT ON COLUMN public.issues.comment_count IS 'number of comments on the issue';
+    COMMENT ON COLUMN public.issues.created_at IS 'timestamp of when the issue was created';
+    COMMENT ON COLUMN public.issues.updated_at IS 'timestamp of when the issue was last updated';
+    COMMENT ON COLUMN public.issues.closed_at IS 'timestamp of when the issue was closed, NULL if it is open';
+    COMMENT ON COLUMN public.issues.url IS 'URL of the issue';
+END;
+$$;
+
+COMMENT ON FUNCTION mergestat.refresh_unified_views() IS 'rebuilds the provider-neutral pull_requests and issues views from the tables of the providers that exist';
+
+SELECT mergestat.refresh_unified_views();
+
+COMMIT;
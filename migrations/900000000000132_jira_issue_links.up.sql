@@ -0,0 +1,77 @@
+BEGIN;
+
+-- Jira API requests are rate limited per account, so run Jira syncs one at a time (like the ones of the other vendors)
+INSERT INTO mergestat.repo_sync_type_groups ("group", concurrent_syncs)
+VALUES ('JIRA', 1)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_labels (label, color)
+VALUES ('jira', '#0052cc')
+ON CONFLICT DO NOTHING;
+
+-- the sync is configured with the url of the Jira instance (and optionally the email of the account, the sync variable
+-- holding its API token, the custom field of the story points and the keys of the projects to link), e.g.
+-- {"url": "https://acme.atlassian.net", "email": "bot@acme.com", "projects": ["PROJ"]}
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('JIRA_ISSUE_LINKS', 'Links the commits, branches and pull requests of a repo to the Jira issues they reference, and retrieves their status, type and story points', 'Jira Issue Links', 2, 'JIRA')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('jira', 'JIRA_ISSUE_LINKS')
+ON CONFLICT DO NOTHING;
+
+-- the issue keys are extracted from the commits and refs synced by these
+INSERT INTO mergestat.repo_sync_type_dependencies (sync_type, depends_on)
+VALUES ('JIRA_ISSUE_LINKS', 'GIT_COMMITS'),
+       ('JIRA_ISSUE_LINKS', 'GIT_REFS')
+ON CONFLICT DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS public.jira_issue_links (
+    repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    source_type text NOT NULL,
+    source_id text NOT NULL,
+    issue_key text NOT NULL,
+    issue_id text NOT NULL,
+    current_key text NOT NULL,
+    project_key text,
+    summary text,
+    issue_type text,
+    status text,
+    status_category text,
+    priority text,
+    assignee text,
+    story_points double precision,
+    created_at timestamp with time zone,
+    updated_at timestamp with time zone,
+    resolved_at timestamp with time zone,
+    url text,
+    _mergestat_synced_at timestamp with time zone NOT NULL DEFAULT now(),
+    _deleted_at timestamp with time zone,
+    CONSTRAINT jira_issue_links_pkey PRIMARY KEY (repo_id, source_type, source_id, issue_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_jira_issue_links_issue_key ON public.jira_issue_links USING btree (repo_id, issue_key);
+
+COMMENT ON TABLE public.jira_issue_links IS 'references to Jira issues in the commit messages, branch names and pull request titles of a repo, with the planning data of the issues';
+COMMENT ON COLUMN public.jira_issue_links.repo_id IS 'foreign key for public.repos.id';
+COMMENT ON COLUMN public.jira_issue_links.source_type IS 'kind of the reference (commit, branch or pull_request)';
+COMMENT ON COLUMN public.jira_issue_links.source_id IS 'hash of the commit, name of the branch or number of the pull request referencing the issue';
+COMMENT ON COLUMN public.jira_issue_links.issue_key IS 'key of the issue as referenced, e.g. PROJ-123';
+COMMENT ON COLUMN public.jira_issue_links.issue_id IS 'Jira id of the issue';
+COMMENT ON COLUMN public.jira_issue_links.current_key IS 'current key of the issue, which differs from issue_key if the issue was moved';
+COMMENT ON COLUMN public.jira_issue_links.project_key IS 'key of the project of the issue';
+COMMENT ON COLUMN public.jira_issue_links.summary IS 'summary of the issue';
+COMMENT ON COLUMN public.jira_issue_links.issue_type IS 'type of the issue, e.g. Story, Bug or Task';
+COMMENT ON COLUMN public.jira_issue_links.status IS 'status of the issue, e.g. In Progress';
+COMMENT ON COLUMN public.jira_issue_links.status_category IS 'category of the status of the issue (new, indeterminate or done)';
+COMMENT ON COLUMN public.jira_issue_links.priority IS 'priority of the issue';
+COMMENT ON COLUMN public.jira_issue_links.assignee IS 'display name of the assignee of the issue';
+COMMENT ON COLUMN public.jira_issue_links.story_points IS 'story points of the issue, NULL if not estimated';
+COMMENT ON COLUMN public.jira_issue_links.created_at IS 'timestamp of when the issue was created';
+COMMENT ON COLUMN public.jira_issue_links.updated_at IS 'timestamp of when the issue was last updated';
+COMMENT ON COLUMN public.jira_issue_links.resolved_at IS 'timestamp of when the issue was resolved, NULL if it is unresolved';
+COMMENT ON COLUMN public.jira_issue_links.url IS 'URL of the issue';
+COMMENT ON COLUMN public.jira_issue_links._mergestat_synced_at IS 'timestamp when record was synced into the MergeStat database';
+COMMENT ON COLUMN public.jira_issue_links._deleted_at IS 'timestamp of when the row was found to be removed by a sync, NULL while it is current';
+
+COMMIT;
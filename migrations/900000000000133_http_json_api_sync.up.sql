@@ -0,0 +1,16 @@
+BEGIN;
+
+-- the sync requests an API configured by its settings (url template, auth, JSONPath of the records and of their fields)
+-- and replaces the rows of the repo in a target table with the records. The table is created by the operator, with a
+-- repo_id column and a column for each field, e.g.
+--   CREATE TABLE public.deploys (repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE, id text, environment text, deployed_at timestamptz);
+-- and the API token (if any) is held by a sync variable of the repo, see mergestat.add_sync_variable
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('HTTP_JSON_API', 'Retrieves the records of a JSON API configured by the settings of the sync into a table', 'HTTP JSON API', 2, 'DEFAULT')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('beta', 'HTTP_JSON_API')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
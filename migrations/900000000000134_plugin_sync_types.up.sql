@@ -0,0 +1,9 @@
+BEGIN;
+
+-- sync types registered by plugins (see pkg/syncplugin) are added to mergestat.repo_sync_types by the worker when it
+-- starts, with this label
+INSERT INTO mergestat.repo_sync_type_labels (label, color)
+VALUES ('plugin', '#0d9488')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
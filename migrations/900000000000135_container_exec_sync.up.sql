@@ -0,0 +1,15 @@
+BEGIN;
+
+-- the sync runs the image of its settings (with podman) against the clone of the repo, mounted read-only at
+-- /mergestat/repo, and replaces the rows of the repo in a target table with the records the container outputs as
+-- newline-delimited JSON objects. The table is created by the operator, with a repo_id column, e.g.
+--   CREATE TABLE public.scc_files (repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE, path text, language text, lines integer);
+INSERT INTO mergestat.repo_sync_types (type, description, short_name, priority, type_group)
+VALUES ('CONTAINER_EXEC', 'Runs a container image against the repo and retrieves the records it outputs into a table', 'Container Exec', 2, 'DEFAULT')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO mergestat.repo_sync_type_label_associations (label, repo_sync_type)
+VALUES ('beta', 'CONTAINER_EXEC')
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,77 @@
+BEGIN;
+
+-- credentials of a provider are only rotated if it's enabled on the provider. Otherwise, its default credential is used
+-- (or the most recent one), as before credentials could be rotated, among the ones that apply to the repo.
+ALTER TABLE mergestat.providers ADD COLUMN IF NOT EXISTS rotate_credentials BOOLEAN NOT NULL DEFAULT FALSE;
+COMMENT ON COLUMN mergestat.providers.rotate_credentials IS 'rotate through the credentials of the provider (least recently used first), rather than always using its default one';
+
+-- the tier of a credential for a repo of its provider (lower tiers are picked first), NULL if it can't be used for it
+CREATE OR REPLACE FUNCTION mergestat.service_auth_credential_tier(credential_id_param UUID, repo_id_param UUID, org_param TEXT)
+RETURNS INTEGER AS $$
+    SELECT CASE
+        WHEN repo_id_param IS NULL THEN
+            CASE WHEN EXISTS (SELECT 1 FROM mergestat.service_auth_credential_assignments a WHERE a.credential_id = credential_id_param) THEN 1 ELSE 0 END
+        WHEN EXISTS (SELECT 1 FROM mergestat.service_auth_credential_assignments a WHERE a.credential_id = credential_id_param AND a.repo_id = repo_id_param) THEN 0
+        WHEN EXISTS (SELECT 1 FROM mergestat.service_auth_credential_assignments a WHERE a.credential_id = credential_id_param AND lower(a.org) = org_param) THEN 1
+        WHEN NOT EXISTS (SELECT 1 FROM mergestat.service_auth_credential_assignments a WHERE a.credential_id = credential_id_param) THEN 2
+    END;
+$$ LANGUAGE SQL STABLE;
+
+-- pick the credential to use for a repo (or for the provider in general, if repo_id_param is NULL).
+-- credentials assigned to the repo come first, then the ones assigned to its org, then the unassigned ones. Without a
+-- repo, the unassigned credentials come first, then the assigned ones.
+-- within each of those, the default credential is picked (or the most recent one), unless the provider rotates its
+-- credentials, in which case the least recently used credential is picked and marked as used. Credentials being picked
+-- by a concurrent call are skipped (if there are others), so that concurrent syncs are spread across credentials.
+DROP FUNCTION IF EXISTS mergestat.next_service_auth_credential(UUID, UUID, TEXT);
+CREATE FUNCTION mergestat.next_service_auth_credential(provider_id UUID, repo_id_param UUID, secret TEXT)
+RETURNS TABLE (id UUID, type TEXT, username TEXT, token TEXT, encrypted_key BYTEA, encrypted_username BYTEA, encrypted_token BYTEA) AS $$
+DECLARE _org TEXT; _tier INTEGER; _id UUID; _rotate BOOLEAN;
+BEGIN
+    SELECT p.rotate_credentials INTO _rotate FROM mergestat.providers p WHERE p.id = provider_id;
+
+    IF repo_id_param IS NOT NULL THEN
+        SELECT lower(split_part(regexp_replace(r.repo, '^[a-z+]+://[^/]+/', ''), '/', 1)) INTO _org
+            FROM public.repos r WHERE r.id = repo_id_param;
+    END IF;
+
+    -- the best tier of the credentials that can be used for the repo
+    SELECT min(mergestat.service_auth_credential_tier(c.id, repo_id_param, _org)) INTO _tier
+        FROM mergestat.service_auth_credentials c WHERE c.provider = provider_id;
+
+    IF _tier IS NULL THEN
+        RETURN;
+    END IF;
+
+    IF _rotate THEN
+        SELECT c.id INTO _id FROM mergestat.service_auth_credentials c
+        WHERE c.provider = provider_id AND mergestat.service_auth_credential_tier(c.id, repo_id_param, _org) = _tier
+        ORDER BY c.last_used_at ASC NULLS FIRST
+        LIMIT 1
+        FOR UPDATE OF c SKIP LOCKED;
+    END IF;
+
+    IF _id IS NULL THEN
+        SELECT c.id INTO _id FROM mergestat.service_auth_credentials c
+        WHERE c.provider = provider_id AND mergestat.service_auth_credential_tier(c.id, repo_id_param, _org) = _tier
+        ORDER BY CASE WHEN _rotate THEN c.last_used_at END ASC NULLS FIRST, c.is_default DESC, c.created_at DESC
+        LIMIT 1;
+    END IF;
+
+    IF _rotate THEN
+        UPDATE mergestat.service_auth_credentials c SET last_used_at = now() WHERE c.id = _id;
+    END IF;
+
+    RETURN QUERY SELECT c.id, c.type,
+            CASE WHEN c.encrypted_key IS NULL THEN pgp_sym_decrypt(c.username, secret) END,
+            CASE WHEN c.encrypted_key IS NULL THEN pgp_sym_decrypt(c.credentials, secret) END,
+            c.encrypted_key,
+            CASE WHEN c.encrypted_key IS NOT NULL THEN c.username END,
+            CASE WHEN c.encrypted_key IS NOT NULL THEN c.credentials END
+        FROM mergestat.service_auth_credentials c WHERE c.id = _id;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMENT ON FUNCTION mergestat.next_service_auth_credential(UUID, UUID, TEXT) IS 'picks the credential to use for a repo of the provider (rotating them, if enabled on the provider)';
+
+COMMIT;
@@ -0,0 +1,30 @@
+BEGIN;
+
+-- the row-level security policies of the tables keyed by repo (rather than having a tenant column), which belong to the
+-- tenant of their repo: the sync variables of repos (which hold secrets, such as the tokens of container syncs) and
+-- the repo syncs (with their settings). As with the tables with a tenant, workers pinned to a tenant are restricted
+-- to the rows of their tenant.
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY['mergestat.sync_variables', 'mergestat.repo_syncs'] LOOP
+        EXECUTE FORMAT('ALTER TABLE %s ENABLE ROW LEVEL SECURITY', t);
+
+        EXECUTE FORMAT('DROP POLICY IF EXISTS tenant_all_access ON %s', t);
+        EXECUTE FORMAT('CREATE POLICY tenant_all_access ON %s FOR ALL USING (TRUE) WITH CHECK (TRUE)', t);
+
+        EXECUTE FORMAT('DROP POLICY IF EXISTS tenant_worker_access ON %s', t);
+        EXECUTE FORMAT('CREATE POLICY tenant_worker_access ON %s AS RESTRICTIVE FOR ALL TO mergestat_role_worker '
+            'USING (mergestat.worker_tenant() IS NULL OR EXISTS (SELECT 1 FROM public.repos r WHERE r.id = repo_id AND r.tenant = mergestat.worker_tenant())) '
+            'WITH CHECK (mergestat.worker_tenant() IS NULL OR EXISTS (SELECT 1 FROM public.repos r WHERE r.id = repo_id AND r.tenant = mergestat.worker_tenant()))', t);
+    END LOOP;
+END
+$$;
+
+-- the data written by syncs (the tables of the public schema, e.g. git_commits) isn't isolated: a worker pinned to a
+-- tenant only runs the sync jobs of its tenant's repos, but its database user can still read and write the synced rows
+-- of every tenant. Deployments needing tenants' data to be isolated have to use a database per tenant.
+COMMENT ON TABLE mergestat.worker_users IS 'database users of workers pinned to a tenant, which can only read or write the providers, repos, credentials, sync variables, repo syncs, jobs and logs of their tenant (but the synced data of all tenants)';
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+
+-- the schema of the target tables of the syncs configured by their settings (HTTP_JSON_API and CONTAINER_EXEC), which
+-- replace the rows of the repo in their table. Those syncs can only write to the tables of this schema, rather than to
+-- the ones of the built-in syncs or of mergestat. The tables are created by the operator, with a repo_id column, e.g.
+--   CREATE TABLE custom_syncs.deploys (repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE, id text, environment text, deployed_at timestamptz);
+CREATE SCHEMA IF NOT EXISTS custom_syncs;
+COMMENT ON SCHEMA custom_syncs IS 'tables of the syncs configured by their settings (HTTP_JSON_API and CONTAINER_EXEC), created by the operator';
+
+GRANT USAGE ON SCHEMA custom_syncs TO mergestat_role_readonly, mergestat_role_user, mergestat_role_admin, mergestat_role_worker;
+GRANT SELECT ON ALL TABLES IN SCHEMA custom_syncs TO mergestat_role_readonly, mergestat_role_user;
+GRANT ALL PRIVILEGES ON ALL TABLES IN SCHEMA custom_syncs TO mergestat_role_admin WITH GRANT OPTION;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ALL TABLES IN SCHEMA custom_syncs TO mergestat_role_worker;
+ALTER DEFAULT PRIVILEGES IN SCHEMA custom_syncs GRANT SELECT ON TABLES TO mergestat_role_readonly, mergestat_role_user;
+ALTER DEFAULT PRIVILEGES IN SCHEMA custom_syncs GRANT ALL PRIVILEGES ON TABLES TO mergestat_role_admin WITH GRANT OPTION;
+ALTER DEFAULT PRIVILEGES IN SCHEMA custom_syncs GRANT SELECT, INSERT, UPDATE, DELETE ON TABLES TO mergestat_role_worker;
+
+COMMIT;
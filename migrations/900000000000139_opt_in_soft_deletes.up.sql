@@ -0,0 +1,87 @@
+BEGIN;
+
+-- rows that aren't synced anymore are deleted again, unless soft deletes are enabled in the settings of the repo sync
+-- ({"softDeletes": true}), in which case they are marked as deleted with _deleted_at. Rows marked as deleted before are
+-- deleted by the next sync without soft deletes. Either way, the views over the synced tables only show current rows.
+DO $$
+DECLARE
+    t TEXT;
+BEGIN
+    FOREACH t IN ARRAY ARRAY[
+        'git_commits', 'git_commit_stats', 'git_refs', 'git_files', 'git_blame',
+        'github_issues', 'github_pull_requests', 'github_pull_request_commits', 'github_pull_request_reviews',
+        'github_releases', 'github_release_assets', 'github_stargazers',
+        'github_code_scanning_alerts', 'github_dependabot_alerts', 'github_secret_scanning_alerts',
+        'bitbucket_pull_requests', 'bitbucket_pipelines', 'azure_pull_requests', 'azure_builds'
+    ] LOOP
+        IF to_regclass(FORMAT('public.%I', t)) IS NOT NULL THEN
+            EXECUTE FORMAT('COMMENT ON COLUMN public.%I._deleted_at IS %L', t,
+                'timestamp of when the row was found to be removed by a sync with soft deletes, NULL while it is current');
+        END IF;
+    END LOOP;
+END
+$$;
+
+CREATE OR REPLACE VIEW public.git_tags AS
+SELECT
+    git_refs.repo_id,
+    git_refs.full_name,
+    git_refs.hash,
+    git_refs.name,
+    git_refs.remote,
+    git_refs.target,
+    git_refs.type,
+    git_refs.tag_commit_hash,
+    git_refs._mergestat_synced_at,
+    git_refs.tag_message,
+    git_refs.tagger_name,
+    git_refs.tagger_email,
+    git_refs.tagger_when
+FROM public.git_refs
+WHERE (git_refs.type = 'tag'::text) AND git_refs._deleted_at IS NULL;
+
+CREATE OR REPLACE VIEW public.git_branches AS
+SELECT
+    git_refs.repo_id,
+    git_refs.full_name,
+    git_refs.hash,
+    git_refs.name,
+    git_refs.remote,
+    git_refs.target,
+    git_refs.type,
+    git_refs.tag_commit_hash,
+    git_refs._mergestat_synced_at,
+    git_refs.ahead,
+    git_refs.behind
+FROM public.git_refs
+WHERE (git_refs.type = 'branch'::text) AND git_refs._deleted_at IS NULL;
+
+-- github_pull_requests may have been dropped by 900000000000059_remove_empty_tables if it was never synced
+DO $$
+BEGIN
+    IF to_regclass('public.github_pull_requests') IS NOT NULL THEN
+        CREATE OR REPLACE VIEW github_pull_request_cycle_times AS
+        SELECT
+            github_pull_requests.repo_id,
+            github_pull_requests.number,
+            github_pull_requests.author_login,
+            github_pull_requests.base_ref_name,
+            github_pull_requests.head_ref_name,
+            github_pull_requests.state,
+            github_pull_requests.merged,
+            github_pull_requests.merged_by,
+            github_pull_requests.review_decision,
+            github_pull_requests.additions,
+            github_pull_requests.deletions,
+            github_pull_requests.created_at,
+            github_pull_requests.merged_at,
+            github_pull_requests.closed_at,
+            github_pull_requests.merged_at - github_pull_requests.created_at AS time_to_merge,
+            COALESCE(github_pull_requests.merged_at, github_pull_requests.closed_at) - github_pull_requests.created_at AS time_to_close
+        FROM github_pull_requests
+        WHERE github_pull_requests._deleted_at IS NULL;
+    END IF;
+END
+$$;
+
+COMMIT;
@@ -0,0 +1,36 @@
+BEGIN;
+
+-- failed sync jobs are retried with an exponential backoff, rather than right away: the nth retry of a job waits
+-- retry_backoff_seconds * 2^(n-1) seconds (doubling at most 10 times), so that e.g. a rate limited or unreachable
+-- provider isn't hammered by the job until it runs out of attempts.
+ALTER TABLE mergestat.repo_sync_types ADD COLUMN IF NOT EXISTS retry_backoff_seconds INTEGER DEFAULT 60 NOT NULL CHECK (retry_backoff_seconds >= 0);
+COMMENT ON COLUMN mergestat.repo_sync_types.retry_backoff_seconds IS 'number of seconds a failed sync of this type waits before its first retry, doubling with each further retry';
+
+ALTER TABLE mergestat.repo_sync_queue ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP WITH TIME ZONE;
+COMMENT ON COLUMN mergestat.repo_sync_queue.next_attempt_at IS 'time before which a re-queued (failed) sync job is not dequeued, NULL if it can run right away';
+
+CREATE INDEX IF NOT EXISTS idx_repo_sync_queue_next_attempt_at ON mergestat.repo_sync_queue (next_attempt_at) WHERE status = 'QUEUED';
+
+-- re-queued dead jobs run right away
+CREATE OR REPLACE FUNCTION mergestat.requeue_dead_repo_syncs(provider_id UUID DEFAULT NULL, sync_type TEXT DEFAULT NULL)
+RETURNS INTEGER
+AS
+$$
+DECLARE _rows_requeued INTEGER;
+BEGIN
+    UPDATE mergestat.repo_sync_queue rsq SET status = 'QUEUED', attempts = 0, next_attempt_at = NULL
+    FROM mergestat.repo_syncs rs, public.repos r
+    WHERE rsq.status = 'DEAD'
+        AND rs.id = rsq.repo_sync_id
+        AND r.id = rs.repo_id
+        AND (requeue_dead_repo_syncs.provider_id IS NULL OR r.provider = requeue_dead_repo_syncs.provider_id)
+        AND (requeue_dead_repo_syncs.sync_type IS NULL OR rs.sync_type = requeue_dead_repo_syncs.sync_type)
+        -- only the latest run of a repo sync is re-queued, if a newer one was enqueued since it's left alone
+        AND rsq.id = (SELECT MAX(id) FROM mergestat.repo_sync_queue WHERE repo_sync_id = rsq.repo_sync_id);
+    GET DIAGNOSTICS _rows_requeued = ROW_COUNT;
+
+    RETURN _rows_requeued;
+END;
+$$ LANGUAGE plpgsql;
+
+COMMIT;
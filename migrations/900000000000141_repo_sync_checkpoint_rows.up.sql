@@ -0,0 +1,26 @@
+BEGIN;
+
+-- the rows fetched by a running sync job, staged along with its checkpoint (which then only holds its cursors, e.g. the
+-- page it's at), so that saving the checkpoint only writes the rows fetched since it was last saved
+CREATE TABLE IF NOT EXISTS mergestat.repo_sync_checkpoint_rows (
+    id bigserial NOT NULL PRIMARY KEY,
+    repo_sync_queue_id bigint NOT NULL REFERENCES mergestat.repo_sync_queue(id) ON DELETE CASCADE ON UPDATE RESTRICT,
+    kind text NOT NULL,
+    data jsonb NOT NULL,
+    created_at timestamp with time zone DEFAULT now() NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_repo_sync_checkpoint_rows_repo_sync_queue_id ON mergestat.repo_sync_checkpoint_rows (repo_sync_queue_id, kind, id);
+
+COMMENT ON TABLE mergestat.repo_sync_checkpoint_rows IS 'rows fetched by a running repo sync job, saved along with its checkpoint';
+COMMENT ON COLUMN mergestat.repo_sync_checkpoint_rows.repo_sync_queue_id IS 'foreign key for mergestat.repo_sync_queue.id';
+COMMENT ON COLUMN mergestat.repo_sync_checkpoint_rows.kind IS 'sync specific kind of the rows (e.g. pull requests or their commits)';
+COMMENT ON COLUMN mergestat.repo_sync_checkpoint_rows.data IS 'JSON array of the rows fetched since the checkpoint was previously saved';
+COMMENT ON COLUMN mergestat.repo_sync_checkpoint_rows.created_at IS 'timestamp when the rows were saved';
+COMMENT ON COLUMN mergestat.repo_sync_checkpoints.state IS 'sync specific state (e.g. the page a paginated API sync is at), the rows it fetched so far are in mergestat.repo_sync_checkpoint_rows';
+
+-- checkpoints saved by previous versions of the syncs held all the rows fetched so far, they're dropped (and the jobs
+-- start over) rather than resumed
+DELETE FROM mergestat.repo_sync_checkpoints;
+
+COMMIT;
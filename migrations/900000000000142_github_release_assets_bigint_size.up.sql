@@ -0,0 +1,12 @@
+BEGIN;
+
+-- release assets can be up to 2 GiB, which overflows an integer
+DO $$
+BEGIN
+    IF to_regclass('public.github_release_assets') IS NOT NULL THEN
+        ALTER TABLE public.github_release_assets ALTER COLUMN size TYPE bigint;
+    END IF;
+END
+$$;
+
+COMMIT;
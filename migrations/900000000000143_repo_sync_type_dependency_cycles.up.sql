@@ -0,0 +1,33 @@
+BEGIN;
+
+-- dependencies of sync types can't form a cycle (which would keep the jobs of the sync types in it from ever being
+-- dequeued), rather than only a sync type depending on itself. Adding (or changing) a dependency is rejected if the
+-- sync type it depends on already depends on the dependent sync type, directly or through others.
+CREATE OR REPLACE FUNCTION mergestat.repo_sync_type_dependencies_cycle_trigger() RETURNS trigger
+LANGUAGE plpgsql
+AS $$
+BEGIN
+    -- dependencies added concurrently are checked one after the other, so that they can't form a cycle together
+    PERFORM pg_advisory_xact_lock(hashtext('mergestat.repo_sync_type_dependencies'));
+
+    IF EXISTS (
+        WITH RECURSIVE dependencies(sync_type) AS (
+            SELECT NEW.depends_on
+            UNION
+            SELECT d.depends_on FROM mergestat.repo_sync_type_dependencies d
+                INNER JOIN dependencies ON d.sync_type = dependencies.sync_type
+        )
+        SELECT 1 FROM dependencies WHERE sync_type = NEW.sync_type
+    ) THEN
+        RAISE EXCEPTION 'sync type % can''t depend on %, as % already depends on it', NEW.sync_type, NEW.depends_on, NEW.depends_on
+            USING ERRCODE = 'check_violation';
+    END IF;
+
+    RETURN NULL;
+END;
+$$;
+
+DROP TRIGGER IF EXISTS repo_sync_type_dependencies_cycle_trigger ON mergestat.repo_sync_type_dependencies;
+CREATE TRIGGER repo_sync_type_dependencies_cycle_trigger AFTER INSERT OR UPDATE ON mergestat.repo_sync_type_dependencies FOR EACH ROW EXECUTE FUNCTION mergestat.repo_sync_type_dependencies_cycle_trigger();
+
+COMMIT;
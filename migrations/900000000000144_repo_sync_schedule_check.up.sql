@@ -0,0 +1,75 @@
+BEGIN;
+
+-- whether the schedule of a repo sync can be parsed by the scheduler (see cron.ParseStandard): a standard cron expression
+-- of 5 fields (e.g. 0 3 * * 0, with names of months and days of the week), a descriptor (e.g. @weekly) or an interval
+-- (e.g. @every 15m), optionally in a time zone (e.g. CRON_TZ=Europe/Berlin 0 3 * * *). The time zone itself isn't checked.
+CREATE OR REPLACE FUNCTION mergestat.valid_sync_schedule(schedule TEXT) RETURNS BOOLEAN
+LANGUAGE plpgsql IMMUTABLE
+AS $$
+DECLARE
+    _spec TEXT;
+    _fields TEXT[];
+    _expr TEXT;
+    _parts TEXT[];
+    _low INTEGER;
+    _high INTEGER;
+    -- bounds of the minute, hour, day of month, month and day of week fields
+    _min INTEGER[] := ARRAY[0, 0, 1, 1, 0];
+    _max INTEGER[] := ARRAY[59, 23, 31, 12, 6];
+BEGIN
+    IF schedule IS NULL THEN
+        RETURN TRUE;
+    END IF;
+
+    _spec := regexp_replace(btrim(schedule), '^(CRON_)?TZ=\S+\s+', '');
+
+    IF _spec ~* '^@(yearly|annually|monthly|weekly|daily|midnight|hourly)$' THEN
+        RETURN TRUE;
+    ELSEIF _spec ~ '^@every\s' THEN
+        RETURN _spec ~ '^@every\s+(0|(([0-9]+(\.[0-9]*)?|\.[0-9]+)(ns|us|µs|μs|ms|s|m|h))+)$';
+    END IF;
+
+    _fields := regexp_split_to_array(_spec, '\s+');
+    IF array_length(_fields, 1) <> 5 THEN
+        RETURN FALSE;
+    END IF;
+
+    FOR i IN 1..5 LOOP
+        FOREACH _expr IN ARRAY string_to_array(_fields[i], ',') LOOP
+            -- a range (*, ?, a value, or two values separated by -) with an optional step, e.g. 1-30/5
+            _parts := regexp_match(_expr, '^(\*|\?|([0-9A-Za-z]+)(-([0-9A-Za-z]+))?)(/([0-9]{1,9}))?$');
+            IF _parts IS NULL OR _parts[6]::INTEGER = 0 THEN
+                RETURN FALSE;
+            END IF;
+
+            IF _parts[2] IS NOT NULL THEN
+                _low := mergestat.sync_schedule_value(_parts[2], i);
+                _high := CASE WHEN _parts[4] IS NULL THEN _low ELSE mergestat.sync_schedule_value(_parts[4], i) END;
+                IF _low IS NULL OR _high IS NULL OR _low < _min[i] OR _high > _max[i] OR _low > _high THEN
+                    RETURN FALSE;
+                END IF;
+            END IF;
+        END LOOP;
+    END LOOP;
+
+    RETURN TRUE;
+END;
+$$;
+
+-- the value of a field of a cron expression, which is a number or (for months and days of the week) a name, NULL if invalid
+CREATE OR REPLACE FUNCTION mergestat.sync_schedule_value(value TEXT, field INTEGER) RETURNS INTEGER
+LANGUAGE SQL IMMUTABLE
+AS $$
+    SELECT CASE
+        WHEN value ~ '^[0-9]{1,9}$' THEN value::INTEGER
+        WHEN field = 4 THEN array_position(ARRAY['jan', 'feb', 'mar', 'apr', 'may', 'jun', 'jul', 'aug', 'sep', 'oct', 'nov', 'dec'], lower(value))
+        WHEN field = 5 THEN array_position(ARRAY['sun', 'mon', 'tue', 'wed', 'thu', 'fri', 'sat'], lower(value)) - 1
+    END;
+$$;
+
+-- schedules are checked as they're written, rather than only once the scheduler parses them. Existing schedules aren't
+-- checked (NOT VALID), an invalid one is reported by the scheduler instead.
+ALTER TABLE mergestat.repo_syncs DROP CONSTRAINT IF EXISTS repo_syncs_schedule_check;
+ALTER TABLE mergestat.repo_syncs ADD CONSTRAINT repo_syncs_schedule_check CHECK (mergestat.valid_sync_schedule(schedule)) NOT VALID;
+
+COMMIT;
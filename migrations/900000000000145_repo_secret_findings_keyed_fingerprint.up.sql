@@ -0,0 +1,22 @@
+BEGIN;
+
+-- the fingerprints of secrets are now keyed (with the ENCRYPTION_SECRET of the workers), as the plain SHA-256 hash of a
+-- short (or guessable) secret could be brute-forced. The unkeyed fingerprints of the findings of earlier scans are
+-- cleared (once, while the column is still NOT NULL), and set again (keyed) as the repos are scanned again.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = 'public' AND table_name = 'repo_secret_findings' AND column_name = 'fingerprint' AND is_nullable = 'NO'
+    ) THEN
+        ALTER TABLE public.repo_secret_findings ALTER COLUMN fingerprint DROP NOT NULL;
+        UPDATE public.repo_secret_findings SET fingerprint = NULL;
+    END IF;
+
+    IF to_regclass('public.repo_secret_findings') IS NOT NULL THEN
+        COMMENT ON COLUMN public.repo_secret_findings.fingerprint IS 'HMAC-SHA256 of the secret (keyed with the ENCRYPTION_SECRET of the workers), to track the same secret across files and commits, NULL for findings of scans from before it was keyed';
+    END IF;
+END
+$$;
+
+COMMIT;
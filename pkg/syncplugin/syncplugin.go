@@ -0,0 +1,132 @@
+// Package syncplugin lets sync types be shipped out-of-tree, rather than by forking internal/syncer. A plugin is a Go
+// package that registers its sync types from an init function (much like a database/sql driver), and that is compiled
+// into the worker with a blank import (see cmd/worker/plugins.go):
+//
+//	func init() {
+//		syncplugin.Register(syncplugin.SyncType{
+//			Name:        "ACME_DEPLOYS",
+//			Description: "Retrieves the deploys of a repo from the ACME deploy tracker",
+//			ShortName:   "ACME Deploys",
+//			Schema:      `CREATE TABLE IF NOT EXISTS public.acme_deploys (repo_id uuid NOT NULL REFERENCES public.repos(id) ON DELETE CASCADE, ...)`,
+//			Handler:     syncplugin.HandlerFunc(syncDeploys),
+//		})
+//	}
+//
+// The tables of the registered sync types are created (with their Schema) along with the migrations of the worker, so by
+// the owner of the schema (and not when SKIP_MIGRATIONS is set). When it starts, the worker adds the sync types to
+// mergestat.repo_sync_types, after which they can be enabled for repos like the built-in ones.
+package syncplugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// Job is a job of a plugin sync type, as handed to its Handler
+type Job struct {
+	ID       int64
+	SyncType string
+	RepoID   uuid.UUID
+	Repo     string          // the url of the repo
+	Settings json.RawMessage // the settings of the repo sync, {} if none
+
+	// Runtime is provided by the worker running the job
+	Runtime
+}
+
+// Runtime is what the worker running a job provides to its handler
+type Runtime interface {
+	// Credentials returns the credentials of the provider of the repo (e.g. a GitHub token), if any
+	Credentials(ctx context.Context) (username, token string, err error)
+	// Variable returns the value of the sync variable of the repo with the given key, false if it has none
+	Variable(ctx context.Context, key string) (string, bool, error)
+	// Clone clones the repo into the given directory, or returns the path of the repo if it's a local one
+	Clone(ctx context.Context, dir string) (string, error)
+	// Log adds a message to the logs of the job, as shown in the UI
+	Log(ctx context.Context, message string) error
+}
+
+// Handler syncs the data of a job. Everything it writes with tx is committed (or discarded, e.g. in dry-run mode) by
+// the worker once the handler returns without an error.
+type Handler interface {
+	Sync(ctx context.Context, job *Job, tx pgx.Tx) error
+}
+
+// HandlerFunc adapts a function into a Handler
+type HandlerFunc func(ctx context.Context, job *Job, tx pgx.Tx) error
+
+// Sync calls f(ctx, job, tx)
+func (f HandlerFunc) Sync(ctx context.Context, job *Job, tx pgx.Tx) error { return f(ctx, job, tx) }
+
+// SyncType is a sync type provided by a plugin
+type SyncType struct {
+	// Name is the (unique) name of the sync type, e.g. ACME_DEPLOYS
+	Name        string
+	Description string
+	ShortName   string
+	// Group is the type group of the sync type (which limits how many of its syncs run at once), DEFAULT if empty
+	Group string
+	// Schema is SQL run along with the migrations of the worker to create the tables (of the public schema) of the sync
+	// type, so it has to be idempotent (e.g. CREATE TABLE IF NOT EXISTS)
+	Schema  string
+	Handler Handler
+}
+
+// syncTypeName matches the valid names of a sync type
+var syncTypeName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+
+var (
+	mu        sync.RWMutex
+	syncTypes = make(map[string]SyncType)
+)
+
+// Register makes a sync type available to the worker. It panics if the sync type is invalid, or if a sync type of
+// the same name is already registered.
+func Register(t SyncType) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !syncTypeName.MatchString(t.Name) {
+		panic(fmt.Sprintf("syncplugin: invalid sync type name %q", t.Name))
+	}
+	if t.Handler == nil {
+		panic(fmt.Sprintf("syncplugin: sync type %s has no handler", t.Name))
+	}
+	if _, dup := syncTypes[t.Name]; dup {
+		panic(fmt.Sprintf("syncplugin: sync type %s registered twice", t.Name))
+	}
+
+	if t.Group == "" {
+		t.Group = "DEFAULT"
+	}
+	syncTypes[t.Name] = t
+}
+
+// Lookup returns the registered sync type of the given name
+func Lookup(name string) (SyncType, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	t, ok := syncTypes[name]
+	return t, ok
+}
+
+// SyncTypes returns the registered sync types, sorted by name
+func SyncTypes() []SyncType {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var result = make([]SyncType, 0, len(syncTypes))
+	for _, t := range syncTypes {
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}